@@ -2,11 +2,17 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"mix/internal/config"
+	"mix/internal/llm/models"
 	"mix/internal/llm/provider"
 	"mix/internal/logging"
 
@@ -16,96 +22,374 @@ import (
 var authCmd = &cobra.Command{
 	Use:   "auth",
 	Short: "Manage authentication credentials",
-	Long: `Manage authentication credentials for various AI providers.
-	
-Currently supports:
-  - anthropic-claude-pro-max: Claude Code OAuth authentication
-  - openai: OpenAI ChatGPT OAuth authentication`,
+	Long: `Manage authentication credentials for AI providers.
+
+Anthropic and OpenAI sign in with OAuth (a Claude Pro/Max or ChatGPT
+subscription) or an API key; Gemini, Groq, Azure, OpenRouter and xAI use an
+API key. Credentials are kept in the encrypted credential store in
+~/.mix/credentials.`,
 }
 
 var authAddCmd = &cobra.Command{
-	Use:   "add [provider]",
+	Use:   "add <provider>",
 	Short: "Add authentication for a provider",
 	Long: `Add authentication credentials for a specific AI provider.
 
 Supported providers:
-  - anthropic-claude-pro-max: Authenticate with Claude using OAuth
-  - openai: Authenticate with OpenAI using OAuth
+  - anthropic (or anthropic-claude-pro-max): OAuth, or an API key with --method api-key
+  - openai: OAuth, or an API key with --method api-key
+  - gemini, groq, azure, openrouter, xai: API key
+
+The API key is read from --api-key, or asked for. It replaces the config file
+and environment key of the provider until removed with mix auth remove.
+
+With --no-browser, nothing is opened or listened on locally, for signing in
+on a remote machine over SSH: Anthropic prints the URL to open elsewhere and
+asks for the code it shows, and OpenAI uses a device code entered at
+auth.openai.com.
 
 Examples:
-  mix auth add anthropic-claude-pro-max
-  mix auth add openai`,
+  mix auth add anthropic
+  mix auth add openai --no-browser
+  mix auth add anthropic --method api-key
+  mix auth add gemini --api-key "$GEMINI_KEY"`,
 	Args: cobra.ExactArgs(1),
 	RunE: handleAuthAdd,
 }
 
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored credentials",
+	Long: `List the OAuth sign-ins and API keys in the credential store, which
+mix auth remove can remove. Keys are shown by their last characters only.`,
+	Args: cobra.NoArgs,
+	RunE: handleAuthList,
+}
+
+var authRemoveCmd = &cobra.Command{
+	Use:   "remove <provider>",
+	Short: "Remove stored credentials for a provider",
+	Long: `Remove a provider's OAuth sign-in and stored API key from the credential
+store. The provider falls back to the key in the config file or its
+environment variable, if any.
+
+Examples:
+  mix auth remove openai
+  mix auth remove anthropic --method oauth`,
+	Args: cobra.ExactArgs(1),
+	RunE: handleAuthRemove,
+}
+
 var authStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show authentication status",
-	Long:  `Display the current authentication status for all configured providers.`,
-	RunE:  handleAuthStatus,
+	Long: `Display how each provider authenticates: the credential in use (oauth,
+stored, config, env, cloud or none) and the other credentials available, in
+the order they are tried.`,
+	Args: cobra.NoArgs,
+	RunE: handleAuthStatus,
+}
+
+const (
+	authMethodOAuth  = "oauth"
+	authMethodAPIKey = "api-key"
+)
+
+// authProvider resolves a provider name given to the auth commands.
+func authProvider(name string) (models.ModelProvider, error) {
+	if name == "anthropic-claude-pro-max" {
+		return models.ProviderAnthropic, nil
+	}
+	p := models.ModelProvider(strings.ToLower(name))
+	if config.APIKeyEnv(p) == "" {
+		return "", fmt.Errorf("unsupported provider: %s\n\nSupported providers: anthropic, openai, gemini, groq, azure, openrouter, xai", name)
+	}
+	return p, nil
+}
+
+// supportsOAuth reports whether a provider can sign in with a subscription.
+func supportsOAuth(p models.ModelProvider) bool {
+	return p == models.ProviderAnthropic || p == models.ProviderOpenAI
 }
 
 func handleAuthAdd(cmd *cobra.Command, args []string) error {
-	providerName := args[0]
+	p, err := authProvider(args[0])
+	if err != nil {
+		return err
+	}
+	noBrowser, _ := cmd.Flags().GetBool("no-browser")
+	method, _ := cmd.Flags().GetString("method")
+	apiKey, _ := cmd.Flags().GetString("api-key")
+
+	switch {
+	case apiKey != "":
+		method = authMethodAPIKey
+	case method == "" && supportsOAuth(p):
+		method = authMethodOAuth
+	case method == "":
+		method = authMethodAPIKey
+	}
 
-	switch providerName {
-	case "anthropic-claude-pro-max", "anthropic":
-		return handleAnthropicOAuth()
-	case "openai":
-		return handleOpenAIOAuth()
+	switch {
+	case method == authMethodAPIKey:
+		return handleAPIKeyAdd(p, apiKey)
+	case method != authMethodOAuth:
+		return fmt.Errorf("invalid method %q: must be %s or %s", method, authMethodOAuth, authMethodAPIKey)
+	case p == models.ProviderAnthropic:
+		return handleAnthropicOAuth(noBrowser)
+	case p == models.ProviderOpenAI:
+		return handleOpenAIOAuth(noBrowser)
 	default:
-		return fmt.Errorf("unsupported provider: %s\n\nSupported providers:\n  - anthropic-claude-pro-max\n  - openai", providerName)
+		return fmt.Errorf("%s does not support OAuth, use an API key instead: mix auth add %s --method api-key", p, p)
 	}
 }
 
-func handleAuthStatus(cmd *cobra.Command, args []string) error {
+// handleAPIKeyAdd stores a provider's API key, asking for it when apiKey is
+// empty.
+func handleAPIKeyAdd(p models.ModelProvider, apiKey string) error {
+	reader := bufio.NewReader(os.Stdin)
+	for apiKey == "" {
+		fmt.Printf("%s API key: ", p)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		apiKey = strings.TrimSpace(line)
+	}
+
+	if err := provider.SetAPIKey(p, apiKey); err != nil {
+		return fmt.Errorf("failed to store API key: %w", err)
+	}
+	fmt.Printf("✅ %s API key stored securely\n", p)
+	return nil
+}
+
+// authCredential is a credential in the credential store.
+type authCredential struct {
+	Provider string `json:"provider"`
+	// Type is oauth or api-key
+	Type      string `json:"type"`
+	KeyHint   string `json:"keyHint,omitempty"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+	Expired   bool   `json:"expired,omitempty"`
+	UpdatedAt int64  `json:"updatedAt,omitempty"`
+}
+
+func storedCredentials(storage *provider.CredentialStorage) ([]authCredential, error) {
+	var credentials []authCredential
+	anthropic, err := storage.GetOAuthCredentials("anthropic")
+	if err != nil {
+		return nil, err
+	}
+	if anthropic != nil {
+		credentials = append(credentials, authCredential{
+			Provider:  string(models.ProviderAnthropic),
+			Type:      authMethodOAuth,
+			ExpiresAt: anthropic.ExpiresAt,
+			Expired:   anthropic.IsTokenExpired(),
+		})
+	}
+	openai, err := storage.GetOpenAICredentials("openai")
+	if err != nil {
+		return nil, err
+	}
+	if openai != nil {
+		credentials = append(credentials, authCredential{
+			Provider:  string(models.ProviderOpenAI),
+			Type:      authMethodOAuth,
+			ExpiresAt: openai.ExpiresAt,
+			Expired:   openai.IsTokenExpired(),
+		})
+	}
+
+	apiKeys, err := storage.APIKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(apiKeys)) {
+		credentials = append(credentials, authCredential{
+			Provider:  name,
+			Type:      authMethodAPIKey,
+			KeyHint:   provider.KeyHint(apiKeys[name].Key),
+			UpdatedAt: apiKeys[name].UpdatedAt,
+		})
+	}
+	return credentials, nil
+}
+
+func handleAuthList(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
 	storage, err := provider.NewCredentialStorage()
 	if err != nil {
 		return fmt.Errorf("failed to initialize credential storage: %w", err)
 	}
+	credentials, err := storedCredentials(storage)
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("Authentication Status:")
-	fmt.Println("=====================")
+	if asJSON {
+		return printJSON(append([]authCredential{}, credentials...))
+	}
+	if len(credentials) == 0 {
+		fmt.Println("No stored credentials. Add one with mix auth add <provider>.")
+		return nil
+	}
+	for _, credential := range credentials {
+		switch {
+		case credential.Type == authMethodAPIKey:
+			fmt.Printf("%-12s api-key  %s\n", credential.Provider, credential.KeyHint)
+		case credential.Expired:
+			fmt.Printf("%-12s oauth    expired, refreshed on next use\n", credential.Provider)
+		default:
+			fmt.Printf("%-12s oauth    expires in ~%.0f minutes\n", credential.Provider, time.Until(time.Unix(credential.ExpiresAt, 0)).Minutes())
+		}
+	}
+	return nil
+}
 
-	// Check Anthropic OAuth
-	creds, err := storage.GetOAuthCredentials("anthropic")
+func handleAuthRemove(cmd *cobra.Command, args []string) error {
+	p, err := authProvider(args[0])
 	if err != nil {
-		fmt.Printf("❌ Anthropic Claude Pro Max: Error checking credentials (%v)\n", err)
-	} else if creds != nil {
-		if creds.IsTokenExpired() {
-			fmt.Printf("⚠️  Anthropic Claude Pro Max: Token expired, refresh needed\n")
-		} else {
-			fmt.Printf("✅ Anthropic Claude Pro Max: Authenticated (expires in ~%.0f minutes)\n",
-				float64(creds.ExpiresAt-time.Now().Unix())/60)
+		return err
+	}
+	method, _ := cmd.Flags().GetString("method")
+	if method != "" && method != authMethodOAuth && method != authMethodAPIKey {
+		return fmt.Errorf("invalid method %q: must be %s or %s", method, authMethodOAuth, authMethodAPIKey)
+	}
+
+	storage, err := provider.NewCredentialStorage()
+	if err != nil {
+		return fmt.Errorf("failed to initialize credential storage: %w", err)
+	}
+	if method != authMethodAPIKey {
+		switch p {
+		case models.ProviderAnthropic:
+			err = storage.ClearOAuthCredentials("anthropic")
+		case models.ProviderOpenAI:
+			err = storage.ClearOpenAICredentials("openai")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove OAuth credentials: %w", err)
 		}
-	} else {
-		fmt.Printf("❌ Anthropic Claude Pro Max: Not authenticated\n")
 	}
+	if method != authMethodOAuth {
+		if err := provider.SetAPIKey(p, ""); err != nil {
+			return fmt.Errorf("failed to remove API key: %w", err)
+		}
+	}
+
+	fmt.Printf("✅ Removed stored %s credentials\n", p)
+	return nil
+}
 
-	// Check OpenAI OAuth
-	openaiCreds, err := storage.GetOpenAICredentials("openai")
+// authStatus is a provider's authentication, as reported by mix auth status.
+type authStatus struct {
+	Provider   string `json:"provider"`
+	Configured bool   `json:"configured"`
+	Disabled   bool   `json:"disabled"`
+	Method     string `json:"method"`
+	OAuth      bool   `json:"oauth"`
+	// OAuthExpiresAt is when the OAuth access token expires; it is refreshed
+	// automatically
+	OAuthExpiresAt int64  `json:"oauthExpiresAt,omitempty"`
+	StoredKey      bool   `json:"storedKey"`
+	ConfigKey      bool   `json:"configKey"`
+	EnvKey         bool   `json:"envKey"`
+	EnvVar         string `json:"envVar,omitempty"`
+	KeyHint        string `json:"keyHint,omitempty"`
+}
+
+func handleAuthStatus(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	// Config file keys are reported when the config loads; authentication
+	// can be checked before mix init, so a missing or invalid config is fine
+	if cwd, err := os.Getwd(); err == nil {
+		if _, err := config.Load(cwd, false, false); err != nil {
+			logging.Debug("Auth status without config file keys", "error", err)
+		}
+	}
+
+	storage, err := provider.NewCredentialStorage()
 	if err != nil {
-		fmt.Printf("❌ OpenAI: Error checking credentials (%v)\n", err)
-	} else if openaiCreds != nil {
-		if openaiCreds.IsTokenExpired() {
-			fmt.Printf("⚠️  OpenAI: Token expired, refresh needed\n")
-		} else {
-			fmt.Printf("✅ OpenAI: Authenticated (expires in ~%.0f minutes)\n",
-				float64(openaiCreds.ExpiresAt-time.Now().Unix())/60)
+		return fmt.Errorf("failed to initialize credential storage: %w", err)
+	}
+	statuses, err := provider.AuthStatuses()
+	if err != nil {
+		return err
+	}
+	credentials, err := storedCredentials(storage)
+	if err != nil {
+		return err
+	}
+	expiresAt := make(map[string]int64)
+	for _, credential := range credentials {
+		if credential.Type == authMethodOAuth {
+			expiresAt[credential.Provider] = credential.ExpiresAt
 		}
-	} else {
-		fmt.Printf("❌ OpenAI: Not authenticated\n")
 	}
 
+	result := make([]authStatus, 0, len(statuses))
+	for _, status := range statuses {
+		result = append(result, authStatus{
+			Provider:       string(status.Provider),
+			Configured:     status.Configured,
+			Disabled:       status.Disabled,
+			Method:         string(status.Method),
+			OAuth:          status.OAuth,
+			OAuthExpiresAt: expiresAt[string(status.Provider)],
+			StoredKey:      status.StoredKey,
+			ConfigKey:      status.ConfigKey,
+			EnvKey:         status.EnvKey,
+			EnvVar:         status.EnvVar,
+			KeyHint:        status.KeyHint,
+		})
+	}
+	if asJSON {
+		return printJSON(result)
+	}
+
+	fmt.Println("Authentication Status:")
+	fmt.Println("=====================")
+	for _, status := range result {
+		switch provider.AuthMethod(status.Method) {
+		case provider.AuthOAuth:
+			fmt.Printf("✅ %s: OAuth", status.Provider)
+			if status.OAuthExpiresAt > 0 {
+				fmt.Printf(" (expires in ~%.0f minutes)", time.Until(time.Unix(status.OAuthExpiresAt, 0)).Minutes())
+			}
+			fmt.Println()
+		case provider.AuthStoredKey:
+			fmt.Printf("✅ %s: stored API key %s\n", status.Provider, status.KeyHint)
+		case provider.AuthConfigKey:
+			fmt.Printf("✅ %s: config file API key %s\n", status.Provider, status.KeyHint)
+		case provider.AuthEnvKey:
+			fmt.Printf("✅ %s: %s %s\n", status.Provider, status.EnvVar, status.KeyHint)
+		case provider.AuthCloud:
+			fmt.Printf("✅ %s: cloud credentials\n", status.Provider)
+		default:
+			fmt.Printf("❌ %s: Not authenticated\n", status.Provider)
+		}
+	}
+
+	fmt.Printf("\nCredentials key: %s\n", storage.KeyStorage())
+
 	fmt.Println("\nTo authenticate:")
-	fmt.Println("  mix auth add anthropic-claude-pro-max  # Claude Code OAuth")
-	fmt.Println("  mix auth add openai                   # OpenAI OAuth")
+	fmt.Println("  mix auth add anthropic            # Claude Pro/Max OAuth")
+	fmt.Println("  mix auth add openai               # ChatGPT OAuth")
+	fmt.Println("  mix auth add <provider> --method api-key")
 
 	return nil
 }
 
-func handleAnthropicOAuth() error {
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func handleAnthropicOAuth(noBrowser bool) error {
 	fmt.Println("🔐 Authenticating with Claude Code OAuth...")
 	fmt.Println()
 
@@ -145,7 +429,11 @@ func handleAnthropicOAuth() error {
 
 	// Display auth URL and try to open browser
 	authURL := oauthFlow.GetAuthorizationURL()
-	fmt.Printf("🌐 Opening browser for authentication...\n")
+	if noBrowser {
+		fmt.Printf("🌐 Open this URL in a browser on any device:\n")
+	} else {
+		fmt.Printf("🌐 Opening browser for authentication...\n")
+	}
 	fmt.Printf("   URL: %s\n", authURL)
 	fmt.Println()
 
@@ -157,9 +445,11 @@ func handleAnthropicOAuth() error {
 	fmt.Println()
 
 	// Try to open browser
-	if err := oauthFlow.OpenBrowser(); err != nil {
-		fmt.Printf("⚠️  Failed to open browser automatically: %v\n", err)
-		fmt.Printf("   Please manually open the URL above in your browser.\n")
+	if !noBrowser {
+		if err := oauthFlow.OpenBrowser(); err != nil {
+			fmt.Printf("⚠️  Failed to open browser automatically: %v\n", err)
+			fmt.Printf("   Please manually open the URL above in your browser.\n")
+		}
 	}
 
 	// Instructions for user
@@ -231,7 +521,7 @@ func handleAnthropicOAuth() error {
 	return nil
 }
 
-func handleOpenAIOAuth() error {
+func handleOpenAIOAuth(noBrowser bool) error {
 	fmt.Println("🔐 Authenticating with OpenAI OAuth...")
 	fmt.Println()
 
@@ -263,12 +553,6 @@ func handleOpenAIOAuth() error {
 		fmt.Println()
 	}
 
-	// Create OAuth flow
-	oauthFlow, err := provider.NewOpenAIOAuthFlow()
-	if err != nil {
-		return fmt.Errorf("failed to create OAuth flow: %w", err)
-	}
-
 	// Important: Prerequisites for OAuth authentication
 	fmt.Printf("⚠️  PREREQUISITES - You must complete these steps first:\n")
 	fmt.Printf("   1. Log into https://chat.openai.com (ChatGPT Plus/Pro required)\n")
@@ -278,14 +562,13 @@ func handleOpenAIOAuth() error {
 	fmt.Printf("   Without these, authentication will fail with 'missing organization or project ID' error.\n")
 	fmt.Println()
 
-	// Start OAuth flow
-	fmt.Printf("🌐 Starting OAuth flow...\n")
-	fmt.Printf("   A browser window will open for authentication\n")
-	fmt.Printf("   The system will automatically handle the callback\n")
-	fmt.Println()
-
-	// Start the authentication flow
-	credentials, err := oauthFlow.StartAuthFlow()
+	var credentials *provider.OpenAICredentials
+	if noBrowser {
+		// The device code flow stores the credentials itself
+		credentials, err = openAIDeviceCodeLogin()
+	} else {
+		credentials, err = openAIBrowserLogin(storage)
+	}
 	if err != nil {
 		fmt.Printf("❌ Authentication failed: %v\n", err)
 		fmt.Println()
@@ -299,12 +582,6 @@ func handleOpenAIOAuth() error {
 		return err
 	}
 
-	// Store credentials
-	err = storage.StoreOpenAICredentials("openai", credentials)
-	if err != nil {
-		return fmt.Errorf("failed to store credentials: %w", err)
-	}
-
 	// Success message
 	fmt.Println()
 	fmt.Println("🎉 Authentication successful!")
@@ -320,8 +597,58 @@ func handleOpenAIOAuth() error {
 	return nil
 }
 
+// openAIBrowserLogin signs in through the browser, with the callback on a
+// local port, and stores the credentials.
+func openAIBrowserLogin(storage *provider.CredentialStorage) (*provider.OpenAICredentials, error) {
+	oauthFlow, err := provider.NewOpenAIOAuthFlow()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OAuth flow: %w", err)
+	}
+
+	fmt.Printf("🌐 Starting OAuth flow...\n")
+	fmt.Printf("   A browser window will open for authentication\n")
+	fmt.Printf("   The system will automatically handle the callback\n")
+	fmt.Println()
+
+	credentials, err := oauthFlow.StartAuthFlow()
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.StoreOpenAICredentials("openai", credentials); err != nil {
+		return nil, fmt.Errorf("failed to store credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// openAIDeviceCodeLogin signs in with a code the user enters on another
+// device.
+func openAIDeviceCodeLogin() (*provider.OpenAICredentials, error) {
+	ctx := context.Background()
+	deviceCode, err := provider.RequestOpenAIDeviceCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("🌐 On any device, open:\n")
+	fmt.Printf("   %s\n", deviceCode.VerificationURL)
+	fmt.Printf("   and enter the code: %s\n", deviceCode.UserCode)
+	fmt.Println()
+	fmt.Printf("⏳ Waiting for authorization (the code expires in %.0f minutes)...\n", time.Until(deviceCode.ExpiresAt).Minutes())
+
+	return deviceCode.Wait(ctx)
+}
+
 func init() {
+	authAddCmd.Flags().Bool("no-browser", false, "Don't open a browser or listen for a callback locally, for signing in over SSH")
+	authAddCmd.Flags().String("method", "", "oauth (anthropic and openai, the default for them) or api-key")
+	authAddCmd.Flags().String("api-key", "", "API key to store, instead of asking for it")
+	authRemoveCmd.Flags().String("method", "", "Only remove the oauth sign-in or the api-key")
+	authListCmd.Flags().Bool("json", false, "Print the credentials as JSON")
+	authStatusCmd.Flags().Bool("json", false, "Print the status as JSON")
+
 	// Add auth subcommands
 	authCmd.AddCommand(authAddCmd)
+	authCmd.AddCommand(authListCmd)
+	authCmd.AddCommand(authRemoveCmd)
 	authCmd.AddCommand(authStatusCmd)
 }
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"mix/internal/backup"
+	"mix/internal/config"
+	"mix/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up and restore the database and credentials",
+	Long: `Back up and restore the session database and stored credentials.
+
+Backups are written to the "backup" section's directory or S3 bucket, by
+default <data directory>/backups. Set "backup.enabled" to take them
+automatically while mix runs.`,
+}
+
+var backupNowCmd = &cobra.Command{
+	Use:   "now",
+	Short: "Take a backup now",
+	Args:  cobra.NoArgs,
+	RunE:  handleBackupNow,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  handleBackupList,
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore [name]",
+	Short: "Restore a backup",
+	Long: `Replace the database and stored credentials with a backup, by default the
+newest. The replaced files are kept with a .before-restore suffix.
+
+Stop any running mix server first.`,
+	Example: `
+  mix backup restore
+  mix backup restore mix-backup-20261016T020000Z.tar.gz
+  `,
+	Args: cobra.MaximumNArgs(1),
+	RunE: handleBackupRestore,
+}
+
+// loadBackupStore loads the config for the current directory and opens the
+// configured backup store.
+func loadBackupStore(ctx context.Context, cmd *cobra.Command) (backup.Store, config.BackupConfig, error) {
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, config.BackupConfig{}, fmt.Errorf("failed to get current working directory: %v", err)
+	}
+	if _, err := config.Load(cwd, debug, false); err != nil {
+		return nil, config.BackupConfig{}, err
+	}
+
+	settings := backup.Settings()
+	store, err := backup.NewStore(ctx, settings)
+	if err != nil {
+		return nil, config.BackupConfig{}, err
+	}
+	return store, settings, nil
+}
+
+func handleBackupNow(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	store, settings, err := loadBackupStore(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	dbCtx, dbCancel := context.WithTimeout(ctx, db.DBConnectionTimeout)
+	defer dbCancel()
+	conn, err := db.Connect(dbCtx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	created, err := backup.Create(ctx, conn.DB, store, settings.Keep)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s (%d bytes)\n", created.Name, created.Size)
+	return nil
+}
+
+func handleBackupList(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	store, _, err := loadBackupStore(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	backups, err := store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups")
+		return nil
+	}
+	for _, b := range backups {
+		fmt.Printf("%s  %s  %d bytes\n", b.Name, b.CreatedAt.Local().Format(time.DateTime), b.Size)
+	}
+	return nil
+}
+
+func handleBackupRestore(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	store, _, err := loadBackupStore(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	} else {
+		backups, err := store.List(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list backups: %w", err)
+		}
+		if len(backups) == 0 {
+			return fmt.Errorf("no backups to restore")
+		}
+		name = backups[0].Name
+	}
+
+	if err := backup.Restore(ctx, store, name, config.Get().Data.Directory); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s\n", name)
+	return nil
+}
+
+func init() {
+	backupCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+	backupCmd.AddCommand(backupNowCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+}
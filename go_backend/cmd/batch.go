@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"mix/internal/app"
+	"mix/internal/config"
+	"mix/internal/db"
+	"mix/internal/llm/agent"
+	"mix/internal/logging"
+
+	"github.com/spf13/cobra"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Run a JSONL file of prompts non-interactively",
+	Long: `Run every prompt in a JSONL file through the agent and write one JSON result per line.
+
+Each input line is an object with a required "prompt" and optional "id" and
+"workingDirectory" fields. Relative working directories are resolved against --cwd.
+
+Each output line contains the id, session ID, response, cost, duration and a
+status of "success", "error" or "cancelled".`,
+	Example: `
+  # Run prompts four at a time and write results to a file
+  mix batch -i prompts.jsonl -o results.jsonl -j 4
+
+  # Input line format
+  {"id": "case-1", "prompt": "Summarize README.md", "workingDirectory": "repos/a"}
+  `,
+	Args: cobra.NoArgs,
+	RunE: handleBatch,
+}
+
+// BatchPrompt is a single line of the batch input file.
+type BatchPrompt struct {
+	ID               string `json:"id,omitempty"`
+	Prompt           string `json:"prompt"`
+	WorkingDirectory string `json:"workingDirectory,omitempty"`
+}
+
+// BatchResult is a single line of the batch output file.
+type BatchResult struct {
+	ID               string  `json:"id"`
+	Prompt           string  `json:"prompt"`
+	WorkingDirectory string  `json:"workingDirectory"`
+	SessionID        string  `json:"sessionId,omitempty"`
+	Response         string  `json:"response"`
+	Cost             float64 `json:"cost"`
+	DurationMs       int64   `json:"durationMs"`
+	Status           string  `json:"status"`
+	Error            string  `json:"error,omitempty"`
+}
+
+const (
+	batchStatusSuccess   = "success"
+	batchStatusError     = "error"
+	batchStatusCancelled = "cancelled"
+)
+
+func handleBatch(cmd *cobra.Command, args []string) error {
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, _ := cmd.Flags().GetString("cwd")
+	inputPath, _ := cmd.Flags().GetString("input")
+	outputPath, _ := cmd.Flags().GetString("output")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	skipPermissions, _ := cmd.Flags().GetBool("dangerously-skip-permissions")
+
+	if concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+	}
+
+	if cwd == "" {
+		var err error
+		cwd, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current working directory: %v", err)
+		}
+	}
+
+	prompts, err := readBatchPrompts(inputPath, cwd)
+	if err != nil {
+		return err
+	}
+
+	// Results go to a file since logs are written to stdout
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := config.Load(cwd, debug, skipPermissions); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dbCtx, dbCancel := context.WithTimeout(ctx, db.DBConnectionTimeout)
+	defer dbCancel()
+	conn, err := db.Connect(dbCtx)
+	if err != nil {
+		return err
+	}
+
+	mixApp, err := app.New(ctx, conn)
+	if err != nil {
+		logging.Error("Failed to create app", "error", err)
+		return err
+	}
+	defer mixApp.Shutdown()
+
+	initMCPTools(ctx, mixApp)
+
+	failed := runBatch(ctx, mixApp, prompts, concurrency, out)
+	logging.Info("Batch run completed", "total", len(prompts), "failed", failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d prompts failed", failed, len(prompts))
+	}
+	return nil
+}
+
+// readBatchPrompts parses the JSONL input, assigning line-number IDs and
+// resolving working directories against baseDir.
+func readBatchPrompts(path string, baseDir string) ([]BatchPrompt, error) {
+	var in io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var prompts []BatchPrompt
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var p BatchPrompt
+		if err := json.Unmarshal(line, &p); err != nil {
+			return nil, fmt.Errorf("invalid JSON on line %d: %w", lineNum, err)
+		}
+		if p.Prompt == "" {
+			return nil, fmt.Errorf("missing prompt on line %d", lineNum)
+		}
+		if p.ID == "" {
+			p.ID = strconv.Itoa(lineNum)
+		}
+		if p.WorkingDirectory == "" {
+			p.WorkingDirectory = baseDir
+		} else if !filepath.IsAbs(p.WorkingDirectory) {
+			p.WorkingDirectory = filepath.Join(baseDir, p.WorkingDirectory)
+		}
+		prompts = append(prompts, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+
+	return prompts, nil
+}
+
+// runBatch runs prompts with at most concurrency in flight, writing each result
+// as soon as it finishes. It returns the number of prompts that did not succeed.
+func runBatch(ctx context.Context, mixApp *app.App, prompts []BatchPrompt, concurrency int, out io.Writer) int {
+	var (
+		wg       sync.WaitGroup
+		outMutex sync.Mutex
+		failed   int
+	)
+	encoder := json.NewEncoder(out)
+	sem := make(chan struct{}, concurrency)
+
+	for _, p := range prompts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p BatchPrompt) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer logging.RecoverPanic("batch-prompt", nil)
+
+			result := runBatchPrompt(ctx, mixApp, p)
+
+			outMutex.Lock()
+			defer outMutex.Unlock()
+			if result.Status != batchStatusSuccess {
+				failed++
+			}
+			if err := encoder.Encode(result); err != nil {
+				logging.Error("Failed to write batch result", "id", p.ID, "error", err)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	return failed
+}
+
+func runBatchPrompt(ctx context.Context, mixApp *app.App, p BatchPrompt) BatchResult {
+	start := time.Now()
+	res, err := mixApp.RunPrompt(ctx, p.Prompt, p.WorkingDirectory, nil)
+
+	result := BatchResult{
+		ID:               p.ID,
+		Prompt:           p.Prompt,
+		WorkingDirectory: p.WorkingDirectory,
+		SessionID:        res.SessionID,
+		Response:         res.Content,
+		Cost:             res.Cost,
+		DurationMs:       time.Since(start).Milliseconds(),
+		Status:           batchStatusSuccess,
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled) || errors.Is(err, agent.ErrRequestCancelled):
+		result.Status = batchStatusCancelled
+		result.Error = err.Error()
+	default:
+		result.Status = batchStatusError
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func init() {
+	batchCmd.Flags().BoolP("debug", "d", false, "Debug")
+	batchCmd.Flags().StringP("cwd", "c", "", "Base working directory for prompts without one")
+	batchCmd.Flags().StringP("input", "i", "-", "JSONL file of prompts (- for stdin)")
+	batchCmd.Flags().StringP("output", "o", "", "JSONL file for results")
+	batchCmd.Flags().IntP("concurrency", "j", 4, "Maximum number of prompts to run at once")
+	batchCmd.Flags().Bool("dangerously-skip-permissions", false, "Skip all permission prompts (DANGEROUS - use only in trusted environments)")
+	batchCmd.MarkFlagRequired("output")
+}
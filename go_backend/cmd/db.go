@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect and migrate the database schema",
+	Long: `Inspect and migrate the session database schema.
+
+Migrations are embedded in the binary and applied automatically at startup.
+Before migrating or rolling back, the database is copied to
+<data directory>/backups/mix.db.pre-migrate-<version>. Stop any running mix
+server before migrating or rolling back by hand.`,
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schema version and which migrations are applied",
+	Args:  cobra.NoArgs,
+	RunE:  handleDBStatus,
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending migrations",
+	Args:  cobra.NoArgs,
+	RunE:  handleDBMigrate,
+}
+
+var dbRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Revert the newest migration, or every migration after --to",
+	Example: `
+  mix db rollback
+  mix db rollback --to 20261016150000
+  `,
+	Args: cobra.NoArgs,
+	RunE: handleDBRollback,
+}
+
+// openDB loads the config for the current directory and opens the database
+// without migrating it.
+func openDB(cmd *cobra.Command) (*db.DB, error) {
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current working directory: %v", err)
+	}
+	if _, err := config.Load(cwd, debug, false); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), db.DBConnectionTimeout)
+	defer cancel()
+	return db.Open(ctx)
+}
+
+func handleDBStatus(cmd *cobra.Command, args []string) error {
+	conn, err := openDB(cmd)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	status, err := db.Status(context.Background(), conn.DB)
+	if err != nil {
+		return err
+	}
+	printSchemaStatus(status)
+	if status.Current > status.Latest {
+		return fmt.Errorf("%w: database is at %d, binary supports up to %d", db.ErrSchemaTooNew, status.Current, status.Latest)
+	}
+	return nil
+}
+
+func handleDBMigrate(cmd *cobra.Command, args []string) error {
+	conn, err := openDB(cmd)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), db.DBMigrationTimeout)
+	defer cancel()
+	before, err := db.Status(ctx, conn.DB)
+	if err != nil {
+		return err
+	}
+	after, err := db.Migrate(ctx, conn.DB, db.BackupDir())
+	if err != nil {
+		return err
+	}
+	if after.Current == before.Current {
+		fmt.Printf("Schema is up to date at version %d\n", after.Current)
+	} else {
+		fmt.Printf("Migrated from version %d to %d\n", before.Current, after.Current)
+	}
+	return nil
+}
+
+func handleDBRollback(cmd *cobra.Command, args []string) error {
+	to, _ := cmd.Flags().GetInt64("to")
+	conn, err := openDB(cmd)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), db.DBMigrationTimeout)
+	defer cancel()
+	before, err := db.Status(ctx, conn.DB)
+	if err != nil {
+		return err
+	}
+	after, err := db.Rollback(ctx, conn.DB, db.BackupDir(), to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back from version %d to %d\n", before.Current, after.Current)
+	return nil
+}
+
+func printSchemaStatus(status db.SchemaStatus) {
+	fmt.Printf("Schema version: %d (latest known: %d)\n\n", status.Current, status.Latest)
+	for _, m := range status.Migrations {
+		applied := "pending"
+		if m.Applied {
+			applied = m.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%-20s %s\n", applied, m.Name)
+	}
+}
+
+func init() {
+	dbCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+	dbRollbackCmd.Flags().Int64("to", 0, "Roll back every migration after this version")
+	dbCmd.AddCommand(dbStatusCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbRollbackCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"mix/internal/config"
+	"mix/internal/db"
+	"mix/internal/message"
+	"mix/internal/session"
+	"mix/internal/transcript"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <session-id>",
+	Short: "Export a session as a Markdown, HTML or JSON transcript",
+	Long: `Export a session's messages, tool calls and the media they reference as a
+transcript to share. Markdown links media by their path in the session's
+working directory; HTML is a single file with syntax-highlighted code and
+inlined thumbnails of images and videos; JSON lists the messages for other
+programs.`,
+	Example: `
+  mix export 3f2a... > session.md
+  mix export 3f2a... --format html --thinking -o session.html
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: handleExport,
+}
+
+func handleExport(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	thinking, _ := cmd.Flags().GetBool("thinking")
+
+	if !slices.Contains(transcript.Formats, format) {
+		return fmt.Errorf("--format must be one of: %s", strings.Join(transcript.Formats, ", "))
+	}
+
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current working directory: %v", err)
+	}
+	if _, err := config.Load(cwd, debug, false); err != nil {
+		return err
+	}
+	// Logs go to stderr so they can't end up in a transcript written to stdout
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	ctx := context.Background()
+	dbCtx, dbCancel := context.WithTimeout(ctx, db.DBConnectionTimeout)
+	defer dbCancel()
+	conn, err := db.Connect(dbCtx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	q := db.New(conn)
+	_, content, err := transcript.RenderSession(ctx, session.NewService(q), message.NewService(q), args[0], transcript.Options{
+		Format:          format,
+		IncludeThinking: thinking,
+	})
+	if err != nil {
+		return err
+	}
+
+	if output == "" || output == "-" {
+		_, err = fmt.Print(content)
+		return err
+	}
+	if err := os.WriteFile(output, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", output)
+	return nil
+}
+
+func init() {
+	exportCmd.Flags().BoolP("debug", "d", false, "Debug")
+	exportCmd.Flags().StringP("format", "f", transcript.FormatMarkdown, "Transcript format: md, html or json")
+	exportCmd.Flags().StringP("output", "o", "", "Write the transcript to this file instead of stdout")
+	exportCmd.Flags().Bool("thinking", false, "Include the assistant's thinking")
+}
@@ -0,0 +1,462 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/agent"
+	"mix/internal/llm/models"
+	"mix/internal/llm/provider"
+
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up mix for the first time",
+	Long: `Walk through first-run setup: how to authenticate with a provider, the
+default model, the default working directory, whether tools ask for
+permission, and MCP servers to add. The answers are written to .mix.json in
+the current directory (or ~/.mix.json with --global), which is then
+validated and checked by sending a minimal request to the model.
+
+Flags answer the matching questions; with --yes the remaining ones take
+their defaults, so setup can run unattended.`,
+	Example: `
+  mix init
+  mix init --provider openai --auth api-key --model gpt-4.1 --yes
+  mix init --global --mcp filesystem --mcp github
+  `,
+	Args: cobra.NoArgs,
+	RunE: handleInit,
+}
+
+// initProviders are the providers offered by mix init, with the environment
+// variable their API key can come from
+var initProviders = []struct {
+	provider     models.ModelProvider
+	apiKeyEnv    string
+	defaultModel models.ModelID
+	oauth        bool
+}{
+	{models.ProviderAnthropic, "ANTHROPIC_API_KEY", models.Claude4Sonnet, true},
+	{models.ProviderOpenAI, "OPENAI_API_KEY", models.GPT41, true},
+	{models.ProviderGemini, "GEMINI_API_KEY", models.Gemini25, false},
+	{models.ProviderGROQ, "GROQ_API_KEY", models.Llama4Maverick, false},
+	{models.ProviderOpenRouter, "OPENROUTER_API_KEY", models.OpenRouterClaude37Sonnet, false},
+}
+
+const (
+	initAuthOAuth  = "oauth"
+	initAuthAPIKey = "api-key"
+
+	initPermissionsAsk  = "ask"
+	initPermissionsSkip = "skip"
+)
+
+// initMCPTemplates are the MCP servers mix init can add. Read-only tools are
+// auto-approved; the rest still ask for permission.
+var initMCPTemplates = map[string]config.MCPServer{
+	"filesystem": {
+		Type:        config.MCPStdio,
+		Command:     "npx",
+		Args:        []string{"-y", "@modelcontextprotocol/server-filesystem", "."},
+		AutoApprove: []string{"read_*", "list_*", "search_files", "get_file_info"},
+	},
+	"github": {
+		Type:        config.MCPStdio,
+		Command:     "npx",
+		Args:        []string{"-y", "@modelcontextprotocol/server-github"},
+		AutoApprove: []string{"get_*", "list_*", "search_*"},
+	},
+	"fetch": {
+		Type:        config.MCPStdio,
+		Command:     "uvx",
+		Args:        []string{"mcp-server-fetch"},
+		AutoApprove: []string{"fetch"},
+	},
+	"playwright": {
+		Type:    config.MCPStdio,
+		Command: "npx",
+		Args:    []string{"-y", "@playwright/mcp@latest"},
+	},
+}
+
+// initConfig is the part of Config written by mix init. Config itself isn't
+// written, since its zero values would override defaults such as the data
+// directory.
+type initConfig struct {
+	WorkingDir      string                                   `json:"wd,omitempty"`
+	Providers       map[models.ModelProvider]config.Provider `json:"providers,omitempty"`
+	Agents          map[config.AgentName]config.Agent        `json:"agents"`
+	SkipPermissions bool                                     `json:"skipPermissions,omitempty"`
+	MCPServers      map[string]config.MCPServer              `json:"mcpServers,omitempty"`
+}
+
+// wizard asks the setup questions. Questions whose flag is set are not asked;
+// with yes, unanswered questions take their defaults.
+type wizard struct {
+	cmd    *cobra.Command
+	reader *bufio.Reader
+	yes    bool
+}
+
+// ask returns the value of flag if it is set, otherwise the answer to
+// question, or def for an empty answer. A non-empty choices restricts the
+// answer to one of them.
+func (w *wizard) ask(flag, question, def string, choices []string) (string, error) {
+	if f := w.cmd.Flags().Lookup(flag); f != nil && f.Changed {
+		value := f.Value.String()
+		if len(choices) > 0 && !slices.Contains(choices, value) {
+			return "", fmt.Errorf("invalid --%s %q: must be one of %s", flag, value, strings.Join(choices, ", "))
+		}
+		return value, nil
+	}
+	if w.yes {
+		return def, nil
+	}
+
+	for {
+		prompt := question
+		if len(choices) > 0 {
+			prompt += " (" + strings.Join(choices, "/") + ")"
+		}
+		if def != "" {
+			prompt += " [" + def + "]"
+		}
+		fmt.Print(prompt + ": ")
+		line, err := w.reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read answer: %w", err)
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if len(choices) == 0 || slices.Contains(choices, answer) {
+			return answer, nil
+		}
+		fmt.Printf("❌ Please answer one of: %s\n", strings.Join(choices, ", "))
+	}
+}
+
+func handleInit(cmd *cobra.Command, args []string) error {
+	global, _ := cmd.Flags().GetBool("global")
+	force, _ := cmd.Flags().GetBool("force")
+	skipVerify, _ := cmd.Flags().GetBool("skip-verify")
+	debug, _ := cmd.Flags().GetBool("debug")
+	yes, _ := cmd.Flags().GetBool("yes")
+	w := &wizard{cmd: cmd, reader: bufio.NewReader(os.Stdin), yes: yes}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current working directory: %v", err)
+	}
+	configFile := filepath.Join(cwd, ".mix.json")
+	if global {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		configFile = filepath.Join(homeDir, ".mix.json")
+	}
+	previous, err := os.ReadFile(configFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+	if err == nil && !force {
+		if yes {
+			return fmt.Errorf("%s already exists; pass --force to replace it", configFile)
+		}
+		answer, err := w.ask("", configFile+" already exists. Replace it?", "n", []string{"y", "n"})
+		if err != nil {
+			return err
+		}
+		if answer != "y" {
+			fmt.Println("Setup cancelled.")
+			return nil
+		}
+	}
+
+	fmt.Println("🛠  Setting up mix")
+	fmt.Println()
+
+	// Provider and authentication
+	providerNames := make([]string, len(initProviders))
+	for i, p := range initProviders {
+		providerNames[i] = string(p.provider)
+	}
+	answer, err := w.ask("provider", "Provider", string(models.ProviderAnthropic), providerNames)
+	if err != nil {
+		return err
+	}
+	p := initProviders[slices.Index(providerNames, answer)]
+
+	auth := initAuthAPIKey
+	if p.oauth {
+		auth, err = w.ask("auth", "Authenticate with your subscription (oauth) or an API key (api-key)?", initAuthOAuth, []string{initAuthOAuth, initAuthAPIKey})
+		if err != nil {
+			return err
+		}
+	} else if f := cmd.Flags().Lookup("auth"); f.Changed && f.Value.String() != initAuthAPIKey {
+		return fmt.Errorf("%s only supports --auth %s", p.provider, initAuthAPIKey)
+	}
+
+	// OAuth logins and keys from the environment need no provider entry;
+	// validation adds the provider
+	providers := make(map[models.ModelProvider]config.Provider)
+	if auth == initAuthOAuth {
+		noBrowser, _ := cmd.Flags().GetBool("no-browser")
+		if err := ensureOAuth(p.provider, yes, noBrowser); err != nil {
+			return err
+		}
+	} else {
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		switch {
+		case apiKey != "":
+		case os.Getenv(p.apiKeyEnv) != "":
+			fmt.Printf("✅ Using the API key in %s\n", p.apiKeyEnv)
+		case yes:
+			return fmt.Errorf("no API key for %s: pass --api-key or set %s", p.provider, p.apiKeyEnv)
+		default:
+			for apiKey == "" {
+				fmt.Printf("API key (or set %s and run mix init again): ", p.apiKeyEnv)
+				line, err := w.reader.ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("failed to read answer: %w", err)
+				}
+				apiKey = strings.TrimSpace(line)
+			}
+		}
+		if apiKey != "" {
+			providers[p.provider] = config.Provider{APIKey: apiKey}
+		}
+	}
+
+	// Default model
+	var modelIDs []string
+	for id, model := range models.Supported() {
+		if model.Provider == p.provider {
+			modelIDs = append(modelIDs, string(id))
+		}
+	}
+	sort.Strings(modelIDs)
+	if !w.yes && !cmd.Flags().Changed("model") {
+		fmt.Printf("Models: %s\n", strings.Join(modelIDs, ", "))
+	}
+	modelID, err := w.ask("model", "Default model", string(p.defaultModel), modelIDs)
+	if err != nil {
+		return err
+	}
+
+	// Working directory
+	workingDir, err := w.ask("working-dir", "Default working directory for sessions (empty for the directory mix starts in)", "", nil)
+	if err != nil {
+		return err
+	}
+	if workingDir != "" {
+		if workingDir, err = filepath.Abs(workingDir); err != nil {
+			return fmt.Errorf("invalid working directory: %w", err)
+		}
+		if info, err := os.Stat(workingDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("working directory %s does not exist", workingDir)
+		}
+	}
+
+	// Permission posture
+	permissions, err := w.ask("permissions", "Ask before tools modify files or run commands (ask), or never ask (skip)?", initPermissionsAsk, []string{initPermissionsAsk, initPermissionsSkip})
+	if err != nil {
+		return err
+	}
+
+	// MCP servers
+	templateNames := make([]string, 0, len(initMCPTemplates))
+	for name := range initMCPTemplates {
+		templateNames = append(templateNames, name)
+	}
+	sort.Strings(templateNames)
+	mcpNames, _ := cmd.Flags().GetStringSlice("mcp")
+	if !cmd.Flags().Changed("mcp") && !yes {
+		answer, err := w.ask("", fmt.Sprintf("MCP servers to add, comma-separated (%s)", strings.Join(templateNames, ", ")), "", nil)
+		if err != nil {
+			return err
+		}
+		for _, name := range strings.Split(answer, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				mcpNames = append(mcpNames, name)
+			}
+		}
+	}
+	mcpServers := make(map[string]config.MCPServer, len(mcpNames))
+	for _, name := range mcpNames {
+		server, ok := initMCPTemplates[name]
+		if !ok {
+			return fmt.Errorf("unknown MCP server template %q: must be one of %s", name, strings.Join(templateNames, ", "))
+		}
+		mcpServers[name] = server
+	}
+
+	cfg := initConfig{
+		WorkingDir: workingDir,
+		Providers:  providers,
+		Agents: map[config.AgentName]config.Agent{
+			config.AgentMain: {Model: models.ModelID(modelID), MaxTokens: config.MaxTokensFallbackDefault},
+			config.AgentSub:  {Model: models.ModelID(modelID), MaxTokens: config.MaxTokensFallbackDefault / 2},
+		},
+		SkipPermissions: permissions == initPermissionsSkip,
+		MCPServers:      mcpServers,
+	}
+	if err := writeInitConfig(configFile, cfg, previous, cwd, debug); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Printf("✅ Wrote %s\n", configFile)
+
+	if skipVerify {
+		return nil
+	}
+	return verifySetup(mcpServers)
+}
+
+// ensureOAuth signs in to provider unless stored credentials are still
+// valid. Signing in needs a browser and, for Anthropic, a pasted code, so it
+// can't run with --yes.
+func ensureOAuth(providerName models.ModelProvider, yes, noBrowser bool) error {
+	storage, err := provider.NewCredentialStorage()
+	if err != nil {
+		return fmt.Errorf("failed to initialize credential storage: %w", err)
+	}
+	var expiresAt int64
+	switch providerName {
+	case models.ProviderAnthropic:
+		if creds, err := storage.GetOAuthCredentials("anthropic"); err == nil && creds != nil && !creds.IsTokenExpired() {
+			expiresAt = creds.ExpiresAt
+		}
+	case models.ProviderOpenAI:
+		if creds, err := storage.GetOpenAICredentials("openai"); err == nil && creds != nil && !creds.IsTokenExpired() {
+			expiresAt = creds.ExpiresAt
+		}
+	}
+	if expiresAt != 0 {
+		fmt.Printf("✅ Already authenticated with %s (expires in ~%.0f minutes)\n", providerName, float64(expiresAt-time.Now().Unix())/60)
+		return nil
+	}
+	if yes {
+		return fmt.Errorf("not authenticated with %s; run mix auth add %s first or use --auth %s", providerName, providerName, initAuthAPIKey)
+	}
+
+	fmt.Println()
+	if providerName == models.ProviderOpenAI {
+		err = handleOpenAIOAuth(noBrowser)
+	} else {
+		err = handleAnthropicOAuth(noBrowser)
+	}
+	fmt.Println()
+	return err
+}
+
+// writeInitConfig writes cfg to configFile and validates the resulting
+// configuration, restoring the previous file if it is invalid.
+func writeInitConfig(configFile string, cfg initConfig, previous []byte, cwd string, debug bool) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	// The file may hold an API key
+	if err := os.WriteFile(configFile, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configFile, err)
+	}
+
+	restore := func(cause error) error {
+		if previous != nil {
+			os.WriteFile(configFile, previous, 0o600)
+		} else {
+			os.Remove(configFile)
+		}
+		return fmt.Errorf("the new configuration is invalid, %s was not changed: %w", configFile, cause)
+	}
+	if _, err := config.Load(cwd, debug, false); err != nil {
+		return restore(err)
+	}
+	if err := config.Validate(); err != nil {
+		return restore(err)
+	}
+	return nil
+}
+
+// verifySetup sends a minimal request to the configured models and lists the
+// tools of the MCP servers set up.
+func verifySetup(mcpServers map[string]config.MCPServer) error {
+	ctx := context.Background()
+	fmt.Println()
+	fmt.Println("🔄 Checking connectivity...")
+
+	failed := false
+	for _, result := range agent.ProbeProviders(ctx) {
+		if result.OK {
+			fmt.Printf("✅ %s %s responded in %s\n", result.Provider, result.Model, result.Duration.Round(time.Millisecond))
+			continue
+		}
+		failed = true
+		fmt.Printf("❌ %s %s: %s\n", result.Provider, result.Model, result.Error)
+		if result.Hint != "" {
+			fmt.Printf("   %s\n", result.Hint)
+		}
+	}
+
+	if len(mcpServers) > 0 {
+		manager := agent.NewMCPClientManager()
+		defer manager.Close()
+		toolCounts := make(map[string]int)
+		for _, tool := range agent.GetMcpTools(ctx, nil, manager) {
+			if serverName, _, ok := strings.Cut(tool.Info().Name, "_"); ok {
+				toolCounts[serverName]++
+			}
+		}
+		names := make([]string, 0, len(mcpServers))
+		for name := range mcpServers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if toolCounts[name] == 0 {
+				fmt.Printf("⚠️  MCP server %s did not start or has no tools; check that %s is installed\n", name, mcpServers[name].Command)
+				continue
+			}
+			fmt.Printf("✅ MCP server %s: %d tools\n", name, toolCounts[name])
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("setup is saved, but the provider check failed; fix the problem above and run mix init again, or check with /doctor")
+	}
+	fmt.Println()
+	fmt.Println("🎉 mix is ready. Start it with: mix -i")
+	return nil
+}
+
+func init() {
+	initCmd.Flags().BoolP("debug", "d", false, "Debug")
+	initCmd.Flags().Bool("global", false, "Write ~/.mix.json instead of .mix.json in the current directory")
+	initCmd.Flags().Bool("force", false, "Replace an existing config file without asking")
+	initCmd.Flags().BoolP("yes", "y", false, "Use defaults for questions not answered by flags")
+	initCmd.Flags().Bool("skip-verify", false, "Don't check connectivity after writing the config")
+	initCmd.Flags().String("provider", "", "Provider: anthropic, openai, gemini, groq or openrouter")
+	initCmd.Flags().String("auth", "", "Authentication: oauth (anthropic and openai) or api-key")
+	initCmd.Flags().Bool("no-browser", false, "Sign in without opening a browser or listening for a callback locally")
+	initCmd.Flags().String("api-key", "", "API key to store in the config, instead of the provider's environment variable")
+	initCmd.Flags().String("model", "", "Default model for the agents")
+	initCmd.Flags().String("working-dir", "", "Default working directory for sessions")
+	initCmd.Flags().String("permissions", "", "ask or skip permission prompts")
+	initCmd.Flags().StringSlice("mcp", nil, "MCP server template to add: filesystem, github, fetch or playwright (repeatable)")
+}
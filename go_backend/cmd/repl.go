@@ -0,0 +1,559 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mix/internal/app"
+	"mix/internal/commands"
+	"mix/internal/config"
+	"mix/internal/db"
+	"mix/internal/llm/agent"
+	"mix/internal/llm/tools"
+	"mix/internal/logging"
+	"mix/internal/message"
+	"mix/internal/permission"
+	"mix/internal/pubsub"
+	"mix/internal/question"
+
+	"github.com/spf13/cobra"
+)
+
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Chat with the agent in an interactive terminal session",
+	Long: `Keep a session open and chat with the agent from the terminal.
+
+Assistant output and tool activity stream as they happen, slash commands from
+the command registry work as in the app, and permission prompts and the
+agent's questions are answered inline. Ctrl+C cancels the running request; Ctrl+D or /exit quits.
+
+Logs go to repl.log in the data directory so they don't interleave with the
+conversation.`,
+	Example: `
+  # Start a new session in the current directory
+  mix repl
+
+  # Resume an existing session
+  mix repl --session 0b5e6f4e-...
+
+  # Start a session from a template
+  mix repl --template bug-triage
+  `,
+	Args: cobra.NoArgs,
+	RunE: handleREPL,
+}
+
+func handleREPL(cmd *cobra.Command, args []string) error {
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, _ := cmd.Flags().GetString("cwd")
+	sessionID, _ := cmd.Flags().GetString("session")
+	templateName, _ := cmd.Flags().GetString("template")
+	skipPermissions, _ := cmd.Flags().GetBool("dangerously-skip-permissions")
+
+	if cwd == "" {
+		var err error
+		cwd, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current working directory: %v", err)
+		}
+	} else if err := os.Chdir(cwd); err != nil {
+		return fmt.Errorf("failed to change directory: %v", err)
+	}
+
+	if _, err := config.Load(cwd, debug, skipPermissions); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dbCtx, dbCancel := context.WithTimeout(ctx, db.DBConnectionTimeout)
+	defer dbCancel()
+	conn, err := db.Connect(dbCtx)
+	if err != nil {
+		return err
+	}
+
+	mixApp, err := app.New(ctx, conn)
+	if err != nil {
+		logging.Error("Failed to create app", "error", err)
+		return err
+	}
+	defer mixApp.Shutdown()
+
+	initMCPTools(ctx, mixApp)
+
+	if templateName != "" {
+		if sessionID != "" {
+			return fmt.Errorf("--session and --template can't be used together")
+		}
+		sess, err := mixApp.ApplyTemplate(ctx, templateName, "", "")
+		if err != nil {
+			return err
+		}
+		sessionID = sess.ID
+	}
+
+	return runREPL(ctx, mixApp, sessionID, debug)
+}
+
+// runREPL reads prompts from stdin until EOF or /exit, streaming each
+// response to stdout.
+func runREPL(ctx context.Context, mixApp *app.App, sessionID string, debug bool) error {
+	logPath, err := logREPLToFile(debug)
+	if err != nil {
+		return err
+	}
+
+	if sessionID == "" {
+		launchDir, err := config.LaunchDirectory()
+		if err != nil {
+			return fmt.Errorf("failed to get launch directory: %w", err)
+		}
+		sess, err := mixApp.Sessions.Create(ctx, "Interactive session", launchDir)
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		sessionID = sess.ID
+	}
+	if err := mixApp.SetCurrentSession(sessionID); err != nil {
+		return err
+	}
+
+	registry := commands.NewRegistry()
+	if err := registry.LoadCommands(mixApp); err != nil {
+		return fmt.Errorf("failed to load commands: %w", err)
+	}
+
+	r := &repl{
+		app:       mixApp,
+		registry:  registry,
+		out:       os.Stdout,
+		sessionID: sessionID,
+		printed:   make(map[string]int),
+		shown:     make(map[string]bool),
+	}
+
+	// Ctrl+C cancels the running request instead of exiting
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	messageEvents := mixApp.Messages.Subscribe(ctx)
+	permissionEvents := mixApp.Permissions.Subscribe(ctx)
+	questionEvents := mixApp.Questions.Subscribe(ctx)
+	lines := readLines(os.Stdin)
+
+	fmt.Fprintf(r.out, "Mix %s session %s\n", mixApp.CoderAgent.Model().Name, sessionID)
+	fmt.Fprintf(r.out, "Type /help for commands, Ctrl+C to cancel a request, Ctrl+D or /exit to quit. Logs: %s\n", logPath)
+	r.prompt()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-signals:
+			if r.events != nil {
+				fmt.Fprintln(r.out, "\nCancelling...")
+				mixApp.CoderAgent.Cancel(r.sessionID)
+			} else {
+				fmt.Fprintln(r.out, "\n(Ctrl+D or /exit to quit)")
+				r.prompt()
+			}
+
+		case line, ok := <-lines:
+			if !ok {
+				if r.events != nil {
+					mixApp.CoderAgent.Cancel(r.sessionID)
+				}
+				fmt.Fprintln(r.out)
+				return nil
+			}
+			if exit := r.handleLine(ctx, line); exit {
+				return nil
+			}
+
+		case event, ok := <-messageEvents:
+			if !ok {
+				return nil
+			}
+			if event.Payload.SessionID == r.sessionID {
+				r.show(event.Payload)
+			}
+
+		case event, ok := <-permissionEvents:
+			if !ok {
+				return nil
+			}
+			if event.Payload.SessionID != r.sessionID {
+				continue
+			}
+			switch event.Type {
+			case pubsub.CreatedEvent:
+				r.permissions = append(r.permissions, event.Payload)
+				if len(r.permissions) == 1 {
+					r.askPermission()
+				}
+			case pubsub.UpdatedEvent:
+				r.dropPermission(event.Payload)
+			}
+
+		case event, ok := <-questionEvents:
+			if !ok {
+				return nil
+			}
+			if event.Payload.SessionID != r.sessionID {
+				continue
+			}
+			switch event.Type {
+			case pubsub.CreatedEvent:
+				r.questions = append(r.questions, event.Payload)
+				if len(r.questions) == 1 && len(r.permissions) == 0 {
+					r.askQuestion()
+				}
+			case pubsub.UpdatedEvent:
+				r.dropQuestion(event.Payload)
+			}
+
+		case event, ok := <-r.events:
+			if !ok {
+				r.events = nil
+				r.prompt()
+				continue
+			}
+			r.handleEvent(event)
+		}
+	}
+}
+
+// repl holds the state of an interactive session. It is only touched from
+// the runREPL loop.
+type repl struct {
+	app       *app.App
+	registry  *commands.Registry
+	out       io.Writer
+	sessionID string
+
+	// events is the running request, nil when idle
+	events <-chan agent.AgentEvent
+	// permissions are waiting for an answer, the first one is on screen
+	permissions []permission.PermissionRequest
+	// questions are waiting for an answer, the first one is on screen once
+	// no permission is
+	questions []question.Question
+
+	printed map[string]int  // message ID -> bytes of text already printed
+	shown   map[string]bool // tool calls and results already printed
+}
+
+func (r *repl) prompt() {
+	fmt.Fprint(r.out, "\n> ")
+}
+
+// handleLine answers a pending permission or question, runs a slash command
+// or sends a prompt. It returns true when the user asked to quit.
+func (r *repl) handleLine(ctx context.Context, line string) bool {
+	line = strings.TrimSpace(line)
+
+	if len(r.permissions) > 0 {
+		r.answerPermission(line)
+		return false
+	}
+	if len(r.questions) > 0 {
+		r.answerQuestion(line)
+		return false
+	}
+	if r.events != nil {
+		if line != "" {
+			fmt.Fprintln(r.out, "A request is running, press Ctrl+C to cancel it")
+		}
+		return false
+	}
+
+	switch {
+	case line == "":
+		r.prompt()
+	case line == "/exit" || line == "/quit":
+		return true
+	case commands.IsSlashCommand(line):
+		r.runCommand(ctx, line)
+	default:
+		r.send(ctx, line)
+	}
+	return false
+}
+
+func (r *repl) send(ctx context.Context, text string) {
+	events, err := r.app.CoderAgent.RunWithState(ctx, tools.RequestState{SessionID: r.sessionID}, text)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		r.prompt()
+		return
+	}
+	r.events = events
+}
+
+// runCommand executes a slash command. File commands expand to a prompt for
+// the agent; built-in commands print their result.
+func (r *repl) runCommand(ctx context.Context, line string) {
+	parsed, err := commands.ParseCommand(line)
+	if err != nil {
+		fmt.Fprintf(r.out, "Invalid slash command: %v\n", err)
+		r.prompt()
+		return
+	}
+	result, err := r.registry.ExecuteCommand(ctx, parsed.Name, parsed.Arguments)
+	if err != nil {
+		fmt.Fprintf(r.out, "%v\n", err)
+		r.prompt()
+		return
+	}
+
+	if cmd, _ := r.registry.GetCommand(parsed.Name); isFileCommand(cmd) {
+		r.send(ctx, result)
+		return
+	}
+
+	fmt.Fprintln(r.out, formatCommandResult(result))
+	// Commands like /clear switch the current session
+	if current := r.app.GetCurrentSessionID(); current != "" {
+		r.sessionID = current
+	}
+	r.prompt()
+}
+
+func isFileCommand(cmd commands.Command) bool {
+	_, ok := cmd.(*commands.FileCommand)
+	return ok
+}
+
+// formatCommandResult unwraps the message and error responses built-in
+// commands return and indents anything else.
+func formatCommandResult(result string) string {
+	var response struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return result
+	}
+	switch response.Type {
+	case "message":
+		return response.Message
+	case "error":
+		return "Error: " + response.Error
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(result), "", "  "); err != nil {
+		return result
+	}
+	return indented.String()
+}
+
+// show prints what is new in a message: streamed text, tool calls once
+// their input is complete, and tool results.
+func (r *repl) show(msg message.Message) {
+	switch msg.Role {
+	case message.Assistant:
+		text := msg.Content().Text
+		if printed := r.printed[msg.ID]; len(text) > printed {
+			fmt.Fprint(r.out, text[printed:])
+			r.printed[msg.ID] = len(text)
+		}
+		for _, call := range msg.ToolCalls() {
+			if call.Finished && !r.shown[call.ID] {
+				r.shown[call.ID] = true
+				fmt.Fprintf(r.out, "\n* %s(%s)\n", call.Name, truncateLine(call.Input, 100))
+			}
+		}
+	case message.Tool:
+		for _, result := range msg.ToolResults() {
+			key := "result:" + result.ToolCallID
+			if r.shown[key] {
+				continue
+			}
+			r.shown[key] = true
+			if result.IsError {
+				fmt.Fprintf(r.out, "  ! %s\n", truncateLine(result.Content, 200))
+			} else {
+				fmt.Fprintf(r.out, "  = %s\n", truncateLine(result.Content, 100))
+			}
+		}
+	}
+}
+
+func (r *repl) handleEvent(event agent.AgentEvent) {
+	switch event.Type {
+	case agent.AgentEventTypeError:
+		if errors.Is(event.Error, agent.ErrRequestCancelled) || errors.Is(event.Error, context.Canceled) {
+			fmt.Fprintln(r.out, "\nCancelled")
+		} else {
+			fmt.Fprintf(r.out, "\nError: %v\n", event.Error)
+		}
+	case agent.AgentEventTypeResponse:
+		// Message updates can be dropped by a busy broker, so catch up here
+		r.show(event.Message)
+		if event.Done && event.Message.FinishReason() == message.FinishReasonPermissionDenied {
+			fmt.Fprintln(r.out, "\nPermission denied")
+		}
+	}
+}
+
+func (r *repl) askPermission() {
+	p := r.permissions[0]
+	fmt.Fprintf(r.out, "\nAllow %s to %s %s?\n  %s\n[y]es, [a]lways for this session, [n]o: ", p.ToolName, p.Action, p.Path, p.Description)
+}
+
+func (r *repl) answerPermission(answer string) {
+	p := r.permissions[0]
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		r.app.Permissions.Grant(p)
+	case "a", "always":
+		r.app.Permissions.GrantPersistant(p)
+	case "n", "no":
+		r.app.Permissions.Deny(p)
+	default:
+		fmt.Fprint(r.out, "Answer y, a or n: ")
+		return
+	}
+	r.permissions = r.permissions[1:]
+	r.askNext()
+}
+
+// askNext shows the next pending permission, or else the next question.
+func (r *repl) askNext() {
+	if len(r.permissions) > 0 {
+		r.askPermission()
+	} else if len(r.questions) > 0 {
+		r.askQuestion()
+	}
+}
+
+// dropPermission removes a request that was resolved without an answer here,
+// e.g. because it timed out, moving on to the next one if it was on screen.
+func (r *repl) dropPermission(p permission.PermissionRequest) {
+	for i, queued := range r.permissions {
+		if queued.ID != p.ID {
+			continue
+		}
+		r.permissions = append(r.permissions[:i], r.permissions[i+1:]...)
+		if i == 0 {
+			fmt.Fprintf(r.out, "\nPermission request %s\n", strings.ReplaceAll(string(p.Resolution), "_", " "))
+			r.askNext()
+		}
+		return
+	}
+}
+
+func (r *repl) askQuestion() {
+	q := r.questions[0]
+	fmt.Fprintf(r.out, "\n? %s\n", q.Question)
+	for i, option := range q.Options {
+		fmt.Fprintf(r.out, "  %d. %s\n", i+1, option)
+	}
+	switch {
+	case len(q.Options) == 0:
+		fmt.Fprint(r.out, "Answer: ")
+	case q.AllowFreeText:
+		fmt.Fprint(r.out, "Answer with a number or your own text: ")
+	default:
+		fmt.Fprint(r.out, "Answer with a number: ")
+	}
+}
+
+// answerQuestion answers the question on screen. A number picks that option.
+func (r *repl) answerQuestion(answer string) {
+	q := r.questions[0]
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(q.Options) {
+		answer = q.Options[n-1]
+	}
+	if _, err := r.app.Questions.Answer(q.ID, answer); errors.Is(err, question.ErrInvalidAnswer) {
+		fmt.Fprintf(r.out, "%v\nAnswer: ", err)
+		return
+	}
+	r.questions = r.questions[1:]
+	r.askNext()
+}
+
+// dropQuestion removes a question that was resolved without an answer here,
+// e.g. because it timed out, moving on to the next one if it was on screen.
+func (r *repl) dropQuestion(q question.Question) {
+	for i, queued := range r.questions {
+		if queued.ID != q.ID {
+			continue
+		}
+		r.questions = append(r.questions[:i], r.questions[i+1:]...)
+		if i == 0 && len(r.permissions) == 0 {
+			fmt.Fprintf(r.out, "\nQuestion %s\n", strings.ReplaceAll(string(q.Resolution), "_", " "))
+			r.askNext()
+		}
+		return
+	}
+}
+
+// readLines feeds stdin to a channel so the REPL loop can select on it
+// alongside agent events. The channel is closed on EOF.
+func readLines(in io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+// logREPLToFile points the default logger at repl.log in the data directory,
+// since logs otherwise go to stdout.
+func logREPLToFile(debug bool) (string, error) {
+	dir := config.Get().Data.Directory
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	path := filepath.Join(dir, "repl.log")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open log file: %w", err)
+	}
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: level})))
+	return path, nil
+}
+
+func truncateLine(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func init() {
+	replCmd.Flags().BoolP("debug", "d", false, "Debug")
+	replCmd.Flags().StringP("cwd", "c", "", "Current working directory")
+	replCmd.Flags().StringP("session", "s", "", "Resume this session instead of starting a new one")
+	replCmd.Flags().String("template", "", "Start the session from this template")
+	replCmd.Flags().Bool("dangerously-skip-permissions", false, "Skip all permission prompts (DANGEROUS - use only in trusted environments)")
+}
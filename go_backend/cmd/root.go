@@ -8,8 +8,12 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"mix/internal/api"
@@ -19,7 +23,13 @@ import (
 	"mix/internal/format"
 	httphandlers "mix/internal/http"
 	"mix/internal/llm/agent"
+	"mix/internal/llm/provider"
+	"mix/internal/llm/responseformat"
 	"mix/internal/logging"
+	"mix/internal/metrics"
+	"mix/internal/render"
+	"mix/internal/session"
+	"mix/internal/share"
 	"mix/internal/version"
 
 	"github.com/spf13/cobra"
@@ -36,6 +46,12 @@ and content creation workflows.`,
   # CLI mode with prompt (direct output)
   mix -p "Explain the use of context in Go"
 
+  # Interactive session in the terminal
+  mix -i
+
+  # CLI mode answering repeated identical runs from the response cache
+  mix -p "Explain the use of context in Go" --cache --cache-ttl 24h
+
   # CLI mode with JSON output format
   mix -p "Explain the use of context in Go" -f json
 
@@ -65,10 +81,23 @@ and content creation workflows.`,
 		prompt, _ := cmd.Flags().GetString("prompt")
 		outputFormat, _ := cmd.Flags().GetString("output-format")
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		interactive, _ := cmd.Flags().GetBool("interactive")
 		query, _ := cmd.Flags().GetString("query")
 		httpPort, _ := cmd.Flags().GetInt("http-port")
 		httpHost, _ := cmd.Flags().GetString("http-host")
+		httpReusePort, _ := cmd.Flags().GetBool("http-reuse-port")
+		httpDrainTimeout, _ := cmd.Flags().GetDuration("http-drain-timeout")
+		httpRequireStreamToken, _ := cmd.Flags().GetBool("http-require-stream-token")
+		httpMaxConcurrentRuns, _ := cmd.Flags().GetInt("http-max-concurrent-runs")
+		httpMaxQueuedRuns, _ := cmd.Flags().GetInt("http-max-queued-runs")
+		httpRateLimit, _ := cmd.Flags().GetFloat64("http-rate-limit")
+		httpRateBurst, _ := cmd.Flags().GetInt("http-rate-burst")
+		httpMaxBodyBytes, _ := cmd.Flags().GetInt64("http-max-body-bytes")
+		httpAuthBypassLocalhost, _ := cmd.Flags().GetBool("http-auth-bypass-localhost")
 		skipPermissions, _ := cmd.Flags().GetBool("dangerously-skip-permissions")
+		responseCache, _ := cmd.Flags().GetBool("cache")
+		responseCacheTTL, _ := cmd.Flags().GetDuration("cache-ttl")
+		responseFormatFlag, _ := cmd.Flags().GetString("response-format")
 
 		// Validate format option
 		if !format.IsValid(outputFormat) {
@@ -97,6 +126,22 @@ and content creation workflows.`,
 			return err
 		}
 
+		if (responseCache || cmd.Flag("cache-ttl").Changed) && prompt == "" {
+			return fmt.Errorf("--cache and --cache-ttl only apply to --prompt runs")
+		}
+		if responseFormatFlag != "" && prompt == "" {
+			return fmt.Errorf("--response-format only applies to --prompt runs")
+		}
+		responseFormat, err := loadResponseFormat(responseFormatFlag)
+		if err != nil {
+			return err
+		}
+		if responseCache {
+			if err := provider.EnableResponseCache(filepath.Join(config.Get().Data.Directory, "response-cache"), responseCacheTTL); err != nil {
+				return err
+			}
+		}
+
 		// Create main context for the application
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -121,7 +166,19 @@ and content creation workflows.`,
 
 		// HTTP server mode (blocks, no other modes)
 		if httpPort > 0 {
-			return startHTTPServer(ctx, app, httpHost, httpPort)
+			httphandlers.RequireStreamTokens(httpRequireStreamToken)
+			app.RunQueue.Configure(httpMaxConcurrentRuns, httpMaxQueuedRuns)
+			httphandlers.ConfigureLimits(httphandlers.LimitConfig{
+				RequestsPerSecond: httpRateLimit,
+				Burst:             httpRateBurst,
+				MaxBodyBytes:      httpMaxBodyBytes,
+			})
+			if err := httphandlers.ConfigureAuth(config.Get().HTTPAuth, httpAuthBypassLocalhost); err != nil {
+				return err
+			}
+			httphandlers.ReloadAuthOnChange(ctx, app)
+			app.WatchConfig(ctx)
+			return startHTTPServer(ctx, app, httpHost, httpPort, httpReusePort, httpDrainTimeout)
 		}
 
 		// Query mode (structured data output)
@@ -131,15 +188,46 @@ and content creation workflows.`,
 
 		// CLI-only mode (when prompt provided)
 		if prompt != "" {
-			return app.RunNonInteractive(ctx, prompt, outputFormat, quiet)
+			return app.RunNonInteractive(ctx, prompt, outputFormat, quiet, responseFormat)
+		}
+
+		// Interactive terminal mode
+		if interactive {
+			return runREPL(ctx, app, "", debug)
 		}
 
 		// Default: Show help when no mode is specified
 		cmd.Help()
-		return fmt.Errorf("no mode specified - use --prompt for CLI mode or --http-port for server mode")
+		return fmt.Errorf("no mode specified - use --prompt for CLI mode, --interactive for a terminal session or --http-port for server mode")
 	},
 }
 
+// loadResponseFormat reads --response-format: "json" for any JSON object,
+// else the path of a JSON Schema file the response must match, named after
+// the file.
+func loadResponseFormat(value string) (*responseformat.Format, error) {
+	if value == "" {
+		return nil, nil
+	}
+	f := &responseformat.Format{Type: responseformat.TypeJSONObject}
+	if value != "json" {
+		schema, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --response-format schema: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(value), filepath.Ext(value))
+		name = regexp.MustCompile(`[^a-zA-Z0-9_-]+`).ReplaceAllString(name, "_")
+		if len(name) > 64 {
+			name = name[:64]
+		}
+		f = &responseformat.Format{Type: responseformat.TypeJSONSchema, Name: name, Schema: schema}
+	}
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid --response-format: %w", err)
+	}
+	return f, nil
+}
+
 func initMCPTools(ctx context.Context, app *app.App) {
 	go func() {
 		defer logging.RecoverPanic("MCP-goroutine", nil)
@@ -225,11 +313,8 @@ Available methods: sessions.list, sessions.create, sessions.select, sessions.del
 		if err := json.Unmarshal([]byte(line), &request); err != nil {
 			// Output error response
 			errorResponse := &api.QueryResponse{
-				Error: &api.QueryError{
-					Code:    -32700,
-					Message: "Parse error: " + err.Error(),
-				},
-				ID: nil,
+				Error: api.NewQueryError(api.CodeParseError, "Parse error: "+err.Error()),
+				ID:    nil,
 			}
 			outputJSONRPCResponse(errorResponse, outputFormat)
 			continue
@@ -252,11 +337,8 @@ func outputJSONRPCResponse(response *api.QueryResponse, outputFormat string) {
 	if err != nil {
 		// Fallback error response
 		fallbackResponse := &api.QueryResponse{
-			Error: &api.QueryError{
-				Code:    -32603,
-				Message: "Internal error: " + err.Error(),
-			},
-			ID: response.ID,
+			Error: api.NewQueryError(api.CodeInternalError, "Internal error: "+err.Error()),
+			ID:    response.ID,
 		}
 		jsonBytes, _ = json.Marshal(fallbackResponse)
 	}
@@ -266,7 +348,14 @@ func outputJSONRPCResponse(response *api.QueryResponse, outputFormat string) {
 
 // SSE handler functions moved to internal/http/sse.go
 
-func startHTTPServer(ctx context.Context, app *app.App, host string, port int) error {
+// handoverGrace is how long an old process may take to stop its agents after
+// draining
+const handoverGrace = 15 * time.Second
+
+func startHTTPServer(ctx context.Context, app *app.App, host string, port int, reusePort bool, drainTimeout time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	handler := api.NewQueryHandler(app)
 
 	// Create dedicated HTTP mux
@@ -279,26 +368,26 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 	})
 
 	// Add SSE streaming endpoint
-	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/stream", httphandlers.LimitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		httphandlers.HandleSSEStream(ctx, handler, w, r)
-	})
+	})))
 
 	// Add message queue endpoint for persistent SSE
-	mux.HandleFunc("/stream/", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/stream/", httphandlers.LimitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Handle stream endpoints
 		if strings.HasSuffix(r.URL.Path, "/message") {
-			httphandlers.HandleMessageQueue(w, r)
+			httphandlers.HandleMessageQueue(handler, w, r)
 		} else {
 			http.NotFound(w, r)
 		}
-	})
+	})))
 
 	// Add video export endpoint
-	mux.HandleFunc("/api/video/export", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/api/video/export", httphandlers.RequireScope(config.HTTPScopeFull, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		// Handle preflight OPTIONS request
 		if r.Method == "OPTIONS" {
@@ -307,14 +396,22 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 		}
 
 		httphandlers.HandleVideoExport(ctx, handler, w, r)
-	})
+	})))
+
+	// Add OpenAI-compatible endpoints for existing SDKs and chat UIs
+	mux.Handle("/v1/chat/completions", httphandlers.LimitRequests(httphandlers.RequireScope(config.HTTPScopeFull, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httphandlers.HandleChatCompletions(ctx, handler, w, r)
+	}))))
+	mux.Handle("/v1/models", httphandlers.RequireScope(config.HTTPScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httphandlers.HandleModels(handler, w, r)
+	})))
 
 	// Add file types endpoint
-	mux.HandleFunc("/api/file-types", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/api/file-types", httphandlers.RequireScope(config.HTTPScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		// Handle preflight OPTIONS request
 		if r.Method == "OPTIONS" {
@@ -342,21 +439,29 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 
 		w.WriteHeader(http.StatusOK)
 		w.Write(jsonBytes)
-	})
+	})))
+
+	// Add asset serving endpoints for media files: each session's under
+	// /assets/{sessionID}/, and the current session's under /input/ and /output/
+	mux.Handle(session.AssetURLPrefix, httphandlers.RequireScope(config.HTTPScopeRead, http.HandlerFunc(app.AssetServer.ServeSession)))
+	mux.Handle("/input/", httphandlers.RequireScope(config.HTTPScopeRead, app.AssetServer))
+	mux.Handle("/output/", httphandlers.RequireScope(config.HTTPScopeRead, app.AssetServer))
+	if app.Renders != nil {
+		mux.Handle(render.URLPrefix, httphandlers.RequireScope(config.HTTPScopeRead, app.Renders))
+	}
 
-	// Add asset serving endpoints for media files
-	mux.HandleFunc("/input/", func(w http.ResponseWriter, r *http.Request) {
-		app.AssetServer.ServeHTTP(w, r)
-	})
-	mux.HandleFunc("/output/", func(w http.ResponseWriter, r *http.Request) {
-		app.AssetServer.ServeHTTP(w, r)
-	})
+	// Add shared transcript endpoint; the link's token is its credential
+	mux.Handle(share.URLPrefix, httphandlers.LimitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httphandlers.HandleShared(handler, w, r)
+	})))
+
+	mux.Handle("/metrics", httphandlers.RequireScope(config.HTTPScopeRead, metrics.Handler()))
 
-	mux.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/rpc", httphandlers.LimitRPC(httphandlers.AuthenticateRPC(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.Header().Set("Content-Type", "application/json")
 
 		// Handle preflight OPTIONS request
@@ -375,10 +480,7 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			errorResponse := &api.QueryResponse{
-				Error: &api.QueryError{
-					Code:    -32700,
-					Message: "Parse error: " + err.Error(),
-				},
+				Error: api.NewQueryError(api.CodeParseError, "Parse error: "+err.Error()),
 			}
 			json.NewEncoder(w).Encode(errorResponse)
 			return
@@ -388,10 +490,7 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 		var request api.QueryRequest
 		if err := json.Unmarshal(body, &request); err != nil {
 			errorResponse := &api.QueryResponse{
-				Error: &api.QueryError{
-					Code:    -32700,
-					Message: "Parse error: " + err.Error(),
-				},
+				Error: api.NewQueryError(api.CodeParseError, "Parse error: "+err.Error()),
 			}
 			json.NewEncoder(w).Encode(errorResponse)
 			return
@@ -402,7 +501,9 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 		logging.Debug("HTTP Request Body: %s\n", string(body))
 
 		// Handle the request
-		response := handler.Handle(ctx, &request)
+		start := time.Now()
+		response := handler.Handle(httphandlers.WithClient(ctx, r), &request)
+		observeRPC(request.Method, response, start)
 
 		// Log the response
 		if responseJSON, err := json.Marshal(response); err == nil {
@@ -413,9 +514,18 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 
 		// Send response
 		json.NewEncoder(w).Encode(response)
-	})
+	}))))
 
 	addr := host + ":" + strconv.Itoa(port)
+	listener, activated, err := httphandlers.Listen(addr, reusePort)
+	if err != nil {
+		return fmt.Errorf("HTTP server failed to listen: %v", err)
+	}
+	if activated {
+		addr = listener.Addr().String()
+		logging.Info("Using socket passed by systemd, ignoring --http-host/--http-port")
+	}
+
 	server := &http.Server{
 		Addr:         addr,
 		Handler:      mux,
@@ -425,26 +535,78 @@ func startHTTPServer(ctx context.Context, app *app.App, host string, port int) e
 	}
 
 	// Immediate feedback to user
-	logging.Info("Starting HTTP JSON-RPC server", "address", addr)
+	logging.Info("Starting HTTP JSON-RPC server", "address", addr, "reusePort", reusePort)
+
+	// Drain on SIGINT/SIGTERM: stop accepting, let in-flight requests and
+	// streams finish, then cancel whatever is left after drainTimeout
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		select {
+		case sig := <-signals:
+			logging.Info("Draining HTTP server", "signal", sig.String(), "timeout", drainTimeout)
+		case <-ctx.Done():
+			logging.Info("Shutting down HTTP server")
+		}
 
-	// Handle graceful shutdown
+		httphandlers.BeginDrain()
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer drainCancel()
+		if err := server.Shutdown(drainCtx); err != nil {
+			logging.Warn("Drain timeout reached, closing remaining connections", "error", err)
+			cancel()
+			server.Close()
+		}
+	}()
+
+	// Recover the responses a previous process left unfinished. In a
+	// SO_REUSEPORT handover the old process is still finishing its own, so
+	// wait until it has drained and its agents have shut down.
+	startedAt := time.Now()
 	go func() {
-		<-ctx.Done()
-		logging.Info("Shutting down HTTP server")
-		server.Shutdown(context.Background())
+		if reusePort {
+			select {
+			case <-time.After(drainTimeout + handoverGrace):
+			case <-ctx.Done():
+				return
+			}
+		}
+		httphandlers.RecoverInterrupted(ctx, handler, startedAt, config.Get().ResumeInterrupted)
 	}()
 
+	// Scheduled jobs only run in the server
+	httphandlers.RunJobs(ctx, handler)
+
 	// Start server and provide ready confirmation
 	logging.Info("Press Ctrl+C to stop")
 
 	// Start server and block (this will block until server shuts down)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("HTTP server failed: %v", err)
 	}
+	<-drained
 
 	return nil
 }
 
+// observeRPC records a JSON-RPC request in the metrics. Unknown methods share
+// one label so clients can't create unbounded series.
+func observeRPC(method string, response *api.QueryResponse, start time.Time) {
+	status := "ok"
+	if response.Error != nil {
+		status = "error"
+		if response.Error.Code == -32601 {
+			method = "unknown"
+		}
+	}
+	metrics.RPCRequests.Inc(method, status)
+	metrics.RPCDuration.Observe(time.Since(start).Seconds(), method)
+}
+
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
@@ -463,6 +625,10 @@ func init() {
 	rootCmd.Flags().StringP("output-format", "f", format.Text.String(),
 		"Output format for CLI-only mode (text, json)")
 	rootCmd.Flags().BoolP("quiet", "q", false, "Hide spinner in CLI-only mode")
+	rootCmd.Flags().String("response-format", "", "Have the --prompt response be JSON: json for any JSON object, or the path of a JSON Schema file it must match; prints the JSON alone")
+	rootCmd.Flags().Bool("cache", false, "Answer repeated identical model requests of --prompt runs from a response cache in the data directory")
+	rootCmd.Flags().Duration("cache-ttl", 0, "How long --cache reuses a response (0 = forever)")
+	rootCmd.Flags().BoolP("interactive", "i", false, "Chat in an interactive terminal session (same as mix repl)")
 
 	// Data query flags
 	rootCmd.Flags().String("query", "", "Query structured data: sessions, tools, mcp, commands")
@@ -470,6 +636,15 @@ func init() {
 	// HTTP server flags
 	rootCmd.Flags().Int("http-port", 0, "Start HTTP JSON-RPC server on this port (0 = disabled)")
 	rootCmd.Flags().String("http-host", "localhost", "HTTP server host")
+	rootCmd.Flags().Bool("http-reuse-port", false, "Bind with SO_REUSEPORT so a new binary can take over the port during upgrades")
+	rootCmd.Flags().Bool("http-require-stream-token", false, "Require a stream.token token, bound to the session, for /stream connections and message posts")
+	rootCmd.Flags().Duration("http-drain-timeout", 2*time.Minute, "How long to let in-flight requests finish on SIGTERM before closing them")
+	rootCmd.Flags().Int("http-max-concurrent-runs", 0, "Maximum agent runs in progress across all clients; more wait in a queue shared fairly between clients (0 = unlimited)")
+	rootCmd.Flags().Int("http-max-queued-runs", 100, "Maximum agent runs waiting for --http-max-concurrent-runs before requests are rejected (0 = unlimited)")
+	rootCmd.Flags().Float64("http-rate-limit", 0, "Requests per second allowed per client IP on /rpc and /stream (0 = unlimited)")
+	rootCmd.Flags().Int("http-rate-burst", 20, "Requests a client IP may make in a burst above --http-rate-limit")
+	rootCmd.Flags().Int64("http-max-body-bytes", httphandlers.DefaultMaxBodyBytes, "Maximum request body size for /rpc and /stream (0 = unlimited)")
+	rootCmd.Flags().Bool("http-auth-bypass-localhost", false, "Let requests from localhost skip httpAuth tokens (for development)")
 
 	// Permission flags
 	rootCmd.Flags().Bool("dangerously-skip-permissions", false, "Skip all permission prompts (DANGEROUS - use only in trusted environments)")
@@ -481,4 +656,11 @@ func init() {
 
 	// Add subcommands
 	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(batchCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(replCmd)
+	rootCmd.AddCommand(templatesCmd)
 }
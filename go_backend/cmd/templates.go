@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"mix/internal/app"
+	"mix/internal/config"
+	"mix/internal/db"
+	"mix/internal/sessiontemplate"
+
+	"github.com/spf13/cobra"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage session templates",
+	Long: `Manage templates for starting standardized sessions. A template captures a
+system prompt addendum, the tools to enable, a model, and a working directory
+with subdirectories to create. Templates are stored in the session database
+and are also available through the templates.* JSON-RPC methods.`,
+}
+
+var templatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List session templates",
+	Args:  cobra.NoArgs,
+	RunE:  handleTemplatesList,
+}
+
+var templatesCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a session template",
+	Example: `
+  mix templates create bug-triage \
+    --description "Reproduce and triage a bug report" \
+    --system-prompt "Reproduce the bug before proposing a fix." \
+    --tools bash,view,grep,glob \
+    --dir repro --dir notes
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: handleTemplatesCreate,
+}
+
+var templatesDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a session template",
+	Args:  cobra.ExactArgs(1),
+	RunE:  handleTemplatesDelete,
+}
+
+var templatesApplyCmd = &cobra.Command{
+	Use:   "apply <name>",
+	Short: "Start a session from a template and print its ID",
+	Long: `Start a session from a template and print its ID, to continue with
+mix repl --session. mix repl --template starts the session and opens it in one
+step.`,
+	Args: cobra.ExactArgs(1),
+	RunE: handleTemplatesApply,
+}
+
+// openApp loads the config for the current directory and creates the app,
+// as the server does. The returned function shuts it down.
+func openApp(cmd *cobra.Command) (*app.App, func(), error) {
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get current working directory: %v", err)
+	}
+	if _, err := config.Load(cwd, debug, false); err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	dbCtx, dbCancel := context.WithTimeout(ctx, db.DBConnectionTimeout)
+	defer dbCancel()
+	conn, err := db.Connect(dbCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	mixApp, err := app.New(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mixApp, mixApp.Shutdown, nil
+}
+
+func handleTemplatesList(cmd *cobra.Command, args []string) error {
+	mixApp, shutdown, err := openApp(cmd)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	templates, err := mixApp.Templates.List(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		fmt.Println("No templates. Create one with mix templates create.")
+		return nil
+	}
+	for _, template := range templates {
+		fmt.Printf("%s", template.Name)
+		if template.Description != "" {
+			fmt.Printf(" - %s", template.Description)
+		}
+		fmt.Println()
+		if template.Model != "" {
+			fmt.Printf("  model: %s\n", template.Model)
+		}
+		if len(template.EnabledTools) > 0 {
+			fmt.Printf("  tools: %s\n", strings.Join(template.EnabledTools, ", "))
+		}
+		if template.WorkingDirectory != "" {
+			fmt.Printf("  working directory: %s\n", template.WorkingDirectory)
+		}
+		if len(template.Directories) > 0 {
+			fmt.Printf("  directories: %s\n", strings.Join(template.Directories, ", "))
+		}
+	}
+	return nil
+}
+
+func handleTemplatesCreate(cmd *cobra.Command, args []string) error {
+	description, _ := cmd.Flags().GetString("description")
+	systemPrompt, _ := cmd.Flags().GetString("system-prompt")
+	systemPromptFile, _ := cmd.Flags().GetString("system-prompt-file")
+	tools, _ := cmd.Flags().GetStringSlice("tools")
+	model, _ := cmd.Flags().GetString("model")
+	workingDir, _ := cmd.Flags().GetString("working-dir")
+	dirs, _ := cmd.Flags().GetStringArray("dir")
+
+	if systemPromptFile != "" {
+		if systemPrompt != "" {
+			return fmt.Errorf("--system-prompt and --system-prompt-file can't be used together")
+		}
+		data, err := os.ReadFile(systemPromptFile)
+		if err != nil {
+			return fmt.Errorf("failed to read system prompt: %w", err)
+		}
+		systemPrompt = string(data)
+	}
+
+	mixApp, shutdown, err := openApp(cmd)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	template, err := mixApp.CreateTemplate(context.Background(), sessiontemplate.Template{
+		Name:             args[0],
+		Description:      description,
+		SystemPrompt:     systemPrompt,
+		EnabledTools:     tools,
+		Model:            model,
+		WorkingDirectory: workingDir,
+		Directories:      dirs,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Created template %s\n", template.Name)
+	return nil
+}
+
+func handleTemplatesDelete(cmd *cobra.Command, args []string) error {
+	mixApp, shutdown, err := openApp(cmd)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	if err := mixApp.Templates.Delete(context.Background(), args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted template %s\n", args[0])
+	return nil
+}
+
+func handleTemplatesApply(cmd *cobra.Command, args []string) error {
+	title, _ := cmd.Flags().GetString("title")
+	workingDir, _ := cmd.Flags().GetString("working-dir")
+
+	mixApp, shutdown, err := openApp(cmd)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	sess, err := mixApp.ApplyTemplate(context.Background(), args[0], title, workingDir)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sess.ID)
+	return nil
+}
+
+func init() {
+	templatesCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+
+	templatesCreateCmd.Flags().String("description", "", "What the template is for")
+	templatesCreateCmd.Flags().String("system-prompt", "", "Text added to the agent's system prompt")
+	templatesCreateCmd.Flags().String("system-prompt-file", "", "Read the system prompt addendum from this file")
+	templatesCreateCmd.Flags().StringSlice("tools", nil, "The only tools to enable (default all)")
+	templatesCreateCmd.Flags().String("model", "", "Model for sessions instead of the agent's configured one")
+	templatesCreateCmd.Flags().String("working-dir", "", "Working directory for sessions (default the directory mix starts in)")
+	templatesCreateCmd.Flags().StringArray("dir", nil, "Directory to create in the working directory (repeatable)")
+
+	templatesApplyCmd.Flags().String("title", "", "Session title (default the template name)")
+	templatesApplyCmd.Flags().String("working-dir", "", "Working directory instead of the template's")
+
+	templatesCmd.AddCommand(templatesListCmd)
+	templatesCmd.AddCommand(templatesCreateCmd)
+	templatesCmd.AddCommand(templatesDeleteCmd)
+	templatesCmd.AddCommand(templatesApplyCmd)
+}
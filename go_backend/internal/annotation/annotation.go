@@ -0,0 +1,159 @@
+// Package annotation stores feedback on messages, such as a thumbs up or down
+// on an agent response with a note and tags, for QA and for collecting
+// preference data.
+package annotation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"mix/internal/db"
+)
+
+var ErrNotFound = errors.New("annotation not found")
+
+type Rating string
+
+const (
+	RatingNone Rating = ""
+	RatingUp   Rating = "up"
+	RatingDown Rating = "down"
+)
+
+// MaxTags bounds the tags of one annotation
+const MaxTags = 32
+
+// Annotation is the feedback on one message. Annotating a message again
+// replaces its annotation.
+type Annotation struct {
+	MessageID string
+	SessionID string
+	Rating    Rating
+	Note      string
+	Tags      []string
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+// Empty reports whether the annotation carries no feedback.
+func (a Annotation) Empty() bool {
+	return a.Rating == RatingNone && a.Note == "" && len(a.Tags) == 0
+}
+
+type Service interface {
+	// Set stores the annotation of a message, or removes it when the
+	// annotation is empty
+	Set(ctx context.Context, annotation Annotation) (Annotation, error)
+	Get(ctx context.Context, messageID string) (Annotation, error)
+	// ListBySession returns the annotations of a session's messages, oldest
+	// first
+	ListBySession(ctx context.Context, sessionID string) ([]Annotation, error)
+}
+
+type service struct {
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q}
+}
+
+func (s *service) Set(ctx context.Context, annotation Annotation) (Annotation, error) {
+	if err := validate(&annotation); err != nil {
+		return Annotation{}, err
+	}
+	if annotation.Empty() {
+		if err := s.q.DeleteMessageAnnotation(ctx, annotation.MessageID); err != nil {
+			return Annotation{}, err
+		}
+		return annotation, nil
+	}
+
+	tags, err := json.Marshal(annotation.Tags)
+	if err != nil {
+		return Annotation{}, err
+	}
+	row, err := s.q.UpsertMessageAnnotation(ctx, db.UpsertMessageAnnotationParams{
+		MessageID: annotation.MessageID,
+		SessionID: annotation.SessionID,
+		Rating:    string(annotation.Rating),
+		Note:      annotation.Note,
+		Tags:      string(tags),
+	})
+	if err != nil {
+		return Annotation{}, err
+	}
+	return fromRow(row)
+}
+
+func (s *service) Get(ctx context.Context, messageID string) (Annotation, error) {
+	row, err := s.q.GetMessageAnnotation(ctx, messageID)
+	if err == sql.ErrNoRows {
+		return Annotation{}, fmt.Errorf("%w: %s", ErrNotFound, messageID)
+	}
+	if err != nil {
+		return Annotation{}, err
+	}
+	return fromRow(row)
+}
+
+func (s *service) ListBySession(ctx context.Context, sessionID string) ([]Annotation, error) {
+	rows, err := s.q.ListMessageAnnotationsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	annotations := make([]Annotation, len(rows))
+	for i, row := range rows {
+		if annotations[i], err = fromRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return annotations, nil
+}
+
+// validate checks annotation and normalizes its note and tags in place: tags
+// are trimmed, lowercased and deduplicated.
+func validate(annotation *Annotation) error {
+	if annotation.MessageID == "" || annotation.SessionID == "" {
+		return errors.New("message and session are required")
+	}
+	switch annotation.Rating {
+	case RatingNone, RatingUp, RatingDown:
+	default:
+		return fmt.Errorf("invalid rating %q: must be %q, %q or empty", annotation.Rating, RatingUp, RatingDown)
+	}
+	annotation.Note = strings.TrimSpace(annotation.Note)
+
+	tags := make([]string, 0, len(annotation.Tags))
+	for _, tag := range annotation.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxTags {
+		return fmt.Errorf("too many tags: at most %d allowed", MaxTags)
+	}
+	annotation.Tags = tags
+	return nil
+}
+
+func fromRow(row db.MessageAnnotation) (Annotation, error) {
+	annotation := Annotation{
+		MessageID: row.MessageID,
+		SessionID: row.SessionID,
+		Rating:    Rating(row.Rating),
+		Note:      row.Note,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+	if err := json.Unmarshal([]byte(row.Tags), &annotation.Tags); err != nil {
+		return Annotation{}, fmt.Errorf("invalid tags stored for message %s: %w", row.MessageID, err)
+	}
+	return annotation, nil
+}
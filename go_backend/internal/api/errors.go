@@ -0,0 +1,129 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+
+	"mix/internal/annotation"
+	"mix/internal/artifact"
+	"mix/internal/checkpoint"
+	"mix/internal/commands"
+	"mix/internal/jobs"
+	"mix/internal/llm/agent"
+	"mix/internal/llm/provider"
+	"mix/internal/memory"
+	"mix/internal/pin"
+	"mix/internal/question"
+	"mix/internal/runqueue"
+	"mix/internal/sessiontemplate"
+	"mix/internal/share"
+)
+
+// JSON-RPC error codes. The -32000 to -32099 range is reserved for the
+// server; each kind of failure clients may want to handle has its own code.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeApplicationError is any other failure of the requested operation
+	CodeApplicationError = -32000
+	// CodeRateLimited rejects clients over the server's own request limits
+	CodeRateLimited         = -32001
+	CodeAuthRequired        = -32002
+	CodeForbidden           = -32003
+	CodeSessionBusy         = -32004
+	CodeBudgetExceeded      = -32005
+	CodeProviderRateLimited = -32006
+	CodeNotFound            = -32007
+)
+
+// ErrorKind names the kind of an error in QueryError.Data, so clients can
+// handle failures without parsing messages.
+type ErrorKind string
+
+const (
+	ErrorKindValidation          ErrorKind = "validation"
+	ErrorKindNotFound            ErrorKind = "not_found"
+	ErrorKindInternal            ErrorKind = "internal"
+	ErrorKindApplication         ErrorKind = "application"
+	ErrorKindRateLimited         ErrorKind = "rate_limited"
+	ErrorKindAuthRequired        ErrorKind = "auth_required"
+	ErrorKindForbidden           ErrorKind = "forbidden"
+	ErrorKindSessionBusy         ErrorKind = "session_busy"
+	ErrorKindBudgetExceeded      ErrorKind = "budget_exceeded"
+	ErrorKindProviderRateLimited ErrorKind = "provider_rate_limited"
+)
+
+var errorKinds = map[int]ErrorKind{
+	CodeParseError:          ErrorKindValidation,
+	CodeInvalidRequest:      ErrorKindValidation,
+	CodeMethodNotFound:      ErrorKindNotFound,
+	CodeInvalidParams:       ErrorKindValidation,
+	CodeInternalError:       ErrorKindInternal,
+	CodeApplicationError:    ErrorKindApplication,
+	CodeRateLimited:         ErrorKindRateLimited,
+	CodeAuthRequired:        ErrorKindAuthRequired,
+	CodeForbidden:           ErrorKindForbidden,
+	CodeSessionBusy:         ErrorKindSessionBusy,
+	CodeBudgetExceeded:      ErrorKindBudgetExceeded,
+	CodeProviderRateLimited: ErrorKindProviderRateLimited,
+	CodeNotFound:            ErrorKindNotFound,
+}
+
+// ErrorData is the machine-readable part of a JSON-RPC error.
+type ErrorData struct {
+	Kind ErrorKind `json:"kind"`
+}
+
+// NewQueryError returns the error with the given code, with the code's kind
+// as its data.
+func NewQueryError(code int, message string) *QueryError {
+	kind, ok := errorKinds[code]
+	if !ok {
+		kind = ErrorKindApplication
+	}
+	return &QueryError{
+		Code:    code,
+		Message: message,
+		Data:    &ErrorData{Kind: kind},
+	}
+}
+
+// notFoundErrors are the errors of lookups that found nothing.
+var notFoundErrors = []error{
+	sql.ErrNoRows,
+	agent.ErrToolCallNotFound,
+	agent.ErrQueuedMessageNotFound,
+	annotation.ErrNotFound,
+	artifact.ErrNotFound,
+	checkpoint.ErrNotFound,
+	commands.ErrCommandNotFound,
+	jobs.ErrNotFound,
+	memory.ErrNotFound,
+	pin.ErrNotFound,
+	question.ErrNotFound,
+	sessiontemplate.ErrNotFound,
+	share.ErrNotFound,
+}
+
+// errorCode returns the code of the kind of err, or CodeApplicationError.
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, agent.ErrSessionBusy):
+		return CodeSessionBusy
+	case errors.Is(err, agent.ErrBudgetExceeded):
+		return CodeBudgetExceeded
+	case provider.IsRateLimited(err):
+		return CodeProviderRateLimited
+	case errors.Is(err, runqueue.ErrFull):
+		return CodeRateLimited
+	}
+	for _, target := range notFoundErrors {
+		if errors.Is(err, target) {
+			return CodeNotFound
+		}
+	}
+	return CodeApplicationError
+}
@@ -2,21 +2,47 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"mix/internal/annotation"
+	"mix/internal/checkpoint"
 	"mix/internal/app"
+	"mix/internal/artifact"
+	"mix/internal/audit"
 	"mix/internal/commands"
 	"mix/internal/config"
+	"mix/internal/gallery"
+	"mix/internal/i18n"
+	"mix/internal/jobs"
 	"mix/internal/llm/agent"
+	"mix/internal/llm/models"
 	"mix/internal/llm/provider"
+	"mix/internal/llm/reminder"
+	"mix/internal/llm/responseformat"
 	"mix/internal/llm/tools"
 	"mix/internal/logging"
+	"mix/internal/memory"
+	"mix/internal/message"
+	"mix/internal/outputlimit"
 	"mix/internal/permission"
+	"mix/internal/question"
+	"mix/internal/pin"
+	"mix/internal/render"
+	"mix/internal/runqueue"
+	"mix/internal/session"
+	"mix/internal/sessiontemplate"
+	"mix/internal/share"
+	"mix/internal/streamtoken"
+	"mix/internal/toolstats"
+	"mix/internal/transcript"
 )
 
 // JSON-RPC Request
@@ -35,8 +61,9 @@ type QueryResponse struct {
 
 // JSON-RPC Error
 type QueryError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *ErrorData `json:"data,omitempty"`
 }
 
 // Structured data types
@@ -52,6 +79,118 @@ type SessionData struct {
 	CreatedAt             time.Time `json:"createdAt"`
 	WorkingDirectory      string    `json:"workingDirectory,omitempty"`
 	FirstUserMessage      string    `json:"firstUserMessage,omitempty"`
+	Tags                  []string  `json:"tags"`
+	Pinned                bool      `json:"pinned"`
+	Archived              bool      `json:"archived"`
+	// CopiedFiles lists the files sessions.fork copied into a fork
+	CopiedFiles []CopiedFileData `json:"copiedFiles,omitempty"`
+}
+
+// CopiedFileData is a file sessions.fork snapshotted into the fork
+type CopiedFileData struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// UsageReportData is a session's token usage and cost, with prompt cache
+// totals, extended thinking and the cost of paid tool calls broken out
+type UsageReportData struct {
+	SessionID           string         `json:"sessionId"`
+	PromptTokens        int64          `json:"promptTokens"`
+	CompletionTokens    int64          `json:"completionTokens"`
+	Cost                float64        `json:"cost"`
+	LLMCost             float64        `json:"llmCost"`
+	ToolCost            float64        `json:"toolCost"`
+	Tools               []ToolCostData `json:"tools"`
+	Requests            int64          `json:"requests"`
+	InputTokens         int64          `json:"inputTokens"`
+	CacheCreationTokens int64          `json:"cacheCreationTokens"`
+	CacheReadTokens     int64          `json:"cacheReadTokens"`
+	CacheHitRate        float64        `json:"cacheHitRate"`
+	Thinking            ThinkingData   `json:"thinking"`
+}
+
+// ThinkingData is the main agent's thinking settings and a session's thinking
+// totals. ToolFailureRate is the share of the session's recent tool calls that
+// failed, which raises the adaptive mode's budget.
+type ThinkingData struct {
+	Mode            string  `json:"mode"`
+	Budget          int     `json:"budget"`
+	MaxBudget       int     `json:"maxBudget"`
+	Requests        int64   `json:"requests"`
+	BudgetTokens    int64   `json:"budgetTokens"`
+	ToolFailureRate float64 `json:"toolFailureRate"`
+}
+
+// TurnEstimateData is a pre-flight estimate of sending a draft message. Token
+// counts are approximate and the cost range covers the first model request of
+// the turn; tool calls add further requests.
+type TurnEstimateData struct {
+	SessionID             string   `json:"sessionId"`
+	Model                 string   `json:"model"`
+	ModelName             string   `json:"modelName"`
+	Provider              string   `json:"provider"`
+	Fallback              []string `json:"fallback,omitempty"`
+	InputTokens           int64    `json:"inputTokens"`
+	SystemTokens          int64    `json:"systemTokens"`
+	ToolTokens            int64    `json:"toolTokens"`
+	HistoryTokens         int64    `json:"historyTokens"`
+	PinnedTokens          int64    `json:"pinnedTokens"`
+	DraftTokens           int64    `json:"draftTokens"`
+	MaxOutputTokens       int64    `json:"maxOutputTokens"`
+	MinCost               float64  `json:"minCost"`
+	MaxCost               float64  `json:"maxCost"`
+	ContextWindow         int64    `json:"contextWindow"`
+	ContextUsagePercent   float64  `json:"contextUsagePercent"`
+	ExceedsContext        bool     `json:"exceedsContext"`
+	CompactionRecommended bool     `json:"compactionRecommended"`
+	SessionCost           float64  `json:"sessionCost"`
+	// MayExceedBudget is set when maxSessionCost is configured and the session
+	// cost plus MaxCost is over it
+	MayExceedBudget bool `json:"mayExceedBudget"`
+}
+
+// PromptPreviewData is what the next turn of a session would send the model,
+// for debugging the prompt.
+type PromptPreviewData struct {
+	SessionID    string   `json:"sessionId"`
+	Model        string   `json:"model"`
+	SystemPrompt string   `json:"systemPrompt"`
+	Tools        []string `json:"tools"`
+	// Reminders are appended to the user message in this order
+	Reminders   []ReminderData `json:"reminders"`
+	UserMessage string         `json:"userMessage"`
+	// PinnedContext is sent ahead of the conversation, empty without pins
+	PinnedContext string `json:"pinnedContext,omitempty"`
+}
+
+// ReminderData is a reminder composed for a turn. Source is how it was
+// attached: planMode, turn, session or default.
+type ReminderData struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Order  int    `json:"order"`
+	Text   string `json:"text"`
+}
+
+// SessionRemindersData names the reminders attached to every turn of a
+// session, besides the configured defaults.
+type SessionRemindersData struct {
+	SessionID string   `json:"sessionId"`
+	Reminders []string `json:"reminders"`
+}
+
+type ToolCostData struct {
+	ToolName string  `json:"toolName"`
+	Calls    int64   `json:"calls"`
+	Cost     float64 `json:"cost"`
+}
+
+// ToolStatsData is the execution statistics of each tool and MCP server,
+// across sessions unless SessionID narrows them to one
+type ToolStatsData struct {
+	SessionID string `json:"sessionId,omitempty"`
+	toolstats.Report
 }
 
 type ToolData struct {
@@ -59,6 +198,13 @@ type ToolData struct {
 	Description string `json:"description"`
 }
 
+// SessionToolData is a tool and whether it is enabled for a session
+type SessionToolData struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
 type MCPServerData struct {
 	Name      string     `json:"name"`
 	Connected bool       `json:"connected"`
@@ -87,6 +233,525 @@ type MessageData struct {
 	Content   string         `json:"content"`
 	Response  string         `json:"response,omitempty"`
 	ToolCalls []ToolCallData `json:"toolCalls,omitempty"`
+	// Reasoning is only populated when includeThinking is requested
+	Reasoning         string `json:"reasoning,omitempty"`
+	ReasoningDuration int64  `json:"reasoningDuration,omitempty"`
+	// ProviderRequestID is the LLM provider's ID for the request that produced an assistant message
+	ProviderRequestID string `json:"providerRequestId,omitempty"`
+	// Cost is the USD cost of the generation that produced an assistant message
+	Cost float64 `json:"cost,omitempty"`
+	// CostUnknown marks assistant messages whose cost wasn't recorded, such as
+	// ones from before costs were
+	CostUnknown bool `json:"costUnknown,omitempty"`
+	// Provider and Model identify who answered an assistant message, which
+	// may be a fallback model
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// FinishReason tells how an assistant message ended, e.g. "end_turn", or
+	// "interrupted" when the server stopped while generating it
+	FinishReason string `json:"finishReason,omitempty"`
+	// HistoryPruned tells what the agent's history strategy left out of the
+	// request that produced an assistant message
+	HistoryPruned *HistoryPrunedData `json:"historyPruned,omitempty"`
+	// Diagrams are only populated when renderDiagrams is requested
+	Diagrams []DiagramData `json:"diagrams,omitempty"`
+	// Annotation is the feedback left on the message with messages.annotate
+	Annotation *AnnotationData `json:"annotation,omitempty"`
+	// Set when the session's output profile limited the response. A split
+	// response is numbered Part of Parts; messages.list returns each part as
+	// its own entry and messages.send the rest as Continuations. A truncated
+	// response keeps its full text as artifact ArtifactID.
+	Part          int      `json:"part,omitempty"`
+	Parts         int      `json:"parts,omitempty"`
+	Continuations []string `json:"continuations,omitempty"`
+	Truncated     bool     `json:"truncated,omitempty"`
+	ArtifactID    string   `json:"artifactId,omitempty"`
+	// Structured is the response's JSON when messages.send was given a
+	// responseFormat
+	Structured json.RawMessage `json:"structured,omitempty"`
+	// ContentLength and ToolCallCount are set by the summary projection of
+	// messages.list, whose Content is only a preview
+	ContentLength int `json:"contentLength,omitempty"`
+	ToolCallCount int `json:"toolCallCount,omitempty"`
+}
+
+// Projections of messages.list
+const (
+	projectionFull    = "full"
+	projectionSummary = "summary"
+)
+
+// messagePreviewLength is how many characters of its content a message has in
+// the summary projection.
+const messagePreviewLength = 200
+
+// HistoryPrunedData lists the messages a history strategy left out of a
+// request and the tool calls whose results it sent as placeholders.
+type HistoryPrunedData struct {
+	Strategy          string   `json:"strategy"`
+	DroppedMessageIDs []string `json:"droppedMessageIds,omitempty"`
+	PrunedToolCallIDs []string `json:"prunedToolCallIds,omitempty"`
+}
+
+func newHistoryPrunedData(msg message.Message) *HistoryPrunedData {
+	pruned := msg.HistoryPruned()
+	if pruned == nil {
+		return nil
+	}
+	return &HistoryPrunedData{
+		Strategy:          pruned.Strategy,
+		DroppedMessageIDs: pruned.DroppedMessageIDs,
+		PrunedToolCallIDs: pruned.PrunedToolCallIDs,
+	}
+}
+
+// AnnotationData is the feedback on a message: a rating of "up" or "down",
+// a note and tags.
+type AnnotationData struct {
+	MessageID string   `json:"messageId"`
+	SessionID string   `json:"sessionId"`
+	Rating    string   `json:"rating"`
+	Note      string   `json:"note"`
+	Tags      []string `json:"tags"`
+	CreatedAt int64    `json:"createdAt,omitempty"`
+	UpdatedAt int64    `json:"updatedAt,omitempty"`
+}
+
+func newAnnotationData(a annotation.Annotation) *AnnotationData {
+	return &AnnotationData{
+		MessageID: a.MessageID,
+		SessionID: a.SessionID,
+		Rating:    string(a.Rating),
+		Note:      a.Note,
+		Tags:      a.Tags,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+}
+
+// SessionExportData is a session with its full message history and the
+// feedback on its messages.
+type SessionExportData struct {
+	Session    SessionData   `json:"session"`
+	Messages   []MessageData `json:"messages"`
+	ExportedAt time.Time     `json:"exportedAt"`
+}
+
+// SessionRenderData is a session's transcript as a Markdown or standalone
+// HTML document, with a suggested file name.
+type SessionRenderData struct {
+	SessionID string `json:"sessionId"`
+	Format    string `json:"format"`
+	Filename  string `json:"filename"`
+	Content   string `json:"content"`
+}
+
+// ShareData is a read-only link to a session's transcript. URL is set when
+// sharing.baseUrl is configured; otherwise clients prefix Path with the
+// server's address.
+type ShareData struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionId"`
+	Token     string    `json:"token"`
+	Path      string    `json:"path"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func newShareData(s share.Share) ShareData {
+	data := ShareData{
+		ID:        s.ID,
+		SessionID: s.SessionID,
+		Token:     s.Token,
+		Path:      s.Path(),
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}
+	if baseURL := config.Get().Sharing.BaseURL; baseURL != "" {
+		data.URL = strings.TrimSuffix(baseURL, "/") + data.Path
+	}
+	return data
+}
+
+// limitResponse sets the response of a messages.send result, shaped by the
+// output profile.
+func (m *MessageData) limitResponse(output outputlimit.Output) {
+	m.Response = output.Parts[0]
+	m.Truncated = output.Truncated
+	m.ArtifactID = output.ArtifactID
+	if len(output.Parts) > 1 {
+		m.Part = 1
+		m.Parts = len(output.Parts)
+		m.Continuations = output.Parts[1:]
+	}
+}
+
+// DiagramData is a rendered mermaid, graphviz or LaTeX block of a message.
+// Index counts renderable blocks in the message content; URL is relative to
+// the HTTP server and Error is set instead when rendering failed.
+type DiagramData struct {
+	Index    int    `json:"index"`
+	Language string `json:"language"`
+	URL      string `json:"url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SessionDiffData struct {
+	BaseSessionID    string `json:"baseSessionId"`
+	CompareSessionID string `json:"compareSessionId"`
+	// ForkPoint is the number of leading messages both sessions share
+	ForkPoint int            `json:"forkPoint"`
+	Base      BranchDiffData `json:"base"`
+	Compare   BranchDiffData `json:"compare"`
+	// Deltas are compare minus base
+	CostDelta            float64 `json:"costDelta"`
+	DurationDeltaSeconds int64   `json:"durationDeltaSeconds"`
+}
+
+type BranchDiffData struct {
+	Messages        []MessageData  `json:"messages"`
+	UniqueToolCalls []ToolCallData `json:"uniqueToolCalls"`
+	// Cost leaves out the messages whose cost is unknown, counted in
+	// UnknownCostMessages
+	Cost                float64 `json:"cost"`
+	UnknownCostMessages int     `json:"unknownCostMessages"`
+	DurationSeconds     int64   `json:"durationSeconds"`
+}
+
+type StreamTokenData struct {
+	Token     string    `json:"token"`
+	SessionID string    `json:"sessionId"`
+	Scope     string    `json:"scope"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type AuditEntryData struct {
+	ID                 string    `json:"id"`
+	SessionID          string    `json:"sessionId"`
+	MessageID          string    `json:"messageId"`
+	ToolCallID         string    `json:"toolCallId"`
+	ToolName           string    `json:"toolName"`
+	Input              string    `json:"input"`
+	Output             string    `json:"output"`
+	OutputTruncated    bool      `json:"outputTruncated"`
+	IsError            bool      `json:"isError"`
+	Error              string    `json:"error,omitempty"`
+	PermissionDecision string    `json:"permissionDecision"`
+	DurationMs         int64     `json:"durationMs"`
+	Cost               float64   `json:"cost"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+type ArtifactData struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Offset  int64  `json:"offset"`
+	Size    int64  `json:"size"`
+	HasMore bool   `json:"hasMore"`
+}
+
+// SessionEnvData is the environment variables of a session's bash commands
+// and stdio MCP servers.
+type SessionEnvData struct {
+	SessionID string            `json:"sessionId"`
+	Env       map[string]string `json:"env"`
+}
+
+type OutputProfileData struct {
+	SessionID string `json:"sessionId"`
+	// Profile is empty when the session has no output limit
+	Profile  string `json:"profile"`
+	MaxBytes int    `json:"maxBytes,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+}
+
+// SandboxProfileData is the sandbox profile of a session's bash commands.
+// Profile is the session's selection, empty when it uses the configured
+// default; Effective is the profile commands run in, empty when unconfined.
+type SandboxProfileData struct {
+	SessionID string `json:"sessionId"`
+	Profile   string `json:"profile"`
+	Effective string `json:"effective"`
+}
+
+// ProviderAuthData is how a provider authenticates. Method is the credential
+// in use, in priority order oauth, stored, config, env, then cloud for AWS,
+// Google Cloud or Entra ID credentials, or none; the flags report every
+// credential available.
+type ProviderAuthData struct {
+	Provider   string `json:"provider"`
+	Configured bool   `json:"configured"`
+	Enabled    bool   `json:"enabled"`
+	Method     string `json:"method"`
+	OAuth      bool   `json:"oauth"`
+	StoredKey  bool   `json:"storedKey"`
+	ConfigKey  bool   `json:"configKey"`
+	EnvKey     bool   `json:"envKey"`
+	EnvVar     string `json:"envVar,omitempty"`
+	KeyHint    string `json:"keyHint,omitempty"`
+	UpdatedAt  int64  `json:"updatedAt,omitempty"`
+}
+
+// ConfigReloadData lists the top-level config sections a reload changed, and
+// those among them that only take effect after a restart.
+type ConfigReloadData struct {
+	Changed         []string `json:"changed"`
+	RestartRequired []string `json:"restartRequired"`
+}
+
+type SessionLocaleData struct {
+	SessionID string `json:"sessionId"`
+	// Locale is empty when the session uses the configured locale
+	Locale string `json:"locale"`
+}
+
+// SessionArtifactData is a generated file in a session's output directory.
+// Location is "local" or the storage URI of its uploaded copy, and URL a
+// temporary download link for that copy.
+type SessionArtifactData struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Local      bool   `json:"local"`
+	Location   string `json:"location"`
+	UploadedAt int64  `json:"uploadedAt,omitempty"`
+	URL        string `json:"url,omitempty"`
+}
+
+// GalleryItemData is a media file a session produced. Path is relative to the
+// session's working directory unless the file is outside it, and tool and
+// messageId name the tool call that wrote or referenced it. Metadata fields
+// are omitted when unknown, with metadataError saying why. URL serves the
+// file, and is omitted for files outside the working directory.
+type GalleryItemData struct {
+	Path          string  `json:"path"`
+	URL           string  `json:"url,omitempty"`
+	Category      string  `json:"category"`
+	Size          int64   `json:"size"`
+	ModifiedAt    int64   `json:"modifiedAt"`
+	Tool          string  `json:"tool,omitempty"`
+	MessageID     string  `json:"messageId,omitempty"`
+	Width         int     `json:"width,omitempty"`
+	Height        int     `json:"height,omitempty"`
+	Duration      float64 `json:"duration,omitempty"`
+	Format        string  `json:"format,omitempty"`
+	VideoCodec    string  `json:"videoCodec,omitempty"`
+	AudioCodec    string  `json:"audioCodec,omitempty"`
+	MetadataError string  `json:"metadataError,omitempty"`
+}
+
+// TemplateData is a preset for starting sessions with templates.apply.
+type TemplateData struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description,omitempty"`
+	SystemPrompt     string   `json:"systemPrompt,omitempty"`
+	EnabledTools     []string `json:"enabledTools"`
+	Model            string   `json:"model,omitempty"`
+	WorkingDirectory string   `json:"workingDirectory,omitempty"`
+	Directories      []string `json:"directories"`
+	CreatedAt        int64    `json:"createdAt"`
+}
+
+// SessionTreeData is the fork tree of a session, parents before children.
+type SessionTreeData struct {
+	SessionID string                `json:"sessionId"`
+	RootID    string                `json:"rootId"`
+	Nodes     []SessionTreeNodeData `json:"nodes"`
+}
+
+// SessionTreeNodeData is a session in a fork tree. ForkMessageIndex is the
+// number of messages shared with the parent; BranchCost is the cost of the
+// messages after it.
+type SessionTreeNodeData struct {
+	ID               string    `json:"id"`
+	ParentID         string    `json:"parentId,omitempty"`
+	Title            string    `json:"title"`
+	CreatedAt        time.Time `json:"createdAt"`
+	ForkMessageIndex int       `json:"forkMessageIndex"`
+	MessageCount     int       `json:"messageCount"`
+	BranchCost       float64   `json:"branchCost"`
+	Children         []string  `json:"children"`
+}
+
+// PendingPermissionData is a permission request waiting for permission.grant
+// or permission.deny. AgeSeconds counts toward the auto-deny timeout.
+type PendingPermissionData struct {
+	ID          string      `json:"id"`
+	SessionID   string      `json:"sessionId"`
+	ToolName    string      `json:"toolName"`
+	Description string      `json:"description"`
+	Action      string      `json:"action"`
+	Path        string      `json:"path"`
+	Params      interface{} `json:"params"`
+	CreatedAt   int64       `json:"createdAt"`
+	AgeSeconds  float64     `json:"ageSeconds"`
+}
+
+// QuestionData is an ask_user question. Pending questions wait for
+// questions.answer; AgeSeconds counts toward the timeout after which the
+// turn goes on without an answer.
+type QuestionData struct {
+	ID            string   `json:"id"`
+	SessionID     string   `json:"sessionId"`
+	ToolCallID    string   `json:"toolCallId"`
+	Question      string   `json:"question"`
+	Options       []string `json:"options,omitempty"`
+	AllowFreeText bool     `json:"allowFreeText"`
+	CreatedAt     int64    `json:"createdAt"`
+	AgeSeconds    float64  `json:"ageSeconds,omitempty"`
+	Resolution    string   `json:"resolution,omitempty"`
+	Answer        string   `json:"answer,omitempty"`
+}
+
+// QueuedMessageData is a follow-up message sent with "queue" while its
+// session was generating. It runs as the session's next turn after the ones
+// queued before it, Position 1 being next.
+type QueuedMessageData struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionId"`
+	Content   string `json:"content"`
+	Position  int    `json:"position"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// ModelCatalogData is the catalog of models discovered from provider APIs.
+// Errors has the providers whose model list couldn't be fetched; their models
+// from the previous refresh are kept.
+type ModelCatalogData struct {
+	RefreshedAt int64             `json:"refreshedAt"`
+	Models      []ModelData       `json:"models"`
+	Errors      map[string]string `json:"errors,omitempty"`
+}
+
+// ModelData describes a model that agents and sessions can be configured with.
+type ModelData struct {
+	ID                  string  `json:"id"`
+	Name                string  `json:"name"`
+	Provider            string  `json:"provider"`
+	APIModel            string  `json:"apiModel"`
+	CostPer1MIn         float64 `json:"costPer1MIn"`
+	CostPer1MOut        float64 `json:"costPer1MOut"`
+	ContextWindow       int64   `json:"contextWindow"`
+	CanReason           bool    `json:"canReason"`
+	SupportsAttachments bool    `json:"supportsAttachments"`
+}
+
+// JobData is a prompt the server runs on a cron schedule. NextRunAt is when
+// the schedule next fires; jobs only run while mix serves HTTP.
+type JobData struct {
+	Name             string      `json:"name"`
+	Schedule         string      `json:"schedule"`
+	Prompt           string      `json:"prompt"`
+	Template         string      `json:"template,omitempty"`
+	WorkingDirectory string      `json:"workingDirectory,omitempty"`
+	NextRunAt        int64       `json:"nextRunAt,omitempty"`
+	LastRun          *JobRunData `json:"lastRun,omitempty"`
+	CreatedAt        int64       `json:"createdAt"`
+}
+
+// JobRunData is the outcome of a job's latest run; SessionID is the session
+// it ran in.
+type JobRunData struct {
+	SessionID string `json:"sessionId,omitempty"`
+	StartedAt int64  `json:"startedAt"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// CheckpointData is the file changes of one agent turn, started by the user
+// message MessageID. Files are relative to WorkingDirectory.
+type CheckpointData struct {
+	ID               string   `json:"id"`
+	SessionID        string   `json:"sessionId"`
+	MessageID        string   `json:"messageId"`
+	WorkingDirectory string   `json:"workingDirectory"`
+	Files            []string `json:"files"`
+	CreatedAt        int64    `json:"createdAt"`
+	RestoredAt       int64    `json:"restoredAt,omitempty"`
+}
+
+func checkpointData(c checkpoint.Checkpoint) CheckpointData {
+	return CheckpointData{
+		ID:               c.ID,
+		SessionID:        c.SessionID,
+		MessageID:        c.MessageID,
+		WorkingDirectory: c.WorkingDirectory,
+		Files:            c.Files,
+		CreatedAt:        c.CreatedAt,
+		RestoredAt:       c.RestoredAt,
+	}
+}
+
+// CheckpointRestoreData lists the checkpoints a restore reverted, newest
+// first, and the files it wrote back and deleted.
+type CheckpointRestoreData struct {
+	Checkpoints []CheckpointData `json:"checkpoints"`
+	Restored    []string         `json:"restored"`
+	Deleted     []string         `json:"deleted"`
+}
+
+// ContextPinData is a file or message kept in a session's context. Target is
+// the file path, relative to the session's working directory when inside it,
+// or the message ID. Tokens approximates the pin's share of every request.
+type ContextPinData struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionId"`
+	Kind      string `json:"kind"`
+	Target    string `json:"target"`
+	CreatedAt int64  `json:"createdAt"`
+	Tokens    int64  `json:"tokens"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Error says why the content is unavailable, e.g. a deleted file
+	Error string `json:"error,omitempty"`
+}
+
+func contextPinData(c pin.Content) ContextPinData {
+	return ContextPinData{
+		ID:        c.ID,
+		SessionID: c.SessionID,
+		Kind:      string(c.Kind),
+		Target:    c.Target,
+		CreatedAt: c.CreatedAt,
+		Tokens:    c.Tokens,
+		Truncated: c.Truncated,
+		Error:     c.Error,
+	}
+}
+
+// ContextListData is a session's pins, oldest first, and their total tokens.
+type ContextListData struct {
+	Pins   []ContextPinData `json:"pins"`
+	Tokens int64            `json:"tokens"`
+}
+
+// QueueStatusData is the agent run queue: its limits, where 0 is unlimited,
+// the runs in progress and waiting per client, and with a sessionId, the
+// position of the session's waiting run.
+type QueueStatusData struct {
+	Limit     int               `json:"limit"`
+	MaxQueued int               `json:"maxQueued"`
+	Running   int               `json:"running"`
+	Queued    int               `json:"queued"`
+	Clients   []QueueClientData `json:"clients"`
+	Position  int               `json:"position,omitempty"`
+}
+
+type QueueClientData struct {
+	Client  string `json:"client"`
+	Running int    `json:"running"`
+	Queued  int    `json:"queued"`
+}
+
+// MemoryData is a long-term memory. Workspace is the working directory of
+// workspace memories, and SessionID the session the memory was written in.
+type MemoryData struct {
+	ID        string `json:"id"`
+	Scope     string `json:"scope"`
+	Workspace string `json:"workspace,omitempty"`
+	Content   string `json:"content"`
+	SessionID string `json:"sessionId,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
 }
 
 // Error response helper functions
@@ -94,37 +759,40 @@ type MessageData struct {
 // newErrorResponse creates a standardized QueryResponse with error
 func newErrorResponse(req *QueryRequest, code int, message string) *QueryResponse {
 	return &QueryResponse{
-		Error: &QueryError{
-			Code:    code,
-			Message: message,
-		},
-		ID: req.ID,
+		Error: NewQueryError(code, message),
+		ID:    req.ID,
 	}
 }
 
 // newInvalidParamsError creates a -32602 Invalid params error response
 func newInvalidParamsError(req *QueryRequest, err error) *QueryResponse {
-	return newErrorResponse(req, -32602, "Invalid params: "+err.Error())
+	return newErrorResponse(req, CodeInvalidParams, "Invalid params: "+err.Error())
 }
 
 // newMissingParamError creates a -32602 Missing required parameter error response
 func newMissingParamError(req *QueryRequest, param string) *QueryResponse {
-	return newErrorResponse(req, -32602, "Missing required parameter: "+param)
+	return newErrorResponse(req, CodeInvalidParams, "Missing required parameter: "+param)
 }
 
 // newInternalError creates a -32603 Internal error response
 func newInternalError(req *QueryRequest, err error) *QueryResponse {
-	return newErrorResponse(req, -32603, "Internal error: "+err.Error())
+	return newErrorResponse(req, CodeInternalError, "Internal error: "+err.Error())
 }
 
 // newMethodNotFoundError creates a -32601 Method not found error response
 func newMethodNotFoundError(req *QueryRequest, method string) *QueryResponse {
-	return newErrorResponse(req, -32601, "Method not found: "+method)
+	return newErrorResponse(req, CodeMethodNotFound, "Method not found: "+method)
 }
 
 // newApplicationError creates a -32000 Application-specific error response
 func newApplicationError(req *QueryRequest, message string) *QueryResponse {
-	return newErrorResponse(req, -32000, message)
+	return newErrorResponse(req, CodeApplicationError, message)
+}
+
+// newOperationError creates the error response of a failed operation, with
+// the code of err's kind, e.g. -32007 when what it looked up doesn't exist
+func newOperationError(req *QueryRequest, message string, err error) *QueryResponse {
+	return newErrorResponse(req, errorCode(err), message+": "+err.Error())
 }
 
 // Query handler
@@ -166,6 +834,16 @@ func (h *QueryHandler) Handle(ctx context.Context, req *QueryRequest) *QueryResp
 		return h.handleSessionsList(ctx, req)
 	case "sessions.get":
 		return h.handleSessionsGet(ctx, req)
+	case "sessions.export":
+		return h.handleSessionsExport(ctx, req)
+	case "sessions.render":
+		return h.handleSessionsRender(ctx, req)
+	case "sessions.share":
+		return h.handleSessionsShare(ctx, req)
+	case "sessions.shares":
+		return h.handleSessionsShares(ctx, req)
+	case "sessions.unshare":
+		return h.handleSessionsUnshare(ctx, req)
 	case "sessions.current":
 		return h.handleSessionsCurrent(ctx, req)
 	case "sessions.select":
@@ -174,16 +852,58 @@ func (h *QueryHandler) Handle(ctx context.Context, req *QueryRequest) *QueryResp
 		return h.handleSessionsCreate(ctx, req)
 	case "sessions.fork":
 		return h.handleSessionsFork(ctx, req)
+	case "sessions.diff":
+		return h.handleSessionsDiff(ctx, req)
+	case "sessions.tree":
+		return h.handleSessionsTree(ctx, req)
+	case "sessions.update":
+		return h.handleSessionsUpdate(ctx, req)
+	case "sessions.tools.set":
+		return h.handleSessionsToolsSet(ctx, req)
+	case "sessions.reminders.set":
+		return h.handleSessionsRemindersSet(ctx, req)
+	case "sessions.env.get":
+		return h.handleSessionsEnvGet(ctx, req)
+	case "sessions.env.set":
+		return h.handleSessionsEnvSet(ctx, req)
+	case "sessions.outputProfile.set":
+		return h.handleSessionsOutputProfileSet(ctx, req)
+	case "sessions.sandbox.set":
+		return h.handleSessionsSandboxSet(ctx, req)
+	case "sessions.locale.set":
+		return h.handleSessionsLocaleSet(ctx, req)
+	case "sessions.setWorkingDirectory":
+		return h.handleSessionsSetWorkingDirectory(ctx, req)
 	case "sessions.delete":
 		return h.handleSessionsDelete(ctx, req)
 	case "messages.send":
 		return h.handleMessagesSend(ctx, req)
 	case "messages.history":
 		return h.handleMessagesHistory(ctx, req)
+	case "messages.edit":
+		return h.handleMessagesEdit(ctx, req)
+	case "messages.regenerate":
+		return h.handleMessagesRegenerate(ctx, req)
+	case "messages.estimate":
+		return h.handleMessagesEstimate(ctx, req)
+	case "messages.preview":
+		return h.handleMessagesPreview(ctx, req)
 	case "messages.list":
 		return h.handleMessagesList(ctx, req)
+	case "messages.annotate":
+		return h.handleMessagesAnnotate(ctx, req)
+	case "messages.listQueued":
+		return h.handleMessagesListQueued(ctx, req)
+	case "messages.removeQueued":
+		return h.handleMessagesRemoveQueued(ctx, req)
 	case "mcp.list":
 		return h.handleMCPList(ctx, req)
+	case "tools.list":
+		return h.handleToolsList(ctx, req)
+	case "tools.stats":
+		return h.handleToolsStats(ctx, req)
+	case "tools.cancel":
+		return h.handleToolsCancel(ctx, req)
 	case "commands.list":
 		return h.handleCommandsList(ctx, req)
 	case "commands.get":
@@ -194,10 +914,68 @@ func (h *QueryHandler) Handle(ctx context.Context, req *QueryRequest) *QueryResp
 		return h.handleAuthLogin(ctx, req)
 	case "auth.apikey":
 		return h.handleSetAPIKey(ctx, req)
+	case "providers.list":
+		return h.handleProvidersList(ctx, req)
+	case "providers.setKey":
+		return h.handleProvidersSetKey(ctx, req)
+	case "config.reload":
+		return h.handleConfigReload(ctx, req)
 	case "permission.grant":
 		return h.handlePermissionGrant(ctx, req)
 	case "permission.deny":
 		return h.handlePermissionDeny(ctx, req)
+	case "permission.listPending":
+		return h.handlePermissionListPending(ctx, req)
+	case "questions.answer":
+		return h.handleQuestionsAnswer(ctx, req)
+	case "questions.listPending":
+		return h.handleQuestionsListPending(ctx, req)
+	case "stream.token":
+		return h.handleStreamToken(ctx, req)
+	case "audit.list":
+		return h.handleAuditList(ctx, req)
+	case "audit.get":
+		return h.handleAuditGet(ctx, req)
+	case "artifacts.get":
+		return h.handleArtifactGet(ctx, req)
+	case "artifacts.list":
+		return h.handleArtifactsList(ctx, req)
+	case "assets.list":
+		return h.handleAssetsList(ctx, req)
+	case "usage.report":
+		return h.handleUsageReport(ctx, req)
+	case "templates.create":
+		return h.handleTemplatesCreate(ctx, req)
+	case "templates.list":
+		return h.handleTemplatesList(ctx, req)
+	case "templates.apply":
+		return h.handleTemplatesApply(ctx, req)
+	case "templates.delete":
+		return h.handleTemplatesDelete(ctx, req)
+	case "models.refresh":
+		return h.handleModelsRefresh(ctx, req)
+	case "jobs.create":
+		return h.handleJobsCreate(ctx, req)
+	case "jobs.list":
+		return h.handleJobsList(ctx, req)
+	case "jobs.delete":
+		return h.handleJobsDelete(ctx, req)
+	case "checkpoints.list":
+		return h.handleCheckpointsList(ctx, req)
+	case "checkpoints.restore":
+		return h.handleCheckpointsRestore(ctx, req)
+	case "context.pin":
+		return h.handleContextPin(ctx, req)
+	case "context.list":
+		return h.handleContextList(ctx, req)
+	case "context.unpin":
+		return h.handleContextUnpin(ctx, req)
+	case "queue.status":
+		return h.handleQueueStatus(ctx, req)
+	case "memory.list":
+		return h.handleMemoryList(ctx, req)
+	case "memory.delete":
+		return h.handleMemoryDelete(ctx, req)
 	default:
 		return newMethodNotFoundError(req, req.Method)
 	}
@@ -218,7 +996,7 @@ func (h *QueryHandler) HandleQueryType(ctx context.Context, queryType string) *Q
 
 	// Invalid query type
 	req := &QueryRequest{ID: 1} // Create temporary request for error response
-	return newErrorResponse(req, -32602, "Invalid query type: " + queryType + ". Supported: " + strings.Join(supportedTypes, ", "))
+	return newErrorResponse(req, CodeInvalidParams, "Invalid query type: " + queryType + ". Supported: " + strings.Join(supportedTypes, ", "))
 }
 
 // GetSupportedQueryTypes returns all supported query types
@@ -239,11 +1017,9 @@ func (h *QueryHandler) handleSetAPIKey(ctx context.Context, req *QueryRequest) *
 		return newMissingParamError(req, "apiKey")
 	}
 
-	// Set environment variable
-	os.Setenv("ANTHROPIC_API_KEY", params.APIKey)
-
-	// No need to reload providers, they'll pick up the new API key from env automatically
-	// on the next request
+	if err := h.app.SetProviderAPIKey(models.ProviderAnthropic, params.APIKey); err != nil {
+		return newOperationError(req, "Failed to set API key", err)
+	}
 
 	return &QueryResponse{
 		Result: map[string]interface{}{
@@ -254,6 +1030,93 @@ func (h *QueryHandler) handleSetAPIKey(ctx context.Context, req *QueryRequest) *
 	}
 }
 
+func (h *QueryHandler) handleProvidersList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	statuses, err := provider.AuthStatuses()
+	if err != nil {
+		return newOperationError(req, "Failed to list providers", err)
+	}
+
+	result := make([]ProviderAuthData, 0, len(statuses))
+	for _, status := range statuses {
+		result = append(result, newProviderAuthData(status))
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleConfigReload(ctx context.Context, req *QueryRequest) *QueryResponse {
+	change, err := h.app.ReloadConfig(ctx)
+	if err != nil {
+		return newApplicationError(req, "Failed to reload config, keeping the current configuration: "+err.Error())
+	}
+
+	return &QueryResponse{
+		Result: ConfigReloadData{
+			Changed:         change.Changed,
+			RestartRequired: change.RestartRequired,
+		},
+		ID: req.ID,
+	}
+}
+
+func newProviderAuthData(status provider.AuthStatus) ProviderAuthData {
+	return ProviderAuthData{
+		Provider:   string(status.Provider),
+		Configured: status.Configured,
+		Enabled:    status.Configured && !status.Disabled,
+		Method:     string(status.Method),
+		OAuth:      status.OAuth,
+		StoredKey:  status.StoredKey,
+		ConfigKey:  status.ConfigKey,
+		EnvKey:     status.EnvKey,
+		EnvVar:     status.EnvVar,
+		KeyHint:    status.KeyHint,
+		UpdatedAt:  status.UpdatedAt,
+	}
+}
+
+func (h *QueryHandler) handleProvidersSetKey(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		Provider string `json:"provider"`
+		// APIKey replaces the stored key; "" clears it so the config file or
+		// environment key applies again
+		APIKey string `json:"apiKey"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.Provider == "" {
+		return newMissingParamError(req, "provider")
+	}
+	p := models.ModelProvider(params.Provider)
+	if config.APIKeyEnv(p) == "" {
+		return newErrorResponse(req, CodeInvalidParams, "Provider does not authenticate with an API key: "+params.Provider)
+	}
+
+	if err := h.app.SetProviderAPIKey(p, params.APIKey); err != nil {
+		return newOperationError(req, "Failed to set API key", err)
+	}
+
+	statuses, err := provider.AuthStatuses()
+	if err != nil {
+		return newOperationError(req, "Failed to get provider status", err)
+	}
+	for _, status := range statuses {
+		if status.Provider == p {
+			return &QueryResponse{
+				Result: newProviderAuthData(status),
+				ID:     req.ID,
+			}
+		}
+	}
+	return newErrorResponse(req, CodeNotFound, "Provider status not found: " + params.Provider)
+}
+
 func (h *QueryHandler) handleAuthLogin(ctx context.Context, req *QueryRequest) *QueryResponse {
 	var params struct {
 		AuthCode string `json:"authCode"`
@@ -267,8 +1130,9 @@ func (h *QueryHandler) handleAuthLogin(ctx context.Context, req *QueryRequest) *
 
 	// Check if this is a manual API key submission
 	if params.APIKey != "" {
-		// Set environment variable
-		os.Setenv("ANTHROPIC_API_KEY", params.APIKey)
+		if err := h.app.SetProviderAPIKey(models.ProviderAnthropic, params.APIKey); err != nil {
+			return newOperationError(req, "Failed to set API key", err)
+		}
 
 		return &QueryResponse{
 			Result: map[string]interface{}{
@@ -285,7 +1149,7 @@ func (h *QueryHandler) handleAuthLogin(ctx context.Context, req *QueryRequest) *
 
 	storage, err := provider.NewCredentialStorage()
 	if err != nil {
-		return newErrorResponse(req, -32603, "Failed to initialize credential storage: " + err.Error())
+		return newErrorResponse(req, CodeInternalError, "Failed to initialize credential storage: " + err.Error())
 	}
 
 	// Extract state from auth code to retrieve the correct OAuth flow
@@ -298,21 +1162,23 @@ func (h *QueryHandler) handleAuthLogin(ctx context.Context, req *QueryRequest) *
 		oauthFlow = provider.GetOAuthFlow(state)
 
 		if oauthFlow == nil {
-			return newErrorResponse(req, -32603, "OAuth flow not found for this session. Please restart the authentication process.")
+			return newErrorResponse(req, CodeInternalError, "OAuth flow not found for this session. Please restart the authentication process.")
 		}
 	} else {
 		// Fallback: create new OAuth flow (for backwards compatibility)
 		var err error
 		oauthFlow, err = provider.NewOAuthFlow("")
 		if err != nil {
-			return newErrorResponse(req, -32603, "Failed to create OAuth flow: " + err.Error())
+			return newErrorResponse(req, CodeInternalError, "Failed to create OAuth flow: " + err.Error())
 		}
 	}
 
 	// For manual token entry (from UI), check if this is an API key (starts with sk-ant-)
 	if params.Manual && strings.HasPrefix(params.AuthCode, "sk-ant-") {
 		// This is a direct API key, not an auth code
-		os.Setenv("ANTHROPIC_API_KEY", params.AuthCode)
+		if err := h.app.SetProviderAPIKey(models.ProviderAnthropic, params.AuthCode); err != nil {
+			return newOperationError(req, "Failed to set API key", err)
+		}
 
 		return &QueryResponse{
 			Result: map[string]interface{}{
@@ -339,13 +1205,13 @@ func (h *QueryHandler) handleAuthLogin(ctx context.Context, req *QueryRequest) *
 		}
 
 		// For other OAuth exchange failures, guide user to manual API key approach
-		return newErrorResponse(req, -32603, "Failed to exchange authorization code: " + err.Error())
+		return newErrorResponse(req, CodeInternalError, "Failed to exchange authorization code: " + err.Error())
 	}
 
 	// Store the credentials
 	err = storage.StoreOAuthCredentials("anthropic", credentials.AccessToken, credentials.RefreshToken, credentials.ExpiresAt, credentials.ClientID)
 	if err != nil {
-		return newErrorResponse(req, -32603, "Failed to store credentials: " + err.Error())
+		return newErrorResponse(req, CodeInternalError, "Failed to store credentials: " + err.Error())
 	}
 
 	// Clean up the OAuth flow from memory after successful authentication
@@ -364,9 +1230,31 @@ func (h *QueryHandler) handleAuthLogin(ctx context.Context, req *QueryRequest) *
 }
 
 func (h *QueryHandler) handleSessionsList(ctx context.Context, req *QueryRequest) *QueryResponse {
-	sessions, err := h.app.Sessions.ListWithContent(ctx)
+	var params struct {
+		Tag      *string `json:"tag"`
+		Pinned   *bool   `json:"pinned"`
+		Archived *bool   `json:"archived"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	// Archived sessions are hidden unless asked for
+	archived := false
+	if params.Archived != nil {
+		archived = *params.Archived
+	}
+
+	sessions, err := h.app.Sessions.ListWithContent(ctx, session.ListFilter{
+		Tag:      params.Tag,
+		Pinned:   params.Pinned,
+		Archived: &archived,
+	})
 	if err != nil {
-		return newApplicationError(req, "Failed to list sessions: " + err.Error())
+		return newOperationError(req, "Failed to list sessions", err)
 	}
 
 	var result []SessionData
@@ -388,6 +1276,9 @@ func (h *QueryHandler) handleSessionsList(ctx context.Context, req *QueryRequest
 			CreatedAt:             time.Unix(s.CreatedAt, 0),
 			WorkingDirectory:      workingDir,
 			FirstUserMessage:      s.FirstUserMessage,
+			Tags:                  session.DecodeTags(s.Tags),
+			Pinned:                s.Pinned,
+			Archived:              s.Archived,
 		})
 	}
 
@@ -412,7 +1303,7 @@ func (h *QueryHandler) handleSessionsGet(ctx context.Context, req *QueryRequest)
 
 	session, err := h.app.Sessions.Get(ctx, params.ID)
 	if err != nil {
-		return newApplicationError(req, "Failed to get session: " + err.Error())
+		return newOperationError(req, "Failed to get session", err)
 	}
 
 	result := SessionData{
@@ -426,6 +1317,9 @@ func (h *QueryHandler) handleSessionsGet(ctx context.Context, req *QueryRequest)
 		Cost:             session.Cost,
 		CreatedAt:        time.Unix(session.CreatedAt, 0),
 		WorkingDirectory: session.WorkingDirectory,
+		Tags:             session.Tags,
+		Pinned:           session.Pinned,
+		Archived:         session.Archived,
 	}
 
 	return &QueryResponse{
@@ -437,7 +1331,7 @@ func (h *QueryHandler) handleSessionsGet(ctx context.Context, req *QueryRequest)
 func (h *QueryHandler) handleSessionsCurrent(ctx context.Context, req *QueryRequest) *QueryResponse {
 	currentSession, err := h.app.GetCurrentSession(ctx)
 	if err != nil {
-		return newApplicationError(req, "Failed to get current session: " + err.Error())
+		return newOperationError(req, "Failed to get current session", err)
 	}
 
 	if currentSession == nil {
@@ -454,6 +1348,9 @@ func (h *QueryHandler) handleSessionsCurrent(ctx context.Context, req *QueryRequ
 		CompletionTokens: currentSession.CompletionTokens,
 		Cost:             currentSession.Cost,
 		CreatedAt:        time.Unix(currentSession.CreatedAt, 0),
+		Tags:             currentSession.Tags,
+		Pinned:           currentSession.Pinned,
+		Archived:         currentSession.Archived,
 	}
 
 	return &QueryResponse{
@@ -487,7 +1384,7 @@ func (h *QueryHandler) handleSessionsSelect(ctx context.Context, req *QueryReque
 	// Set current session
 	err := h.app.SetCurrentSession(params.ID)
 	if err != nil {
-		return newApplicationError(req, "Failed to select session: " + err.Error())
+		return newOperationError(req, "Failed to select session", err)
 	}
 
 	return &QueryResponse{
@@ -514,14 +1411,14 @@ func (h *QueryHandler) handleSessionsCreate(ctx context.Context, req *QueryReque
 	// Create session
 	session, err := h.app.Sessions.Create(ctx, params.Title, params.WorkingDirectory)
 	if err != nil {
-		return newApplicationError(req, "Failed to create session: " + err.Error())
+		return newOperationError(req, "Failed to create session", err)
 	}
 
 	// Optionally set as current
 	if params.SetCurrent {
 		err = h.app.SetCurrentSession(session.ID)
 		if err != nil {
-			return newApplicationError(req, "Session created but failed to set as current: " + err.Error())
+			return newOperationError(req, "Session created but failed to set as current", err)
 		}
 	}
 
@@ -536,6 +1433,9 @@ func (h *QueryHandler) handleSessionsCreate(ctx context.Context, req *QueryReque
 		Cost:             session.Cost,
 		CreatedAt:        time.Unix(session.CreatedAt, 0),
 		WorkingDirectory: session.WorkingDirectory,
+		Tags:             session.Tags,
+		Pinned:           session.Pinned,
+		Archived:         session.Archived,
 	}
 
 	return &QueryResponse{
@@ -549,6 +1449,11 @@ func (h *QueryHandler) handleSessionsFork(ctx context.Context, req *QueryRequest
 		SourceSessionID string `json:"sourceSessionId"`
 		MessageIndex    int64  `json:"messageIndex"`
 		Title           string `json:"title,omitempty"`
+		// WorkingDirectory points the fork at another directory
+		WorkingDirectory string `json:"workingDirectory,omitempty"`
+		// CopyFiles snapshots referenced attachments and files into the
+		// fork's working directory
+		CopyFiles bool `json:"copyFiles,omitempty"`
 	}
 
 	if err := json.Unmarshal(req.Params, &params); err != nil {
@@ -572,13 +1477,28 @@ func (h *QueryHandler) handleSessionsFork(ctx context.Context, req *QueryRequest
 	// Create the forked session
 	newSession, err := h.app.Sessions.Fork(ctx, params.SourceSessionID, title)
 	if err != nil {
-		return newApplicationError(req, "Failed to fork session: " + err.Error())
+		return newOperationError(req, "Failed to fork session", err)
+	}
+
+	if params.WorkingDirectory != "" {
+		newSession, err = h.app.Sessions.SetWorkingDirectory(ctx, newSession.ID, params.WorkingDirectory)
+		if err != nil {
+			return newOperationError(req, "Failed to set working directory", err)
+		}
 	}
 
 	// Copy messages to the new session
 	err = h.app.Messages.CopyMessagesToSession(ctx, params.SourceSessionID, newSession.ID, params.MessageIndex)
 	if err != nil {
-		return newApplicationError(req, "Failed to copy messages: " + err.Error())
+		return newOperationError(req, "Failed to copy messages", err)
+	}
+
+	var copied []app.CopiedFile
+	if params.CopyFiles {
+		copied, err = h.app.CopyForkFiles(ctx, params.SourceSessionID, newSession.ID)
+		if err != nil {
+			return newOperationError(req, "Failed to copy files", err)
+		}
 	}
 
 	result := SessionData{
@@ -592,6 +1512,12 @@ func (h *QueryHandler) handleSessionsFork(ctx context.Context, req *QueryRequest
 		Cost:             newSession.Cost,
 		CreatedAt:        time.Unix(newSession.CreatedAt, 0),
 		WorkingDirectory: newSession.WorkingDirectory,
+		Tags:             newSession.Tags,
+		Pinned:           newSession.Pinned,
+		Archived:         newSession.Archived,
+	}
+	for _, file := range copied {
+		result.CopiedFiles = append(result.CopiedFiles, CopiedFileData{Source: file.Source, Destination: file.Destination})
 	}
 
 	return &QueryResponse{
@@ -600,62 +1526,252 @@ func (h *QueryHandler) handleSessionsFork(ctx context.Context, req *QueryRequest
 	}
 }
 
-func (h *QueryHandler) handleMCPList(ctx context.Context, req *QueryRequest) *QueryResponse {
-	cfg := config.Get()
+func (h *QueryHandler) handleSessionsDiff(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		BaseSessionID    string `json:"baseSessionId"`
+		CompareSessionID string `json:"compareSessionId"`
+	}
 
-	var result []MCPServerData
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
 
-	if len(cfg.MCPServers) == 0 {
-		return &QueryResponse{
-			Result: result, // Empty array
-			ID:     req.ID,
-		}
+	if params.BaseSessionID == "" {
+		return newMissingParamError(req, "baseSessionId")
+	}
+	if params.CompareSessionID == "" {
+		return newMissingParamError(req, "compareSessionId")
 	}
 
-	// Get MCP tools to check connection status and group by server
-	// Create temporary manager for informational listing
-	tempManager2 := agent.NewMCPClientManager()
-	defer tempManager2.Close()
-	mcpTools := agent.GetMcpTools(ctx, h.app.Permissions, tempManager2)
+	base, err := h.app.Sessions.Get(ctx, params.BaseSessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get base session", err)
+	}
+	compare, err := h.app.Sessions.Get(ctx, params.CompareSessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get compare session", err)
+	}
 
-	// Group tools by server name
-	serverTools := make(map[string][]tools.BaseTool)
-	for _, tool := range mcpTools {
-		if toolInfo := tool.Info(); strings.Contains(toolInfo.Name, "_") {
-			serverName := strings.Split(toolInfo.Name, "_")[0]
-			serverTools[serverName] = append(serverTools[serverName], tool)
-		}
+	// Forks share history with every session of their fork tree
+	related, err := h.app.SameForkTree(ctx, base.ID, compare.ID)
+	if err != nil {
+		return newOperationError(req, "Failed to compare session ancestry", err)
+	}
+	if !related {
+		return newApplicationError(req, "Sessions do not share a fork point")
 	}
 
-	// Sort server names for consistent output
-	var serverNames []string
-	for name := range cfg.MCPServers {
-		serverNames = append(serverNames, name)
+	baseMessages, err := h.app.Messages.List(ctx, base.ID)
+	if err != nil {
+		return newOperationError(req, "Failed to get base messages", err)
+	}
+	compareMessages, err := h.app.Messages.List(ctx, compare.ID)
+	if err != nil {
+		return newOperationError(req, "Failed to get compare messages", err)
 	}
-	sort.Strings(serverNames)
 
-	for _, name := range serverNames {
-		tools := serverTools[name]
+	diff := message.Diff(baseMessages, compareMessages)
 
-		// Determine connection status
-		connected := len(tools) > 0
-		status := "connected"
-		if !connected {
-			status = "failed"
+	return &QueryResponse{
+		Result: SessionDiffData{
+			BaseSessionID:        base.ID,
+			CompareSessionID:     compare.ID,
+			ForkPoint:            diff.ForkPoint,
+			Base:                 toBranchDiffData(diff.Base),
+			Compare:              toBranchDiffData(diff.Compare),
+			CostDelta:            diff.Compare.Cost - diff.Base.Cost,
+			DurationDeltaSeconds: diff.Compare.DurationSeconds - diff.Base.DurationSeconds,
+		},
+		ID: req.ID,
+	}
+}
+
+func toBranchDiffData(branch message.Branch) BranchDiffData {
+	messages := make([]MessageData, len(branch.Messages))
+	for i, msg := range branch.Messages {
+		messages[i] = MessageData{
+			ID:                msg.ID,
+			SessionID:         msg.SessionID,
+			Role:              string(msg.Role),
+			Content:           msg.Content().String(),
+			ToolCalls:         toToolCallData(msg.ToolCalls()),
+			ProviderRequestID: msg.ProviderRequestID(),
+			Cost:              msg.Cost(),
+			CostUnknown:       msg.CostUnknown(),
 		}
+	}
+	return BranchDiffData{
+		Messages:        messages,
+		UniqueToolCalls:     toToolCallData(branch.UniqueToolCalls),
+		Cost:                branch.Cost,
+		UnknownCostMessages: branch.UnknownCostMessages,
+		DurationSeconds:     branch.DurationSeconds,
+	}
+}
 
-		// Convert tools to ToolData
-		var toolsData []ToolData
-		for _, tool := range tools {
-			info := tool.Info()
-			// Remove server prefix from tool name for cleaner display
-			toolName := info.Name
-			if strings.Contains(toolName, "_") {
-				parts := strings.SplitN(toolName, "_", 2)
-				if len(parts) > 1 {
-					toolName = parts[1]
-				}
-			}
+func toToolCallData(toolCalls []message.ToolCall) []ToolCallData {
+	result := make([]ToolCallData, len(toolCalls))
+	for i, tc := range toolCalls {
+		result[i] = ToolCallData{
+			ID:       tc.ID,
+			Name:     tc.Name,
+			Input:    tc.Input,
+			Type:     tc.Type,
+			Finished: tc.Finished,
+		}
+	}
+	return result
+}
+
+func (h *QueryHandler) handleSessionsUpdate(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		ID       string    `json:"id"`
+		Title    *string   `json:"title"`
+		Tags     *[]string `json:"tags"`
+		Pinned   *bool     `json:"pinned"`
+		Archived *bool     `json:"archived"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	updated, err := h.app.Sessions.Organize(ctx, params.ID, session.Organization{
+		Title:    params.Title,
+		Tags:     params.Tags,
+		Pinned:   params.Pinned,
+		Archived: params.Archived,
+	})
+	if err != nil {
+		return newOperationError(req, "Failed to update session", err)
+	}
+
+	result := SessionData{
+		ID:                    updated.ID,
+		Title:                 updated.Title,
+		UserMessageCount:      updated.UserMessageCount,
+		AssistantMessageCount: updated.AssistantMessageCount,
+		ToolCallCount:         updated.ToolCallCount,
+		PromptTokens:          updated.PromptTokens,
+		CompletionTokens:      updated.CompletionTokens,
+		Cost:                  updated.Cost,
+		CreatedAt:             time.Unix(updated.CreatedAt, 0),
+		WorkingDirectory:      updated.WorkingDirectory,
+		Tags:                  updated.Tags,
+		Pinned:                updated.Pinned,
+		Archived:              updated.Archived,
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsSetWorkingDirectory(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		ID               string `json:"id"`
+		WorkingDirectory string `json:"workingDirectory"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+	if params.WorkingDirectory == "" {
+		return newMissingParamError(req, "workingDirectory")
+	}
+
+	session, err := h.app.SetSessionWorkingDirectory(ctx, params.ID, params.WorkingDirectory)
+	if err != nil {
+		return newOperationError(req, "Failed to set working directory", err)
+	}
+
+	result := SessionData{
+		ID:                    session.ID,
+		Title:                 session.Title,
+		UserMessageCount:      session.UserMessageCount,
+		AssistantMessageCount: session.AssistantMessageCount,
+		ToolCallCount:         session.ToolCallCount,
+		PromptTokens:          session.PromptTokens,
+		CompletionTokens:      session.CompletionTokens,
+		Cost:                  session.Cost,
+		CreatedAt:             time.Unix(session.CreatedAt, 0),
+		WorkingDirectory:      session.WorkingDirectory,
+		Tags:                  session.Tags,
+		Pinned:                session.Pinned,
+		Archived:              session.Archived,
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleMCPList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	cfg := config.Get()
+
+	var result []MCPServerData
+
+	if len(cfg.MCPServers) == 0 {
+		return &QueryResponse{
+			Result: result, // Empty array
+			ID:     req.ID,
+		}
+	}
+
+	// Get MCP tools to check connection status and group by server
+	// Create temporary manager for informational listing
+	tempManager2 := agent.NewMCPClientManager()
+	defer tempManager2.Close()
+	mcpTools := agent.GetMcpTools(ctx, h.app.Permissions, tempManager2)
+
+	// Group tools by server name
+	serverTools := make(map[string][]tools.BaseTool)
+	for _, tool := range mcpTools {
+		if toolInfo := tool.Info(); strings.Contains(toolInfo.Name, "_") {
+			serverName := strings.Split(toolInfo.Name, "_")[0]
+			serverTools[serverName] = append(serverTools[serverName], tool)
+		}
+	}
+
+	// Sort server names for consistent output
+	var serverNames []string
+	for name := range cfg.MCPServers {
+		serverNames = append(serverNames, name)
+	}
+	sort.Strings(serverNames)
+
+	for _, name := range serverNames {
+		tools := serverTools[name]
+
+		// Determine connection status
+		connected := len(tools) > 0
+		status := "connected"
+		if !connected {
+			status = "failed"
+		}
+
+		// Convert tools to ToolData
+		var toolsData []ToolData
+		for _, tool := range tools {
+			info := tool.Info()
+			// Remove server prefix from tool name for cleaner display
+			toolName := info.Name
+			if strings.Contains(toolName, "_") {
+				parts := strings.SplitN(toolName, "_", 2)
+				if len(parts) > 1 {
+					toolName = parts[1]
+				}
+			}
 			toolsData = append(toolsData, ToolData{
 				Name:        toolName,
 				Description: info.Description,
@@ -729,7 +1845,7 @@ func (h *QueryHandler) handleCommandsGet(ctx context.Context, req *QueryRequest)
 
 	cmd, exists := h.commandRegistry.GetCommand(params.Name)
 	if !exists {
-		return newApplicationError(req, "Command not found: " + params.Name)
+		return newErrorResponse(req, CodeNotFound, "Command not found: " + params.Name)
 	}
 
 	builtins := map[string]bool{
@@ -756,8 +1872,18 @@ func (h *QueryHandler) handleCommandsGet(ctx context.Context, req *QueryRequest)
 
 func (h *QueryHandler) handleMessagesSend(ctx context.Context, req *QueryRequest) *QueryResponse {
 	var params struct {
-		SessionID string `json:"sessionId"`
-		Content   string `json:"content"`
+		SessionID      string   `json:"sessionId"`
+		Content        string   `json:"content"`
+		IdempotencyKey string   `json:"idempotencyKey,omitempty"`
+		Reminders      []string `json:"reminders,omitempty"`
+		DryRun         bool     `json:"dryRun,omitempty"`
+		// Thinking replaces the agent's thinking settings for this turn
+		Thinking *config.Thinking `json:"thinking,omitempty"`
+		// ResponseFormat constrains the final response to JSON
+		ResponseFormat *responseformat.Format `json:"responseFormat,omitempty"`
+		// Queue waits for the session's running turn to finish instead of
+		// failing while it generates
+		Queue bool `json:"queue,omitempty"`
 	}
 
 	if err := json.Unmarshal(req.Params, &params); err != nil {
@@ -772,6 +1898,18 @@ func (h *QueryHandler) handleMessagesSend(ctx context.Context, req *QueryRequest
 		return newMissingParamError(req, "content")
 	}
 
+	if err := reminder.Validate(params.Reminders); err != nil {
+		return newErrorResponse(req, CodeInvalidParams, err.Error())
+	}
+
+	if err := params.Thinking.Validate(); err != nil {
+		return newErrorResponse(req, CodeInvalidParams, err.Error())
+	}
+
+	if err := params.ResponseFormat.Validate(); err != nil {
+		return newErrorResponse(req, CodeInvalidParams, err.Error())
+	}
+
 	// Check authentication status before processing the message using the centralized function
 	authenticated, _, authErr := provider.IsAuthenticated()
 	if authErr != nil {
@@ -800,14 +1938,14 @@ func (h *QueryHandler) handleMessagesSend(ctx context.Context, req *QueryRequest
 	// Set the session as current
 	setSessionErr := h.app.SetCurrentSession(params.SessionID)
 	if setSessionErr != nil {
-		return newApplicationError(req, "Failed to set session: " + setSessionErr.Error())
+		return newOperationError(req, "Failed to set session", setSessionErr)
 	}
 
 	// Check if this is a slash command and handle it immediately
 	if commands.IsSlashCommand(params.Content) {
 		parsed, parseErr := commands.ParseCommand(params.Content)
 		if parseErr != nil {
-			return newErrorResponse(req, -32602, "Invalid slash command: " + parseErr.Error())
+			return newErrorResponse(req, CodeInvalidParams, "Invalid slash command: " + parseErr.Error())
 		}
 
 		logging.Info("Executing command", "name", parsed.Name, "args", parsed.Arguments)
@@ -823,10 +1961,10 @@ func (h *QueryHandler) handleMessagesSend(ctx context.Context, req *QueryRequest
 				commandNames := getCommandNames(allCommands)
 				logging.Info("Available commands", "commands", commandNames)
 
-				return newApplicationError(req, fmt.Sprintf("Command '%s' not found. Available commands: %v", parsed.Name, commandNames))
+				return newErrorResponse(req, CodeNotFound, fmt.Sprintf("Command '%s' not found. Available commands: %v", parsed.Name, commandNames))
 			}
 
-			return newApplicationError(req, "Command execution failed: " + execErr.Error())
+			return newOperationError(req, "Command execution failed", execErr)
 		}
 
 		logging.Info("Command executed successfully", "name", parsed.Name, "result_length", len(commandResult))
@@ -843,14 +1981,36 @@ func (h *QueryHandler) handleMessagesSend(ctx context.Context, req *QueryRequest
 		}
 	}
 
-	// Send message to agent
-	done, err := h.app.CoderAgent.Run(ctx, params.SessionID, params.Content)
+	// A retried request returns the result of the original instead of re-running the agent
+	if params.IdempotencyKey != "" {
+		if response := h.idempotentSendResponse(ctx, req, params.SessionID, params.IdempotencyKey, params.Content, params.ResponseFormat); response != nil {
+			return response
+		}
+	}
+
+	// Send message to agent; the run queue slot is taken once the turn may
+	// start, so a queued follow-up doesn't hold one while it waits
+	done, err := h.app.CoderAgent.RunWithState(ctx, tools.RequestState{
+		SessionID:      params.SessionID,
+		IdempotencyKey: params.IdempotencyKey,
+		Reminders:      params.Reminders,
+		DryRun:         params.DryRun,
+		Thinking:       params.Thinking,
+		ResponseFormat: params.ResponseFormat,
+		Queue:          params.Queue,
+		AcquireRun: func(ctx context.Context) (func(), error) {
+			return h.app.RunQueue.Acquire(ctx, runqueue.Request{Client: runqueue.Client(ctx), SessionID: params.SessionID}, nil)
+		},
+	}, params.Content)
+	if errors.Is(err, agent.ErrIdempotencyKeyQueued) {
+		return newErrorResponse(req, CodeSessionBusy, "Request with this idempotencyKey is still processing")
+	}
 	if err != nil {
-		return newApplicationError(req, "Failed to send message: " + err.Error())
+		return newOperationError(req, "Failed to send message", err)
 	}
 
 	// Wait for response
-	result := <-done
+	result := agent.WaitForResult(done)
 
 	// Check for processing errors
 	if result.Error != nil {
@@ -870,21 +2030,108 @@ func (h *QueryHandler) handleMessagesSend(ctx context.Context, req *QueryRequest
 			}
 		}
 
-		return newApplicationError(req, "Agent processing failed: " + errorMessage)
+		return newOperationError(req, "Agent processing failed", result.Error)
+	}
+
+	return h.sendResult(ctx, req, params.SessionID, params.Content, result.Message, result.Structured)
+}
+
+// sendResult returns the messages.send result of response, the final message
+// of the turn content started.
+func (h *QueryHandler) sendResult(ctx context.Context, req *QueryRequest, sessionID, content string, response message.Message, structured json.RawMessage) *QueryResponse {
+	output, err := h.limitOutput(ctx, sessionID, response.ID, response.Content().String())
+	if err != nil {
+		return newOperationError(req, "Failed to limit response", err)
+	}
+
+	messageData := MessageData{
+		ID:                response.ID,
+		Role:              "user",
+		Content:           content,
+		ProviderRequestID: response.ProviderRequestID(),
+		Structured:        structured,
+	}
+	messageData.limitResponse(output)
+
+	return &QueryResponse{
+		Result: messageData,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleMessagesEdit(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		MessageID string `json:"messageId"`
+		Content   string `json:"content"`
+		// Fork leaves the session unchanged and edits a fork of it instead of
+		// deleting the messages after the edited one
+		Fork bool `json:"fork"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.MessageID == "" {
+		return newMissingParamError(req, "messageId")
+	}
+
+	if params.Content == "" {
+		return newMissingParamError(req, "content")
+	}
+
+	edited, err := h.app.EditMessage(ctx, params.MessageID, params.Content, params.Fork)
+	if err != nil {
+		return newOperationError(req, "Failed to edit message", err)
+	}
+
+	return &QueryResponse{
+		Result: MessageData{
+			ID:        edited.ID,
+			SessionID: edited.SessionID,
+			Role:      string(edited.Role),
+			Content:   edited.Content().String(),
+		},
+		ID: req.ID,
+	}
+}
+
+func (h *QueryHandler) handleMessagesRegenerate(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		MessageID string `json:"messageId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.MessageID == "" {
+		return newMissingParamError(req, "messageId")
+	}
+
+	done, err := h.app.Regenerate(ctx, params.MessageID)
+	if err != nil {
+		return newOperationError(req, "Failed to regenerate", err)
+	}
+
+	result := agent.WaitForResult(done)
+	if result.Error != nil {
+		return newOperationError(req, "Agent processing failed", result.Error)
 	}
 
-	// Extract text content from the response message
-	response := ""
-	if result.Message.Content().String() != "" {
-		response = result.Message.Content().String()
+	output, err := h.limitOutput(ctx, result.Message.SessionID, result.Message.ID, result.Message.Content().String())
+	if err != nil {
+		return newOperationError(req, "Failed to limit response", err)
 	}
 
 	messageData := MessageData{
-		ID:       result.Message.ID,
-		Role:     "user",
-		Content:  params.Content,
-		Response: response,
+		ID:                result.Message.ID,
+		SessionID:         result.Message.SessionID,
+		Role:              string(result.Message.Role),
+		ProviderRequestID: result.Message.ProviderRequestID(),
+		FinishReason:      string(result.Message.FinishReason()),
 	}
+	messageData.limitResponse(output)
 
 	return &QueryResponse{
 		Result: messageData,
@@ -892,6 +2139,79 @@ func (h *QueryHandler) handleMessagesSend(ctx context.Context, req *QueryRequest
 	}
 }
 
+// idempotentSendResponse returns the stored result for a previously seen
+// idempotency key, or nil if the key has not been used in this session or the
+// request that used it failed. A failed request gives up its key, so this one
+// runs it again.
+func (h *QueryHandler) idempotentSendResponse(ctx context.Context, req *QueryRequest, sessionID, key, content string, format *responseformat.Format) *QueryResponse {
+	userMsg, err := h.app.Messages.GetByIdempotencyKey(ctx, sessionID, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return newInternalError(req, err)
+	}
+
+	msgs, err := h.app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return newInternalError(req, err)
+	}
+	response, latest := turnResponse(msgs, userMsg.ID)
+	if latest && h.app.CoderAgent.IsSessionBusy(sessionID) {
+		return newErrorResponse(req, CodeSessionBusy, "Request with this idempotencyKey is still processing")
+	}
+
+	if response != nil && answered(*response) {
+		var structured json.RawMessage
+		if format != nil {
+			structured, err = format.Check(response.Content().Text)
+		}
+		if err == nil {
+			logging.Info("Returning stored result for idempotent request", "sessionID", sessionID, "messageID", response.ID)
+			return h.sendResult(ctx, req, sessionID, content, *response, structured)
+		}
+	}
+
+	logging.Info("Retrying idempotent request that did not complete", "sessionID", sessionID, "messageID", userMsg.ID)
+	if err := h.app.Messages.ReleaseIdempotencyKey(ctx, userMsg.ID); err != nil {
+		return newInternalError(req, err)
+	}
+	return nil
+}
+
+// turnResponse returns the last assistant message of the turn the user message
+// id started, or nil if it has none, and whether that turn is the session's
+// latest. A turn ends at the next user message other than the corrections the
+// agent sends itself, which hold only reminders.
+func turnResponse(msgs []message.Message, id string) (*message.Message, bool) {
+	start := slices.IndexFunc(msgs, func(msg message.Message) bool { return msg.ID == id })
+	if start < 0 {
+		return nil, false
+	}
+	var response *message.Message
+	for i := start + 1; i < len(msgs); i++ {
+		switch msgs[i].Role {
+		case message.User:
+			if !reminder.Only(msgs[i].Content().Text) {
+				return response, false
+			}
+		case message.Assistant:
+			response = &msgs[i]
+		}
+	}
+	return response, true
+}
+
+// answered reports whether msg ended its turn with a response, rather than
+// being cut short by an error, a cancellation, the cost budget or a restart.
+func answered(msg message.Message) bool {
+	switch msg.FinishReason() {
+	case message.FinishReasonEndTurn, message.FinishReasonMaxTokens, message.FinishReasonPermissionDenied, message.FinishReasonUnknown:
+		return true
+	}
+	return false
+}
+
 func (h *QueryHandler) handleMessagesHistory(ctx context.Context, req *QueryRequest) *QueryResponse {
 	var params struct {
 		Limit  int64 `json:"limit,omitempty"`
@@ -909,7 +2229,7 @@ func (h *QueryHandler) handleMessagesHistory(ctx context.Context, req *QueryRequ
 
 	messages, err := h.app.Messages.ListUserMessageHistory(ctx, params.Limit, params.Offset)
 	if err != nil {
-		return newApplicationError(req, "Failed to get message history: " + err.Error())
+		return newOperationError(req, "Failed to get message history", err)
 	}
 
 	var result []MessageData
@@ -944,7 +2264,19 @@ func (h *QueryHandler) handleMessagesHistory(ctx context.Context, req *QueryRequ
 
 func (h *QueryHandler) handleMessagesList(ctx context.Context, req *QueryRequest) *QueryResponse {
 	var params struct {
-		SessionID string `json:"sessionId"`
+		SessionID       string `json:"sessionId"`
+		IncludeThinking bool   `json:"includeThinking"`
+		RenderDiagrams  bool   `json:"renderDiagrams"`
+		// RenderFormat is "svg" or "png", by default render.format
+		RenderFormat string `json:"renderFormat"`
+		// Limit, Offset, Before and Latest select a window of the messages;
+		// without them all are returned
+		Limit  int64  `json:"limit"`
+		Offset int64  `json:"offset"`
+		Before string `json:"before"`
+		Latest bool   `json:"latest"`
+		// Projection is "full" (default) or "summary"
+		Projection string `json:"projection"`
 	}
 
 	if err := json.Unmarshal(req.Params, &params); err != nil {
@@ -954,14 +2286,73 @@ func (h *QueryHandler) handleMessagesList(ctx context.Context, req *QueryRequest
 	if params.SessionID == "" {
 		return newMissingParamError(req, "sessionId")
 	}
+	if params.Limit < 0 || params.Offset < 0 {
+		return newErrorResponse(req, CodeInvalidParams, "limit and offset must not be negative")
+	}
+	if params.Offset > 0 && (params.Before != "" || params.Latest) {
+		return newErrorResponse(req, CodeInvalidParams, "offset can't be combined with before or latest")
+	}
+	switch params.Projection {
+	case "", projectionFull:
+	case projectionSummary:
+		if params.IncludeThinking || params.RenderDiagrams {
+			return newErrorResponse(req, CodeInvalidParams, "includeThinking and renderDiagrams need the full projection")
+		}
+	default:
+		return newErrorResponse(req, CodeInvalidParams, "projection must be full or summary")
+	}
+	if params.Before != "" {
+		before, err := h.app.Messages.Get(ctx, params.Before)
+		if err != nil || before.SessionID != params.SessionID {
+			return newErrorResponse(req, CodeInvalidParams, fmt.Sprintf("message %s not found in session", params.Before))
+		}
+	}
 
-	messages, err := h.app.Messages.List(ctx, params.SessionID)
+	if params.RenderDiagrams {
+		if h.app.Renders == nil {
+			return newApplicationError(req, "Diagram rendering is disabled; set render.enabled in the configuration")
+		}
+		if params.RenderFormat == "" {
+			params.RenderFormat = config.Get().Render.Format
+		}
+		if params.RenderFormat == "" {
+			params.RenderFormat = render.FormatSVG
+		}
+		if params.RenderFormat != render.FormatSVG && params.RenderFormat != render.FormatPNG {
+			return newErrorResponse(req, CodeInvalidParams, "renderFormat must be svg or png")
+		}
+	}
+
+	var messages []message.Message
+	var err error
+	if params.Limit > 0 || params.Offset > 0 || params.Before != "" || params.Latest {
+		page := message.Page{Limit: params.Limit, Offset: params.Offset, Before: params.Before, Latest: params.Latest}
+		messages, err = h.app.Messages.ListPage(ctx, params.SessionID, page, params.IncludeThinking)
+	} else if params.IncludeThinking {
+		messages, err = h.app.Messages.ListWithReasoning(ctx, params.SessionID)
+	} else {
+		messages, err = h.app.Messages.List(ctx, params.SessionID)
+	}
+	if err != nil {
+		return newOperationError(req, "Failed to get messages", err)
+	}
+
+	profile, err := h.OutputProfile(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get output profile", err)
+	}
+
+	annotations, err := h.messageAnnotations(ctx, params.SessionID)
 	if err != nil {
-		return newApplicationError(req, "Failed to get messages: " + err.Error())
+		return newOperationError(req, "Failed to get annotations", err)
 	}
 
 	var result []MessageData
 	for _, msg := range messages {
+		if params.Projection == projectionSummary {
+			result = append(result, newMessageSummaryData(msg, annotations[msg.ID]))
+			continue
+		}
 		// Extract tool calls
 		toolCalls := msg.ToolCalls()
 		toolCallsData := make([]ToolCallData, len(toolCalls))
@@ -975,13 +2366,55 @@ func (h *QueryHandler) handleMessagesList(ctx context.Context, req *QueryRequest
 			}
 		}
 
-		result = append(result, MessageData{
-			ID:        msg.ID,
-			SessionID: msg.SessionID,
-			Role:      string(msg.Role),
-			Content:   msg.Content().String(),
-			ToolCalls: toolCallsData,
-		})
+		reasoning := msg.ReasoningContent()
+		providerName, modelID := msg.AnsweredBy()
+		data := MessageData{
+			ID:                msg.ID,
+			SessionID:         msg.SessionID,
+			Role:              string(msg.Role),
+			Content:           msg.Content().String(),
+			ToolCalls:         toolCallsData,
+			Reasoning:         reasoning.Thinking,
+			ReasoningDuration: reasoning.Duration,
+			ProviderRequestID: msg.ProviderRequestID(),
+			Cost:              msg.Cost(),
+			CostUnknown:       msg.CostUnknown(),
+			Provider:          string(providerName),
+			Model:             string(modelID),
+		}
+		if params.RenderDiagrams && msg.Role == message.Assistant {
+			data.Diagrams = h.renderDiagrams(ctx, msg.Content().String(), params.RenderFormat)
+		}
+		data.Annotation = annotations[msg.ID]
+		if msg.Role != message.Assistant {
+			result = append(result, data)
+			continue
+		}
+		data.FinishReason = string(msg.FinishReason())
+		data.HistoryPruned = newHistoryPrunedData(msg)
+
+		output, err := outputlimit.Apply(profile, msg.SessionID, msg.ID, data.Content)
+		if err != nil {
+			return newOperationError(req, "Failed to limit response", err)
+		}
+		data.Content = output.Parts[0]
+		data.Truncated = output.Truncated
+		data.ArtifactID = output.ArtifactID
+		if len(output.Parts) == 1 {
+			result = append(result, data)
+			continue
+		}
+		// Continuation parts carry only their content; everything else stays
+		// on the first part
+		for i, part := range output.Parts {
+			entry := MessageData{ID: msg.ID, SessionID: msg.SessionID, Role: data.Role, Content: part}
+			if i == 0 {
+				entry = data
+			}
+			entry.Part = i + 1
+			entry.Parts = len(output.Parts)
+			result = append(result, entry)
+		}
 	}
 
 	return &QueryResponse{
@@ -990,7 +2423,48 @@ func (h *QueryHandler) handleMessagesList(ctx context.Context, req *QueryRequest
 	}
 }
 
-func (h *QueryHandler) handleAgentCancel(ctx context.Context, req *QueryRequest) *QueryResponse {
+// newMessageSummaryData returns the summary projection of a message: a preview
+// of its content and counts instead of the content and tool calls.
+func newMessageSummaryData(msg message.Message, annotation *AnnotationData) MessageData {
+	content := []rune(msg.Content().String())
+	preview := content
+	if len(preview) > messagePreviewLength {
+		preview = preview[:messagePreviewLength]
+	}
+	providerName, modelID := msg.AnsweredBy()
+	data := MessageData{
+		ID:            msg.ID,
+		SessionID:     msg.SessionID,
+		Role:          string(msg.Role),
+		Content:       string(preview),
+		ContentLength: len(content),
+		ToolCallCount: len(msg.ToolCalls()),
+		Cost:          msg.Cost(),
+		CostUnknown:   msg.CostUnknown(),
+		Provider:      string(providerName),
+		Model:         string(modelID),
+		Annotation:    annotation,
+	}
+	if msg.Role == message.Assistant {
+		data.FinishReason = string(msg.FinishReason())
+	}
+	return data
+}
+
+// messageAnnotations returns the feedback on a session's messages by message ID.
+func (h *QueryHandler) messageAnnotations(ctx context.Context, sessionID string) (map[string]*AnnotationData, error) {
+	annotations, err := h.app.Annotations.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	byMessage := make(map[string]*AnnotationData, len(annotations))
+	for _, a := range annotations {
+		byMessage[a.MessageID] = newAnnotationData(a)
+	}
+	return byMessage, nil
+}
+
+func (h *QueryHandler) handleMessagesListQueued(ctx context.Context, req *QueryRequest) *QueryResponse {
 	var params struct {
 		SessionID string `json:"sessionId"`
 	}
@@ -1003,11 +2477,351 @@ func (h *QueryHandler) handleAgentCancel(ctx context.Context, req *QueryRequest)
 		return newMissingParamError(req, "sessionId")
 	}
 
-	// Cancel the agent processing for this session
-	h.app.CoderAgent.Cancel(params.SessionID)
+	result := []QueuedMessageData{}
+	for _, queued := range h.app.CoderAgent.ListQueued(params.SessionID) {
+		result = append(result, newQueuedMessageData(queued))
+	}
 
 	return &QueryResponse{
-		Result: map[string]string{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleMessagesRemoveQueued(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId,omitempty"`
+		ID        string `json:"id"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	// The messages.send waiting for it fails as cancelled; the ones queued
+	// after it move up
+	if err := h.app.CoderAgent.RemoveQueued(params.SessionID, params.ID); err != nil {
+		return newOperationError(req, "Failed to remove queued message", err)
+	}
+
+	return &QueryResponse{
+		Result: map[string]string{
+			"status": "removed",
+			"id":     params.ID,
+		},
+		ID: req.ID,
+	}
+}
+
+func newQueuedMessageData(queued agent.QueuedMessage) QueuedMessageData {
+	return QueuedMessageData{
+		ID:        queued.ID,
+		SessionID: queued.SessionID,
+		Content:   queued.Content,
+		Position:  queued.Position,
+		CreatedAt: queued.CreatedAt.Unix(),
+	}
+}
+
+func (h *QueryHandler) handleMessagesAnnotate(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		MessageID string   `json:"messageId"`
+		Rating    string   `json:"rating"`
+		Note      string   `json:"note"`
+		Tags      []string `json:"tags"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.MessageID == "" {
+		return newMissingParamError(req, "messageId")
+	}
+	switch annotation.Rating(params.Rating) {
+	case annotation.RatingNone, annotation.RatingUp, annotation.RatingDown:
+	default:
+		return newErrorResponse(req, CodeInvalidParams, "rating must be up, down or empty")
+	}
+
+	msg, err := h.app.Messages.Get(ctx, params.MessageID)
+	if err != nil {
+		return newOperationError(req, "Failed to get message", err)
+	}
+
+	saved, err := h.app.Annotations.Set(ctx, annotation.Annotation{
+		MessageID: msg.ID,
+		SessionID: msg.SessionID,
+		Rating:    annotation.Rating(params.Rating),
+		Note:      params.Note,
+		Tags:      params.Tags,
+	})
+	if err != nil {
+		return newOperationError(req, "Failed to annotate message", err)
+	}
+
+	return &QueryResponse{
+		Result: newAnnotationData(saved),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsExport(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID       string `json:"sessionId"`
+		IncludeThinking bool   `json:"includeThinking"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	session, err := h.app.Sessions.Get(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+
+	var messages []message.Message
+	if params.IncludeThinking {
+		messages, err = h.app.Messages.ListWithReasoning(ctx, params.SessionID)
+	} else {
+		messages, err = h.app.Messages.List(ctx, params.SessionID)
+	}
+	if err != nil {
+		return newOperationError(req, "Failed to get messages", err)
+	}
+
+	annotations, err := h.messageAnnotations(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get annotations", err)
+	}
+
+	result := SessionExportData{
+		Session: SessionData{
+			ID:                    session.ID,
+			Title:                 session.Title,
+			UserMessageCount:      session.UserMessageCount,
+			AssistantMessageCount: session.AssistantMessageCount,
+			ToolCallCount:         session.ToolCallCount,
+			PromptTokens:          session.PromptTokens,
+			CompletionTokens:      session.CompletionTokens,
+			Cost:                  session.Cost,
+			CreatedAt:             time.Unix(session.CreatedAt, 0),
+			WorkingDirectory:      session.WorkingDirectory,
+			Tags:                  session.Tags,
+			Pinned:                session.Pinned,
+			Archived:              session.Archived,
+		},
+		Messages:   make([]MessageData, 0, len(messages)),
+		ExportedAt: time.Now(),
+	}
+	// Exports keep full responses, whatever the session's output profile
+	for _, msg := range messages {
+		toolCalls := msg.ToolCalls()
+		toolCallsData := make([]ToolCallData, len(toolCalls))
+		for i, tc := range toolCalls {
+			toolCallsData[i] = ToolCallData{
+				ID:       tc.ID,
+				Name:     tc.Name,
+				Input:    tc.Input,
+				Type:     tc.Type,
+				Finished: tc.Finished,
+			}
+		}
+
+		reasoning := msg.ReasoningContent()
+		providerName, modelID := msg.AnsweredBy()
+		data := MessageData{
+			ID:                msg.ID,
+			SessionID:         msg.SessionID,
+			Role:              string(msg.Role),
+			Content:           msg.Content().String(),
+			ToolCalls:         toolCallsData,
+			Reasoning:         reasoning.Thinking,
+			ReasoningDuration: reasoning.Duration,
+			ProviderRequestID: msg.ProviderRequestID(),
+			Cost:              msg.Cost(),
+			CostUnknown:       msg.CostUnknown(),
+			Provider:          string(providerName),
+			Model:             string(modelID),
+			Annotation:        annotations[msg.ID],
+		}
+		if msg.Role == message.Assistant {
+			data.FinishReason = string(msg.FinishReason())
+			data.HistoryPruned = newHistoryPrunedData(msg)
+		}
+		result.Messages = append(result.Messages, data)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+// handleSessionsRender renders a session's transcript in the requested format,
+// Markdown by default.
+func (h *QueryHandler) handleSessionsRender(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID       string `json:"sessionId"`
+		Format          string `json:"format"`
+		IncludeThinking bool   `json:"includeThinking"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+	if params.Format == "" {
+		params.Format = transcript.FormatMarkdown
+	}
+	if !slices.Contains(transcript.Formats, params.Format) {
+		return newErrorResponse(req, CodeInvalidParams, "format must be one of: "+strings.Join(transcript.Formats, ", "))
+	}
+
+	session, content, err := transcript.RenderSession(ctx, h.app.Sessions, h.app.Messages, params.SessionID, transcript.Options{
+		Format:          params.Format,
+		IncludeThinking: params.IncludeThinking,
+	})
+	if err != nil {
+		return newOperationError(req, "Failed to render session", err)
+	}
+
+	return &QueryResponse{
+		Result: SessionRenderData{
+			SessionID: session.ID,
+			Format:    params.Format,
+			Filename:  transcript.Filename(session, params.Format),
+			Content:   content,
+		},
+		ID: req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsShare(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID      string `json:"sessionId"`
+		ExpiresInHours int64  `json:"expiresInHours"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+	if params.ExpiresInHours < 0 || time.Duration(params.ExpiresInHours)*time.Hour > share.MaxTTL {
+		return newErrorResponse(req, CodeInvalidParams, fmt.Sprintf("expiresInHours must be between 1 and %d, or 0 for %d", int64(share.MaxTTL/time.Hour), int64(share.DefaultTTL/time.Hour)))
+	}
+
+	if _, err := h.app.Sessions.Get(ctx, params.SessionID); err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+
+	created, err := h.app.Shares.Create(ctx, params.SessionID, time.Duration(params.ExpiresInHours)*time.Hour)
+	if err != nil {
+		return newOperationError(req, "Failed to share session", err)
+	}
+
+	return &QueryResponse{
+		Result: newShareData(created),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsShares(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	shares, err := h.app.Shares.List(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to list shares", err)
+	}
+
+	result := make([]ShareData, len(shares))
+	for i, s := range shares {
+		result[i] = newShareData(s)
+	}
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsUnshare(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		// ShareID is the share to revoke; empty revokes all of the session's
+		ShareID string `json:"shareId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	revoked := int64(1)
+	var err error
+	if params.ShareID != "" {
+		err = h.app.Shares.Revoke(ctx, params.SessionID, params.ShareID)
+	} else {
+		revoked, err = h.app.Shares.RevokeSession(ctx, params.SessionID)
+	}
+	if err != nil {
+		return newOperationError(req, "Failed to revoke share", err)
+	}
+
+	return &QueryResponse{
+		Result: map[string]interface{}{
+			"sessionId": params.SessionID,
+			"revoked":   revoked,
+		},
+		ID: req.ID,
+	}
+}
+
+func (h *QueryHandler) handleAgentCancel(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	// Cancel the agent processing for this session
+	h.app.CoderAgent.Cancel(params.SessionID)
+
+	return &QueryResponse{
+		Result: map[string]string{
 			"status":    "cancelled",
 			"sessionId": params.SessionID,
 		},
@@ -1015,6 +2829,34 @@ func (h *QueryHandler) handleAgentCancel(ctx context.Context, req *QueryRequest)
 	}
 }
 
+func (h *QueryHandler) handleToolsCancel(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID  string `json:"sessionId,omitempty"`
+		ToolCallID string `json:"toolCallId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ToolCallID == "" {
+		return newMissingParamError(req, "toolCallId")
+	}
+
+	// Only this call is canceled; the turn continues with its canceled result
+	if err := h.app.CoderAgent.CancelToolCall(params.SessionID, params.ToolCallID); err != nil {
+		return newOperationError(req, "Failed to cancel tool call", err)
+	}
+
+	return &QueryResponse{
+		Result: map[string]string{
+			"status":     "cancelled",
+			"toolCallId": params.ToolCallID,
+		},
+		ID: req.ID,
+	}
+}
+
 func (h *QueryHandler) handleSessionsDelete(ctx context.Context, req *QueryRequest) *QueryResponse {
 	var params struct {
 		ID string `json:"id"`
@@ -1037,8 +2879,9 @@ func (h *QueryHandler) handleSessionsDelete(ctx context.Context, req *QueryReque
 	// Delete the session
 	err := h.app.Sessions.Delete(ctx, params.ID)
 	if err != nil {
-		return newApplicationError(req, "Failed to delete session: " + err.Error())
+		return newOperationError(req, "Failed to delete session", err)
 	}
+	h.app.StreamTokens.RevokeSession(params.ID)
 
 	return &QueryResponse{
 		Result: map[string]string{"message": "Session deleted: " + params.ID},
@@ -1097,3 +2940,1542 @@ func (h *QueryHandler) handlePermissionDeny(ctx context.Context, req *QueryReque
 		ID: req.ID,
 	}
 }
+
+func (h *QueryHandler) handleStreamToken(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID  string `json:"sessionId"`
+		Scope      string `json:"scope"`
+		TTLSeconds int64  `json:"ttlSeconds"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+	if params.Scope == "" {
+		params.Scope = string(streamtoken.ScopeRead)
+	}
+
+	if _, err := h.app.Sessions.Get(ctx, params.SessionID); err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+
+	token, err := h.app.StreamTokens.Issue(params.SessionID, streamtoken.Scope(params.Scope), time.Duration(params.TTLSeconds)*time.Second)
+	if err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	return &QueryResponse{
+		Result: StreamTokenData{
+			Token:     token.Value,
+			SessionID: token.SessionID,
+			Scope:     string(token.Scope),
+			ExpiresAt: token.ExpiresAt,
+		},
+		ID: req.ID,
+	}
+}
+
+func (h *QueryHandler) handleAuditList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string     `json:"sessionId"`
+		ToolName  string     `json:"toolName"`
+		Since     *time.Time `json:"since"`
+		Until     *time.Time `json:"until"`
+		Limit     int64      `json:"limit"`
+		Offset    int64      `json:"offset"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	filter := audit.ListFilter{
+		SessionID: params.SessionID,
+		ToolName:  params.ToolName,
+		Limit:     params.Limit,
+		Offset:    params.Offset,
+	}
+	if params.Since != nil {
+		filter.Since = *params.Since
+	}
+	if params.Until != nil {
+		filter.Until = *params.Until
+	}
+
+	entries, err := h.app.Audits.List(ctx, filter)
+	if err != nil {
+		return newOperationError(req, "Failed to list audit entries", err)
+	}
+
+	result := make([]AuditEntryData, len(entries))
+	for i, entry := range entries {
+		result[i] = toAuditEntryData(entry)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleAuditGet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	entry, err := h.app.Audits.Get(ctx, params.ID)
+	if err != nil {
+		return newOperationError(req, "Failed to get audit entry", err)
+	}
+
+	return &QueryResponse{
+		Result: toAuditEntryData(entry),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleArtifactGet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		ID        string `json:"id"`
+		Offset    int64  `json:"offset"`
+		Limit     int64  `json:"limit"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	chunk, err := artifact.Read(params.SessionID, params.ID, params.Offset, params.Limit)
+	if err != nil {
+		return newOperationError(req, "Failed to get artifact", err)
+	}
+
+	return &QueryResponse{
+		Result: ArtifactData{
+			ID:      chunk.ID,
+			Content: chunk.Content,
+			Offset:  chunk.Offset,
+			Size:    chunk.Size,
+			HasMore: chunk.More(),
+		},
+		ID: req.ID,
+	}
+}
+
+// renderDiagrams renders the diagram blocks in content, reporting failures
+// per block so one bad diagram doesn't hide the others.
+func (h *QueryHandler) renderDiagrams(ctx context.Context, content, format string) []DiagramData {
+	var diagrams []DiagramData
+	for _, block := range render.Blocks(content) {
+		diagram := DiagramData{Index: block.Index, Language: block.Language}
+		if name, err := h.app.Renders.Render(ctx, block, format); err != nil {
+			diagram.Error = err.Error()
+		} else {
+			diagram.URL = render.URL(name)
+		}
+		diagrams = append(diagrams, diagram)
+	}
+	return diagrams
+}
+
+func (h *QueryHandler) handleArtifactsList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		// Sync uploads new and changed files before listing
+		Sync bool `json:"sync"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	sess, err := h.app.Sessions.Get(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Session not found", err)
+	}
+
+	if params.Sync {
+		if err := h.app.Assets.Sync(ctx, sess.ID, sess.WorkingDirectory); err != nil {
+			return newOperationError(req, "Failed to upload artifacts", err)
+		}
+	}
+
+	stored, err := h.app.Assets.List(ctx, sess.ID, sess.WorkingDirectory)
+	if err != nil {
+		return newOperationError(req, "Failed to list artifacts", err)
+	}
+
+	result := make([]SessionArtifactData, 0, len(stored))
+	for _, asset := range stored {
+		data := SessionArtifactData{
+			Path:     asset.RelPath,
+			Size:     asset.Size,
+			Local:    asset.Local,
+			Location: asset.Location,
+		}
+		if !asset.UploadedAt.IsZero() {
+			data.UploadedAt = asset.UploadedAt.Unix()
+			if url, err := h.app.Assets.SignedURL(ctx, asset.Path); err == nil {
+				data.URL = url
+			}
+		}
+		result = append(result, data)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleAssetsList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		// Category limits the gallery to "image", "video" or "audio"
+		Category string `json:"category"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	switch session.FileTypeCategory(params.Category) {
+	case "", session.CategoryImage, session.CategoryVideo, session.CategoryAudio:
+	default:
+		return newInvalidParamsError(req, fmt.Errorf("invalid category %q: must be image, video or audio", params.Category))
+	}
+
+	sess, err := h.app.Sessions.Get(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Session not found", err)
+	}
+
+	msgs, err := h.app.Messages.List(ctx, sess.ID)
+	if err != nil {
+		return newOperationError(req, "Failed to list messages", err)
+	}
+
+	items, err := gallery.List(ctx, sess.WorkingDirectory, msgs)
+	if err != nil {
+		return newOperationError(req, "Failed to list assets", err)
+	}
+
+	result := make([]GalleryItemData, 0, len(items))
+	for _, item := range items {
+		if params.Category != "" && string(item.Category) != params.Category {
+			continue
+		}
+		url := ""
+		if item.RelPath != item.Path {
+			url = session.AssetURL(sess.ID, item.RelPath)
+		}
+		result = append(result, GalleryItemData{
+			Path:          item.RelPath,
+			URL:           url,
+			Category:      string(item.Category),
+			Size:          item.Size,
+			ModifiedAt:    item.ModifiedAt.Unix(),
+			Tool:          item.Tool,
+			MessageID:     item.MessageID,
+			Width:         item.Metadata.Width,
+			Height:        item.Metadata.Height,
+			Duration:      item.Metadata.Duration,
+			Format:        item.Metadata.Format,
+			VideoCodec:    item.Metadata.VideoCodec,
+			AudioCodec:    item.Metadata.AudioCodec,
+			MetadataError: item.Metadata.Error,
+		})
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func toAuditEntryData(entry audit.Entry) AuditEntryData {
+	return AuditEntryData{
+		ID:                 entry.ID,
+		SessionID:          entry.SessionID,
+		MessageID:          entry.MessageID,
+		ToolCallID:         entry.ToolCallID,
+		ToolName:           entry.ToolName,
+		Input:              entry.Input,
+		Output:             entry.Output,
+		OutputTruncated:    entry.OutputTruncated,
+		IsError:            entry.IsError,
+		Error:              entry.Error,
+		PermissionDecision: entry.PermissionDecision,
+		DurationMs:         entry.Duration.Milliseconds(),
+		Cost:               entry.Cost,
+		CreatedAt:          time.Unix(entry.CreatedAt, 0),
+	}
+}
+
+func (h *QueryHandler) handleMessagesEstimate(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		Content   string `json:"content"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	session, err := h.app.Sessions.Get(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+
+	estimate, err := h.app.CoderAgent.Estimate(ctx, params.SessionID, params.Content)
+	if err != nil {
+		return newOperationError(req, "Failed to estimate turn", err)
+	}
+
+	fallback := make([]string, len(estimate.Fallback))
+	for i, modelID := range estimate.Fallback {
+		fallback[i] = string(modelID)
+	}
+	maxSessionCost := config.Get().MaxSessionCost
+	result := TurnEstimateData{
+		SessionID:             session.ID,
+		Model:                 string(estimate.Model.ID),
+		ModelName:             estimate.Model.Name,
+		Provider:              string(estimate.Model.Provider),
+		Fallback:              fallback,
+		InputTokens:           estimate.InputTokens,
+		SystemTokens:          estimate.SystemTokens,
+		ToolTokens:            estimate.ToolTokens,
+		HistoryTokens:         estimate.HistoryTokens,
+		PinnedTokens:          estimate.PinnedTokens,
+		DraftTokens:           estimate.DraftTokens,
+		MaxOutputTokens:       estimate.MaxOutputTokens,
+		MinCost:               estimate.MinCost,
+		MaxCost:               estimate.MaxCost,
+		ContextWindow:         estimate.ContextWindow,
+		ContextUsagePercent:   estimate.ContextUsage * 100,
+		ExceedsContext:        estimate.ExceedsContext,
+		CompactionRecommended: estimate.CompactionRecommended,
+		SessionCost:           session.Cost,
+		MayExceedBudget:       maxSessionCost > 0 && session.Cost+estimate.MaxCost > maxSessionCost,
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleMessagesPreview(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string   `json:"sessionId"`
+		Content   string   `json:"content"`
+		PlanMode  bool     `json:"planMode"`
+		Reminders []string `json:"reminders"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	if err := reminder.Validate(params.Reminders); err != nil {
+		return newErrorResponse(req, CodeInvalidParams, err.Error())
+	}
+
+	preview, err := h.app.CoderAgent.Preview(ctx, tools.RequestState{
+		SessionID: params.SessionID,
+		PlanMode:  params.PlanMode,
+		Reminders: params.Reminders,
+	}, params.Content)
+	if err != nil {
+		return newOperationError(req, "Failed to preview turn", err)
+	}
+
+	reminders := make([]ReminderData, len(preview.Reminders))
+	for i, r := range preview.Reminders {
+		reminders[i] = ReminderData{Name: r.Name, Source: r.Source, Order: r.Order, Text: r.Text}
+	}
+	return &QueryResponse{
+		Result: PromptPreviewData{
+			SessionID:    params.SessionID,
+			Model:        string(preview.Model.ID),
+			SystemPrompt: preview.SystemPrompt,
+			Tools:        preview.Tools,
+			Reminders:    reminders,
+			UserMessage:  preview.UserMessage,
+			PinnedContext: preview.PinnedContext,
+		},
+		ID: req.ID,
+	}
+}
+
+func (h *QueryHandler) handleUsageReport(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	session, err := h.app.Sessions.Get(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+
+	usage, err := h.app.Sessions.CacheUsage(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get cache usage", err)
+	}
+
+	toolCosts, err := h.app.Audits.ToolCosts(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get tool costs", err)
+	}
+
+	messages, err := h.app.Messages.List(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to list messages", err)
+	}
+
+	thinking := config.Get().Agents[config.AgentMain].Thinking.Resolved()
+	result := UsageReportData{
+		SessionID:           session.ID,
+		PromptTokens:        session.PromptTokens,
+		CompletionTokens:    session.CompletionTokens,
+		Cost:                session.Cost,
+		Tools:               make([]ToolCostData, len(toolCosts)),
+		Requests:            usage.Requests,
+		InputTokens:         usage.InputTokens,
+		CacheCreationTokens: usage.CacheCreationTokens,
+		CacheReadTokens:     usage.CacheReadTokens,
+		CacheHitRate:        usage.HitRate(),
+		Thinking: ThinkingData{
+			Mode:            thinking.Mode,
+			Budget:          thinking.Budget,
+			MaxBudget:       thinking.MaxBudget,
+			Requests:        usage.ThinkingRequests,
+			BudgetTokens:    usage.ThinkingBudgetTokens,
+			ToolFailureRate: provider.ToolFailureRate(messages),
+		},
+	}
+	for i, toolCost := range toolCosts {
+		result.Tools[i] = ToolCostData{
+			ToolName: toolCost.ToolName,
+			Calls:    toolCost.Calls,
+			Cost:     toolCost.Cost,
+		}
+		result.ToolCost += toolCost.Cost
+	}
+	result.LLMCost = max(session.Cost-result.ToolCost, 0)
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleToolsList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	var disabled []string
+	if params.SessionID != "" {
+		var err error
+		disabled, err = h.app.Sessions.DisabledTools(ctx, params.SessionID)
+		if err != nil {
+			return newOperationError(req, "Failed to get session tools", err)
+		}
+	}
+
+	return &QueryResponse{
+		Result: h.sessionTools(disabled),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleToolsStats(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string     `json:"sessionId"`
+		Since     *time.Time `json:"since"`
+		Until     *time.Time `json:"until"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	filter := toolstats.Filter{SessionID: params.SessionID}
+	if params.Since != nil {
+		filter.Since = *params.Since
+	}
+	if params.Until != nil {
+		filter.Until = *params.Until
+	}
+
+	report, err := h.app.ToolStats.Report(ctx, filter)
+	if err != nil {
+		return newOperationError(req, "Failed to get tool stats", err)
+	}
+
+	return &QueryResponse{
+		Result: ToolStatsData{SessionID: params.SessionID, Report: report},
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsToolsSet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string   `json:"sessionId"`
+		Enable    []string `json:"enable"`
+		Disable   []string `json:"disable"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	known := make(map[string]bool)
+	for _, tool := range h.app.CoderAgent.Tools() {
+		known[tool.Info().Name] = true
+	}
+	for _, name := range append(append([]string{}, params.Enable...), params.Disable...) {
+		if !known[name] {
+			return newErrorResponse(req, CodeInvalidParams, "Unknown tool: "+name)
+		}
+	}
+
+	disabled, err := h.app.Sessions.SetToolsEnabled(ctx, params.SessionID, params.Enable, params.Disable)
+	if err != nil {
+		return newOperationError(req, "Failed to set session tools", err)
+	}
+
+	return &QueryResponse{
+		Result: h.sessionTools(disabled),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsRemindersSet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string   `json:"sessionId"`
+		Add       []string `json:"add"`
+		Remove    []string `json:"remove"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	// Reminders removed from the config can still be detached
+	if err := reminder.Validate(params.Add); err != nil {
+		return newErrorResponse(req, CodeInvalidParams, err.Error())
+	}
+
+	reminders, err := h.app.Sessions.SetReminders(ctx, params.SessionID, params.Add, params.Remove)
+	if err != nil {
+		return newOperationError(req, "Failed to set session reminders", err)
+	}
+
+	return &QueryResponse{
+		Result: SessionRemindersData{SessionID: params.SessionID, Reminders: reminders},
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsEnvGet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	if _, err := h.app.Sessions.Get(ctx, params.SessionID); err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+	env, err := h.app.Sessions.Env(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get session environment", err)
+	}
+
+	return &QueryResponse{
+		Result: SessionEnvData{SessionID: params.SessionID, Env: env},
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsEnvSet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string            `json:"sessionId"`
+		Set       map[string]string `json:"set"`
+		Unset     []string          `json:"unset"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	env, err := h.app.Sessions.SetEnv(ctx, params.SessionID, params.Set, params.Unset)
+	if err != nil {
+		return newOperationError(req, "Failed to set session environment", err)
+	}
+
+	return &QueryResponse{
+		Result: SessionEnvData{SessionID: params.SessionID, Env: env},
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsOutputProfileSet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		// Profile is the name of a configured output profile, "" for none
+		Profile string `json:"profile"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	profile, ok := config.Get().OutputProfile(params.Profile)
+	if params.Profile != "" && !ok {
+		return newErrorResponse(req, CodeInvalidParams, "Unknown output profile: "+params.Profile)
+	}
+
+	if err := h.app.Sessions.SetOutputProfile(ctx, params.SessionID, params.Profile); err != nil {
+		return newOperationError(req, "Failed to set output profile", err)
+	}
+
+	return &QueryResponse{
+		Result: OutputProfileData{
+			SessionID: params.SessionID,
+			Profile:   profile.Name,
+			MaxBytes:  profile.MaxBytes,
+			Mode:      profile.Mode,
+		},
+		ID: req.ID,
+	}
+}
+
+func (h *QueryHandler) handleSessionsSandboxSet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		// Profile is the name of a configured sandbox profile, "" for the
+		// default
+		Profile string `json:"profile"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	cfg := config.Get()
+	if _, ok := cfg.SandboxProfile(params.Profile); params.Profile != "" && !ok {
+		return newErrorResponse(req, CodeInvalidParams, "Unknown sandbox profile: "+params.Profile)
+	}
+
+	if err := h.app.Sessions.SetSandboxProfile(ctx, params.SessionID, params.Profile); err != nil {
+		return newOperationError(req, "Failed to set sandbox profile", err)
+	}
+
+	effective := params.Profile
+	if effective == "" {
+		effective = cfg.DefaultSandboxProfile
+	}
+	return &QueryResponse{
+		Result: SandboxProfileData{
+			SessionID: params.SessionID,
+			Profile:   params.Profile,
+			Effective: effective,
+		},
+		ID: req.ID,
+	}
+}
+
+// OutputProfile returns the session's output profile, or the zero profile
+// when responses are unlimited. A profile removed from the configuration is
+// treated as none.
+func (h *QueryHandler) OutputProfile(ctx context.Context, sessionID string) (config.OutputProfile, error) {
+	name, err := h.app.Sessions.OutputProfile(ctx, sessionID)
+	if err != nil || name == "" {
+		return config.OutputProfile{}, err
+	}
+	profile, ok := config.Get().OutputProfile(name)
+	if !ok {
+		logging.Warn("Session output profile is not configured, returning responses unlimited", "session", sessionID, "profile", name)
+	}
+	return profile, nil
+}
+
+// limitOutput shapes an assistant response to the session's output profile.
+func (h *QueryHandler) limitOutput(ctx context.Context, sessionID, messageID, content string) (outputlimit.Output, error) {
+	profile, err := h.OutputProfile(ctx, sessionID)
+	if err != nil {
+		return outputlimit.Output{}, err
+	}
+	return outputlimit.Apply(profile, sessionID, messageID, content)
+}
+
+func (h *QueryHandler) handleSessionsLocaleSet(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		// Locale of built-in command responses, "" for the configured one
+		Locale string `json:"locale"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	if params.Locale != "" && !i18n.Supported(params.Locale) {
+		return newErrorResponse(req, CodeInvalidParams, "Unsupported locale: "+params.Locale+" (available: "+strings.Join(i18n.Locales(), ", ")+")")
+	}
+
+	if err := h.app.Sessions.SetLocale(ctx, params.SessionID, params.Locale); err != nil {
+		return newOperationError(req, "Failed to set locale", err)
+	}
+
+	return &QueryResponse{
+		Result: SessionLocaleData{SessionID: params.SessionID, Locale: params.Locale},
+		ID:     req.ID,
+	}
+}
+
+// sessionTools lists the agent's tools sorted by name, marking the disabled ones.
+func (h *QueryHandler) sessionTools(disabled []string) []SessionToolData {
+	off := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		off[name] = true
+	}
+	agentTools := h.app.CoderAgent.Tools()
+	result := make([]SessionToolData, len(agentTools))
+	for i, tool := range agentTools {
+		info := tool.Info()
+		result[i] = SessionToolData{
+			Name:        info.Name,
+			Description: info.Description,
+			Enabled:     !off[info.Name],
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func templateData(template sessiontemplate.Template) TemplateData {
+	data := TemplateData{
+		Name:             template.Name,
+		Description:      template.Description,
+		SystemPrompt:     template.SystemPrompt,
+		EnabledTools:     template.EnabledTools,
+		Model:            template.Model,
+		WorkingDirectory: template.WorkingDirectory,
+		Directories:      template.Directories,
+		CreatedAt:        template.CreatedAt,
+	}
+	if data.EnabledTools == nil {
+		data.EnabledTools = []string{}
+	}
+	if data.Directories == nil {
+		data.Directories = []string{}
+	}
+	return data
+}
+
+func (h *QueryHandler) handleTemplatesCreate(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		Name             string   `json:"name"`
+		Description      string   `json:"description,omitempty"`
+		SystemPrompt     string   `json:"systemPrompt,omitempty"`
+		EnabledTools     []string `json:"enabledTools,omitempty"`
+		Model            string   `json:"model,omitempty"`
+		WorkingDirectory string   `json:"workingDirectory,omitempty"`
+		Directories      []string `json:"directories,omitempty"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.Name == "" {
+		return newMissingParamError(req, "name")
+	}
+
+	template, err := h.app.CreateTemplate(ctx, sessiontemplate.Template{
+		Name:             params.Name,
+		Description:      params.Description,
+		SystemPrompt:     params.SystemPrompt,
+		EnabledTools:     params.EnabledTools,
+		Model:            params.Model,
+		WorkingDirectory: params.WorkingDirectory,
+		Directories:      params.Directories,
+	})
+	if err != nil {
+		return newOperationError(req, "Failed to create template", err)
+	}
+
+	return &QueryResponse{
+		Result: templateData(template),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleTemplatesList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	templates, err := h.app.Templates.List(ctx)
+	if err != nil {
+		return newOperationError(req, "Failed to list templates", err)
+	}
+
+	result := make([]TemplateData, len(templates))
+	for i, template := range templates {
+		result[i] = templateData(template)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleTemplatesApply(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		Name  string `json:"name"`
+		Title string `json:"title,omitempty"`
+		// WorkingDirectory overrides the template's working directory
+		WorkingDirectory string `json:"workingDirectory,omitempty"`
+		SetCurrent       bool   `json:"setCurrent,omitempty"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.Name == "" {
+		return newMissingParamError(req, "name")
+	}
+
+	session, err := h.app.ApplyTemplate(ctx, params.Name, params.Title, params.WorkingDirectory)
+	if err != nil {
+		return newOperationError(req, "Failed to apply template", err)
+	}
+
+	if params.SetCurrent {
+		if err := h.app.SetCurrentSession(session.ID); err != nil {
+			return newOperationError(req, "Session created but failed to set as current", err)
+		}
+	}
+
+	result := SessionData{
+		ID:                    session.ID,
+		Title:                 session.Title,
+		UserMessageCount:      session.UserMessageCount,
+		AssistantMessageCount: session.AssistantMessageCount,
+		ToolCallCount:         session.ToolCallCount,
+		PromptTokens:          session.PromptTokens,
+		CompletionTokens:      session.CompletionTokens,
+		Cost:                  session.Cost,
+		CreatedAt:             time.Unix(session.CreatedAt, 0),
+		WorkingDirectory:      session.WorkingDirectory,
+		Tags:                  session.Tags,
+		Pinned:                session.Pinned,
+		Archived:              session.Archived,
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleTemplatesDelete(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.Name == "" {
+		return newMissingParamError(req, "name")
+	}
+
+	if err := h.app.Templates.Delete(ctx, params.Name); err != nil {
+		return newOperationError(req, "Failed to delete template", err)
+	}
+
+	return &QueryResponse{
+		Result: map[string]string{"message": "Template deleted: " + params.Name},
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleModelsRefresh(ctx context.Context, req *QueryRequest) *QueryResponse {
+	catalog, failed, err := config.RefreshModelCatalog(ctx)
+	if err != nil {
+		return newOperationError(req, "Failed to refresh models", err)
+	}
+
+	result := ModelCatalogData{
+		RefreshedAt: catalog.RefreshedAt.Unix(),
+		Models:      make([]ModelData, len(catalog.Models)),
+	}
+	for i, model := range catalog.Models {
+		result.Models[i] = ModelData{
+			ID:                  string(model.ID),
+			Name:                model.Name,
+			Provider:            string(model.Provider),
+			APIModel:            model.APIModel,
+			CostPer1MIn:         model.CostPer1MIn,
+			CostPer1MOut:        model.CostPer1MOut,
+			ContextWindow:       model.ContextWindow,
+			CanReason:           model.CanReason,
+			SupportsAttachments: model.SupportsAttachments,
+		}
+	}
+	if len(failed) > 0 {
+		result.Errors = make(map[string]string, len(failed))
+		for provider, err := range failed {
+			result.Errors[string(provider)] = err.Error()
+		}
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handlePermissionListPending(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId,omitempty"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	result := []PendingPermissionData{}
+	for _, pending := range h.app.Permissions.ListPending() {
+		if params.SessionID != "" && pending.SessionID != params.SessionID {
+			continue
+		}
+		result = append(result, PendingPermissionData{
+			ID:          pending.ID,
+			SessionID:   pending.SessionID,
+			ToolName:    pending.ToolName,
+			Description: pending.Description,
+			Action:      pending.Action,
+			Path:        pending.Path,
+			Params:      pending.Params,
+			CreatedAt:   pending.CreatedAt.Unix(),
+			AgeSeconds:  time.Since(pending.CreatedAt).Seconds(),
+		})
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleQuestionsAnswer(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		ID     string `json:"id"`
+		Answer string `json:"answer"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	answered, err := h.app.Questions.Answer(params.ID, params.Answer)
+	if errors.Is(err, question.ErrInvalidAnswer) {
+		return newErrorResponse(req, CodeInvalidParams, err.Error())
+	}
+	if err != nil {
+		return newOperationError(req, "Failed to answer question", err)
+	}
+
+	return &QueryResponse{
+		Result: newQuestionData(answered),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleQuestionsListPending(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId,omitempty"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	result := []QuestionData{}
+	for _, pending := range h.app.Questions.ListPending() {
+		if params.SessionID != "" && pending.SessionID != params.SessionID {
+			continue
+		}
+		data := newQuestionData(pending)
+		data.AgeSeconds = time.Since(pending.CreatedAt).Seconds()
+		result = append(result, data)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func newQuestionData(q question.Question) QuestionData {
+	return QuestionData{
+		ID:            q.ID,
+		SessionID:     q.SessionID,
+		ToolCallID:    q.ToolCallID,
+		Question:      q.Question,
+		Options:       q.Options,
+		AllowFreeText: q.AllowFreeText,
+		CreatedAt:     q.CreatedAt.Unix(),
+		Resolution:    string(q.Resolution),
+		Answer:        q.Answer,
+	}
+}
+
+func (h *QueryHandler) handleSessionsTree(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	nodes, err := h.app.SessionTree(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get session tree", err)
+	}
+
+	result := SessionTreeData{
+		SessionID: params.SessionID,
+		RootID:    nodes[0].Session.ID,
+		Nodes:     make([]SessionTreeNodeData, len(nodes)),
+	}
+	for i, node := range nodes {
+		children := node.Children
+		if children == nil {
+			children = []string{}
+		}
+		result.Nodes[i] = SessionTreeNodeData{
+			ID:               node.Session.ID,
+			ParentID:         node.Session.ParentSessionID,
+			Title:            node.Session.Title,
+			CreatedAt:        time.Unix(node.Session.CreatedAt, 0),
+			ForkMessageIndex: node.ForkPoint,
+			MessageCount:     node.MessageCount,
+			BranchCost:       node.BranchCost,
+			Children:         children,
+		}
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func jobData(job jobs.Job) JobData {
+	data := JobData{
+		Name:             job.Name,
+		Schedule:         job.Schedule,
+		Prompt:           job.Prompt,
+		Template:         job.Template,
+		WorkingDirectory: job.WorkingDirectory,
+		CreatedAt:        job.CreatedAt,
+	}
+	if schedule, err := jobs.ParseSchedule(job.Schedule); err == nil {
+		data.NextRunAt = schedule.Next(time.Now()).Unix()
+	}
+	if job.LastRun != nil {
+		data.LastRun = &JobRunData{
+			SessionID: job.LastRun.SessionID,
+			StartedAt: job.LastRun.StartedAt.Unix(),
+			Status:    job.LastRun.Status,
+			Error:     job.LastRun.Error,
+		}
+	}
+	return data
+}
+
+func (h *QueryHandler) handleJobsCreate(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		Name     string `json:"name"`
+		Schedule string `json:"schedule"`
+		Prompt   string `json:"prompt"`
+		// Template is the session template runs start from
+		Template         string `json:"template,omitempty"`
+		WorkingDirectory string `json:"workingDirectory,omitempty"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.Name == "" {
+		return newMissingParamError(req, "name")
+	}
+	if params.Schedule == "" {
+		return newMissingParamError(req, "schedule")
+	}
+	if params.Prompt == "" {
+		return newMissingParamError(req, "prompt")
+	}
+
+	job, err := h.app.CreateJob(ctx, jobs.Job{
+		Name:             params.Name,
+		Schedule:         params.Schedule,
+		Prompt:           params.Prompt,
+		Template:         params.Template,
+		WorkingDirectory: params.WorkingDirectory,
+	})
+	if err != nil {
+		return newOperationError(req, "Failed to create job", err)
+	}
+
+	return &QueryResponse{
+		Result: jobData(job),
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleJobsList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	list, err := h.app.Jobs.List(ctx)
+	if err != nil {
+		return newOperationError(req, "Failed to list jobs", err)
+	}
+
+	result := make([]JobData, len(list))
+	for i, job := range list {
+		result[i] = jobData(job)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleJobsDelete(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.Name == "" {
+		return newMissingParamError(req, "name")
+	}
+
+	if err := h.app.Jobs.Delete(ctx, params.Name); err != nil {
+		return newOperationError(req, "Failed to delete job", err)
+	}
+
+	return &QueryResponse{
+		Result: map[string]string{"message": "Job deleted: " + params.Name},
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleCheckpointsList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	list, err := h.app.Checkpoints.List(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to list checkpoints", err)
+	}
+
+	result := make([]CheckpointData, len(list))
+	for i, c := range list {
+		result[i] = checkpointData(c)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+// handleCheckpointsRestore restores the checkpoint with the given id, or the
+// session's newest one not restored yet when only sessionId is given.
+func (h *QueryHandler) handleCheckpointsRestore(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		ID        string `json:"id"`
+		SessionID string `json:"sessionId"`
+		Force     bool   `json:"force"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" && params.SessionID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	sessionID := params.SessionID
+	if params.ID != "" {
+		c, err := h.app.Checkpoints.Get(ctx, params.ID)
+		if err != nil {
+			return newOperationError(req, "Failed to get checkpoint", err)
+		}
+		sessionID = c.SessionID
+	}
+
+	// The running turn may still be writing the files
+	if h.app.CoderAgent.IsSessionBusy(sessionID) {
+		return newErrorResponse(req, CodeSessionBusy, "Session is busy, cancel the running request before restoring a checkpoint")
+	}
+
+	var restore checkpoint.Restore
+	var err error
+	if params.ID != "" {
+		restore, err = h.app.Checkpoints.Restore(ctx, params.ID, params.Force)
+	} else {
+		restore, err = h.app.Checkpoints.Undo(ctx, sessionID, params.Force)
+	}
+	if err != nil {
+		return newOperationError(req, "Failed to restore checkpoint", err)
+	}
+
+	result := CheckpointRestoreData{
+		Checkpoints: make([]CheckpointData, len(restore.Checkpoints)),
+		Restored:    restore.Restored,
+		Deleted:     restore.Deleted,
+	}
+	for i, c := range restore.Checkpoints {
+		result.Checkpoints[i] = checkpointData(c)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+// handleContextPin keeps a file or message in the session's context, ahead of
+// the conversation in every request.
+func (h *QueryHandler) handleContextPin(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		Kind      string `json:"kind"`
+		Target    string `json:"target"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+	if params.Target == "" {
+		return newMissingParamError(req, "target")
+	}
+	kind := pin.Kind(params.Kind)
+	if kind != pin.KindFile && kind != pin.KindMessage {
+		return newErrorResponse(req, CodeInvalidParams, "kind must be \"file\" or \"message\"")
+	}
+
+	sess, err := h.app.Sessions.Get(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+
+	p, err := h.app.Pins.Pin(ctx, sess.ID, sess.WorkingDirectory, kind, params.Target)
+	if err != nil {
+		return newOperationError(req, "Failed to pin", err)
+	}
+
+	contents, err := h.app.Pins.Contents(ctx, sess.ID, sess.WorkingDirectory)
+	if err != nil {
+		return newOperationError(req, "Failed to read pins", err)
+	}
+	result := contextPinData(pin.Content{Pin: p})
+	for _, c := range contents {
+		if c.ID == p.ID {
+			result = contextPinData(c)
+		}
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleContextList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.SessionID == "" {
+		return newMissingParamError(req, "sessionId")
+	}
+
+	sess, err := h.app.Sessions.Get(ctx, params.SessionID)
+	if err != nil {
+		return newOperationError(req, "Failed to get session", err)
+	}
+
+	contents, err := h.app.Pins.Contents(ctx, sess.ID, sess.WorkingDirectory)
+	if err != nil {
+		return newOperationError(req, "Failed to list pins", err)
+	}
+
+	result := ContextListData{Pins: make([]ContextPinData, len(contents))}
+	for i, c := range contents {
+		result.Pins[i] = contextPinData(c)
+		result.Tokens += c.Tokens
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleQueueStatus(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	status := h.app.RunQueue.Status()
+	result := QueueStatusData{
+		Limit:     status.Limit,
+		MaxQueued: status.MaxQueued,
+		Running:   status.Running,
+		Queued:    status.Queued,
+		Clients:   make([]QueueClientData, len(status.Clients)),
+	}
+	for i, c := range status.Clients {
+		result.Clients[i] = QueueClientData{Client: c.Client, Running: c.Running, Queued: c.Queued}
+	}
+	if params.SessionID != "" {
+		result.Position = h.app.RunQueue.Position(params.SessionID)
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleContextUnpin(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	if err := h.app.Pins.Unpin(ctx, params.ID); err != nil {
+		return newOperationError(req, "Failed to unpin", err)
+	}
+
+	return &QueryResponse{
+		Result: map[string]string{"message": "Unpinned: " + params.ID},
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleMemoryList(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		Scope     string `json:"scope,omitempty"`
+		Workspace string `json:"workspace,omitempty"`
+	}
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newInvalidParamsError(req, err)
+		}
+	}
+
+	switch memory.Scope(params.Scope) {
+	case "", memory.ScopeUser, memory.ScopeWorkspace:
+	default:
+		return newErrorResponse(req, CodeInvalidParams, fmt.Sprintf("Invalid scope %q: must be %q or %q", params.Scope, memory.ScopeUser, memory.ScopeWorkspace))
+	}
+
+	memories, err := h.app.Memories.List(ctx)
+	if err != nil {
+		return newOperationError(req, "Failed to list memories", err)
+	}
+
+	result := []MemoryData{}
+	for _, m := range memories {
+		if params.Scope != "" && string(m.Scope) != params.Scope {
+			continue
+		}
+		if params.Workspace != "" && m.Workspace != filepath.Clean(params.Workspace) {
+			continue
+		}
+		result = append(result, MemoryData{
+			ID:        m.ID,
+			Scope:     string(m.Scope),
+			Workspace: m.Workspace,
+			Content:   m.Content,
+			SessionID: m.SessionID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		})
+	}
+
+	return &QueryResponse{
+		Result: result,
+		ID:     req.ID,
+	}
+}
+
+func (h *QueryHandler) handleMemoryDelete(ctx context.Context, req *QueryRequest) *QueryResponse {
+	var params struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return newInvalidParamsError(req, err)
+	}
+
+	if params.ID == "" {
+		return newMissingParamError(req, "id")
+	}
+
+	if err := h.app.Memories.Delete(ctx, params.ID); err != nil {
+		return newOperationError(req, "Failed to delete memory", err)
+	}
+
+	return &QueryResponse{
+		Result: map[string]string{"message": "Deleted memory: " + params.ID},
+		ID:     req.ID,
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"mix/internal/app"
+	"mix/internal/llm/agent"
+	"mix/internal/llm/responseformat"
+	"mix/internal/message"
+	"mix/internal/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyedMessages holds a session's messages and the idempotency keys they
+// were sent with.
+type keyedMessages struct {
+	message.Service
+	msgs     []message.Message
+	keys     map[string]string
+	released []string
+}
+
+func (m *keyedMessages) GetByIdempotencyKey(_ context.Context, _, key string) (message.Message, error) {
+	for _, msg := range m.msgs {
+		if m.keys[msg.ID] == key {
+			return msg, nil
+		}
+	}
+	return message.Message{}, sql.ErrNoRows
+}
+
+func (m *keyedMessages) List(context.Context, string) ([]message.Message, error) {
+	return m.msgs, nil
+}
+
+func (m *keyedMessages) ReleaseIdempotencyKey(_ context.Context, id string) error {
+	delete(m.keys, id)
+	m.released = append(m.released, id)
+	return nil
+}
+
+type unlimitedSessions struct {
+	session.Service
+}
+
+func (unlimitedSessions) OutputProfile(context.Context, string) (string, error) {
+	return "", nil
+}
+
+type busyAgent struct {
+	agent.Service
+	busy bool
+}
+
+func (a busyAgent) IsSessionBusy(string) bool {
+	return a.busy
+}
+
+func userMessage(id, text string) message.Message {
+	return message.Message{ID: id, Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: text}}}
+}
+
+func assistantMessage(id, text string, reason message.FinishReason) message.Message {
+	msg := message.Message{ID: id, Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: text}}}
+	if reason != "" {
+		msg.AddFinish(reason)
+		msg.SetProviderRequestID("req-" + id)
+	}
+	return msg
+}
+
+func TestIdempotentSendResponse(t *testing.T) {
+	correction := userMessage("correction", "<system-reminder>\nSend the corrected final response.\n</system-reminder>")
+	format := &responseformat.Format{Type: responseformat.TypeJSONObject}
+
+	tests := []struct {
+		name     string
+		msgs     []message.Message
+		busy     bool
+		format   *responseformat.Format
+		code     int
+		response string
+		// structured is the replayed JSON, if any
+		structured string
+		released   bool
+	}{
+		{
+			name:     "completed request",
+			msgs:     []message.Message{userMessage("u1", "hi"), assistantMessage("a1", "hello", message.FinishReasonEndTurn)},
+			response: "a1",
+		},
+		{
+			name:       "completed request with a response format",
+			msgs:       []message.Message{userMessage("u1", "hi"), assistantMessage("a1", `{"greeting":"hello"}`, message.FinishReasonEndTurn)},
+			format:     format,
+			response:   "a1",
+			structured: `{"greeting":"hello"}`,
+		},
+		{
+			name: "response format corrected",
+			msgs: []message.Message{
+				userMessage("u1", "hi"),
+				assistantMessage("a1", "hello", message.FinishReasonEndTurn),
+				correction,
+				assistantMessage("a2", `{"greeting":"hello"}`, message.FinishReasonEndTurn),
+			},
+			format:     format,
+			response:   "a2",
+			structured: `{"greeting":"hello"}`,
+		},
+		{
+			name: "response ends at the next request",
+			msgs: []message.Message{
+				userMessage("u1", "hi"),
+				assistantMessage("a1", "hello", message.FinishReasonEndTurn),
+				userMessage("u2", "again"),
+				assistantMessage("a2", "hello again", message.FinishReasonEndTurn),
+			},
+			busy:     true,
+			response: "a1",
+		},
+		{
+			name: "still running",
+			msgs: []message.Message{userMessage("u1", "hi"), assistantMessage("a1", "hel", "")},
+			busy: true,
+			code: CodeSessionBusy,
+		},
+		{
+			name:     "failed before responding",
+			msgs:     []message.Message{userMessage("u1", "hi")},
+			released: true,
+		},
+		{
+			name:     "failed while responding",
+			msgs:     []message.Message{userMessage("u1", "hi"), assistantMessage("a1", "hel", message.FinishReasonCanceled)},
+			released: true,
+		},
+		{
+			name:     "interrupted by a restart",
+			msgs:     []message.Message{userMessage("u1", "hi"), assistantMessage("a1", "hel", message.FinishReasonInterrupted)},
+			released: true,
+		},
+		{
+			name:     "response not matching the format",
+			msgs:     []message.Message{userMessage("u1", "hi"), assistantMessage("a1", "hello", message.FinishReasonEndTurn)},
+			format:   format,
+			released: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := &keyedMessages{msgs: tt.msgs, keys: map[string]string{"u1": "key-1"}}
+			h := &QueryHandler{app: &app.App{Messages: messages, Sessions: unlimitedSessions{}, CoderAgent: busyAgent{busy: tt.busy}}}
+			req := &QueryRequest{Method: "messages.send", ID: 1}
+
+			resp := h.idempotentSendResponse(context.Background(), req, "s1", "key-1", "hi", tt.format)
+
+			if tt.released {
+				// The request runs again under the key
+				assert.Nil(t, resp)
+				assert.Equal(t, []string{"u1"}, messages.released)
+				return
+			}
+			require.NotNil(t, resp)
+			assert.Empty(t, messages.released)
+			if tt.code != 0 {
+				require.NotNil(t, resp.Error)
+				assert.Equal(t, tt.code, resp.Error.Code)
+				return
+			}
+			require.Nil(t, resp.Error)
+			data := resp.Result.(MessageData)
+			assert.Equal(t, tt.response, data.ID)
+			assert.Equal(t, "hi", data.Content)
+			assert.Equal(t, "req-"+tt.response, data.ProviderRequestID)
+			if tt.structured == "" {
+				assert.Nil(t, data.Structured)
+			} else {
+				assert.JSONEq(t, tt.structured, string(data.Structured))
+			}
+		})
+	}
+}
+
+func TestIdempotentSendResponseUnusedKey(t *testing.T) {
+	messages := &keyedMessages{keys: map[string]string{}}
+	h := &QueryHandler{app: &app.App{Messages: messages, CoderAgent: busyAgent{}}}
+	resp := h.idempotentSendResponse(context.Background(), &QueryRequest{ID: 1}, "s1", "key-1", "hi", nil)
+	assert.Nil(t, resp)
+	assert.Empty(t, messages.released)
+}
@@ -2,51 +2,123 @@ package app
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"mix/internal/analytics"
+	"mix/internal/annotation"
+	"mix/internal/assets"
+	"mix/internal/audit"
+	"mix/internal/backup"
+	"mix/internal/chaos"
+	"mix/internal/checkpoint"
 	"mix/internal/config"
 	"mix/internal/db"
+	"mix/internal/eventexport"
+	"mix/internal/eventlog"
+	"mix/internal/filewatch"
 	"mix/internal/format"
 	"mix/internal/history"
+	"mix/internal/i18n"
+	"mix/internal/jobs"
 	"mix/internal/llm/agent"
+	"mix/internal/llm/models"
+	"mix/internal/llm/provider"
+	"mix/internal/llm/responseformat"
+	"mix/internal/llm/tools"
 	"mix/internal/logging"
+	"mix/internal/memory"
 	"mix/internal/message"
+	"mix/internal/metrics"
+	"mix/internal/netpolicy"
 	"mix/internal/permission"
+	"mix/internal/pin"
+	"mix/internal/pubsub"
+	"mix/internal/question"
+	"mix/internal/render"
+	"mix/internal/runqueue"
 	"mix/internal/session"
+	"mix/internal/sessiontemplate"
+	"mix/internal/share"
+	"mix/internal/streamtoken"
+	"mix/internal/toolstats"
+	"mix/internal/tracing"
 	"mix/internal/video"
+	"mix/internal/webhook"
 )
 
 type App struct {
-	Sessions    session.Service
-	Messages    message.Service
-	History     history.Service
-	Permissions permission.Service
-	Analytics   analytics.Service
-	Video       *video.ExportService
-	AssetServer *session.AssetServer
+	Sessions     session.Service
+	Messages     message.Service
+	History      history.Service
+	Permissions  permission.Service
+	Questions    question.Service
+	Analytics    analytics.Service
+	Audits       audit.Service
+	ToolStats    toolstats.Service
+	Checkpoints  checkpoint.Service
+	Pins         pin.Service
+	Memories     memory.Service
+	StreamTokens streamtoken.Service
+	Shares       share.Service
+	// RunQueue limits the agent runs HTTP clients have in progress
+	RunQueue     *runqueue.Queue
+	StreamEvents eventlog.Service
+	Templates    sessiontemplate.Service
+	Annotations  annotation.Service
+	Jobs         jobs.Service
+	Assets       assets.Service
+	Video        *video.ExportService
+	AssetServer  *session.AssetServer
+	FileWatch    filewatch.Service
+	// Renders is nil unless render.enabled is set
+	Renders *render.Renderer
 
 	CoderAgent agent.Service
 
+	// ConfigChanges publishes each successful configuration reload
+	ConfigChanges *pubsub.Broker[config.Change]
+
+	db         *db.DB
+	mcpManager *agent.MCPClientManager
+	backups    *backup.Scheduler
+	jobs       *jobs.Scheduler
+	exporter   *eventexport.Exporter
+	tracer     *tracing.Exporter
+	webhooks   *webhook.Dispatcher
+	// reloadMu serializes config reloads and applying them
+	reloadMu sync.Mutex
+
 	// Current session tracking for API session selection
 	currentSessionID string
 }
 
-func New(ctx context.Context, conn *sql.DB) (*App, error) {
-	q := db.New(conn)
+func New(ctx context.Context, conn *db.DB) (*App, error) {
+	if err := chaos.Init(config.Get().Chaos); err != nil {
+		return nil, err
+	}
+
+	q := db.New(metrics.WrapDB(chaos.WrapDB(conn)))
+	metrics.DBPools.Track("writer", conn.DB)
+	metrics.DBPools.Track("reader", conn.Reader)
 	sessions := session.NewService(q)
 
 	// Create base message service
 	baseMessageService := message.NewService(q)
 
-	files := history.NewService(q, conn)
+	files := history.NewService(q, conn.DB)
 
 	// Initialize analytics service with PostHog API key
 	posthogAPIKey := "phc_9QLQI8n19fRg1vsqvYRaQVXFRpMRXTGQK4i2DaYqWRU"
 
 	cfg := config.Get()
+	if err := configureRedaction(cfg); err != nil {
+		return nil, fmt.Errorf("failed to configure redaction: %w", err)
+	}
 	analyticsEnabled := cfg.AnalyticsEnabled
 
 	if !analyticsEnabled {
@@ -62,35 +134,96 @@ func New(ctx context.Context, conn *sql.DB) (*App, error) {
 		return nil, fmt.Errorf("failed to initialize video export service: %w", err)
 	}
 
+	if cfg.LocalesDir != "" {
+		if err := i18n.LoadDir(cfg.LocalesDir); err != nil {
+			return nil, fmt.Errorf("failed to load message catalogs: %w", err)
+		}
+	}
+	if cfg.Locale != "" && !i18n.Supported(cfg.Locale) {
+		return nil, fmt.Errorf("unsupported locale %q: available locales are %s", cfg.Locale, strings.Join(i18n.Locales(), ", "))
+	}
+
 	// Initialize asset server for serving files
-	assetServer := session.NewAssetServer()
+	assetServer := session.NewAssetServer(sessions)
+	assetStore, err := assets.NewService(ctx, q, cfg.ArtifactStorage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize artifact storage: %w", err)
+	}
+	if assetStore.Enabled() {
+		assetServer.SetRemote(assetStore)
+	}
+
+	shareKey, err := share.LoadKey(cfg.Sharing, cfg.Data.Directory)
+	if err != nil {
+		return nil, err
+	}
 
 	// Wrap message service with tracking
 	messages := message.NewTrackingService(baseMessageService, analyticsService)
 
 	app := &App{
-		Sessions:    sessions,
-		Messages:    messages,
-		History:     files,
-		Permissions: permission.NewPermissionService(sessions),
-		Analytics:   analyticsService,
-		Video:       videoService,
-		AssetServer: assetServer,
+		Sessions:     sessions,
+		Messages:     messages,
+		History:      files,
+		Permissions:  permission.NewPermissionService(sessions),
+		Questions:    question.NewService(),
+		Analytics:    analyticsService,
+		Audits:       audit.NewService(q),
+		ToolStats:    toolstats.NewService(q),
+		Checkpoints:  checkpoint.NewService(q),
+		Pins:         pin.NewService(q, messages),
+		Memories:     memory.NewService(q),
+		StreamTokens: streamtoken.NewService(),
+		Shares:       share.NewService(q, shareKey),
+		RunQueue:     runqueue.New(0, 0),
+		StreamEvents: eventlog.NewService(q),
+		Templates:    sessiontemplate.NewService(q),
+		Annotations:  annotation.NewService(q),
+		Jobs:         jobs.NewService(q),
+		Assets:       assetStore,
+		Video:        videoService,
+		AssetServer:  assetServer,
+		FileWatch:    filewatch.NewService(),
+		db:           conn,
+
+		ConfigChanges: pubsub.NewBroker[config.Change](),
+	}
+
+	if cfg.Render.Enabled {
+		app.Renders = render.NewRenderer(filepath.Join(cfg.Data.Directory, "renders"))
+	}
+
+	if err := netpolicy.Init(cfg.Network, app.Audits); err != nil {
+		return nil, err
+	}
+
+	if cfg.Backup.Enabled {
+		app.backups, err = backup.Start(conn.DB, backup.Settings())
+		if err != nil {
+			return nil, fmt.Errorf("failed to start backups: %w", err)
+		}
 	}
 
 	// Create MCP manager for this agent
-	mcpManager := agent.NewMCPClientManager()
+	app.mcpManager = agent.NewMCPClientManager()
 
 	app.CoderAgent, err = agent.NewAgent(
 		config.AgentMain,
 		app.Sessions,
 		app.Messages,
+		app.Audits,
+		app.Checkpoints,
+		app.Pins,
+		app.Memories,
 		agent.CoderAgentTools(
 			app.Permissions,
 			app.Sessions,
 			app.Messages,
 			app.History,
-			mcpManager,
+			app.Audits,
+			app.Memories,
+			app.Questions,
+			app.mcpManager,
 		),
 	)
 	if err != nil {
@@ -98,20 +231,125 @@ func New(ctx context.Context, conn *sql.DB) (*App, error) {
 		return nil, err
 	}
 
+	if app.Assets.Enabled() {
+		go app.uploadAssetsAfterRuns(ctx)
+	}
+
+	if cfg.EventExport != nil {
+		app.exporter, err = eventexport.Start(*cfg.EventExport, config.AgentMain, app.CoderAgent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start event export: %w", err)
+		}
+	}
+
+	if cfg.Tracing != nil {
+		app.tracer, err = tracing.Start(ctx, *cfg.Tracing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start tracing: %w", err)
+		}
+	}
+
+	if len(cfg.Webhooks) > 0 {
+		app.webhooks = webhook.Start(cfg.Webhooks, config.AgentMain, app.CoderAgent, app.Sessions, app.Messages)
+	}
+
+	if cfg.ProbeProviders {
+		probeProviders(ctx)
+	}
+
+	if config.ModelCatalogDue() {
+		go refreshModelCatalog(ctx)
+	}
+	if config.OpenRouterCatalogDue() {
+		go refreshOpenRouterCatalog(ctx)
+	}
+
 	return app, nil
 }
 
-// Removed theme initialization for embedded binary
+// refreshModelCatalog discovers models released since the last refresh. The
+// models it adds are accepted by the next config validation and usable right
+// away by sessions.
+func refreshModelCatalog(ctx context.Context) {
+	_, failed, err := config.RefreshModelCatalog(ctx)
+	if err != nil {
+		logging.Warn("Failed to refresh model catalog", "error", err)
+		return
+	}
+	for provider, err := range failed {
+		logging.Warn("Failed to list provider models, keeping the previous ones", "provider", provider, "error", err)
+	}
+}
 
-// RunNonInteractive handles the execution flow when a prompt is provided via CLI flag.
-func (a *App) RunNonInteractive(ctx context.Context, prompt string, outputFormat string, quiet bool) error {
-	logging.Info("Running in non-interactive mode")
+// refreshOpenRouterCatalog fetches OpenRouter's live model catalog in the
+// background, so startup and reloads don't wait on OpenRouter. Until it
+// arrives the models of the previous fetch, or the built-in ones, are used.
+func refreshOpenRouterCatalog(ctx context.Context) {
+	if err := config.RefreshOpenRouterCatalog(ctx); err != nil {
+		logging.Warn("Failed to refresh OpenRouter model catalog, keeping the previous models", "error", err)
+	}
+}
 
-	// Processing message for non-interactive mode
-	if !quiet {
-		fmt.Println("Processing...")
+// probeProviders reports misconfigured providers at startup instead of at the
+// first user message. Failures are logged, not fatal, since other agents or
+// fallback models may still work.
+func probeProviders(ctx context.Context) {
+	for _, result := range agent.ProbeProviders(ctx) {
+		if result.OK {
+			logging.Info("Provider probe succeeded", "provider", result.Provider, "model", result.Model, "duration", result.Duration)
+			continue
+		}
+		logging.Error("Provider probe failed",
+			"provider", result.Provider,
+			"model", result.Model,
+			"agents", result.Agents,
+			"problem", result.Problem,
+			"hint", result.Hint,
+			"error", result.Error,
+		)
 	}
+}
 
+// uploadAssetsAfterRuns uploads a session's generated files each time one of
+// its responses completes.
+func (a *App) uploadAssetsAfterRuns(ctx context.Context) {
+	defer logging.RecoverPanic("asset-uploads", nil)
+	for event := range a.CoderAgent.Subscribe(ctx) {
+		if event.Payload.Type == agent.AgentEventTypeResponse && event.Payload.Done {
+			a.UploadSessionAssets(event.Payload.SessionID)
+		}
+	}
+}
+
+// UploadSessionAssets starts uploading a session's new and changed output
+// files when artifact storage is configured.
+func (a *App) UploadSessionAssets(sessionID string) {
+	if !a.Assets.Enabled() {
+		return
+	}
+	sess, err := a.Sessions.Get(context.Background(), sessionID)
+	if err != nil {
+		logging.Warn("Failed to load session for asset upload", "session", sessionID, "error", err)
+		return
+	}
+	a.Assets.Upload(sessionID, sess.WorkingDirectory)
+}
+
+// Removed theme initialization for embedded binary
+
+// PromptResult holds the outcome of a single non-interactive prompt run.
+type PromptResult struct {
+	SessionID string
+	Content   string
+	Cost      float64
+	// Structured is the response's JSON when the prompt had a response format
+	Structured json.RawMessage
+}
+
+// RunPrompt creates a session in workingDir, runs the prompt through the coder
+// agent and waits for the final response, which must match responseFormat
+// unless it is nil.
+func (a *App) RunPrompt(ctx context.Context, prompt string, workingDir string, responseFormat *responseformat.Format) (PromptResult, error) {
 	const maxPromptLengthForTitle = 100
 	titlePrefix := "Non-interactive: "
 	var titleSuffix string
@@ -123,40 +361,82 @@ func (a *App) RunNonInteractive(ctx context.Context, prompt string, outputFormat
 	}
 	title := titlePrefix + titleSuffix
 
-	launchDir, err := config.LaunchDirectory()
+	sess, err := a.Sessions.Create(ctx, title, workingDir)
 	if err != nil {
-		return fmt.Errorf("failed to get launch directory: %w", err)
+		return PromptResult{}, fmt.Errorf("failed to create session for non-interactive mode: %w", err)
 	}
+	logging.Info("Created session for non-interactive run", "session_id", sess.ID)
 
-	sess, err := a.Sessions.Create(ctx, title, launchDir)
+	return a.runPromptInSession(ctx, sess.ID, prompt, responseFormat)
+}
+
+// runPromptInSession runs the prompt through the coder agent in an existing
+// session and waits for the final response.
+func (a *App) runPromptInSession(ctx context.Context, sessionID string, prompt string, responseFormat *responseformat.Format) (PromptResult, error) {
+	result := PromptResult{SessionID: sessionID}
+
+	done, err := a.CoderAgent.RunWithState(ctx, tools.RequestState{SessionID: sessionID, ResponseFormat: responseFormat}, prompt)
 	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+		return result, fmt.Errorf("failed to start agent processing stream: %w", err)
+	}
+
+	agentResult := agent.WaitForResult(done)
+	if agentResult.Error != nil {
+		return result, fmt.Errorf("agent processing failed: %w", agentResult.Error)
+	}
+
+	result.Content = agentResult.Message.Content().String()
+	result.Structured = agentResult.Structured
+
+	// Cost is accumulated on the session by the agent while it runs
+	updated, err := a.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return result, fmt.Errorf("failed to load session: %w", err)
+	}
+	result.Cost = updated.Cost
+
+	return result, nil
+}
+
+// RunNonInteractive handles the execution flow when a prompt is provided via CLI flag.
+// With a responseFormat, only the response's JSON is printed.
+func (a *App) RunNonInteractive(ctx context.Context, prompt string, outputFormat string, quiet bool, responseFormat *responseformat.Format) error {
+	logging.Info("Running in non-interactive mode")
+
+	// Processing message for non-interactive mode
+	if !quiet {
+		fmt.Println("Processing...")
 	}
-	logging.Info("Created session for non-interactive run", "session_id", sess.ID)
 
-	done, err := a.CoderAgent.Run(ctx, sess.ID, prompt)
+	launchDir, err := config.LaunchDirectory()
 	if err != nil {
-		return fmt.Errorf("failed to start agent processing stream: %w", err)
+		return fmt.Errorf("failed to get launch directory: %w", err)
 	}
 
-	result := <-done
-	if result.Error != nil {
-		if errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled) {
-			logging.Info("Agent processing cancelled", "session_id", sess.ID)
+	result, err := a.RunPrompt(ctx, prompt, launchDir, responseFormat)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, agent.ErrRequestCancelled) {
+			logging.Info("Agent processing cancelled", "session_id", result.SessionID)
 			return nil
 		}
-		return fmt.Errorf("agent processing failed: %w", result.Error)
+		return err
+	}
+
+	if responseFormat != nil {
+		fmt.Println(string(result.Structured))
+		logging.Info("Non-interactive run completed", "session_id", result.SessionID)
+		return nil
 	}
 
 	// Get the text content from the response
 	content := "No content available"
-	if result.Message.Content().String() != "" {
-		content = result.Message.Content().String()
+	if result.Content != "" {
+		content = result.Content
 	}
 
 	fmt.Println(format.FormatOutput(content, outputFormat))
 
-	logging.Info("Non-interactive run completed", "session_id", sess.ID)
+	logging.Info("Non-interactive run completed", "session_id", result.SessionID)
 
 	return nil
 }
@@ -186,6 +466,43 @@ func (a *App) SetCurrentSession(sessionID string) error {
 	return nil
 }
 
+// SetSessionWorkingDirectory repoints a session at a new project folder. The
+// cached provider is dropped since its system prompt embeds the working
+// directory, the session's file watch moves to the new folder, and the asset
+// server follows if the session is current.
+func (a *App) SetSessionWorkingDirectory(ctx context.Context, sessionID string, workingDir string) (session.Session, error) {
+	if a.CoderAgent.IsSessionBusy(sessionID) {
+		return session.Session{}, agent.ErrSessionBusy
+	}
+
+	sess, err := a.Sessions.SetWorkingDirectory(ctx, sessionID, workingDir)
+	if err != nil {
+		return session.Session{}, err
+	}
+	a.CoderAgent.InvalidateSessionProvider(sessionID)
+	if err := a.FileWatch.SetDirectory(sessionID, sess.WorkingDirectory); err != nil {
+		logging.Warn("Failed to move the working directory watch", "session", sessionID, "error", err)
+	}
+
+	if sessionID == a.currentSessionID && a.AssetServer != nil {
+		if err := a.AssetServer.SetWorkingDirectory(sess.WorkingDirectory); err != nil {
+			return session.Session{}, fmt.Errorf("failed to set asset server working directory: %w", err)
+		}
+	}
+
+	return sess, nil
+}
+
+// SetProviderAPIKey stores a provider's API key in the credential store, or
+// clears it when apiKey is empty, and rebuilds the agent's providers so the
+// next run authenticates with it.
+func (a *App) SetProviderAPIKey(p models.ModelProvider, apiKey string) error {
+	if err := provider.SetAPIKey(p, apiKey); err != nil {
+		return err
+	}
+	return a.CoderAgent.ReloadProviders()
+}
+
 // GetCurrentSession returns the currently selected session, or nil if none selected
 func (a *App) GetCurrentSession(ctx context.Context) (*session.Session, error) {
 	if a.currentSessionID == "" {
@@ -206,19 +523,3 @@ func (a *App) GetCurrentSession(ctx context.Context) (*session.Session, error) {
 func (a *App) GetCurrentSessionID() string {
 	return a.currentSessionID
 }
-
-// Shutdown performs a clean shutdown of the application
-func (app *App) Shutdown() {
-	if app.CoderAgent != nil {
-		app.CoderAgent.Shutdown()
-	}
-
-	// Clean up analytics service
-	if app.Analytics != nil {
-		if err := app.Analytics.Close(); err != nil {
-			logging.Error("Failed to close analytics service: %v", err)
-		}
-	}
-
-	logging.Info("Application shutdown completed")
-}
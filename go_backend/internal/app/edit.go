@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"mix/internal/llm/agent"
+	"mix/internal/message"
+)
+
+// EditMessage replaces the text of the user message messageID and deletes the
+// messages after it. With fork set, the original session stays unchanged:
+// the edited message is added to a new session forked just before it. The
+// returned message is the edited one, in the fork if there is one.
+func (a *App) EditMessage(ctx context.Context, messageID, content string, fork bool) (message.Message, error) {
+	msg, err := a.Messages.Get(ctx, messageID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to get message: %w", err)
+	}
+	if msg.Role != message.User {
+		return message.Message{}, fmt.Errorf("only user messages can be edited, message %s is a %s message", messageID, msg.Role)
+	}
+	msg.SetContent(content)
+
+	if fork {
+		return a.forkWithEdit(ctx, msg)
+	}
+
+	if a.CoderAgent.IsSessionBusy(msg.SessionID) {
+		return message.Message{}, agent.ErrSessionBusy
+	}
+	if err := a.truncateAfter(ctx, msg.ID); err != nil {
+		return message.Message{}, err
+	}
+	if err := a.Messages.Update(ctx, msg); err != nil {
+		return message.Message{}, fmt.Errorf("failed to update message: %w", err)
+	}
+	return msg, nil
+}
+
+// forkWithEdit forks msg's session with the messages before msg, then adds msg
+// to the fork.
+func (a *App) forkWithEdit(ctx context.Context, msg message.Message) (message.Message, error) {
+	msgs, err := a.Messages.List(ctx, msg.SessionID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	index := 0
+	for index < len(msgs) && msgs[index].ID != msg.ID {
+		index++
+	}
+
+	source, err := a.Sessions.Get(ctx, msg.SessionID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	forked, err := a.Sessions.Fork(ctx, source.ID, source.Title+" (edited)")
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to fork session: %w", err)
+	}
+	if index > 0 {
+		if err := a.Messages.CopyMessagesToSession(ctx, source.ID, forked.ID, int64(index)); err != nil {
+			return message.Message{}, fmt.Errorf("failed to copy messages: %w", err)
+		}
+	}
+
+	// Create adds its own finish part
+	parts := make([]message.ContentPart, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		if _, ok := part.(message.Finish); !ok {
+			parts = append(parts, part)
+		}
+	}
+	edited, err := a.Messages.Create(ctx, forked.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: parts,
+		Model: msg.Model,
+	})
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to add edited message: %w", err)
+	}
+	return edited, nil
+}
+
+// Regenerate deletes the messages after messageID and generates the turn
+// again from there. For an assistant message, the message itself is replaced
+// too, so its response is generated again.
+func (a *App) Regenerate(ctx context.Context, messageID string) (<-chan agent.AgentEvent, error) {
+	msg, err := a.Messages.Get(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if a.CoderAgent.IsSessionBusy(msg.SessionID) {
+		return nil, agent.ErrSessionBusy
+	}
+
+	anchor := msg.ID
+	if msg.Role == message.Assistant {
+		msgs, err := a.Messages.List(ctx, msg.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+		anchor = ""
+		for i := 1; i < len(msgs); i++ {
+			if msgs[i].ID == msg.ID {
+				anchor = msgs[i-1].ID
+				break
+			}
+		}
+		if anchor == "" {
+			return nil, fmt.Errorf("message %s has no message to respond to", messageID)
+		}
+	}
+	if err := a.truncateAfter(ctx, anchor); err != nil {
+		return nil, err
+	}
+	return a.CoderAgent.Resume(ctx, msg.SessionID)
+}
+
+// truncateAfter deletes the messages after messageID, clearing the session's
+// summary if it was among them.
+func (a *App) truncateAfter(ctx context.Context, messageID string) error {
+	deleted, err := a.Messages.DeleteAfter(ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete later messages: %w", err)
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	sess, err := a.Sessions.Get(ctx, deleted[0].SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	for _, msg := range deleted {
+		if msg.ID == sess.SummaryMessageID {
+			sess.SummaryMessageID = ""
+			if _, err := a.Sessions.Save(ctx, sess); err != nil {
+				return fmt.Errorf("failed to clear session summary: %w", err)
+			}
+			break
+		}
+	}
+	return nil
+}
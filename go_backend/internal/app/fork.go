@@ -0,0 +1,252 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"mix/internal/message"
+)
+
+// CopiedFile is a file snapshotted into a fork.
+type CopiedFile struct {
+	Source      string
+	Destination string
+}
+
+// CopyForkFiles snapshots the files a fork's messages reference into its
+// working directory and rewrites the paths in the messages, so the fork keeps
+// working when the source session's directory changes or the fork is pointed
+// elsewhere. Attachments are copied, as are the files under the source
+// working directory that messages reference by absolute path. Files already
+// present at their destination are not overwritten.
+func (a *App) CopyForkFiles(ctx context.Context, sourceSessionID, forkSessionID string) ([]CopiedFile, error) {
+	source, err := a.Sessions.Get(ctx, sourceSessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source session: %w", err)
+	}
+	fork, err := a.Sessions.Get(ctx, forkSessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fork: %w", err)
+	}
+	if fork.WorkingDirectory == "" {
+		return nil, nil
+	}
+
+	msgs, err := a.Messages.List(ctx, fork.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	snapshot := newFileSnapshot(source.WorkingDirectory, fork.WorkingDirectory)
+	for _, msg := range msgs {
+		changed, err := snapshot.rewrite(&msg)
+		if err != nil {
+			return snapshot.copied, err
+		}
+		if !changed {
+			continue
+		}
+		if err := a.Messages.Update(ctx, msg); err != nil {
+			return snapshot.copied, fmt.Errorf("failed to update message: %w", err)
+		}
+	}
+	return snapshot.copied, nil
+}
+
+// fileSnapshot copies the files messages reference from srcDir, or from
+// anywhere for attachments, into dstDir.
+type fileSnapshot struct {
+	srcDir string
+	dstDir string
+	// paths maps the files handled so far to their copies
+	paths  map[string]string
+	copied []CopiedFile
+	// srcPaths matches absolute paths under srcDir
+	srcPaths *regexp.Regexp
+}
+
+func newFileSnapshot(srcDir, dstDir string) *fileSnapshot {
+	s := &fileSnapshot{srcDir: srcDir, dstDir: dstDir, paths: map[string]string{}}
+	if srcDir != "" && filepath.Clean(srcDir) != filepath.Clean(dstDir) {
+		prefix := regexp.QuoteMeta(filepath.Clean(srcDir) + string(filepath.Separator))
+		s.srcPaths = regexp.MustCompile(prefix + "[^\\s\"'`<>|\\\\]+")
+	}
+	return s
+}
+
+// rewrite copies the files msg references and points it at the copies,
+// reporting whether msg changed.
+func (s *fileSnapshot) rewrite(msg *message.Message) (bool, error) {
+	changed := false
+	update := func(text *string) error {
+		next, err := s.rewriteText(*text)
+		if err != nil {
+			return err
+		}
+		if next != *text {
+			*text, changed = next, true
+		}
+		return nil
+	}
+
+	for i, part := range msg.Parts {
+		var err error
+		switch p := part.(type) {
+		case message.TextContent:
+			err = update(&p.Text)
+			part = p
+		case message.ToolCall:
+			err = update(&p.Input)
+			part = p
+		case message.ToolResult:
+			if err = update(&p.Content); err == nil {
+				err = update(&p.Metadata)
+			}
+			part = p
+		case message.BinaryContent:
+			var dst string
+			if dst, err = s.attachment(p.Path, p.MIMEType); err == nil && dst != "" {
+				p.Path, changed = dst, true
+			}
+			part = p
+		}
+		if err != nil {
+			return false, err
+		}
+		msg.Parts[i] = part
+	}
+	return changed, nil
+}
+
+// rewriteText replaces the paths under srcDir in text with their copies.
+func (s *fileSnapshot) rewriteText(text string) (string, error) {
+	if s.srcPaths == nil {
+		return text, nil
+	}
+	var copyErr error
+	text = s.srcPaths.ReplaceAllStringFunc(text, func(match string) string {
+		// Paths in prose are often followed by punctuation
+		path, rest := match, ""
+		for !isRegularFile(path) {
+			last := path[len(path)-1:]
+			if !strings.Contains(".,;:!?)]}", last) {
+				return match
+			}
+			path, rest = path[:len(path)-1], last+rest
+		}
+		rel, err := filepath.Rel(s.srcDir, path)
+		if err != nil {
+			return match
+		}
+		dst, err := s.copy(path, filepath.Join(s.dstDir, rel))
+		if err != nil {
+			copyErr = err
+			return match
+		}
+		return dst + rest
+	})
+	return text, copyErr
+}
+
+// attachment copies an attachment outside dstDir, keeping its place when it is
+// under srcDir and otherwise to the input subdirectory for its MIME type. It
+// returns the copy's path, or "" to keep path.
+func (s *fileSnapshot) attachment(path, mimeType string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(path) && s.srcDir != "" {
+		path = filepath.Join(s.srcDir, path)
+	}
+	if dst, ok := s.paths[path]; ok {
+		return dst, nil
+	}
+	if rel, err := filepath.Rel(s.dstDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return "", nil
+	}
+	if !isRegularFile(path) {
+		return "", nil
+	}
+
+	if rel, err := filepath.Rel(s.srcDir, path); s.srcDir != "" && err == nil && !strings.HasPrefix(rel, "..") {
+		return s.copy(path, filepath.Join(s.dstDir, rel))
+	}
+	return s.copy(path, uniquePath(filepath.Join(s.dstDir, "input", inputSubdir(mimeType), filepath.Base(path))))
+}
+
+// copy copies src to dst once, unless dst already exists, and returns dst.
+func (s *fileSnapshot) copy(src, dst string) (string, error) {
+	if existing, ok := s.paths[src]; ok {
+		return existing, nil
+	}
+	if _, err := os.Stat(dst); err == nil {
+		s.paths[src] = dst
+		return dst, nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	s.paths[src] = dst
+	s.copied = append(s.copied, CopiedFile{Source: src, Destination: dst})
+	return dst, nil
+}
+
+// inputSubdir is the input directory subdirectory for attachments of
+// mimeType, as created for new working directories.
+func inputSubdir(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "images"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "videos"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audios"
+	}
+	return "text"
+}
+
+// uniquePath adds a numeric suffix to path's name until no file has it.
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
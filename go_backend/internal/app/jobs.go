@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/jobs"
+	"mix/internal/llm/agent"
+	"mix/internal/session"
+)
+
+// CreateJob stores a scheduled job after checking that its template exists.
+func (a *App) CreateJob(ctx context.Context, job jobs.Job) (jobs.Job, error) {
+	if job.Template != "" {
+		if _, err := a.Templates.Get(ctx, job.Template); err != nil {
+			return jobs.Job{}, err
+		}
+	}
+	return a.Jobs.Create(ctx, job)
+}
+
+// StartJobs runs the stored jobs on their schedules until the app shuts
+// down. Only the server runs jobs, so other commands don't run them twice.
+func (a *App) StartJobs() {
+	if a.jobs == nil {
+		a.jobs = jobs.Start(a.Jobs, a.runJob)
+	}
+}
+
+// runJob runs job's prompt non-interactively in a new session, started from
+// the job's template when it has one.
+func (a *App) runJob(ctx context.Context, job jobs.Job) jobs.Run {
+	run := jobs.Run{Job: job.Name, StartedAt: time.Now()}
+
+	sess, err := a.createJobSession(ctx, job, run.StartedAt)
+	if err == nil {
+		run.SessionID = sess.ID
+		var result PromptResult
+		result, err = a.runPromptInSession(ctx, sess.ID, job.Prompt, nil)
+		run.Response = result.Content
+		run.Cost = result.Cost
+	}
+	run.FinishedAt = time.Now()
+
+	switch {
+	case err == nil:
+		run.Status = jobs.StatusSuccess
+	case errors.Is(err, context.Canceled) || errors.Is(err, agent.ErrRequestCancelled):
+		run.Status = jobs.StatusCancelled
+		run.Error = err.Error()
+	default:
+		run.Status = jobs.StatusError
+		run.Error = err.Error()
+	}
+	return run
+}
+
+func (a *App) createJobSession(ctx context.Context, job jobs.Job, startedAt time.Time) (session.Session, error) {
+	title := fmt.Sprintf("%s (%s)", job.Name, startedAt.Format("2006-01-02 15:04"))
+	if job.Template != "" {
+		return a.ApplyTemplate(ctx, job.Template, title, job.WorkingDirectory)
+	}
+
+	workingDir := job.WorkingDirectory
+	if workingDir == "" {
+		var err error
+		if workingDir, err = config.LaunchDirectory(); err != nil {
+			return session.Session{}, fmt.Errorf("failed to get launch directory: %w", err)
+		}
+	}
+	sess, err := a.Sessions.Create(ctx, title, workingDir)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	return sess, nil
+}
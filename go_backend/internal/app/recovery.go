@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"mix/internal/logging"
+	"mix/internal/message"
+)
+
+// InterruptedTurn is an assistant message the server stopped generating
+// before it finished, e.g. because the process crashed or was killed.
+type InterruptedTurn struct {
+	SessionID string
+	MessageID string
+	// Latest is set when nothing was added to the session after the message,
+	// so the turn can be resumed
+	Latest bool
+}
+
+// RecoverInterruptedTurns finishes the assistant messages created before the
+// given Unix time that never got a finish reason, with FinishReasonInterrupted.
+// Tool calls of such a message never ran; they get error results so the
+// history stays valid for providers that require a result for every call.
+func (a *App) RecoverInterruptedTurns(ctx context.Context, createdBefore int64) ([]InterruptedTurn, error) {
+	unfinished, err := a.Messages.ListUnfinished(ctx, createdBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unfinished messages: %w", err)
+	}
+
+	turns := make([]InterruptedTurn, 0, len(unfinished))
+	for _, msg := range unfinished {
+		msgs, err := a.Messages.List(ctx, msg.SessionID)
+		if err != nil {
+			return turns, fmt.Errorf("failed to list messages of session %s: %w", msg.SessionID, err)
+		}
+		turn := InterruptedTurn{
+			SessionID: msg.SessionID,
+			MessageID: msg.ID,
+			Latest:    len(msgs) > 0 && msgs[len(msgs)-1].ID == msg.ID,
+		}
+
+		msg.AddFinish(message.FinishReasonInterrupted)
+		if err := a.Messages.Update(ctx, msg); err != nil {
+			return turns, fmt.Errorf("failed to finish message %s: %w", msg.ID, err)
+		}
+		if toolCalls := msg.ToolCalls(); len(toolCalls) > 0 {
+			parts := make([]message.ContentPart, len(toolCalls))
+			for i, call := range toolCalls {
+				parts[i] = message.ToolResult{
+					ToolCallID: call.ID,
+					Content:    "Tool execution interrupted: the server stopped before the tool ran",
+					IsError:    true,
+				}
+			}
+			if _, err := a.Messages.Create(ctx, msg.SessionID, message.CreateMessageParams{
+				Role:  message.Tool,
+				Parts: parts,
+			}); err != nil {
+				return turns, fmt.Errorf("failed to record interrupted tool calls of message %s: %w", msg.ID, err)
+			}
+		}
+
+		logging.Info("Recovered interrupted response", "session", turn.SessionID, "message", turn.MessageID, "latest", turn.Latest)
+		turns = append(turns, turn)
+	}
+	return turns, nil
+}
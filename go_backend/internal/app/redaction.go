@@ -0,0 +1,31 @@
+package app
+
+import (
+	"mix/internal/config"
+	"mix/internal/redact"
+)
+
+// configureRedaction applies cfg's redaction settings, adding the credentials
+// cfg holds to the secrets always redacted.
+func configureRedaction(cfg *config.Config) error {
+	var secrets []string
+	for _, provider := range cfg.Providers {
+		secrets = append(secrets, provider.APIKey)
+	}
+	for _, token := range cfg.HTTPAuth.Tokens {
+		secrets = append(secrets, token.Token)
+	}
+	if cfg.HTTPAuth.JWT != nil {
+		secrets = append(secrets, cfg.HTTPAuth.JWT.Secret)
+	}
+	for _, webhook := range cfg.Webhooks {
+		secrets = append(secrets, webhook.Secret)
+	}
+	return redact.Configure(redact.Options{
+		Disabled:   cfg.Redaction.Disabled,
+		Strict:     cfg.Redaction.Strict,
+		Patterns:   cfg.Redaction.Patterns,
+		MinEntropy: cfg.Redaction.MinEntropy,
+		Secrets:    secrets,
+	})
+}
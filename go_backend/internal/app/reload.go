@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/agent"
+	"mix/internal/llm/models"
+	"mix/internal/logging"
+	"mix/internal/pubsub"
+)
+
+// mcpToolsTimeout bounds listing the tools of reloaded MCP servers
+const mcpToolsTimeout = 5 * time.Second
+
+// ReloadConfig reads the config files again and applies them: providers are
+// rebuilt for new models and keys, the OpenRouter catalog is fetched in the
+// background for a new OpenRouter key, MCP servers whose definition changed are
+// restarted and their tools listed again, command tools are rebuilt,
+// redaction picks up new patterns and credentials, and budgets and permission
+// settings apply from the next use.
+// An invalid configuration is rejected and the current one stays active.
+// Successful reloads are published on ConfigChanges.
+func (a *App) ReloadConfig(ctx context.Context) (config.Change, error) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	previous := config.Get()
+	change, err := config.Reload()
+	if err != nil {
+		return change, err
+	}
+	current := config.Get()
+
+	if err := configureRedaction(current); err != nil {
+		// Patterns validated with the configuration, so this doesn't happen
+		logging.Error("Failed to apply redaction settings after config reload", "error", err)
+	}
+
+	if change.Has("mcpServers") || change.Has("compactTools") {
+		for name, server := range previous.MCPServers {
+			if next, ok := current.MCPServers[name]; !ok || !reflect.DeepEqual(server, next) {
+				a.mcpManager.CloseClient(name)
+			}
+		}
+		toolsCtx, cancel := context.WithTimeout(ctx, mcpToolsTimeout)
+		a.CoderAgent.SetMCPTools(agent.GetMcpTools(toolsCtx, a.Permissions, a.mcpManager))
+		cancel()
+	}
+	openRouter := models.ProviderOpenRouter
+	if !reflect.DeepEqual(previous.Providers[openRouter], current.Providers[openRouter]) && config.OpenRouterCatalogDue() {
+		go refreshOpenRouterCatalog(context.Background())
+	}
+	if change.Has("commandTools") {
+		a.CoderAgent.SetCommandTools(agent.CommandTools(a.Permissions))
+	}
+	if err := a.CoderAgent.ReloadProviders(); err != nil {
+		// The new configuration validated, so keep it; runs fail with the
+		// provider error until the configuration is fixed
+		logging.Error("Failed to rebuild providers after config reload", "error", err)
+	}
+
+	a.ConfigChanges.Publish(ctx, pubsub.UpdatedEvent, change)
+	return change, nil
+}
+
+// WatchConfig reloads the configuration whenever a config file changes, until
+// ctx is cancelled. Invalid edits are logged and leave the current
+// configuration active.
+func (a *App) WatchConfig(ctx context.Context) {
+	err := config.Watch(ctx, func() {
+		if _, err := a.ReloadConfig(ctx); err != nil {
+			logging.Error("Config reload rejected, keeping the current configuration", "error", err)
+		}
+	})
+	if err != nil {
+		logging.Warn("Failed to watch config files", "error", err)
+	}
+}
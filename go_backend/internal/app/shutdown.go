@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mix/internal/logging"
+)
+
+// shutdownStepTimeout bounds each subsystem so one stuck component cannot
+// keep the process from exiting
+const shutdownStepTimeout = 10 * time.Second
+
+// Shutdown stops subsystems in dependency order: scheduled jobs are cancelled
+// and agents drain first so their final messages are saved, their events
+// exported, their runs delivered to webhooks and their spans sent, then MCP
+// servers, file watches, media jobs, asset uploads and analytics are closed,
+// scheduled backups stop, and the database is checkpointed last.
+func (app *App) Shutdown() {
+	start := time.Now()
+
+	if app.jobs != nil {
+		shutdownStep("jobs", app.jobs.Stop)
+	}
+	if app.CoderAgent != nil {
+		shutdownStep("agents", app.CoderAgent.Shutdown)
+	}
+	if app.exporter != nil {
+		shutdownStep("event-export", app.exporter.Shutdown)
+	}
+	if app.webhooks != nil {
+		shutdownStep("webhooks", app.webhooks.Shutdown)
+	}
+	if app.tracer != nil {
+		shutdownStep("tracing", app.tracer.Shutdown)
+	}
+	if app.mcpManager != nil {
+		shutdownStep("mcp", func(ctx context.Context) error {
+			app.mcpManager.Close()
+			return nil
+		})
+	}
+	if app.FileWatch != nil {
+		shutdownStep("file-watch", app.FileWatch.Shutdown)
+	}
+	if app.AssetServer != nil {
+		shutdownStep("assets", app.AssetServer.Shutdown)
+	}
+	if app.Assets != nil {
+		shutdownStep("asset-uploads", app.Assets.Shutdown)
+	}
+	if app.Video != nil {
+		shutdownStep("video", app.Video.Shutdown)
+	}
+	if app.Analytics != nil {
+		shutdownStep("analytics", func(ctx context.Context) error {
+			return app.Analytics.Close()
+		})
+	}
+	if app.backups != nil {
+		shutdownStep("backups", app.backups.Stop)
+	}
+	if app.db != nil {
+		shutdownStep("database", app.closeDB)
+	}
+
+	logging.Info("Application shutdown completed", "duration", time.Since(start))
+}
+
+// closeDB folds the WAL back into the database file and closes the pool.
+func (app *App) closeDB(ctx context.Context) error {
+	if _, err := app.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE);"); err != nil {
+		logging.Warn("Failed to checkpoint database", "error", err)
+	}
+	return app.db.Close()
+}
+
+// shutdownStep runs stop with a timeout and logs how it went. A step that
+// times out is abandoned so the remaining steps still run.
+func shutdownStep(name string, stop func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownStepTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer logging.RecoverPanic("shutdown."+name, nil)
+		done <- stop(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		logging.Warn("Shutdown step timed out", "subsystem", name, "timeout", shutdownStepTimeout)
+	case err != nil:
+		logging.Error("Shutdown step failed", "subsystem", name, "duration", time.Since(start), "error", err)
+	default:
+		logging.Info("Shutdown step completed", "subsystem", name, "duration", time.Since(start))
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"mix/internal/config"
+	"mix/internal/session"
+	"mix/internal/sessiontemplate"
+)
+
+// CreateTemplate stores a session template after checking that the tools it
+// enables exist.
+func (a *App) CreateTemplate(ctx context.Context, template sessiontemplate.Template) (sessiontemplate.Template, error) {
+	known := a.toolNames()
+	for _, name := range template.EnabledTools {
+		if !slices.Contains(known, name) {
+			return sessiontemplate.Template{}, fmt.Errorf("unknown tool: %s", name)
+		}
+	}
+	return a.Templates.Create(ctx, template)
+}
+
+// ApplyTemplate starts a session from the named template. An empty title
+// uses the template's name, and an empty workingDirectory the template's,
+// or the launch directory if the template has none. The template's
+// directories are created in the working directory.
+func (a *App) ApplyTemplate(ctx context.Context, name, title, workingDirectory string) (session.Session, error) {
+	template, err := a.Templates.Get(ctx, name)
+	if err != nil {
+		return session.Session{}, err
+	}
+	if title == "" {
+		title = template.Name
+	}
+	if workingDirectory == "" {
+		workingDirectory = template.WorkingDirectory
+	}
+	if workingDirectory == "" {
+		if workingDirectory, err = config.LaunchDirectory(); err != nil {
+			return session.Session{}, fmt.Errorf("failed to get launch directory: %w", err)
+		}
+	}
+	if workingDirectory, err = filepath.Abs(workingDirectory); err != nil {
+		return session.Session{}, fmt.Errorf("invalid working directory: %w", err)
+	}
+	for _, dir := range template.Directories {
+		if err := os.MkdirAll(filepath.Join(workingDirectory, dir), 0o755); err != nil {
+			return session.Session{}, fmt.Errorf("failed to create working directory layout: %w", err)
+		}
+	}
+
+	sess, err := a.Sessions.Create(ctx, title, workingDirectory)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	if len(template.EnabledTools) > 0 {
+		var disable []string
+		for _, name := range a.toolNames() {
+			if !slices.Contains(template.EnabledTools, name) {
+				disable = append(disable, name)
+			}
+		}
+		if _, err := a.Sessions.SetToolsEnabled(ctx, sess.ID, nil, disable); err != nil {
+			return session.Session{}, fmt.Errorf("failed to set session tools: %w", err)
+		}
+	}
+	if template.Model != "" || template.SystemPrompt != "" {
+		if err := a.Sessions.SetAgentSettings(ctx, sess.ID, session.AgentSettings{
+			Model:        template.Model,
+			SystemPrompt: template.SystemPrompt,
+		}); err != nil {
+			return session.Session{}, fmt.Errorf("failed to set session agent settings: %w", err)
+		}
+	}
+	return sess, nil
+}
+
+func (a *App) toolNames() []string {
+	tools := a.CoderAgent.Tools()
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Info().Name
+	}
+	return names
+}
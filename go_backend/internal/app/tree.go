@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"mix/internal/message"
+	"mix/internal/session"
+)
+
+// TreeNode is a session in a fork tree.
+type TreeNode struct {
+	Session session.Session
+	// ForkPoint is the number of messages the session shares with its parent
+	ForkPoint int
+	// MessageCount counts all of the session's messages, BranchCost only the
+	// ones after the fork point, which the branch generated itself
+	MessageCount int
+	BranchCost   float64
+	Children     []string
+}
+
+// SessionTree returns the fork tree sessionID belongs to: the session's
+// topmost ancestor and all of its descendants, parents before children.
+func (a *App) SessionTree(ctx context.Context, sessionID string) ([]TreeNode, error) {
+	sessions, err := a.Sessions.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	byID := make(map[string]session.Session, len(sessions))
+	children := make(map[string][]string)
+	// Sessions are listed newest first; children are kept oldest first
+	for i := len(sessions) - 1; i >= 0; i-- {
+		sess := sessions[i]
+		byID[sess.ID] = sess
+		if sess.ParentSessionID != "" {
+			children[sess.ParentSessionID] = append(children[sess.ParentSessionID], sess.ID)
+		}
+	}
+
+	root, ok := byID[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+	// A deleted parent ends the walk; seen guards against cycles
+	seen := map[string]bool{root.ID: true}
+	for {
+		parent, ok := byID[root.ParentSessionID]
+		if !ok || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		root = parent
+	}
+
+	var nodes []TreeNode
+	msgs := make(map[string][]message.Message)
+	queue := []string{root.ID}
+	visited := make(map[string]bool)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		sess := byID[id]
+		sessionMessages, err := a.Messages.List(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages of session %s: %w", id, err)
+		}
+		msgs[id] = sessionMessages
+
+		node := TreeNode{Session: sess, MessageCount: len(sessionMessages), Children: children[id]}
+		if parentMessages, ok := msgs[sess.ParentSessionID]; ok && id != root.ID {
+			diff := message.Diff(parentMessages, sessionMessages)
+			node.ForkPoint = diff.ForkPoint
+			node.BranchCost = diff.Compare.Cost
+		} else {
+			for _, msg := range sessionMessages {
+				node.BranchCost += msg.Cost()
+			}
+		}
+		nodes = append(nodes, node)
+		queue = append(queue, children[id]...)
+	}
+	return nodes, nil
+}
+
+// SameForkTree reports whether two sessions belong to the same fork tree,
+// having a common ancestor, so their histories share a fork point.
+func (a *App) SameForkTree(ctx context.Context, sessionID, otherSessionID string) (bool, error) {
+	ancestors, err := a.sessionAncestry(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	shared := make(map[string]bool, len(ancestors))
+	for _, id := range ancestors {
+		shared[id] = true
+	}
+	otherAncestors, err := a.sessionAncestry(ctx, otherSessionID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range otherAncestors {
+		if shared[id] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sessionAncestry returns sessionID and the IDs of its ancestors, nearest
+// first. A deleted ancestor ends the list; its ID is still included, so
+// sessions forked from it are found related.
+func (a *App) sessionAncestry(ctx context.Context, sessionID string) ([]string, error) {
+	ids := []string{sessionID}
+	seen := map[string]bool{sessionID: true}
+	sess, err := a.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	for sess.ParentSessionID != "" && !seen[sess.ParentSessionID] {
+		parentID := sess.ParentSessionID
+		ids = append(ids, parentID)
+		seen[parentID] = true
+		sess, err = a.Sessions.Get(ctx, parentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session %s: %w", parentID, err)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"mix/internal/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forkSessions serves Get from sessions keyed by ID to their parent's ID
+type forkSessions struct {
+	session.Service
+	parents map[string]string
+}
+
+func (s forkSessions) Get(_ context.Context, id string) (session.Session, error) {
+	parent, ok := s.parents[id]
+	if !ok {
+		return session.Session{}, sql.ErrNoRows
+	}
+	return session.Session{ID: id, ParentSessionID: parent}, nil
+}
+
+func TestSameForkTree(t *testing.T) {
+	// root ─┬─ a ── a1
+	//       └─ b
+	// other
+	// deleted (gone) ─┬─ c
+	//                 └─ d
+	// loop1 ⇄ loop2
+	app := &App{Sessions: forkSessions{parents: map[string]string{
+		"root":  "",
+		"a":     "root",
+		"a1":    "a",
+		"b":     "root",
+		"other": "",
+		"c":     "deleted",
+		"d":     "deleted",
+		"loop1": "loop2",
+		"loop2": "loop1",
+	}}}
+
+	tests := []struct {
+		name     string
+		session  string
+		other    string
+		expected bool
+	}{
+		{name: "parent and fork", session: "root", other: "a", expected: true},
+		{name: "sibling forks", session: "a", other: "b", expected: true},
+		{name: "cousins through the root", session: "a1", other: "b", expected: true},
+		{name: "grandparent", session: "a1", other: "root", expected: true},
+		{name: "the same session", session: "b", other: "b", expected: true},
+		{name: "unrelated roots", session: "root", other: "other", expected: false},
+		{name: "forks of a deleted session", session: "c", other: "d", expected: true},
+		{name: "forks of a deleted session and another tree", session: "c", other: "a1", expected: false},
+		{name: "parent cycles end", session: "loop1", other: "root", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			related, err := app.SameForkTree(context.Background(), tt.session, tt.other)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, related)
+		})
+	}
+
+	_, err := app.SameForkTree(context.Background(), "missing", "root")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,150 @@
+// Package artifact stores full tool outputs that were too large to return to
+// the model, keyed by session and tool call ID, so they can be paged through
+// on demand by that session.
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"unicode/utf8"
+
+	"mix/internal/config"
+)
+
+// DefaultMaxOutputBytes is used when toolOutput.maxBytes is not configured
+const DefaultMaxOutputBytes = 50 * 1024
+
+var (
+	ErrNotFound  = errors.New("artifact not found")
+	ErrInvalidID = errors.New("invalid artifact id")
+
+	validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Chunk is a window into a stored artifact.
+type Chunk struct {
+	ID      string
+	Content string
+	Offset  int64
+	Size    int64
+}
+
+// More reports whether content remains after this chunk.
+func (c Chunk) More() bool {
+	return c.Offset+int64(len(c.Content)) < c.Size
+}
+
+// MaxOutputBytes is the size past which tool outputs are truncated.
+func MaxOutputBytes() int {
+	if max := config.Get().ToolOutput.MaxBytes; max > 0 {
+		return max
+	}
+	return DefaultMaxOutputBytes
+}
+
+func sessionDir(sessionID string) (string, error) {
+	if !validID.MatchString(sessionID) {
+		return "", ErrInvalidID
+	}
+	return filepath.Join(config.Get().Data.Directory, "artifacts", sessionID), nil
+}
+
+func path(sessionID, id string) (string, error) {
+	dir, err := sessionDir(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if !validID.MatchString(id) {
+		return "", ErrInvalidID
+	}
+	return filepath.Join(dir, id+".txt"), nil
+}
+
+// Store writes content as the artifact for a tool call of a session.
+func Store(sessionID, id, content string) error {
+	p, err := path(sessionID, id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+	return nil
+}
+
+// Read returns up to limit bytes of a session's artifact starting at offset.
+// Artifacts of other sessions are not found.
+func Read(sessionID, id string, offset, limit int64) (Chunk, error) {
+	p, err := path(sessionID, id)
+	if err != nil {
+		return Chunk{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Chunk{}, ErrNotFound
+		}
+		return Chunk{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return Chunk{}, err
+	}
+	if offset < 0 || offset > info.Size() {
+		return Chunk{}, fmt.Errorf("offset %d is outside the artifact (%d bytes)", offset, info.Size())
+	}
+	if limit <= 0 || limit > int64(MaxOutputBytes()) {
+		limit = int64(MaxOutputBytes())
+	}
+
+	buf := make([]byte, limit)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return Chunk{}, err
+	}
+	content := buf[:n]
+	if offset+int64(n) < info.Size() {
+		content = TrimPartialRune(content)
+	}
+	return Chunk{
+		ID:      id,
+		Content: string(content),
+		Offset:  offset,
+		Size:    info.Size(),
+	}, nil
+}
+
+// DeleteSession removes the artifacts of a session.
+func DeleteSession(sessionID string) error {
+	dir, err := sessionDir(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to delete artifacts: %w", err)
+	}
+	return nil
+}
+
+// TrimPartialRune drops a multi-byte character cut off at the end of b, so
+// chunks split on character boundaries.
+func TrimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
@@ -0,0 +1,157 @@
+package artifact
+
+import (
+	"strings"
+	"testing"
+
+	"mix/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupConfig(t *testing.T) {
+	t.Helper()
+	_, err := config.Load(t.TempDir(), false, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = t.TempDir()
+	config.Get().ToolOutput.MaxBytes = 8
+}
+
+func TestRead(t *testing.T) {
+	setupConfig(t)
+	require.NoError(t, Store("session-a", "toolu_1", "héllo wörld"))
+
+	tests := []struct {
+		name      string
+		sessionID string
+		id        string
+		offset    int64
+		limit     int64
+		expected  Chunk
+		more      bool
+		err       error
+		errText   string
+	}{
+		{
+			name:      "reads from the start up to the limit",
+			sessionID: "session-a",
+			id:        "toolu_1",
+			limit:     4,
+			expected:  Chunk{ID: "toolu_1", Content: "hél", Offset: 0, Size: 13},
+			more:      true,
+		},
+		{
+			name:      "chunks end on a character boundary",
+			sessionID: "session-a",
+			id:        "toolu_1",
+			limit:     2,
+			expected:  Chunk{ID: "toolu_1", Content: "h", Offset: 0, Size: 13},
+			more:      true,
+		},
+		{
+			name:      "limits above the maximum are capped",
+			sessionID: "session-a",
+			id:        "toolu_1",
+			offset:    4,
+			limit:     100,
+			expected:  Chunk{ID: "toolu_1", Content: "lo wörl", Offset: 4, Size: 13},
+			more:      true,
+		},
+		{
+			name:      "the last chunk has no more",
+			sessionID: "session-a",
+			id:        "toolu_1",
+			offset:    8,
+			expected:  Chunk{ID: "toolu_1", Content: "örld", Offset: 8, Size: 13},
+		},
+		{
+			name:      "offsets past the end fail",
+			sessionID: "session-a",
+			id:        "toolu_1",
+			offset:    14,
+			errText:   "outside the artifact",
+		},
+		{
+			name:      "other sessions' artifacts are not found",
+			sessionID: "session-b",
+			id:        "toolu_1",
+			err:       ErrNotFound,
+		},
+		{
+			name:      "missing artifacts are not found",
+			sessionID: "session-a",
+			id:        "toolu_2",
+			err:       ErrNotFound,
+		},
+		{
+			name:      "IDs can't leave the artifacts directory",
+			sessionID: "session-a",
+			id:        "../session-a/toolu_1",
+			err:       ErrInvalidID,
+		},
+		{
+			name:      "session IDs can't leave the artifacts directory",
+			sessionID: "..",
+			id:        "toolu_1",
+			err:       ErrInvalidID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk, err := Read(tt.sessionID, tt.id, tt.offset, tt.limit)
+			switch {
+			case tt.err != nil:
+				assert.ErrorIs(t, err, tt.err)
+			case tt.errText != "":
+				assert.ErrorContains(t, err, tt.errText)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, chunk)
+				assert.Equal(t, tt.more, chunk.More())
+			}
+		})
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	setupConfig(t)
+	require.NoError(t, Store("session-a", "toolu_1", "a"))
+	require.NoError(t, Store("session-b", "toolu_1", "b"))
+
+	require.NoError(t, DeleteSession("session-a"))
+
+	_, err := Read("session-a", "toolu_1", 0, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	chunk, err := Read("session-b", "toolu_1", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "b", chunk.Content)
+	assert.NoError(t, DeleteSession("session-c"))
+	assert.ErrorIs(t, DeleteSession("../"), ErrInvalidID)
+}
+
+func TestTrimPartialRune(t *testing.T) {
+	euro := "€" // three bytes
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "empty", input: "", expected: ""},
+		{name: "ASCII", input: "abc", expected: "abc"},
+		{name: "complete multi-byte character", input: "a" + euro, expected: "a" + euro},
+		{name: "one byte of three", input: "a" + euro[:1], expected: "a"},
+		{name: "two bytes of three", input: "a" + euro[:2], expected: "a"},
+		{name: "four-byte character cut", input: "a" + "😀"[:3], expected: "a"},
+		{name: "only a partial character", input: euro[:2], expected: ""},
+		{name: "invalid bytes are kept", input: "a\xff", expected: "a\xff"},
+		{name: "stray continuation bytes are kept", input: strings.Repeat("\x80", 5), expected: strings.Repeat("\x80", 5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, string(TrimPartialRune([]byte(tt.input))))
+		})
+	}
+}
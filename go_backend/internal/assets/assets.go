@@ -0,0 +1,311 @@
+// Package assets uploads files generated in session output directories to
+// object storage, so they can still be served after the local disk is gone.
+package assets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/db"
+	"mix/internal/logging"
+	"mix/internal/objectstore"
+)
+
+const (
+	// OutputDir is the working directory subdirectory whose files are uploaded
+	OutputDir = "output"
+
+	// LocationLocal marks assets that exist only on local disk
+	LocationLocal = "local"
+
+	DefaultURLExpiry = 15 * time.Minute
+)
+
+var ErrNotStored = errors.New("asset is not in artifact storage")
+
+// Asset is a generated file in a session's output directory, on local disk,
+// in artifact storage, or both.
+type Asset struct {
+	SessionID string
+	// Path is the absolute local path
+	Path string
+	// RelPath is Path relative to the session's working directory
+	RelPath string
+	Size    int64
+	// Local reports whether the file is still on local disk
+	Local bool
+	// Location is LocationLocal or the storage URI of the uploaded copy
+	Location   string
+	UploadedAt time.Time
+}
+
+type Service interface {
+	// Enabled reports whether artifact storage is configured
+	Enabled() bool
+	// Upload copies new and changed output files of a session to storage in
+	// the background.
+	Upload(sessionID, workingDir string)
+	// Sync uploads new and changed output files of a session.
+	Sync(ctx context.Context, sessionID, workingDir string) error
+	// List returns a session's output files, local and stored.
+	List(ctx context.Context, sessionID, workingDir string) ([]Asset, error)
+	// SignedURL returns a temporary download URL for the stored copy of the
+	// file at localPath, or ErrNotStored.
+	SignedURL(ctx context.Context, localPath string) (string, error)
+	// Shutdown waits for background uploads, or until ctx is done.
+	Shutdown(ctx context.Context) error
+}
+
+type service struct {
+	q          db.Querier
+	client     *objectstore.Client
+	expireDays int
+	urlExpiry  time.Duration
+
+	// mu serializes syncs so a file is never uploaded twice at once
+	mu      sync.Mutex
+	uploads sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewService returns a service that uploads to the bucket in cfg, or that
+// only lists local files when cfg is nil.
+func NewService(ctx context.Context, q db.Querier, cfg *config.ArtifactStorageConfig) (Service, error) {
+	uploadCtx, cancel := context.WithCancel(context.Background())
+	s := &service{q: q, urlExpiry: DefaultURLExpiry, ctx: uploadCtx, cancel: cancel}
+	if cfg == nil {
+		return s, nil
+	}
+
+	client, err := objectstore.New(ctx, objectstore.Config{
+		Provider: cfg.Provider,
+		Endpoint: cfg.Endpoint,
+		Bucket:   cfg.Bucket,
+		Prefix:   cfg.Prefix,
+		Region:   cfg.Region,
+		Profile:  cfg.Profile,
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if cfg.ExpireDays > 0 {
+		if err := client.ExpireAfter(ctx, cfg.ExpireDays); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	if cfg.URLExpiryMinutes > 0 {
+		s.urlExpiry = time.Duration(cfg.URLExpiryMinutes) * time.Minute
+	}
+	s.client = client
+	s.expireDays = cfg.ExpireDays
+	return s, nil
+}
+
+func (s *service) Enabled() bool {
+	return s.client != nil
+}
+
+func (s *service) Upload(sessionID, workingDir string) {
+	if s.client == nil || workingDir == "" {
+		return
+	}
+	s.uploads.Add(1)
+	go func() {
+		defer s.uploads.Done()
+		defer logging.RecoverPanic("assets-upload", nil)
+		if err := s.Sync(s.ctx, sessionID, workingDir); err != nil && s.ctx.Err() == nil {
+			logging.Error("Failed to upload session assets", "session", sessionID, "error", err)
+		}
+	}()
+}
+
+func (s *service) Sync(ctx context.Context, sessionID, workingDir string) error {
+	if s.client == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := outputFiles(workingDir)
+	if err != nil {
+		return err
+	}
+	rows, err := s.q.ListSessionAssets(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	uploaded := make(map[string]db.SessionAsset, len(rows))
+	for _, row := range rows {
+		uploaded[row.Path] = row
+	}
+
+	for _, file := range files {
+		if row, ok := uploaded[file.Path]; ok && !s.expired(row) &&
+			row.Size == file.Size && row.ModifiedAt == file.modified.UnixNano() {
+			continue
+		}
+		key := "sessions/" + sessionID + "/" + filepath.ToSlash(file.RelPath)
+		if err := s.put(ctx, key, file.Path, file.Size); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", file.RelPath, err)
+		}
+		if err := s.q.UpsertSessionAsset(ctx, db.UpsertSessionAssetParams{
+			SessionID:  sessionID,
+			Path:       file.Path,
+			ObjectKey:  key,
+			Size:       file.Size,
+			ModifiedAt: file.modified.UnixNano(),
+		}); err != nil {
+			return err
+		}
+		logging.Info("Uploaded session asset", "session", sessionID, "path", file.RelPath, "location", s.client.Location(key))
+	}
+	return nil
+}
+
+func (s *service) put(ctx context.Context, key, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.client.Put(ctx, key, f, size, mime.TypeByExtension(filepath.Ext(path)))
+}
+
+// expired reports whether the bucket's lifecycle rule has deleted the upload.
+func (s *service) expired(row db.SessionAsset) bool {
+	return s.expireDays > 0 && time.Since(time.Unix(row.UploadedAt, 0)) > time.Duration(s.expireDays)*24*time.Hour
+}
+
+func (s *service) List(ctx context.Context, sessionID, workingDir string) ([]Asset, error) {
+	files, err := outputFiles(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*Asset, len(files))
+	for i := range files {
+		files[i].Location = LocationLocal
+		byPath[files[i].Path] = &files[i].Asset
+	}
+
+	rows, err := s.q.ListSessionAssets(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if s.client == nil || s.expired(row) {
+			continue
+		}
+		asset, ok := byPath[row.Path]
+		if !ok {
+			asset = &Asset{SessionID: sessionID, Path: row.Path, RelPath: relPath(workingDir, row.Path), Size: row.Size}
+			byPath[row.Path] = asset
+		}
+		asset.Location = s.client.Location(row.ObjectKey)
+		asset.UploadedAt = time.Unix(row.UploadedAt, 0)
+	}
+
+	assets := make([]Asset, 0, len(byPath))
+	for _, asset := range byPath {
+		asset.SessionID = sessionID
+		assets = append(assets, *asset)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].RelPath < assets[j].RelPath })
+	return assets, nil
+}
+
+func (s *service) SignedURL(ctx context.Context, localPath string) (string, error) {
+	if s.client == nil {
+		return "", ErrNotStored
+	}
+	row, err := s.q.GetSessionAssetByPath(ctx, localPath)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && s.expired(row)) {
+		return "", ErrNotStored
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.client.SignedURL(ctx, row.ObjectKey, s.urlExpiry)
+}
+
+func (s *service) Shutdown(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.uploads.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type outputFile struct {
+	Asset
+	modified time.Time
+}
+
+// outputFiles lists the regular files under workingDir's output directory,
+// skipping hidden files such as thumbnail caches.
+func outputFiles(workingDir string) ([]outputFile, error) {
+	if workingDir == "" {
+		return nil, nil
+	}
+	root := filepath.Join(workingDir, OutputDir)
+	var files []outputFile
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, outputFile{
+			Asset: Asset{
+				Path:    path,
+				RelPath: relPath(workingDir, path),
+				Size:    info.Size(),
+				Local:   true,
+			},
+			modified: info.ModTime(),
+		})
+		return nil
+	})
+	return files, err
+}
+
+func relPath(workingDir, path string) string {
+	if rel, err := filepath.Rel(workingDir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
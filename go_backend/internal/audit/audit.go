@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"mix/internal/db"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxOutputLength is the number of bytes of tool output kept per entry
+	MaxOutputLength = 4096
+
+	DefaultListLimit = 100
+
+	PermissionAllowed = "allowed"
+	PermissionDenied  = "denied"
+	// PermissionBlocked marks calls stopped by the network egress policy
+	PermissionBlocked = "blocked"
+)
+
+type Entry struct {
+	ID                 string
+	SessionID          string
+	MessageID          string
+	ToolCallID         string
+	ToolName           string
+	Input              string
+	Output             string
+	OutputTruncated    bool
+	IsError            bool
+	Error              string
+	PermissionDecision string
+	Duration           time.Duration
+	// Cost is what the call spent on paid external APIs, in USD
+	Cost      float64
+	CreatedAt int64
+}
+
+// ToolCost totals a session's paid tool calls for one tool.
+type ToolCost struct {
+	ToolName string
+	Calls    int64
+	Cost     float64
+}
+
+// ListFilter narrows audit.list results. Zero values are ignored.
+type ListFilter struct {
+	SessionID string
+	ToolName  string
+	Since     time.Time
+	Until     time.Time
+	Limit     int64
+	Offset    int64
+}
+
+type Service interface {
+	Record(ctx context.Context, entry Entry) (Entry, error)
+	Get(ctx context.Context, id string) (Entry, error)
+	List(ctx context.Context, filter ListFilter) ([]Entry, error)
+	ToolCosts(ctx context.Context, sessionID string) ([]ToolCost, error)
+}
+
+type service struct {
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q}
+}
+
+func (s *service) Record(ctx context.Context, entry Entry) (Entry, error) {
+	output := entry.Output
+	truncated := false
+	if len(output) > MaxOutputLength {
+		output = strings.ToValidUTF8(output[:MaxOutputLength], "")
+		truncated = true
+	}
+
+	dbEntry, err := s.q.CreateToolAudit(ctx, db.CreateToolAuditParams{
+		ID:                 uuid.New().String(),
+		SessionID:          entry.SessionID,
+		MessageID:          entry.MessageID,
+		ToolCallID:         entry.ToolCallID,
+		ToolName:           entry.ToolName,
+		Input:              entry.Input,
+		Output:             output,
+		OutputTruncated:    truncated,
+		IsError:            entry.IsError,
+		Error:              sql.NullString{String: entry.Error, Valid: entry.Error != ""},
+		PermissionDecision: entry.PermissionDecision,
+		DurationMs:         entry.Duration.Milliseconds(),
+		Cost:               entry.Cost,
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	return fromDBItem(dbEntry), nil
+}
+
+func (s *service) Get(ctx context.Context, id string) (Entry, error) {
+	dbEntry, err := s.q.GetToolAudit(ctx, id)
+	if err != nil {
+		return Entry{}, err
+	}
+	return fromDBItem(dbEntry), nil
+}
+
+func (s *service) List(ctx context.Context, filter ListFilter) ([]Entry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+
+	params := db.ListToolAuditsParams{
+		SessionID: sql.NullString{String: filter.SessionID, Valid: filter.SessionID != ""},
+		ToolName:  sql.NullString{String: filter.ToolName, Valid: filter.ToolName != ""},
+		Limit:     limit,
+		Offset:    filter.Offset,
+	}
+	if !filter.Since.IsZero() {
+		params.Since = sql.NullInt64{Int64: filter.Since.Unix(), Valid: true}
+	}
+	if !filter.Until.IsZero() {
+		params.Until = sql.NullInt64{Int64: filter.Until.Unix(), Valid: true}
+	}
+
+	dbEntries, err := s.q.ListToolAudits(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(dbEntries))
+	for i, dbEntry := range dbEntries {
+		entries[i] = fromDBItem(dbEntry)
+	}
+	return entries, nil
+}
+
+// ToolCosts sums the cost of a session's paid tool calls per tool, most
+// expensive first.
+func (s *service) ToolCosts(ctx context.Context, sessionID string) ([]ToolCost, error) {
+	rows, err := s.q.SummarizeToolCosts(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	costs := make([]ToolCost, len(rows))
+	for i, row := range rows {
+		costs[i] = ToolCost{ToolName: row.ToolName, Calls: row.Calls, Cost: row.Cost}
+	}
+	return costs, nil
+}
+
+func fromDBItem(item db.ToolAudit) Entry {
+	return Entry{
+		ID:                 item.ID,
+		SessionID:          item.SessionID,
+		MessageID:          item.MessageID,
+		ToolCallID:         item.ToolCallID,
+		ToolName:           item.ToolName,
+		Input:              item.Input,
+		Output:             item.Output,
+		OutputTruncated:    item.OutputTruncated,
+		IsError:            item.IsError,
+		Error:              item.Error.String,
+		PermissionDecision: item.PermissionDecision,
+		Duration:           time.Duration(item.DurationMs) * time.Millisecond,
+		Cost:               item.Cost,
+		CreatedAt:          item.CreatedAt,
+	}
+}
@@ -0,0 +1,322 @@
+// Package backup snapshots the SQLite database and the encrypted credentials
+// into compressed archives, keeps the newest few in a directory or an
+// S3-compatible bucket, and restores them.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/provider"
+	"mix/internal/logging"
+)
+
+const (
+	DefaultIntervalHours = 24
+	DefaultKeep          = 7
+
+	namePrefix = "mix-backup-"
+	nameSuffix = ".tar.gz"
+	timeLayout = "20060102T150405Z"
+
+	// Archive entries
+	databaseEntry    = "mix.db"
+	credentialsEntry = "credentials"
+)
+
+// Backup is one archive in a store.
+type Backup struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	Size      int64     `json:"size"`
+}
+
+// parseName reads the creation time from a backup name, reporting false for
+// files that aren't backups.
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, namePrefix) || !strings.HasSuffix(name, nameSuffix) {
+		return time.Time{}, false
+	}
+	created, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), nameSuffix))
+	return created, err == nil
+}
+
+// Create snapshots the database with VACUUM INTO, which is consistent while
+// the app keeps writing, archives it with the credentials and saves it to
+// store. Backups beyond the newest keep are then deleted.
+func Create(ctx context.Context, conn *sql.DB, store Store, keep int) (Backup, error) {
+	start := time.Now()
+	tmpDir, err := os.MkdirTemp("", "mix-backup-")
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshot := filepath.Join(tmpDir, databaseEntry)
+	if _, err := conn.ExecContext(ctx, "VACUUM INTO ?", snapshot); err != nil {
+		return Backup{}, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	var archive bytes.Buffer
+	if err := writeArchive(&archive, snapshot); err != nil {
+		return Backup{}, err
+	}
+
+	created := time.Now().UTC()
+	backup := Backup{
+		Name:      namePrefix + created.Format(timeLayout) + nameSuffix,
+		CreatedAt: created.Truncate(time.Second),
+		Size:      int64(archive.Len()),
+	}
+	if err := store.Put(ctx, backup.Name, archive.Bytes()); err != nil {
+		return Backup{}, fmt.Errorf("failed to save backup: %w", err)
+	}
+	logging.Info("Backup created", "name", backup.Name, "size", backup.Size, "duration", time.Since(start))
+
+	if err := Prune(ctx, store, keep); err != nil {
+		return backup, err
+	}
+	return backup, nil
+}
+
+// writeArchive writes the database snapshot and every file in the
+// credentials directory as a gzipped tar.
+func writeArchive(w io.Writer, snapshot string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := addFile(tw, snapshot, databaseEntry); err != nil {
+		return err
+	}
+
+	credDir, err := provider.CredentialsDir()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(credDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read credentials: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			if err := addFile(tw, filepath.Join(credDir, entry.Name()), path.Join(credentialsEntry, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return gz.Close()
+}
+
+func addFile(tw *tar.Writer, src, name string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{Name: name, Mode: 0o600, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes all but the newest keep backups. A keep of 0 keeps everything.
+func Prune(ctx context.Context, store Store, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	backups, err := store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	for _, old := range backups[min(keep, len(backups)):] {
+		if err := store.Delete(ctx, old.Name); err != nil {
+			return fmt.Errorf("failed to delete old backup %s: %w", old.Name, err)
+		}
+		logging.Info("Deleted old backup", "name", old.Name)
+	}
+	return nil
+}
+
+// Restore replaces the database in dataDir and the stored credentials with
+// the contents of a backup. The files it replaces are kept next to the new
+// ones with a .before-restore suffix. Nothing may have the database open.
+func Restore(ctx context.Context, store Store, name, dataDir string) error {
+	if _, ok := parseName(name); !ok {
+		return fmt.Errorf("%s is not a backup name", name)
+	}
+	rc, err := store.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	defer rc.Close()
+
+	tmpDir, err := os.MkdirTemp("", "mix-restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files, err := extractArchive(rc, tmpDir)
+	if err != nil {
+		return err
+	}
+	if !files[databaseEntry] {
+		return fmt.Errorf("backup %s has no database", name)
+	}
+	if err := checkDatabase(ctx, filepath.Join(tmpDir, databaseEntry)); err != nil {
+		return err
+	}
+
+	// The WAL and shared memory files follow the database so the old copy
+	// stays consistent under its new name
+	dbPath := filepath.Join(dataDir, databaseEntry)
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(dbPath+suffix, dbPath+".before-restore"+suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move the current database aside: %w", err)
+		}
+	}
+	if err := copyFile(filepath.Join(tmpDir, databaseEntry), dbPath); err != nil {
+		return err
+	}
+
+	credDir, err := provider.CredentialsDir()
+	if err != nil {
+		return err
+	}
+	for entry := range files {
+		if entry == databaseEntry {
+			continue
+		}
+		if err := os.MkdirAll(credDir, 0o700); err != nil {
+			return fmt.Errorf("failed to create credentials directory: %w", err)
+		}
+		dst := filepath.Join(credDir, path.Base(entry))
+		if err := os.Rename(dst, dst+".before-restore"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move current credentials aside: %w", err)
+		}
+		if err := copyFile(filepath.Join(tmpDir, filepath.FromSlash(entry)), dst); err != nil {
+			return err
+		}
+	}
+
+	logging.Info("Backup restored", "name", name, "database", dbPath)
+	return nil
+}
+
+// extractArchive unpacks the database and credentials entries into dir,
+// refusing anything else so a crafted archive can't write outside it.
+func extractArchive(r io.Reader, dir string) (map[string]bool, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("backup is not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string]bool)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup: %w", err)
+		}
+		name := header.Name
+		validCredential := path.Dir(name) == credentialsEntry && path.Base(name) != ".."
+		if header.Typeflag != tar.TypeReg || (name != databaseEntry && !validCredential) {
+			return nil, fmt.Errorf("unexpected entry %q in backup", name)
+		}
+
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(file, tr)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		files[name] = true
+	}
+	return files, nil
+}
+
+// checkDatabase runs SQLite's integrity check on a restored database before
+// it replaces the current one.
+func checkDatabase(ctx context.Context, dbPath string) error {
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup database: %w", err)
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("failed to check backup database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup database is corrupt: %s", result)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return out.Close()
+}
+
+// Settings returns the backup config with defaults applied.
+func Settings() config.BackupConfig {
+	cfg := config.Get().Backup
+	if cfg.Directory == "" {
+		cfg.Directory = filepath.Join(config.Get().Data.Directory, "backups")
+	}
+	if cfg.IntervalHours == 0 {
+		cfg.IntervalHours = DefaultIntervalHours
+	}
+	if cfg.Keep == 0 {
+		cfg.Keep = DefaultKeep
+	}
+	return cfg
+}
@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/logging"
+)
+
+// startupDelay keeps an overdue backup from competing with startup work
+const startupDelay = time.Minute
+
+// Scheduler takes a backup every interval, counted from the newest backup in
+// the store so restarts don't reset the clock.
+type Scheduler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start runs scheduled backups of conn until Stop is called.
+func Start(conn *sql.DB, cfg config.BackupConfig) (*Scheduler, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store, err := NewStore(ctx, cfg)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &Scheduler{cancel: cancel, done: make(chan struct{})}
+	go s.run(ctx, conn, store, time.Duration(cfg.IntervalHours)*time.Hour, cfg.Keep)
+	logging.Info("Backups scheduled", "intervalHours", cfg.IntervalHours, "keep", cfg.Keep)
+	return s, nil
+}
+
+func (s *Scheduler) run(ctx context.Context, conn *sql.DB, store Store, interval time.Duration, keep int) {
+	defer close(s.done)
+	defer logging.RecoverPanic("backup-scheduler", nil)
+
+	wait := startupDelay
+	if backups, err := store.List(ctx); err != nil {
+		logging.Warn("Failed to list backups", "error", err)
+	} else if len(backups) > 0 {
+		wait = max(wait, time.Until(backups[0].CreatedAt.Add(interval)))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if _, err := Create(ctx, conn, store, keep); err != nil && ctx.Err() == nil {
+				logging.Error("Scheduled backup failed", "error", err)
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// Stop cancels any backup in progress and waits for the scheduler to exit.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
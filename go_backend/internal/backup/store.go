@@ -0,0 +1,136 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"mix/internal/config"
+	"mix/internal/objectstore"
+)
+
+// Store keeps backup archives. List returns only backups, newest first.
+type Store interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	List(ctx context.Context) ([]Backup, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// NewStore returns the S3 store when cfg.S3 is set and the directory store
+// otherwise.
+func NewStore(ctx context.Context, cfg config.BackupConfig) (Store, error) {
+	if cfg.S3 != nil {
+		return newS3Store(ctx, *cfg.S3)
+	}
+	if cfg.Directory == "" {
+		return nil, fmt.Errorf("backup directory is not set")
+	}
+	return &dirStore{dir: cfg.Directory}, nil
+}
+
+func sortNewestFirst(backups []Backup) {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+}
+
+type dirStore struct {
+	dir string
+}
+
+func (s *dirStore) Put(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a truncated backup
+	tmp := filepath.Join(s.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+func (s *dirStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+func (s *dirStore) List(ctx context.Context) ([]Backup, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, entry := range entries {
+		created, ok := parseName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{Name: entry.Name(), CreatedAt: created, Size: info.Size()})
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+func (s *dirStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// s3Store keeps backups in an S3-compatible bucket.
+type s3Store struct {
+	client *objectstore.Client
+}
+
+func newS3Store(ctx context.Context, cfg config.S3BackupConfig) (*s3Store, error) {
+	client, err := objectstore.New(ctx, objectstore.Config{
+		Provider: objectstore.ProviderS3,
+		Endpoint: cfg.Endpoint,
+		Bucket:   cfg.Bucket,
+		Prefix:   cfg.Prefix,
+		Region:   cfg.Region,
+		Profile:  cfg.Profile,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Store{client: client}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, name string, data []byte) error {
+	return s.client.Put(ctx, name, bytes.NewReader(data), int64(len(data)), "application/gzip")
+}
+
+func (s *s3Store) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return s.client.Get(ctx, name)
+}
+
+func (s *s3Store) List(ctx context.Context) ([]Backup, error) {
+	objects, err := s.client.List(ctx, namePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, object := range objects {
+		if created, ok := parseName(object.Key); ok {
+			backups = append(backups, Backup{Name: object.Key, CreatedAt: created, Size: object.Size})
+		}
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, name string) error {
+	return s.client.Delete(ctx, name)
+}
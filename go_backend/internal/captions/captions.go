@@ -0,0 +1,193 @@
+package captions
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatSRT Format = "srt"
+	FormatVTT Format = "vtt"
+)
+
+// Cue is one caption: text shown from Start until End.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// FormatFromPath picks the caption format from a file extension.
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".srt":
+		return FormatSRT, nil
+	case ".vtt":
+		return FormatVTT, nil
+	}
+	return "", fmt.Errorf("unsupported caption file %s: use a .srt or .vtt extension", path)
+}
+
+// timingPattern matches "00:00:01,000 --> 00:00:02,500" in SRT and
+// "00:01.000 --> 00:02.500 align:start" in VTT, where hours are optional.
+var timingPattern = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})\s+-->\s+((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})`)
+
+// Parse reads SRT or WebVTT captions. VTT header, NOTE, STYLE and REGION
+// blocks and cue settings are dropped.
+func Parse(data string) ([]Cue, error) {
+	var cues []Cue
+	var current *Cue
+	var lines []string
+
+	flush := func() {
+		if current != nil {
+			current.Text = strings.Join(lines, "\n")
+			cues = append(cues, *current)
+		}
+		current, lines = nil, nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(data, "\r\n", "\n")))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if current != nil {
+			lines = append(lines, line)
+			continue
+		}
+		m := timingPattern.FindStringSubmatch(line)
+		if m == nil {
+			// Cue identifiers, the WEBVTT header and metadata blocks
+			continue
+		}
+		start, err := ParseTimestamp(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		end, err := ParseTimestamp(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		current = &Cue{Start: start, End: end}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("no caption cues found")
+	}
+	return cues, nil
+}
+
+// ParseTimestamp reads an SRT or VTT timestamp such as 00:01:02,500 or 01:02.500.
+func ParseTimestamp(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	parts := strings.Split(s, ":")
+	var total float64
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		total = total*60 + v
+	}
+	return time.Duration(total * float64(time.Second)).Round(time.Millisecond), nil
+}
+
+// Render writes cues as SRT or WebVTT.
+func Render(cues []Cue, format Format) string {
+	var b strings.Builder
+	separator := ","
+	if format == FormatVTT {
+		b.WriteString("WEBVTT\n\n")
+		separator = "."
+	}
+	for i, cue := range cues {
+		if format == FormatSRT {
+			fmt.Fprintf(&b, "%d\n", i+1)
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatTimestamp(cue.Start, separator), formatTimestamp(cue.End, separator), cue.Text)
+	}
+	return b.String()
+}
+
+func formatTimestamp(d time.Duration, separator string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+// Validate reports cues with empty text, non-positive durations or overlaps
+// with the previous cue.
+func Validate(cues []Cue) error {
+	for i, cue := range cues {
+		if strings.TrimSpace(cue.Text) == "" {
+			return fmt.Errorf("cue %d has no text", i+1)
+		}
+		if cue.Start < 0 {
+			return fmt.Errorf("cue %d starts before 0", i+1)
+		}
+		if cue.End <= cue.Start {
+			return fmt.Errorf("cue %d ends at %s, not after its start %s", i+1, formatTimestamp(cue.End, "."), formatTimestamp(cue.Start, "."))
+		}
+		if i > 0 && cue.Start < cues[i-1].End {
+			return fmt.Errorf("cue %d starts at %s, before cue %d ends at %s", i+1, formatTimestamp(cue.Start, "."), i, formatTimestamp(cues[i-1].End, "."))
+		}
+	}
+	return nil
+}
+
+// Shift moves every cue by offset, dropping cues that end up entirely before
+// 0 and clamping the rest.
+func Shift(cues []Cue, offset time.Duration) []Cue {
+	result := make([]Cue, 0, len(cues))
+	for _, cue := range cues {
+		cue.Start += offset
+		cue.End += offset
+		if cue.End <= 0 {
+			continue
+		}
+		if cue.Start < 0 {
+			cue.Start = 0
+		}
+		result = append(result, cue)
+	}
+	return result
+}
+
+// Scale multiplies every timestamp by factor, e.g. to follow a sped up edit
+// or to fix drift between 25 and 23.976 fps.
+func Scale(cues []Cue, factor float64) []Cue {
+	result := make([]Cue, len(cues))
+	for i, cue := range cues {
+		cue.Start = time.Duration(float64(cue.Start) * factor).Round(time.Millisecond)
+		cue.End = time.Duration(float64(cue.End) * factor).Round(time.Millisecond)
+		result[i] = cue
+	}
+	return result
+}
+
+// Duration is the end of the last cue.
+func Duration(cues []Cue) time.Duration {
+	var end time.Duration
+	for _, cue := range cues {
+		end = max(end, cue.End)
+	}
+	return end
+}
@@ -0,0 +1,114 @@
+package captions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MediaInfo is the subset of ffprobe output the agent needs to check a render.
+type MediaInfo struct {
+	Path     string       `json:"path"`
+	Duration float64      `json:"duration"`
+	Format   string       `json:"format"`
+	Size     int64        `json:"size"`
+	Streams  []StreamInfo `json:"streams"`
+}
+
+type StreamInfo struct {
+	Type     string  `json:"type"`
+	Codec    string  `json:"codec"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// Probe reads duration, container and stream metadata with ffprobe.
+func Probe(ctx context.Context, path string) (MediaInfo, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration,format_name,size:stream=codec_type,codec_name,width,height,duration",
+		"-of", "json",
+		path,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return MediaInfo{}, fmt.Errorf("ffprobe failed: %v, output: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return MediaInfo{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Format struct {
+			Duration   string `json:"duration"`
+			FormatName string `json:"format_name"`
+			Size       string `json:"size"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+			Duration  string `json:"duration"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return MediaInfo{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	info := MediaInfo{Path: path, Format: probe.Format.FormatName}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	info.Size, _ = strconv.ParseInt(probe.Format.Size, 10, 64)
+	for _, s := range probe.Streams {
+		stream := StreamInfo{Type: s.CodecType, Codec: s.CodecName, Width: s.Width, Height: s.Height}
+		stream.Duration, _ = strconv.ParseFloat(s.Duration, 64)
+		info.Streams = append(info.Streams, stream)
+	}
+	return info, nil
+}
+
+// Burn renders captions into the video frames with ffmpeg's subtitles filter,
+// copying the audio unchanged. style is an optional ASS force_style such as
+// "FontSize=24,Outline=2".
+func Burn(ctx context.Context, videoPath, captionsPath, outputPath, style string) error {
+	filter := "subtitles=filename=" + escapeFilterValue(captionsPath)
+	if style != "" {
+		filter += ":force_style=" + escapeFilterValue(style)
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-nostdin",
+		"-i", videoPath,
+		"-vf", filter,
+		"-c:a", "copy",
+		"-y",
+		outputPath,
+	)
+	cmd.Dir = filepath.Dir(outputPath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v, output: %s", err, tail(string(output), 2000))
+	}
+	return nil
+}
+
+// escapeFilterValue escapes a filter option value twice, once for the option
+// parser and once for the filtergraph parser, so paths with colons, commas or
+// quotes survive.
+func escapeFilterValue(value string) string {
+	option := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(value)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(option)
+}
+
+// tail keeps the end of ffmpeg's log, where the error is.
+func tail(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
@@ -0,0 +1,241 @@
+package captions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Layout limits how much text a cue holds. Cues have at most two lines; the
+// defaults follow common broadcast guidelines of 42 characters per line and
+// at most 7 seconds on screen.
+type Layout struct {
+	MaxLineChars int
+	MaxDuration  time.Duration
+}
+
+var DefaultLayout = Layout{MaxLineChars: 42, MaxDuration: 7 * time.Second}
+
+// wordGap ends a cue built from word timings when the speaker pauses
+const wordGap = 700 * time.Millisecond
+
+type timedText struct {
+	start, end time.Duration
+	text       string
+}
+
+// FromTranscript builds cues from a transcription result: Whisper style JSON
+// with "segments" or "words" that carry start and end times, anywhere in the
+// document. Word timings are preferred since they allow tighter cues.
+func FromTranscript(data []byte, layout Layout) ([]Cue, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("transcript is not JSON: %w", err)
+	}
+	if words := findTimed(doc, "words"); len(words) > 0 {
+		return fromWords(words, layout), nil
+	}
+	if segments := findTimed(doc, "segments"); len(segments) > 0 {
+		return fromSegments(segments, layout), nil
+	}
+	return nil, fmt.Errorf("transcript has no timed segments or words; transcribe with timestamps, or pass plain text with media_path")
+}
+
+// FromText spreads untimed text over duration in proportion to its length.
+func FromText(text string, duration time.Duration, layout Layout) ([]Cue, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("transcript is empty")
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive to time plain text captions")
+	}
+	return fromSegments([]timedText{{start: 0, end: duration, text: text}}, layout), nil
+}
+
+// findTimed returns the first array under key whose entries have start, end
+// and text (or word) fields, searching depth first.
+func findTimed(node any, key string) []timedText {
+	switch node := node.(type) {
+	case map[string]any:
+		if items, ok := node[key].([]any); ok {
+			if timed := parseTimed(items); len(timed) > 0 {
+				return timed
+			}
+		}
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if timed := findTimed(node[k], key); len(timed) > 0 {
+				return timed
+			}
+		}
+	case []any:
+		// Batch results hold one transcript per file; only use the first
+		for _, item := range node {
+			if timed := findTimed(item, key); len(timed) > 0 {
+				return timed
+			}
+		}
+	}
+	return nil
+}
+
+func parseTimed(items []any) []timedText {
+	result := make([]timedText, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil
+		}
+		start, okStart := jsonSeconds(obj["start"])
+		end, okEnd := jsonSeconds(obj["end"])
+		text, okText := obj["text"].(string)
+		if !okText {
+			text, okText = obj["word"].(string)
+		}
+		if !okStart || !okEnd || !okText {
+			return nil
+		}
+		result = append(result, timedText{start: start, end: end, text: text})
+	}
+	return result
+}
+
+// jsonSeconds reads a time given in seconds or as a timestamp string.
+func jsonSeconds(v any) (time.Duration, bool) {
+	switch v := v.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second)).Round(time.Millisecond), true
+	case string:
+		d, err := ParseTimestamp(v)
+		return d, err == nil
+	}
+	return 0, false
+}
+
+func fromWords(words []timedText, layout Layout) []Cue {
+	var cues []Cue
+	var current []timedText
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		texts := make([]string, len(current))
+		for i, w := range current {
+			texts[i] = strings.TrimSpace(w.text)
+		}
+		cues = append(cues, Cue{
+			Start: current[0].start,
+			End:   current[len(current)-1].end,
+			Text:  wrap(strings.Join(texts, " "), layout.MaxLineChars),
+		})
+		current = nil
+	}
+
+	maxChars := 2 * layout.MaxLineChars
+	chars := 0
+	for _, w := range words {
+		text := strings.TrimSpace(w.text)
+		if text == "" {
+			continue
+		}
+		if len(current) > 0 {
+			last := current[len(current)-1]
+			if chars+1+utf8.RuneCountInString(text) > maxChars ||
+				w.end-current[0].start > layout.MaxDuration ||
+				w.start-last.end > wordGap ||
+				endsSentence(last.text) {
+				flush()
+				chars = 0
+			} else {
+				chars++
+			}
+		}
+		current = append(current, w)
+		chars += utf8.RuneCountInString(text)
+	}
+	flush()
+	return cues
+}
+
+func endsSentence(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "!")
+}
+
+// fromSegments splits segments that exceed the layout into chunks of whole
+// words, timed in proportion to their length.
+func fromSegments(segments []timedText, layout Layout) []Cue {
+	var cues []Cue
+	maxChars := 2 * layout.MaxLineChars
+	for _, seg := range segments {
+		words := strings.Fields(seg.text)
+		if len(words) == 0 || seg.end <= seg.start {
+			continue
+		}
+		total := utf8.RuneCountInString(strings.Join(words, " "))
+		span := seg.end - seg.start
+		// Enough chunks to satisfy both the length and the duration limit
+		chunks := max((total+maxChars-1)/maxChars, int((span+layout.MaxDuration-1)/layout.MaxDuration), 1)
+		target := (total + chunks - 1) / chunks
+
+		var chunk []string
+		chunkChars, done := 0, 0
+		emit := func() {
+			text := strings.Join(chunk, " ")
+			n := utf8.RuneCountInString(text)
+			start := seg.start + time.Duration(float64(span)*float64(done)/float64(total))
+			done = min(done+n+1, total)
+			end := seg.start + time.Duration(float64(span)*float64(done)/float64(total))
+			cues = append(cues, Cue{
+				Start: start.Round(time.Millisecond),
+				End:   end.Round(time.Millisecond),
+				Text:  wrap(text, layout.MaxLineChars),
+			})
+			chunk, chunkChars = nil, 0
+		}
+		for _, word := range words {
+			n := utf8.RuneCountInString(word)
+			if len(chunk) > 0 && (chunkChars+1+n > maxChars || chunkChars >= target) {
+				emit()
+			}
+			if len(chunk) > 0 {
+				chunkChars++
+			}
+			chunk = append(chunk, word)
+			chunkChars += n
+		}
+		emit()
+	}
+	return cues
+}
+
+// wrap breaks text into two balanced lines when it does not fit on one.
+func wrap(text string, maxLineChars int) string {
+	if utf8.RuneCountInString(text) <= maxLineChars {
+		return text
+	}
+	words := strings.Fields(text)
+	best, bestDiff := -1, -1
+	for i := 1; i < len(words); i++ {
+		first := utf8.RuneCountInString(strings.Join(words[:i], " "))
+		second := utf8.RuneCountInString(strings.Join(words[i:], " "))
+		diff := first - second
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	if best < 0 {
+		return text
+	}
+	return strings.Join(words[:best], " ") + "\n" + strings.Join(words[best:], " ")
+}
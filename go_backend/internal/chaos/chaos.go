@@ -0,0 +1,171 @@
+// Package chaos injects faults at configurable rates so the retry, recovery
+// and cancellation paths can be exercised continuously in CI. It is disabled
+// unless enabled in the config or through the MIX_CHAOS environment variable.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/logging"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// EnvVar holds comma-separated fault=rate pairs, e.g.
+// "provider_error=0.1,stream_truncate=0.05,seed=42". Setting it enables chaos mode.
+const EnvVar = "MIX_CHAOS"
+
+type Fault string
+
+const (
+	// ProviderError answers a provider API call with a 529 overloaded response
+	ProviderError Fault = "provider_error"
+	// StreamTruncate cuts a provider stream short as a dropped connection would
+	StreamTruncate Fault = "stream_truncate"
+	// ToolTimeout fails a tool call with a deadline exceeded error
+	ToolTimeout Fault = "tool_timeout"
+	// DBLock fails a database statement with SQLITE_BUSY
+	DBLock Fault = "db_lock"
+)
+
+type injector struct {
+	mu    sync.Mutex
+	rng   *rand.Rand
+	rates map[Fault]float64
+}
+
+var active *injector
+
+// Init enables fault injection from cfg, overridden by MIX_CHAOS when set.
+func Init(cfg config.ChaosConfig) error {
+	if env := os.Getenv(EnvVar); env != "" {
+		parsed, err := parseEnv(env)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvVar, err)
+		}
+		cfg = parsed
+	}
+	if !cfg.Enabled {
+		active = nil
+		return nil
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	active = &injector{
+		rng: rand.New(rand.NewSource(seed)),
+		rates: map[Fault]float64{
+			ProviderError:  cfg.ProviderErrorRate,
+			StreamTruncate: cfg.StreamTruncateRate,
+			ToolTimeout:    cfg.ToolTimeoutRate,
+			DBLock:         cfg.DBLockRate,
+		},
+	}
+	logging.Warn("Chaos mode enabled, faults will be injected", "seed", seed, "rates", active.rates)
+	return nil
+}
+
+// Enabled reports whether chaos mode is on.
+func Enabled() bool {
+	return active != nil
+}
+
+// Should reports whether fault f should be injected now.
+func Should(f Fault) bool {
+	if active == nil {
+		return false
+	}
+	active.mu.Lock()
+	defer active.mu.Unlock()
+	rate := active.rates[f]
+	if rate <= 0 || active.rng.Float64() >= rate {
+		return false
+	}
+	logging.Warn("Chaos: injecting fault", "fault", f)
+	return true
+}
+
+// Intn returns a random number in [0, n) from the seeded source.
+func Intn(n int) int {
+	if active == nil || n <= 0 {
+		return 0
+	}
+	active.mu.Lock()
+	defer active.mu.Unlock()
+	return active.rng.Intn(n)
+}
+
+// Inject returns the error for f if it should be injected now, otherwise nil.
+func Inject(f Fault) error {
+	if !Should(f) {
+		return nil
+	}
+	return Error(f)
+}
+
+// Error is the error a fault produces. It wraps the error the real failure
+// would surface so callers handle it the same way.
+func Error(f Fault) error {
+	var cause error
+	switch f {
+	case StreamTruncate:
+		cause = io.ErrUnexpectedEOF
+	case ToolTimeout:
+		cause = context.DeadlineExceeded
+	case DBLock:
+		cause = sqlite3.BUSY
+	default:
+		return fmt.Errorf("chaos: injected %s", f)
+	}
+	return fmt.Errorf("chaos: injected %s: %w", f, cause)
+}
+
+func parseEnv(env string) (config.ChaosConfig, error) {
+	cfg := config.ChaosConfig{Enabled: true}
+	for _, pair := range strings.Split(env, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" || pair == "1" || pair == "true" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return cfg, fmt.Errorf("expected fault=rate, got %q", pair)
+		}
+		if key == "seed" {
+			seed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid seed %q: %w", value, err)
+			}
+			cfg.Seed = seed
+			continue
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return cfg, fmt.Errorf("rate for %s must be between 0 and 1, got %q", key, value)
+		}
+		switch Fault(key) {
+		case ProviderError:
+			cfg.ProviderErrorRate = rate
+		case StreamTruncate:
+			cfg.StreamTruncateRate = rate
+		case ToolTimeout:
+			cfg.ToolTimeoutRate = rate
+		case DBLock:
+			cfg.DBLockRate = rate
+		default:
+			return cfg, fmt.Errorf("unknown fault %q", key)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,69 @@
+package chaos
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const overloadedBody = `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded (injected by chaos mode)"}}`
+
+// ProviderMiddleware answers provider API calls with a 529 at the
+// provider_error rate. It matches the middleware signature of the Anthropic
+// and OpenAI SDKs, so injected failures go through their error handling.
+func ProviderMiddleware(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if !Should(ProviderError) {
+		return next(req)
+	}
+	return &http.Response{
+		Status:     "529 Overloaded",
+		StatusCode: 529,
+		Proto:      req.Proto,
+		ProtoMajor: req.ProtoMajor,
+		ProtoMinor: req.ProtoMinor,
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+			"Retry-After":  []string{"1"},
+		},
+		Body:          io.NopCloser(strings.NewReader(overloadedBody)),
+		ContentLength: int64(len(overloadedBody)),
+		Request:       req,
+	}, nil
+}
+
+// DBTX matches db.DBTX so WrapDB can sit between the queries and the connection.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// WrapDB fails statements with SQLITE_BUSY at the db_lock rate. Single-row
+// queries are passed through since *sql.Row cannot carry a synthetic error.
+func WrapDB(db DBTX) DBTX {
+	if !Enabled() {
+		return db
+	}
+	return &lockingDB{DBTX: db}
+}
+
+type lockingDB struct {
+	DBTX
+}
+
+func (d *lockingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := Inject(DBLock); err != nil {
+		return nil, err
+	}
+	return d.DBTX.ExecContext(ctx, query, args...)
+}
+
+func (d *lockingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := Inject(DBLock); err != nil {
+		return nil, err
+	}
+	return d.DBTX.QueryContext(ctx, query, args...)
+}
@@ -0,0 +1,352 @@
+// Package checkpoint records the file changes of each agent turn so they can
+// be reverted. The working directory is snapshotted into a private git
+// repository under the data directory before the turn's first tool that could
+// write files and again when the turn ends; a turn that changed files leaves a
+// checkpoint. Restoring a checkpoint puts the files it changed back as they
+// were before its turn.
+package checkpoint
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"mix/internal/config"
+	"mix/internal/db"
+	"mix/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound = errors.New("checkpoint not found")
+	// ErrNothingToUndo is returned by Undo when every checkpoint of the
+	// session was restored already
+	ErrNothingToUndo = errors.New("no agent turn to undo")
+)
+
+// ConflictError is returned when files a restore would revert were changed
+// after the agent turns that wrote them.
+type ConflictError struct {
+	Paths []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("files changed since the agent wrote them: %s; restore with force to overwrite them", strings.Join(e.Paths, ", "))
+}
+
+// Checkpoint is the file changes of one agent turn.
+type Checkpoint struct {
+	ID        string
+	SessionID string
+	// MessageID is the user message that started the turn
+	MessageID        string
+	WorkingDirectory string
+	// Files are the paths the turn changed, relative to WorkingDirectory
+	Files     []string
+	CreatedAt int64
+	// RestoredAt is when the checkpoint was restored, zero if it wasn't
+	RestoredAt int64
+
+	beforeTree string
+	afterTree  string
+}
+
+// Restore reports the checkpoints a restore reverted, newest first, and the
+// files it wrote back and deleted.
+type Restore struct {
+	Checkpoints []Checkpoint
+	Restored    []string
+	Deleted     []string
+}
+
+type Service interface {
+	// Begin starts recording a turn's file changes in workingDirectory. It
+	// returns nil when checkpoints are disabled or unavailable; a nil Turn
+	// records nothing.
+	Begin(sessionID, messageID, workingDirectory string) *Turn
+	Get(ctx context.Context, id string) (Checkpoint, error)
+	// List returns the session's checkpoints, newest first
+	List(ctx context.Context, sessionID string) ([]Checkpoint, error)
+	// Restore reverts the checkpoint and every later one of its session not
+	// restored yet, returning the files they changed to how they were before
+	// the checkpoint's turn. Unless force is set, it fails with a
+	// ConflictError when any of the files changed since.
+	Restore(ctx context.Context, id string, force bool) (Restore, error)
+	// Undo restores the session's newest checkpoint not restored yet.
+	Undo(ctx context.Context, sessionID string, force bool) (Restore, error)
+}
+
+type service struct {
+	q   db.Querier
+	dir string
+	// locks serializes the use of each snapshot repository
+	locks sync.Map
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q, dir: filepath.Join(config.Get().Data.Directory, "checkpoints")}
+}
+
+func (s *service) Begin(sessionID, messageID, workingDirectory string) *Turn {
+	if workingDirectory == "" || config.Get().Checkpoints.Disabled {
+		return nil
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		logging.Warn("Checkpoints need git, which isn't installed; agent turns can't be undone")
+		return nil
+	}
+	return &Turn{s: s, sessionID: sessionID, messageID: messageID, workDir: workingDirectory}
+}
+
+// repo opens the snapshot repository of workDir and locks it until the
+// returned function is called.
+func (s *service) repo(ctx context.Context, workDir string) (*repo, func(), error) {
+	lock, _ := s.locks.LoadOrStore(workDir, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	r, err := openRepo(ctx, s.dir, workDir, config.Get().Checkpoints.MaxFileSizeMB)
+	if err != nil {
+		mu.Unlock()
+		return nil, nil, fmt.Errorf("failed to open snapshot repository: %w", err)
+	}
+	return r, mu.Unlock, nil
+}
+
+func (s *service) Get(ctx context.Context, id string) (Checkpoint, error) {
+	row, err := s.q.GetCheckpoint(ctx, id)
+	if err == sql.ErrNoRows {
+		return Checkpoint{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return fromRow(row)
+}
+
+func (s *service) List(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	rows, err := s.q.ListCheckpointsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]Checkpoint, len(rows))
+	for i, row := range rows {
+		if checkpoints[i], err = fromRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return checkpoints, nil
+}
+
+func (s *service) Undo(ctx context.Context, sessionID string, force bool) (Restore, error) {
+	checkpoints, err := s.List(ctx, sessionID)
+	if err != nil {
+		return Restore{}, err
+	}
+	for _, checkpoint := range checkpoints {
+		if checkpoint.RestoredAt == 0 {
+			return s.Restore(ctx, checkpoint.ID, force)
+		}
+	}
+	return Restore{}, ErrNothingToUndo
+}
+
+func (s *service) Restore(ctx context.Context, id string, force bool) (Restore, error) {
+	target, err := s.Get(ctx, id)
+	if err != nil {
+		return Restore{}, err
+	}
+	if target.RestoredAt != 0 {
+		return Restore{}, fmt.Errorf("checkpoint %s was already restored", id)
+	}
+	all, err := s.List(ctx, target.SessionID)
+	if err != nil {
+		return Restore{}, err
+	}
+	// The checkpoints to revert, newest first, down to the target
+	var reverted []Checkpoint
+	for _, checkpoint := range all {
+		if checkpoint.RestoredAt == 0 && checkpoint.WorkingDirectory == target.WorkingDirectory {
+			reverted = append(reverted, checkpoint)
+		}
+		if checkpoint.ID == id {
+			break
+		}
+	}
+
+	r, unlock, err := s.repo(ctx, target.WorkingDirectory)
+	if err != nil {
+		return Restore{}, err
+	}
+	defer unlock()
+
+	// Each file is expected as the newest turn that changed it left it, and
+	// goes back to how it was before the oldest one
+	newest := map[string]Checkpoint{}
+	oldest := map[string]Checkpoint{}
+	var paths []string
+	for _, checkpoint := range reverted {
+		for _, path := range checkpoint.Files {
+			if _, seen := newest[path]; !seen {
+				newest[path] = checkpoint
+				paths = append(paths, path)
+			}
+			oldest[path] = checkpoint
+		}
+	}
+	slices.Sort(paths)
+
+	if !force {
+		var conflicts []string
+		for _, path := range paths {
+			expected, err := r.blob(ctx, newest[path].afterTree, path)
+			if err != nil {
+				return Restore{}, err
+			}
+			current, err := r.current(ctx, path)
+			if err != nil {
+				return Restore{}, err
+			}
+			if current != expected {
+				conflicts = append(conflicts, path)
+			}
+		}
+		if len(conflicts) > 0 {
+			return Restore{}, &ConflictError{Paths: conflicts}
+		}
+	}
+
+	result := Restore{Checkpoints: reverted, Restored: []string{}, Deleted: []string{}}
+	byTree := map[string][]string{}
+	for _, path := range paths {
+		tree := oldest[path].beforeTree
+		previous, err := r.blob(ctx, tree, path)
+		if err != nil {
+			return Restore{}, err
+		}
+		if previous == "" {
+			if err := r.remove(path); err != nil {
+				return Restore{}, fmt.Errorf("failed to delete %s: %w", path, err)
+			}
+			result.Deleted = append(result.Deleted, path)
+			continue
+		}
+		byTree[tree] = append(byTree[tree], path)
+		result.Restored = append(result.Restored, path)
+	}
+	for tree, treePaths := range byTree {
+		if err := r.checkout(ctx, tree, treePaths); err != nil {
+			return Restore{}, fmt.Errorf("failed to restore files: %w", err)
+		}
+	}
+
+	for i := range result.Checkpoints {
+		if err := s.q.MarkCheckpointRestored(ctx, result.Checkpoints[i].ID); err != nil {
+			return result, err
+		}
+		restored, err := s.Get(ctx, result.Checkpoints[i].ID)
+		if err != nil {
+			return result, err
+		}
+		result.Checkpoints[i] = restored
+	}
+	return result, nil
+}
+
+// Turn records the file changes of one agent turn. Its methods may be called
+// on a nil Turn, which records nothing.
+type Turn struct {
+	s         *service
+	sessionID string
+	messageID string
+	workDir   string
+
+	once       sync.Once
+	beforeTree string
+}
+
+// Capture snapshots the working directory the first time it's called, so call
+// it before running any tool that could write files. Concurrent calls wait for
+// the snapshot.
+func (t *Turn) Capture(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		r, unlock, err := t.s.repo(ctx, t.workDir)
+		if err == nil {
+			defer unlock()
+			t.beforeTree, err = r.snapshot(ctx)
+		}
+		if err != nil {
+			logging.Warn("Failed to snapshot working directory, this turn can't be undone", "session", t.sessionID, "error", err)
+		}
+	})
+}
+
+// Finish snapshots the working directory again and records a checkpoint when
+// the turn changed files since Capture.
+func (t *Turn) Finish(ctx context.Context) {
+	if t == nil || t.beforeTree == "" {
+		return
+	}
+	if err := t.finish(ctx); err != nil {
+		logging.Warn("Failed to record checkpoint, this turn can't be undone", "session", t.sessionID, "error", err)
+	}
+}
+
+func (t *Turn) finish(ctx context.Context) error {
+	r, unlock, err := t.s.repo(ctx, t.workDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	afterTree, err := r.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := r.changed(ctx, t.beforeTree, afterTree)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	filesJSON, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	_, err = t.s.q.CreateCheckpoint(ctx, db.CreateCheckpointParams{
+		ID:               uuid.New().String(),
+		SessionID:        t.sessionID,
+		MessageID:        t.messageID,
+		WorkingDirectory: t.workDir,
+		BeforeTree:       t.beforeTree,
+		AfterTree:        afterTree,
+		Files:            string(filesJSON),
+	})
+	return err
+}
+
+func fromRow(row db.Checkpoint) (Checkpoint, error) {
+	checkpoint := Checkpoint{
+		ID:               row.ID,
+		SessionID:        row.SessionID,
+		MessageID:        row.MessageID,
+		WorkingDirectory: row.WorkingDirectory,
+		CreatedAt:        row.CreatedAt,
+		beforeTree:       row.BeforeTree,
+		afterTree:        row.AfterTree,
+	}
+	if row.RestoredAt.Valid {
+		checkpoint.RestoredAt = row.RestoredAt.Int64
+	}
+	if err := json.Unmarshal([]byte(row.Files), &checkpoint.Files); err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid files of checkpoint %s: %w", row.ID, err)
+	}
+	return checkpoint, nil
+}
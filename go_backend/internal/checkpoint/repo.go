@@ -0,0 +1,218 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMaxFileSizeMB bounds the files snapshotted when the config doesn't
+const defaultMaxFileSizeMB = 50
+
+// repo is the snapshot repository of one working directory: a bare git
+// repository outside it whose index and objects track the directory's files.
+// It never touches the directory's own .git.
+type repo struct {
+	gitDir  string
+	workDir string
+	// maxFileSize is the size in bytes above which files aren't snapshotted
+	maxFileSize int64
+}
+
+// openRepo returns the snapshot repository for workDir under dir, creating it
+// on first use.
+func openRepo(ctx context.Context, dir, workDir string, maxFileSizeMB int) (*repo, error) {
+	workDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return nil, err
+	}
+	// git runs in workDir, so a relative data directory would resolve there
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if maxFileSizeMB <= 0 {
+		maxFileSizeMB = defaultMaxFileSizeMB
+	}
+	sum := sha256.Sum256([]byte(workDir))
+	r := &repo{
+		gitDir:      filepath.Join(dir, hex.EncodeToString(sum[:8])),
+		workDir:     workDir,
+		maxFileSize: int64(maxFileSizeMB) << 20,
+	}
+	if _, err := os.Stat(filepath.Join(r.gitDir, "HEAD")); err == nil {
+		return r, nil
+	}
+
+	if out, err := exec.CommandContext(ctx, "git", "init", "--bare", "--quiet", r.gitDir).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git init: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	// Trees are only referenced from the database, so git must never prune them
+	for _, setting := range [][2]string{{"gc.auto", "0"}, {"gc.pruneExpire", "never"}, {"core.autocrlf", "false"}} {
+		if _, err := r.git(ctx, nil, "config", setting[0], setting[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Leave out the data directory, whose database changes on every turn
+	excludes := []string{".mix/"}
+	if rel, err := filepath.Rel(workDir, filepath.Dir(dir)); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		excludes = append(excludes, "/"+filepath.ToSlash(rel)+"/")
+	}
+	if err := os.MkdirAll(filepath.Join(r.gitDir, "info"), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(r.gitDir, "info", "exclude"), []byte(strings.Join(excludes, "\n")+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// git runs a git command on the repository, with stdin when it isn't nil, and
+// returns its output.
+func (r *repo) git(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.workDir
+	cmd.Env = append(os.Environ(),
+		"GIT_DIR="+r.gitDir,
+		"GIT_WORK_TREE="+r.workDir,
+		"GIT_LITERAL_PATHSPECS=1",
+	)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// snapshot records the working directory's files and returns their tree.
+// Ignored files and files over the size limit are left out.
+func (r *repo) snapshot(ctx context.Context) (string, error) {
+	out, err := r.git(ctx, nil, "ls-files", "-z", "--others", "--modified", "--exclude-standard")
+	if err != nil {
+		return "", err
+	}
+	var paths []byte
+	for _, path := range splitNul(out) {
+		if info, err := os.Lstat(filepath.Join(r.workDir, path)); err == nil && info.Mode().IsRegular() && info.Size() > r.maxFileSize {
+			continue
+		}
+		paths = append(append(paths, path...), 0)
+	}
+	if len(paths) > 0 {
+		if _, err := r.git(ctx, paths, "add", "--all", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+			return "", err
+		}
+	}
+	tree, err := r.git(ctx, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(tree)), nil
+}
+
+// changed returns the paths that differ between two trees.
+func (r *repo) changed(ctx context.Context, from, to string) ([]string, error) {
+	if from == to {
+		return nil, nil
+	}
+	out, err := r.git(ctx, nil, "diff-tree", "-r", "-z", "--name-only", "--no-renames", from, to)
+	if err != nil {
+		return nil, err
+	}
+	return splitNul(out), nil
+}
+
+// blob returns the object id of path in tree, or "" when the tree doesn't
+// have it.
+func (r *repo) blob(ctx context.Context, tree, path string) (string, error) {
+	out, err := r.git(ctx, nil, "ls-tree", "-z", tree, "--", path)
+	if err != nil {
+		return "", err
+	}
+	entry := strings.TrimSuffix(string(out), "\x00")
+	if entry == "" {
+		return "", nil
+	}
+	// <mode> SP <type> SP <object> TAB <path>
+	fields := strings.Fields(strings.SplitN(entry, "\t", 2)[0])
+	if len(fields) != 3 {
+		return "", fmt.Errorf("unexpected ls-tree output %q", entry)
+	}
+	return fields[2], nil
+}
+
+// current returns the object id path would have if it were snapshotted now,
+// or "" when it doesn't exist.
+func (r *repo) current(ctx context.Context, path string) (string, error) {
+	info, err := os.Lstat(filepath.Join(r.workDir, path))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(filepath.Join(r.workDir, path))
+		if err != nil {
+			return "", err
+		}
+		out, err := r.git(ctx, []byte(target), "hash-object", "--stdin")
+		return strings.TrimSpace(string(out)), err
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil
+	}
+	out, err := r.git(ctx, nil, "hash-object", "--", path)
+	return strings.TrimSpace(string(out)), err
+}
+
+// checkout writes paths as they are in tree to the working directory.
+func (r *repo) checkout(ctx context.Context, tree string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	var list []byte
+	for _, path := range paths {
+		list = append(append(list, path...), 0)
+	}
+	_, err := r.git(ctx, list, "checkout", tree, "--pathspec-from-file=-", "--pathspec-file-nul")
+	return err
+}
+
+// remove deletes path from the working directory, and the directories it
+// leaves empty.
+func (r *repo) remove(path string) error {
+	full := filepath.Join(r.workDir, path)
+	if err := os.Remove(full); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(full); dir != r.workDir && strings.HasPrefix(dir, r.workDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func splitNul(out []byte) []string {
+	var parts []string
+	for _, part := range strings.Split(string(out), "\x00") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -10,10 +11,15 @@ import (
 	"time"
 
 	"mix/internal/app"
+	"mix/internal/checkpoint"
 	"mix/internal/config"
+	"mix/internal/i18n"
 	"mix/internal/llm/agent"
+	"mix/internal/llm/models"
 	"mix/internal/llm/provider"
 	"mix/internal/llm/tools"
+	"mix/internal/logging"
+	"mix/internal/toolstats"
 )
 
 // ContextResponse represents the JSON response for the /context command
@@ -29,6 +35,7 @@ type ContextResponse struct {
 
 // ComponentBreakdown represents individual context component usage
 type ComponentBreakdown struct {
+	Key        string  `json:"key"` // stable, e.g. "system_prompt"; Name is localized
 	Name       string  `json:"name"`
 	Tokens     int64   `json:"tokens"`
 	Percentage float64 `json:"percentage"`
@@ -65,6 +72,57 @@ type SessionResponse struct {
 	ParentSessionID  string  `json:"parentSessionId,omitempty"`
 }
 
+// CacheResponse represents the JSON response for the /cache command
+type CacheResponse struct {
+	Type                string  `json:"type"`
+	SessionID           string  `json:"sessionId"`
+	Model               string  `json:"model"`
+	Enabled             bool    `json:"enabled"`
+	CacheSystem         bool    `json:"cacheSystem"`
+	CacheTools          bool    `json:"cacheTools"`
+	CachedMessages      int     `json:"cachedMessages"`
+	Requests            int64   `json:"requests"`
+	InputTokens         int64   `json:"inputTokens"`
+	CacheCreationTokens int64   `json:"cacheCreationTokens"`
+	CacheReadTokens     int64   `json:"cacheReadTokens"`
+	HitRate             float64 `json:"hitRate"`
+}
+
+// StatsResponse represents the JSON response for the /stats command
+type StatsResponse struct {
+	Type      string `json:"type"`
+	SessionID string `json:"sessionId,omitempty"`
+	toolstats.Report
+}
+
+// UndoResponse represents the JSON response for the /undo command: the turns
+// reverted and the files written back and deleted, relative to the session's
+// working directory
+type UndoResponse struct {
+	Type        string   `json:"type"`
+	Checkpoints []string `json:"checkpoints"`
+	Restored    []string `json:"restored"`
+	Deleted     []string `json:"deleted"`
+}
+
+// DoctorResponse represents the JSON response for the /doctor command
+type DoctorResponse struct {
+	Type      string           `json:"type"`
+	Healthy   bool             `json:"healthy"`
+	Providers []DoctorProvider `json:"providers"`
+}
+
+// DoctorProvider represents the probe result for one configured model
+type DoctorProvider struct {
+	Provider  string   `json:"provider"`
+	Model     string   `json:"model"`
+	Agents    []string `json:"agents"`
+	Status    string   `json:"status"` // "ok" or the problem, e.g. "invalid_credentials"
+	Error     string   `json:"error,omitempty"`
+	Hint      string   `json:"hint,omitempty"`
+	LatencyMs int64    `json:"latencyMs"`
+}
+
 // McpResponse represents the JSON response for the /mcp command
 type McpResponse struct {
 	Type    string      `json:"type"`
@@ -111,6 +169,7 @@ type SessionSummary struct {
 // ErrorResponse represents error responses from commands
 type ErrorResponse struct {
 	Type    string `json:"type"`
+	Code    string `json:"code"` // message catalog key of Error
 	Error   string `json:"error"`
 	Command string `json:"command,omitempty"`
 }
@@ -118,6 +177,7 @@ type ErrorResponse struct {
 // MessageResponse represents informational messages from commands
 type MessageResponse struct {
 	Type    string `json:"type"`
+	Code    string `json:"code"` // message catalog key of Message
 	Message string `json:"message"`
 	Command string `json:"command,omitempty"`
 }
@@ -128,6 +188,7 @@ type AuthStatusResponse struct {
 	Status    string `json:"status"`    // "authenticated" | "not_authenticated"
 	Provider  string `json:"provider"`  // "anthropic"
 	ExpiresIn int64  `json:"expiresIn"` // minutes until expiry
+	Code      string `json:"code"`      // message catalog key of Message
 	Message   string `json:"message"`
 }
 
@@ -135,121 +196,131 @@ type AuthStatusResponse struct {
 type AuthLoginResponse struct {
 	Type    string `json:"type"`
 	Status  string `json:"status"` // "success" | "pending" | "error"
+	Code    string `json:"code"`   // message catalog key of Message
 	Message string `json:"message"`
 	AuthURL string `json:"authUrl,omitempty"` // for OAuth flow
 	Step    string `json:"step,omitempty"`    // current step in flow
 }
 
+// commandHandler runs a built-in command, writing its response in locale
+type commandHandler func(ctx context.Context, locale string, args string) (string, error)
+
 // BuiltinCommand represents a built-in command
 type BuiltinCommand struct {
-	name        string
-	description string
-	handler     func(ctx context.Context, args string) (string, error)
+	name    string
+	app     *app.App
+	handler commandHandler
 }
 
 func (c *BuiltinCommand) Name() string {
 	return c.name
 }
 
+// Description is the English description; help shows it in the session's locale.
 func (c *BuiltinCommand) Description() string {
-	return c.description
+	return c.localizedDescription(i18n.DefaultLocale)
+}
+
+func (c *BuiltinCommand) localizedDescription(locale string) string {
+	return i18n.T(locale, "command."+c.name+".description", nil)
 }
 
 func (c *BuiltinCommand) Execute(ctx context.Context, args string) (string, error) {
-	return c.handler(ctx, args)
+	return c.handler(ctx, c.locale(ctx), args)
+}
+
+// locale is the current session's locale, or the configured one.
+func (c *BuiltinCommand) locale(ctx context.Context) string {
+	if c.app != nil {
+		if id := c.app.GetCurrentSessionID(); id != "" {
+			locale, err := c.app.Sessions.Locale(ctx, id)
+			if err != nil {
+				logging.Warn("Failed to get session locale", "session", id, "error", err)
+			}
+			if locale != "" {
+				return locale
+			}
+		}
+	}
+	if locale := config.Get().Locale; locale != "" {
+		return locale
+	}
+	return i18n.DefaultLocale
 }
 
 // Helper functions for structured responses
 
-// returnError creates a structured error response
-func returnError(command, errorMsg string) (string, error) {
+// returnError creates a structured error response with the catalog message key
+func returnError(locale, command, key string, args i18n.Args) (string, error) {
 	response := ErrorResponse{
 		Type:    "error",
-		Error:   errorMsg,
+		Code:    key,
+		Error:   i18n.T(locale, key, args),
 		Command: command,
 	}
 	jsonData, _ := json.Marshal(response)
 	return string(jsonData), nil
 }
 
-// returnMessage creates a structured informational message response
-func returnMessage(command, message string) (string, error) {
+// returnMessage creates a structured informational message response with the
+// catalog message key
+func returnMessage(locale, command, key string, args i18n.Args) (string, error) {
 	response := MessageResponse{
 		Type:    "message",
-		Message: message,
+		Code:    key,
+		Message: i18n.T(locale, key, args),
 		Command: command,
 	}
 	jsonData, _ := json.Marshal(response)
 	return string(jsonData), nil
 }
 
-// GetBuiltinCommands returns all built-in commands
+// returnEncodeError reports a response that failed to marshal
+func returnEncodeError(locale, command string, err error) (string, error) {
+	return returnError(locale, command, "error.encode", i18n.Args{"error": err})
+}
+
+// GetBuiltinCommands returns all built-in commands. Their descriptions are
+// the command.<name>.description catalog messages.
 func GetBuiltinCommands(registry *Registry, app *app.App) map[string]Command {
-	return map[string]Command{
-		"help": &BuiltinCommand{
-			name:        "help",
-			description: "Show available commands",
-			handler:     createHelpHandler(registry),
-		},
-		"clear": &BuiltinCommand{
-			name:        "clear",
-			description: "Start new session",
-			handler:     createClearHandler(app),
-		},
-		"session": &BuiltinCommand{
-			name:        "session",
-			description: "Show session information or switch sessions",
-			handler:     createSessionHandler(app),
-		},
-		"sessions": &BuiltinCommand{
-			name:        "sessions",
-			description: "List all available sessions",
-			handler:     createSessionsHandler(app),
-		},
-		"mcp": &BuiltinCommand{
-			name:        "mcp",
-			description: "List configured MCP servers",
-			handler:     createMcpHandler(),
-		},
-		"context": &BuiltinCommand{
-			name:        "context",
-			description: "Show context usage breakdown with percentages",
-			handler:     createContextHandler(app),
-		},
-		"login": &BuiltinCommand{
-			name:        "login",
-			description: "Authenticate with Claude Code OAuth",
-			handler:     createLoginHandler(),
-		},
-		"logout": &BuiltinCommand{
-			name:        "logout",
-			description: "Sign out from Claude Code",
-			handler:     createLogoutHandler(),
-		},
-		"status": &BuiltinCommand{
-			name:        "status",
-			description: "Check Claude Code authentication status",
-			handler:     createAuthStatusHandler(),
-		},
-		"auth-code": &BuiltinCommand{
-			name:        "auth-code",
-			description: "Exchange authorization code for OAuth tokens",
-			handler:     createAuthCodeHandler(),
-		},
+	handlers := map[string]commandHandler{
+		"help":      createHelpHandler(registry),
+		"clear":     createClearHandler(app),
+		"session":   createSessionHandler(app),
+		"sessions":  createSessionsHandler(app),
+		"mcp":       createMcpHandler(),
+		"context":   createContextHandler(app),
+		"cache":     createCacheHandler(app),
+		"stats":     createStatsHandler(app),
+		"undo":      createUndoHandler(app),
+		"doctor":    createDoctorHandler(),
+		"login":     createLoginHandler(),
+		"logout":    createLogoutHandler(),
+		"status":    createAuthStatusHandler(),
+		"auth-code": createAuthCodeHandler(),
+	}
+	commands := make(map[string]Command, len(handlers))
+	for name, handler := range handlers {
+		commands[name] = &BuiltinCommand{name: name, app: app, handler: handler}
 	}
+	return commands
 }
 
-func createHelpHandler(registry *Registry) func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createHelpHandler(registry *Registry) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		// Get all commands from registry
 		commands := registry.GetAllCommands()
 
 		// Build commands slice
 		var helpCommands []HelpCommand
 		for name, cmd := range commands {
+			description := cmd.Description()
+			if builtin, ok := cmd.(*BuiltinCommand); ok {
+				description = builtin.localizedDescription(locale)
+			}
 			helpCommands = append(helpCommands, HelpCommand{
 				Name:        name,
-				Description: cmd.Description(),
+				Description: description,
 				Usage:       fmt.Sprintf("/%s", name),
 			})
 		}
@@ -268,17 +339,17 @@ func createHelpHandler(registry *Registry) func(ctx context.Context, args string
 		// Convert to JSON
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("help", fmt.Sprintf("Error marshaling help data: %v", err))
+			return returnEncodeError(locale, "help", err)
 		}
 
 		return string(jsonData), nil
 	}
 }
 
-func createClearHandler(app *app.App) func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createClearHandler(app *app.App) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		// Create a new session with a default title
-		title := "New Session"
+		title := i18n.T(locale, "session.default_title", nil)
 		workingDirectory := ""
 
 		// Get current session for working directory context
@@ -289,70 +360,70 @@ func createClearHandler(app *app.App) func(ctx context.Context, args string) (st
 		// Create the new session
 		session, err := app.Sessions.Create(ctx, title, workingDirectory)
 		if err != nil {
-			return returnError("clear", fmt.Sprintf("Failed to create new session: %v", err))
+			return returnError(locale, "clear", "clear.create_failed", i18n.Args{"error": err})
 		}
 
 		// Set the new session as current
 		if err := app.SetCurrentSession(session.ID); err != nil {
-			return returnError("clear", fmt.Sprintf("Failed to set new session as current: %v", err))
+			return returnError(locale, "clear", "clear.select_failed", i18n.Args{"error": err})
 		}
 
 		// Return success message with session info
-		return returnMessage("clear", fmt.Sprintf("Started new session: %s (ID: %s)", session.Title, session.ID[:8]))
+		return returnMessage(locale, "clear", "clear.started", i18n.Args{"title": session.Title, "id": session.ID[:8]})
 	}
 }
 
-func createSessionHandler(app *app.App) func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createSessionHandler(app *app.App) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		args = strings.TrimSpace(args)
 		if args == "" {
 			// Show current session info
 			currentSession, err := app.GetCurrentSession(ctx)
 			if err != nil {
-				return returnError("session", fmt.Sprintf("Error retrieving current session: %v", err))
+				return returnError(locale, "session", "session.lookup_failed", i18n.Args{"error": err})
 			}
 
 			if currentSession == nil {
-				return returnMessage("session", "No active session. Use /sessions to list available sessions.")
+				return returnMessage(locale, "session", "session.none", nil)
 			}
 
 			// Create structured response
 			response := SessionResponse{
-				Type:             "session",
-				ID:               currentSession.ID,
-				Title:            currentSession.Title,
+				Type:                  "session",
+				ID:                    currentSession.ID,
+				Title:                 currentSession.Title,
 				UserMessageCount:      currentSession.UserMessageCount,
 				AssistantMessageCount: currentSession.AssistantMessageCount,
 				ToolCallCount:         currentSession.ToolCallCount,
-				TotalTokens:      currentSession.PromptTokens + currentSession.CompletionTokens,
-				PromptTokens:     currentSession.PromptTokens,
-				CompletionTokens: currentSession.CompletionTokens,
-				Cost:             currentSession.Cost,
-				CreatedAt:        currentSession.CreatedAt,
-				UpdatedAt:        currentSession.UpdatedAt,
-				ParentSessionID:  currentSession.ParentSessionID,
+				TotalTokens:           currentSession.PromptTokens + currentSession.CompletionTokens,
+				PromptTokens:          currentSession.PromptTokens,
+				CompletionTokens:      currentSession.CompletionTokens,
+				Cost:                  currentSession.Cost,
+				CreatedAt:             currentSession.CreatedAt,
+				UpdatedAt:             currentSession.UpdatedAt,
+				ParentSessionID:       currentSession.ParentSessionID,
 			}
 
 			// Convert to JSON
 			jsonData, err := json.Marshal(response)
 			if err != nil {
-				return returnError("session", fmt.Sprintf("Error marshaling session data: %v", err))
+				return returnEncodeError(locale, "session", err)
 			}
 
 			return string(jsonData), nil
 		} else {
 			// Switch to specific session
-			return returnMessage("session", fmt.Sprintf("Session switching to '%s' is available via the HTTP API.", args))
+			return returnMessage(locale, "session", "session.switch_unavailable", i18n.Args{"session": args})
 		}
 	}
 }
 
-func createSessionsHandler(app *app.App) func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createSessionsHandler(app *app.App) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		// Get all sessions from the database
 		sessions, err := app.Sessions.List(ctx)
 		if err != nil {
-			return returnError("sessions", fmt.Sprintf("Error retrieving sessions: %v", err))
+			return returnError(locale, "sessions", "sessions.list_failed", i18n.Args{"error": err})
 		}
 
 		// Get current session ID for comparison
@@ -362,17 +433,17 @@ func createSessionsHandler(app *app.App) func(ctx context.Context, args string)
 		var sessionSummaries []SessionSummary
 		for _, session := range sessions {
 			sessionSummaries = append(sessionSummaries, SessionSummary{
-				ID:              session.ID,
-				Title:           session.Title,
+				ID:                    session.ID,
+				Title:                 session.Title,
 				UserMessageCount:      session.UserMessageCount,
 				AssistantMessageCount: session.AssistantMessageCount,
 				ToolCallCount:         session.ToolCallCount,
-				TotalTokens:     session.PromptTokens + session.CompletionTokens,
-				Cost:            session.Cost,
-				CreatedAt:       session.CreatedAt,
-				UpdatedAt:       session.UpdatedAt,
-				ParentSessionID: session.ParentSessionID,
-				IsCurrent:       session.ID == currentSessionID,
+				TotalTokens:           session.PromptTokens + session.CompletionTokens,
+				Cost:                  session.Cost,
+				CreatedAt:             session.CreatedAt,
+				UpdatedAt:             session.UpdatedAt,
+				ParentSessionID:       session.ParentSessionID,
+				IsCurrent:             session.ID == currentSessionID,
 			})
 		}
 
@@ -386,19 +457,19 @@ func createSessionsHandler(app *app.App) func(ctx context.Context, args string)
 		// Convert to JSON
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("sessions", fmt.Sprintf("Error marshaling sessions data: %v", err))
+			return returnEncodeError(locale, "sessions", err)
 		}
 
 		return string(jsonData), nil
 	}
 }
 
-func createMcpHandler() func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createMcpHandler() commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		cfg := config.Get()
 
 		if len(cfg.MCPServers) == 0 {
-			return returnMessage("mcp", "No MCP servers configured.\n\nTo configure MCP servers, add them to your configuration file under 'mcpServers'.")
+			return returnMessage(locale, "mcp", "mcp.none", nil)
 		}
 
 		// Sort server names for consistent output
@@ -480,22 +551,135 @@ func createMcpHandler() func(ctx context.Context, args string) (string, error) {
 		// Convert to JSON
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("mcp", fmt.Sprintf("Error marshaling MCP data: %v", err))
+			return returnEncodeError(locale, "mcp", err)
+		}
+
+		return string(jsonData), nil
+	}
+}
+
+func createCacheHandler(app *app.App) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
+		currentSession, err := app.GetCurrentSession(ctx)
+		if err != nil {
+			return returnError(locale, "cache", "session.lookup_failed", i18n.Args{"error": err})
+		}
+
+		if currentSession == nil {
+			return returnMessage(locale, "cache", "session.none", nil)
+		}
+
+		usage, err := app.Sessions.CacheUsage(ctx, currentSession.ID)
+		if err != nil {
+			return returnError(locale, "cache", "cache.usage_failed", i18n.Args{"error": err})
+		}
+
+		system, tools, messages := config.Get().Agents[config.AgentMain].Cache.Breakpoints()
+		model := app.CoderAgent.Model()
+		response := CacheResponse{
+			Type:                "cache",
+			SessionID:           currentSession.ID,
+			Model:               model.Name,
+			Enabled:             system || tools || messages > 0,
+			CacheSystem:         system,
+			CacheTools:          tools,
+			CachedMessages:      messages,
+			Requests:            usage.Requests,
+			InputTokens:         usage.InputTokens,
+			CacheCreationTokens: usage.CacheCreationTokens,
+			CacheReadTokens:     usage.CacheReadTokens,
+			HitRate:             usage.HitRate(),
+		}
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return returnEncodeError(locale, "cache", err)
+		}
+
+		return string(jsonData), nil
+	}
+}
+
+// createStatsHandler reports tool execution statistics across sessions, or for
+// the current session with "/stats session".
+func createStatsHandler(app *app.App) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
+		var filter toolstats.Filter
+		if strings.TrimSpace(args) == "session" {
+			currentSession, err := app.GetCurrentSession(ctx)
+			if err != nil {
+				return returnError(locale, "stats", "session.lookup_failed", i18n.Args{"error": err})
+			}
+			if currentSession == nil {
+				return returnMessage(locale, "stats", "session.none", nil)
+			}
+			filter.SessionID = currentSession.ID
+		}
+
+		report, err := app.ToolStats.Report(ctx, filter)
+		if err != nil {
+			return returnError(locale, "stats", "stats.failed", i18n.Args{"error": err})
+		}
+		if len(report.Tools) == 0 {
+			return returnMessage(locale, "stats", "stats.none", nil)
+		}
+
+		jsonData, err := json.Marshal(StatsResponse{Type: "stats", SessionID: filter.SessionID, Report: report})
+		if err != nil {
+			return returnEncodeError(locale, "stats", err)
+		}
+
+		return string(jsonData), nil
+	}
+}
+
+// createUndoHandler reverts the file changes of the current session's last
+// agent turn not undone yet. "/undo force" also overwrites files changed
+// since the turn.
+func createUndoHandler(app *app.App) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
+		currentSession, err := app.GetCurrentSession(ctx)
+		if err != nil {
+			return returnError(locale, "undo", "session.lookup_failed", i18n.Args{"error": err})
+		}
+		if currentSession == nil {
+			return returnMessage(locale, "undo", "session.none", nil)
+		}
+		if app.CoderAgent.IsSessionBusy(currentSession.ID) {
+			return returnMessage(locale, "undo", "undo.busy", nil)
+		}
+
+		force := strings.TrimSpace(args) == "force"
+		restore, err := app.Checkpoints.Undo(ctx, currentSession.ID, force)
+		if errors.Is(err, checkpoint.ErrNothingToUndo) {
+			return returnMessage(locale, "undo", "undo.none", nil)
+		}
+		if err != nil {
+			return returnError(locale, "undo", "undo.failed", i18n.Args{"error": err})
+		}
+
+		response := UndoResponse{Type: "undo", Restored: restore.Restored, Deleted: restore.Deleted}
+		for _, c := range restore.Checkpoints {
+			response.Checkpoints = append(response.Checkpoints, c.ID)
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return returnEncodeError(locale, "undo", err)
 		}
 
 		return string(jsonData), nil
 	}
 }
 
-func createContextHandler(app *app.App) func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createContextHandler(app *app.App) commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		currentSession, err := app.GetCurrentSession(ctx)
 		if err != nil {
-			return returnError("context", fmt.Sprintf("Error retrieving current session: %v", err))
+			return returnError(locale, "context", "session.lookup_failed", i18n.Args{"error": err})
 		}
 
 		if currentSession == nil {
-			return returnMessage("context", "No active session. Use /sessions to list available sessions.")
+			return returnMessage(locale, "context", "session.none", nil)
 		}
 
 		// Get current model's context window from agent
@@ -513,8 +697,20 @@ func createContextHandler(app *app.App) func(ctx context.Context, args string) (
 		// Calculate conversation tokens (excluding system overhead)
 		conversationTokens := currentSession.PromptTokens + currentSession.CompletionTokens
 
+		// Pinned files and messages are sent with every request, so they are
+		// part of the prompt tokens
+		pinned, err := app.Pins.Contents(ctx, currentSession.ID, currentSession.WorkingDirectory)
+		if err != nil {
+			return returnError(locale, "context", "session.lookup_failed", i18n.Args{"error": err})
+		}
+		var pinnedTokens int64
+		for _, c := range pinned {
+			pinnedTokens += c.Tokens
+		}
+		pinnedPercent := float64(pinnedTokens) / float64(maxContextTokens) * 100
+
 		// User and assistant message breakdown
-		userTokens := currentSession.PromptTokens
+		userTokens := max(currentSession.PromptTokens-pinnedTokens, 0)
 		userPercent := float64(userTokens) / float64(maxContextTokens) * 100
 
 		assistantTokens := currentSession.CompletionTokens
@@ -530,10 +726,10 @@ func createContextHandler(app *app.App) func(ctx context.Context, args string) (
 		warningMessage := ""
 		if contextUsagePercent > 80 {
 			warningLevel = "high"
-			warningMessage = "Context usage above 80% - consider starting a new session"
+			warningMessage = i18n.T(locale, "context.warning_high", nil)
 		} else if contextUsagePercent > 60 {
 			warningLevel = "medium"
-			warningMessage = "Context usage above 60% - monitor usage"
+			warningMessage = i18n.T(locale, "context.warning_medium", nil)
 		}
 
 		// Create structured response
@@ -546,27 +742,38 @@ func createContextHandler(app *app.App) func(ctx context.Context, args string) (
 			WarningMessage: warningMessage,
 			Components: []ComponentBreakdown{
 				{
-					Name:       "System Prompt",
+					Key:        "system_prompt",
+					Name:       i18n.T(locale, "context.component.system_prompt", nil),
 					Tokens:     systemPromptTokens,
 					Percentage: systemPromptPercent,
 				},
 				{
-					Name:       "Tool Descriptions",
+					Key:        "tool_descriptions",
+					Name:       i18n.T(locale, "context.component.tool_descriptions", nil),
 					Tokens:     toolTokens,
 					Percentage: toolPercent,
 				},
 				{
-					Name:       "User Messages",
+					Key:        "pinned_context",
+					Name:       i18n.T(locale, "context.component.pinned_context", nil),
+					Tokens:     pinnedTokens,
+					Percentage: pinnedPercent,
+				},
+				{
+					Key:        "user_messages",
+					Name:       i18n.T(locale, "context.component.user_messages", nil),
 					Tokens:     userTokens,
 					Percentage: userPercent,
 				},
 				{
-					Name:       "Assistant Responses",
+					Key:        "assistant_responses",
+					Name:       i18n.T(locale, "context.component.assistant_responses", nil),
 					Tokens:     assistantTokens,
 					Percentage: assistantPercent,
 				},
 				{
-					Name:       "Total",
+					Key:        "total",
+					Name:       i18n.T(locale, "context.component.total", nil),
 					Tokens:     totalTokens,
 					Percentage: contextUsagePercent,
 					IsTotal:    true,
@@ -577,7 +784,44 @@ func createContextHandler(app *app.App) func(ctx context.Context, args string) (
 		// Convert to JSON
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("context", fmt.Sprintf("Error marshaling context data: %v", err))
+			return returnEncodeError(locale, "context", err)
+		}
+
+		return string(jsonData), nil
+	}
+}
+
+func createDoctorHandler() commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
+		response := DoctorResponse{
+			Type:      "doctor",
+			Healthy:   true,
+			Providers: []DoctorProvider{},
+		}
+		for _, result := range agent.ProbeProviders(ctx) {
+			status := "ok"
+			if !result.OK {
+				status = result.Problem
+				response.Healthy = false
+			}
+			agents := make([]string, len(result.Agents))
+			for i, name := range result.Agents {
+				agents[i] = string(name)
+			}
+			response.Providers = append(response.Providers, DoctorProvider{
+				Provider:  string(result.Provider),
+				Model:     string(result.Model),
+				Agents:    agents,
+				Status:    status,
+				Error:     result.Error,
+				Hint:      result.Hint,
+				LatencyMs: result.Duration.Milliseconds(),
+			})
+		}
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return returnEncodeError(locale, "doctor", err)
 		}
 
 		return string(jsonData), nil
@@ -586,21 +830,20 @@ func createContextHandler(app *app.App) func(ctx context.Context, args string) (
 
 // Authentication command handlers
 
-func createAuthStatusHandler() func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createAuthStatusHandler() commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		storage, err := provider.NewCredentialStorage()
 		if err != nil {
-			return returnError("status", fmt.Sprintf("Failed to initialize credential storage: %v", err))
+			return returnError(locale, "status", "auth.storage_failed", i18n.Args{"error": err})
 		}
 
 		// Check Anthropic OAuth credentials
 		creds, err := storage.GetOAuthCredentials("anthropic")
 		if err != nil {
-			return returnError("status", fmt.Sprintf("Error checking credentials: %v", err))
+			return returnError(locale, "status", "auth.check_failed", i18n.Args{"error": err})
 		}
 
-		// Check if API key is set in environment
-		hasAPIKey := os.Getenv("ANTHROPIC_API_KEY") != ""
+		hasAPIKey := hasAnthropicAPIKey(storage)
 
 		response := AuthStatusResponse{
 			Type:     "auth_status",
@@ -611,36 +854,50 @@ func createAuthStatusHandler() func(ctx context.Context, args string) (string, e
 		if creds != nil && !creds.IsTokenExpired() {
 			response.Status = "authenticated"
 			response.ExpiresIn = (creds.ExpiresAt - time.Now().Unix()) / 60 // minutes
-			response.Message = "✅ Authenticated with Claude Code OAuth"
+			response.Code = "auth.oauth_authenticated"
+			response.Message = i18n.T(locale, "auth.oauth_authenticated", nil)
 		} else if hasAPIKey {
 			response.Status = "authenticated"
 			response.ExpiresIn = 0 // API keys don't expire
-			response.Message = "✅ Authenticated with Anthropic API Key"
+			response.Code = "auth.api_key_authenticated"
+			response.Message = i18n.T(locale, "auth.api_key_authenticated", nil)
 		} else {
 			response.Status = "not_authenticated"
 			response.ExpiresIn = 0
 			if creds != nil && creds.IsTokenExpired() {
-				response.Message = "❌ Token expired. Please login again."
+				response.Code = "auth.token_expired"
+				response.Message = i18n.T(locale, "auth.token_expired", nil)
 			} else {
-				response.Message = "❌ Not authenticated. Use /login to authenticate."
+				response.Code = "auth.not_authenticated"
+				response.Message = i18n.T(locale, "auth.not_authenticated", nil)
 			}
 		}
 
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("status", fmt.Sprintf("Error marshaling status data: %v", err))
+			return returnEncodeError(locale, "status", err)
 		}
 
 		return string(jsonData), nil
 	}
 }
 
-func createLoginHandler() func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+// hasAnthropicAPIKey reports whether an Anthropic API key is stored or set in
+// the environment.
+func hasAnthropicAPIKey(storage *provider.CredentialStorage) bool {
+	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+		return true
+	}
+	keys, err := storage.APIKeys()
+	return err == nil && keys[string(models.ProviderAnthropic)].Key != ""
+}
+
+func createLoginHandler() commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		// Check if already authenticated
 		storage, err := provider.NewCredentialStorage()
 		if err != nil {
-			return returnError("login", fmt.Sprintf("Failed to initialize credential storage: %v", err))
+			return returnError(locale, "login", "auth.storage_failed", i18n.Args{"error": err})
 		}
 
 		existingCreds, err := storage.GetOAuthCredentials("anthropic")
@@ -648,7 +905,8 @@ func createLoginHandler() func(ctx context.Context, args string) (string, error)
 			response := AuthLoginResponse{
 				Type:    "auth_login",
 				Status:  "success",
-				Message: "✅ Already authenticated with Claude Code OAuth!",
+				Code:    "auth.already_authenticated",
+				Message: i18n.T(locale, "auth.already_authenticated", nil),
 			}
 			jsonData, _ := json.Marshal(response)
 			return string(jsonData), nil
@@ -660,7 +918,8 @@ func createLoginHandler() func(ctx context.Context, args string) (string, error)
 				Type:    "auth_login",
 				Status:  "success",
 				Step:    "api_key",
-				Message: "✅ Using Anthropic API key from environment variables. OAuth not needed.",
+				Code:    "auth.api_key_env",
+				Message: i18n.T(locale, "auth.api_key_env", nil),
 			}
 			jsonData, _ := json.Marshal(response)
 			return string(jsonData), nil
@@ -670,13 +929,13 @@ func createLoginHandler() func(ctx context.Context, args string) (string, error)
 		args = strings.TrimSpace(args)
 		if args != "" {
 			// Handle authorization code exchange
-			return handleAuthCodeExchange(args, storage)
+			return handleAuthCodeExchange(locale, args, storage)
 		}
 
 		// Create OAuth flow and initiate login
 		oauthFlow, err := provider.NewOAuthFlow("")
 		if err != nil {
-			return returnError("login", fmt.Sprintf("Failed to create OAuth flow: %v", err))
+			return returnError(locale, "login", "auth.oauth_flow_failed", i18n.Args{"error": err})
 		}
 
 		authURL := oauthFlow.GetAuthorizationURL()
@@ -689,7 +948,8 @@ func createLoginHandler() func(ctx context.Context, args string) (string, error)
 				Status:  "pending",
 				AuthURL: authURL,
 				Step:    "authorization",
-				Message: "🔐 Failed to open browser automatically. Please manually visit the URL above and complete OAuth authentication. Then run: /login <authorization_code>",
+				Code:    "auth.browser_failed",
+				Message: i18n.T(locale, "auth.browser_failed", nil),
 			}
 			jsonData, _ := json.Marshal(response)
 			return string(jsonData), nil
@@ -700,12 +960,13 @@ func createLoginHandler() func(ctx context.Context, args string) (string, error)
 			Status:  "pending",
 			AuthURL: authURL,
 			Step:    "authorization",
-			Message: "🔐 Browser opened for authentication. Complete OAuth in your browser, then copy the authorization code and paste it.",
+			Code:    "auth.browser_opened",
+			Message: i18n.T(locale, "auth.browser_opened", nil),
 		}
 
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("login", fmt.Sprintf("Error marshaling login data: %v", err))
+			return returnEncodeError(locale, "login", err)
 		}
 
 		return string(jsonData), nil
@@ -713,11 +974,11 @@ func createLoginHandler() func(ctx context.Context, args string) (string, error)
 }
 
 // handleAuthCodeExchange handles the authorization code exchange for tokens
-func handleAuthCodeExchange(authCode string, storage *provider.CredentialStorage) (string, error) {
+func handleAuthCodeExchange(locale, authCode string, storage *provider.CredentialStorage) (string, error) {
 	// Create new OAuth flow for token exchange
 	oauthFlow, err := provider.NewOAuthFlow("")
 	if err != nil {
-		return returnError("login", fmt.Sprintf("Failed to create OAuth flow: %v", err))
+		return returnError(locale, "login", "auth.oauth_flow_failed", i18n.Args{"error": err})
 	}
 
 	// Exchange authorization code for tokens
@@ -728,7 +989,8 @@ func handleAuthCodeExchange(authCode string, storage *provider.CredentialStorage
 			Type:    "auth_login",
 			Status:  "error",
 			Step:    "manual_api_key",
-			Message: "OAuth flow could not be completed automatically due to Cloudflare protection. \n\nPlease use an API key instead:\n\n1. Visit: https://console.anthropic.com/settings/keys\n2. Create a new API key\n3. Set the environment variable: export ANTHROPIC_API_KEY=your_api_key\n4. Restart the application\n\nThis will be fixed in a future update.",
+			Code:    "auth.manual_api_key",
+			Message: i18n.T(locale, "auth.manual_api_key", nil),
 		}
 		jsonData, _ := json.Marshal(response)
 		return string(jsonData), nil
@@ -737,37 +999,37 @@ func handleAuthCodeExchange(authCode string, storage *provider.CredentialStorage
 	// Store the credentials
 	err = storage.StoreOAuthCredentials("anthropic", creds.AccessToken, creds.RefreshToken, creds.ExpiresAt, creds.ClientID)
 	if err != nil {
-		return returnError("login", fmt.Sprintf("Failed to store credentials: %v", err))
+		return returnError(locale, "login", "auth.store_failed", i18n.Args{"error": err})
 	}
 
 	response := AuthLoginResponse{
 		Type:    "auth_login",
 		Status:  "success",
 		Step:    "completed",
-		Message: "✅ Successfully authenticated with Claude Code OAuth!",
+		Code:    "auth.login_succeeded",
+		Message: i18n.T(locale, "auth.login_succeeded", nil),
 	}
 
 	jsonData, err := json.Marshal(response)
 	if err != nil {
-		return returnError("login", fmt.Sprintf("Error marshaling success response: %v", err))
+		return returnEncodeError(locale, "login", err)
 	}
 
 	return string(jsonData), nil
 }
 
-func createLogoutHandler() func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createLogoutHandler() commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		storage, err := provider.NewCredentialStorage()
 		if err != nil {
-			return returnError("logout", fmt.Sprintf("Failed to initialize credential storage: %v", err))
+			return returnError(locale, "logout", "auth.storage_failed", i18n.Args{"error": err})
 		}
 
 		// Check if authenticated with OAuth
 		creds, err := storage.GetOAuthCredentials("anthropic")
 		hasOAuth := err == nil && creds != nil
 
-		// Check if API key is set in environment
-		hasAPIKey := os.Getenv("ANTHROPIC_API_KEY") != ""
+		hasAPIKey := hasAnthropicAPIKey(storage)
 
 		// If neither authentication method is active, we're already logged out
 		if !hasOAuth && !hasAPIKey {
@@ -775,7 +1037,8 @@ func createLogoutHandler() func(ctx context.Context, args string) (string, error
 				Type:     "auth_status",
 				Status:   "not_authenticated",
 				Provider: "anthropic",
-				Message:  "❌ Already logged out",
+				Code:     "auth.already_logged_out",
+				Message:  i18n.T(locale, "auth.already_logged_out", nil),
 			}
 			jsonData, _ := json.Marshal(response)
 			return string(jsonData), nil
@@ -785,36 +1048,40 @@ func createLogoutHandler() func(ctx context.Context, args string) (string, error
 		if hasOAuth {
 			err = storage.ClearOAuthCredentials("anthropic")
 			if err != nil {
-				return returnError("logout", fmt.Sprintf("Failed to clear credentials: %v", err))
+				return returnError(locale, "logout", "auth.clear_failed", i18n.Args{"error": err})
 			}
 		}
 
-		// Clear API key from environment if present
+		// Clear API key from the credential store and environment if present
 		if hasAPIKey {
 			os.Unsetenv("ANTHROPIC_API_KEY")
+			if err := provider.SetAPIKey(models.ProviderAnthropic, ""); err != nil {
+				return returnError(locale, "logout", "auth.clear_failed", i18n.Args{"error": err})
+			}
 		}
 
 		response := AuthStatusResponse{
 			Type:     "auth_status",
 			Status:   "not_authenticated",
 			Provider: "anthropic",
-			Message:  "✅ Successfully logged out from Claude Code",
+			Code:     "auth.logged_out",
+			Message:  i18n.T(locale, "auth.logged_out", nil),
 		}
 
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("logout", fmt.Sprintf("Error marshaling logout data: %v", err))
+			return returnEncodeError(locale, "logout", err)
 		}
 
 		return string(jsonData), nil
 	}
 }
 
-func createAuthCodeHandler() func(ctx context.Context, args string) (string, error) {
-	return func(ctx context.Context, args string) (string, error) {
+func createAuthCodeHandler() commandHandler {
+	return func(ctx context.Context, locale string, args string) (string, error) {
 		authCode := strings.TrimSpace(args)
 		if authCode == "" {
-			return returnError("auth-code", "Authorization code is required. Usage: /auth-code <code#state>")
+			return returnError(locale, "auth-code", "auth.code_required", nil)
 		}
 
 		// Check if there's a '/login ' prefix and remove it - this happens when users copy the whole command
@@ -824,13 +1091,13 @@ func createAuthCodeHandler() func(ctx context.Context, args string) (string, err
 
 		storage, err := provider.NewCredentialStorage()
 		if err != nil {
-			return returnError("auth-code", fmt.Sprintf("Failed to initialize credential storage: %v", err))
+			return returnError(locale, "auth-code", "auth.storage_failed", i18n.Args{"error": err})
 		}
 
 		// Create OAuth flow (we need this to exchange the code)
 		oauthFlow, err := provider.NewOAuthFlow("")
 		if err != nil {
-			return returnError("auth-code", fmt.Sprintf("Failed to create OAuth flow: %v", err))
+			return returnError(locale, "auth-code", "auth.oauth_flow_failed", i18n.Args{"error": err})
 		}
 
 		// Exchange the authorization code for tokens
@@ -841,7 +1108,8 @@ func createAuthCodeHandler() func(ctx context.Context, args string) (string, err
 				Type:    "auth_login",
 				Status:  "error",
 				Step:    "manual_api_key",
-				Message: "OAuth flow could not be completed automatically due to Cloudflare protection. \n\nPlease use an API key instead:\n\n1. Visit: https://console.anthropic.com/settings/keys\n2. Create a new API key\n3. Set the environment variable: export ANTHROPIC_API_KEY=your_api_key\n4. Restart the application\n\nThis will be fixed in a future update.",
+				Code:    "auth.manual_api_key",
+				Message: i18n.T(locale, "auth.manual_api_key", nil),
 			}
 			jsonData, _ := json.Marshal(response)
 			return string(jsonData), nil
@@ -850,18 +1118,19 @@ func createAuthCodeHandler() func(ctx context.Context, args string) (string, err
 		// Store the credentials
 		err = storage.StoreOAuthCredentials("anthropic", credentials.AccessToken, credentials.RefreshToken, credentials.ExpiresAt, credentials.ClientID)
 		if err != nil {
-			return returnError("auth-code", fmt.Sprintf("Failed to store credentials: %v", err))
+			return returnError(locale, "auth-code", "auth.store_failed", i18n.Args{"error": err})
 		}
 
 		response := AuthLoginResponse{
 			Type:    "auth_login",
 			Status:  "success",
-			Message: "✅ Successfully authenticated with Claude Code OAuth! You can now use the application.",
+			Code:    "auth.code_succeeded",
+			Message: i18n.T(locale, "auth.code_succeeded", nil),
 		}
 
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			return returnError("auth-code", fmt.Sprintf("Error marshaling success response: %v", err))
+			return returnEncodeError(locale, "auth-code", err)
 		}
 
 		return string(jsonData), nil
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"mix/internal/llm/models"
+	"mix/internal/logging"
+)
+
+// apiKeyEnv names the environment variable each key-based provider reads its
+// API key from
+var apiKeyEnv = map[models.ModelProvider]string{
+	models.ProviderAnthropic:  "ANTHROPIC_API_KEY",
+	models.ProviderOpenAI:     "OPENAI_API_KEY",
+	models.ProviderGemini:     "GEMINI_API_KEY",
+	models.ProviderGROQ:       "GROQ_API_KEY",
+	models.ProviderAzure:      "AZURE_OPENAI_API_KEY",
+	models.ProviderOpenRouter: "OPENROUTER_API_KEY",
+	models.ProviderXAI:        "XAI_API_KEY",
+}
+
+// apiKeyStore loads the keys kept in the encrypted credential store, which
+// lives in the provider package and is registered from there
+var apiKeyStore func() (map[models.ModelProvider]string, error)
+
+// APIKeyEnv returns the environment variable a provider's API key is read
+// from, or "" for providers that don't authenticate with a key.
+func APIKeyEnv(provider models.ModelProvider) string {
+	return apiKeyEnv[provider]
+}
+
+// HasCloudCredentials reports whether a provider that authenticates without
+// an API key, Bedrock through AWS and Vertex AI through Google Cloud, finds
+// credentials in the environment.
+func HasCloudCredentials(provider models.ModelProvider) bool {
+	switch provider {
+	case models.ProviderBedrock:
+		return hasAWSCredentials()
+	case models.ProviderVertexAI:
+		return hasVertexAICredentials()
+	}
+	return false
+}
+
+// SetAPIKeyStore registers the loader of stored API keys, which Load applies
+// over the keys from the config file and environment.
+func SetAPIKeyStore(load func() (map[models.ModelProvider]string, error)) {
+	apiKeyStore = load
+}
+
+func applyStoredAPIKeys(c *Config) {
+	if apiKeyStore == nil {
+		return
+	}
+	keys, err := apiKeyStore()
+	if err != nil {
+		logging.Warn("Failed to load stored API keys", "error", err)
+		return
+	}
+	cfgMutex.Lock()
+	defer cfgMutex.Unlock()
+	for provider, apiKey := range keys {
+		c.setProviderAPIKey(provider, apiKey)
+	}
+}
+
+// SetProviderAPIKey replaces a provider's API key at runtime, enabling the
+// provider if it was only disabled for lacking one. An empty key restores the
+// key from the config file or environment, disabling the provider again if
+// there is none. Providers already created keep their old key until they are
+// rebuilt.
+func SetProviderAPIKey(provider models.ModelProvider, apiKey string) {
+	cfgMutex.Lock()
+	defer cfgMutex.Unlock()
+
+	if cfg == nil {
+		return
+	}
+	cfg.setProviderAPIKey(provider, apiKey)
+}
+
+func (c *Config) setProviderAPIKey(provider models.ModelProvider, apiKey string) {
+	if c.Providers == nil {
+		c.Providers = make(map[models.ModelProvider]Provider)
+	}
+	if c.configuredAPIKeys == nil {
+		c.configuredAPIKeys = make(map[models.ModelProvider]string)
+	}
+	if c.keylessProviders == nil {
+		c.keylessProviders = make(map[models.ModelProvider]bool)
+	}
+	providerCfg := c.Providers[provider]
+	if _, saved := c.configuredAPIKeys[provider]; !saved {
+		c.configuredAPIKeys[provider] = providerCfg.APIKey
+	}
+	if apiKey == "" {
+		apiKey = c.configuredAPIKeys[provider]
+	}
+	providerCfg.APIKey = apiKey
+	switch {
+	case apiKey != "" && c.keylessProviders[provider]:
+		providerCfg.Disabled = false
+		delete(c.keylessProviders, provider)
+	case apiKey == "" && !providerCfg.Disabled && requiresAPIKey(provider, providerCfg):
+		providerCfg.Disabled = true
+		c.keylessProviders[provider] = true
+	}
+	c.Providers[provider] = providerCfg
+}
+
+// ConfiguredAPIKey returns a provider's key from the config file or
+// environment, ignoring any stored key that replaced it.
+func ConfiguredAPIKey(provider models.ModelProvider) string {
+	cfgMutex.RLock()
+	defer cfgMutex.RUnlock()
+
+	if cfg == nil {
+		return ""
+	}
+	if apiKey, ok := cfg.configuredAPIKeys[provider]; ok {
+		return apiKey
+	}
+	return cfg.Providers[provider].APIKey
+}
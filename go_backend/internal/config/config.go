@@ -2,15 +2,22 @@
 package config
 
 import (
+	"cmp"
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/user"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"mix/internal/llm/models"
 	"mix/internal/logging"
@@ -32,16 +39,49 @@ const (
 
 // MCPServer defines the configuration for a Model Control Protocol server.
 type MCPServer struct {
-	Command      string            `json:"command"`
-	Env          []string          `json:"env"`
-	Args         []string          `json:"args"`
-	Type         MCPType           `json:"type"`
-	URL          string            `json:"url"`
-	Headers      map[string]string `json:"headers"`
-	AllowedTools []string          `json:"allowedTools,omitempty"`
-	DeniedTools  []string          `json:"deniedTools,omitempty"`
+	Command string            `json:"command"`
+	Env     []string          `json:"env"`
+	Args    []string          `json:"args"`
+	Type    MCPType           `json:"type"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// AllowedTools and DeniedTools filter the server's tools by name, with
+	// path.Match wildcards such as "read_*"; a denied tool is left out even
+	// if it is allowed
+	AllowedTools []string `json:"allowedTools,omitempty"`
+	DeniedTools  []string `json:"deniedTools,omitempty"`
+	// AutoApprove lists tools, in the same patterns, that run without asking
+	// for permission
+	AutoApprove []string `json:"autoApprove,omitempty"`
 }
 
+// CommandTool is an executable the agent can call as a tool, so teams can add
+// tools without an MCP server. Each call runs Command with Args in the
+// session's working directory, writes the call's JSON input to its stdin and
+// reads the result from its stdout: either plain text, or a JSON object with
+// "content" and optionally "isError" and "metadata". A non-zero exit status
+// fails the call with the command's stderr. Calls ask for permission unless
+// AutoApprove is set.
+type CommandTool struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Command     string   `json:"command"`
+	Args        []string `json:"args,omitempty"`
+	Env         []string `json:"env,omitempty"`
+	// InputSchema is the JSON schema of the input, with "properties" and
+	// "required" like the input schemas of MCP tools. Unset, the tool takes
+	// no parameters.
+	InputSchema map[string]any `json:"inputSchema,omitempty"`
+	// TimeoutSeconds bounds a call. Zero uses 30 seconds.
+	TimeoutSeconds int  `json:"timeoutSeconds,omitempty"`
+	AutoApprove    bool `json:"autoApprove,omitempty"`
+}
+
+const (
+	DefaultCommandToolTimeoutSeconds = 30
+	MaxCommandToolTimeoutSeconds     = 600
+)
+
 type AgentName string
 
 const (
@@ -54,12 +94,245 @@ type Agent struct {
 	Model           models.ModelID `json:"model"`
 	MaxTokens       int64          `json:"maxTokens"`
 	ReasoningEffort string         `json:"reasoningEffort"` // For openai models low,medium,heigh
+	// Fallback models are tried in order when the model's provider is still
+	// rate limited or failing after retries
+	Fallback []models.ModelID `json:"fallback,omitempty"`
+	// Cache tunes Anthropic prompt caching; unset keeps the defaults
+	Cache *Cache `json:"cache,omitempty"`
+	// Thinking sets the extended thinking budget of Anthropic models that can
+	// reason; unset keeps budgets following phrases in the user's message
+	Thinking *Thinking `json:"thinking,omitempty"`
+	// History shortens the history sent with each request; unset sends all
+	// messages since the last summary
+	History *History `json:"history,omitempty"`
+}
+
+// Cache places Anthropic prompt cache breakpoints. Anthropic allows at most
+// MaxCacheBreakpoints per request.
+type Cache struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// System and Tools cache the system prompt and tool definitions (default: true)
+	System *bool `json:"system,omitempty"`
+	Tools  *bool `json:"tools,omitempty"`
+	// Messages is how many of the most recent messages get a breakpoint (default: 2)
+	Messages *int `json:"messages,omitempty"`
+}
+
+const (
+	MaxCacheBreakpoints   = 4
+	DefaultCachedMessages = 2
+)
+
+// Breakpoints resolves the defaults: whether to cache the system prompt and
+// tools, and how many recent messages to cache. A nil Cache uses the defaults.
+func (c *Cache) Breakpoints() (system, tools bool, messages int) {
+	if c == nil {
+		return true, true, DefaultCachedMessages
+	}
+	if c.Disabled {
+		return false, false, 0
+	}
+	system, tools, messages = true, true, DefaultCachedMessages
+	if c.System != nil {
+		system = *c.System
+	}
+	if c.Tools != nil {
+		tools = *c.Tools
+	}
+	if c.Messages != nil {
+		messages = *c.Messages
+	}
+	return system, tools, messages
+}
+
+// Thinking modes
+const (
+	// ThinkingPhrases budgets thinking by phrases such as "think hard" in the
+	// user's message
+	ThinkingPhrases = "phrases"
+	// ThinkingFixed gives every request Budget
+	ThinkingFixed = "fixed"
+	// ThinkingAdaptive raises Budget towards MaxBudget with the share of the
+	// session's recent tool calls that failed
+	ThinkingAdaptive = "adaptive"
+	ThinkingOff      = "off"
+
+	DefaultThinkingBudget = 4000
+	MaxThinkingBudget     = 31999
+	// MinThinkingBudget is the smallest budget Anthropic accepts
+	MinThinkingBudget = 1024
+)
+
+// Thinking configures the extended thinking budget, for an agent or, as the
+// thinking parameter of messages.send, a single request.
+type Thinking struct {
+	// Mode is ThinkingPhrases (default), ThinkingFixed, ThinkingAdaptive or
+	// ThinkingOff
+	Mode string `json:"mode,omitempty"`
+	// Budget is the token budget of the fixed and adaptive modes (default: 4000)
+	Budget int `json:"budget,omitempty"`
+	// MaxBudget caps the adaptive budget (default: 31999)
+	MaxBudget int `json:"maxBudget,omitempty"`
+}
+
+// Resolved returns t with its defaults filled in. A nil Thinking uses the
+// phrases mode.
+func (t *Thinking) Resolved() Thinking {
+	var resolved Thinking
+	if t != nil {
+		resolved = *t
+	}
+	if resolved.Mode == "" {
+		resolved.Mode = ThinkingPhrases
+	}
+	if resolved.Budget == 0 {
+		resolved.Budget = DefaultThinkingBudget
+	}
+	if resolved.MaxBudget == 0 {
+		resolved.MaxBudget = max(MaxThinkingBudget, resolved.Budget)
+	}
+	return resolved
+}
+
+// Validate checks the mode and budgets.
+func (t *Thinking) Validate() error {
+	if t == nil {
+		return nil
+	}
+	switch t.Mode {
+	case "", ThinkingPhrases, ThinkingFixed, ThinkingAdaptive, ThinkingOff:
+	default:
+		return fmt.Errorf("unknown thinking mode %q: must be %s, %s, %s or %s", t.Mode, ThinkingPhrases, ThinkingFixed, ThinkingAdaptive, ThinkingOff)
+	}
+	resolved := t.Resolved()
+	if resolved.Budget < MinThinkingBudget {
+		return fmt.Errorf("thinking budget must be at least %d tokens", MinThinkingBudget)
+	}
+	if resolved.MaxBudget < resolved.Budget {
+		return fmt.Errorf("thinking maxBudget must not be below budget")
+	}
+	return nil
+}
+
+// History strategies
+const (
+	// HistoryWindow sends the last KeepTurns turns only
+	HistoryWindow = "window"
+	// HistoryMiddleDrop sends the first KeepFirstTurns and the last KeepTurns
+	// turns, dropping those in between
+	HistoryMiddleDrop = "middle-drop"
+	// HistoryPruneToolResults sends every turn but replaces the tool results
+	// of those before the last KeepTurns with placeholders
+	HistoryPruneToolResults = "prune-tool-results"
+
+	DefaultHistoryKeepTurns      = 10
+	DefaultHistoryKeepFirstTurns = 1
+)
+
+// History shortens the history an agent sends with each request, on top of
+// summaries, which replace it for good. A turn is a user message and the
+// responses and tool results that follow it. Nothing is removed from the
+// session; the strategy only changes what the model is sent.
+type History struct {
+	Strategy string `json:"strategy"`
+	// KeepTurns is how many recent turns are sent verbatim (default: 10)
+	KeepTurns int `json:"keepTurns,omitempty"`
+	// KeepFirstTurns is how many leading turns middle-drop keeps (default: 1)
+	KeepFirstTurns int `json:"keepFirstTurns,omitempty"`
+	// ContextPercent applies the strategy only once the session's last
+	// request used this share of the model's context window; zero always
+	// applies it
+	ContextPercent int `json:"contextPercent,omitempty"`
+}
+
+// Resolved returns h with its defaults filled in.
+func (h History) Resolved() History {
+	if h.KeepTurns == 0 {
+		h.KeepTurns = DefaultHistoryKeepTurns
+	}
+	if h.KeepFirstTurns == 0 {
+		h.KeepFirstTurns = DefaultHistoryKeepFirstTurns
+	}
+	return h
+}
+
+// Validate checks the strategy and thresholds.
+func (h *History) Validate() error {
+	if h == nil {
+		return nil
+	}
+	switch h.Strategy {
+	case HistoryWindow, HistoryMiddleDrop, HistoryPruneToolResults:
+	default:
+		return fmt.Errorf("unknown history strategy %q: must be %s, %s or %s", h.Strategy, HistoryWindow, HistoryMiddleDrop, HistoryPruneToolResults)
+	}
+	if h.KeepTurns < 0 || h.KeepFirstTurns < 0 {
+		return fmt.Errorf("history keepTurns and keepFirstTurns must not be negative")
+	}
+	if h.ContextPercent < 0 || h.ContextPercent > 100 {
+		return fmt.Errorf("history contextPercent must be between 0 and 100")
+	}
+	return nil
 }
 
 // Provider defines configuration for an LLM provider.
 type Provider struct {
 	APIKey   string `json:"apiKey"`
 	Disabled bool   `json:"disabled"`
+
+	// Bedrock only. Region and Profile default to the AWS SDK's resolution
+	// (AWS_REGION, AWS_PROFILE, ~/.aws/config); InferenceProfileARN replaces
+	// the model ID, e.g. for an application inference profile.
+	Region              string `json:"region,omitempty"`
+	Profile             string `json:"profile,omitempty"`
+	InferenceProfileARN string `json:"inferenceProfileArn,omitempty"`
+
+	// Azure only. Endpoint and APIVersion default to AZURE_OPENAI_ENDPOINT and
+	// AZURE_OPENAI_API_VERSION. A model without a deployment is sent to a
+	// deployment named after it. EntraID authenticates with the Azure
+	// credential chain instead of an API key.
+	Endpoint    string            `json:"endpoint,omitempty"`
+	APIVersion  string            `json:"apiVersion,omitempty"`
+	Deployments []AzureDeployment `json:"deployments,omitempty"`
+	EntraID     bool              `json:"entraId,omitempty"`
+
+	// Vertex AI only. Project defaults to VERTEXAI_PROJECT or
+	// GOOGLE_CLOUD_PROJECT, and Location to VERTEXAI_LOCATION,
+	// GOOGLE_CLOUD_LOCATION, GOOGLE_CLOUD_REGION or us-central1. Requests
+	// authenticate with Application Default Credentials.
+	Project  string `json:"project,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// AzureDeployment maps a model to the Azure deployment serving it. It is a
+// list entry rather than a map because model IDs contain dots, which the
+// config loader treats as key separators.
+type AzureDeployment struct {
+	Model models.ModelID `json:"model"`
+	Name  string         `json:"name"`
+}
+
+// Deployment returns the Azure deployment name configured for modelID, or "".
+func (p Provider) Deployment(modelID models.ModelID) string {
+	for _, deployment := range p.Deployments {
+		if deployment.Model == modelID {
+			return deployment.Name
+		}
+	}
+	return ""
+}
+
+// requiresAPIKey reports whether a provider can't authenticate without an API
+// key. Anthropic and OpenAI support OAuth, Azure can use Entra ID, and Vertex
+// AI uses Google Cloud credentials.
+func requiresAPIKey(provider models.ModelProvider, providerCfg Provider) bool {
+	switch provider {
+	case models.ProviderAnthropic, models.ProviderOpenAI, models.ProviderVertexAI:
+		return false
+	case models.ProviderAzure:
+		return !providerCfg.EntraID
+	}
+	return true
 }
 
 // Data defines storage configuration.
@@ -75,12 +348,345 @@ type ShellConfig struct {
 	Args []string `json:"args,omitempty"`
 }
 
+// CompactToolsConfig controls abbreviated descriptions for tools that have not
+// been used in the first UnusedTurns assistant turns of a session. The choice
+// is made once per session, so the tools sent don't change from turn to turn.
+type CompactToolsConfig struct {
+	Enabled     bool `json:"enabled,omitempty"`
+	UnusedTurns int  `json:"unusedTurns,omitempty"`
+}
+
+// NetworkConfig is the egress policy for tools that reach the network. Entries
+// are domains (matching subdomains too), IPs or CIDRs. Denied entries win over
+// allowed ones; with DefaultDeny only allowed destinations are reachable.
+type NetworkConfig struct {
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+	DeniedHosts  []string `json:"deniedHosts,omitempty"`
+	DefaultDeny  bool     `json:"defaultDeny,omitempty"`
+}
+
+// ChaosConfig injects faults at the given rates (0 to 1) to exercise retry and
+// recovery paths. A zero Seed picks a random one. The MIX_CHAOS environment
+// variable overrides this section.
+type ChaosConfig struct {
+	Enabled            bool    `json:"enabled,omitempty"`
+	ProviderErrorRate  float64 `json:"providerErrorRate,omitempty"`
+	StreamTruncateRate float64 `json:"streamTruncateRate,omitempty"`
+	ToolTimeoutRate    float64 `json:"toolTimeoutRate,omitempty"`
+	DBLockRate         float64 `json:"dbLockRate,omitempty"`
+	Seed               int64   `json:"seed,omitempty"`
+}
+
+// ToolOutputConfig limits the size of tool results. Zero uses the default.
+type ToolOutputConfig struct {
+	MaxBytes int `json:"maxBytes,omitempty"`
+}
+
+// GrammarConfig selects the grammar checker. Without a LanguageToolURL the
+// built-in English rules are used. Username and APIKey are only needed for
+// LanguageTool Premium.
+type GrammarConfig struct {
+	LanguageToolURL string `json:"languageToolUrl,omitempty"`
+	Language        string `json:"language,omitempty"`
+	Username        string `json:"username,omitempty"`
+	APIKey          string `json:"apiKey,omitempty"`
+}
+
+// RenderConfig turns on server-side rendering of mermaid, graphviz and LaTeX
+// blocks in assistant messages, for clients without their own renderers. It
+// needs mmdc (mermaid-cli), dot, and latex with dvisvgm and dvipng installed
+// for the respective block types. Format is the default, "svg" or "png".
+type RenderConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Format  string `json:"format,omitempty"`
+}
+
+// FileWatchConfig streams the changes to a session's working directory to its
+// SSE clients as files_changed events. Paths matching the directory's root
+// .gitignore or Ignore, both gitignore patterns, are left out, and the changes
+// of each DebounceMs (default 250) are sent together.
+type FileWatchConfig struct {
+	Enabled    bool     `json:"enabled,omitempty"`
+	Ignore     []string `json:"ignore,omitempty"`
+	DebounceMs int      `json:"debounceMs,omitempty"`
+}
+
+// ThumbnailsConfig bounds each .thumbnails cache: files unused for longer than
+// MaxAgeHours (default 168) are pruned, then the least recently used ones
+// until the cache fits in MaxCacheMB (default 500).
+type ThumbnailsConfig struct {
+	MaxCacheMB  int `json:"maxCacheMb,omitempty"`
+	MaxAgeHours int `json:"maxAgeHours,omitempty"`
+}
+
+// BackupConfig schedules snapshots of the database and stored credentials.
+// Backups go to Directory (default <data directory>/backups), or to an
+// S3-compatible bucket when S3 is set, and only the newest Keep are retained.
+type BackupConfig struct {
+	Enabled       bool            `json:"enabled,omitempty"`
+	Directory     string          `json:"directory,omitempty"`
+	IntervalHours int             `json:"intervalHours,omitempty"`
+	Keep          int             `json:"keep,omitempty"`
+	S3            *S3BackupConfig `json:"s3,omitempty"`
+}
+
+// CheckpointsConfig controls the snapshots of the working directory taken
+// around each agent turn that changes files, which checkpoints.restore and
+// /undo revert. Files larger than MaxFileSizeMB (default 50) are left out of
+// the snapshots. Snapshots need git installed.
+type CheckpointsConfig struct {
+	Disabled      bool `json:"disabled,omitempty"`
+	MaxFileSizeMB int  `json:"maxFileSizeMb,omitempty"`
+}
+
+// MemoryConfig bounds the long-term memories the agent saves with
+// memory_write. The user's memories and each workspace's keep only their
+// newest MaxEntries (default 100), and a new session's system prompt carries
+// the newest memories up to MaxPromptChars (default 6000).
+type MemoryConfig struct {
+	Disabled       bool `json:"disabled,omitempty"`
+	MaxEntries     int  `json:"maxEntries,omitempty"`
+	MaxPromptChars int  `json:"maxPromptChars,omitempty"`
+}
+
+// Built-in reminders. plan_mode is attached to plan mode turns and
+// response_format to turns with a response format, the others are attached
+// like custom reminders.
+const (
+	ReminderPlanMode       = "plan_mode"
+	ReminderContextRefresh = "context_refresh"
+	ReminderTokenBudget    = "token_budget"
+	ReminderResponseFormat = "response_format"
+)
+
+// RemindersConfig defines the <system-reminder> blocks appended to user
+// messages. Sessions and turns attach reminders by name, and the Default ones
+// are attached to every turn. Besides Custom, there are the built-in
+// plan_mode, context_refresh, which resends the context files such as MIX.md
+// as they are now, and token_budget, which warns once the conversation uses
+// more than TokenBudgetPercent (default 80) of the model's context window.
+type RemindersConfig struct {
+	Custom             []Reminder `json:"custom,omitempty"`
+	Default            []string   `json:"default,omitempty"`
+	TokenBudgetPercent int        `json:"tokenBudgetPercent,omitempty"`
+}
+
+// Reminder is a custom reminder, such as a style guide. Its text is Text, or
+// the content of File read on every turn, relative to the session's working
+// directory. Reminders are sent sorted by Order, then by name; the built-in
+// ones have orders -200 (plan_mode), -100 (context_refresh) and 100
+// (token_budget).
+type Reminder struct {
+	Name  string `json:"name"`
+	Text  string `json:"text,omitempty"`
+	File  string `json:"file,omitempty"`
+	Order int    `json:"order,omitempty"`
+}
+
+// S3BackupConfig points backups at an S3-compatible bucket. Endpoint defaults
+// to AWS S3 in Region; set it for MinIO, R2 and the like. Credentials come
+// from the standard AWS chain, optionally using Profile.
+type S3BackupConfig struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Bucket   string `json:"bucket"`
+	Prefix   string `json:"prefix,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+// ArtifactStorageConfig uploads files generated in session output directories
+// to an S3 or Google Cloud Storage bucket, so they outlive the local disk.
+// Endpoint defaults to the provider's public endpoint. S3 credentials come
+// from the standard AWS chain, optionally using Profile; for GCS use an HMAC
+// key set as AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. When ExpireDays is
+// set, a lifecycle rule deleting uploads after that many days replaces the
+// bucket's lifecycle configuration.
+type ArtifactStorageConfig struct {
+	Provider         string `json:"provider"`
+	Endpoint         string `json:"endpoint,omitempty"`
+	Bucket           string `json:"bucket"`
+	Prefix           string `json:"prefix,omitempty"`
+	Region           string `json:"region,omitempty"`
+	Profile          string `json:"profile,omitempty"`
+	ExpireDays       int    `json:"expireDays,omitempty"`
+	URLExpiryMinutes int    `json:"urlExpiryMinutes,omitempty"`
+}
+
+// ModelCatalogConfig controls the catalog of models discovered from the
+// providers' model list endpoints, and OpenRouter's catalog. They're refreshed
+// in the background at startup once older than RefreshHours (default 24),
+// unless DisableRefresh is set; the models.refresh RPC refreshes the
+// providers' catalog on demand either way.
+type ModelCatalogConfig struct {
+	DisableRefresh bool `json:"disableRefresh,omitempty"`
+	RefreshHours   int  `json:"refreshHours,omitempty"`
+}
+
+// Event export sinks
+const (
+	EventSinkFile  = "file"
+	EventSinkNATS  = "nats"
+	EventSinkKafka = "kafka"
+)
+
+// EventExportConfig streams every agent event to an analytics pipeline: as
+// JSON lines appended to Path ("file"), published to the Topic subject of the
+// NATS server at URL ("nats"), or produced to Topic through the Kafka REST
+// proxy at URL ("kafka"). Message text and tool inputs are only exported with
+// IncludeContent.
+type EventExportConfig struct {
+	Sink           string `json:"sink"`
+	Path           string `json:"path,omitempty"`
+	URL            string `json:"url,omitempty"`
+	Topic          string `json:"topic,omitempty"`
+	IncludeContent bool   `json:"includeContent,omitempty"`
+}
+
+// TracingConfig exports OpenTelemetry spans of agent runs, model requests and
+// tool calls to the OTLP/HTTP collector at Endpoint, by default the one the
+// OTEL_EXPORTER_OTLP_ENDPOINT variable or localhost:4318 names. SampleRatio
+// is the fraction of runs traced (default 1).
+type TracingConfig struct {
+	Endpoint    string            `json:"endpoint,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ServiceName string            `json:"serviceName,omitempty"`
+	SampleRatio float64           `json:"sampleRatio,omitempty"`
+}
+
+// RedactionConfig tunes the redaction of secrets from logs, stored tool
+// results and exported transcripts. Well-known credential formats, the
+// configured API keys and the values of environment variables named like
+// secrets are always detected; Patterns adds regular expressions, and
+// MinEntropy, when set, long random-looking words. Strict withholds tool
+// results containing credentials instead of redacting them.
+type RedactionConfig struct {
+	Disabled   bool     `json:"disabled,omitempty"`
+	Strict     bool     `json:"strict,omitempty"`
+	Patterns   []string `json:"patterns,omitempty"`
+	MinEntropy float64  `json:"minEntropy,omitempty"`
+}
+
+// SharingConfig tunes the links sessions.share creates. Secret signs their
+// tokens; unset, a key generated into the data directory does. BaseURL, the
+// server's public URL, makes links absolute.
+type SharingConfig struct {
+	BaseURL string `json:"baseUrl,omitempty"`
+	Secret  string `json:"secret,omitempty"`
+}
+
+// MinSharingSecretLength is the shortest sharing.secret accepted
+const MinSharingSecretLength = 32
+
+// Webhook events
+const (
+	WebhookResponseCompleted = "response.completed"
+	WebhookResponseFailed    = "response.failed"
+)
+
+// WebhookConfig posts a JSON payload to URL when an agent response completes
+// or fails. Events limits the deliveries to some of the webhook events; empty
+// sends all of them. With Secret set, the body is signed with HMAC-SHA256.
+type WebhookConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+	// MaxAttempts bounds the tries of a delivery, retried with backoff on
+	// network errors, 429 and 5xx responses. Zero uses 4.
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+// HTTP API token scopes
+const (
+	// HTTPScopeRead allows the JSON-RPC methods that only read state
+	HTTPScopeRead = "read"
+	// HTTPScopeFull allows every method
+	HTTPScopeFull = "full"
+)
+
+// HTTPAuthConfig requires HTTP server clients to present a bearer token: one
+// of Tokens, or a JWT verified against JWT. Without either the server is open.
+type HTTPAuthConfig struct {
+	Tokens []HTTPToken    `json:"tokens,omitempty"`
+	JWT    *HTTPJWTConfig `json:"jwt,omitempty"`
+}
+
+// HTTPToken is a static API token. Scope is read or full; empty means full.
+type HTTPToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Scope string `json:"scope,omitempty"`
+}
+
+// HTTPJWTConfig verifies JWTs signed with the HMAC Secret or the RSA, ECDSA or
+// Ed25519 public key in the PEM file PublicKeyFile. Issuer and Audience are
+// checked when set. The scope is read from ScopeClaim ("scope" by default), a
+// space-separated string or list that must include read or full.
+type HTTPJWTConfig struct {
+	Secret        string `json:"secret,omitempty"`
+	PublicKeyFile string `json:"publicKeyFile,omitempty"`
+	Issuer        string `json:"issuer,omitempty"`
+	Audience      string `json:"audience,omitempty"`
+	ScopeClaim    string `json:"scopeClaim,omitempty"`
+}
+
+// Enabled reports whether clients must authenticate.
+func (c HTTPAuthConfig) Enabled() bool {
+	return len(c.Tokens) > 0 || c.JWT != nil
+}
+
+// Output profile modes
+const (
+	// OutputModeTruncate stores the full response as an artifact and returns
+	// the first MaxBytes with a pointer to it
+	OutputModeTruncate = "truncate"
+	// OutputModeSplit returns the response as continuation parts of at most
+	// MaxBytes each
+	OutputModeSplit = "split"
+)
+
+// OutputProfile limits the size of assistant responses returned to a kind of
+// client. Sessions opt into a profile by name.
+type OutputProfile struct {
+	Name     string `json:"name"`
+	MaxBytes int    `json:"maxBytes"`
+	Mode     string `json:"mode"`
+}
+
+// SandboxProfile confines the shell that runs a session's bash commands. On
+// Linux, paths and network are isolated with bubblewrap (bwrap) and resources
+// limited by a systemd user scope's cgroup; on macOS, paths and network are
+// isolated with sandbox-exec. A profile that can't be enforced on the host
+// fails commands rather than running them unconfined.
+type SandboxProfile struct {
+	Name string `json:"name"`
+	// Path replaces PATH, e.g. "/usr/bin:/bin". Sandboxed shells only keep
+	// HOME, USER, LANG, TERM and TMPDIR of the server's environment, plus the
+	// session's variables.
+	Path string `json:"path,omitempty"`
+	// ReadOnlyPaths can be read but not written; "/" makes everything but the
+	// working directory and the temporary directory read-only
+	ReadOnlyPaths []string `json:"readOnlyPaths,omitempty"`
+	// HiddenPaths, such as ~/.ssh, can't be read at all
+	HiddenPaths    []string `json:"hiddenPaths,omitempty"`
+	DisableNetwork bool     `json:"disableNetwork,omitempty"`
+	// CPUPercent caps CPU time, 100 being one core. MemoryMB and MaxProcesses
+	// cap the shell and everything it starts. Zero leaves a resource
+	// unlimited; these limits are only supported on Linux.
+	CPUPercent   int `json:"cpuPercent,omitempty"`
+	MemoryMB     int `json:"memoryMb,omitempty"`
+	MaxProcesses int `json:"maxProcesses,omitempty"`
+	// TimeoutSeconds caps the timeout of each command
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
 // Config is the simplified configuration structure for embedded binary.
 type Config struct {
 	Data             Data                              `json:"data"`
 	WorkingDir       string                            `json:"wd,omitempty"`
 	PromptsDir       string                            `json:"promptsDir,omitempty"`
+	PromptVars       map[string]string                 `json:"promptVars,omitempty"`
 	MCPServers       map[string]MCPServer              `json:"mcpServers,omitempty"`
+	CommandTools     []CommandTool                     `json:"commandTools,omitempty"`
 	Providers        map[models.ModelProvider]Provider `json:"providers,omitempty"`
 	Agents           map[AgentName]Agent               `json:"agents,omitempty"`
 	Debug            bool                              `json:"debug,omitempty"`
@@ -88,6 +694,92 @@ type Config struct {
 	Shell            ShellConfig                       `json:"shell,omitempty"`
 	SkipPermissions  bool                              `json:"skipPermissions,omitempty"`
 	AnalyticsEnabled bool                              `json:"analyticsEnabled,omitempty"`
+	CompactTools     CompactToolsConfig                `json:"compactTools,omitempty"`
+	Network          NetworkConfig                     `json:"network,omitempty"`
+	// MaxSessionCost stops a session's generation once its cost in USD,
+	// including the running estimate for the current turn, exceeds this value
+	MaxSessionCost float64     `json:"maxSessionCost,omitempty"`
+	Chaos          ChaosConfig `json:"chaos,omitempty"`
+	// ToolOutput bounds tool results returned to the model; the rest is kept
+	// as an artifact readable with the view_artifact tool
+	ToolOutput ToolOutputConfig `json:"toolOutput,omitempty"`
+	Grammar    GrammarConfig    `json:"grammar,omitempty"`
+	// ToolCosts is the USD price per call of tools backed by paid APIs, keyed
+	// by tool name. It applies to tools, such as MCP tools, that don't report
+	// their own cost, and counts toward MaxSessionCost.
+	ToolCosts map[string]float64 `json:"toolCosts,omitempty"`
+	Backup    BackupConfig       `json:"backup,omitempty"`
+	// Checkpoints snapshot the files agent turns change so they can be undone
+	Checkpoints CheckpointsConfig `json:"checkpoints,omitempty"`
+	Reminders   RemindersConfig   `json:"reminders,omitempty"`
+	Memory      MemoryConfig      `json:"memory,omitempty"`
+	// ProbeProviders sends a minimal request to every model the agents use at
+	// startup and logs misconfigured credentials or missing model access
+	ProbeProviders bool         `json:"probeProviders,omitempty"`
+	Render         RenderConfig `json:"render,omitempty"`
+	// Thumbnails bounds the .thumbnails caches of the working directories
+	// the asset server generates thumbnails and previews in
+	Thumbnails ThumbnailsConfig `json:"thumbnails,omitempty"`
+	FileWatch  FileWatchConfig  `json:"fileWatch,omitempty"`
+	// ArtifactStorage is unset to keep generated assets on local disk only
+	ArtifactStorage *ArtifactStorageConfig `json:"artifactStorage,omitempty"`
+	// OutputProfiles are the response size policies sessions can select
+	OutputProfiles []OutputProfile `json:"outputProfiles,omitempty"`
+	// SandboxProfiles confine bash commands. Sessions select one by name, or
+	// use DefaultSandboxProfile; without either, commands run unconfined.
+	SandboxProfiles       []SandboxProfile `json:"sandboxProfiles,omitempty"`
+	DefaultSandboxProfile string           `json:"defaultSandboxProfile,omitempty"`
+	// Locale selects the language of command responses, e.g. "de"; sessions
+	// can override it. LocalesDir holds extra <locale>.json message catalogs.
+	Locale     string `json:"locale,omitempty"`
+	LocalesDir string `json:"localesDir,omitempty"`
+	// ResumeInterrupted regenerates, at startup, the responses a crashed or
+	// killed server left unfinished
+	ResumeInterrupted bool `json:"resumeInterrupted,omitempty"`
+	// EventExport is unset to keep agent events in-process
+	EventExport *EventExportConfig `json:"eventExport,omitempty"`
+	// Tracing is unset to leave agent turns untraced
+	Tracing      *TracingConfig     `json:"tracing,omitempty"`
+	ModelCatalog ModelCatalogConfig `json:"modelCatalog,omitempty"`
+	Webhooks     []WebhookConfig    `json:"webhooks,omitempty"`
+	HTTPAuth     HTTPAuthConfig     `json:"httpAuth,omitempty"`
+	Redaction    RedactionConfig    `json:"redaction,omitempty"`
+	Sharing      SharingConfig      `json:"sharing,omitempty"`
+	// PermissionTimeoutSeconds is how long a permission request waits for an
+	// answer before it is denied. Zero uses 30 seconds.
+	PermissionTimeoutSeconds int `json:"permissionTimeoutSeconds,omitempty"`
+	// QuestionTimeoutSeconds is how long an ask_user question waits for an
+	// answer before the turn goes on without one. Zero uses 5 minutes.
+	QuestionTimeoutSeconds int `json:"questionTimeoutSeconds,omitempty"`
+	// DryRun makes every turn a dry run: tools that could change anything
+	// describe what they would do instead of doing it
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// configuredAPIKeys holds the config file or environment keys that stored
+	// keys replaced, restored when the stored key is cleared
+	configuredAPIKeys map[models.ModelProvider]string
+	// keylessProviders were disabled by validation for lacking an API key
+	keylessProviders map[models.ModelProvider]bool
+}
+
+// OutputProfile returns the output profile with the given name.
+func (c *Config) OutputProfile(name string) (OutputProfile, bool) {
+	for _, profile := range c.OutputProfiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return OutputProfile{}, false
+}
+
+// SandboxProfile returns the sandbox profile with the given name.
+func (c *Config) SandboxProfile(name string) (SandboxProfile, bool) {
+	for _, profile := range c.SandboxProfiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return SandboxProfile{}, false
 }
 
 // Application constants
@@ -152,65 +844,19 @@ func Load(workingDir string, debug bool, skipPermissions bool) (*Config, error)
 		return cfg, nil
 	}
 
-	configureViper()
-	setDefaults(debug)
-
-	// Ensure config file exists in home directory
-	if err := ensureConfigFile(); err != nil {
-		return nil, fmt.Errorf("failed to initialize config file: %w", err)
-	}
-
-	// Read global config
-	if err := readConfig(viper.ReadInConfig()); err != nil {
-		return nil, err
-	}
-
-	// Load and merge local config
-	mergeLocalConfig(workingDir)
-
-	// Get prompts directory from config with default expansion
-	promptsDir := viper.GetString("promptsDir")
-	if promptsDir == "" {
-		homeDir, err := user.Current()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		promptsDir = filepath.Join(homeDir.HomeDir, ".mix", "prompts")
-	} else if strings.HasPrefix(promptsDir, "~/") {
-		// Expand ~ to home directory
-		homeDir, err := user.Current()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		promptsDir = filepath.Join(homeDir.HomeDir, promptsDir[2:])
-	}
-
-	cfg = &Config{
-		WorkingDir:      workingDir,
-		PromptsDir:      promptsDir,
-		MCPServers:      make(map[string]MCPServer),
-		Providers:       make(map[models.ModelProvider]Provider),
-		SkipPermissions: skipPermissions,
+	c, err := readConfigFiles(viper.GetViper(), workingDir, debug, skipPermissions)
+	if c != nil {
+		cfg = c
 	}
-
-	setProviderDefaults()
-
-	// Apply configuration to the struct
-	if err := viper.Unmarshal(cfg); err != nil {
-		return cfg, fmt.Errorf("failed to unmarshal config: %w", err)
+	if err != nil {
+		return cfg, err
 	}
 
-	// Restore prompts directory after viper unmarshal (which overwrites with empty default)
-	cfg.PromptsDir = promptsDir
-
-	applyDefaultValues()
-
 	// Ensure embedded .mix directory structure is written to home directory
 	if err := ensureEmbeddedDataDirectory(); err != nil {
 		return cfg, fmt.Errorf("failed to initialize embedded data directory: %w", err)
 	}
 
-	// Prompts directory no longer needed - all prompts are embedded
 	defaultLevel := slog.LevelInfo
 	if cfg.Debug {
 		defaultLevel = slog.LevelDebug
@@ -267,11 +913,88 @@ func Load(workingDir string, debug bool, skipPermissions bool) (*Config, error)
 		slog.SetDefault(logger)
 	}
 
+	// Catalogs must be loaded before agents referencing their models are validated
+	loadModelCatalog()
+	loadOpenRouterCatalog()
+
+	// Stored keys must be in place before providers without one are disabled
+	applyStoredAPIKeys(cfg)
+
 	// Validate configuration
 	if err := Validate(); err != nil {
 		return cfg, fmt.Errorf("config validation failed: %w", err)
 	}
+	if err := requireAgents(cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// readConfigFiles reads the global and local config files through v into a
+// new configuration, before catalogs, stored keys and validation are applied.
+func readConfigFiles(v *viper.Viper, workingDir string, debug bool, skipPermissions bool) (*Config, error) {
+	configureViper(v)
+	setDefaults(v, debug)
+
+	// Ensure config file exists in home directory
+	if err := ensureConfigFile(); err != nil {
+		return nil, fmt.Errorf("failed to initialize config file: %w", err)
+	}
+
+	// Read global config
+	if err := readConfig(v.ReadInConfig()); err != nil {
+		return nil, err
+	}
+	// Viper ignores unknown keys and coerces mistyped values, so the files are
+	// checked against the schema first
+	if err := validateConfigFile(v.ConfigFileUsed()); err != nil {
+		return nil, err
+	}
+
+	// Load and merge local config
+	if err := mergeLocalConfig(v, workingDir); err != nil {
+		return nil, err
+	}
+
+	// Project prompt overrides live in .mix/prompts unless configured; relative paths are project-relative
+	promptsDir := v.GetString("promptsDir")
+	if promptsDir == "" {
+		promptsDir = filepath.Join(workingDir, defaultDataDirectory, "prompts")
+	} else if strings.HasPrefix(promptsDir, "~/") {
+		// Expand ~ to home directory
+		homeDir, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		promptsDir = filepath.Join(homeDir.HomeDir, promptsDir[2:])
+	} else if !filepath.IsAbs(promptsDir) {
+		promptsDir = filepath.Join(workingDir, promptsDir)
+	}
+
+	c := &Config{
+		WorkingDir:      workingDir,
+		PromptsDir:      promptsDir,
+		MCPServers:      make(map[string]MCPServer),
+		Providers:       make(map[models.ModelProvider]Provider),
+		SkipPermissions: skipPermissions,
+	}
+
+	setProviderDefaults(v)
+
+	// Apply configuration to the struct
+	if err := v.Unmarshal(c); err != nil {
+		return c, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// Restore prompts directory after viper unmarshal (which overwrites with empty default)
+	c.PromptsDir = promptsDir
 
+	applyDefaultValues(c)
+	return c, nil
+}
+
+// requireAgents checks that the main and sub agents are configured.
+func requireAgents(cfg *Config) error {
 	if cfg.Agents == nil {
 		cfg.Agents = make(map[AgentName]Agent)
 	}
@@ -283,62 +1006,68 @@ func Load(workingDir string, debug bool, skipPermissions bool) (*Config, error)
 	cfgMutex.RUnlock()
 
 	if !mainExists {
-		return cfg, fmt.Errorf("main agent not configured - please specify model in configuration file")
+		return fmt.Errorf("main agent not configured - please specify model in configuration file")
 	}
 	if !subExists {
-		return cfg, fmt.Errorf("sub agent not configured - please specify model in configuration file")
+		return fmt.Errorf("sub agent not configured - please specify model in configuration file")
 	}
-	return cfg, nil
+	return nil
 }
 
 // configureViper sets up viper's configuration paths and environment variables.
-func configureViper() {
-	viper.SetConfigName(fmt.Sprintf(".%s", appName))
-	viper.SetConfigType("json")
-	viper.AddConfigPath("$HOME")
-	viper.AddConfigPath(fmt.Sprintf("$XDG_CONFIG_HOME/%s", appName))
-	viper.AddConfigPath(fmt.Sprintf("$HOME/.config/%s", appName))
-	viper.SetEnvPrefix(strings.ToUpper(appName))
-	viper.AutomaticEnv()
+func configureViper(v *viper.Viper) {
+	v.SetConfigName(fmt.Sprintf(".%s", appName))
+	v.SetConfigType("json")
+	v.AddConfigPath("$HOME")
+	v.AddConfigPath(fmt.Sprintf("$XDG_CONFIG_HOME/%s", appName))
+	v.AddConfigPath(fmt.Sprintf("$HOME/.config/%s", appName))
+	v.SetEnvPrefix(strings.ToUpper(appName))
+	v.AutomaticEnv()
 }
 
 // setDefaults configures default values for embedded binary configuration.
-func setDefaults(debug bool) {
-	viper.SetDefault("data.directory", defaultDataDirectory)
-	viper.SetDefault("contextPaths", defaultContextPaths)
-	viper.SetDefault("promptsDir", "")
+func setDefaults(v *viper.Viper, debug bool) {
+	v.SetDefault("data.directory", defaultDataDirectory)
+	v.SetDefault("contextPaths", defaultContextPaths)
+	v.SetDefault("promptsDir", "")
+	v.SetDefault("compactTools.unusedTurns", 3)
 
 	// Set default shell from environment or fallback to /bin/bash
 	shellPath := os.Getenv("SHELL")
 	if shellPath == "" {
 		shellPath = "/bin/bash"
 	}
-	viper.SetDefault("shell.path", shellPath)
-	viper.SetDefault("shell.args", []string{"-l"})
+	v.SetDefault("shell.path", shellPath)
+	v.SetDefault("shell.args", []string{"-l"})
 
 	// Check for analytics enabled flag from environment
 	analyticsEnabled := os.Getenv("MIX_ANALYTICS_ENABLED")
 	if analyticsEnabled != "" {
-		viper.SetDefault("analyticsEnabled", analyticsEnabled == "true" || analyticsEnabled == "1")
+		v.SetDefault("analyticsEnabled", analyticsEnabled == "true" || analyticsEnabled == "1")
 	} else {
-		viper.SetDefault("analyticsEnabled", true) // Default to true for backward compatibility
+		v.SetDefault("analyticsEnabled", true) // Default to true for backward compatibility
 	}
 
 	if debug {
-		viper.SetDefault("debug", true)
-		viper.Set("log.level", "debug")
+		v.SetDefault("debug", true)
+		v.Set("log.level", "debug")
 	} else {
-		viper.SetDefault("debug", false)
-		viper.SetDefault("log.level", defaultLogLevel)
+		v.SetDefault("debug", false)
+		v.SetDefault("log.level", defaultLogLevel)
 	}
 }
 
 // setProviderDefaults configures LLM provider defaults for embedded binary.
-func setProviderDefaults() {
+func setProviderDefaults(v *viper.Viper) {
 
 	if apiKey := os.Getenv("AZURE_OPENAI_ENDPOINT"); apiKey != "" {
 		// api-key may be empty when using Entra ID credentials – that's okay
-		viper.SetDefault("providers.azure.apiKey", os.Getenv("AZURE_OPENAI_API_KEY"))
+		v.SetDefault("providers.azure.apiKey", os.Getenv("AZURE_OPENAI_API_KEY"))
+	}
+
+	// Bedrock authenticates with AWS credentials; the placeholder key keeps it enabled
+	if hasAWSCredentials() {
+		v.SetDefault("providers.bedrock.apiKey", getProviderAPIKey(models.ProviderBedrock))
 	}
 }
 
@@ -368,17 +1097,11 @@ func hasAWSCredentials() bool {
 	return false
 }
 
-// hasVertexAICredentials checks if VertexAI credentials are available in the environment.
+// hasVertexAICredentials checks if the environment names a Google Cloud
+// project for Vertex AI. The location has a default, and the credentials are
+// the Application Default Credentials found when the provider is created.
 func hasVertexAICredentials() bool {
-	// Check for explicit VertexAI parameters
-	if os.Getenv("VERTEXAI_PROJECT") != "" && os.Getenv("VERTEXAI_LOCATION") != "" {
-		return true
-	}
-	// Check for Google Cloud project and location
-	if os.Getenv("GOOGLE_CLOUD_PROJECT") != "" && (os.Getenv("GOOGLE_CLOUD_REGION") != "" || os.Getenv("GOOGLE_CLOUD_LOCATION") != "") {
-		return true
-	}
-	return false
+	return os.Getenv("VERTEXAI_PROJECT") != "" || os.Getenv("GOOGLE_CLOUD_PROJECT") != ""
 }
 
 // readConfig handles the result of reading a configuration file.
@@ -450,7 +1173,8 @@ func ensureConfigFile() error {
 }
 
 // mergeLocalConfig loads and merges configuration from the local directory.
-func mergeLocalConfig(workingDir string) {
+// It fails when the local config doesn't match the schema.
+func mergeLocalConfig(v *viper.Viper, workingDir string) error {
 	local := viper.New()
 	local.SetConfigName(fmt.Sprintf(".%s", appName))
 	local.SetConfigType("json")
@@ -458,12 +1182,16 @@ func mergeLocalConfig(workingDir string) {
 
 	// Merge local config if it exists
 	if err := local.ReadInConfig(); err == nil {
-		viper.MergeConfigMap(local.AllSettings())
+		if err := validateConfigFile(local.ConfigFileUsed()); err != nil {
+			return err
+		}
+		v.MergeConfigMap(local.AllSettings())
 	}
+	return nil
 }
 
 // applyDefaultValues sets default values for configuration fields that need processing.
-func applyDefaultValues() {
+func applyDefaultValues(cfg *Config) {
 	// Set default MCP type if not specified
 	cfgMutex.Lock()
 	for k, v := range cfg.MCPServers {
@@ -476,40 +1204,43 @@ func applyDefaultValues() {
 }
 
 // It validates model IDs and providers, ensuring they are supported.
-func validateAgent(cfg *Config, name AgentName, agent Agent) error {
-	// Check if model exists
-	model, modelExists := models.SupportedModels[agent.Model]
-	if !modelExists {
-		return fmt.Errorf("unsupported model %s configured for agent %s", agent.Model, name)
+func validateCache(cache *Cache) error {
+	system, tools, messages := cache.Breakpoints()
+	if messages < 0 {
+		return fmt.Errorf("messages must not be negative")
+	}
+	total := messages
+	if system {
+		total++
+	}
+	if tools {
+		total++
+	}
+	if total > MaxCacheBreakpoints {
+		return fmt.Errorf("%d cache breakpoints configured, Anthropic allows at most %d", total, MaxCacheBreakpoints)
 	}
+	return nil
+}
 
-	// Check if provider for the model is configured
+func validateAgent(cfg *Config, name AgentName, agent Agent) error {
+	model, err := validateAgentModel(cfg, name, agent.Model)
+	if err != nil {
+		return err
+	}
 	provider := model.Provider
-	cfgMutex.RLock()
-	providerCfg, providerExists := cfg.Providers[provider]
-	cfgMutex.RUnlock()
-
-	if !providerExists {
-		// Provider not configured, check if we have environment variables
-		apiKey := getProviderAPIKey(provider)
-		if apiKey == "" && provider != "anthropic" && provider != "openai" {
-			return fmt.Errorf("provider %s not configured for agent %s (model %s) and no API key found in environment", provider, name, agent.Model)
-		}
-		// Add provider - with API key from environment or empty for OAuth-supported providers
-		cfgMutex.Lock()
-		cfg.Providers[provider] = Provider{
-			APIKey: apiKey,
+	for _, fallback := range agent.Fallback {
+		if _, err := validateAgentModel(cfg, name, fallback); err != nil {
+			return fmt.Errorf("invalid fallback: %w", err)
 		}
-		cfgMutex.Unlock()
-		if apiKey != "" {
-			logging.Info("added provider from environment", "provider", provider)
-		} else {
-			logging.Info("added provider without API key (OAuth-supported)", "provider", provider)
-		}
-	} else if providerCfg.Disabled {
-		return fmt.Errorf("provider %s is disabled for agent %s (model %s)", provider, name, agent.Model)
-	} else if providerCfg.APIKey == "" && provider != "anthropic" && provider != "openai" {
-		return fmt.Errorf("provider %s has no API key configured for agent %s (model %s)", provider, name, agent.Model)
+	}
+	if err := validateCache(agent.Cache); err != nil {
+		return fmt.Errorf("invalid cache config for agent %s: %w", name, err)
+	}
+	if err := agent.Thinking.Validate(); err != nil {
+		return fmt.Errorf("invalid thinking config for agent %s: %w", name, err)
+	}
+	if err := agent.History.Validate(); err != nil {
+		return fmt.Errorf("invalid history config for agent %s: %w", name, err)
 	}
 
 	// Validate max tokens
@@ -594,11 +1325,55 @@ func validateAgent(cfg *Config, name AgentName, agent Agent) error {
 	return nil
 }
 
+// validateAgentModel checks that modelID is supported and its provider is
+// usable, adding the provider from the environment when it is not configured.
+func validateAgentModel(cfg *Config, name AgentName, modelID models.ModelID) (models.Model, error) {
+	// Check if model exists
+	model, modelExists := models.Lookup(modelID)
+	if !modelExists {
+		return model, fmt.Errorf("unsupported model %s configured for agent %s", modelID, name)
+	}
+
+	// Check if provider for the model is configured
+	provider := model.Provider
+	cfgMutex.RLock()
+	providerCfg, providerExists := cfg.Providers[provider]
+	cfgMutex.RUnlock()
+
+	if !providerExists {
+		// Provider not configured, check if we have environment variables
+		apiKey := getProviderAPIKey(provider)
+		if apiKey == "" && provider != "anthropic" && provider != "openai" {
+			return model, fmt.Errorf("provider %s not configured for agent %s (model %s) and no API key found in environment", provider, name, modelID)
+		}
+		// Add provider - with API key from environment or empty for OAuth-supported providers
+		cfgMutex.Lock()
+		cfg.Providers[provider] = Provider{
+			APIKey: apiKey,
+		}
+		cfgMutex.Unlock()
+		if apiKey != "" {
+			logging.Info("added provider from environment", "provider", provider)
+		} else {
+			logging.Info("added provider without API key (OAuth-supported)", "provider", provider)
+		}
+	} else if providerCfg.Disabled {
+		return model, fmt.Errorf("provider %s is disabled for agent %s (model %s)", provider, name, modelID)
+	} else if providerCfg.APIKey == "" && requiresAPIKey(provider, providerCfg) {
+		return model, fmt.Errorf("provider %s has no API key configured for agent %s (model %s)", provider, name, modelID)
+	}
+	return model, nil
+}
+
 // Validate checks if the configuration is valid and applies defaults where needed.
 func Validate() error {
 	if cfg == nil {
 		return fmt.Errorf("config not loaded")
 	}
+	return validate(cfg)
+}
+
+func validate(cfg *Config) error {
 
 	// Validate agent models
 	for name, agent := range cfg.Agents {
@@ -607,39 +1382,506 @@ func Validate() error {
 		}
 	}
 
+	for tool, cost := range cfg.ToolCosts {
+		if cost < 0 {
+			return fmt.Errorf("invalid cost for tool %s: must not be negative", tool)
+		}
+	}
+	if cfg.PermissionTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid permissionTimeoutSeconds: must not be negative")
+	}
+	if cfg.QuestionTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid questionTimeoutSeconds: must not be negative")
+	}
+
+	if err := validateBackup(cfg.Backup); err != nil {
+		return err
+	}
+	if err := validateArtifactStorage(cfg.ArtifactStorage); err != nil {
+		return err
+	}
+	if f := cfg.Render.Format; f != "" && f != "svg" && f != "png" {
+		return fmt.Errorf("invalid render.format %q: must be svg or png", f)
+	}
+	if err := validateSandboxProfiles(cfg); err != nil {
+		return err
+	}
+	if err := validateOutputProfiles(cfg.OutputProfiles); err != nil {
+		return err
+	}
+	if err := validateReminders(cfg.Reminders); err != nil {
+		return err
+	}
+	if err := validateEventExport(cfg.EventExport); err != nil {
+		return err
+	}
+	if err := validateTracing(cfg.Tracing); err != nil {
+		return err
+	}
+	if err := validateRedaction(cfg.Redaction); err != nil {
+		return err
+	}
+	if err := validateSharing(cfg.Sharing); err != nil {
+		return err
+	}
+	if err := validateWebhooks(cfg.Webhooks); err != nil {
+		return err
+	}
+	if err := validateMCPServers(cfg.MCPServers); err != nil {
+		return err
+	}
+	if err := validateCommandTools(cfg.CommandTools); err != nil {
+		return err
+	}
+	if err := validateHTTPAuth(cfg.HTTPAuth); err != nil {
+		return err
+	}
+
 	// Validate providers
 	cfgMutex.Lock()
 	for provider, providerCfg := range cfg.Providers {
 		// Skip API key validation for providers that support OAuth authentication
-		if providerCfg.APIKey == "" && !providerCfg.Disabled && provider != "anthropic" && provider != "openai" {
-			fmt.Printf("provider has no API key, marking as disabled %s", provider)
+		if providerCfg.APIKey == "" && !providerCfg.Disabled && requiresAPIKey(provider, providerCfg) {
 			logging.Warn("provider has no API key, marking as disabled", "provider", provider)
 			providerCfg.Disabled = true
 			cfg.Providers[provider] = providerCfg
+			if cfg.keylessProviders == nil {
+				cfg.keylessProviders = make(map[models.ModelProvider]bool)
+			}
+			cfg.keylessProviders[provider] = true
 		}
 	}
 	cfgMutex.Unlock()
 
+	if azure, ok := cfg.Providers[models.ProviderAzure]; ok && !azure.Disabled {
+		if err := validateAzure(azure); err != nil {
+			return err
+		}
+	}
+
+	if vertex, ok := cfg.Providers[models.ProviderVertexAI]; ok && !vertex.Disabled {
+		if vertex.Project == "" && !hasVertexAICredentials() {
+			return fmt.Errorf("providers.vertexai.project is required (or set VERTEXAI_PROJECT or GOOGLE_CLOUD_PROJECT)")
+		}
+	}
+
 	// Removed LSP validation for embedded binary
 
 	return nil
 }
 
+// validateAzure checks the endpoint settings and that deployments are only
+// mapped for Azure models.
+func validateAzure(azure Provider) error {
+	endpoint := azure.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+	}
+	if endpoint == "" {
+		return fmt.Errorf("providers.azure.endpoint is required (or set AZURE_OPENAI_ENDPOINT)")
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid providers.azure.endpoint %q: must be a URL such as https://my-resource.openai.azure.com", endpoint)
+	}
+	if azure.APIVersion == "" && os.Getenv("AZURE_OPENAI_API_VERSION") == "" {
+		return fmt.Errorf("providers.azure.apiVersion is required (or set AZURE_OPENAI_API_VERSION)")
+	}
+	seen := make(map[models.ModelID]bool)
+	for _, deployment := range azure.Deployments {
+		model, ok := models.Lookup(deployment.Model)
+		if !ok || model.Provider != models.ProviderAzure {
+			return fmt.Errorf("providers.azure.deployments: %q is not an Azure model", deployment.Model)
+		}
+		if strings.TrimSpace(deployment.Name) == "" {
+			return fmt.Errorf("providers.azure.deployments: empty deployment name for %s", deployment.Model)
+		}
+		if seen[deployment.Model] {
+			return fmt.Errorf("providers.azure.deployments: %s is mapped more than once", deployment.Model)
+		}
+		seen[deployment.Model] = true
+	}
+	return nil
+}
+
+func validateBackup(backup BackupConfig) error {
+	if backup.IntervalHours < 0 {
+		return fmt.Errorf("invalid backup.intervalHours: must not be negative")
+	}
+	if backup.Keep < 0 {
+		return fmt.Errorf("invalid backup.keep: must not be negative")
+	}
+	if backup.S3 != nil && backup.S3.Bucket == "" {
+		return fmt.Errorf("backup.s3.bucket is required")
+	}
+	return nil
+}
+
+func validateArtifactStorage(storage *ArtifactStorageConfig) error {
+	if storage == nil {
+		return nil
+	}
+	if storage.Provider != "s3" && storage.Provider != "gcs" {
+		return fmt.Errorf("invalid artifactStorage.provider %q: must be s3 or gcs", storage.Provider)
+	}
+	if storage.Bucket == "" {
+		return fmt.Errorf("artifactStorage.bucket is required")
+	}
+	if storage.ExpireDays < 0 {
+		return fmt.Errorf("invalid artifactStorage.expireDays: must not be negative")
+	}
+	if storage.URLExpiryMinutes < 0 || storage.URLExpiryMinutes > 7*24*60 {
+		return fmt.Errorf("invalid artifactStorage.urlExpiryMinutes: must be between 0 and 10080")
+	}
+	return nil
+}
+
+func validateEventExport(export *EventExportConfig) error {
+	if export == nil {
+		return nil
+	}
+	switch export.Sink {
+	case EventSinkFile:
+		if export.Path == "" {
+			return fmt.Errorf("eventExport.path is required for the file sink")
+		}
+	case EventSinkNATS, EventSinkKafka:
+		if u, err := url.Parse(export.URL); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid eventExport.url %q: must be a URL such as nats://localhost:4222 or http://localhost:8082", export.URL)
+		}
+		if export.Topic == "" || strings.ContainsAny(export.Topic, " \t\r\n") {
+			return fmt.Errorf("invalid eventExport.topic %q: must be a non-empty name without whitespace", export.Topic)
+		}
+	default:
+		return fmt.Errorf("invalid eventExport.sink %q: must be file, nats or kafka", export.Sink)
+	}
+	return nil
+}
+
+func validateTracing(tracing *TracingConfig) error {
+	if tracing == nil {
+		return nil
+	}
+	if tracing.Endpoint != "" {
+		if u, err := url.Parse(tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid tracing.endpoint %q: must be an http or https URL such as http://localhost:4318", tracing.Endpoint)
+		}
+	}
+	if tracing.SampleRatio < 0 || tracing.SampleRatio > 1 {
+		return fmt.Errorf("invalid tracing.sampleRatio %v: must be between 0 and 1", tracing.SampleRatio)
+	}
+	return nil
+}
+
+func validateRedaction(redaction RedactionConfig) error {
+	for i, pattern := range redaction.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redaction.patterns[%d] %q: %w", i, pattern, err)
+		}
+	}
+	if redaction.MinEntropy < 0 || redaction.MinEntropy > 8 {
+		return fmt.Errorf("invalid redaction.minEntropy %v: must be between 0 and 8 bits per character", redaction.MinEntropy)
+	}
+	return nil
+}
+
+func validateSharing(sharing SharingConfig) error {
+	if sharing.BaseURL != "" {
+		if u, err := url.Parse(sharing.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid sharing.baseUrl %q: must be an http or https URL", sharing.BaseURL)
+		}
+	}
+	if sharing.Secret != "" && len(sharing.Secret) < MinSharingSecretLength {
+		return fmt.Errorf("invalid sharing.secret: must be at least %d characters", MinSharingSecretLength)
+	}
+	return nil
+}
+
+func validateWebhooks(webhooks []WebhookConfig) error {
+	for i, webhook := range webhooks {
+		if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhooks[%d].url %q: must be an http or https URL", i, webhook.URL)
+		}
+		for _, event := range webhook.Events {
+			if event != WebhookResponseCompleted && event != WebhookResponseFailed {
+				return fmt.Errorf("invalid webhooks[%d] event %q: must be %s or %s", i, event, WebhookResponseCompleted, WebhookResponseFailed)
+			}
+		}
+		if webhook.MaxAttempts < 0 {
+			return fmt.Errorf("invalid webhooks[%d].maxAttempts %d: must not be negative", i, webhook.MaxAttempts)
+		}
+	}
+	return nil
+}
+
+func validateHTTPAuth(auth HTTPAuthConfig) error {
+	seen := make(map[string]bool)
+	for i, token := range auth.Tokens {
+		if token.Token == "" {
+			return fmt.Errorf("invalid httpAuth.tokens[%d]: token is required", i)
+		}
+		if seen[token.Token] {
+			return fmt.Errorf("invalid httpAuth.tokens[%d]: token is listed more than once", i)
+		}
+		seen[token.Token] = true
+		if token.Scope != "" && token.Scope != HTTPScopeRead && token.Scope != HTTPScopeFull {
+			return fmt.Errorf("invalid httpAuth.tokens[%d].scope %q: must be %s or %s", i, token.Scope, HTTPScopeRead, HTTPScopeFull)
+		}
+	}
+	if jwt := auth.JWT; jwt != nil && (jwt.Secret == "") == (jwt.PublicKeyFile == "") {
+		return fmt.Errorf("invalid httpAuth.jwt: exactly one of secret and publicKeyFile is required")
+	}
+	return nil
+}
+
+// validateMCPServers checks the tool patterns of the MCP servers.
+func validateMCPServers(servers map[string]MCPServer) error {
+	for name, server := range servers {
+		lists := map[string][]string{
+			"allowedTools": server.AllowedTools,
+			"deniedTools":  server.DeniedTools,
+			"autoApprove":  server.AutoApprove,
+		}
+		for field, patterns := range lists {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("invalid mcpServers.%s.%s pattern %q: %w", name, field, pattern, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// commandToolName matches the tool names every provider accepts
+var commandToolName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// validateCommandTools checks the names, commands and input schemas of the
+// command tools. Names clashing with built-in and MCP tools are reported when
+// the tools are loaded, since the configuration doesn't know them.
+func validateCommandTools(commandTools []CommandTool) error {
+	seen := make(map[string]bool)
+	for i, tool := range commandTools {
+		if !commandToolName.MatchString(tool.Name) {
+			return fmt.Errorf("invalid commandTools[%d].name %q: must be 1 to 64 letters, digits, _ or -", i, tool.Name)
+		}
+		if seen[tool.Name] {
+			return fmt.Errorf("commandTools: %s is defined more than once", tool.Name)
+		}
+		seen[tool.Name] = true
+		if strings.TrimSpace(tool.Description) == "" {
+			return fmt.Errorf("commandTools: %s has no description", tool.Name)
+		}
+		if strings.TrimSpace(tool.Command) == "" {
+			return fmt.Errorf("commandTools: %s has no command", tool.Name)
+		}
+		if tool.TimeoutSeconds < 0 || tool.TimeoutSeconds > MaxCommandToolTimeoutSeconds {
+			return fmt.Errorf("invalid commandTools %s timeoutSeconds %d: must be between 0 and %d", tool.Name, tool.TimeoutSeconds, MaxCommandToolTimeoutSeconds)
+		}
+		if err := validateInputSchema(tool.InputSchema); err != nil {
+			return fmt.Errorf("invalid commandTools %s inputSchema: %w", tool.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateInputSchema checks that schema describes an object whose required
+// properties are among its properties.
+func validateInputSchema(schema map[string]any) error {
+	if typ, ok := schema["type"]; ok && typ != "object" {
+		return fmt.Errorf("type must be object")
+	}
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok && schema["properties"] != nil {
+		return fmt.Errorf("properties must be an object")
+	}
+	if schema["required"] == nil {
+		return nil
+	}
+	required, ok := schema["required"].([]any)
+	if !ok {
+		return fmt.Errorf("required must be a list of property names")
+	}
+	for _, name := range required {
+		name, ok := name.(string)
+		if !ok {
+			return fmt.Errorf("required must be a list of property names")
+		}
+		if _, ok := properties[name]; !ok {
+			return fmt.Errorf("required property %q is not among the properties", name)
+		}
+	}
+	return nil
+}
+
+func validateOutputProfiles(profiles []OutputProfile) error {
+	seen := make(map[string]bool)
+	for _, profile := range profiles {
+		if strings.TrimSpace(profile.Name) == "" {
+			return fmt.Errorf("outputProfiles: profile name is required")
+		}
+		if seen[profile.Name] {
+			return fmt.Errorf("outputProfiles: %s is defined more than once", profile.Name)
+		}
+		seen[profile.Name] = true
+		if profile.MaxBytes <= 0 {
+			return fmt.Errorf("invalid outputProfiles %s maxBytes: must be positive", profile.Name)
+		}
+		if profile.Mode != OutputModeTruncate && profile.Mode != OutputModeSplit {
+			return fmt.Errorf("invalid outputProfiles %s mode %q: must be truncate or split", profile.Name, profile.Mode)
+		}
+	}
+	return nil
+}
+
+func validateReminders(reminders RemindersConfig) error {
+	seen := map[string]bool{ReminderPlanMode: true, ReminderContextRefresh: true, ReminderTokenBudget: true, ReminderResponseFormat: true}
+	for _, reminder := range reminders.Custom {
+		if strings.TrimSpace(reminder.Name) == "" {
+			return fmt.Errorf("reminders.custom: reminder name is required")
+		}
+		if seen[reminder.Name] {
+			return fmt.Errorf("reminders.custom: %s is defined more than once or is built in", reminder.Name)
+		}
+		seen[reminder.Name] = true
+		if (reminder.Text == "") == (reminder.File == "") {
+			return fmt.Errorf("invalid reminders.custom %s: set one of text and file", reminder.Name)
+		}
+	}
+	for _, name := range reminders.Default {
+		if !seen[name] || name == ReminderPlanMode || name == ReminderResponseFormat {
+			return fmt.Errorf("invalid reminders.default %q: no such reminder", name)
+		}
+	}
+	if reminders.TokenBudgetPercent < 0 || reminders.TokenBudgetPercent > 100 {
+		return fmt.Errorf("invalid reminders.tokenBudgetPercent: must be between 0 and 100")
+	}
+	return nil
+}
+
+func validateSandboxProfiles(cfg *Config) error {
+	seen := make(map[string]bool)
+	for _, profile := range cfg.SandboxProfiles {
+		if strings.TrimSpace(profile.Name) == "" {
+			return fmt.Errorf("sandboxProfiles: profile name is required")
+		}
+		if seen[profile.Name] {
+			return fmt.Errorf("sandboxProfiles: %s is defined more than once", profile.Name)
+		}
+		seen[profile.Name] = true
+		for _, dir := range slices.Concat(profile.ReadOnlyPaths, profile.HiddenPaths) {
+			if !filepath.IsAbs(dir) && !strings.HasPrefix(dir, "~/") {
+				return fmt.Errorf("invalid sandboxProfiles %s path %q: must be absolute or start with ~/", profile.Name, dir)
+			}
+		}
+		if profile.CPUPercent < 0 || profile.MemoryMB < 0 || profile.MaxProcesses < 0 || profile.TimeoutSeconds < 0 {
+			return fmt.Errorf("invalid sandboxProfiles %s: limits can't be negative", profile.Name)
+		}
+	}
+	if cfg.DefaultSandboxProfile != "" && !seen[cfg.DefaultSandboxProfile] {
+		return fmt.Errorf("invalid defaultSandboxProfile %q: no such sandbox profile", cfg.DefaultSandboxProfile)
+	}
+	return nil
+}
+
+// loadModelCatalog registers the models discovered by the last catalog
+// refresh. A catalog that can't be read leaves only the built-in models.
+func loadModelCatalog() {
+	if _, err := models.LoadCatalog(ModelCatalogPath()); err != nil {
+		logging.Warn("Failed to load model catalog, using built-in models", "error", err)
+	}
+}
+
+// ModelCatalogPath returns where the discovered model catalog is persisted.
+func ModelCatalogPath() string {
+	return filepath.Join(cfg.Data.Directory, models.CatalogFile)
+}
+
+// ModelCatalogDue reports whether the model catalog should be refreshed at
+// startup: refreshing is enabled, a provider can list its models and the
+// catalog is older than the refresh interval.
+func ModelCatalogDue() bool {
+	if cfg.ModelCatalog.DisableRefresh || len(modelCatalogAPIKeys()) == 0 {
+		return false
+	}
+	interval := time.Duration(cmp.Or(cfg.ModelCatalog.RefreshHours, 24)) * time.Hour
+	return time.Since(models.CatalogRefreshedAt()) > interval
+}
+
+// RefreshModelCatalog fetches the model lists of the providers with API keys
+// and registers the models they added. See models.RefreshCatalog.
+func RefreshModelCatalog(ctx context.Context) (models.Catalog, map[models.ModelProvider]error, error) {
+	apiKeys := modelCatalogAPIKeys()
+	if len(apiKeys) == 0 {
+		return models.Catalog{}, nil, fmt.Errorf("no API key set for a provider that lists its models %v", models.CatalogProviders)
+	}
+	return models.RefreshCatalog(ctx, ModelCatalogPath(), apiKeys)
+}
+
+// modelCatalogAPIKeys returns the API keys of the enabled providers that list
+// their models. OAuth sign-ins can't list models.
+func modelCatalogAPIKeys() map[models.ModelProvider]string {
+	apiKeys := make(map[models.ModelProvider]string)
+	for _, provider := range models.CatalogProviders {
+		providerCfg := cfg.Providers[provider]
+		if providerCfg.Disabled {
+			continue
+		}
+		if apiKey := cmp.Or(providerCfg.APIKey, getProviderAPIKey(provider)); apiKey != "" {
+			apiKeys[provider] = apiKey
+		}
+	}
+	return apiKeys
+}
+
+// loadOpenRouterCatalog registers the OpenRouter models of the last catalog
+// refresh. A catalog that can't be read leaves the built-in OpenRouter models.
+func loadOpenRouterCatalog() {
+	if err := models.LoadOpenRouterCatalog(OpenRouterCatalogPath()); err != nil {
+		logging.Warn("Failed to load OpenRouter model catalog, using built-in models", "error", err)
+	}
+}
+
+// OpenRouterCatalogPath returns where the OpenRouter catalog is persisted.
+func OpenRouterCatalogPath() string {
+	return filepath.Join(cfg.Data.Directory, models.OpenRouterCatalogFile)
+}
+
+// OpenRouterCatalogDue reports whether the OpenRouter catalog should be
+// refreshed: refreshing is enabled, OpenRouter has an API key and the catalog
+// is older than the refresh interval.
+func OpenRouterCatalogDue() bool {
+	if cfg.ModelCatalog.DisableRefresh || openRouterAPIKey() == "" {
+		return false
+	}
+	interval := time.Duration(cmp.Or(cfg.ModelCatalog.RefreshHours, 24)) * time.Hour
+	return time.Since(models.OpenRouterCatalogRefreshedAt()) > interval
+}
+
+// RefreshOpenRouterCatalog fetches OpenRouter's live model catalog and
+// registers its models. See models.RefreshOpenRouterCatalog.
+func RefreshOpenRouterCatalog(ctx context.Context) error {
+	apiKey := openRouterAPIKey()
+	if apiKey == "" {
+		return fmt.Errorf("no API key set for %s", models.ProviderOpenRouter)
+	}
+	return models.RefreshOpenRouterCatalog(ctx, OpenRouterCatalogPath(), apiKey)
+}
+
+// openRouterAPIKey returns OpenRouter's API key, empty when it is disabled.
+func openRouterAPIKey() string {
+	providerCfg, configured := cfg.Providers[models.ProviderOpenRouter]
+	if configured && providerCfg.Disabled {
+		return ""
+	}
+	return cmp.Or(providerCfg.APIKey, getProviderAPIKey(models.ProviderOpenRouter))
+}
+
 // getProviderAPIKey gets the API key for providers from environment variables
 func getProviderAPIKey(provider models.ModelProvider) string {
+	if name := APIKeyEnv(provider); name != "" {
+		return os.Getenv(name)
+	}
 	switch provider {
-	case models.ProviderAnthropic:
-		return os.Getenv("ANTHROPIC_API_KEY")
-	case models.ProviderOpenAI:
-		return os.Getenv("OPENAI_API_KEY")
-	case models.ProviderGemini:
-		return os.Getenv("GEMINI_API_KEY")
-	case models.ProviderGROQ:
-		return os.Getenv("GROQ_API_KEY")
-	case models.ProviderAzure:
-		return os.Getenv("AZURE_OPENAI_API_KEY")
-	case models.ProviderOpenRouter:
-		return os.Getenv("OPENROUTER_API_KEY")
 	case models.ProviderBedrock:
 		if hasAWSCredentials() {
 			return "aws-credentials-available"
@@ -699,8 +1941,10 @@ func updateCfgFile(updateCfg func(config *Config)) error {
 }
 
 // Get returns the current configuration.
-// It's safe to call this function multiple times.
+// It's safe to call this function multiple times, and while Reload replaces it.
 func Get() *Config {
+	cfgMutex.RLock()
+	defer cfgMutex.RUnlock()
 	return cfg
 }
 
@@ -711,10 +1955,11 @@ func GetEmbeddedPrompts() embed.FS {
 
 // LaunchDirectory returns the current launch directory from the configuration.
 func LaunchDirectory() (string, error) {
-	if cfg == nil {
+	c := Get()
+	if c == nil {
 		return "", fmt.Errorf("config not loaded")
 	}
-	return cfg.WorkingDir, nil
+	return c.WorkingDir, nil
 }
 
 // PromptsDirectory returns the prompts directory from the configuration.
@@ -730,7 +1975,7 @@ func UpdateAgentModel(agentName AgentName, modelID models.ModelID) error {
 		return fmt.Errorf("config not loaded")
 	}
 
-	model, ok := models.SupportedModels[modelID]
+	model, ok := models.Lookup(modelID)
 	if !ok {
 		return fmt.Errorf("model %s not supported", modelID)
 	}
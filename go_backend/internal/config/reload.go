@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"mix/internal/logging"
+
+	"github.com/spf13/viper"
+)
+
+// restartSections are the config sections read once at startup. Reloading
+// changes them in the configuration, but the running server keeps the old
+// values until it restarts.
+var restartSections = map[string]bool{
+	"promptsDir":       true,
+	"analyticsEnabled": true,
+	"network":          true,
+	"chaos":            true,
+	"backup":           true,
+	"render":           true,
+	"artifactStorage":  true,
+	"eventExport":      true,
+	"webhooks":         true,
+	"localesDir":       true,
+	"probeProviders":   true,
+}
+
+// Change describes a reload by the top-level config sections, named as in
+// .mix.json, that differ from the previous configuration.
+type Change struct {
+	Changed []string `json:"changed"`
+	// RestartRequired are the changed sections that only take effect after a
+	// restart
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// Has reports whether a section changed.
+func (c Change) Has(section string) bool {
+	return slices.Contains(c.Changed, section)
+}
+
+// Reload reads the config files again and, if the result validates, makes it
+// the current configuration. An invalid configuration is rejected and the
+// current one stays active. The working directory, data directory, debug and
+// skip-permissions settings come from the command line or locate the
+// database, so they are kept. Callers serialize reloads, as App.ReloadConfig
+// does, so each compares against the configuration the previous one installed.
+func Reload() (Change, error) {
+	cfgMutex.RLock()
+	current := cfg
+	cfgMutex.RUnlock()
+	if current == nil {
+		return Change{}, fmt.Errorf("config not loaded")
+	}
+
+	next, err := readConfigFiles(viper.New(), current.WorkingDir, current.Debug, current.SkipPermissions)
+	if err != nil {
+		return Change{}, err
+	}
+	next.Data = current.Data
+	next.Debug = current.Debug
+
+	applyStoredAPIKeys(next)
+
+	if err := validate(next); err != nil {
+		return Change{}, fmt.Errorf("config validation failed: %w", err)
+	}
+	if err := requireAgents(next); err != nil {
+		return Change{}, err
+	}
+
+	cfgMutex.Lock()
+	change := diffConfig(current, next)
+	cfg = next
+	cfgMutex.Unlock()
+
+	if len(change.RestartRequired) > 0 {
+		logging.Warn("Reloaded config sections take effect after a restart", "sections", strings.Join(change.RestartRequired, ", "))
+	}
+	logging.Info("Configuration reloaded", "changed", strings.Join(change.Changed, ", "))
+	return change, nil
+}
+
+// diffConfig compares the exported fields of two configurations.
+func diffConfig(old, next *Config) Change {
+	change := Change{Changed: []string{}, RestartRequired: []string{}}
+	oldValue, nextValue := reflect.ValueOf(*old), reflect.ValueOf(*next)
+	for i := range oldValue.NumField() {
+		field := oldValue.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		change.Changed = append(change.Changed, name)
+		if restartSections[name] {
+			change.RestartRequired = append(change.RestartRequired, name)
+		}
+	}
+	return change
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mix/internal/llm/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		changed []string
+		restart []string
+	}{
+		{
+			name:    "unchanged",
+			change:  func(*Config) {},
+			changed: []string{},
+			restart: []string{},
+		},
+		{
+			name:    "live section",
+			change:  func(c *Config) { c.ContextPaths = []string{"MIX.md"} },
+			changed: []string{"contextPaths"},
+			restart: []string{},
+		},
+		{
+			name: "restart sections",
+			change: func(c *Config) {
+				c.PromptsDir = "/prompts"
+				c.ProbeProviders = true
+				c.MaxSessionCost = 5
+			},
+			changed: []string{"promptsDir", "maxSessionCost", "probeProviders"},
+			restart: []string{"promptsDir", "probeProviders"},
+		},
+		{
+			name:    "unexported fields are ignored",
+			change:  func(c *Config) { c.configuredAPIKeys = map[models.ModelProvider]string{models.ProviderOpenAI: "key"} },
+			changed: []string{},
+			restart: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := &Config{ContextPaths: []string{"AGENTS.md"}}
+			next := *old
+			tt.change(&next)
+
+			change := diffConfig(old, &next)
+			assert.ElementsMatch(t, tt.changed, change.Changed)
+			assert.ElementsMatch(t, tt.restart, change.RestartRequired)
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	previous := cfg
+	cfg = nil
+	t.Cleanup(func() { cfg = previous })
+
+	workDir := t.TempDir()
+	_, err := Load(workDir, false, false)
+	require.NoError(t, err)
+	projectFile := filepath.Join(workDir, ".mix.json")
+
+	tests := []struct {
+		name    string
+		project string
+		changed []string
+		restart []string
+		err     bool
+	}{
+		{
+			name:    "nothing changed",
+			changed: []string{},
+			restart: []string{},
+		},
+		{
+			name:    "live section",
+			project: `{"contextPaths": ["NOTES.md"]}`,
+			changed: []string{"contextPaths"},
+			restart: []string{},
+		},
+		{
+			name:    "restart section",
+			project: `{"contextPaths": ["NOTES.md"], "probeProviders": true}`,
+			changed: []string{"probeProviders"},
+			restart: []string{"probeProviders"},
+		},
+		{
+			name:    "invalid config keeps the current one",
+			project: `{"contextPaths": 5}`,
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.project != "" {
+				require.NoError(t, os.WriteFile(projectFile, []byte(tt.project), 0o644))
+			}
+			before := Get()
+
+			change, err := Reload()
+			if tt.err {
+				require.Error(t, err)
+				assert.Same(t, before, Get())
+				return
+			}
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.changed, change.Changed)
+			assert.ElementsMatch(t, tt.restart, change.RestartRequired)
+			assert.Equal(t, before.Data, Get().Data)
+			assert.Equal(t, workDir, Get().WorkingDir)
+		})
+	}
+
+	t.Run("read while reloading", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(projectFile, []byte(`{"contextPaths": ["NOTES.md"]}`), 0o644))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 20 {
+				_, err := Reload()
+				assert.NoError(t, err)
+			}
+		}()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				assert.NotNil(t, Get())
+			}
+		}
+	})
+}
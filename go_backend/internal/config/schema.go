@@ -0,0 +1,375 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"mix/internal/jsonschema"
+	"mix/internal/llm/models"
+)
+
+//go:generate go run ./schemagen mix-schema.json ../../mix-schema.json
+
+// schemaJSON is the JSON Schema of .mix.json generated from Config by
+// GenerateSchema. Config files are validated against it when they are read.
+//
+//go:embed mix-schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON Schema of .mix.json, for editors to complete and
+// check config files with.
+func Schema() []byte {
+	return slices.Clone(schemaJSON)
+}
+
+var loadSchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	return jsonschema.Parse(schemaJSON)
+})
+
+// ValidateJSON checks a config file against the embedded schema. Its error
+// lists every problem by path, such as "mcpServers.foo.type must be one of
+// stdio|sse", rather than leaving them to be ignored or coerced when the
+// file is unmarshaled.
+func ValidateJSON(data []byte) error {
+	schema, err := loadSchema()
+	if err != nil {
+		return fmt.Errorf("embedded config schema: %w", err)
+	}
+	return schema.Validate(data)
+}
+
+// validateConfigFile checks the config file at path, if any, against the
+// schema.
+func validateConfigFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := ValidateJSON(data); err != nil {
+		return fmt.Errorf("invalid config file %s:\n%w", path, err)
+	}
+	return nil
+}
+
+// schemaDoc annotates a field, keyed by its type's name and JSON name as in
+// "Agent.model", or a struct type, keyed by its name. Enums of optional
+// fields list "" when it selects the default.
+type schemaDoc struct {
+	description string
+	enum        []any
+	// keys lists the names allowed in a map
+	keys     []any
+	examples []any
+	minimum  *float64
+	maximum  *float64
+	def      any
+	required bool
+}
+
+func bound(v float64) *float64 { return &v }
+
+var schemaDocs = map[string]schemaDoc{
+	"Config":                          {description: "Configuration schema for the Mix application"},
+	"Config.$schema":                  {description: "JSON Schema the file is validated against in editors"},
+	"Config.data":                     {description: "Storage configuration"},
+	"Config.wd":                       {description: "Working directory for the application"},
+	"Config.promptsDir":               {description: "Directory of prompt overrides, relative to the working directory or starting with ~/ (default: .mix/prompts)"},
+	"Config.promptVars":               {description: "Variables substituted into prompt templates"},
+	"Config.mcpServers":               {description: "Model Control Protocol server configurations"},
+	"Config.commandTools":             {description: "Executables run as tools, passed each call's input on stdin"},
+	"Config.providers":                {description: "LLM provider configurations", keys: providerNames()},
+	"Config.agents":                   {description: "Agent configurations", keys: []any{AgentMain, AgentSub}},
+	"Config.debug":                    {description: "Enable debug mode", def: false},
+	"Config.contextPaths":             {description: "Context files, such as MIX.md, included in the system prompt", def: defaultContextPaths},
+	"Config.shell":                    {description: "Shell used by the bash tool"},
+	"Config.skipPermissions":          {description: "Run every tool without asking for permission"},
+	"Config.analyticsEnabled":         {description: "Send anonymous usage analytics", def: true},
+	"Config.compactTools":             {description: "Abbreviate the descriptions of tools left unused for a number of turns, chosen once per session so requests keep a cacheable prefix"},
+	"Config.network":                  {description: "Egress policy for tools that reach the network"},
+	"Config.maxSessionCost":           {description: "Stop a session's generation once its cost in USD exceeds this value", minimum: bound(0)},
+	"Config.chaos":                    {description: "Fault injection to exercise retry and recovery paths"},
+	"Config.toolOutput":               {description: "Size limit of tool results returned to the model"},
+	"Config.grammar":                  {description: "Grammar checker settings"},
+	"Config.toolCosts":                {description: "USD price per call of tools backed by paid APIs, by tool name"},
+	"Config.backup":                   {description: "Scheduled snapshots of the database and stored credentials"},
+	"Config.checkpoints":              {description: "Snapshots of the files agent turns change, so they can be undone"},
+	"Config.reminders":                {description: "<system-reminder> blocks appended to user messages"},
+	"Config.memory":                   {description: "Long-term memories saved with memory_write"},
+	"Config.probeProviders":           {description: "Check at startup that every model the agents use can be reached"},
+	"Config.render":                   {description: "Server-side rendering of mermaid, graphviz and LaTeX blocks"},
+	"Config.thumbnails":               {description: "Size and age limits of the asset server's thumbnail caches"},
+	"Config.fileWatch":                {description: "Live file change events for the working directories of sessions with open streams"},
+	"Config.artifactStorage":          {description: "Bucket generated files are uploaded to"},
+	"Config.outputProfiles":           {description: "Response size policies sessions can select"},
+	"Config.sandboxProfiles":          {description: "Confinements for bash commands that sessions can select"},
+	"Config.defaultSandboxProfile":    {description: "Sandbox profile of sessions that don't select one"},
+	"Config.locale":                   {description: "Language of command responses, e.g. \"de\""},
+	"Config.localesDir":               {description: "Directory of extra <locale>.json message catalogs"},
+	"Config.resumeInterrupted":        {description: "Regenerate, at startup, the responses a crashed server left unfinished"},
+	"Config.eventExport":              {description: "Analytics pipeline every agent event is streamed to"},
+	"Config.tracing":                  {description: "OpenTelemetry spans of agent runs, model requests and tool calls"},
+	"Config.modelCatalog":             {description: "Catalog of models discovered from the providers"},
+	"Config.webhooks":                 {description: "URLs notified when agent responses complete or fail"},
+	"Config.httpAuth":                 {description: "Bearer tokens HTTP server clients must present"},
+	"Config.redaction":                {description: "Redaction of API keys, tokens and other secrets from logs, tool results and transcripts"},
+	"Config.sharing":                  {description: "Read-only session links created with sessions.share"},
+	"Config.permissionTimeoutSeconds": {description: "Seconds a permission request waits for an answer before it is denied (default: 30)", minimum: bound(0)},
+	"Config.questionTimeoutSeconds":   {description: "Seconds an ask_user question waits for an answer before the turn goes on without one (default: 300)", minimum: bound(0)},
+	"Config.dryRun":                   {description: "Make every turn a dry run: tools describe what they would change instead of changing it"},
+
+	"Data.directory": {description: "Directory where application data is stored", def: defaultDataDirectory},
+
+	"MCPServer":              {description: "MCP server configuration"},
+	"MCPServer.command":      {description: "Command starting a stdio server"},
+	"MCPServer.env":          {description: "Environment of a stdio server, as KEY=value"},
+	"MCPServer.args":         {description: "Arguments of the command"},
+	"MCPServer.type":         {description: "Transport of the server", enum: []any{"", MCPStdio, MCPSse}, def: MCPStdio},
+	"MCPServer.url":          {description: "URL of an sse server"},
+	"MCPServer.headers":      {description: "HTTP headers sent to an sse server"},
+	"MCPServer.allowedTools": {description: "Tools to use, as path.Match patterns such as \"read_*\"; all when empty"},
+	"MCPServer.deniedTools":  {description: "Tools to leave out, as path.Match patterns; wins over allowedTools"},
+	"MCPServer.autoApprove":  {description: "Tools, as path.Match patterns, that run without asking for permission"},
+
+	"CommandTool":                {description: "Executable run as a tool: the call's JSON input is written to its stdin and its result read from its stdout"},
+	"CommandTool.name":           {description: "Name of the tool, of letters, digits, _ and -", required: true},
+	"CommandTool.description":    {description: "Description telling the model what the tool does and when to call it", required: true},
+	"CommandTool.command":        {description: "Executable to run, found on the PATH unless a path", required: true},
+	"CommandTool.args":           {description: "Arguments of the command"},
+	"CommandTool.env":            {description: "Environment of the command, as KEY=value, added to the server's and the session's"},
+	"CommandTool.inputSchema":    {description: "JSON schema of the tool's input, an object schema with properties and required"},
+	"CommandTool.timeoutSeconds": {description: "Seconds a call may run before the command is killed", minimum: bound(0), maximum: bound(MaxCommandToolTimeoutSeconds), def: DefaultCommandToolTimeoutSeconds},
+	"CommandTool.autoApprove":    {description: "Run the tool without asking for permission"},
+
+	"Provider":                     {description: "Provider configuration"},
+	"Provider.apiKey":              {description: "API key; anthropic and openai can sign in with mix auth instead"},
+	"Provider.disabled":            {description: "Disable the provider"},
+	"Provider.region":              {description: "Bedrock only: AWS region"},
+	"Provider.profile":             {description: "Bedrock only: AWS profile"},
+	"Provider.inferenceProfileArn": {description: "Bedrock only: inference profile used instead of the model ID"},
+	"Provider.endpoint":            {description: "Azure only: resource endpoint (default: AZURE_OPENAI_ENDPOINT)"},
+	"Provider.apiVersion":          {description: "Azure only: API version (default: AZURE_OPENAI_API_VERSION)"},
+	"Provider.deployments":         {description: "Azure only: deployments serving models, by default named after the model"},
+	"Provider.entraId":             {description: "Azure only: authenticate with Entra ID instead of an API key"},
+	"Provider.project":             {description: "Vertex AI only: Google Cloud project (default: VERTEXAI_PROJECT or GOOGLE_CLOUD_PROJECT)"},
+	"Provider.location":            {description: "Vertex AI only: region (default: us-central1)"},
+
+	"Agent":                 {description: "Agent configuration"},
+	"Agent.model":           {description: "Model ID for the agent", examples: modelIDs(), required: true},
+	"Agent.maxTokens":       {description: "Maximum tokens for the agent", minimum: bound(1)},
+	"Agent.reasoningEffort": {description: "Reasoning effort for models that support it (OpenAI, Anthropic)", enum: []any{"", "low", "medium", "high"}},
+	"Agent.fallback":        {description: "Model IDs to fall back to, in order, when the model's provider is rate limited or failing after retries"},
+	"Agent.cache":           {description: "Anthropic prompt caching breakpoints, at most 4 in total"},
+	"Agent.thinking":        {description: "Extended thinking budget of Anthropic models that can reason"},
+	"Agent.history":         {description: "How the history sent with each request is shortened, on top of summaries"},
+
+	"Cache.disabled": {description: "Disable prompt caching for this agent"},
+	"Cache.system":   {description: "Cache the system prompt", def: true},
+	"Cache.tools":    {description: "Cache the tool definitions", def: true},
+	"Cache.messages": {description: "Number of most recent messages to cache", minimum: bound(0), def: DefaultCachedMessages},
+
+	"History.strategy":       {description: "window keeps the last turns, middle-drop the first and last turns, prune-tool-results replaces older tool results with placeholders", enum: []any{HistoryWindow, HistoryMiddleDrop, HistoryPruneToolResults}, required: true},
+	"History.keepTurns":      {description: "Recent turns sent verbatim", minimum: bound(0), def: DefaultHistoryKeepTurns},
+	"History.keepFirstTurns": {description: "Leading turns middle-drop keeps", minimum: bound(0), def: DefaultHistoryKeepFirstTurns},
+	"History.contextPercent": {description: "Apply the strategy only once the last request used this percentage of the model's context window; 0 always applies it", minimum: bound(0), maximum: bound(100)},
+
+	"Thinking.mode":      {description: "How requests are budgeted", enum: []any{"", ThinkingPhrases, ThinkingFixed, ThinkingAdaptive, ThinkingOff}, def: ThinkingPhrases},
+	"Thinking.budget":    {description: "Token budget of the fixed and adaptive modes", minimum: bound(MinThinkingBudget), def: DefaultThinkingBudget},
+	"Thinking.maxBudget": {description: "Highest adaptive budget", minimum: bound(MinThinkingBudget), def: MaxThinkingBudget},
+
+	"CompactToolsConfig.unusedTurns": {description: "Assistant turns a tool goes unused before its description is abbreviated", minimum: bound(0), def: 3},
+
+	"ChaosConfig.providerErrorRate":  {minimum: bound(0), maximum: bound(1)},
+	"ChaosConfig.streamTruncateRate": {minimum: bound(0), maximum: bound(1)},
+	"ChaosConfig.toolTimeoutRate":    {minimum: bound(0), maximum: bound(1)},
+	"ChaosConfig.dbLockRate":         {minimum: bound(0), maximum: bound(1)},
+
+	"ToolOutputConfig.maxBytes": {minimum: bound(0)},
+
+	"RenderConfig.format": {enum: []any{"", "svg", "png"}, def: "svg"},
+
+	"ThumbnailsConfig.maxCacheMb":  {minimum: bound(0), def: 500},
+	"ThumbnailsConfig.maxAgeHours": {minimum: bound(0), def: 168},
+
+	"FileWatchConfig.ignore":     {description: "gitignore patterns of paths whose changes aren't sent, on top of the working directory's .gitignore"},
+	"FileWatchConfig.debounceMs": {description: "Milliseconds of changes grouped into one event", minimum: bound(0), def: 250},
+
+	"BackupConfig.intervalHours": {minimum: bound(0)},
+	"BackupConfig.keep":          {minimum: bound(0)},
+	"S3BackupConfig.bucket":      {required: true},
+
+	"CheckpointsConfig.maxFileSizeMb": {minimum: bound(0), def: 50},
+
+	"MemoryConfig.maxEntries":     {description: "Memories kept for the user and for each workspace", minimum: bound(0), def: 100},
+	"MemoryConfig.maxPromptChars": {description: "Characters of memories in a new session's system prompt", minimum: bound(0), def: 6000},
+
+	"RemindersConfig.tokenBudgetPercent": {minimum: bound(0), maximum: bound(100), def: 80},
+	"Reminder.name":                      {required: true},
+
+	"ArtifactStorageConfig.provider":         {enum: []any{"s3", "gcs"}, required: true},
+	"ArtifactStorageConfig.bucket":           {required: true},
+	"ArtifactStorageConfig.expireDays":       {minimum: bound(0)},
+	"ArtifactStorageConfig.urlExpiryMinutes": {minimum: bound(0), maximum: bound(7 * 24 * 60)},
+
+	"ModelCatalogConfig.refreshHours": {minimum: bound(0), def: 24},
+
+	"EventExportConfig.sink": {enum: []any{EventSinkFile, EventSinkNATS, EventSinkKafka}, required: true},
+
+	"TracingConfig.endpoint":    {description: "OTLP/HTTP collector URL, e.g. \"http://localhost:4318\"; /v1/traces is added when it has no path"},
+	"TracingConfig.headers":     {description: "HTTP headers sent to the collector, e.g. for authentication"},
+	"TracingConfig.serviceName": {description: "service.name of the exported spans", def: "mix"},
+	"TracingConfig.sampleRatio": {description: "Fraction of agent runs traced", minimum: bound(0), maximum: bound(1), def: 1},
+
+	"RedactionConfig.disabled":   {description: "Log, store and export secrets unredacted"},
+	"RedactionConfig.strict":     {description: "Withhold tool results containing credentials from the model instead of redacting them"},
+	"RedactionConfig.patterns":   {description: "Regular expressions of extra secrets; with a capture group, only the first group is redacted"},
+	"RedactionConfig.minEntropy": {description: "Also redact words of 20 or more letters and digits with at least this Shannon entropy in bits per character, e.g. 4.5; 0 disables", minimum: bound(0), maximum: bound(8)},
+
+	"SharingConfig.baseUrl": {description: "Public URL of the server, e.g. \"https://mix.example.com\", making share links absolute"},
+	"SharingConfig.secret":  {description: "Key of at least 32 characters signing share tokens; unset, a key generated into the data directory is used. Changing it invalidates existing links"},
+
+	"WebhookConfig.url":         {required: true},
+	"WebhookConfig.events":      {enum: []any{WebhookResponseCompleted, WebhookResponseFailed}},
+	"WebhookConfig.maxAttempts": {minimum: bound(0)},
+
+	"HTTPToken.token": {required: true},
+	"HTTPToken.scope": {enum: []any{"", HTTPScopeRead, HTTPScopeFull}},
+
+	"OutputProfile.name":     {required: true},
+	"OutputProfile.maxBytes": {minimum: bound(1), required: true},
+	"OutputProfile.mode":     {enum: []any{OutputModeTruncate, OutputModeSplit}, required: true},
+
+	"SandboxProfile.name":           {required: true},
+	"SandboxProfile.cpuPercent":     {minimum: bound(0)},
+	"SandboxProfile.memoryMb":       {minimum: bound(0)},
+	"SandboxProfile.maxProcesses":   {minimum: bound(0)},
+	"SandboxProfile.timeoutSeconds": {minimum: bound(0)},
+}
+
+// providerNames returns the providers that can be configured.
+func providerNames() []any {
+	var names []any
+	for _, provider := range slices.Sorted(maps.Keys(models.ProviderPopularity)) {
+		names = append(names, provider)
+	}
+	return append(names, models.ProviderXAI, models.ProviderLocal)
+}
+
+// modelIDs returns the built-in model IDs. They are only examples since
+// catalogs and local servers add models at runtime.
+func modelIDs() []any {
+	var ids []any
+	for _, id := range slices.Sorted(maps.Keys(models.Supported())) {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// GenerateSchema returns the JSON Schema of Config, as written to
+// mix-schema.json by go generate. Fields are named by their JSON tags and
+// annotated from schemaDocs; objects don't allow properties Config doesn't
+// have.
+func GenerateSchema() ([]byte, error) {
+	root := schemaFor(reflect.TypeOf(Config{}))
+	root.Schema = "http://json-schema.org/draft-07/schema#"
+	root.Title = "Mix Configuration"
+	// Config files point editors at the schema with a $schema property
+	root.Properties["$schema"] = &jsonschema.Schema{Type: jsonschema.Types{"string"}}
+	annotate(root.Properties["$schema"], schemaDocs["Config.$schema"])
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaFor describes values of type t. Slices, maps and pointers may also be
+// null, as they are when nil.
+func schemaFor(t reflect.Type) *jsonschema.Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+	types := func(typ string) jsonschema.Types {
+		if nullable {
+			return jsonschema.Types{typ, "null"}
+		}
+		return jsonschema.Types{typ}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonschema.Schema{Type: types("boolean")}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonschema.Schema{Type: types("integer")}
+	case reflect.Float32, reflect.Float64:
+		return &jsonschema.Schema{Type: types("number")}
+	case reflect.String:
+		return &jsonschema.Schema{Type: types("string")}
+	case reflect.Slice, reflect.Array:
+		nullable = nullable || t.Kind() == reflect.Slice
+		return &jsonschema.Schema{Type: types("array"), Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		nullable = true
+		return &jsonschema.Schema{Type: types("object"), AdditionalProperties: &jsonschema.Additional{Schema: schemaFor(t.Elem())}}
+	case reflect.Interface:
+		// Any JSON value, such as the values of a JSON schema
+		return &jsonschema.Schema{}
+	case reflect.Struct:
+		s := &jsonschema.Schema{
+			Type:                 types("object"),
+			Description:          schemaDocs[t.Name()].description,
+			Properties:           make(map[string]*jsonschema.Schema),
+			AdditionalProperties: &jsonschema.Additional{},
+		}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := schemaFor(field.Type)
+			doc := schemaDocs[t.Name()+"."+name]
+			annotate(property, doc)
+			if doc.required {
+				s.Required = append(s.Required, name)
+			}
+			s.Properties[name] = property
+		}
+		return s
+	}
+	panic(fmt.Sprintf("config: no JSON schema for %s", t))
+}
+
+// annotate applies doc to the schema of a field. Enums of list fields apply
+// to their items and key lists of maps to their property names.
+func annotate(s *jsonschema.Schema, doc schemaDoc) {
+	if doc.description != "" {
+		s.Description = doc.description
+	}
+	target := s
+	if s.Items != nil {
+		target = s.Items
+	}
+	target.Enum = doc.enum
+	target.Examples = doc.examples
+	target.Minimum = doc.minimum
+	target.Maximum = doc.maximum
+	if doc.keys != nil {
+		s.PropertyNames = &jsonschema.Schema{Enum: doc.keys}
+	}
+	s.Default = doc.def
+}
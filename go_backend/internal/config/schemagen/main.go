@@ -0,0 +1,29 @@
+// Command schemagen writes the JSON Schema of .mix.json, generated from the
+// config structs, to each path given. It is run by go generate in
+// internal/config.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"mix/internal/config"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: schemagen <output>...")
+		os.Exit(2)
+	}
+	schema, err := config.GenerateSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, path := range os.Args[1:] {
+		if err := os.WriteFile(path, schema, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
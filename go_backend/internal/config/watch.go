@@ -0,0 +1,104 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mix/internal/logging"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// watchDebounce groups the bursts of events editors produce on save
+const watchDebounce = 250 * time.Millisecond
+
+// Watch calls onChange whenever the global or the project .mix.json changes,
+// until ctx is cancelled. The directories holding them are watched rather than
+// the files, since editors often save by replacing the file.
+func Watch(ctx context.Context, onChange func()) error {
+	if cfg == nil {
+		return fmt.Errorf("config not loaded")
+	}
+	files, err := configFiles()
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool, len(files))
+	for file := range files {
+		dir := filepath.Dir(file)
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		watched[dir] = true
+	}
+
+	logging.Info("Watching config files for changes")
+
+	go func() {
+		defer logging.RecoverPanic("config-watcher", nil)
+		defer watcher.Close()
+
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !files[filepath.Clean(event.Name)] || event.Op == fsnotify.Chmod {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDebounce, func() {
+					logging.Info("Config file changed, reloading", "file", event.Name)
+					onChange()
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.Warn("Config watcher error", "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// configFiles returns the paths of the global and the project config file,
+// whether or not they exist.
+func configFiles() (map[string]bool, error) {
+	global := viper.ConfigFileUsed()
+	if global == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		global = filepath.Join(homeDir, fmt.Sprintf(".%s.json", appName))
+	}
+	local := filepath.Join(cfg.WorkingDir, fmt.Sprintf(".%s.json", appName))
+	return map[string]bool{
+		filepath.Clean(global): true,
+		filepath.Clean(local):  true,
+	}, nil
+}
@@ -3,29 +3,102 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 
+	"github.com/ncruces/go-sqlite3"
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
 
 	"mix/internal/config"
 	"mix/internal/logging"
-
-	"github.com/pressly/goose/v3"
 )
 
 const (
 	// Database operation timeouts
 	DBConnectionTimeout = 30 * time.Second
 	DBPingTimeout       = 10 * time.Second
-	DBPragmaTimeout     = 10 * time.Second
 	DBMigrationTimeout  = 5 * time.Minute
+
+	// DBBusyTimeout is how long a statement waits for another process's
+	// lock before failing with SQLITE_BUSY
+	DBBusyTimeout = 10 * time.Second
 )
 
-func Connect(ctx context.Context) (*sql.DB, error) {
+// DB is the database, opened as a single writer connection and a pool of
+// read-only connections. Writers queue for the one connection in Go instead
+// of failing with SQLITE_BUSY, while WAL lets readers run alongside them.
+// The embedded writer serves everything but SELECT statements, including
+// transactions and migrations.
+type DB struct {
+	*sql.DB
+	Reader *sql.DB
+}
+
+// QueryContext runs SELECT statements on the reader pool.
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if isRead(query) {
+		return d.Reader.QueryContext(ctx, query, args...)
+	}
+	return d.DB.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs SELECT statements on the reader pool.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if isRead(query) {
+		return d.Reader.QueryRowContext(ctx, query, args...)
+	}
+	return d.DB.QueryRowContext(ctx, query, args...)
+}
+
+// Close closes both pools.
+func (d *DB) Close() error {
+	return errors.Join(d.Reader.Close(), d.DB.Close())
+}
+
+// isRead reports whether query is a SELECT, after its "-- name:" header.
+// Statements with RETURNING write and stay on the writer.
+func isRead(query string) bool {
+	for strings.HasPrefix(query, "--") {
+		_, query, _ = strings.Cut(query, "\n")
+		query = strings.TrimSpace(query)
+	}
+	query = strings.TrimSpace(query)
+	return len(query) >= 6 && strings.EqualFold(query[:6], "SELECT")
+}
+
+// Connect opens the database and brings its schema up to date, backing it
+// up first when migrations are pending. It refuses databases migrated by a
+// newer mix.
+func Connect(ctx context.Context) (*DB, error) {
+	db, err := Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationCtx, cancel := context.WithTimeout(ctx, DBMigrationTimeout)
+	defer cancel()
+	if _, err := Migrate(migrationCtx, db.DB, BackupDir()); err != nil {
+		logging.Error("Failed to apply migrations", "error", err)
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// BackupDir is where pre-migration snapshots are written.
+func BackupDir() string {
+	return filepath.Join(config.Get().Data.Directory, "backups")
+}
+
+// Open opens the database and sets its pragmas without touching the schema.
+func Open(ctx context.Context) (*DB, error) {
 	dataDir := config.Get().Data.Directory
 	if dataDir == "" {
 		return nil, fmt.Errorf("data.dir is not set")
@@ -34,68 +107,61 @@ func Connect(ctx context.Context) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 	dbPath := filepath.Join(dataDir, "mix.db")
-	// Open the SQLite database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
 
-	// Verify connection with timeout
-	pingCtx, cancel := context.WithTimeout(ctx, DBPingTimeout)
-	defer cancel()
-	if err = db.PingContext(pingCtx); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	// Compiling SQLite happens once, before the first connection, and can take
+	// longer than the ping timeout on a slow or instrumented build
+	if err := sqlite3.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize SQLite: %w", err)
 	}
 
-	// Set pragmas for better performance
+	// Pragmas are per connection, so they go in the DSN to apply to every
+	// connection the pools open
 	pragmas := []string{
-		"PRAGMA foreign_keys = ON;",
-		"PRAGMA journal_mode = WAL;",
-		"PRAGMA page_size = 4096;",
-		"PRAGMA cache_size = -8000;",
-		"PRAGMA synchronous = NORMAL;",
+		fmt.Sprintf("busy_timeout(%d)", DBBusyTimeout.Milliseconds()),
+		"foreign_keys(1)",
+		"journal_mode(WAL)",
+		"synchronous(NORMAL)",
+		"cache_size(-8000)",
+	}
+	writer, err := openPool(ctx, dbPath, "rwc", pragmas)
+	if err != nil {
+		return nil, err
 	}
+	writer.SetMaxOpenConns(1)
 
-	for _, pragma := range pragmas {
-		pragmaCtx, cancel := context.WithTimeout(ctx, DBPragmaTimeout)
-		if _, err = db.ExecContext(pragmaCtx, pragma); err != nil {
-			logging.Error("Failed to set pragma", pragma, err)
-		} else {
-			logging.Debug("Set pragma", "pragma", pragma)
-		}
-		cancel()
+	// The writer has created the database and its WAL, which read-only
+	// connections can't do
+	reader, err := openPool(ctx, dbPath, "ro", pragmas[:2])
+	if err != nil {
+		writer.Close()
+		return nil, err
 	}
+	reader.SetMaxOpenConns(max(4, runtime.NumCPU()))
 
-	goose.SetBaseFS(FS)
+	return &DB{DB: writer, Reader: reader}, nil
+}
 
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		logging.Error("Failed to set dialect", "error", err)
-		return nil, fmt.Errorf("failed to set dialect: %w", err)
+func openPool(ctx context.Context, dbPath, mode string, pragmas []string) (*sql.DB, error) {
+	query := url.Values{"mode": {mode}, "_pragma": pragmas}
+	db, err := sql.Open("sqlite3", "file:"+(&url.URL{Path: dbPath}).EscapedPath()+"?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	migrationCtx, cancel := context.WithTimeout(ctx, DBMigrationTimeout)
+	// Verify connection with timeout
+	pingCtx, cancel := context.WithTimeout(ctx, DBPingTimeout)
 	defer cancel()
-	if err := goose.UpContext(migrationCtx, db, "migrations"); err != nil {
-		logging.Error("Failed to apply migrations", "error", err)
-		return nil, fmt.Errorf("failed to apply migrations: %w", err)
+	if err = db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 	return db, nil
 }
 
 // SetupTestDatabase applies migrations to a test database connection
 func SetupTestDatabase(ctx context.Context, db *sql.DB) error {
-	goose.SetBaseFS(FS)
-
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set dialect: %w", err)
-	}
-
 	migrationCtx, cancel := context.WithTimeout(ctx, DBMigrationTimeout)
 	defer cancel()
-	if err := goose.UpContext(migrationCtx, db, "migrations"); err != nil {
-		return fmt.Errorf("failed to apply migrations: %w", err)
-	}
-
-	return nil
+	_, err := Migrate(migrationCtx, db, "")
+	return err
 }
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"mix/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected bool
+	}{
+		{name: "select", query: "SELECT id FROM sessions", expected: true},
+		{name: "lowercase select", query: "select id from sessions", expected: true},
+		{name: "after a sqlc header", query: "-- name: GetSessionByID :one\nSELECT id FROM sessions WHERE id = ?", expected: true},
+		{name: "after several comments and blank lines", query: "-- name: ListSessions :many\n-- newest first\n\n  SELECT id FROM sessions", expected: true},
+		{name: "insert", query: "-- name: CreateSession :one\nINSERT INTO sessions (id) VALUES (?)", expected: false},
+		{name: "insert returning", query: "INSERT INTO sessions (id) VALUES (?) RETURNING id", expected: false},
+		{name: "update", query: "UPDATE sessions SET title = ?", expected: false},
+		{name: "common table expressions stay on the writer", query: "WITH recent AS (SELECT id FROM sessions) SELECT * FROM recent", expected: false},
+		{name: "pragma", query: "PRAGMA user_version", expected: false},
+		{name: "empty", query: "", expected: false},
+		{name: "only a comment", query: "-- name: Nothing :exec", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isRead(tt.query))
+		})
+	}
+}
+
+func TestOpenRoutesQueries(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = t.TempDir()
+
+	ctx := context.Background()
+	db, err := Open(ctx)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+	require.NoError(t, err)
+
+	// inUse returns the connections each pool has busy with open rows
+	inUse := func() (writer, reader int) {
+		return db.DB.Stats().InUse, db.Reader.Stats().InUse
+	}
+
+	// RETURNING writes, so it must reach the writer
+	rows, err := db.QueryContext(ctx, "-- name: CreateItem :one\nINSERT INTO items (name) VALUES (?) RETURNING id", "a")
+	require.NoError(t, err)
+	writer, reader := inUse()
+	assert.Equal(t, 1, writer)
+	assert.Equal(t, 0, reader)
+	require.True(t, rows.Next())
+	var id int64
+	require.NoError(t, rows.Scan(&id))
+	require.NoError(t, rows.Close())
+	assert.Equal(t, int64(1), id)
+
+	rows, err = db.QueryContext(ctx, "-- name: GetItem :one\nSELECT name FROM items WHERE id = ?", id)
+	require.NoError(t, err)
+	writer, reader = inUse()
+	assert.Equal(t, 0, writer)
+	assert.Equal(t, 1, reader)
+	require.True(t, rows.Next())
+	var name string
+	require.NoError(t, rows.Scan(&name))
+	require.NoError(t, rows.Close())
+	assert.Equal(t, "a", name)
+
+	// The reader pool is read-only
+	_, err = db.Reader.ExecContext(ctx, "INSERT INTO items (name) VALUES ('b')")
+	assert.Error(t, err)
+}
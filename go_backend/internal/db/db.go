@@ -24,60 +24,297 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.addSessionCacheUsageStmt, err = db.PrepareContext(ctx, addSessionCacheUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query AddSessionCacheUsage: %w", err)
+	}
+	if q.addSessionCostStmt, err = db.PrepareContext(ctx, addSessionCost); err != nil {
+		return nil, fmt.Errorf("error preparing query AddSessionCost: %w", err)
+	}
+	if q.addSessionReminderStmt, err = db.PrepareContext(ctx, addSessionReminder); err != nil {
+		return nil, fmt.Errorf("error preparing query AddSessionReminder: %w", err)
+	}
+	if q.appendStreamEventStmt, err = db.PrepareContext(ctx, appendStreamEvent); err != nil {
+		return nil, fmt.Errorf("error preparing query AppendStreamEvent: %w", err)
+	}
+	if q.clearMessageIdempotencyKeyStmt, err = db.PrepareContext(ctx, clearMessageIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query ClearMessageIdempotencyKey: %w", err)
+	}
+	if q.copySessionAgentSettingsStmt, err = db.PrepareContext(ctx, copySessionAgentSettings); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySessionAgentSettings: %w", err)
+	}
+	if q.copySessionDisabledToolsStmt, err = db.PrepareContext(ctx, copySessionDisabledTools); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySessionDisabledTools: %w", err)
+	}
+	if q.copySessionEnvStmt, err = db.PrepareContext(ctx, copySessionEnv); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySessionEnv: %w", err)
+	}
+	if q.copySessionLocaleStmt, err = db.PrepareContext(ctx, copySessionLocale); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySessionLocale: %w", err)
+	}
+	if q.copySessionOutputProfileStmt, err = db.PrepareContext(ctx, copySessionOutputProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySessionOutputProfile: %w", err)
+	}
+	if q.copySessionRemindersStmt, err = db.PrepareContext(ctx, copySessionReminders); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySessionReminders: %w", err)
+	}
+	if q.copySessionSandboxProfileStmt, err = db.PrepareContext(ctx, copySessionSandboxProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySessionSandboxProfile: %w", err)
+	}
+	if q.createCheckpointStmt, err = db.PrepareContext(ctx, createCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateCheckpoint: %w", err)
+	}
+	if q.createContextPinStmt, err = db.PrepareContext(ctx, createContextPin); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateContextPin: %w", err)
+	}
 	if q.createFileStmt, err = db.PrepareContext(ctx, createFile); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateFile: %w", err)
 	}
+	if q.createJobStmt, err = db.PrepareContext(ctx, createJob); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateJob: %w", err)
+	}
+	if q.createMemoryStmt, err = db.PrepareContext(ctx, createMemory); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateMemory: %w", err)
+	}
 	if q.createMessageStmt, err = db.PrepareContext(ctx, createMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateMessage: %w", err)
 	}
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
+	if q.createSessionShareStmt, err = db.PrepareContext(ctx, createSessionShare); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateSessionShare: %w", err)
+	}
+	if q.createSessionTemplateStmt, err = db.PrepareContext(ctx, createSessionTemplate); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateSessionTemplate: %w", err)
+	}
+	if q.createToolAuditStmt, err = db.PrepareContext(ctx, createToolAudit); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateToolAudit: %w", err)
+	}
+	if q.deleteContextPinStmt, err = db.PrepareContext(ctx, deleteContextPin); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteContextPin: %w", err)
+	}
+	if q.deleteExpiredSessionSharesStmt, err = db.PrepareContext(ctx, deleteExpiredSessionShares); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteExpiredSessionShares: %w", err)
+	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
+	if q.deleteJobStmt, err = db.PrepareContext(ctx, deleteJob); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteJob: %w", err)
+	}
+	if q.deleteMemoryStmt, err = db.PrepareContext(ctx, deleteMemory); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteMemory: %w", err)
+	}
 	if q.deleteMessageStmt, err = db.PrepareContext(ctx, deleteMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteMessage: %w", err)
 	}
+	if q.deleteMessageAnnotationStmt, err = db.PrepareContext(ctx, deleteMessageAnnotation); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteMessageAnnotation: %w", err)
+	}
 	if q.deleteSessionStmt, err = db.PrepareContext(ctx, deleteSession); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSession: %w", err)
 	}
+	if q.deleteSessionEnvVarStmt, err = db.PrepareContext(ctx, deleteSessionEnvVar); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSessionEnvVar: %w", err)
+	}
+	if q.deleteSessionLocaleStmt, err = db.PrepareContext(ctx, deleteSessionLocale); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSessionLocale: %w", err)
+	}
+	if q.deleteSessionOutputProfileStmt, err = db.PrepareContext(ctx, deleteSessionOutputProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSessionOutputProfile: %w", err)
+	}
+	if q.deleteSessionSandboxProfileStmt, err = db.PrepareContext(ctx, deleteSessionSandboxProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSessionSandboxProfile: %w", err)
+	}
+	if q.deleteSessionShareStmt, err = db.PrepareContext(ctx, deleteSessionShare); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSessionShare: %w", err)
+	}
+	if q.deleteSessionSharesStmt, err = db.PrepareContext(ctx, deleteSessionShares); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSessionShares: %w", err)
+	}
+	if q.deleteSessionTemplateStmt, err = db.PrepareContext(ctx, deleteSessionTemplate); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSessionTemplate: %w", err)
+	}
+	if q.deleteStreamEventsBeforeStmt, err = db.PrepareContext(ctx, deleteStreamEventsBefore); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteStreamEventsBefore: %w", err)
+	}
+	if q.disableSessionToolStmt, err = db.PrepareContext(ctx, disableSessionTool); err != nil {
+		return nil, fmt.Errorf("error preparing query DisableSessionTool: %w", err)
+	}
+	if q.enableSessionToolStmt, err = db.PrepareContext(ctx, enableSessionTool); err != nil {
+		return nil, fmt.Errorf("error preparing query EnableSessionTool: %w", err)
+	}
+	if q.getCheckpointStmt, err = db.PrepareContext(ctx, getCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCheckpoint: %w", err)
+	}
 	if q.getFileStmt, err = db.PrepareContext(ctx, getFile); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFile: %w", err)
 	}
 	if q.getFileByPathAndSessionStmt, err = db.PrepareContext(ctx, getFileByPathAndSession); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFileByPathAndSession: %w", err)
 	}
+	if q.getJobStmt, err = db.PrepareContext(ctx, getJob); err != nil {
+		return nil, fmt.Errorf("error preparing query GetJob: %w", err)
+	}
+	if q.getLatestStreamEventSeqStmt, err = db.PrepareContext(ctx, getLatestStreamEventSeq); err != nil {
+		return nil, fmt.Errorf("error preparing query GetLatestStreamEventSeq: %w", err)
+	}
 	if q.getMessageStmt, err = db.PrepareContext(ctx, getMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query GetMessage: %w", err)
 	}
+	if q.getMessageAnnotationStmt, err = db.PrepareContext(ctx, getMessageAnnotation); err != nil {
+		return nil, fmt.Errorf("error preparing query GetMessageAnnotation: %w", err)
+	}
+	if q.getMessageByIdempotencyKeyStmt, err = db.PrepareContext(ctx, getMessageByIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query GetMessageByIdempotencyKey: %w", err)
+	}
+	if q.getMessageReasoningStmt, err = db.PrepareContext(ctx, getMessageReasoning); err != nil {
+		return nil, fmt.Errorf("error preparing query GetMessageReasoning: %w", err)
+	}
+	if q.getSessionAgentSettingsStmt, err = db.PrepareContext(ctx, getSessionAgentSettings); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionAgentSettings: %w", err)
+	}
+	if q.getSessionAssetByPathStmt, err = db.PrepareContext(ctx, getSessionAssetByPath); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionAssetByPath: %w", err)
+	}
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.getSessionCacheUsageStmt, err = db.PrepareContext(ctx, getSessionCacheUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionCacheUsage: %w", err)
+	}
+	if q.getSessionLocaleStmt, err = db.PrepareContext(ctx, getSessionLocale); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionLocale: %w", err)
+	}
+	if q.getSessionOutputProfileStmt, err = db.PrepareContext(ctx, getSessionOutputProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionOutputProfile: %w", err)
+	}
+	if q.getSessionSandboxProfileStmt, err = db.PrepareContext(ctx, getSessionSandboxProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionSandboxProfile: %w", err)
+	}
+	if q.getSessionShareStmt, err = db.PrepareContext(ctx, getSessionShare); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionShare: %w", err)
+	}
+	if q.getSessionTemplateStmt, err = db.PrepareContext(ctx, getSessionTemplate); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionTemplate: %w", err)
+	}
+	if q.getToolAuditStmt, err = db.PrepareContext(ctx, getToolAudit); err != nil {
+		return nil, fmt.Errorf("error preparing query GetToolAudit: %w", err)
+	}
+	if q.listCheckpointsBySessionStmt, err = db.PrepareContext(ctx, listCheckpointsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListCheckpointsBySession: %w", err)
+	}
+	if q.listContextPinsBySessionStmt, err = db.PrepareContext(ctx, listContextPinsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListContextPinsBySession: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
 	if q.listFilesBySessionStmt, err = db.PrepareContext(ctx, listFilesBySession); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesBySession: %w", err)
 	}
+	if q.listJobsStmt, err = db.PrepareContext(ctx, listJobs); err != nil {
+		return nil, fmt.Errorf("error preparing query ListJobs: %w", err)
+	}
 	if q.listLatestSessionFilesStmt, err = db.PrepareContext(ctx, listLatestSessionFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListLatestSessionFiles: %w", err)
 	}
+	if q.listMemoriesStmt, err = db.PrepareContext(ctx, listMemories); err != nil {
+		return nil, fmt.Errorf("error preparing query ListMemories: %w", err)
+	}
+	if q.listMemoriesForWorkspaceStmt, err = db.PrepareContext(ctx, listMemoriesForWorkspace); err != nil {
+		return nil, fmt.Errorf("error preparing query ListMemoriesForWorkspace: %w", err)
+	}
+	if q.listMessageAnnotationsBySessionStmt, err = db.PrepareContext(ctx, listMessageAnnotationsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListMessageAnnotationsBySession: %w", err)
+	}
+	if q.listMessageReasoningBySessionStmt, err = db.PrepareContext(ctx, listMessageReasoningBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListMessageReasoningBySession: %w", err)
+	}
+	if q.listMessagesBeforeStmt, err = db.PrepareContext(ctx, listMessagesBefore); err != nil {
+		return nil, fmt.Errorf("error preparing query ListMessagesBefore: %w", err)
+	}
 	if q.listMessagesBySessionStmt, err = db.PrepareContext(ctx, listMessagesBySession); err != nil {
 		return nil, fmt.Errorf("error preparing query ListMessagesBySession: %w", err)
 	}
 	if q.listMessagesForForkStmt, err = db.PrepareContext(ctx, listMessagesForFork); err != nil {
 		return nil, fmt.Errorf("error preparing query ListMessagesForFork: %w", err)
 	}
+	if q.listMessagesFromStmt, err = db.PrepareContext(ctx, listMessagesFrom); err != nil {
+		return nil, fmt.Errorf("error preparing query ListMessagesFrom: %w", err)
+	}
+	if q.listMessagesPageStmt, err = db.PrepareContext(ctx, listMessagesPage); err != nil {
+		return nil, fmt.Errorf("error preparing query ListMessagesPage: %w", err)
+	}
+	if q.listSessionAssetsStmt, err = db.PrepareContext(ctx, listSessionAssets); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionAssets: %w", err)
+	}
+	if q.listSessionDisabledToolsStmt, err = db.PrepareContext(ctx, listSessionDisabledTools); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionDisabledTools: %w", err)
+	}
+	if q.listSessionEnvStmt, err = db.PrepareContext(ctx, listSessionEnv); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionEnv: %w", err)
+	}
+	if q.listSessionRemindersStmt, err = db.PrepareContext(ctx, listSessionReminders); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionReminders: %w", err)
+	}
+	if q.listSessionSharesStmt, err = db.PrepareContext(ctx, listSessionShares); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionShares: %w", err)
+	}
+	if q.listSessionTemplatesStmt, err = db.PrepareContext(ctx, listSessionTemplates); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionTemplates: %w", err)
+	}
 	if q.listSessionsMetadataStmt, err = db.PrepareContext(ctx, listSessionsMetadata); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessionsMetadata: %w", err)
 	}
 	if q.listSessionsWithContentStmt, err = db.PrepareContext(ctx, listSessionsWithContent); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessionsWithContent: %w", err)
 	}
+	if q.listStreamEventsSinceStmt, err = db.PrepareContext(ctx, listStreamEventsSince); err != nil {
+		return nil, fmt.Errorf("error preparing query ListStreamEventsSince: %w", err)
+	}
+	if q.listToolAuditsStmt, err = db.PrepareContext(ctx, listToolAudits); err != nil {
+		return nil, fmt.Errorf("error preparing query ListToolAudits: %w", err)
+	}
+	if q.listToolTimingsStmt, err = db.PrepareContext(ctx, listToolTimings); err != nil {
+		return nil, fmt.Errorf("error preparing query ListToolTimings: %w", err)
+	}
+	if q.listUnfinishedAssistantMessagesStmt, err = db.PrepareContext(ctx, listUnfinishedAssistantMessages); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUnfinishedAssistantMessages: %w", err)
+	}
 	if q.listUserMessageHistoryStmt, err = db.PrepareContext(ctx, listUserMessageHistory); err != nil {
 		return nil, fmt.Errorf("error preparing query ListUserMessageHistory: %w", err)
 	}
+	if q.markCheckpointRestoredStmt, err = db.PrepareContext(ctx, markCheckpointRestored); err != nil {
+		return nil, fmt.Errorf("error preparing query MarkCheckpointRestored: %w", err)
+	}
+	if q.pruneMemoriesStmt, err = db.PrepareContext(ctx, pruneMemories); err != nil {
+		return nil, fmt.Errorf("error preparing query PruneMemories: %w", err)
+	}
+	if q.recordJobRunStmt, err = db.PrepareContext(ctx, recordJobRun); err != nil {
+		return nil, fmt.Errorf("error preparing query RecordJobRun: %w", err)
+	}
+	if q.removeSessionReminderStmt, err = db.PrepareContext(ctx, removeSessionReminder); err != nil {
+		return nil, fmt.Errorf("error preparing query RemoveSessionReminder: %w", err)
+	}
+	if q.setSessionAgentSettingsStmt, err = db.PrepareContext(ctx, setSessionAgentSettings); err != nil {
+		return nil, fmt.Errorf("error preparing query SetSessionAgentSettings: %w", err)
+	}
+	if q.setSessionEnvVarStmt, err = db.PrepareContext(ctx, setSessionEnvVar); err != nil {
+		return nil, fmt.Errorf("error preparing query SetSessionEnvVar: %w", err)
+	}
+	if q.setSessionLocaleStmt, err = db.PrepareContext(ctx, setSessionLocale); err != nil {
+		return nil, fmt.Errorf("error preparing query SetSessionLocale: %w", err)
+	}
+	if q.setSessionOutputProfileStmt, err = db.PrepareContext(ctx, setSessionOutputProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query SetSessionOutputProfile: %w", err)
+	}
+	if q.setSessionSandboxProfileStmt, err = db.PrepareContext(ctx, setSessionSandboxProfile); err != nil {
+		return nil, fmt.Errorf("error preparing query SetSessionSandboxProfile: %w", err)
+	}
+	if q.summarizeToolCostsStmt, err = db.PrepareContext(ctx, summarizeToolCosts); err != nil {
+		return nil, fmt.Errorf("error preparing query SummarizeToolCosts: %w", err)
+	}
 	if q.updateFileStmt, err = db.PrepareContext(ctx, updateFile); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateFile: %w", err)
 	}
@@ -87,16 +324,111 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.updateSessionStmt, err = db.PrepareContext(ctx, updateSession); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateSession: %w", err)
 	}
+	if q.updateSessionOrganizationStmt, err = db.PrepareContext(ctx, updateSessionOrganization); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateSessionOrganization: %w", err)
+	}
+	if q.updateSessionWorkingDirectoryStmt, err = db.PrepareContext(ctx, updateSessionWorkingDirectory); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateSessionWorkingDirectory: %w", err)
+	}
+	if q.upsertMessageAnnotationStmt, err = db.PrepareContext(ctx, upsertMessageAnnotation); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertMessageAnnotation: %w", err)
+	}
+	if q.upsertMessageReasoningStmt, err = db.PrepareContext(ctx, upsertMessageReasoning); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertMessageReasoning: %w", err)
+	}
+	if q.upsertSessionAssetStmt, err = db.PrepareContext(ctx, upsertSessionAsset); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertSessionAsset: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.addSessionCacheUsageStmt != nil {
+		if cerr := q.addSessionCacheUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addSessionCacheUsageStmt: %w", cerr)
+		}
+	}
+	if q.addSessionCostStmt != nil {
+		if cerr := q.addSessionCostStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addSessionCostStmt: %w", cerr)
+		}
+	}
+	if q.addSessionReminderStmt != nil {
+		if cerr := q.addSessionReminderStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addSessionReminderStmt: %w", cerr)
+		}
+	}
+	if q.appendStreamEventStmt != nil {
+		if cerr := q.appendStreamEventStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing appendStreamEventStmt: %w", cerr)
+		}
+	}
+	if q.clearMessageIdempotencyKeyStmt != nil {
+		if cerr := q.clearMessageIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing clearMessageIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.copySessionAgentSettingsStmt != nil {
+		if cerr := q.copySessionAgentSettingsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySessionAgentSettingsStmt: %w", cerr)
+		}
+	}
+	if q.copySessionDisabledToolsStmt != nil {
+		if cerr := q.copySessionDisabledToolsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySessionDisabledToolsStmt: %w", cerr)
+		}
+	}
+	if q.copySessionEnvStmt != nil {
+		if cerr := q.copySessionEnvStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySessionEnvStmt: %w", cerr)
+		}
+	}
+	if q.copySessionLocaleStmt != nil {
+		if cerr := q.copySessionLocaleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySessionLocaleStmt: %w", cerr)
+		}
+	}
+	if q.copySessionOutputProfileStmt != nil {
+		if cerr := q.copySessionOutputProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySessionOutputProfileStmt: %w", cerr)
+		}
+	}
+	if q.copySessionRemindersStmt != nil {
+		if cerr := q.copySessionRemindersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySessionRemindersStmt: %w", cerr)
+		}
+	}
+	if q.copySessionSandboxProfileStmt != nil {
+		if cerr := q.copySessionSandboxProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySessionSandboxProfileStmt: %w", cerr)
+		}
+	}
+	if q.createCheckpointStmt != nil {
+		if cerr := q.createCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createCheckpointStmt: %w", cerr)
+		}
+	}
+	if q.createContextPinStmt != nil {
+		if cerr := q.createContextPinStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createContextPinStmt: %w", cerr)
+		}
+	}
 	if q.createFileStmt != nil {
 		if cerr := q.createFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createFileStmt: %w", cerr)
 		}
 	}
+	if q.createJobStmt != nil {
+		if cerr := q.createJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createJobStmt: %w", cerr)
+		}
+	}
+	if q.createMemoryStmt != nil {
+		if cerr := q.createMemoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createMemoryStmt: %w", cerr)
+		}
+	}
 	if q.createMessageStmt != nil {
 		if cerr := q.createMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createMessageStmt: %w", cerr)
@@ -107,21 +439,116 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
 		}
 	}
+	if q.createSessionShareStmt != nil {
+		if cerr := q.createSessionShareStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createSessionShareStmt: %w", cerr)
+		}
+	}
+	if q.createSessionTemplateStmt != nil {
+		if cerr := q.createSessionTemplateStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createSessionTemplateStmt: %w", cerr)
+		}
+	}
+	if q.createToolAuditStmt != nil {
+		if cerr := q.createToolAuditStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createToolAuditStmt: %w", cerr)
+		}
+	}
+	if q.deleteContextPinStmt != nil {
+		if cerr := q.deleteContextPinStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteContextPinStmt: %w", cerr)
+		}
+	}
+	if q.deleteExpiredSessionSharesStmt != nil {
+		if cerr := q.deleteExpiredSessionSharesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteExpiredSessionSharesStmt: %w", cerr)
+		}
+	}
 	if q.deleteFileStmt != nil {
 		if cerr := q.deleteFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
 		}
 	}
+	if q.deleteJobStmt != nil {
+		if cerr := q.deleteJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteJobStmt: %w", cerr)
+		}
+	}
+	if q.deleteMemoryStmt != nil {
+		if cerr := q.deleteMemoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteMemoryStmt: %w", cerr)
+		}
+	}
 	if q.deleteMessageStmt != nil {
 		if cerr := q.deleteMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteMessageStmt: %w", cerr)
 		}
 	}
+	if q.deleteMessageAnnotationStmt != nil {
+		if cerr := q.deleteMessageAnnotationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteMessageAnnotationStmt: %w", cerr)
+		}
+	}
 	if q.deleteSessionStmt != nil {
 		if cerr := q.deleteSessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteSessionStmt: %w", cerr)
 		}
 	}
+	if q.deleteSessionEnvVarStmt != nil {
+		if cerr := q.deleteSessionEnvVarStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSessionEnvVarStmt: %w", cerr)
+		}
+	}
+	if q.deleteSessionLocaleStmt != nil {
+		if cerr := q.deleteSessionLocaleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSessionLocaleStmt: %w", cerr)
+		}
+	}
+	if q.deleteSessionOutputProfileStmt != nil {
+		if cerr := q.deleteSessionOutputProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSessionOutputProfileStmt: %w", cerr)
+		}
+	}
+	if q.deleteSessionSandboxProfileStmt != nil {
+		if cerr := q.deleteSessionSandboxProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSessionSandboxProfileStmt: %w", cerr)
+		}
+	}
+	if q.deleteSessionShareStmt != nil {
+		if cerr := q.deleteSessionShareStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSessionShareStmt: %w", cerr)
+		}
+	}
+	if q.deleteSessionSharesStmt != nil {
+		if cerr := q.deleteSessionSharesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSessionSharesStmt: %w", cerr)
+		}
+	}
+	if q.deleteSessionTemplateStmt != nil {
+		if cerr := q.deleteSessionTemplateStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSessionTemplateStmt: %w", cerr)
+		}
+	}
+	if q.deleteStreamEventsBeforeStmt != nil {
+		if cerr := q.deleteStreamEventsBeforeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteStreamEventsBeforeStmt: %w", cerr)
+		}
+	}
+	if q.disableSessionToolStmt != nil {
+		if cerr := q.disableSessionToolStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing disableSessionToolStmt: %w", cerr)
+		}
+	}
+	if q.enableSessionToolStmt != nil {
+		if cerr := q.enableSessionToolStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing enableSessionToolStmt: %w", cerr)
+		}
+	}
+	if q.getCheckpointStmt != nil {
+		if cerr := q.getCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCheckpointStmt: %w", cerr)
+		}
+	}
 	if q.getFileStmt != nil {
 		if cerr := q.getFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getFileStmt: %w", cerr)
@@ -132,16 +559,96 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getFileByPathAndSessionStmt: %w", cerr)
 		}
 	}
+	if q.getJobStmt != nil {
+		if cerr := q.getJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getJobStmt: %w", cerr)
+		}
+	}
+	if q.getLatestStreamEventSeqStmt != nil {
+		if cerr := q.getLatestStreamEventSeqStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLatestStreamEventSeqStmt: %w", cerr)
+		}
+	}
 	if q.getMessageStmt != nil {
 		if cerr := q.getMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getMessageStmt: %w", cerr)
 		}
 	}
+	if q.getMessageAnnotationStmt != nil {
+		if cerr := q.getMessageAnnotationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getMessageAnnotationStmt: %w", cerr)
+		}
+	}
+	if q.getMessageByIdempotencyKeyStmt != nil {
+		if cerr := q.getMessageByIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getMessageByIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.getMessageReasoningStmt != nil {
+		if cerr := q.getMessageReasoningStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getMessageReasoningStmt: %w", cerr)
+		}
+	}
+	if q.getSessionAgentSettingsStmt != nil {
+		if cerr := q.getSessionAgentSettingsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionAgentSettingsStmt: %w", cerr)
+		}
+	}
+	if q.getSessionAssetByPathStmt != nil {
+		if cerr := q.getSessionAssetByPathStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionAssetByPathStmt: %w", cerr)
+		}
+	}
 	if q.getSessionByIDStmt != nil {
 		if cerr := q.getSessionByIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.getSessionCacheUsageStmt != nil {
+		if cerr := q.getSessionCacheUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionCacheUsageStmt: %w", cerr)
+		}
+	}
+	if q.getSessionLocaleStmt != nil {
+		if cerr := q.getSessionLocaleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionLocaleStmt: %w", cerr)
+		}
+	}
+	if q.getSessionOutputProfileStmt != nil {
+		if cerr := q.getSessionOutputProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionOutputProfileStmt: %w", cerr)
+		}
+	}
+	if q.getSessionSandboxProfileStmt != nil {
+		if cerr := q.getSessionSandboxProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionSandboxProfileStmt: %w", cerr)
+		}
+	}
+	if q.getSessionShareStmt != nil {
+		if cerr := q.getSessionShareStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionShareStmt: %w", cerr)
+		}
+	}
+	if q.getSessionTemplateStmt != nil {
+		if cerr := q.getSessionTemplateStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionTemplateStmt: %w", cerr)
+		}
+	}
+	if q.getToolAuditStmt != nil {
+		if cerr := q.getToolAuditStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getToolAuditStmt: %w", cerr)
+		}
+	}
+	if q.listCheckpointsBySessionStmt != nil {
+		if cerr := q.listCheckpointsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listCheckpointsBySessionStmt: %w", cerr)
+		}
+	}
+	if q.listContextPinsBySessionStmt != nil {
+		if cerr := q.listContextPinsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listContextPinsBySessionStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -152,11 +659,41 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listFilesBySessionStmt: %w", cerr)
 		}
 	}
+	if q.listJobsStmt != nil {
+		if cerr := q.listJobsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listJobsStmt: %w", cerr)
+		}
+	}
 	if q.listLatestSessionFilesStmt != nil {
 		if cerr := q.listLatestSessionFilesStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listLatestSessionFilesStmt: %w", cerr)
 		}
 	}
+	if q.listMemoriesStmt != nil {
+		if cerr := q.listMemoriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listMemoriesStmt: %w", cerr)
+		}
+	}
+	if q.listMemoriesForWorkspaceStmt != nil {
+		if cerr := q.listMemoriesForWorkspaceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listMemoriesForWorkspaceStmt: %w", cerr)
+		}
+	}
+	if q.listMessageAnnotationsBySessionStmt != nil {
+		if cerr := q.listMessageAnnotationsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listMessageAnnotationsBySessionStmt: %w", cerr)
+		}
+	}
+	if q.listMessageReasoningBySessionStmt != nil {
+		if cerr := q.listMessageReasoningBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listMessageReasoningBySessionStmt: %w", cerr)
+		}
+	}
+	if q.listMessagesBeforeStmt != nil {
+		if cerr := q.listMessagesBeforeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listMessagesBeforeStmt: %w", cerr)
+		}
+	}
 	if q.listMessagesBySessionStmt != nil {
 		if cerr := q.listMessagesBySessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listMessagesBySessionStmt: %w", cerr)
@@ -167,6 +704,46 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listMessagesForForkStmt: %w", cerr)
 		}
 	}
+	if q.listMessagesFromStmt != nil {
+		if cerr := q.listMessagesFromStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listMessagesFromStmt: %w", cerr)
+		}
+	}
+	if q.listMessagesPageStmt != nil {
+		if cerr := q.listMessagesPageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listMessagesPageStmt: %w", cerr)
+		}
+	}
+	if q.listSessionAssetsStmt != nil {
+		if cerr := q.listSessionAssetsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionAssetsStmt: %w", cerr)
+		}
+	}
+	if q.listSessionDisabledToolsStmt != nil {
+		if cerr := q.listSessionDisabledToolsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionDisabledToolsStmt: %w", cerr)
+		}
+	}
+	if q.listSessionEnvStmt != nil {
+		if cerr := q.listSessionEnvStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionEnvStmt: %w", cerr)
+		}
+	}
+	if q.listSessionRemindersStmt != nil {
+		if cerr := q.listSessionRemindersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionRemindersStmt: %w", cerr)
+		}
+	}
+	if q.listSessionSharesStmt != nil {
+		if cerr := q.listSessionSharesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionSharesStmt: %w", cerr)
+		}
+	}
+	if q.listSessionTemplatesStmt != nil {
+		if cerr := q.listSessionTemplatesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionTemplatesStmt: %w", cerr)
+		}
+	}
 	if q.listSessionsMetadataStmt != nil {
 		if cerr := q.listSessionsMetadataStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listSessionsMetadataStmt: %w", cerr)
@@ -177,11 +754,81 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listSessionsWithContentStmt: %w", cerr)
 		}
 	}
+	if q.listStreamEventsSinceStmt != nil {
+		if cerr := q.listStreamEventsSinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listStreamEventsSinceStmt: %w", cerr)
+		}
+	}
+	if q.listToolAuditsStmt != nil {
+		if cerr := q.listToolAuditsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listToolAuditsStmt: %w", cerr)
+		}
+	}
+	if q.listToolTimingsStmt != nil {
+		if cerr := q.listToolTimingsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listToolTimingsStmt: %w", cerr)
+		}
+	}
+	if q.listUnfinishedAssistantMessagesStmt != nil {
+		if cerr := q.listUnfinishedAssistantMessagesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUnfinishedAssistantMessagesStmt: %w", cerr)
+		}
+	}
 	if q.listUserMessageHistoryStmt != nil {
 		if cerr := q.listUserMessageHistoryStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listUserMessageHistoryStmt: %w", cerr)
 		}
 	}
+	if q.markCheckpointRestoredStmt != nil {
+		if cerr := q.markCheckpointRestoredStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing markCheckpointRestoredStmt: %w", cerr)
+		}
+	}
+	if q.pruneMemoriesStmt != nil {
+		if cerr := q.pruneMemoriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing pruneMemoriesStmt: %w", cerr)
+		}
+	}
+	if q.recordJobRunStmt != nil {
+		if cerr := q.recordJobRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing recordJobRunStmt: %w", cerr)
+		}
+	}
+	if q.removeSessionReminderStmt != nil {
+		if cerr := q.removeSessionReminderStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing removeSessionReminderStmt: %w", cerr)
+		}
+	}
+	if q.setSessionAgentSettingsStmt != nil {
+		if cerr := q.setSessionAgentSettingsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setSessionAgentSettingsStmt: %w", cerr)
+		}
+	}
+	if q.setSessionEnvVarStmt != nil {
+		if cerr := q.setSessionEnvVarStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setSessionEnvVarStmt: %w", cerr)
+		}
+	}
+	if q.setSessionLocaleStmt != nil {
+		if cerr := q.setSessionLocaleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setSessionLocaleStmt: %w", cerr)
+		}
+	}
+	if q.setSessionOutputProfileStmt != nil {
+		if cerr := q.setSessionOutputProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setSessionOutputProfileStmt: %w", cerr)
+		}
+	}
+	if q.setSessionSandboxProfileStmt != nil {
+		if cerr := q.setSessionSandboxProfileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setSessionSandboxProfileStmt: %w", cerr)
+		}
+	}
+	if q.summarizeToolCostsStmt != nil {
+		if cerr := q.summarizeToolCostsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing summarizeToolCostsStmt: %w", cerr)
+		}
+	}
 	if q.updateFileStmt != nil {
 		if cerr := q.updateFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateFileStmt: %w", cerr)
@@ -197,6 +844,31 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing updateSessionStmt: %w", cerr)
 		}
 	}
+	if q.updateSessionOrganizationStmt != nil {
+		if cerr := q.updateSessionOrganizationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateSessionOrganizationStmt: %w", cerr)
+		}
+	}
+	if q.updateSessionWorkingDirectoryStmt != nil {
+		if cerr := q.updateSessionWorkingDirectoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateSessionWorkingDirectoryStmt: %w", cerr)
+		}
+	}
+	if q.upsertMessageAnnotationStmt != nil {
+		if cerr := q.upsertMessageAnnotationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertMessageAnnotationStmt: %w", cerr)
+		}
+	}
+	if q.upsertMessageReasoningStmt != nil {
+		if cerr := q.upsertMessageReasoningStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertMessageReasoningStmt: %w", cerr)
+		}
+	}
+	if q.upsertSessionAssetStmt != nil {
+		if cerr := q.upsertSessionAssetStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertSessionAssetStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -234,55 +906,223 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                          DBTX
-	tx                          *sql.Tx
-	createFileStmt              *sql.Stmt
-	createMessageStmt           *sql.Stmt
-	createSessionStmt           *sql.Stmt
-	deleteFileStmt              *sql.Stmt
-	deleteMessageStmt           *sql.Stmt
-	deleteSessionStmt           *sql.Stmt
-	getFileStmt                 *sql.Stmt
-	getFileByPathAndSessionStmt *sql.Stmt
-	getMessageStmt              *sql.Stmt
-	getSessionByIDStmt          *sql.Stmt
-	listFilesByPathStmt         *sql.Stmt
-	listFilesBySessionStmt      *sql.Stmt
-	listLatestSessionFilesStmt  *sql.Stmt
-	listMessagesBySessionStmt   *sql.Stmt
-	listMessagesForForkStmt     *sql.Stmt
-	listSessionsMetadataStmt    *sql.Stmt
-	listSessionsWithContentStmt *sql.Stmt
-	listUserMessageHistoryStmt  *sql.Stmt
-	updateFileStmt              *sql.Stmt
-	updateMessageStmt           *sql.Stmt
-	updateSessionStmt           *sql.Stmt
+	db                                  DBTX
+	tx                                  *sql.Tx
+	addSessionCacheUsageStmt            *sql.Stmt
+	addSessionCostStmt                  *sql.Stmt
+	addSessionReminderStmt              *sql.Stmt
+	appendStreamEventStmt               *sql.Stmt
+	clearMessageIdempotencyKeyStmt      *sql.Stmt
+	copySessionAgentSettingsStmt        *sql.Stmt
+	copySessionDisabledToolsStmt        *sql.Stmt
+	copySessionEnvStmt                  *sql.Stmt
+	copySessionLocaleStmt               *sql.Stmt
+	copySessionOutputProfileStmt        *sql.Stmt
+	copySessionRemindersStmt            *sql.Stmt
+	copySessionSandboxProfileStmt       *sql.Stmt
+	createCheckpointStmt                *sql.Stmt
+	createContextPinStmt                *sql.Stmt
+	createFileStmt                      *sql.Stmt
+	createJobStmt                       *sql.Stmt
+	createMemoryStmt                    *sql.Stmt
+	createMessageStmt                   *sql.Stmt
+	createSessionStmt                   *sql.Stmt
+	createSessionShareStmt              *sql.Stmt
+	createSessionTemplateStmt           *sql.Stmt
+	createToolAuditStmt                 *sql.Stmt
+	deleteContextPinStmt                *sql.Stmt
+	deleteExpiredSessionSharesStmt      *sql.Stmt
+	deleteFileStmt                      *sql.Stmt
+	deleteJobStmt                       *sql.Stmt
+	deleteMemoryStmt                    *sql.Stmt
+	deleteMessageStmt                   *sql.Stmt
+	deleteMessageAnnotationStmt         *sql.Stmt
+	deleteSessionStmt                   *sql.Stmt
+	deleteSessionEnvVarStmt             *sql.Stmt
+	deleteSessionLocaleStmt             *sql.Stmt
+	deleteSessionOutputProfileStmt      *sql.Stmt
+	deleteSessionSandboxProfileStmt     *sql.Stmt
+	deleteSessionShareStmt              *sql.Stmt
+	deleteSessionSharesStmt             *sql.Stmt
+	deleteSessionTemplateStmt           *sql.Stmt
+	deleteStreamEventsBeforeStmt        *sql.Stmt
+	disableSessionToolStmt              *sql.Stmt
+	enableSessionToolStmt               *sql.Stmt
+	getCheckpointStmt                   *sql.Stmt
+	getFileStmt                         *sql.Stmt
+	getFileByPathAndSessionStmt         *sql.Stmt
+	getJobStmt                          *sql.Stmt
+	getLatestStreamEventSeqStmt         *sql.Stmt
+	getMessageStmt                      *sql.Stmt
+	getMessageAnnotationStmt            *sql.Stmt
+	getMessageByIdempotencyKeyStmt      *sql.Stmt
+	getMessageReasoningStmt             *sql.Stmt
+	getSessionAgentSettingsStmt         *sql.Stmt
+	getSessionAssetByPathStmt           *sql.Stmt
+	getSessionByIDStmt                  *sql.Stmt
+	getSessionCacheUsageStmt            *sql.Stmt
+	getSessionLocaleStmt                *sql.Stmt
+	getSessionOutputProfileStmt         *sql.Stmt
+	getSessionSandboxProfileStmt        *sql.Stmt
+	getSessionShareStmt                 *sql.Stmt
+	getSessionTemplateStmt              *sql.Stmt
+	getToolAuditStmt                    *sql.Stmt
+	listCheckpointsBySessionStmt        *sql.Stmt
+	listContextPinsBySessionStmt        *sql.Stmt
+	listFilesByPathStmt                 *sql.Stmt
+	listFilesBySessionStmt              *sql.Stmt
+	listJobsStmt                        *sql.Stmt
+	listLatestSessionFilesStmt          *sql.Stmt
+	listMemoriesStmt                    *sql.Stmt
+	listMemoriesForWorkspaceStmt        *sql.Stmt
+	listMessageAnnotationsBySessionStmt *sql.Stmt
+	listMessageReasoningBySessionStmt   *sql.Stmt
+	listMessagesBeforeStmt              *sql.Stmt
+	listMessagesBySessionStmt           *sql.Stmt
+	listMessagesForForkStmt             *sql.Stmt
+	listMessagesFromStmt                *sql.Stmt
+	listMessagesPageStmt                *sql.Stmt
+	listSessionAssetsStmt               *sql.Stmt
+	listSessionDisabledToolsStmt        *sql.Stmt
+	listSessionEnvStmt                  *sql.Stmt
+	listSessionRemindersStmt            *sql.Stmt
+	listSessionSharesStmt               *sql.Stmt
+	listSessionTemplatesStmt            *sql.Stmt
+	listSessionsMetadataStmt            *sql.Stmt
+	listSessionsWithContentStmt         *sql.Stmt
+	listStreamEventsSinceStmt           *sql.Stmt
+	listToolAuditsStmt                  *sql.Stmt
+	listToolTimingsStmt                 *sql.Stmt
+	listUnfinishedAssistantMessagesStmt *sql.Stmt
+	listUserMessageHistoryStmt          *sql.Stmt
+	markCheckpointRestoredStmt          *sql.Stmt
+	pruneMemoriesStmt                   *sql.Stmt
+	recordJobRunStmt                    *sql.Stmt
+	removeSessionReminderStmt           *sql.Stmt
+	setSessionAgentSettingsStmt         *sql.Stmt
+	setSessionEnvVarStmt                *sql.Stmt
+	setSessionLocaleStmt                *sql.Stmt
+	setSessionOutputProfileStmt         *sql.Stmt
+	setSessionSandboxProfileStmt        *sql.Stmt
+	summarizeToolCostsStmt              *sql.Stmt
+	updateFileStmt                      *sql.Stmt
+	updateMessageStmt                   *sql.Stmt
+	updateSessionStmt                   *sql.Stmt
+	updateSessionOrganizationStmt       *sql.Stmt
+	updateSessionWorkingDirectoryStmt   *sql.Stmt
+	upsertMessageAnnotationStmt         *sql.Stmt
+	upsertMessageReasoningStmt          *sql.Stmt
+	upsertSessionAssetStmt              *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                          tx,
-		tx:                          tx,
-		createFileStmt:              q.createFileStmt,
-		createMessageStmt:           q.createMessageStmt,
-		createSessionStmt:           q.createSessionStmt,
-		deleteFileStmt:              q.deleteFileStmt,
-		deleteMessageStmt:           q.deleteMessageStmt,
-		deleteSessionStmt:           q.deleteSessionStmt,
-		getFileStmt:                 q.getFileStmt,
-		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
-		getMessageStmt:              q.getMessageStmt,
-		getSessionByIDStmt:          q.getSessionByIDStmt,
-		listFilesByPathStmt:         q.listFilesByPathStmt,
-		listFilesBySessionStmt:      q.listFilesBySessionStmt,
-		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
-		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
-		listMessagesForForkStmt:     q.listMessagesForForkStmt,
-		listSessionsMetadataStmt:    q.listSessionsMetadataStmt,
-		listSessionsWithContentStmt: q.listSessionsWithContentStmt,
-		listUserMessageHistoryStmt:  q.listUserMessageHistoryStmt,
-		updateFileStmt:              q.updateFileStmt,
-		updateMessageStmt:           q.updateMessageStmt,
-		updateSessionStmt:           q.updateSessionStmt,
+		db:                                  tx,
+		tx:                                  tx,
+		addSessionCacheUsageStmt:            q.addSessionCacheUsageStmt,
+		addSessionCostStmt:                  q.addSessionCostStmt,
+		addSessionReminderStmt:              q.addSessionReminderStmt,
+		appendStreamEventStmt:               q.appendStreamEventStmt,
+		clearMessageIdempotencyKeyStmt:      q.clearMessageIdempotencyKeyStmt,
+		copySessionAgentSettingsStmt:        q.copySessionAgentSettingsStmt,
+		copySessionDisabledToolsStmt:        q.copySessionDisabledToolsStmt,
+		copySessionEnvStmt:                  q.copySessionEnvStmt,
+		copySessionLocaleStmt:               q.copySessionLocaleStmt,
+		copySessionOutputProfileStmt:        q.copySessionOutputProfileStmt,
+		copySessionRemindersStmt:            q.copySessionRemindersStmt,
+		copySessionSandboxProfileStmt:       q.copySessionSandboxProfileStmt,
+		createCheckpointStmt:                q.createCheckpointStmt,
+		createContextPinStmt:                q.createContextPinStmt,
+		createFileStmt:                      q.createFileStmt,
+		createJobStmt:                       q.createJobStmt,
+		createMemoryStmt:                    q.createMemoryStmt,
+		createMessageStmt:                   q.createMessageStmt,
+		createSessionStmt:                   q.createSessionStmt,
+		createSessionShareStmt:              q.createSessionShareStmt,
+		createSessionTemplateStmt:           q.createSessionTemplateStmt,
+		createToolAuditStmt:                 q.createToolAuditStmt,
+		deleteContextPinStmt:                q.deleteContextPinStmt,
+		deleteExpiredSessionSharesStmt:      q.deleteExpiredSessionSharesStmt,
+		deleteFileStmt:                      q.deleteFileStmt,
+		deleteJobStmt:                       q.deleteJobStmt,
+		deleteMemoryStmt:                    q.deleteMemoryStmt,
+		deleteMessageStmt:                   q.deleteMessageStmt,
+		deleteMessageAnnotationStmt:         q.deleteMessageAnnotationStmt,
+		deleteSessionStmt:                   q.deleteSessionStmt,
+		deleteSessionEnvVarStmt:             q.deleteSessionEnvVarStmt,
+		deleteSessionLocaleStmt:             q.deleteSessionLocaleStmt,
+		deleteSessionOutputProfileStmt:      q.deleteSessionOutputProfileStmt,
+		deleteSessionSandboxProfileStmt:     q.deleteSessionSandboxProfileStmt,
+		deleteSessionShareStmt:              q.deleteSessionShareStmt,
+		deleteSessionSharesStmt:             q.deleteSessionSharesStmt,
+		deleteSessionTemplateStmt:           q.deleteSessionTemplateStmt,
+		deleteStreamEventsBeforeStmt:        q.deleteStreamEventsBeforeStmt,
+		disableSessionToolStmt:              q.disableSessionToolStmt,
+		enableSessionToolStmt:               q.enableSessionToolStmt,
+		getCheckpointStmt:                   q.getCheckpointStmt,
+		getFileStmt:                         q.getFileStmt,
+		getFileByPathAndSessionStmt:         q.getFileByPathAndSessionStmt,
+		getJobStmt:                          q.getJobStmt,
+		getLatestStreamEventSeqStmt:         q.getLatestStreamEventSeqStmt,
+		getMessageStmt:                      q.getMessageStmt,
+		getMessageAnnotationStmt:            q.getMessageAnnotationStmt,
+		getMessageByIdempotencyKeyStmt:      q.getMessageByIdempotencyKeyStmt,
+		getMessageReasoningStmt:             q.getMessageReasoningStmt,
+		getSessionAgentSettingsStmt:         q.getSessionAgentSettingsStmt,
+		getSessionAssetByPathStmt:           q.getSessionAssetByPathStmt,
+		getSessionByIDStmt:                  q.getSessionByIDStmt,
+		getSessionCacheUsageStmt:            q.getSessionCacheUsageStmt,
+		getSessionLocaleStmt:                q.getSessionLocaleStmt,
+		getSessionOutputProfileStmt:         q.getSessionOutputProfileStmt,
+		getSessionSandboxProfileStmt:        q.getSessionSandboxProfileStmt,
+		getSessionShareStmt:                 q.getSessionShareStmt,
+		getSessionTemplateStmt:              q.getSessionTemplateStmt,
+		getToolAuditStmt:                    q.getToolAuditStmt,
+		listCheckpointsBySessionStmt:        q.listCheckpointsBySessionStmt,
+		listContextPinsBySessionStmt:        q.listContextPinsBySessionStmt,
+		listFilesByPathStmt:                 q.listFilesByPathStmt,
+		listFilesBySessionStmt:              q.listFilesBySessionStmt,
+		listJobsStmt:                        q.listJobsStmt,
+		listLatestSessionFilesStmt:          q.listLatestSessionFilesStmt,
+		listMemoriesStmt:                    q.listMemoriesStmt,
+		listMemoriesForWorkspaceStmt:        q.listMemoriesForWorkspaceStmt,
+		listMessageAnnotationsBySessionStmt: q.listMessageAnnotationsBySessionStmt,
+		listMessageReasoningBySessionStmt:   q.listMessageReasoningBySessionStmt,
+		listMessagesBeforeStmt:              q.listMessagesBeforeStmt,
+		listMessagesBySessionStmt:           q.listMessagesBySessionStmt,
+		listMessagesForForkStmt:             q.listMessagesForForkStmt,
+		listMessagesFromStmt:                q.listMessagesFromStmt,
+		listMessagesPageStmt:                q.listMessagesPageStmt,
+		listSessionAssetsStmt:               q.listSessionAssetsStmt,
+		listSessionDisabledToolsStmt:        q.listSessionDisabledToolsStmt,
+		listSessionEnvStmt:                  q.listSessionEnvStmt,
+		listSessionRemindersStmt:            q.listSessionRemindersStmt,
+		listSessionSharesStmt:               q.listSessionSharesStmt,
+		listSessionTemplatesStmt:            q.listSessionTemplatesStmt,
+		listSessionsMetadataStmt:            q.listSessionsMetadataStmt,
+		listSessionsWithContentStmt:         q.listSessionsWithContentStmt,
+		listStreamEventsSinceStmt:           q.listStreamEventsSinceStmt,
+		listToolAuditsStmt:                  q.listToolAuditsStmt,
+		listToolTimingsStmt:                 q.listToolTimingsStmt,
+		listUnfinishedAssistantMessagesStmt: q.listUnfinishedAssistantMessagesStmt,
+		listUserMessageHistoryStmt:          q.listUserMessageHistoryStmt,
+		markCheckpointRestoredStmt:          q.markCheckpointRestoredStmt,
+		pruneMemoriesStmt:                   q.pruneMemoriesStmt,
+		recordJobRunStmt:                    q.recordJobRunStmt,
+		removeSessionReminderStmt:           q.removeSessionReminderStmt,
+		setSessionAgentSettingsStmt:         q.setSessionAgentSettingsStmt,
+		setSessionEnvVarStmt:                q.setSessionEnvVarStmt,
+		setSessionLocaleStmt:                q.setSessionLocaleStmt,
+		setSessionOutputProfileStmt:         q.setSessionOutputProfileStmt,
+		setSessionSandboxProfileStmt:        q.setSessionSandboxProfileStmt,
+		summarizeToolCostsStmt:              q.summarizeToolCostsStmt,
+		updateFileStmt:                      q.updateFileStmt,
+		updateMessageStmt:                   q.updateMessageStmt,
+		updateSessionStmt:                   q.updateSessionStmt,
+		updateSessionOrganizationStmt:       q.updateSessionOrganizationStmt,
+		updateSessionWorkingDirectoryStmt:   q.updateSessionWorkingDirectoryStmt,
+		upsertMessageAnnotationStmt:         q.upsertMessageAnnotationStmt,
+		upsertMessageReasoningStmt:          q.upsertMessageReasoningStmt,
+		upsertSessionAssetStmt:              q.upsertSessionAssetStmt,
 	}
 }
@@ -10,6 +10,17 @@ import (
 	"database/sql"
 )
 
+const clearMessageIdempotencyKey = `-- name: ClearMessageIdempotencyKey :exec
+UPDATE messages
+SET idempotency_key = NULL
+WHERE id = ?
+`
+
+func (q *Queries) ClearMessageIdempotencyKey(ctx context.Context, id string) error {
+	_, err := q.exec(ctx, q.clearMessageIdempotencyKeyStmt, clearMessageIdempotencyKey, id)
+	return err
+}
+
 const createMessage = `-- name: CreateMessage :one
 INSERT INTO messages (
     id,
@@ -17,20 +28,22 @@ INSERT INTO messages (
     role,
     parts,
     model,
+    idempotency_key,
     created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
-RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at
+RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
 `
 
 type CreateMessageParams struct {
-	ID        string         `json:"id"`
-	SessionID string         `json:"session_id"`
-	Role      string         `json:"role"`
-	Parts     string         `json:"parts"`
-	Model     sql.NullString `json:"model"`
+	ID             string         `json:"id"`
+	SessionID      string         `json:"session_id"`
+	Role           string         `json:"role"`
+	Parts          string         `json:"parts"`
+	Model          sql.NullString `json:"model"`
+	IdempotencyKey sql.NullString `json:"idempotency_key"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -40,6 +53,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.Role,
 		arg.Parts,
 		arg.Model,
+		arg.IdempotencyKey,
 	)
 	var i Message
 	err := row.Scan(
@@ -51,6 +65,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.FinishedAt,
+		&i.IdempotencyKey,
 	)
 	return i, err
 }
@@ -66,7 +81,7 @@ func (q *Queries) DeleteMessage(ctx context.Context, id string) error {
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
 FROM messages
 WHERE id = ? LIMIT 1
 `
@@ -83,15 +98,92 @@ func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.FinishedAt,
+		&i.IdempotencyKey,
+	)
+	return i, err
+}
+
+const getMessageByIdempotencyKey = `-- name: GetMessageByIdempotencyKey :one
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
+FROM messages
+WHERE session_id = ? AND idempotency_key = ? LIMIT 1
+`
+
+type GetMessageByIdempotencyKeyParams struct {
+	SessionID      string         `json:"session_id"`
+	IdempotencyKey sql.NullString `json:"idempotency_key"`
+}
+
+func (q *Queries) GetMessageByIdempotencyKey(ctx context.Context, arg GetMessageByIdempotencyKeyParams) (Message, error) {
+	row := q.queryRow(ctx, q.getMessageByIdempotencyKeyStmt, getMessageByIdempotencyKey, arg.SessionID, arg.IdempotencyKey)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Role,
+		&i.Parts,
+		&i.Model,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FinishedAt,
+		&i.IdempotencyKey,
 	)
 	return i, err
 }
 
+const listMessagesBefore = `-- name: ListMessagesBefore :many
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
+FROM messages
+WHERE session_id = ?1
+  AND (?2 = '' OR (created_at, rowid) < (SELECT m.created_at, m.rowid FROM messages m WHERE m.id = ?2))
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?3
+`
+
+type ListMessagesBeforeParams struct {
+	SessionID string `json:"session_id"`
+	Before    string `json:"before"`
+	Limit     int64  `json:"limit"`
+}
+
+func (q *Queries) ListMessagesBefore(ctx context.Context, arg ListMessagesBeforeParams) ([]Message, error) {
+	rows, err := q.query(ctx, q.listMessagesBeforeStmt, listMessagesBefore, arg.SessionID, arg.Before, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Role,
+			&i.Parts,
+			&i.Model,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+			&i.IdempotencyKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listMessagesBySession = `-- name: ListMessagesBySession :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
 FROM messages
 WHERE session_id = ?
-ORDER BY created_at ASC
+ORDER BY created_at ASC, rowid ASC
 `
 
 func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error) {
@@ -112,6 +204,7 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FinishedAt,
+			&i.IdempotencyKey,
 		); err != nil {
 			return nil, err
 		}
@@ -127,10 +220,10 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 }
 
 const listMessagesForFork = `-- name: ListMessagesForFork :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
 FROM messages
 WHERE session_id = ?
-ORDER BY created_at ASC
+ORDER BY created_at ASC, rowid ASC
 LIMIT ?
 `
 
@@ -157,6 +250,140 @@ func (q *Queries) ListMessagesForFork(ctx context.Context, arg ListMessagesForFo
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FinishedAt,
+			&i.IdempotencyKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesFrom = `-- name: ListMessagesFrom :many
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
+FROM messages
+WHERE session_id = ?
+  AND (created_at, rowid) >= (SELECT m.created_at, m.rowid FROM messages m WHERE m.id = ?)
+ORDER BY created_at ASC, rowid ASC
+`
+
+type ListMessagesFromParams struct {
+	SessionID string `json:"session_id"`
+	ID        string `json:"id"`
+}
+
+func (q *Queries) ListMessagesFrom(ctx context.Context, arg ListMessagesFromParams) ([]Message, error) {
+	rows, err := q.query(ctx, q.listMessagesFromStmt, listMessagesFrom, arg.SessionID, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Role,
+			&i.Parts,
+			&i.Model,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+			&i.IdempotencyKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesPage = `-- name: ListMessagesPage :many
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
+FROM messages
+WHERE session_id = ?
+ORDER BY created_at ASC, rowid ASC
+LIMIT ? OFFSET ?
+`
+
+type ListMessagesPageParams struct {
+	SessionID string `json:"session_id"`
+	Limit     int64  `json:"limit"`
+	Offset    int64  `json:"offset"`
+}
+
+func (q *Queries) ListMessagesPage(ctx context.Context, arg ListMessagesPageParams) ([]Message, error) {
+	rows, err := q.query(ctx, q.listMessagesPageStmt, listMessagesPage, arg.SessionID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Role,
+			&i.Parts,
+			&i.Model,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+			&i.IdempotencyKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnfinishedAssistantMessages = `-- name: ListUnfinishedAssistantMessages :many
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
+FROM messages
+WHERE role = 'assistant' AND finished_at IS NULL AND created_at < ?
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListUnfinishedAssistantMessages(ctx context.Context, createdAt int64) ([]Message, error) {
+	rows, err := q.query(ctx, q.listUnfinishedAssistantMessagesStmt, listUnfinishedAssistantMessages, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Role,
+			&i.Parts,
+			&i.Model,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+			&i.IdempotencyKey,
 		); err != nil {
 			return nil, err
 		}
@@ -172,7 +399,7 @@ func (q *Queries) ListMessagesForFork(ctx context.Context, arg ListMessagesForFo
 }
 
 const listUserMessageHistory = `-- name: ListUserMessageHistory :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, idempotency_key
 FROM messages
 WHERE role = 'user'
 ORDER BY created_at DESC
@@ -202,6 +429,7 @@ func (q *Queries) ListUserMessageHistory(ctx context.Context, arg ListUserMessag
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FinishedAt,
+			&i.IdempotencyKey,
 		); err != nil {
 			return nil, err
 		}
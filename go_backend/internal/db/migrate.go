@@ -0,0 +1,215 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mix/internal/logging"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+)
+
+// versionTable records which migrations have been applied
+const versionTable = "schema_version"
+
+// legacyVersionTable is goose's default table, used before versionTable
+const legacyVersionTable = "goose_db_version"
+
+// ErrSchemaTooNew means the database was migrated by a newer mix; running an
+// older binary against it could corrupt data.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of mix")
+
+// MigrationStatus is one embedded migration and whether it has been applied.
+type MigrationStatus struct {
+	Version   int64     `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"appliedAt,omitempty"`
+}
+
+// SchemaStatus compares the database's schema version with the migrations
+// embedded in this binary.
+type SchemaStatus struct {
+	Current    int64             `json:"current"`
+	Latest     int64             `json:"latest"`
+	Migrations []MigrationStatus `json:"migrations"`
+}
+
+// Pending reports whether migrations remain to be applied.
+func (s SchemaStatus) Pending() bool {
+	for _, m := range s.Migrations {
+		if !m.Applied {
+			return true
+		}
+	}
+	return false
+}
+
+func newMigrator(ctx context.Context, conn *sql.DB) (*goose.Provider, error) {
+	if err := adoptLegacyVersionTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	migrations, err := fs.Sub(FS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	store, err := database.NewStore(database.DialectSQLite3, versionTable)
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider("", conn, migrations, goose.WithStore(store))
+}
+
+// adoptLegacyVersionTable renames goose's default version table in databases
+// created before versionTable was introduced.
+func adoptLegacyVersionTable(ctx context.Context, conn *sql.DB) error {
+	var legacy, current int
+	err := conn.QueryRowContext(ctx,
+		"SELECT count(*) FILTER (WHERE name = ?), count(*) FILTER (WHERE name = ?) FROM sqlite_master WHERE type = 'table'",
+		legacyVersionTable, versionTable,
+	).Scan(&legacy, &current)
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	if legacy == 0 || current > 0 {
+		return nil
+	}
+	if _, err := conn.ExecContext(ctx, "ALTER TABLE "+legacyVersionTable+" RENAME TO "+versionTable); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", legacyVersionTable, err)
+	}
+	return nil
+}
+
+// Status lists the embedded migrations and the database's current version.
+func Status(ctx context.Context, conn *sql.DB) (SchemaStatus, error) {
+	migrator, err := newMigrator(ctx, conn)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	return schemaStatus(ctx, migrator)
+}
+
+func schemaStatus(ctx context.Context, migrator *goose.Provider) (SchemaStatus, error) {
+	current, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		return SchemaStatus{}, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return SchemaStatus{}, fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	status := SchemaStatus{Current: current}
+	for _, s := range statuses {
+		status.Latest = max(status.Latest, s.Source.Version)
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version:   s.Source.Version,
+			Name:      filepath.Base(s.Source.Path),
+			Applied:   s.State == goose.StateApplied,
+			AppliedAt: s.AppliedAt,
+		})
+	}
+	return status, nil
+}
+
+// checkNotTooNew refuses databases migrated past the newest embedded migration.
+func checkNotTooNew(status SchemaStatus) error {
+	if status.Current > status.Latest {
+		return fmt.Errorf("%w: the database is at version %d but this binary only knows migrations up to %d; upgrade mix, or restore a backup made by this version with `mix backup restore`",
+			ErrSchemaTooNew, status.Current, status.Latest)
+	}
+	return nil
+}
+
+// Migrate applies pending migrations. When backupDir is set and the database
+// already has a schema, it is first copied to backupDir with VACUUM INTO so a
+// failed migration can be undone by hand.
+func Migrate(ctx context.Context, conn *sql.DB, backupDir string) (SchemaStatus, error) {
+	migrator, err := newMigrator(ctx, conn)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	status, err := schemaStatus(ctx, migrator)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	if err := checkNotTooNew(status); err != nil {
+		return status, err
+	}
+	if !status.Pending() {
+		return status, nil
+	}
+
+	if backupDir != "" && status.Current > 0 {
+		if err := backupBeforeMigration(ctx, conn, backupDir, status.Current); err != nil {
+			return status, err
+		}
+	}
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		return status, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	for _, result := range results {
+		logging.Info("Applied migration", "version", result.Source.Version, "duration", result.Duration)
+	}
+	return schemaStatus(ctx, migrator)
+}
+
+// Rollback reverts the newest migration, or all migrations after version when
+// it is positive, backing up the database to backupDir first.
+func Rollback(ctx context.Context, conn *sql.DB, backupDir string, version int64) (SchemaStatus, error) {
+	migrator, err := newMigrator(ctx, conn)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	status, err := schemaStatus(ctx, migrator)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	if err := checkNotTooNew(status); err != nil {
+		return status, err
+	}
+	if status.Current == 0 {
+		return status, fmt.Errorf("no migrations have been applied")
+	}
+	if version < 0 || version >= status.Current {
+		return status, fmt.Errorf("version %d is not below the current version %d", version, status.Current)
+	}
+
+	if err := backupBeforeMigration(ctx, conn, backupDir, status.Current); err != nil {
+		return status, err
+	}
+	if version > 0 {
+		_, err = migrator.DownTo(ctx, version)
+	} else {
+		_, err = migrator.Down(ctx)
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to roll back: %w", err)
+	}
+	return schemaStatus(ctx, migrator)
+}
+
+// backupBeforeMigration snapshots the database as mix.db.pre-migrate-<version>,
+// replacing an earlier snapshot of the same version.
+func backupBeforeMigration(ctx context.Context, conn *sql.DB, backupDir string, version int64) error {
+	if err := os.MkdirAll(backupDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	path := filepath.Join(backupDir, fmt.Sprintf("mix.db.pre-migrate-%d", version))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace old pre-migration backup: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database before migrating: %w", err)
+	}
+	logging.Info("Backed up database before migrating", "path", path, "version", version)
+	return nil
+}
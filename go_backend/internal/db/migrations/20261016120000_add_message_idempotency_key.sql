@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Client-supplied key so retried messages.send requests are not processed twice
+ALTER TABLE messages ADD COLUMN idempotency_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_session_idempotency_key
+ON messages (session_id, idempotency_key)
+WHERE idempotency_key IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_messages_session_idempotency_key;
+
+ALTER TABLE messages DROP COLUMN idempotency_key;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Audit trail of tool executions. Rows are kept when the session is deleted.
+CREATE TABLE IF NOT EXISTS tool_audits (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    tool_call_id TEXT NOT NULL,
+    tool_name TEXT NOT NULL,
+    input TEXT NOT NULL,
+    output TEXT NOT NULL,
+    output_truncated BOOLEAN NOT NULL DEFAULT 0,
+    is_error BOOLEAN NOT NULL DEFAULT 0,
+    error TEXT,
+    permission_decision TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    created_at INTEGER NOT NULL  -- Unix timestamp in seconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_audits_session_id ON tool_audits (session_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_tool_audits_tool_name ON tool_audits (tool_name, created_at);
+CREATE INDEX IF NOT EXISTS idx_tool_audits_created_at ON tool_audits (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tool_audits_created_at;
+DROP INDEX IF EXISTS idx_tool_audits_tool_name;
+DROP INDEX IF EXISTS idx_tool_audits_session_id;
+DROP TABLE IF EXISTS tool_audits;
+-- +goose StatementEnd
@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Reasoning is kept out of messages.parts so it is only returned when asked for.
+CREATE TABLE IF NOT EXISTS message_reasoning (
+    message_id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    thinking TEXT NOT NULL,
+    duration INTEGER NOT NULL DEFAULT 0,  -- Seconds
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_reasoning_session_id ON message_reasoning (session_id);
+
+INSERT INTO message_reasoning (message_id, session_id, thinking, duration, created_at, updated_at)
+SELECT
+    m.id,
+    m.session_id,
+    json_extract(p.value, '$.data.thinking'),
+    COALESCE(json_extract(p.value, '$.data.duration'), 0),
+    m.created_at,
+    m.updated_at
+FROM messages m, json_each(m.parts) p
+WHERE json_extract(p.value, '$.type') = 'reasoning'
+  AND COALESCE(json_extract(p.value, '$.data.thinking'), '') != '';
+
+UPDATE messages
+SET parts = (
+    SELECT json_group_array(json(p.value))
+    FROM json_each(messages.parts) p
+    WHERE json_extract(p.value, '$.type') != 'reasoning'
+)
+WHERE EXISTS (
+    SELECT 1
+    FROM json_each(messages.parts) p
+    WHERE json_extract(p.value, '$.type') = 'reasoning'
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE messages
+SET parts = (
+    SELECT json_group_array(json(v))
+    FROM (
+        SELECT json_object('type', 'reasoning', 'data', json_object('thinking', r.thinking, 'duration', r.duration)) AS v
+        FROM message_reasoning r
+        WHERE r.message_id = messages.id
+        UNION ALL
+        SELECT p.value AS v
+        FROM json_each(messages.parts) p
+    )
+)
+WHERE id IN (SELECT message_id FROM message_reasoning);
+
+DROP INDEX IF EXISTS idx_message_reasoning_session_id;
+DROP TABLE IF EXISTS message_reasoning;
+-- +goose StatementEnd
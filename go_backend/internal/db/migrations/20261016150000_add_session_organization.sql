@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';  -- JSON array of strings
+ALTER TABLE sessions ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN archived BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_archived ON sessions (archived);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_sessions_archived;
+ALTER TABLE sessions DROP COLUMN archived;
+ALTER TABLE sessions DROP COLUMN pinned;
+ALTER TABLE sessions DROP COLUMN tags;
+-- +goose StatementEnd
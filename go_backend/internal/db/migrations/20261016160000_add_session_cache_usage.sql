@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Running totals of prompt cache usage, for the per-session cache hit rate.
+CREATE TABLE IF NOT EXISTS session_cache_usage (
+    session_id TEXT PRIMARY KEY,
+    requests INTEGER NOT NULL DEFAULT 0,
+    input_tokens INTEGER NOT NULL DEFAULT 0,  -- Uncached input tokens
+    cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_cache_usage;
+-- +goose StatementEnd
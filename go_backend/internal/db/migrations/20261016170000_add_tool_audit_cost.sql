@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tool_audits ADD COLUMN cost REAL NOT NULL DEFAULT 0;  -- USD spent on paid APIs by the call
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tool_audits DROP COLUMN cost;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tools turned off for a session; every other tool is available.
+CREATE TABLE IF NOT EXISTS session_disabled_tools (
+    session_id TEXT NOT NULL,
+    tool_name TEXT NOT NULL,
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    PRIMARY KEY (session_id, tool_name),
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_disabled_tools;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- SSE events sent for agent requests, kept so reconnecting clients can replay
+-- what they missed. seq increases monotonically per session.
+CREATE TABLE IF NOT EXISTS stream_events (
+    session_id TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    request_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    data TEXT NOT NULL,  -- JSON payload of the SSE event
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    PRIMARY KEY (session_id, seq),
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stream_events;
+-- +goose StatementEnd
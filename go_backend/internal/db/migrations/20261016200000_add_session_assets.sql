@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Generated session files uploaded to artifact storage.
+CREATE TABLE IF NOT EXISTS session_assets (
+    session_id TEXT NOT NULL,
+    path TEXT NOT NULL,  -- Absolute local path the file was uploaded from
+    object_key TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    modified_at INTEGER NOT NULL,  -- Local modification time, Unix nanoseconds
+    uploaded_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    PRIMARY KEY (session_id, path),
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_assets_path ON session_assets (path);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_session_assets_path;
+DROP TABLE IF EXISTS session_assets;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Output profile selected for a session; sessions without one are unlimited.
+CREATE TABLE IF NOT EXISTS session_output_profiles (
+    session_id TEXT PRIMARY KEY,
+    profile TEXT NOT NULL,
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_output_profiles;
+-- +goose StatementEnd
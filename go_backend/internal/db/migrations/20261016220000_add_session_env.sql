@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Environment variables set for a session's bash commands and stdio MCP servers.
+CREATE TABLE IF NOT EXISTS session_env (
+    session_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    PRIMARY KEY (session_id, name),
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_env;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Locale selected for a session; sessions without one use the configured locale.
+CREATE TABLE IF NOT EXISTS session_locales (
+    session_id TEXT PRIMARY KEY,
+    locale TEXT NOT NULL,
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_locales;
+-- +goose StatementEnd
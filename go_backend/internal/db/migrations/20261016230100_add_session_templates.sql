@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Presets for starting sessions: a system prompt addendum, the tools to
+-- enable, a model and a working directory layout.
+CREATE TABLE IF NOT EXISTS session_templates (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    system_prompt TEXT NOT NULL DEFAULT '',
+    enabled_tools TEXT NOT NULL DEFAULT '[]',  -- JSON array; empty enables every tool
+    model TEXT NOT NULL DEFAULT '',
+    working_directory TEXT NOT NULL DEFAULT '',
+    directories TEXT NOT NULL DEFAULT '[]',  -- JSON array of paths created in the working directory
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    updated_at INTEGER NOT NULL  -- Unix timestamp in seconds
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Model and system prompt addendum of a session; sessions without a row use
+-- the agent's configured model and prompt.
+CREATE TABLE IF NOT EXISTS session_agent_settings (
+    session_id TEXT PRIMARY KEY,
+    model TEXT NOT NULL DEFAULT '',
+    system_prompt TEXT NOT NULL DEFAULT '',
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_agent_settings;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_templates;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Prompts the server runs on a cron schedule, each run in a new session.
+CREATE TABLE IF NOT EXISTS jobs (
+    name TEXT PRIMARY KEY,
+    schedule TEXT NOT NULL,  -- cron expression in the server's local time
+    prompt TEXT NOT NULL,
+    template TEXT NOT NULL DEFAULT '',  -- session template runs start from; empty for none
+    working_directory TEXT NOT NULL DEFAULT '',
+    last_run_at INTEGER NOT NULL DEFAULT 0,  -- Unix timestamp in seconds; 0 before the first run
+    last_session_id TEXT NOT NULL DEFAULT '',
+    last_status TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    updated_at INTEGER NOT NULL  -- Unix timestamp in seconds
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
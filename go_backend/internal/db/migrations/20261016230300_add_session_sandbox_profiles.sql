@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sandbox profile selected for a session's bash commands; sessions without one
+-- use the configured default.
+CREATE TABLE IF NOT EXISTS session_sandbox_profiles (
+    session_id TEXT PRIMARY KEY,
+    profile TEXT NOT NULL,
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_sandbox_profiles;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Feedback on a message: a thumbs up or down rating, a note and tags. Tags
+-- are a JSON array.
+CREATE TABLE IF NOT EXISTS message_annotations (
+    message_id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    rating TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    tags TEXT NOT NULL DEFAULT '[]',
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_annotations_session_id ON message_annotations (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_message_annotations_session_id;
+DROP TABLE IF EXISTS message_annotations;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The file changes of an agent turn. before_tree and after_tree are trees in
+-- the working directory's snapshot repository, taken before the turn's first
+-- tool that could write files and when the turn ended. files is a JSON array
+-- of the paths the turn changed, relative to the working directory.
+CREATE TABLE IF NOT EXISTS checkpoints (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    working_directory TEXT NOT NULL,
+    before_tree TEXT NOT NULL,
+    after_tree TEXT NOT NULL,
+    files TEXT NOT NULL DEFAULT '[]',
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    restored_at INTEGER,  -- Unix timestamp in seconds, null until restored
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkpoints_session_id ON checkpoints (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_checkpoints_session_id;
+DROP TABLE IF EXISTS checkpoints;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Reminders attached to every turn of a session, by name.
+CREATE TABLE IF NOT EXISTS session_reminders (
+    session_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    PRIMARY KEY (session_id, name),
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_reminders;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Files and messages kept in a session's context. target is the file path,
+-- relative to the session's working directory when inside it, or the message
+-- id.
+CREATE TABLE IF NOT EXISTS context_pins (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('file', 'message')),
+    target TEXT NOT NULL,
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    UNIQUE (session_id, kind, target),
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS context_pins;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Requests that had extended thinking enabled, and the budgets they were given
+ALTER TABLE session_cache_usage ADD COLUMN thinking_requests INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE session_cache_usage ADD COLUMN thinking_budget_tokens INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE session_cache_usage DROP COLUMN thinking_budget_tokens;
+ALTER TABLE session_cache_usage DROP COLUMN thinking_requests;
+-- +goose StatementEnd
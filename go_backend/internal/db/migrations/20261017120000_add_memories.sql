@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Long-term facts the agent saved with memory_write, injected into the system
+-- prompt of new sessions. User memories apply everywhere; workspace memories
+-- only to sessions in that working directory.
+CREATE TABLE IF NOT EXISTS memories (
+    id TEXT PRIMARY KEY,
+    scope TEXT NOT NULL CHECK (scope IN ('user', 'workspace')),
+    workspace TEXT NOT NULL DEFAULT '',  -- Working directory of workspace memories, '' for user memories
+    content TEXT NOT NULL,
+    session_id TEXT NOT NULL DEFAULT '',  -- Session the memory was written in; kept after it is deleted
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    UNIQUE (scope, workspace, content)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS memories;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Links granting read-only access to a session's transcript. A link's token
+-- is signed and carries the share's ID and expiry; deleting the row revokes it.
+CREATE TABLE IF NOT EXISTS session_shares (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    expires_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_shares_session_id ON session_shares (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_session_shares_session_id;
+DROP TABLE IF EXISTS session_shares;
+-- +goose StatementEnd
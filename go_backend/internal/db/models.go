@@ -8,6 +8,26 @@ import (
 	"database/sql"
 )
 
+type Checkpoint struct {
+	ID               string        `json:"id"`
+	SessionID        string        `json:"session_id"`
+	MessageID        string        `json:"message_id"`
+	WorkingDirectory string        `json:"working_directory"`
+	BeforeTree       string        `json:"before_tree"`
+	AfterTree        string        `json:"after_tree"`
+	Files            string        `json:"files"`
+	CreatedAt        int64         `json:"created_at"`
+	RestoredAt       sql.NullInt64 `json:"restored_at"`
+}
+
+type ContextPin struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Kind      string `json:"kind"`
+	Target    string `json:"target"`
+	CreatedAt int64  `json:"created_at"`
+}
+
 type File struct {
 	ID        string `json:"id"`
 	SessionID string `json:"session_id"`
@@ -18,15 +38,59 @@ type File struct {
 	UpdatedAt int64  `json:"updated_at"`
 }
 
+type Job struct {
+	Name             string `json:"name"`
+	Schedule         string `json:"schedule"`
+	Prompt           string `json:"prompt"`
+	Template         string `json:"template"`
+	WorkingDirectory string `json:"working_directory"`
+	LastRunAt        int64  `json:"last_run_at"`
+	LastSessionID    string `json:"last_session_id"`
+	LastStatus       string `json:"last_status"`
+	LastError        string `json:"last_error"`
+	CreatedAt        int64  `json:"created_at"`
+	UpdatedAt        int64  `json:"updated_at"`
+}
+
+type Memory struct {
+	ID        string `json:"id"`
+	Scope     string `json:"scope"`
+	Workspace string `json:"workspace"`
+	Content   string `json:"content"`
+	SessionID string `json:"session_id"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
 type Message struct {
-	ID         string         `json:"id"`
-	SessionID  string         `json:"session_id"`
-	Role       string         `json:"role"`
-	Parts      string         `json:"parts"`
-	Model      sql.NullString `json:"model"`
-	CreatedAt  int64          `json:"created_at"`
-	UpdatedAt  int64          `json:"updated_at"`
-	FinishedAt sql.NullInt64  `json:"finished_at"`
+	ID             string         `json:"id"`
+	SessionID      string         `json:"session_id"`
+	Role           string         `json:"role"`
+	Parts          string         `json:"parts"`
+	Model          sql.NullString `json:"model"`
+	CreatedAt      int64          `json:"created_at"`
+	UpdatedAt      int64          `json:"updated_at"`
+	FinishedAt     sql.NullInt64  `json:"finished_at"`
+	IdempotencyKey sql.NullString `json:"idempotency_key"`
+}
+
+type MessageAnnotation struct {
+	MessageID string `json:"message_id"`
+	SessionID string `json:"session_id"`
+	Rating    string `json:"rating"`
+	Note      string `json:"note"`
+	Tags      string `json:"tags"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type MessageReasoning struct {
+	MessageID string `json:"message_id"`
+	SessionID string `json:"session_id"`
+	Thinking  string `json:"thinking"`
+	Duration  int64  `json:"duration"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
 }
 
 type Session struct {
@@ -40,4 +104,116 @@ type Session struct {
 	CreatedAt        int64          `json:"created_at"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
 	WorkingDirectory sql.NullString `json:"working_directory"`
+	Tags             string         `json:"tags"`
+	Pinned           bool           `json:"pinned"`
+	Archived         bool           `json:"archived"`
+}
+
+type SessionAgentSetting struct {
+	SessionID    string `json:"session_id"`
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+type SessionAsset struct {
+	SessionID  string `json:"session_id"`
+	Path       string `json:"path"`
+	ObjectKey  string `json:"object_key"`
+	Size       int64  `json:"size"`
+	ModifiedAt int64  `json:"modified_at"`
+	UploadedAt int64  `json:"uploaded_at"`
+}
+
+type SessionCacheUsage struct {
+	SessionID            string `json:"session_id"`
+	Requests             int64  `json:"requests"`
+	InputTokens          int64  `json:"input_tokens"`
+	CacheCreationTokens  int64  `json:"cache_creation_tokens"`
+	CacheReadTokens      int64  `json:"cache_read_tokens"`
+	UpdatedAt            int64  `json:"updated_at"`
+	ThinkingRequests     int64  `json:"thinking_requests"`
+	ThinkingBudgetTokens int64  `json:"thinking_budget_tokens"`
+}
+
+type SessionDisabledTool struct {
+	SessionID string `json:"session_id"`
+	ToolName  string `json:"tool_name"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type SessionEnv struct {
+	SessionID string `json:"session_id"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type SessionLocale struct {
+	SessionID string `json:"session_id"`
+	Locale    string `json:"locale"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type SessionOutputProfile struct {
+	SessionID string `json:"session_id"`
+	Profile   string `json:"profile"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type SessionReminder struct {
+	SessionID string `json:"session_id"`
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type SessionSandboxProfile struct {
+	SessionID string `json:"session_id"`
+	Profile   string `json:"profile"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type SessionShare struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	ExpiresAt int64  `json:"expires_at"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type SessionTemplate struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	SystemPrompt     string `json:"system_prompt"`
+	EnabledTools     string `json:"enabled_tools"`
+	Model            string `json:"model"`
+	WorkingDirectory string `json:"working_directory"`
+	Directories      string `json:"directories"`
+	CreatedAt        int64  `json:"created_at"`
+	UpdatedAt        int64  `json:"updated_at"`
+}
+
+type StreamEvent struct {
+	SessionID string `json:"session_id"`
+	Seq       int64  `json:"seq"`
+	RequestID string `json:"request_id"`
+	Event     string `json:"event"`
+	Data      string `json:"data"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type ToolAudit struct {
+	ID                 string         `json:"id"`
+	SessionID          string         `json:"session_id"`
+	MessageID          string         `json:"message_id"`
+	ToolCallID         string         `json:"tool_call_id"`
+	ToolName           string         `json:"tool_name"`
+	Input              string         `json:"input"`
+	Output             string         `json:"output"`
+	OutputTruncated    bool           `json:"output_truncated"`
+	IsError            bool           `json:"is_error"`
+	Error              sql.NullString `json:"error"`
+	PermissionDecision string         `json:"permission_decision"`
+	DurationMs         int64          `json:"duration_ms"`
+	CreatedAt          int64          `json:"created_at"`
+	Cost               float64        `json:"cost"`
 }
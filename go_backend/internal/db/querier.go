@@ -9,27 +9,111 @@ import (
 )
 
 type Querier interface {
+	AddSessionCacheUsage(ctx context.Context, arg AddSessionCacheUsageParams) error
+	AddSessionCost(ctx context.Context, arg AddSessionCostParams) error
+	AddSessionReminder(ctx context.Context, arg AddSessionReminderParams) error
+	AppendStreamEvent(ctx context.Context, arg AppendStreamEventParams) (StreamEvent, error)
+	ClearMessageIdempotencyKey(ctx context.Context, id string) error
+	CopySessionAgentSettings(ctx context.Context, arg CopySessionAgentSettingsParams) error
+	CopySessionDisabledTools(ctx context.Context, arg CopySessionDisabledToolsParams) error
+	CopySessionEnv(ctx context.Context, arg CopySessionEnvParams) error
+	CopySessionLocale(ctx context.Context, arg CopySessionLocaleParams) error
+	CopySessionOutputProfile(ctx context.Context, arg CopySessionOutputProfileParams) error
+	CopySessionReminders(ctx context.Context, arg CopySessionRemindersParams) error
+	CopySessionSandboxProfile(ctx context.Context, arg CopySessionSandboxProfileParams) error
+	CreateCheckpoint(ctx context.Context, arg CreateCheckpointParams) (Checkpoint, error)
+	CreateContextPin(ctx context.Context, arg CreateContextPinParams) (ContextPin, error)
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
+	CreateJob(ctx context.Context, arg CreateJobParams) (Job, error)
+	CreateMemory(ctx context.Context, arg CreateMemoryParams) (Memory, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (CreateSessionRow, error)
+	CreateSessionShare(ctx context.Context, arg CreateSessionShareParams) (SessionShare, error)
+	CreateSessionTemplate(ctx context.Context, arg CreateSessionTemplateParams) (SessionTemplate, error)
+	CreateToolAudit(ctx context.Context, arg CreateToolAuditParams) (ToolAudit, error)
+	DeleteContextPin(ctx context.Context, id string) (int64, error)
+	DeleteExpiredSessionShares(ctx context.Context, expiresAt int64) error
 	DeleteFile(ctx context.Context, id string) error
+	DeleteJob(ctx context.Context, name string) error
+	DeleteMemory(ctx context.Context, id string) (int64, error)
 	DeleteMessage(ctx context.Context, id string) error
+	DeleteMessageAnnotation(ctx context.Context, messageID string) error
 	DeleteSession(ctx context.Context, id string) error
+	DeleteSessionEnvVar(ctx context.Context, arg DeleteSessionEnvVarParams) error
+	DeleteSessionLocale(ctx context.Context, sessionID string) error
+	DeleteSessionOutputProfile(ctx context.Context, sessionID string) error
+	DeleteSessionSandboxProfile(ctx context.Context, sessionID string) error
+	DeleteSessionShare(ctx context.Context, arg DeleteSessionShareParams) (int64, error)
+	DeleteSessionShares(ctx context.Context, sessionID string) (int64, error)
+	DeleteSessionTemplate(ctx context.Context, name string) error
+	DeleteStreamEventsBefore(ctx context.Context, arg DeleteStreamEventsBeforeParams) error
+	DisableSessionTool(ctx context.Context, arg DisableSessionToolParams) error
+	EnableSessionTool(ctx context.Context, arg EnableSessionToolParams) error
+	GetCheckpoint(ctx context.Context, id string) (Checkpoint, error)
 	GetFile(ctx context.Context, id string) (File, error)
 	GetFileByPathAndSession(ctx context.Context, arg GetFileByPathAndSessionParams) (File, error)
+	GetJob(ctx context.Context, name string) (Job, error)
+	GetLatestStreamEventSeq(ctx context.Context, sessionID string) (int64, error)
 	GetMessage(ctx context.Context, id string) (Message, error)
+	GetMessageAnnotation(ctx context.Context, messageID string) (MessageAnnotation, error)
+	GetMessageByIdempotencyKey(ctx context.Context, arg GetMessageByIdempotencyKeyParams) (Message, error)
+	GetMessageReasoning(ctx context.Context, messageID string) (MessageReasoning, error)
+	GetSessionAgentSettings(ctx context.Context, sessionID string) (GetSessionAgentSettingsRow, error)
+	GetSessionAssetByPath(ctx context.Context, path string) (SessionAsset, error)
 	GetSessionByID(ctx context.Context, id string) (GetSessionByIDRow, error)
+	GetSessionCacheUsage(ctx context.Context, sessionID string) (SessionCacheUsage, error)
+	GetSessionLocale(ctx context.Context, sessionID string) (string, error)
+	GetSessionOutputProfile(ctx context.Context, sessionID string) (string, error)
+	GetSessionSandboxProfile(ctx context.Context, sessionID string) (string, error)
+	GetSessionShare(ctx context.Context, id string) (SessionShare, error)
+	GetSessionTemplate(ctx context.Context, name string) (SessionTemplate, error)
+	GetToolAudit(ctx context.Context, id string) (ToolAudit, error)
+	ListCheckpointsBySession(ctx context.Context, sessionID string) ([]Checkpoint, error)
+	ListContextPinsBySession(ctx context.Context, sessionID string) ([]ContextPin, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
+	ListJobs(ctx context.Context) ([]Job, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
+	ListMemories(ctx context.Context) ([]Memory, error)
+	ListMemoriesForWorkspace(ctx context.Context, workspace string) ([]Memory, error)
+	ListMessageAnnotationsBySession(ctx context.Context, sessionID string) ([]MessageAnnotation, error)
+	ListMessageReasoningBySession(ctx context.Context, sessionID string) ([]MessageReasoning, error)
+	ListMessagesBefore(ctx context.Context, arg ListMessagesBeforeParams) ([]Message, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListMessagesForFork(ctx context.Context, arg ListMessagesForForkParams) ([]Message, error)
+	ListMessagesFrom(ctx context.Context, arg ListMessagesFromParams) ([]Message, error)
+	ListMessagesPage(ctx context.Context, arg ListMessagesPageParams) ([]Message, error)
+	ListSessionAssets(ctx context.Context, sessionID string) ([]SessionAsset, error)
+	ListSessionDisabledTools(ctx context.Context, sessionID string) ([]string, error)
+	ListSessionEnv(ctx context.Context, sessionID string) ([]SessionEnv, error)
+	ListSessionReminders(ctx context.Context, sessionID string) ([]string, error)
+	ListSessionShares(ctx context.Context, arg ListSessionSharesParams) ([]SessionShare, error)
+	ListSessionTemplates(ctx context.Context) ([]SessionTemplate, error)
 	ListSessionsMetadata(ctx context.Context) ([]ListSessionsMetadataRow, error)
-	ListSessionsWithContent(ctx context.Context) ([]ListSessionsWithContentRow, error)
+	ListSessionsWithContent(ctx context.Context, arg ListSessionsWithContentParams) ([]ListSessionsWithContentRow, error)
+	ListStreamEventsSince(ctx context.Context, arg ListStreamEventsSinceParams) ([]StreamEvent, error)
+	ListToolAudits(ctx context.Context, arg ListToolAuditsParams) ([]ToolAudit, error)
+	ListToolTimings(ctx context.Context, arg ListToolTimingsParams) ([]ListToolTimingsRow, error)
+	ListUnfinishedAssistantMessages(ctx context.Context, createdAt int64) ([]Message, error)
 	ListUserMessageHistory(ctx context.Context, arg ListUserMessageHistoryParams) ([]Message, error)
+	MarkCheckpointRestored(ctx context.Context, id string) error
+	PruneMemories(ctx context.Context, arg PruneMemoriesParams) error
+	RecordJobRun(ctx context.Context, arg RecordJobRunParams) error
+	RemoveSessionReminder(ctx context.Context, arg RemoveSessionReminderParams) error
+	SetSessionAgentSettings(ctx context.Context, arg SetSessionAgentSettingsParams) error
+	SetSessionEnvVar(ctx context.Context, arg SetSessionEnvVarParams) error
+	SetSessionLocale(ctx context.Context, arg SetSessionLocaleParams) error
+	SetSessionOutputProfile(ctx context.Context, arg SetSessionOutputProfileParams) error
+	SetSessionSandboxProfile(ctx context.Context, arg SetSessionSandboxProfileParams) error
+	SummarizeToolCosts(ctx context.Context, sessionID string) ([]SummarizeToolCostsRow, error)
 	UpdateFile(ctx context.Context, arg UpdateFileParams) (File, error)
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (UpdateSessionRow, error)
+	UpdateSessionOrganization(ctx context.Context, arg UpdateSessionOrganizationParams) error
+	UpdateSessionWorkingDirectory(ctx context.Context, arg UpdateSessionWorkingDirectoryParams) error
+	UpsertMessageAnnotation(ctx context.Context, arg UpsertMessageAnnotationParams) (MessageAnnotation, error)
+	UpsertMessageReasoning(ctx context.Context, arg UpsertMessageReasoningParams) error
+	UpsertSessionAsset(ctx context.Context, arg UpsertSessionAssetParams) error
 }
 
 var _ Querier = (*Queries)(nil)
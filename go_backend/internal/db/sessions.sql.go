@@ -10,6 +10,24 @@ import (
 	"database/sql"
 )
 
+const addSessionCost = `-- name: AddSessionCost :exec
+UPDATE sessions
+SET
+    cost = cost + ?,
+    updated_at = strftime('%s', 'now')
+WHERE id = ?
+`
+
+type AddSessionCostParams struct {
+	Cost float64 `json:"cost"`
+	ID   string  `json:"id"`
+}
+
+func (q *Queries) AddSessionCost(ctx context.Context, arg AddSessionCostParams) error {
+	_, err := q.exec(ctx, q.addSessionCostStmt, addSessionCost, arg.Cost, arg.ID)
+	return err
+}
+
 const createSession = `-- name: CreateSession :one
 INSERT INTO sessions (
     id,
@@ -43,7 +61,10 @@ INSERT INTO sessions (
     created_at, 
     updated_at,
     summary_message_id,
-    working_directory
+    working_directory,
+    tags,
+    pinned,
+    archived
 `
 
 type CreateSessionParams struct {
@@ -67,6 +88,9 @@ type CreateSessionRow struct {
 	UpdatedAt        int64          `json:"updated_at"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
 	WorkingDirectory sql.NullString `json:"working_directory"`
+	Tags             string         `json:"tags"`
+	Pinned           bool           `json:"pinned"`
+	Archived         bool           `json:"archived"`
 }
 
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (CreateSessionRow, error) {
@@ -91,6 +115,9 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (C
 		&i.UpdatedAt,
 		&i.SummaryMessageID,
 		&i.WorkingDirectory,
+		&i.Tags,
+		&i.Pinned,
+		&i.Archived,
 	)
 	return i, err
 }
@@ -117,6 +144,9 @@ SELECT
     s.updated_at,
     s.summary_message_id,
     s.working_directory,
+    s.tags,
+    s.pinned,
+    s.archived,
     COALESCE(counts.user_message_count, 0) as user_message_count,
     COALESCE(counts.assistant_message_count, 0) as assistant_message_count, 
     COALESCE(counts.tool_call_count, 0) as tool_call_count
@@ -142,6 +172,9 @@ type GetSessionByIDRow struct {
 	UpdatedAt             int64          `json:"updated_at"`
 	SummaryMessageID      sql.NullString `json:"summary_message_id"`
 	WorkingDirectory      sql.NullString `json:"working_directory"`
+	Tags                  string         `json:"tags"`
+	Pinned                bool           `json:"pinned"`
+	Archived              bool           `json:"archived"`
 	UserMessageCount      int64          `json:"user_message_count"`
 	AssistantMessageCount int64          `json:"assistant_message_count"`
 	ToolCallCount         int64          `json:"tool_call_count"`
@@ -161,6 +194,9 @@ func (q *Queries) GetSessionByID(ctx context.Context, id string) (GetSessionByID
 		&i.UpdatedAt,
 		&i.SummaryMessageID,
 		&i.WorkingDirectory,
+		&i.Tags,
+		&i.Pinned,
+		&i.Archived,
 		&i.UserMessageCount,
 		&i.AssistantMessageCount,
 		&i.ToolCallCount,
@@ -180,6 +216,9 @@ SELECT
     s.updated_at,
     s.summary_message_id,
     s.working_directory,
+    s.tags,
+    s.pinned,
+    s.archived,
     COALESCE(counts.user_message_count, 0) as user_message_count,
     COALESCE(counts.assistant_message_count, 0) as assistant_message_count, 
     COALESCE(counts.tool_call_count, 0) as tool_call_count
@@ -205,6 +244,9 @@ type ListSessionsMetadataRow struct {
 	UpdatedAt             int64          `json:"updated_at"`
 	SummaryMessageID      sql.NullString `json:"summary_message_id"`
 	WorkingDirectory      sql.NullString `json:"working_directory"`
+	Tags                  string         `json:"tags"`
+	Pinned                bool           `json:"pinned"`
+	Archived              bool           `json:"archived"`
 	UserMessageCount      int64          `json:"user_message_count"`
 	AssistantMessageCount int64          `json:"assistant_message_count"`
 	ToolCallCount         int64          `json:"tool_call_count"`
@@ -230,6 +272,9 @@ func (q *Queries) ListSessionsMetadata(ctx context.Context) ([]ListSessionsMetad
 			&i.UpdatedAt,
 			&i.SummaryMessageID,
 			&i.WorkingDirectory,
+			&i.Tags,
+			&i.Pinned,
+			&i.Archived,
 			&i.UserMessageCount,
 			&i.AssistantMessageCount,
 			&i.ToolCallCount,
@@ -259,6 +304,9 @@ SELECT
     s.updated_at,
     s.summary_message_id,
     s.working_directory,
+    s.tags,
+    s.pinned,
+    s.archived,
     COALESCE(first_msg.parts, '') as first_user_message,
     COALESCE(counts.user_message_count, 0) as user_message_count,
     COALESCE(counts.assistant_message_count, 0) as assistant_message_count, 
@@ -279,7 +327,10 @@ LEFT JOIN (
            COUNT(CASE WHEN role = 'tool' THEN 1 END) as tool_call_count
     FROM messages GROUP BY session_id
 ) counts ON s.id = counts.session_id
-ORDER BY s.created_at DESC
+WHERE (?1 IS NULL OR EXISTS (SELECT 1 FROM json_each(s.tags) WHERE json_each.value = ?1))
+  AND (?2 IS NULL OR s.pinned = ?2)
+  AND (?3 IS NULL OR s.archived = ?3)
+ORDER BY s.pinned DESC, s.created_at DESC
 `
 
 type ListSessionsWithContentRow struct {
@@ -293,14 +344,27 @@ type ListSessionsWithContentRow struct {
 	UpdatedAt             int64          `json:"updated_at"`
 	SummaryMessageID      sql.NullString `json:"summary_message_id"`
 	WorkingDirectory      sql.NullString `json:"working_directory"`
+	Tags                  string         `json:"tags"`
+	Pinned                bool           `json:"pinned"`
+	Archived              bool           `json:"archived"`
 	FirstUserMessage      string         `json:"first_user_message"`
 	UserMessageCount      int64          `json:"user_message_count"`
 	AssistantMessageCount int64          `json:"assistant_message_count"`
 	ToolCallCount         int64          `json:"tool_call_count"`
 }
 
-func (q *Queries) ListSessionsWithContent(ctx context.Context) ([]ListSessionsWithContentRow, error) {
-	rows, err := q.query(ctx, q.listSessionsWithContentStmt, listSessionsWithContent)
+type ListSessionsWithContentParams struct {
+	Tag      sql.NullString `json:"tag"`
+	Pinned   sql.NullBool   `json:"pinned"`
+	Archived sql.NullBool   `json:"archived"`
+}
+
+func (q *Queries) ListSessionsWithContent(ctx context.Context, arg ListSessionsWithContentParams) ([]ListSessionsWithContentRow, error) {
+	rows, err := q.query(ctx, q.listSessionsWithContentStmt, listSessionsWithContent,
+		arg.Tag,
+		arg.Pinned,
+		arg.Archived,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -319,6 +383,9 @@ func (q *Queries) ListSessionsWithContent(ctx context.Context) ([]ListSessionsWi
 			&i.UpdatedAt,
 			&i.SummaryMessageID,
 			&i.WorkingDirectory,
+			&i.Tags,
+			&i.Pinned,
+			&i.Archived,
 			&i.FirstUserMessage,
 			&i.UserMessageCount,
 			&i.AssistantMessageCount,
@@ -357,7 +424,10 @@ RETURNING
     created_at, 
     updated_at,
     summary_message_id,
-    working_directory
+    working_directory,
+    tags,
+    pinned,
+    archived
 `
 
 type UpdateSessionParams struct {
@@ -380,6 +450,9 @@ type UpdateSessionRow struct {
 	UpdatedAt        int64          `json:"updated_at"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
 	WorkingDirectory sql.NullString `json:"working_directory"`
+	Tags             string         `json:"tags"`
+	Pinned           bool           `json:"pinned"`
+	Archived         bool           `json:"archived"`
 }
 
 func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (UpdateSessionRow, error) {
@@ -403,6 +476,57 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (U
 		&i.UpdatedAt,
 		&i.SummaryMessageID,
 		&i.WorkingDirectory,
+		&i.Tags,
+		&i.Pinned,
+		&i.Archived,
 	)
 	return i, err
 }
+
+const updateSessionWorkingDirectory = `-- name: UpdateSessionWorkingDirectory :exec
+UPDATE sessions
+SET
+    working_directory = ?,
+    updated_at = strftime('%s', 'now')
+WHERE id = ?
+`
+
+type UpdateSessionWorkingDirectoryParams struct {
+	WorkingDirectory sql.NullString `json:"working_directory"`
+	ID               string         `json:"id"`
+}
+
+func (q *Queries) UpdateSessionWorkingDirectory(ctx context.Context, arg UpdateSessionWorkingDirectoryParams) error {
+	_, err := q.exec(ctx, q.updateSessionWorkingDirectoryStmt, updateSessionWorkingDirectory, arg.WorkingDirectory, arg.ID)
+	return err
+}
+
+const updateSessionOrganization = `-- name: UpdateSessionOrganization :exec
+UPDATE sessions
+SET
+    title = ?,
+    tags = ?,
+    pinned = ?,
+    archived = ?,
+    updated_at = strftime('%s', 'now')
+WHERE id = ?
+`
+
+type UpdateSessionOrganizationParams struct {
+	Title    string `json:"title"`
+	Tags     string `json:"tags"`
+	Pinned   bool   `json:"pinned"`
+	Archived bool   `json:"archived"`
+	ID       string `json:"id"`
+}
+
+func (q *Queries) UpdateSessionOrganization(ctx context.Context, arg UpdateSessionOrganizationParams) error {
+	_, err := q.exec(ctx, q.updateSessionOrganizationStmt, updateSessionOrganization,
+		arg.Title,
+		arg.Tags,
+		arg.Pinned,
+		arg.Archived,
+		arg.ID,
+	)
+	return err
+}
@@ -0,0 +1,30 @@
+-- name: CreateCheckpoint :one
+INSERT INTO checkpoints (
+    id,
+    session_id,
+    message_id,
+    working_directory,
+    before_tree,
+    after_tree,
+    files,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetCheckpoint :one
+SELECT *
+FROM checkpoints
+WHERE id = ? LIMIT 1;
+
+-- name: ListCheckpointsBySession :many
+SELECT *
+FROM checkpoints
+WHERE session_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: MarkCheckpointRestored :exec
+UPDATE checkpoints
+SET restored_at = strftime('%s', 'now')
+WHERE id = ?;
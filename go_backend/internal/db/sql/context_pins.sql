@@ -0,0 +1,22 @@
+-- name: CreateContextPin :one
+INSERT INTO context_pins (
+    id,
+    session_id,
+    kind,
+    target,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id, kind, target) DO UPDATE SET target = excluded.target
+RETURNING *;
+
+-- name: ListContextPinsBySession :many
+SELECT *
+FROM context_pins
+WHERE session_id = ?
+ORDER BY created_at, rowid;
+
+-- name: DeleteContextPin :execrows
+DELETE FROM context_pins
+WHERE id = ?;
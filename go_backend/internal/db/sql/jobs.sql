@@ -0,0 +1,37 @@
+-- name: CreateJob :one
+INSERT INTO jobs (
+    name,
+    schedule,
+    prompt,
+    template,
+    working_directory,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetJob :one
+SELECT *
+FROM jobs
+WHERE name = ?;
+
+-- name: ListJobs :many
+SELECT *
+FROM jobs
+ORDER BY name;
+
+-- name: RecordJobRun :exec
+UPDATE jobs
+SET
+    last_run_at = ?,
+    last_session_id = ?,
+    last_status = ?,
+    last_error = ?,
+    updated_at = strftime('%s', 'now')
+WHERE name = ?;
+
+-- name: DeleteJob :exec
+DELETE FROM jobs
+WHERE name = ?;
@@ -0,0 +1,41 @@
+-- name: CreateMemory :one
+INSERT INTO memories (
+    id,
+    scope,
+    workspace,
+    content,
+    session_id,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+ON CONFLICT (scope, workspace, content) DO UPDATE SET
+    session_id = excluded.session_id,
+    updated_at = excluded.updated_at
+RETURNING *;
+
+-- name: ListMemories :many
+SELECT *
+FROM memories
+ORDER BY updated_at DESC, rowid DESC;
+
+-- name: ListMemoriesForWorkspace :many
+SELECT *
+FROM memories
+WHERE scope = 'user' OR (scope = 'workspace' AND workspace = ?)
+ORDER BY updated_at DESC, rowid DESC;
+
+-- name: DeleteMemory :execrows
+DELETE FROM memories
+WHERE id = ?;
+
+-- name: PruneMemories :exec
+DELETE FROM memories
+WHERE scope = ?1 AND workspace = ?2 AND id NOT IN (
+    SELECT id
+    FROM memories
+    WHERE scope = ?1 AND workspace = ?2
+    ORDER BY updated_at DESC, rowid DESC
+    LIMIT ?3
+);
@@ -0,0 +1,33 @@
+-- name: UpsertMessageAnnotation :one
+INSERT INTO message_annotations (
+    message_id,
+    session_id,
+    rating,
+    note,
+    tags,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+ON CONFLICT (message_id) DO UPDATE SET
+    rating = excluded.rating,
+    note = excluded.note,
+    tags = excluded.tags,
+    updated_at = strftime('%s', 'now')
+RETURNING *;
+
+-- name: GetMessageAnnotation :one
+SELECT *
+FROM message_annotations
+WHERE message_id = ? LIMIT 1;
+
+-- name: ListMessageAnnotationsBySession :many
+SELECT *
+FROM message_annotations
+WHERE session_id = ?
+ORDER BY created_at ASC;
+
+-- name: DeleteMessageAnnotation :exec
+DELETE FROM message_annotations
+WHERE message_id = ?;
@@ -0,0 +1,25 @@
+-- name: UpsertMessageReasoning :exec
+INSERT INTO message_reasoning (
+    message_id,
+    session_id,
+    thinking,
+    duration,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+ON CONFLICT (message_id) DO UPDATE SET
+    thinking = excluded.thinking,
+    duration = excluded.duration,
+    updated_at = strftime('%s', 'now');
+
+-- name: GetMessageReasoning :one
+SELECT *
+FROM message_reasoning
+WHERE message_id = ? LIMIT 1;
+
+-- name: ListMessageReasoningBySession :many
+SELECT *
+FROM message_reasoning
+WHERE session_id = ?;
@@ -3,11 +3,38 @@ SELECT *
 FROM messages
 WHERE id = ? LIMIT 1;
 
+-- name: GetMessageByIdempotencyKey :one
+SELECT *
+FROM messages
+WHERE session_id = ? AND idempotency_key = ? LIMIT 1;
+
 -- name: ListMessagesBySession :many
 SELECT *
 FROM messages
 WHERE session_id = ?
-ORDER BY created_at ASC;
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: ListMessagesPage :many
+SELECT *
+FROM messages
+WHERE session_id = ?
+ORDER BY created_at ASC, rowid ASC
+LIMIT ? OFFSET ?;
+
+-- name: ListMessagesBefore :many
+SELECT *
+FROM messages
+WHERE session_id = ?1
+  AND (?2 = '' OR (created_at, rowid) < (SELECT m.created_at, m.rowid FROM messages m WHERE m.id = ?2))
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?3;
+
+-- name: ListMessagesFrom :many
+SELECT *
+FROM messages
+WHERE session_id = ?
+  AND (created_at, rowid) >= (SELECT m.created_at, m.rowid FROM messages m WHERE m.id = ?)
+ORDER BY created_at ASC, rowid ASC;
 
 -- name: CreateMessage :one
 INSERT INTO messages (
@@ -16,10 +43,11 @@ INSERT INTO messages (
     role,
     parts,
     model,
+    idempotency_key,
     created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
 RETURNING *;
 
@@ -32,11 +60,22 @@ SET
 WHERE id = ?;
 
 
+-- name: ClearMessageIdempotencyKey :exec
+UPDATE messages
+SET idempotency_key = NULL
+WHERE id = ?;
+
 -- name: DeleteMessage :exec
 DELETE FROM messages
 WHERE id = ?;
 
 
+-- name: ListUnfinishedAssistantMessages :many
+SELECT *
+FROM messages
+WHERE role = 'assistant' AND finished_at IS NULL AND created_at < ?
+ORDER BY created_at ASC;
+
 -- name: ListUserMessageHistory :many
 SELECT *
 FROM messages
@@ -48,5 +87,5 @@ LIMIT ? OFFSET ?;
 SELECT *
 FROM messages
 WHERE session_id = ?
-ORDER BY created_at ASC
+ORDER BY created_at ASC, rowid ASC
 LIMIT ?;
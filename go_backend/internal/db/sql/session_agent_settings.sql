@@ -0,0 +1,24 @@
+-- name: GetSessionAgentSettings :one
+SELECT model, system_prompt
+FROM session_agent_settings
+WHERE session_id = ?;
+
+-- name: SetSessionAgentSettings :exec
+INSERT INTO session_agent_settings (
+    session_id,
+    model,
+    system_prompt,
+    updated_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    model = excluded.model,
+    system_prompt = excluded.system_prompt,
+    updated_at = excluded.updated_at;
+
+-- name: CopySessionAgentSettings :exec
+INSERT INTO session_agent_settings (session_id, model, system_prompt, updated_at)
+SELECT sqlc.arg('target_session_id'), model, system_prompt, strftime('%s', 'now')
+FROM session_agent_settings
+WHERE session_id = sqlc.arg('source_session_id');
@@ -0,0 +1,29 @@
+-- name: UpsertSessionAsset :exec
+INSERT INTO session_assets (
+    session_id,
+    path,
+    object_key,
+    size,
+    modified_at,
+    uploaded_at
+) VALUES (
+    ?, ?, ?, ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id, path) DO UPDATE SET
+    object_key = excluded.object_key,
+    size = excluded.size,
+    modified_at = excluded.modified_at,
+    uploaded_at = excluded.uploaded_at;
+
+-- name: ListSessionAssets :many
+SELECT session_id, path, object_key, size, modified_at, uploaded_at
+FROM session_assets
+WHERE session_id = ?
+ORDER BY path;
+
+-- name: GetSessionAssetByPath :one
+SELECT session_id, path, object_key, size, modified_at, uploaded_at
+FROM session_assets
+WHERE path = ?
+ORDER BY uploaded_at DESC
+LIMIT 1;
@@ -0,0 +1,26 @@
+-- name: AddSessionCacheUsage :exec
+INSERT INTO session_cache_usage (
+    session_id,
+    requests,
+    input_tokens,
+    cache_creation_tokens,
+    cache_read_tokens,
+    thinking_requests,
+    thinking_budget_tokens,
+    updated_at
+) VALUES (
+    ?, 1, ?, ?, ?, ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    requests = requests + 1,
+    input_tokens = input_tokens + excluded.input_tokens,
+    cache_creation_tokens = cache_creation_tokens + excluded.cache_creation_tokens,
+    cache_read_tokens = cache_read_tokens + excluded.cache_read_tokens,
+    thinking_requests = thinking_requests + excluded.thinking_requests,
+    thinking_budget_tokens = thinking_budget_tokens + excluded.thinking_budget_tokens,
+    updated_at = strftime('%s', 'now');
+
+-- name: GetSessionCacheUsage :one
+SELECT *
+FROM session_cache_usage
+WHERE session_id = ? LIMIT 1;
@@ -0,0 +1,25 @@
+-- name: DisableSessionTool :exec
+INSERT INTO session_disabled_tools (
+    session_id,
+    tool_name,
+    created_at
+) VALUES (
+    ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id, tool_name) DO NOTHING;
+
+-- name: EnableSessionTool :exec
+DELETE FROM session_disabled_tools
+WHERE session_id = ? AND tool_name = ?;
+
+-- name: ListSessionDisabledTools :many
+SELECT tool_name
+FROM session_disabled_tools
+WHERE session_id = ?
+ORDER BY tool_name;
+
+-- name: CopySessionDisabledTools :exec
+INSERT INTO session_disabled_tools (session_id, tool_name, created_at)
+SELECT sqlc.arg('target_session_id'), tool_name, strftime('%s', 'now')
+FROM session_disabled_tools
+WHERE session_id = sqlc.arg('source_session_id');
@@ -0,0 +1,28 @@
+-- name: ListSessionEnv :many
+SELECT session_id, name, value, updated_at
+FROM session_env
+WHERE session_id = ?
+ORDER BY name;
+
+-- name: SetSessionEnvVar :exec
+INSERT INTO session_env (
+    session_id,
+    name,
+    value,
+    updated_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id, name) DO UPDATE SET
+    value = excluded.value,
+    updated_at = excluded.updated_at;
+
+-- name: DeleteSessionEnvVar :exec
+DELETE FROM session_env
+WHERE session_id = ? AND name = ?;
+
+-- name: CopySessionEnv :exec
+INSERT INTO session_env (session_id, name, value, updated_at)
+SELECT sqlc.arg('target_session_id'), name, value, strftime('%s', 'now')
+FROM session_env
+WHERE session_id = sqlc.arg('source_session_id');
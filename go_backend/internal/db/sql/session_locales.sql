@@ -0,0 +1,26 @@
+-- name: GetSessionLocale :one
+SELECT locale
+FROM session_locales
+WHERE session_id = ?;
+
+-- name: SetSessionLocale :exec
+INSERT INTO session_locales (
+    session_id,
+    locale,
+    updated_at
+) VALUES (
+    ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    locale = excluded.locale,
+    updated_at = excluded.updated_at;
+
+-- name: DeleteSessionLocale :exec
+DELETE FROM session_locales
+WHERE session_id = ?;
+
+-- name: CopySessionLocale :exec
+INSERT INTO session_locales (session_id, locale, updated_at)
+SELECT sqlc.arg('target_session_id'), locale, strftime('%s', 'now')
+FROM session_locales
+WHERE session_id = sqlc.arg('source_session_id');
@@ -0,0 +1,26 @@
+-- name: GetSessionOutputProfile :one
+SELECT profile
+FROM session_output_profiles
+WHERE session_id = ?;
+
+-- name: SetSessionOutputProfile :exec
+INSERT INTO session_output_profiles (
+    session_id,
+    profile,
+    updated_at
+) VALUES (
+    ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    profile = excluded.profile,
+    updated_at = excluded.updated_at;
+
+-- name: DeleteSessionOutputProfile :exec
+DELETE FROM session_output_profiles
+WHERE session_id = ?;
+
+-- name: CopySessionOutputProfile :exec
+INSERT INTO session_output_profiles (session_id, profile, updated_at)
+SELECT sqlc.arg('target_session_id'), profile, strftime('%s', 'now')
+FROM session_output_profiles
+WHERE session_id = sqlc.arg('source_session_id');
@@ -0,0 +1,25 @@
+-- name: AddSessionReminder :exec
+INSERT INTO session_reminders (
+    session_id,
+    name,
+    created_at
+) VALUES (
+    ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id, name) DO NOTHING;
+
+-- name: RemoveSessionReminder :exec
+DELETE FROM session_reminders
+WHERE session_id = ? AND name = ?;
+
+-- name: ListSessionReminders :many
+SELECT name
+FROM session_reminders
+WHERE session_id = ?
+ORDER BY name;
+
+-- name: CopySessionReminders :exec
+INSERT INTO session_reminders (session_id, name, created_at)
+SELECT sqlc.arg('target_session_id'), name, strftime('%s', 'now')
+FROM session_reminders
+WHERE session_id = sqlc.arg('source_session_id');
@@ -0,0 +1,26 @@
+-- name: GetSessionSandboxProfile :one
+SELECT profile
+FROM session_sandbox_profiles
+WHERE session_id = ?;
+
+-- name: SetSessionSandboxProfile :exec
+INSERT INTO session_sandbox_profiles (
+    session_id,
+    profile,
+    updated_at
+) VALUES (
+    ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    profile = excluded.profile,
+    updated_at = excluded.updated_at;
+
+-- name: DeleteSessionSandboxProfile :exec
+DELETE FROM session_sandbox_profiles
+WHERE session_id = ?;
+
+-- name: CopySessionSandboxProfile :exec
+INSERT INTO session_sandbox_profiles (session_id, profile, updated_at)
+SELECT sqlc.arg('target_session_id'), profile, strftime('%s', 'now')
+FROM session_sandbox_profiles
+WHERE session_id = sqlc.arg('source_session_id');
@@ -0,0 +1,33 @@
+-- name: CreateSessionShare :one
+INSERT INTO session_shares (
+    id,
+    session_id,
+    expires_at,
+    created_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetSessionShare :one
+SELECT *
+FROM session_shares
+WHERE id = ? LIMIT 1;
+
+-- name: ListSessionShares :many
+SELECT *
+FROM session_shares
+WHERE session_id = ? AND expires_at > ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: DeleteSessionShare :execrows
+DELETE FROM session_shares
+WHERE id = ? AND session_id = ?;
+
+-- name: DeleteSessionShares :execrows
+DELETE FROM session_shares
+WHERE session_id = ?;
+
+-- name: DeleteExpiredSessionShares :exec
+DELETE FROM session_shares
+WHERE expires_at <= ?;
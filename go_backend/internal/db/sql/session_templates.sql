@@ -0,0 +1,29 @@
+-- name: CreateSessionTemplate :one
+INSERT INTO session_templates (
+    name,
+    description,
+    system_prompt,
+    enabled_tools,
+    model,
+    working_directory,
+    directories,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetSessionTemplate :one
+SELECT *
+FROM session_templates
+WHERE name = ?;
+
+-- name: ListSessionTemplates :many
+SELECT *
+FROM session_templates
+ORDER BY name;
+
+-- name: DeleteSessionTemplate :exec
+DELETE FROM session_templates
+WHERE name = ?;
@@ -31,7 +31,10 @@ INSERT INTO sessions (
     created_at, 
     updated_at,
     summary_message_id,
-    working_directory;
+    working_directory,
+    tags,
+    pinned,
+    archived;
 
 -- name: GetSessionByID :one
 SELECT 
@@ -45,6 +48,9 @@ SELECT
     s.updated_at,
     s.summary_message_id,
     s.working_directory,
+    s.tags,
+    s.pinned,
+    s.archived,
     COALESCE(counts.user_message_count, 0) as user_message_count,
     COALESCE(counts.assistant_message_count, 0) as assistant_message_count, 
     COALESCE(counts.tool_call_count, 0) as tool_call_count
@@ -70,6 +76,9 @@ SELECT
     s.updated_at,
     s.summary_message_id,
     s.working_directory,
+    s.tags,
+    s.pinned,
+    s.archived,
     COALESCE(counts.user_message_count, 0) as user_message_count,
     COALESCE(counts.assistant_message_count, 0) as assistant_message_count, 
     COALESCE(counts.tool_call_count, 0) as tool_call_count
@@ -95,6 +104,9 @@ SELECT
     s.updated_at,
     s.summary_message_id,
     s.working_directory,
+    s.tags,
+    s.pinned,
+    s.archived,
     COALESCE(first_msg.parts, '') as first_user_message,
     COALESCE(counts.user_message_count, 0) as user_message_count,
     COALESCE(counts.assistant_message_count, 0) as assistant_message_count, 
@@ -115,7 +127,10 @@ LEFT JOIN (
            COUNT(CASE WHEN role = 'tool' THEN 1 END) as tool_call_count
     FROM messages GROUP BY session_id
 ) counts ON s.id = counts.session_id
-ORDER BY s.created_at DESC;
+WHERE (sqlc.narg('tag') IS NULL OR EXISTS (SELECT 1 FROM json_each(s.tags) WHERE json_each.value = sqlc.narg('tag')))
+  AND (sqlc.narg('pinned') IS NULL OR s.pinned = sqlc.narg('pinned'))
+  AND (sqlc.narg('archived') IS NULL OR s.archived = sqlc.narg('archived'))
+ORDER BY s.pinned DESC, s.created_at DESC;
 
 -- name: UpdateSession :one
 UPDATE sessions
@@ -137,8 +152,36 @@ RETURNING
     created_at, 
     updated_at,
     summary_message_id,
-    working_directory;
+    working_directory,
+    tags,
+    pinned,
+    archived;
+
+
+-- name: UpdateSessionWorkingDirectory :exec
+UPDATE sessions
+SET
+    working_directory = ?,
+    updated_at = strftime('%s', 'now')
+WHERE id = ?;
+
 
+-- name: UpdateSessionOrganization :exec
+UPDATE sessions
+SET
+    title = ?,
+    tags = ?,
+    pinned = ?,
+    archived = ?,
+    updated_at = strftime('%s', 'now')
+WHERE id = ?;
+
+-- name: AddSessionCost :exec
+UPDATE sessions
+SET
+    cost = cost + ?,
+    updated_at = strftime('%s', 'now')
+WHERE id = ?;
 
 -- name: DeleteSession :exec
 DELETE FROM sessions
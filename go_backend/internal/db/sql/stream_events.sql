@@ -0,0 +1,24 @@
+-- name: AppendStreamEvent :one
+INSERT INTO stream_events (session_id, seq, request_id, event, data, created_at)
+SELECT sqlc.arg('session_id'), COALESCE(MAX(seq), 0) + 1, sqlc.arg('request_id'), sqlc.arg('event'), sqlc.arg('data'), strftime('%s', 'now')
+FROM stream_events
+WHERE session_id = sqlc.arg('session_id')
+RETURNING session_id, seq, request_id, event, data, created_at;
+
+-- name: GetLatestStreamEventSeq :one
+SELECT CAST(COALESCE(MAX(seq), 0) AS INTEGER) AS seq
+FROM stream_events
+WHERE session_id = ?;
+
+-- name: ListStreamEventsSince :many
+SELECT session_id, seq, request_id, event, data, created_at
+FROM stream_events
+WHERE session_id = ? AND seq > ?
+ORDER BY seq;
+
+-- name: DeleteStreamEventsBefore :exec
+-- Keeps the session's newest event so its sequence never restarts.
+DELETE FROM stream_events
+WHERE session_id = sqlc.arg('session_id')
+  AND created_at < sqlc.arg('before')
+  AND seq < (SELECT MAX(seq) FROM stream_events AS newest WHERE newest.session_id = sqlc.arg('session_id'));
@@ -0,0 +1,53 @@
+-- name: CreateToolAudit :one
+INSERT INTO tool_audits (
+    id,
+    session_id,
+    message_id,
+    tool_call_id,
+    tool_name,
+    input,
+    output,
+    output_truncated,
+    is_error,
+    error,
+    permission_decision,
+    duration_ms,
+    cost,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetToolAudit :one
+SELECT *
+FROM tool_audits
+WHERE id = ? LIMIT 1;
+
+-- name: ListToolAudits :many
+SELECT *
+FROM tool_audits
+WHERE (sqlc.narg('session_id') IS NULL OR session_id = sqlc.narg('session_id'))
+  AND (sqlc.narg('tool_name') IS NULL OR tool_name = sqlc.narg('tool_name'))
+  AND (sqlc.narg('since') IS NULL OR created_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until') IS NULL OR created_at <= sqlc.narg('until'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SummarizeToolCosts :many
+SELECT
+    tool_name,
+    COUNT(*) AS calls,
+    CAST(SUM(cost) AS REAL) AS cost
+FROM tool_audits
+WHERE session_id = ? AND cost > 0
+GROUP BY tool_name
+ORDER BY cost DESC;
+
+-- name: ListToolTimings :many
+SELECT tool_name, duration_ms, is_error, permission_decision
+FROM tool_audits
+WHERE (sqlc.narg('session_id') IS NULL OR session_id = sqlc.narg('session_id'))
+  AND (sqlc.narg('since') IS NULL OR created_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until') IS NULL OR created_at <= sqlc.narg('until'))
+ORDER BY tool_name, duration_ms;
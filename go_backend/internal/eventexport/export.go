@@ -0,0 +1,279 @@
+// Package eventexport streams agent events to an external sink, a JSON lines
+// file, a NATS subject or a Kafka topic, so teams can build analytics without
+// polling the API or scraping logs. Records follow the Record schema; message
+// text and tool inputs are left out unless the export includes content.
+package eventexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/agent"
+	"mix/internal/logging"
+	"mix/internal/message"
+	"mix/internal/pubsub"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is incremented when a field of Record changes meaning or is
+// removed; added fields don't change it.
+const SchemaVersion = 1
+
+const (
+	// queueSize bounds the records waiting for the sink; more are dropped
+	queueSize = 4096
+	// batchSize and flushInterval bound how long a record waits in a batch
+	batchSize     = 100
+	flushInterval = time.Second
+)
+
+// Record is one exported agent event.
+type Record struct {
+	Schema int    `json:"schema"`
+	ID     string `json:"id"`
+	// Time is when the event was exported, in RFC 3339 format
+	Time      time.Time `json:"time"`
+	Agent     string    `json:"agent"`
+	Type      string    `json:"type"`
+	SessionID string    `json:"sessionId,omitempty"`
+	// Done marks the last event of a response or summary, including the
+	// error a failed run ends with
+	Done     bool           `json:"done"`
+	Message  *MessageRecord `json:"message,omitempty"`
+	Usage    *UsageRecord   `json:"usage,omitempty"`
+	Progress string         `json:"progress,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// MessageRecord describes the message an event refers to. Content is only
+// set when the export includes content; reasoning is never exported.
+type MessageRecord struct {
+	ID            string           `json:"id"`
+	Role          string           `json:"role"`
+	Provider      string           `json:"provider,omitempty"`
+	Model         string           `json:"model,omitempty"`
+	FinishReason  string           `json:"finishReason,omitempty"`
+	ContentLength int              `json:"contentLength"`
+	Content       string           `json:"content,omitempty"`
+	ToolCalls     []ToolCallRecord `json:"toolCalls,omitempty"`
+	Cost          float64          `json:"cost,omitempty"`
+}
+
+// ToolCallRecord is a tool call of the message. Input is only set when the
+// export includes content.
+type ToolCallRecord struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Finished bool   `json:"finished"`
+	Input    string `json:"input,omitempty"`
+}
+
+// UsageRecord is the running estimate sent while a response streams.
+type UsageRecord struct {
+	OutputTokens int64   `json:"outputTokens"`
+	Cost         float64 `json:"cost"`
+	SessionCost  float64 `json:"sessionCost"`
+}
+
+// sink delivers encoded records in order.
+type sink interface {
+	Write(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// Exporter forwards the events of an agent to a sink in the background.
+type Exporter struct {
+	sink           sink
+	agentName      string
+	includeContent bool
+	queue          chan Record
+
+	cancel  context.CancelFunc
+	done    sync.WaitGroup
+	dropped int
+}
+
+// Start exports the events of the named agent to the sink in cfg until
+// Shutdown.
+func Start(cfg config.EventExportConfig, agentName config.AgentName, events pubsub.Suscriber[agent.AgentEvent]) (*Exporter, error) {
+	s, err := newSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Exporter{
+		sink:           s,
+		agentName:      string(agentName),
+		includeContent: cfg.IncludeContent,
+		queue:          make(chan Record, queueSize),
+		cancel:         cancel,
+	}
+
+	subscription := events.Subscribe(ctx)
+	e.done.Add(2)
+	go e.collect(subscription)
+	go e.deliver()
+	logging.Info("Exporting agent events", "sink", cfg.Sink, "agent", agentName)
+	return e, nil
+}
+
+func newSink(cfg config.EventExportConfig) (sink, error) {
+	switch cfg.Sink {
+	case config.EventSinkFile:
+		return newFileSink(cfg.Path)
+	case config.EventSinkNATS:
+		return newNATSSink(cfg.URL, cfg.Topic), nil
+	case config.EventSinkKafka:
+		return newKafkaSink(cfg.URL, cfg.Topic), nil
+	}
+	return nil, fmt.Errorf("unknown event export sink %q", cfg.Sink)
+}
+
+// collect converts events to records as fast as they arrive, so the agent's
+// broker never drops them for a slow sink; the queue drops instead.
+func (e *Exporter) collect(events <-chan pubsub.Event[agent.AgentEvent]) {
+	defer e.done.Done()
+	defer close(e.queue)
+	defer logging.RecoverPanic("event-export", nil)
+	for event := range events {
+		// Streamed tool arguments are a preview; the finished calls are
+		// exported with the response
+		if event.Payload.Type == agent.AgentEventTypeToolInput {
+			continue
+		}
+		select {
+		case e.queue <- e.record(event.Payload):
+		default:
+			e.dropped++
+			if e.dropped == 1 || e.dropped%1000 == 0 {
+				logging.Warn("Event export queue is full, dropping events", "dropped", e.dropped)
+			}
+		}
+	}
+}
+
+// deliver writes queued records to the sink in batches.
+func (e *Exporter) deliver() {
+	defer e.done.Done()
+	defer logging.RecoverPanic("event-export", nil)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]Record, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := e.sink.Write(ctx, batch); err != nil {
+			logging.Error("Failed to export agent events", "records", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case record, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown stops exporting, delivers the queued records and closes the sink.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.cancel()
+	delivered := make(chan struct{})
+	go func() {
+		e.done.Wait()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.sink.Close()
+}
+
+func (e *Exporter) record(event agent.AgentEvent) Record {
+	record := Record{
+		Schema:    SchemaVersion,
+		ID:        uuid.New().String(),
+		Time:      time.Now().UTC(),
+		Agent:     e.agentName,
+		Type:      string(event.Type),
+		SessionID: event.SessionID,
+		Done:      event.Done,
+		Progress:  event.Progress,
+	}
+	if event.Error != nil {
+		record.Error = event.Error.Error()
+	}
+	if event.Usage != nil {
+		record.Usage = &UsageRecord{
+			OutputTokens: event.Usage.OutputTokens,
+			Cost:         event.Usage.Cost,
+			SessionCost:  event.Usage.SessionCost,
+		}
+	}
+	if msg := event.Message; msg.ID != "" {
+		if record.SessionID == "" {
+			record.SessionID = msg.SessionID
+		}
+		record.Message = e.messageRecord(msg)
+	}
+	return record
+}
+
+func (e *Exporter) messageRecord(msg message.Message) *MessageRecord {
+	provider, model := msg.AnsweredBy()
+	content := msg.Content().String()
+	record := &MessageRecord{
+		ID:            msg.ID,
+		Role:          string(msg.Role),
+		Provider:      string(provider),
+		Model:         string(model),
+		FinishReason:  string(msg.FinishReason()),
+		ContentLength: len(content),
+		Cost:          msg.Cost(),
+	}
+	if e.includeContent {
+		record.Content = content
+	}
+	for _, call := range msg.ToolCalls() {
+		toolCall := ToolCallRecord{ID: call.ID, Name: call.Name, Finished: call.Finished}
+		if e.includeContent {
+			toolCall.Input = call.Input
+		}
+		record.ToolCalls = append(record.ToolCalls, toolCall)
+	}
+	return record
+}
+
+// encodeLines encodes records as JSON lines.
+func encodeLines(records []Record) ([]byte, error) {
+	var data []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return data, nil
+}
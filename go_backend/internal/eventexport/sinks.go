@@ -0,0 +1,236 @@
+package eventexport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/logging"
+)
+
+// fileSink appends records to a JSON lines file.
+type fileSink struct {
+	file *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create event export directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event export file: %w", err)
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) Write(ctx context.Context, records []Record) error {
+	data, err := encodeLines(records)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(data)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+// natsSink publishes each record to a NATS subject with the core NATS text
+// protocol. It connects lazily and reconnects after a failed write; TLS
+// servers are not supported.
+type natsSink struct {
+	url     string
+	subject string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+const natsDialTimeout = 10 * time.Second
+
+func newNATSSink(serverURL, subject string) *natsSink {
+	return &natsSink{url: serverURL, subject: subject}
+}
+
+func (s *natsSink) Write(ctx context.Context, records []Record) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		payload, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "PUB %s %d\r\n", s.subject, len(payload))
+		buf.Write(payload)
+		buf.WriteString("\r\n")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+	return nil
+}
+
+// connect reads the server's INFO and sends CONNECT, with the credentials of
+// the URL if it has any. Must be called with mu held.
+func (s *natsSink) connect(ctx context.Context) error {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return fmt.Errorf("invalid NATS URL: %w", err)
+	}
+	dialer := net.Dialer{Timeout: natsDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(natsDialTimeout))
+	reader := bufio.NewReader(conn)
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting %q: %v", strings.TrimSpace(info), err)
+	}
+	if strings.Contains(info, `"tls_required":true`) {
+		conn.Close()
+		return fmt.Errorf("NATS server requires TLS, which the event exporter does not support")
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	options := map[string]any{"verbose": false, "pedantic": false, "name": "mix-event-export", "lang": "go"}
+	if u.User != nil {
+		options["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			options["pass"] = password
+		} else {
+			options["auth_token"] = u.User.Username()
+			delete(options, "user")
+		}
+	}
+	connect, _ := json.Marshal(options)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", connect); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	s.conn = conn
+	go s.readLoop(conn, reader)
+	return nil
+}
+
+// readLoop answers the server's keep-alive pings and logs its errors until
+// the connection closes.
+func (s *natsSink) readLoop(conn net.Conn, reader *bufio.Reader) {
+	defer logging.RecoverPanic("event-export-nats", nil)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err != io.EOF {
+				logging.Debug("NATS event export connection closed", "error", err)
+			}
+			s.mu.Lock()
+			if s.conn == conn {
+				conn.Close()
+				s.conn = nil
+			}
+			s.mu.Unlock()
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			s.mu.Lock()
+			conn.Write([]byte("PONG\r\n"))
+			s.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			logging.Error("NATS rejected exported events", "error", line)
+		}
+	}
+}
+
+func (s *natsSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// kafkaSink produces records to a Kafka topic through a Kafka REST proxy
+// (v2 API, as served by Confluent REST Proxy and Redpanda). Records are keyed
+// by session, so a session's events stay in order within a partition.
+type kafkaSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newKafkaSink(proxyURL, topic string) *kafkaSink {
+	return &kafkaSink{
+		endpoint: strings.TrimSuffix(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *kafkaSink) Write(ctx context.Context, records []Record) error {
+	type kafkaRecord struct {
+		Key   string `json:"key,omitempty"`
+		Value Record `json:"value"`
+	}
+	body := struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: make([]kafkaRecord, len(records))}
+	for i, record := range records {
+		body.Records[i] = kafkaRecord{Key: record.SessionID, Value: record}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce to Kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Kafka REST proxy returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package eventlog persists the SSE events sent for agent requests so clients
+// that reconnect mid-response can replay what they missed.
+package eventlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"mix/internal/db"
+	"mix/internal/pubsub"
+)
+
+// Retention is how long events are kept for replay
+const Retention = 24 * time.Hour
+
+// Event is one SSE event. Seq increases monotonically per session and is sent
+// to clients as the SSE event id.
+type Event struct {
+	SessionID string
+	Seq       int64
+	RequestID string
+	Type      string
+	Data      json.RawMessage
+	CreatedAt int64
+}
+
+type Service interface {
+	pubsub.Suscriber[Event]
+	// Append stores an event and publishes it to subscribers
+	Append(ctx context.Context, sessionID, requestID, eventType string, data any) (Event, error)
+	// Latest returns the session's newest sequence number, 0 when it has none
+	Latest(ctx context.Context, sessionID string) (int64, error)
+	// Since lists a session's events after seq, oldest first
+	Since(ctx context.Context, sessionID string, seq int64) ([]Event, error)
+	// Prune drops a session's events older than Retention
+	Prune(ctx context.Context, sessionID string) error
+}
+
+type service struct {
+	*pubsub.Broker[Event]
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{Broker: pubsub.NewBroker[Event](), q: q}
+}
+
+func (s *service) Append(ctx context.Context, sessionID, requestID, eventType string, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+	row, err := s.q.AppendStreamEvent(ctx, db.AppendStreamEventParams{
+		SessionID: sessionID,
+		RequestID: requestID,
+		Event:     eventType,
+		Data:      string(payload),
+	})
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to store %s event: %w", eventType, err)
+	}
+	event := fromDBItem(row)
+	s.Publish(ctx, pubsub.CreatedEvent, event)
+	return event, nil
+}
+
+func (s *service) Latest(ctx context.Context, sessionID string) (int64, error) {
+	return s.q.GetLatestStreamEventSeq(ctx, sessionID)
+}
+
+func (s *service) Since(ctx context.Context, sessionID string, seq int64) ([]Event, error) {
+	rows, err := s.q.ListStreamEventsSince(ctx, db.ListStreamEventsSinceParams{SessionID: sessionID, Seq: seq})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(rows))
+	for i, row := range rows {
+		events[i] = fromDBItem(row)
+	}
+	return events, nil
+}
+
+func (s *service) Prune(ctx context.Context, sessionID string) error {
+	return s.q.DeleteStreamEventsBefore(ctx, db.DeleteStreamEventsBeforeParams{
+		SessionID: sessionID,
+		Before:    time.Now().Add(-Retention).Unix(),
+	})
+}
+
+func fromDBItem(item db.StreamEvent) Event {
+	return Event{
+		SessionID: item.SessionID,
+		Seq:       item.Seq,
+		RequestID: item.RequestID,
+		Type:      item.Event,
+		Data:      json.RawMessage(item.Data),
+		CreatedAt: item.CreatedAt,
+	}
+}
@@ -0,0 +1,193 @@
+// Package filewatch reports the files created, modified and deleted in the
+// working directories of sessions, so clients can refresh their file trees and
+// media galleries while the agent works.
+package filewatch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/logging"
+	"mix/internal/pubsub"
+)
+
+// DefaultDebounce is how long changes are collected into one Changes, unless
+// configured with fileWatch.debounceMs.
+const DefaultDebounce = 250 * time.Millisecond
+
+// Op is what happened to a path.
+type Op string
+
+const (
+	OpCreated  Op = "created"
+	OpModified Op = "modified"
+	OpDeleted  Op = "deleted"
+)
+
+// Change is a change to the file or directory at Path, relative to the
+// working directory with forward slashes.
+type Change struct {
+	Path  string `json:"path"`
+	Op    Op     `json:"op"`
+	IsDir bool   `json:"isDir,omitempty"`
+}
+
+// Changes are the changes to a session's working directory within one
+// debounce window, each path once, sorted by path.
+type Changes struct {
+	SessionID string
+	Changes   []Change
+	// Truncated is set when more paths changed than one event carries, so
+	// clients should reload the directory instead
+	Truncated bool
+}
+
+type Service interface {
+	pubsub.Suscriber[Changes]
+	// Watch watches dir for the session until the returned function has been
+	// called as many times as Watch was for the session. Watching another
+	// directory for the session moves its watch there.
+	Watch(sessionID, dir string) (func(), error)
+	// SetDirectory moves the session's watch, if it has one, to dir
+	SetDirectory(sessionID, dir string) error
+	// Shutdown stops every watch
+	Shutdown(ctx context.Context) error
+}
+
+// sessionWatch is the watcher of a session's working directory and the number
+// of Watch calls not released yet.
+type sessionWatch struct {
+	refs    int
+	watcher *dirWatcher
+}
+
+type service struct {
+	*pubsub.Broker[Changes]
+
+	mu      sync.Mutex
+	watches map[string]*sessionWatch
+}
+
+func NewService() Service {
+	return &service{
+		Broker:  pubsub.NewBroker[Changes](),
+		watches: map[string]*sessionWatch{},
+	}
+}
+
+func (s *service) Watch(sessionID, dir string) (func(), error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watch, ok := s.watches[sessionID]
+	if !ok {
+		watcher, err := s.start(sessionID, root)
+		if err != nil {
+			return nil, err
+		}
+		watch = &sessionWatch{watcher: watcher}
+		s.watches[sessionID] = watch
+	} else if watch.watcher.root != root {
+		if err := s.moveLocked(sessionID, watch, root); err != nil {
+			return nil, err
+		}
+	}
+	watch.refs++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.release(sessionID, watch) })
+	}, nil
+}
+
+// release drops a reference to the session's watch, stopping it with the last.
+func (s *service) release(sessionID string, watch *sessionWatch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watch.refs--
+	if watch.refs > 0 || s.watches[sessionID] != watch {
+		return
+	}
+	delete(s.watches, sessionID)
+	watch.watcher.close()
+}
+
+func (s *service) SetDirectory(sessionID, dir string) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watch, ok := s.watches[sessionID]
+	if !ok || watch.watcher.root == root {
+		return nil
+	}
+	return s.moveLocked(sessionID, watch, root)
+}
+
+// moveLocked replaces the watcher of watch with one of root.
+func (s *service) moveLocked(sessionID string, watch *sessionWatch, root string) error {
+	watcher, err := s.start(sessionID, root)
+	if err != nil {
+		return err
+	}
+	watch.watcher.close()
+	watch.watcher = watcher
+	return nil
+}
+
+func (s *service) start(sessionID, root string) (*dirWatcher, error) {
+	settings := config.FileWatchConfig{}
+	if cfg := config.Get(); cfg != nil {
+		settings = cfg.FileWatch
+	}
+	debounce := DefaultDebounce
+	if settings.DebounceMs > 0 {
+		debounce = time.Duration(settings.DebounceMs) * time.Millisecond
+	}
+
+	watcher, err := newDirWatcher(root, settings.Ignore, debounce, func(changes []Change, truncated bool) {
+		if err := s.Publish(context.Background(), pubsub.CreatedEvent, Changes{
+			SessionID: sessionID,
+			Changes:   changes,
+			Truncated: truncated,
+		}); err != nil {
+			logging.Error("Failed to publish file changes", "sessionID", sessionID, "error", err)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	logging.Debug("Watching working directory", "sessionID", sessionID, "dir", root)
+	return watcher, nil
+}
+
+func (s *service) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	watches := s.watches
+	s.watches = map[string]*sessionWatch{}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, watch := range watches {
+			watch.watcher.close()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
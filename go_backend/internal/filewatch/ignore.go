@@ -0,0 +1,102 @@
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// defaultIgnore are left out of every watch: version control, the server's
+// own state and the asset server's thumbnail cache change on their own.
+var defaultIgnore = []string{".git/", ".mix/", ".thumbnails/"}
+
+// ignoreRule is one gitignore pattern.
+type ignoreRule struct {
+	pattern string
+	// negate re-includes the paths the rules before it ignore
+	negate bool
+	// dirOnly matches directories only, for patterns ending in a slash
+	dirOnly bool
+	// anchored patterns contain a slash and match the path from the root;
+	// the others match the name at any depth
+	anchored bool
+}
+
+// ignoreMatcher matches paths against gitignore patterns. The last matching
+// pattern wins, and everything below an ignored directory is ignored.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+func newIgnoreMatcher(patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	for _, line := range patterns {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		// A backslash escapes a leading # or !
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		m.rules = append(m.rules, rule)
+	}
+	return m
+}
+
+// loadIgnoreMatcher matches the default patterns, then the root .gitignore of
+// dir, then extra.
+func loadIgnoreMatcher(dir string, extra []string) *ignoreMatcher {
+	patterns := append([]string{}, defaultIgnore...)
+	if data, err := os.ReadFile(filepath.Join(dir, ".gitignore")); err == nil {
+		patterns = append(patterns, strings.Split(string(data), "\n")...)
+	}
+	return newIgnoreMatcher(append(patterns, extra...))
+}
+
+// ignored reports whether rel, a slash-separated path relative to the root,
+// is ignored, itself or through one of its parent directories.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		dir := isDir || i < len(parts)-1
+		if m.matches(strings.Join(parts[:i+1], "/"), parts[i], dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ignoreMatcher) matches(path, name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := name
+		if rule.anchored {
+			target = path
+		}
+		if ok, _ := doublestar.Match(rule.pattern, target); ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
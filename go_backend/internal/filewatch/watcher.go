@@ -0,0 +1,253 @@
+package filewatch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mix/internal/logging"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// maxWatchedDirs bounds the directories of one working directory watched,
+	// so a huge tree can't exhaust the system's inotify watches
+	maxWatchedDirs = 4096
+	// maxChangesPerEvent bounds the changes published at once; the rest are
+	// dropped and the event marked truncated
+	maxChangesPerEvent = 500
+)
+
+// dirWatcher watches a directory tree, fsnotify watching single directories
+// only, and publishes its changes after each debounce window. Events are
+// handled on one goroutine, which owns every field but the ones set before it
+// starts.
+type dirWatcher struct {
+	root     string
+	extra    []string
+	debounce time.Duration
+	publish  func([]Change, bool)
+
+	fsw    *fsnotify.Watcher
+	ignore *ignoreMatcher
+	// dirs holds the watched directories
+	dirs    map[string]bool
+	full    bool
+	pending map[string]Change
+	done    chan struct{}
+}
+
+func newDirWatcher(root string, extra []string, debounce time.Duration, publish func([]Change, bool)) (*dirWatcher, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("not a directory")
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &dirWatcher{
+		root:     root,
+		extra:    extra,
+		debounce: debounce,
+		publish:  publish,
+		fsw:      fsw,
+		ignore:   loadIgnoreMatcher(root, extra),
+		dirs:     map[string]bool{},
+		pending:  map[string]Change{},
+		done:     make(chan struct{}),
+	}
+	w.addTree(root, false)
+	go w.run()
+	return w, nil
+}
+
+// close stops the watcher, dropping the changes not published yet.
+func (w *dirWatcher) close() {
+	w.fsw.Close()
+	<-w.done
+}
+
+func (w *dirWatcher) run() {
+	defer logging.RecoverPanic("file-watcher", nil)
+	defer close(w.done)
+
+	var timer *time.Timer
+	var flush <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+			if len(w.pending) > 0 && flush == nil {
+				timer = time.NewTimer(w.debounce)
+				flush = timer.C
+			}
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			logging.Warn("File watcher error", "dir", w.root, "error", err)
+		case <-flush:
+			flush = nil
+			w.flush()
+		}
+	}
+}
+
+// addTree watches dir and the directories below it that aren't ignored. With
+// report set, dir was just created, and what it holds is recorded as created,
+// since it may have been written before the watch was added.
+func (w *dirWatcher) addTree(dir string, report bool) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, ok := w.rel(path)
+		if ok && w.ignore.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if report && ok && path != dir {
+			w.record(Change{Path: rel, Op: OpCreated, IsDir: d.IsDir()})
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if len(w.dirs) >= maxWatchedDirs {
+			if !w.full {
+				logging.Warn("Too many directories to watch, changes below the rest aren't reported", "dir", w.root, "limit", maxWatchedDirs)
+				w.full = true
+			}
+			return filepath.SkipAll
+		}
+		if err := w.fsw.Add(path); err != nil {
+			logging.Debug("Failed to watch directory", "dir", path, "error", err)
+			return filepath.SkipDir
+		}
+		w.dirs[path] = true
+		return nil
+	})
+}
+
+// rel returns path relative to the root with forward slashes, or false for
+// the root itself.
+func (w *dirWatcher) rel(path string) (string, bool) {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (w *dirWatcher) handle(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+	path := filepath.Clean(event.Name)
+	rel, ok := w.rel(path)
+	if !ok {
+		return
+	}
+	if rel == ".gitignore" {
+		// Paths it ignores from now on are filtered; newly included
+		// directories are watched once the session's watch restarts
+		w.ignore = loadIgnoreMatcher(w.root, w.extra)
+	}
+
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Lstat(path)
+		if err != nil {
+			return
+		}
+		if w.ignore.ignored(rel, info.IsDir()) {
+			return
+		}
+		w.record(Change{Path: rel, Op: OpCreated, IsDir: info.IsDir()})
+		if info.IsDir() {
+			w.addTree(path, true)
+		}
+	case event.Has(fsnotify.Write):
+		if w.ignore.ignored(rel, false) {
+			return
+		}
+		w.record(Change{Path: rel, Op: OpModified})
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// A renamed path is deleted here and created under its new name
+		isDir := w.dirs[path]
+		if isDir {
+			w.forget(path)
+		}
+		if w.ignore.ignored(rel, isDir) {
+			return
+		}
+		w.record(Change{Path: rel, Op: OpDeleted, IsDir: isDir})
+	}
+}
+
+// forget stops watching dir and the directories below it.
+func (w *dirWatcher) forget(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for path := range w.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			w.fsw.Remove(path)
+			delete(w.dirs, path)
+		}
+	}
+	w.full = false
+}
+
+// record adds a change to the pending ones, folding it into an earlier change
+// to the same path within the window.
+func (w *dirWatcher) record(change Change) {
+	previous, ok := w.pending[change.Path]
+	switch {
+	case !ok:
+	case previous.Op == OpCreated && change.Op == OpDeleted:
+		// Gone before anyone heard of it
+		delete(w.pending, change.Path)
+		return
+	case previous.Op == OpCreated:
+		return
+	case previous.Op == OpDeleted && change.Op == OpCreated:
+		change.Op = OpModified
+	}
+	w.pending[change.Path] = change
+}
+
+// flush publishes the pending changes.
+func (w *dirWatcher) flush() {
+	if len(w.pending) == 0 {
+		return
+	}
+	changes := make([]Change, 0, len(w.pending))
+	for _, change := range w.pending {
+		changes = append(changes, change)
+	}
+	clear(w.pending)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	truncated := len(changes) > maxChangesPerEvent
+	if truncated {
+		changes = changes[:maxChangesPerEvent]
+	}
+	w.publish(changes, truncated)
+}
@@ -0,0 +1,312 @@
+// Package gallery indexes the media a session produced: image, video and audio
+// files in its working directory and files its tool calls wrote or referenced
+// elsewhere, with their dimensions, duration and codecs.
+package gallery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/captions"
+	"mix/internal/message"
+	"mix/internal/session"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxItems bounds a gallery; the most recently modified files are kept
+	MaxItems = 500
+	// probeWorkers bounds the ffprobe processes run at once
+	probeWorkers = 4
+	probeTimeout = 15 * time.Second
+	// cacheSize bounds the metadata kept between listings; the cache is
+	// emptied when it fills
+	cacheSize = 4096
+)
+
+// skipDirs are not scanned for media, besides hidden directories such as the
+// asset server's thumbnail cache
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"__pycache__":  true,
+}
+
+// Item is a media file in a session's gallery.
+type Item struct {
+	// Path is absolute; RelPath is relative to the working directory, or the
+	// absolute path for files outside it
+	Path       string
+	RelPath    string
+	Category   session.FileTypeCategory
+	Size       int64
+	ModifiedAt time.Time
+	// Tool and MessageID name the last tool call that wrote or referenced
+	// the file, if any
+	Tool      string
+	MessageID string
+	Metadata  Metadata
+}
+
+// Metadata is what could be extracted from a media file. Error is set when
+// extraction failed, e.g. because ffprobe isn't installed.
+type Metadata struct {
+	Width      int
+	Height     int
+	Duration   float64
+	Format     string
+	VideoCodec string
+	AudioCodec string
+	Error      string
+}
+
+type cacheKey struct {
+	path     string
+	size     int64
+	modified time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[cacheKey]Metadata)
+)
+
+// List returns the media files of a session, newest first: the supported
+// files under workingDir and those named in the tool calls of msgs. Metadata
+// is cached by path, size and modification time, so repeated listings only
+// probe new and changed files.
+func List(ctx context.Context, workingDir string, msgs []message.Message) ([]Item, error) {
+	byPath := make(map[string]*Item)
+	if workingDir != "" {
+		if err := scan(workingDir, byPath); err != nil {
+			return nil, fmt.Errorf("failed to scan working directory: %w", err)
+		}
+	}
+	addToolFiles(workingDir, msgs, byPath)
+
+	items := make([]Item, 0, len(byPath))
+	for _, item := range byPath {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].ModifiedAt.Equal(items[j].ModifiedAt) {
+			return items[i].ModifiedAt.After(items[j].ModifiedAt)
+		}
+		return items[i].RelPath < items[j].RelPath
+	})
+	if len(items) > MaxItems {
+		items = items[:MaxItems]
+	}
+
+	extract(ctx, items)
+	return items, nil
+}
+
+func scan(workingDir string, byPath map[string]*Item) error {
+	return filepath.WalkDir(workingDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if path != workingDir && (strings.HasPrefix(d.Name(), ".") || d.IsDir() && skipDirs[d.Name()]) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		add(workingDir, path, byPath)
+		return nil
+	})
+}
+
+// add indexes path if it is an existing media file and returns its item.
+func add(workingDir, path string, byPath map[string]*Item) *Item {
+	if item, ok := byPath[path]; ok {
+		return item
+	}
+	category, ok := session.MediaCategory(path)
+	if !ok {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	item := &Item{
+		Path:       path,
+		RelPath:    relPath(workingDir, path),
+		Category:   category,
+		Size:       info.Size(),
+		ModifiedAt: info.ModTime(),
+	}
+	byPath[path] = item
+	return item
+}
+
+// addToolFiles indexes the media files named by string values in tool call
+// inputs, such as the path a file was written to or a render's output, and
+// records the call on each.
+func addToolFiles(workingDir string, msgs []message.Message, byPath map[string]*Item) {
+	for _, msg := range msgs {
+		for _, call := range msg.ToolCalls() {
+			var input any
+			if err := json.Unmarshal([]byte(call.Input), &input); err != nil {
+				continue
+			}
+			for _, value := range stringValues(input) {
+				if _, ok := session.MediaCategory(value); !ok {
+					continue
+				}
+				path := value
+				if !filepath.IsAbs(path) {
+					if workingDir == "" {
+						continue
+					}
+					path = filepath.Join(workingDir, path)
+				}
+				if item := add(workingDir, filepath.Clean(path), byPath); item != nil {
+					item.Tool = call.Name
+					item.MessageID = msg.ID
+				}
+			}
+		}
+	}
+}
+
+func stringValues(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, e := range v {
+			values = append(values, stringValues(e)...)
+		}
+		return values
+	case map[string]any:
+		var values []string
+		for _, e := range v {
+			values = append(values, stringValues(e)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// extract fills in the metadata of items, probing uncached audio and video
+// files with a few ffprobe processes at a time.
+func extract(ctx context.Context, items []Item) {
+	sem := make(chan struct{}, probeWorkers)
+	var wg sync.WaitGroup
+	for i := range items {
+		item := &items[i]
+		key := cacheKey{path: item.Path, size: item.Size, modified: item.ModifiedAt}
+		cacheMu.Lock()
+		metadata, ok := cache[key]
+		cacheMu.Unlock()
+		if ok {
+			item.Metadata = metadata
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				item.Metadata.Error = ctx.Err().Error()
+				return
+			}
+			defer func() { <-sem }()
+
+			item.Metadata = probe(ctx, item.Path, item.Category)
+			// Failures aren't cached, so installing ffprobe takes effect
+			if item.Metadata.Error != "" {
+				return
+			}
+			cacheMu.Lock()
+			if len(cache) >= cacheSize {
+				clear(cache)
+			}
+			cache[key] = item.Metadata
+			cacheMu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+func probe(ctx context.Context, path string, category session.FileTypeCategory) Metadata {
+	if category == session.CategoryImage {
+		return probeImage(path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	info, err := captions.Probe(ctx, path)
+	if err != nil {
+		return Metadata{Error: err.Error()}
+	}
+	metadata := Metadata{Duration: info.Duration, Format: info.Format}
+	for _, stream := range info.Streams {
+		switch stream.Type {
+		case "video":
+			// Cover art in audio files shows up as a video stream
+			if category == session.CategoryVideo && metadata.VideoCodec == "" {
+				metadata.VideoCodec = stream.Codec
+				metadata.Width = stream.Width
+				metadata.Height = stream.Height
+			}
+		case "audio":
+			if metadata.AudioCodec == "" {
+				metadata.AudioCodec = stream.Codec
+			}
+		}
+	}
+	return metadata
+}
+
+// probeImage reads an image's dimensions and format from its header without
+// decoding the pixels.
+func probeImage(path string) Metadata {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{Error: err.Error()}
+	}
+	defer f.Close()
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return Metadata{Error: fmt.Sprintf("failed to decode image: %v", err)}
+	}
+	return Metadata{Width: config.Width, Height: config.Height, Format: format}
+}
+
+func relPath(workingDir, path string) string {
+	if workingDir != "" {
+		if rel, err := filepath.Rel(workingDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path
+}
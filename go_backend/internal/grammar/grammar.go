@@ -0,0 +1,74 @@
+// Package grammar checks generated text such as narration scripts and
+// captions for spelling, grammar and style issues. It uses a LanguageTool
+// server when one is configured and a small set of local rules otherwise.
+package grammar
+
+import (
+	"context"
+	"sort"
+
+	"mix/internal/config"
+)
+
+const (
+	BackendLanguageTool = "languagetool"
+	BackendLocal        = "local"
+
+	defaultLanguage = "auto"
+)
+
+// Suggestion is a single issue found in the checked text. Offset and Length
+// are in characters (runes), not bytes.
+type Suggestion struct {
+	Offset       int      `json:"offset"`
+	Length       int      `json:"length"`
+	Text         string   `json:"text"`
+	Message      string   `json:"message"`
+	Rule         string   `json:"rule"`
+	Category     string   `json:"category,omitempty"`
+	Replacements []string `json:"replacements,omitempty"`
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Backend     string       `json:"backend"`
+	Language    string       `json:"language"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// Check runs text through the configured backend. An empty language uses the
+// configured default, or auto-detection.
+func Check(ctx context.Context, text, language string) (Result, error) {
+	cfg := config.Get().Grammar
+	if language == "" {
+		language = cfg.Language
+	}
+	if language == "" {
+		language = defaultLanguage
+	}
+
+	if cfg.LanguageToolURL == "" {
+		return Result{
+			Backend:     BackendLocal,
+			Language:    language,
+			Suggestions: checkLocal(text),
+		}, nil
+	}
+
+	suggestions, detected, err := checkLanguageTool(ctx, cfg, text, language)
+	if err != nil {
+		return Result{}, err
+	}
+	sortSuggestions(suggestions)
+	return Result{
+		Backend:     BackendLanguageTool,
+		Language:    detected,
+		Suggestions: suggestions,
+	}, nil
+}
+
+func sortSuggestions(suggestions []Suggestion) {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Offset < suggestions[j].Offset
+	})
+}
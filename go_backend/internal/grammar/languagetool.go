@@ -0,0 +1,120 @@
+package grammar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"mix/internal/config"
+	"mix/internal/netpolicy"
+)
+
+const (
+	languageToolTimeout = 30 * time.Second
+	// maxReplacements keeps the suggestions compact; LanguageTool can return dozens
+	maxReplacements = 5
+)
+
+type languageToolResponse struct {
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+	Matches []struct {
+		Message      string `json:"message"`
+		Offset       int    `json:"offset"`
+		Length       int    `json:"length"`
+		Replacements []struct {
+			Value string `json:"value"`
+		} `json:"replacements"`
+		Rule struct {
+			ID       string `json:"id"`
+			Category struct {
+				ID string `json:"id"`
+			} `json:"category"`
+		} `json:"rule"`
+	} `json:"matches"`
+}
+
+// checkLanguageTool calls the /v2/check endpoint of a LanguageTool server, either
+// the public API or a self-hosted instance.
+func checkLanguageTool(ctx context.Context, cfg config.GrammarConfig, text, language string) ([]Suggestion, string, error) {
+	form := url.Values{}
+	form.Set("text", text)
+	form.Set("language", language)
+	if cfg.Username != "" && cfg.APIKey != "" {
+		form.Set("username", cfg.Username)
+		form.Set("apiKey", cfg.APIKey)
+	}
+
+	endpoint := strings.TrimRight(cfg.LanguageToolURL, "/") + "/v2/check"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create LanguageTool request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "mix/1.0")
+
+	resp, err := netpolicy.Default().Client(languageToolTimeout).Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("LanguageTool request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, "", fmt.Errorf("LanguageTool returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed languageToolResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, "", fmt.Errorf("failed to decode LanguageTool response: %w", err)
+	}
+
+	runes := []rune(text)
+	suggestions := make([]Suggestion, 0, len(parsed.Matches))
+	for _, m := range parsed.Matches {
+		offset := runeOffset(runes, m.Offset)
+		length := runeOffset(runes[offset:], m.Length)
+		s := Suggestion{
+			Offset:   offset,
+			Length:   length,
+			Text:     string(runes[offset : offset+length]),
+			Message:  m.Message,
+			Rule:     m.Rule.ID,
+			Category: m.Rule.Category.ID,
+		}
+		for i, r := range m.Replacements {
+			if i == maxReplacements {
+				break
+			}
+			s.Replacements = append(s.Replacements, r.Value)
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	detected := parsed.Language.Code
+	if detected == "" {
+		detected = language
+	}
+	return suggestions, detected, nil
+}
+
+// runeOffset converts a UTF-16 offset, which LanguageTool reports since it
+// indexes Java strings, to a rune offset into text, clamped to its length.
+func runeOffset(text []rune, utf16Offset int) int {
+	units := 0
+	for i, r := range text {
+		if units >= utf16Offset {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(text)
+}
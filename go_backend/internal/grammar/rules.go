@@ -0,0 +1,207 @@
+package grammar
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Local rules are English-only and catch the mechanical mistakes that slip
+// into generated narration; configure a LanguageTool server for real grammar
+// and other languages.
+
+var (
+	wordPattern             = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+	multipleSpacesPattern   = regexp.MustCompile(`\S( {2,})\S`)
+	spaceBeforePunctPattern = regexp.MustCompile(`\p{L}( +)[,.;:!?]`)
+	missingSpacePattern     = regexp.MustCompile(`\p{Ll}[,;:](\p{L})`)
+	sentenceStartPattern    = regexp.MustCompile(`(?:^|[.!?]["”')]?\s+)(\p{Ll})`)
+	lowercaseIPattern       = regexp.MustCompile(`(?:^|[^\p{L}'’])(i)(?:$|[^\p{L}'’])`)
+
+	// abbreviations are not sentence ends, so the next word may be lowercase
+	abbreviations = map[string]bool{
+		"e.g": true, "i.e": true, "etc": true, "vs": true, "approx": true,
+		"mr": true, "mrs": true, "ms": true, "dr": true, "st": true,
+	}
+
+	misspellings = map[string]string{
+		"accomodate":  "accommodate",
+		"acheive":     "achieve",
+		"alot":        "a lot",
+		"begining":    "beginning",
+		"beleive":     "believe",
+		"calender":    "calendar",
+		"definately":  "definitely",
+		"enviroment":  "environment",
+		"existance":   "existence",
+		"goverment":   "government",
+		"independant": "independent",
+		"occured":     "occurred",
+		"occurence":   "occurrence",
+		"recieve":     "receive",
+		"seperate":    "separate",
+		"succesful":   "successful",
+		"teh":         "the",
+		"thier":       "their",
+		"tommorow":    "tomorrow",
+		"untill":      "until",
+		"wich":        "which",
+		"writting":    "writing",
+	}
+)
+
+type localRule func(text string) []Suggestion
+
+var localRules = []localRule{
+	checkMisspellings,
+	checkRepeatedWords,
+	checkMultipleSpaces,
+	checkSpaceBeforePunctuation,
+	checkMissingSpaceAfterPunctuation,
+	checkSentenceStart,
+	checkLowercaseI,
+}
+
+func checkLocal(text string) []Suggestion {
+	suggestions := []Suggestion{}
+	for _, rule := range localRules {
+		suggestions = append(suggestions, rule(text)...)
+	}
+	sortSuggestions(suggestions)
+	return suggestions
+}
+
+// suggestion builds a Suggestion for the byte range [start, end) of text.
+func suggestion(text string, start, end int, rule, category, message string, replacements ...string) Suggestion {
+	return Suggestion{
+		Offset:       utf8.RuneCountInString(text[:start]),
+		Length:       utf8.RuneCountInString(text[start:end]),
+		Text:         text[start:end],
+		Message:      message,
+		Rule:         rule,
+		Category:     category,
+		Replacements: replacements,
+	}
+}
+
+func checkMisspellings(text string) []Suggestion {
+	var result []Suggestion
+	for _, loc := range wordPattern.FindAllStringIndex(text, -1) {
+		word := text[loc[0]:loc[1]]
+		correct, ok := misspellings[strings.ToLower(word)]
+		if !ok {
+			continue
+		}
+		if unicode.IsUpper([]rune(word)[0]) {
+			correct = strings.ToUpper(correct[:1]) + correct[1:]
+		}
+		result = append(result, suggestion(text, loc[0], loc[1], "MORFOLOGIK_RULE_EN", "TYPOS",
+			"Possible spelling mistake found.", correct))
+	}
+	return result
+}
+
+func checkRepeatedWords(text string) []Suggestion {
+	var result []Suggestion
+	locs := wordPattern.FindAllStringIndex(text, -1)
+	for i := 1; i < len(locs); i++ {
+		prev, cur := locs[i-1], locs[i]
+		if strings.TrimSpace(text[prev[1]:cur[0]]) != "" {
+			continue
+		}
+		word := text[cur[0]:cur[1]]
+		if !strings.EqualFold(text[prev[0]:prev[1]], word) {
+			continue
+		}
+		result = append(result, suggestion(text, prev[0], cur[1], "ENGLISH_WORD_REPEAT_RULE", "MISC",
+			"Possible typo: you repeated a word.", text[prev[0]:prev[1]]))
+	}
+	return result
+}
+
+func checkMultipleSpaces(text string) []Suggestion {
+	var result []Suggestion
+	for _, loc := range multipleSpacesPattern.FindAllStringSubmatchIndex(text, -1) {
+		result = append(result, suggestion(text, loc[2], loc[3], "WHITESPACE_RULE", "TYPOGRAPHY",
+			"Possible typo: you repeated a whitespace.", " "))
+	}
+	return result
+}
+
+func checkSpaceBeforePunctuation(text string) []Suggestion {
+	var result []Suggestion
+	for _, loc := range spaceBeforePunctPattern.FindAllStringSubmatchIndex(text, -1) {
+		result = append(result, suggestion(text, loc[2], loc[3], "COMMA_PARENTHESIS_WHITESPACE", "TYPOGRAPHY",
+			"Don't put a space before the punctuation mark.", ""))
+	}
+	return result
+}
+
+func checkMissingSpaceAfterPunctuation(text string) []Suggestion {
+	var result []Suggestion
+	for _, loc := range missingSpacePattern.FindAllStringSubmatchIndex(text, -1) {
+		// Flag the punctuation mark and the letter after it
+		start, end := loc[2]-1, loc[3]
+		result = append(result, suggestion(text, start, end, "PUNCTUATION_WHITESPACE", "TYPOGRAPHY",
+			"Put a space after the punctuation mark.", text[start:start+1]+" "+text[loc[2]:end]))
+	}
+	return result
+}
+
+func checkSentenceStart(text string) []Suggestion {
+	var result []Suggestion
+	for _, loc := range sentenceStartPattern.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] > 0 && endsWithAbbreviation(text[:loc[0]+1]) {
+			continue
+		}
+		start, end := loc[2], loc[3]
+		// "i" is reported by checkLowercaseI; words like "iPhone" are styled
+		// lowercase on purpose
+		if word := wordPattern.FindString(text[start:]); word == "i" || hasInnerUpper(word) {
+			continue
+		}
+		result = append(result, suggestion(text, start, end, "UPPERCASE_SENTENCE_START", "CASING",
+			"This sentence does not start with an uppercase letter.", strings.ToUpper(text[start:end])))
+	}
+	return result
+}
+
+func checkLowercaseI(text string) []Suggestion {
+	var result []Suggestion
+	// Matches share their delimiters, so scan from the end of each match's "i"
+	for offset := 0; offset < len(text); {
+		loc := lowercaseIPattern.FindStringSubmatchIndex(text[offset:])
+		if loc == nil {
+			break
+		}
+		start, end := offset+loc[2], offset+loc[3]
+		result = append(result, suggestion(text, start, end, "I_LOWERCASE", "CASING",
+			"The personal pronoun \"I\" should be uppercase.", "I"))
+		offset = end
+	}
+	return result
+}
+
+// endsWithAbbreviation reports whether text, ending in a period, ends with a
+// known abbreviation or an ellipsis rather than a sentence.
+func endsWithAbbreviation(text string) bool {
+	if !strings.HasSuffix(text, ".") {
+		return false
+	}
+	if strings.HasSuffix(text, "..") {
+		return true
+	}
+	text = strings.TrimSuffix(text, ".")
+	i := strings.LastIndexFunc(text, unicode.IsSpace)
+	return abbreviations[strings.ToLower(text[i+1:])]
+}
+
+func hasInnerUpper(word string) bool {
+	for i, r := range word {
+		if i > 0 && unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
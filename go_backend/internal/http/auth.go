@@ -0,0 +1,325 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"mix/internal/api"
+	"mix/internal/app"
+	"mix/internal/config"
+	"mix/internal/logging"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// RPCErrorUnauthorized is the JSON-RPC error code for requests without
+	// valid credentials
+	RPCErrorUnauthorized = api.CodeAuthRequired
+	// RPCErrorForbidden is the JSON-RPC error code for methods the token's
+	// scope doesn't allow
+	RPCErrorForbidden = api.CodeForbidden
+)
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid bearer token")
+)
+
+// readMethods are the JSON-RPC methods a read-scoped token may call. They
+// don't change sessions, settings or credentials, or start agent work.
+var readMethods = map[string]bool{
+	"sessions.list":          true,
+	"sessions.get":           true,
+	"sessions.export":        true,
+	"sessions.render":        true,
+	"sessions.shares":        true,
+	"sessions.current":       true,
+	"sessions.diff":          true,
+	"sessions.tree":          true,
+	"messages.history":       true,
+	"messages.list":          true,
+	"messages.estimate":      true,
+	"messages.preview":       true,
+	"messages.listQueued":    true,
+	"mcp.list":               true,
+	"tools.list":             true,
+	"tools.stats":            true,
+	"commands.list":          true,
+	"commands.get":           true,
+	"providers.list":         true,
+	"permission.listPending": true,
+	"questions.listPending":  true,
+	"audit.list":             true,
+	"audit.get":              true,
+	"artifacts.list":         true,
+	"artifacts.get":          true,
+	"assets.list":            true,
+	"usage.report":           true,
+	"templates.list":         true,
+	"jobs.list":              true,
+	"checkpoints.list":       true,
+	"context.list":           true,
+	"queue.status":           true,
+	"memory.list":            true,
+}
+
+// Authenticator checks the bearer credentials of HTTP requests against the
+// static tokens and JWT settings of config.HTTPAuthConfig.
+type Authenticator struct {
+	tokens          []staticToken
+	jwt             *config.HTTPJWTConfig
+	jwtKey          any
+	jwtMethods      []string
+	bypassLocalhost bool
+}
+
+type staticToken struct {
+	hash  [sha256.Size]byte
+	scope string
+}
+
+// auth holds the Authenticator the handlers apply. Config reloads replace it
+// while requests are served, so each request loads it once.
+var auth atomic.Pointer[Authenticator]
+
+func init() {
+	auth.Store(&Authenticator{})
+}
+
+// ConfigureAuth replaces the authentication applied by the HTTP handlers.
+// With bypassLocalhost, requests from loopback addresses need no credentials.
+func ConfigureAuth(cfg config.HTTPAuthConfig, bypassLocalhost bool) error {
+	a, err := NewAuthenticator(cfg, bypassLocalhost)
+	if err != nil {
+		return err
+	}
+	auth.Store(a)
+	return nil
+}
+
+// ReloadAuthOnChange applies the httpAuth section of each reloaded
+// configuration that changed it, until ctx is cancelled. A section that fails
+// to load, e.g. an unreadable JWT public key, keeps the previous settings.
+func ReloadAuthOnChange(ctx context.Context, app *app.App) {
+	changes := app.ConfigChanges.Subscribe(ctx)
+	go func() {
+		defer logging.RecoverPanic("http-auth-reload", nil)
+		for event := range changes {
+			if !event.Payload.Has("httpAuth") {
+				continue
+			}
+			if err := ConfigureAuth(config.Get().HTTPAuth, auth.Load().bypassLocalhost); err != nil {
+				logging.Error("Failed to apply reloaded HTTP authentication, keeping the previous settings", "error", err)
+				continue
+			}
+			logging.Info("HTTP authentication reloaded")
+		}
+	}()
+}
+
+func NewAuthenticator(cfg config.HTTPAuthConfig, bypassLocalhost bool) (*Authenticator, error) {
+	a := &Authenticator{bypassLocalhost: bypassLocalhost}
+	for _, token := range cfg.Tokens {
+		scope := token.Scope
+		if scope == "" {
+			scope = config.HTTPScopeFull
+		}
+		a.tokens = append(a.tokens, staticToken{hash: sha256.Sum256([]byte(token.Token)), scope: scope})
+	}
+	if cfg.JWT != nil {
+		a.jwt = cfg.JWT
+		if cfg.JWT.Secret != "" {
+			a.jwtKey = []byte(cfg.JWT.Secret)
+			a.jwtMethods = []string{"HS256", "HS384", "HS512"}
+		} else {
+			key, methods, err := loadJWTPublicKey(cfg.JWT.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			a.jwtKey, a.jwtMethods = key, methods
+		}
+	}
+	return a, nil
+}
+
+// loadJWTPublicKey reads an RSA, ECDSA or Ed25519 public key and the signing
+// methods it verifies.
+func loadJWTPublicKey(path string) (any, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read JWT public key: %w", err)
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, []string{"ES256", "ES384", "ES512"}, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return key, []string{"EdDSA"}, nil
+	}
+	return nil, nil, fmt.Errorf("failed to parse JWT public key %s: not an RSA, ECDSA or Ed25519 PEM public key", path)
+}
+
+// Enabled reports whether requests must authenticate.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0 || a.jwt != nil
+}
+
+// bypassed reports whether the request needs no credentials.
+func (a *Authenticator) bypassed(r *http.Request) bool {
+	if !a.Enabled() {
+		return true
+	}
+	if !a.bypassLocalhost {
+		return false
+	}
+	ip := net.ParseIP(clientIP(r))
+	return ip != nil && ip.IsLoopback()
+}
+
+// Authenticate returns the scope granted by the request's bearer token, or
+// full access when authentication is off or bypassed for the request.
+func (a *Authenticator) Authenticate(r *http.Request) (string, error) {
+	if a.bypassed(r) {
+		return config.HTTPScopeFull, nil
+	}
+	value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || value == "" {
+		return "", errMissingToken
+	}
+
+	hash := sha256.Sum256([]byte(value))
+	for _, token := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], token.hash[:]) == 1 {
+			return token.scope, nil
+		}
+	}
+	if a.jwt != nil {
+		return a.authenticateJWT(value)
+	}
+	return "", errInvalidToken
+}
+
+func (a *Authenticator) authenticateJWT(value string) (string, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(a.jwtMethods)}
+	if a.jwt.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.jwt.Issuer))
+	}
+	if a.jwt.Audience != "" {
+		options = append(options, jwt.WithAudience(a.jwt.Audience))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(value, claims, func(*jwt.Token) (any, error) {
+		return a.jwtKey, nil
+	}, options...); err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+
+	claim := a.jwt.ScopeClaim
+	if claim == "" {
+		claim = "scope"
+	}
+	var scopes []string
+	switch v := claims[claim].(type) {
+	case string:
+		scopes = strings.Fields(v)
+	case []any:
+		for _, scope := range v {
+			if s, ok := scope.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	switch {
+	case slices.Contains(scopes, config.HTTPScopeFull):
+		return config.HTTPScopeFull, nil
+	case slices.Contains(scopes, config.HTTPScopeRead):
+		return config.HTTPScopeRead, nil
+	}
+	return "", fmt.Errorf("%w: %s claim grants neither %s nor %s", errInvalidToken, claim, config.HTTPScopeRead, config.HTTPScopeFull)
+}
+
+// allowsMethod reports whether scope may call a JSON-RPC method.
+func allowsMethod(scope, method string) bool {
+	return scope == config.HTTPScopeFull || readMethods[method]
+}
+
+// AuthenticateRPC wraps the /rpc handler, rejecting requests without valid
+// credentials with HTTP 401, methods outside the token's scope with 403, and
+// bodies of scoped tokens that don't parse as a single request with 400.
+func AuthenticateRPC(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := auth.Load()
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scope, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mix"`)
+			writeRPCError(w, http.StatusUnauthorized, nil, RPCErrorUnauthorized, "Unauthorized: "+err.Error())
+			return
+		}
+		if scope == config.HTTPScopeFull {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeRPCError(w, http.StatusBadRequest, nil, api.CodeParseError, "Parse error: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		// A body that isn't a single request, e.g. a batch, can't be checked
+		// against the scope, so it isn't forwarded
+		var request api.QueryRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeRPCError(w, http.StatusBadRequest, nil, api.CodeParseError, "Parse error: "+err.Error())
+			return
+		}
+		if !allowsMethod(scope, request.Method) {
+			writeRPCError(w, http.StatusForbidden, request.ID, RPCErrorForbidden, "Forbidden: "+request.Method+" requires the full scope")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope wraps an HTTP handler other than /rpc, rejecting requests
+// whose credentials don't grant scope.
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		granted, err := auth.Load().Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mix"`)
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if scope == config.HTTPScopeFull && granted != config.HTTPScopeFull {
+			http.Error(w, "Forbidden: requires the full scope", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
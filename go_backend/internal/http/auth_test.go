@@ -0,0 +1,303 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mix/internal/api"
+	"mix/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWTSecret = "test-secret"
+
+func signTestJWT(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuthenticate(t *testing.T) {
+	cfg := config.HTTPAuthConfig{
+		Tokens: []config.HTTPToken{
+			{Name: "reader", Token: "read-token", Scope: config.HTTPScopeRead},
+			{Name: "admin", Token: "full-token"},
+		},
+		JWT: &config.HTTPJWTConfig{Secret: testJWTSecret, Issuer: "mix-tests", Audience: "mix"},
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name          string
+		authorization string
+		remoteAddr    string
+		bypass        bool
+		scope         string
+		err           error
+	}{
+		{
+			name:          "static read token",
+			authorization: "Bearer read-token",
+			scope:         config.HTTPScopeRead,
+		},
+		{
+			name:          "static token without scope is full",
+			authorization: "Bearer full-token",
+			scope:         config.HTTPScopeFull,
+		},
+		{
+			name: "missing token",
+			err:  errMissingToken,
+		},
+		{
+			name:          "not a bearer token",
+			authorization: "Basic cmVhZC10b2tlbg==",
+			err:           errMissingToken,
+		},
+		{
+			name:          "unknown token",
+			authorization: "Bearer other-token",
+			err:           errInvalidToken,
+		},
+		{
+			name: "JWT with read scope",
+			authorization: "Bearer " + signTestJWT(t, testJWTSecret, jwt.MapClaims{
+				"iss": "mix-tests", "aud": "mix", "exp": expires, "scope": "profile read",
+			}),
+			scope: config.HTTPScopeRead,
+		},
+		{
+			name: "JWT with scope list including full",
+			authorization: "Bearer " + signTestJWT(t, testJWTSecret, jwt.MapClaims{
+				"iss": "mix-tests", "aud": "mix", "exp": expires, "scope": []any{"read", "full"},
+			}),
+			scope: config.HTTPScopeFull,
+		},
+		{
+			name: "JWT without a known scope",
+			authorization: "Bearer " + signTestJWT(t, testJWTSecret, jwt.MapClaims{
+				"iss": "mix-tests", "aud": "mix", "exp": expires, "scope": "profile",
+			}),
+			err: errInvalidToken,
+		},
+		{
+			name: "JWT signed with another secret",
+			authorization: "Bearer " + signTestJWT(t, "other-secret", jwt.MapClaims{
+				"iss": "mix-tests", "aud": "mix", "exp": expires, "scope": "full",
+			}),
+			err: errInvalidToken,
+		},
+		{
+			name: "JWT from another issuer",
+			authorization: "Bearer " + signTestJWT(t, testJWTSecret, jwt.MapClaims{
+				"iss": "elsewhere", "aud": "mix", "exp": expires, "scope": "full",
+			}),
+			err: errInvalidToken,
+		},
+		{
+			name: "expired JWT",
+			authorization: "Bearer " + signTestJWT(t, testJWTSecret, jwt.MapClaims{
+				"iss": "mix-tests", "aud": "mix", "exp": time.Now().Add(-time.Hour).Unix(), "scope": "full",
+			}),
+			err: errInvalidToken,
+		},
+		{
+			name:       "localhost bypass",
+			remoteAddr: "127.0.0.1:1234",
+			bypass:     true,
+			scope:      config.HTTPScopeFull,
+		},
+		{
+			name:       "bypass doesn't cover other addresses",
+			remoteAddr: "10.0.0.1:1234",
+			bypass:     true,
+			err:        errMissingToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAuthenticator(cfg, tt.bypass)
+			require.NoError(t, err)
+			r := httptest.NewRequest(http.MethodGet, "/rpc", nil)
+			if tt.remoteAddr != "" {
+				r.RemoteAddr = tt.remoteAddr
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			scope, err := a.Authenticate(r)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.scope, scope)
+		})
+	}
+}
+
+func TestAuthenticateRPC(t *testing.T) {
+	require.NoError(t, ConfigureAuth(config.HTTPAuthConfig{
+		Tokens: []config.HTTPToken{
+			{Name: "reader", Token: "read-token", Scope: config.HTTPScopeRead},
+			{Name: "admin", Token: "full-token", Scope: config.HTTPScopeFull},
+		},
+	}, false))
+	defer ConfigureAuth(config.HTTPAuthConfig{}, false)
+
+	tests := []struct {
+		name      string
+		token     string
+		body      string
+		status    int
+		code      int
+		forwarded bool
+	}{
+		{
+			name:   "missing token",
+			body:   `{"method":"sessions.list","id":1}`,
+			status: http.StatusUnauthorized,
+			code:   RPCErrorUnauthorized,
+		},
+		{
+			name:      "read token calls a read method",
+			token:     "read-token",
+			body:      `{"method":"sessions.list","id":1}`,
+			status:    http.StatusOK,
+			forwarded: true,
+		},
+		{
+			name:   "read token calls a write method",
+			token:  "read-token",
+			body:   `{"method":"sessions.delete","id":1}`,
+			status: http.StatusForbidden,
+			code:   RPCErrorForbidden,
+		},
+		{
+			name:   "read token sends a batch",
+			token:  "read-token",
+			body:   `[{"method":"sessions.list","id":1},{"method":"sessions.delete","id":2}]`,
+			status: http.StatusBadRequest,
+			code:   api.CodeParseError,
+		},
+		{
+			name:   "read token sends malformed JSON",
+			token:  "read-token",
+			body:   `{"method":`,
+			status: http.StatusBadRequest,
+			code:   api.CodeParseError,
+		},
+		{
+			name:      "full token calls a write method",
+			token:     "full-token",
+			body:      `{"method":"sessions.delete","id":1}`,
+			status:    http.StatusOK,
+			forwarded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded := false
+			handler := AuthenticateRPC(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded = true
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(tt.body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Equal(t, tt.forwarded, forwarded)
+			if tt.code == 0 {
+				return
+			}
+			var resp api.QueryResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+			require.NotNil(t, resp.Error)
+			assert.Equal(t, tt.code, resp.Error.Code)
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	require.NoError(t, ConfigureAuth(config.HTTPAuthConfig{
+		Tokens: []config.HTTPToken{
+			{Name: "reader", Token: "read-token", Scope: config.HTTPScopeRead},
+			{Name: "admin", Token: "full-token"},
+		},
+	}, false))
+	defer ConfigureAuth(config.HTTPAuthConfig{}, false)
+
+	tests := []struct {
+		name   string
+		scope  string
+		method string
+		token  string
+		status int
+	}{
+		{name: "read route without token", scope: config.HTTPScopeRead, token: "", status: http.StatusUnauthorized},
+		{name: "read route with read token", scope: config.HTTPScopeRead, token: "read-token", status: http.StatusOK},
+		{name: "full route with read token", scope: config.HTTPScopeFull, token: "read-token", status: http.StatusForbidden},
+		{name: "full route with full token", scope: config.HTTPScopeFull, token: "full-token", status: http.StatusOK},
+		{name: "preflight needs no token", scope: config.HTTPScopeFull, method: http.MethodOptions, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequireScope(tt.scope, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/metrics", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+			assert.Equal(t, tt.status, recorder.Code)
+		})
+	}
+}
+
+func TestConfigureAuthWhileServing(t *testing.T) {
+	defer ConfigureAuth(config.HTTPAuthConfig{}, false)
+	handler := RequireScope(config.HTTPScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 50 {
+			token := config.HTTPToken{Name: "reader", Token: "read-token", Scope: config.HTTPScopeRead}
+			if i%2 == 1 {
+				token.Token = "rotated-token"
+			}
+			assert.NoError(t, ConfigureAuth(config.HTTPAuthConfig{Tokens: []config.HTTPToken{token}}, false))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Authorization", "Bearer read-token")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		assert.Contains(t, []int{http.StatusOK, http.StatusUnauthorized}, recorder.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+
+	"mix/internal/api"
+	"mix/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+// RunJobs starts the app's job scheduler and logs a "job_completed" event to
+// the stream of each run's session, so clients opening the session replay
+// it. Runs that failed before creating a session are only logged.
+func RunJobs(ctx context.Context, handler *api.QueryHandler) {
+	app := handler.GetApp()
+	runs := app.Jobs.Subscribe(ctx)
+	go func() {
+		defer logging.RecoverPanic("job-events", nil)
+		for event := range runs {
+			run := event.Payload
+			if run.SessionID == "" {
+				continue
+			}
+			stream := requestStream{events: app.StreamEvents, sessionID: run.SessionID, requestID: uuid.New().String()}
+			stream.send("job_completed", JobCompletedEvent{
+				Type:       "job_completed",
+				Job:        run.Job,
+				SessionID:  run.SessionID,
+				Status:     run.Status,
+				Error:      run.Error,
+				Response:   run.Response,
+				Cost:       run.Cost,
+				StartedAt:  run.StartedAt.Unix(),
+				FinishedAt: run.FinishedAt.Unix(),
+			})
+		}
+	}()
+	app.StartJobs()
+}
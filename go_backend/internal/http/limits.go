@@ -0,0 +1,207 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"mix/internal/api"
+	"mix/internal/runqueue"
+)
+
+const (
+	// RPCErrorRateLimited is the JSON-RPC error code for rejected requests
+	RPCErrorRateLimited = api.CodeRateLimited
+	// RPCErrorInvalidRequest is the JSON-RPC error code for oversized bodies
+	RPCErrorInvalidRequest = api.CodeInvalidRequest
+
+	DefaultMaxBodyBytes = 10 << 20
+
+	// idleClientTTL is how long a client's rate bucket is kept without requests
+	idleClientTTL = 10 * time.Minute
+)
+
+// LimitConfig bounds what a single client can make the server do. Zero values
+// disable the corresponding limit. Agent runs are limited by the app's
+// RunQueue.
+type LimitConfig struct {
+	// RequestsPerSecond and Burst define a token bucket per client IP
+	RequestsPerSecond float64
+	Burst             int
+	MaxBodyBytes      int64
+}
+
+// Limiter enforces LimitConfig for the /rpc and /stream endpoints.
+type Limiter struct {
+	cfg LimitConfig
+
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var limits = NewLimiter(LimitConfig{})
+
+// ConfigureLimits replaces the limits applied by the HTTP handlers.
+func ConfigureLimits(cfg LimitConfig) {
+	limits = NewLimiter(cfg)
+}
+
+func NewLimiter(cfg LimitConfig) *Limiter {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	l := &Limiter{
+		cfg:       cfg,
+		clients:   make(map[string]*bucket),
+		lastPrune: time.Now(),
+	}
+	return l
+}
+
+// Allow takes a token from the client's bucket, reporting false when empty.
+func (l *Limiter) Allow(r *http.Request) bool {
+	if l.cfg.RequestsPerSecond <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	client := clientIP(r)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > idleClientTTL {
+		for ip, b := range l.clients {
+			if now.Sub(b.last) > idleClientTTL {
+				delete(l.clients, ip)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.cfg.Burst), last: now}
+		l.clients[client] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.cfg.RequestsPerSecond
+	if b.tokens > float64(l.cfg.Burst) {
+		b.tokens = float64(l.cfg.Burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryAfter is the number of seconds until a client regains a token
+func (l *Limiter) retryAfter() int {
+	if l.cfg.RequestsPerSecond <= 0 {
+		return 1
+	}
+	seconds := int(1/l.cfg.RequestsPerSecond + 0.5)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// LimitRPC wraps the /rpc handler with rate and body size limits, answering
+// rejected requests with JSON-RPC errors.
+func LimitRPC(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := limits
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !l.Allow(r) {
+			w.Header().Set("Retry-After", strconv.Itoa(l.retryAfter()))
+			writeRPCError(w, http.StatusTooManyRequests, nil, RPCErrorRateLimited, "Rate limit exceeded")
+			return
+		}
+
+		if l.cfg.MaxBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, l.cfg.MaxBodyBytes)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeRPCError(w, http.StatusRequestEntityTooLarge, nil, RPCErrorInvalidRequest, "Request body too large")
+				return
+			}
+			writeRPCError(w, http.StatusBadRequest, nil, api.CodeParseError, "Parse error: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitRequests wraps the /stream handlers with rate and body size limits.
+// Agent runs started over a stream wait for the run queue in
+// handleRegularMessage.
+func LimitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := limits
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !l.Allow(r) {
+			w.Header().Set("Retry-After", strconv.Itoa(l.retryAfter()))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		if l.cfg.MaxBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, l.cfg.MaxBodyBytes)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeRPCError(w http.ResponseWriter, status int, id interface{}, code int, message string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&api.QueryResponse{
+		Error: api.NewQueryError(code, message),
+		ID:    id,
+	})
+}
+
+// WithClient returns ctx identifying the client of r to the run queue.
+func WithClient(ctx context.Context, r *http.Request) context.Context {
+	return runqueue.WithClient(ctx, clientIP(r))
+}
+
+// clientIP is the connection's remote address. Forwarding headers are ignored
+// because clients can set them freely.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mix/internal/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      LimitConfig
+		requests []string
+		expected []bool
+	}{
+		{
+			name:     "unlimited",
+			requests: []string{"10.0.0.1:1", "10.0.0.1:1", "10.0.0.1:1"},
+			expected: []bool{true, true, true},
+		},
+		{
+			name:     "burst then rejected",
+			cfg:      LimitConfig{RequestsPerSecond: 0.001, Burst: 2},
+			requests: []string{"10.0.0.1:1", "10.0.0.1:2", "10.0.0.1:3"},
+			expected: []bool{true, true, false},
+		},
+		{
+			name:     "buckets are per client IP",
+			cfg:      LimitConfig{RequestsPerSecond: 0.001, Burst: 1},
+			requests: []string{"10.0.0.1:1", "10.0.0.2:1", "10.0.0.1:2"},
+			expected: []bool{true, true, false},
+		},
+		{
+			name:     "burst is at least one",
+			cfg:      LimitConfig{RequestsPerSecond: 0.001},
+			requests: []string{"10.0.0.1:1", "10.0.0.1:1"},
+			expected: []bool{true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewLimiter(tt.cfg)
+			for i, remoteAddr := range tt.requests {
+				r := httptest.NewRequest(http.MethodPost, "/rpc", nil)
+				r.RemoteAddr = remoteAddr
+				r.Header.Set("X-Forwarded-For", "10.9.9.9")
+				assert.Equal(t, tt.expected[i], limiter.Allow(r), "request %d", i)
+			}
+		})
+	}
+}
+
+func TestLimitRPC(t *testing.T) {
+	defer ConfigureLimits(LimitConfig{})
+
+	tests := []struct {
+		name     string
+		cfg      LimitConfig
+		requests int
+		body     string
+		status   int
+		code     int
+	}{
+		{
+			name:     "within limits",
+			cfg:      LimitConfig{MaxBodyBytes: 64},
+			requests: 1,
+			body:     `{"method":"sessions.list","id":1}`,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "oversized body",
+			cfg:      LimitConfig{MaxBodyBytes: 8},
+			requests: 1,
+			body:     `{"method":"sessions.list","id":1}`,
+			status:   http.StatusRequestEntityTooLarge,
+			code:     RPCErrorInvalidRequest,
+		},
+		{
+			name:     "rate limited",
+			cfg:      LimitConfig{RequestsPerSecond: 0.001, Burst: 1},
+			requests: 2,
+			body:     `{"method":"sessions.list","id":1}`,
+			status:   http.StatusTooManyRequests,
+			code:     RPCErrorRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigureLimits(tt.cfg)
+			handler := LimitRPC(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+			}))
+
+			var recorder *httptest.ResponseRecorder
+			for range tt.requests {
+				recorder = httptest.NewRecorder()
+				handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(tt.body)))
+			}
+
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.code == 0 {
+				return
+			}
+			var resp api.QueryResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+			require.NotNil(t, resp.Error)
+			assert.Equal(t, tt.code, resp.Error.Code)
+			if tt.status == http.StatusTooManyRequests {
+				assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
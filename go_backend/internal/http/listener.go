@@ -0,0 +1,62 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation
+const listenFDsStart = 3
+
+// Listen returns the listener for the HTTP server. A socket passed by systemd
+// (LISTEN_PID/LISTEN_FDS) takes precedence over addr. Otherwise addr is bound,
+// with SO_REUSEPORT when reusePort is set so a new binary can bind the same
+// port before the old one has drained.
+func Listen(addr string, reusePort bool) (net.Listener, bool, error) {
+	ln, err := activationListener()
+	if err != nil {
+		return nil, false, err
+	}
+	if ln != nil {
+		return ln, true, nil
+	}
+
+	lc := net.ListenConfig{}
+	if reusePort {
+		lc.Control = reusePortControl
+	}
+	ln, err = lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, false, err
+	}
+	return ln, false, nil
+}
+
+// activationListener returns the first socket passed by systemd, or nil if the
+// process was not socket activated.
+func activationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds < 1 {
+		return nil, nil
+	}
+
+	// Children (bash tool, MCP servers) must not think the sockets are theirs
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(uintptr(listenFDsStart), "systemd-socket")
+	defer file.Close()
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,568 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"mix/internal/api"
+	"mix/internal/app"
+	"mix/internal/config"
+	"mix/internal/llm/agent"
+	"mix/internal/llm/tools"
+	"mix/internal/logging"
+	"mix/internal/message"
+	"mix/internal/runqueue"
+	"mix/internal/session"
+	"mix/internal/sessiontemplate"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultChatModel is the model name of /v1/chat/completions that starts
+	// plain sessions; every other model name is a session template's
+	DefaultChatModel = "mix"
+	// ChatSessionHeader names the session a chat completion ran in. Clients
+	// may send it to continue that session.
+	ChatSessionHeader = "X-Mix-Session-Id"
+	// maxChatConversations bounds the conversations remembered to continue
+	// their sessions
+	maxChatConversations = 1000
+)
+
+// chatCompletionRequest is the part of an OpenAI chat completion request
+// mix uses. Sampling parameters are left to the session's model settings.
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type chatMessage struct {
+	Role string `json:"role"`
+	// Content is a string or an array of text, image_url and file parts
+	Content json.RawMessage `json:"content"`
+}
+
+type chatContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+	File struct {
+		// FileData is a base64 data URL
+		FileData string `json:"file_data"`
+		FileID   string `json:"file_id"`
+		Filename string `json:"filename"`
+	} `json:"file"`
+}
+
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int        `json:"index"`
+	Message      *chatDelta `json:"message,omitempty"`
+	Delta        *chatDelta `json:"delta,omitempty"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+type chatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type chatUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type chatModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type chatError struct {
+	Error chatErrorBody `json:"error"`
+}
+
+type chatErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// text returns the message's text and its image and file parts.
+func (m chatMessage) text() (string, []chatContentPart, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return "", nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text, nil, nil
+	}
+	var parts []chatContentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", nil, fmt.Errorf("content must be a string or an array of parts")
+	}
+	var texts []string
+	var media []chatContentPart
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url", "file":
+			media = append(media, part)
+		}
+	}
+	return strings.Join(texts, "\n"), media, nil
+}
+
+// conversationStore remembers which session answered a conversation, so a
+// client resending the whole conversation with a new message continues the
+// session instead of starting another.
+type conversationStore struct {
+	mu       sync.Mutex
+	sessions map[string]string
+	order    []string
+}
+
+var conversations = &conversationStore{sessions: make(map[string]string)}
+
+// conversationKey identifies messages of a conversation with model.
+func conversationKey(model string, messages []chatMessage) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", model)
+	for _, msg := range messages {
+		text, _, _ := msg.text()
+		fmt.Fprintf(h, "%s\x00%s\x00", msg.Role, strings.TrimSpace(text))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *conversationStore) lookup(model string, messages []chatMessage) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[conversationKey(model, messages)]
+}
+
+func (s *conversationStore) remember(model string, messages []chatMessage, sessionID string) {
+	key := conversationKey(model, messages)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.sessions[key] = sessionID
+	for len(s.order) > maxChatConversations {
+		delete(s.sessions, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// chatText collects the text of a run's assistant messages as they stream,
+// separating messages by a blank line.
+type chatText struct {
+	sessionID string
+	written   map[string]int
+	text      strings.Builder
+}
+
+// update returns the text of msg not yet collected.
+func (t *chatText) update(msg message.Message) string {
+	if msg.SessionID != t.sessionID || msg.Role != message.Assistant {
+		return ""
+	}
+	text := msg.Content().Text
+	written := t.written[msg.ID]
+	if len(text) <= written {
+		return ""
+	}
+	delta := text[written:]
+	if written == 0 && t.text.Len() > 0 {
+		delta = "\n\n" + delta
+	}
+	t.written[msg.ID] = len(text)
+	t.text.WriteString(delta)
+	return delta
+}
+
+// HandleModels handles GET /v1/models, listing the default chat model and a
+// model for each session template.
+func HandleModels(handler *api.QueryHandler, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		writeChatError(w, http.StatusMethodNotAllowed, "invalid_request_error", "Only GET method is allowed")
+		return
+	}
+
+	templates, err := handler.GetApp().Templates.List(r.Context())
+	if err != nil {
+		writeChatError(w, http.StatusInternalServerError, "server_error", "Failed to list templates: "+err.Error())
+		return
+	}
+	models := []chatModel{{ID: DefaultChatModel, Object: "model", OwnedBy: "mix"}}
+	for _, template := range templates {
+		models = append(models, chatModel{ID: template.Name, Object: "model", Created: template.CreatedAt, OwnedBy: "mix"})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": models})
+}
+
+// HandleChatCompletions handles POST /v1/chat/completions, running the last
+// user message through the agent with its tools. The model names the session
+// template to start a session from, or DefaultChatModel for a plain session.
+// A conversation resent with a new message continues the session that
+// answered it; ChatSessionHeader names a session to continue explicitly.
+func HandleChatCompletions(ctx context.Context, handler *api.QueryHandler, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+ChatSessionHeader)
+	w.Header().Set("Access-Control-Expose-Headers", ChatSessionHeader)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		writeChatError(w, http.StatusMethodNotAllowed, "invalid_request_error", "Only POST method is allowed")
+		return
+	}
+
+	var req chatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeChatError(w, http.StatusBadRequest, "invalid_request_error", "Failed to parse JSON body: "+err.Error())
+		return
+	}
+	if req.Model == "" {
+		writeChatError(w, http.StatusBadRequest, "invalid_request_error", "Missing required parameter: model")
+		return
+	}
+	if len(req.Messages) == 0 || req.Messages[len(req.Messages)-1].Role != "user" {
+		writeChatError(w, http.StatusBadRequest, "invalid_request_error", "messages must end with a user message")
+		return
+	}
+	history := req.Messages[:len(req.Messages)-1]
+	text, media, err := req.Messages[len(req.Messages)-1].text()
+	if err != nil {
+		writeChatError(w, http.StatusBadRequest, "invalid_request_error", "Invalid last message: "+err.Error())
+		return
+	}
+	attachments, prompt, err := chatAttachments(text, media)
+	if err != nil {
+		writeChatError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	app := handler.GetApp()
+	sessionID := r.Header.Get(ChatSessionHeader)
+	if sessionID == "" {
+		sessionID = conversations.lookup(req.Model, history)
+	}
+	if sessionID != "" {
+		if _, err := app.Sessions.Get(r.Context(), sessionID); err != nil {
+			writeChatError(w, http.StatusNotFound, "invalid_request_error", "Session not found: "+sessionID)
+			return
+		}
+	} else {
+		sess, err := startChatSession(r.Context(), app, req.Model, prompt)
+		if errors.Is(err, sessiontemplate.ErrNotFound) {
+			writeChatError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("Unknown model %q: use %q or a session template name", req.Model, DefaultChatModel))
+			return
+		}
+		if err != nil {
+			writeChatError(w, http.StatusInternalServerError, "server_error", "Failed to start session: "+err.Error())
+			return
+		}
+		sessionID = sess.ID
+		// The new session hasn't seen the conversation so far
+		prompt = withHistory(history, prompt)
+	}
+
+	// Wait for the run queue until the client gives up
+	release, err := app.RunQueue.Acquire(r.Context(), runqueue.Request{Client: clientIP(r), SessionID: sessionID}, nil)
+	if errors.Is(err, runqueue.ErrFull) {
+		writeChatError(w, http.StatusTooManyRequests, "rate_limit_exceeded", err.Error())
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer release()
+
+	before, err := app.Sessions.Get(r.Context(), sessionID)
+	if err != nil {
+		writeChatError(w, http.StatusInternalServerError, "server_error", "Failed to get session: "+err.Error())
+		return
+	}
+
+	// Subscribe before running so no streamed text is missed
+	messagesCtx, unsubscribe := context.WithCancel(r.Context())
+	defer unsubscribe()
+	messageEvents := app.Messages.Subscribe(messagesCtx)
+
+	events, err := app.CoderAgent.RunWithState(ctx, tools.RequestState{SessionID: sessionID}, prompt, attachments...)
+	if errors.Is(err, agent.ErrSessionBusy) {
+		writeChatError(w, http.StatusConflict, "session_busy", err.Error())
+		return
+	}
+	if err != nil {
+		writeChatError(w, http.StatusInternalServerError, "server_error", "Failed to start agent: "+err.Error())
+		return
+	}
+	w.Header().Set(ChatSessionHeader, sessionID)
+
+	completion := chatCompletion{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+	var flusher http.Flusher
+	if req.Stream {
+		var ok bool
+		if flusher, ok = w.(http.Flusher); !ok {
+			app.CoderAgent.Cancel(sessionID)
+			writeChatError(w, http.StatusInternalServerError, "server_error", "Streaming not supported")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		completion.Object = "chat.completion.chunk"
+		writeChatChunk(w, completion, chatDelta{Role: "assistant"}, nil)
+		flusher.Flush()
+	}
+
+	collected := &chatText{sessionID: sessionID, written: make(map[string]int)}
+	send := func(delta string) {
+		if delta == "" || !req.Stream {
+			return
+		}
+		writeChatChunk(w, completion, chatDelta{Content: delta}, nil)
+		flusher.Flush()
+	}
+
+	var runErr error
+run:
+	for {
+		select {
+		case <-r.Context().Done():
+			// The client went away; nobody is left to read the answer
+			app.CoderAgent.Cancel(sessionID)
+			return
+
+		case <-ctx.Done():
+			app.CoderAgent.Cancel(sessionID)
+			return
+
+		case event, ok := <-messageEvents:
+			if ok {
+				send(collected.update(event.Payload))
+			} else {
+				messageEvents = nil
+			}
+
+		case event, ok := <-events:
+			if !ok {
+				if messages, err := app.Messages.List(context.Background(), sessionID); err == nil && len(messages) > 0 {
+					send(collected.update(messages[len(messages)-1]))
+				}
+				break run
+			}
+			if event.Type == agent.AgentEventTypeError {
+				runErr = event.Error
+				break run
+			}
+			if event.Type == agent.AgentEventTypeResponse {
+				send(collected.update(event.Message))
+				if event.Done {
+					break run
+				}
+			}
+		}
+	}
+
+	if runErr != nil {
+		logging.Warn("Chat completion failed", "session", sessionID, "error", runErr)
+		if req.Stream {
+			fmt.Fprintf(w, "data: %s\n\n", mustJSON(chatError{Error: chatErrorBody{Message: runErr.Error(), Type: "server_error"}}))
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			flusher.Flush()
+		} else {
+			writeChatError(w, http.StatusInternalServerError, "server_error", runErr.Error())
+		}
+		return
+	}
+
+	content := collected.text.String()
+	conversations.remember(req.Model, append(req.Messages[:len(req.Messages):len(req.Messages)], chatMessage{
+		Role:    "assistant",
+		Content: mustJSON(content),
+	}), sessionID)
+
+	if after, err := app.Sessions.Get(context.Background(), sessionID); err == nil {
+		completion.Usage = &chatUsage{
+			PromptTokens:     after.PromptTokens - before.PromptTokens,
+			CompletionTokens: after.CompletionTokens - before.CompletionTokens,
+		}
+		completion.Usage.TotalTokens = completion.Usage.PromptTokens + completion.Usage.CompletionTokens
+	}
+
+	stop := "stop"
+	if req.Stream {
+		// The final chunk carries the usage
+		writeChatChunk(w, completion, chatDelta{}, &stop)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+		return
+	}
+	completion.Choices = []chatChoice{{Message: &chatDelta{Role: "assistant", Content: content}, FinishReason: &stop}}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(completion)
+}
+
+// startChatSession starts a session for model, titled after prompt unless a
+// template names it.
+func startChatSession(ctx context.Context, app *app.App, model, prompt string) (session.Session, error) {
+	if model != DefaultChatModel {
+		return app.ApplyTemplate(ctx, model, "", "")
+	}
+	workingDirectory, err := config.LaunchDirectory()
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get launch directory: %w", err)
+	}
+	return app.Sessions.Create(ctx, chatTitle(prompt), workingDirectory)
+}
+
+// chatTitle is the first line of prompt, shortened.
+func chatTitle(prompt string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	if utf8.RuneCountInString(title) > 60 {
+		title = string([]rune(title)[:60]) + "…"
+	}
+	if title == "" {
+		title = "Chat"
+	}
+	return title
+}
+
+// withHistory prefixes prompt with the conversation before it.
+func withHistory(history []chatMessage, prompt string) string {
+	var b strings.Builder
+	for _, msg := range history {
+		text, _, _ := msg.text()
+		if msg.Role == "" || strings.TrimSpace(text) == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString("Earlier messages of this conversation:\n\n")
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", strings.ToUpper(msg.Role[:1])+msg.Role[1:], text)
+	}
+	if b.Len() == 0 {
+		return prompt
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(prompt)
+	return b.String()
+}
+
+// chatAttachments decodes images and files sent as data URLs into
+// attachments, checking their type and size. Other image URLs are added to
+// the prompt for the agent to fetch.
+func chatAttachments(text string, media []chatContentPart) ([]message.Attachment, string, error) {
+	var attachments []message.Attachment
+	images, files := 0, 0
+	for _, part := range media {
+		var label, url, name string
+		if part.Type == "file" {
+			files++
+			label = fmt.Sprintf("file %d", files)
+			if part.File.FileData == "" {
+				return nil, "", fmt.Errorf("%s: file_data is required, uploaded files are not supported", label)
+			}
+			url, name = part.File.FileData, part.File.Filename
+			if !strings.HasPrefix(url, "data:") {
+				// Clients may send the bare base64 content
+				url = "data:;base64," + url
+			}
+		} else {
+			images++
+			label, url = fmt.Sprintf("image %d", images), part.ImageURL.URL
+		}
+		header, data, isData := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if !strings.HasPrefix(url, "data:") || !isData {
+			text += "\n\nImage: " + url
+			continue
+		}
+		mimeType := strings.TrimSuffix(header, ";base64")
+		if !strings.HasSuffix(header, ";base64") {
+			return nil, "", fmt.Errorf("%s: data URLs must be base64 encoded", label)
+		}
+		content, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: invalid base64 data: %v", label, err)
+		}
+		if name == "" {
+			name = strings.ReplaceAll(label, " ", "-")
+			if extensions, _ := mime.ExtensionsByType(mimeType); len(extensions) > 0 {
+				name += extensions[0]
+			}
+		}
+		attachment, err := message.NewAttachment(name, mimeType, content)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %v", label, err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, text, nil
+}
+
+func writeChatChunk(w http.ResponseWriter, completion chatCompletion, delta chatDelta, finishReason *string) {
+	chunk := completion
+	chunk.Choices = []chatChoice{{Delta: &delta, FinishReason: finishReason}}
+	if finishReason == nil {
+		chunk.Usage = nil
+	}
+	fmt.Fprintf(w, "data: %s\n\n", mustJSON(chunk))
+}
+
+func writeChatError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(chatError{Error: chatErrorBody{Message: message, Type: errType}})
+}
+
+func mustJSON(v any) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mix/internal/api"
+	"mix/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+// RecoverInterrupted finishes the responses that were still generating when a
+// previous server process stopped, and logs an "interrupted" event to each
+// session's stream so reconnecting clients replay it. Only messages created
+// before startedAt are considered, leaving this process's own runs alone.
+// With resume set, each session whose latest message was interrupted has its
+// turn generated again.
+func RecoverInterrupted(ctx context.Context, handler *api.QueryHandler, startedAt time.Time, resume bool) {
+	app := handler.GetApp()
+	turns, err := app.RecoverInterruptedTurns(ctx, startedAt.Unix())
+	if err != nil {
+		logging.Error("Failed to recover interrupted responses", "error", err)
+	}
+	for _, turn := range turns {
+		resumed := resume && turn.Latest && !app.CoderAgent.IsSessionBusy(turn.SessionID)
+		stream := requestStream{events: app.StreamEvents, sessionID: turn.SessionID, requestID: uuid.New().String()}
+		stream.send("interrupted", InterruptedEvent{Type: "interrupted", MessageID: turn.MessageID, Resumed: resumed})
+		if resumed {
+			go resumeTurn(ctx, handler, stream)
+		}
+	}
+}
+
+// resumeTurn generates the session's interrupted turn again.
+func resumeTurn(ctx context.Context, handler *api.QueryHandler, stream requestStream) {
+	defer logging.RecoverPanic("resume-turn", nil)
+
+	profile, err := handler.OutputProfile(ctx, stream.sessionID)
+	if err != nil {
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Failed to get output profile: %s", err.Error())})
+		return
+	}
+	stream.output = profile
+
+	release, err := stream.acquireRun(ctx, handler.GetApp())
+	if err != nil {
+		stream.send("error", ErrorEvent{Error: "The interrupted response was not resumed: " + err.Error(), Type: "rate_limited"})
+		return
+	}
+	defer release()
+
+	events, err := handler.GetApp().CoderAgent.Resume(ctx, stream.sessionID)
+	if err != nil {
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Failed to resume agent: %s", err.Error())})
+		return
+	}
+	logging.Info("Resuming interrupted response", "session", stream.sessionID)
+	streamAgentRun(ctx, handler, stream, events)
+}
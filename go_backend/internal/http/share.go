@@ -0,0 +1,88 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"mix/internal/api"
+	"mix/internal/logging"
+	"mix/internal/share"
+	"mix/internal/transcript"
+)
+
+// sharedContentTypes are the content types of the transcript formats
+var sharedContentTypes = map[string]string{
+	transcript.FormatMarkdown: "text/markdown; charset=utf-8",
+	transcript.FormatHTML:     "text/html; charset=utf-8",
+	transcript.FormatJSON:     "application/json",
+}
+
+// HandleShared handles GET /shared/{token}, serving the transcript of the
+// session a share link grants access to. It needs no credentials: the token
+// is the credential, and grants nothing else. The transcript is HTML unless
+// the format query parameter or an Accept header of application/json selects
+// another format. Unknown and revoked links get 404, expired ones 410.
+func HandleShared(handler *api.QueryHandler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, share.URLPrefix)
+	if token == "" || strings.Contains(token, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = transcript.FormatHTML
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			format = transcript.FormatJSON
+		}
+	}
+	if !slices.Contains(transcript.Formats, format) {
+		http.Error(w, "format must be one of: "+strings.Join(transcript.Formats, ", "), http.StatusBadRequest)
+		return
+	}
+
+	app := handler.GetApp()
+	shared, err := app.Shares.Resolve(r.Context(), token)
+	switch {
+	case errors.Is(err, share.ErrInvalidToken):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, share.ErrExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	case err != nil:
+		logging.Error("Failed to resolve share token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sess, content, err := transcript.RenderSession(r.Context(), app.Sessions, app.Messages, shared.SessionID, transcript.Options{Format: format})
+	if err != nil {
+		logging.Error("Failed to render shared session", "session", shared.SessionID, "share", shared.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The token is in the URL, so keep it out of caches, search indexes and
+	// the Referer of links followed from the page
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if format == transcript.FormatHTML {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'")
+	} else {
+		w.Header().Set("Content-Disposition", `inline; filename="`+transcript.Filename(sess, format)+`"`)
+	}
+	w.Header().Set("Content-Type", sharedContentTypes[format])
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		w.Write([]byte(content))
+	}
+}
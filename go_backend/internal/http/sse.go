@@ -3,34 +3,60 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"mix/internal/api"
+	"mix/internal/app"
 	"mix/internal/commands"
+	"mix/internal/config"
+	"mix/internal/eventlog"
 	"mix/internal/fileutil"
 	"mix/internal/llm/agent"
 	"mix/internal/llm/provider"
+	"mix/internal/llm/reminder"
+	"mix/internal/llm/tools"
+	"mix/internal/logging"
+	"mix/internal/metrics"
+	"mix/internal/outputlimit"
 	"mix/internal/pubsub"
+	"mix/internal/runqueue"
+	"mix/internal/streamtoken"
+
+	"github.com/google/uuid"
 )
 
 // Connection represents a single SSE connection
 type Connection struct {
 	SessionID string
+	// IncludeThinking controls whether reasoning is sent in complete events
+	IncludeThinking bool
+	// Schema is the event schema version the stream was opened with
+	Schema int
+	// Client identifies who opened the stream to the run queue
+	Client    string
 	Messages  chan string
 	Done      chan struct{}
 	closeOnce sync.Once
+	// pending counts messages queued or running on this connection
+	pending atomic.Int32
+	// registered orders a session's connections, newest highest
+	registered int64
 }
 
 // ConnectionRegistry manages active SSE connections
 type ConnectionRegistry struct {
 	mu          sync.RWMutex
 	connections map[string]map[*Connection]struct{}
+	registered  int64
 }
 
 // Global connection registry
@@ -38,6 +64,22 @@ var registry = &ConnectionRegistry{
 	connections: make(map[string]map[*Connection]struct{}),
 }
 
+// drain is closed when the server starts draining for a handover
+var (
+	drain     = make(chan struct{})
+	drainOnce sync.Once
+)
+
+// BeginDrain makes idle SSE streams close so clients reconnect to the new
+// listener. Streams with a message in flight finish it first.
+func BeginDrain() {
+	drainOnce.Do(func() { close(drain) })
+}
+
+// reconnectGrace is how long a request keeps running after the last stream
+// for its session disconnects, so a reconnecting client can pick it back up.
+const reconnectGrace = 30 * time.Second
+
 // Register adds a connection to the registry
 func (r *ConnectionRegistry) Register(sessionID string, conn *Connection) {
 	r.mu.Lock()
@@ -45,7 +87,10 @@ func (r *ConnectionRegistry) Register(sessionID string, conn *Connection) {
 	if r.connections[sessionID] == nil {
 		r.connections[sessionID] = make(map[*Connection]struct{})
 	}
+	r.registered++
+	conn.registered = r.registered
 	r.connections[sessionID][conn] = struct{}{}
+	metrics.SSEConnections.Add(1)
 }
 
 // Unregister removes a connection from the registry
@@ -54,6 +99,9 @@ func (r *ConnectionRegistry) Unregister(sessionID string, conn *Connection) {
 	defer r.mu.Unlock()
 
 	if connections, exists := r.connections[sessionID]; exists {
+		if _, registered := connections[conn]; registered {
+			metrics.SSEConnections.Add(-1)
+		}
 		delete(connections, conn)
 		// Clean up empty session entries
 		if len(connections) == 0 {
@@ -62,21 +110,77 @@ func (r *ConnectionRegistry) Unregister(sessionID string, conn *Connection) {
 	}
 }
 
-// Broadcast sends a message to all connections for a sessionID
-func (r *ConnectionRegistry) Broadcast(sessionID, message string) {
+// Sessions returns the sessions with an open stream
+func (r *ConnectionRegistry) Sessions() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	sessions := make([]string, 0, len(r.connections))
+	for sessionID := range r.connections {
+		sessions = append(sessions, sessionID)
+	}
+	return sessions
+}
 
-	connections := r.connections[sessionID]
-	for conn := range connections {
-		select {
-		case conn.Messages <- message:
-		case <-conn.Done:
-			// Connection is closed, skip
-		default:
-			// Channel full, drop message to prevent blocking
+// HasConnections reports whether any stream is open for sessionID
+func (r *ConnectionRegistry) HasConnections(sessionID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.connections[sessionID]) > 0
+}
+
+// Deliver queues a message on the newest connection for sessionID, which runs
+// it. Every connection to the session receives the resulting events.
+func (r *ConnectionRegistry) Deliver(sessionID, message string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var newest *Connection
+	for conn := range r.connections[sessionID] {
+		if newest == nil || conn.registered > newest.registered {
+			newest = conn
 		}
 	}
+	if newest == nil {
+		return false
+	}
+	newest.pending.Add(1)
+	select {
+	case newest.Messages <- message:
+		return true
+	case <-newest.Done:
+	default:
+		// Channel full, drop message to prevent blocking
+	}
+	newest.pending.Add(-1)
+	return false
+}
+
+// requireStreamToken makes /stream and message posts require a token issued by stream.token
+var requireStreamToken bool
+
+// RequireStreamTokens turns stream token enforcement on or off. Tokens that are
+// presented are always validated.
+func RequireStreamTokens(required bool) {
+	requireStreamToken = required
+}
+
+// authorizeStream validates the stream token from the "token" query parameter
+// or a bearer Authorization header against sessionID and scope. Tokens are
+// required with --http-require-stream-token or HTTP API authentication, since
+// the latter only guards the stream.token method that issues them.
+func authorizeStream(handler *api.QueryHandler, r *http.Request, sessionID string, scope streamtoken.Scope) error {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		if requireStreamToken || !auth.Load().bypassed(r) {
+			return fmt.Errorf("missing stream token")
+		}
+		return nil
+	}
+	_, err := handler.GetApp().StreamTokens.Validate(token, sessionID, scope)
+	return err
 }
 
 // HandleSSEStream handles persistent Server-Sent Events streaming for agent responses
@@ -93,30 +197,63 @@ func HandleSSEStream(ctx context.Context, handler *api.QueryHandler, w http.Resp
 		return
 	}
 
+	schema, err := parseEventSchema(r)
+	if err != nil {
+		WriteSSE(w, "error", ErrorEvent{Error: err.Error()})
+		return
+	}
+	w.Header().Set(EventSchemaHeader, strconv.Itoa(schema))
+
 	sessionID := r.URL.Query().Get("sessionId")
 	if sessionID == "" {
-		WriteSSE(w, "error", ErrorEvent{Error: "Missing sessionId parameter"})
+		writeEvent(w, schema, "error", ErrorEvent{Error: "Missing sessionId parameter"})
+		return
+	}
+
+	// Reject with a status code so EventSource stops reconnecting
+	if err := authorizeStream(handler, r, sessionID, streamtoken.ScopeRead); err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	if err := handler.GetApp().SetCurrentSession(sessionID); err != nil {
-		WriteSSE(w, "error", ErrorEvent{Error: "Failed to set session: " + err.Error()})
+		writeEvent(w, schema, "error", ErrorEvent{Error: "Failed to set session: " + err.Error()})
 		return
 	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		WriteSSE(w, "error", ErrorEvent{Error: "Streaming not supported"})
+		writeEvent(w, schema, "error", ErrorEvent{Error: "Streaming not supported"})
 		return
 	}
 
+	since, err := parseLastEventID(r)
+	if err != nil {
+		writeEvent(w, schema, "error", ErrorEvent{Error: err.Error()})
+		return
+	}
+
+	app := handler.GetApp()
+	forwardPermissions(ctx, app)
+	forwardQuestions(ctx, app)
+	forwardConfigChanges(ctx, app)
+	forwardFileChanges(ctx, app)
+
 	// Create connection
 	conn := &Connection{
-		SessionID: sessionID,
-		Messages:  make(chan string, 100),
-		Done:      make(chan struct{}),
+		SessionID:       sessionID,
+		IncludeThinking: r.URL.Query().Get("includeThinking") != "false",
+		Schema:          schema,
+		Client:          clientIP(r),
+		Messages:        make(chan string, 100),
+		Done:            make(chan struct{}),
 	}
 
+	// Subscribe before reading the log so no event falls between replay and live
+	eventsCtx, unsubscribe := context.WithCancel(ctx)
+	defer unsubscribe()
+	events := app.StreamEvents.Subscribe(eventsCtx)
+
 	// Register connection and ensure cleanup
 	registry.Register(sessionID, conn)
 	defer func() {
@@ -127,95 +264,379 @@ func HandleSSEStream(ctx context.Context, handler *api.QueryHandler, w http.Resp
 		})
 	}()
 
+	// Watch the working directory while the session has a stream open
+	if config.Get().FileWatch.Enabled {
+		if sess, err := app.Sessions.Get(ctx, sessionID); err == nil && sess.WorkingDirectory != "" {
+			release, err := app.FileWatch.Watch(sessionID, sess.WorkingDirectory)
+			if err != nil {
+				logging.Warn("Failed to watch working directory", "session", sessionID, "error", err)
+			} else {
+				defer release()
+			}
+		}
+	}
+
 	// Send connection confirmation
-	WriteSSE(w, "connected", ConnectedEvent{SessionID: sessionID})
+	writeEvent(w, schema, "connected", ConnectedEvent{SessionID: sessionID})
 	flusher.Flush()
 
-	// Subscribe to permission events for this session
-	permissionEvents := handler.GetApp().Permissions.Subscribe(ctx)
-
-	// Handle permission events in a separate goroutine with high priority
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-r.Context().Done():
-				return
-			case permissionEvent, ok := <-permissionEvents:
-				if !ok {
-					return
-				}
-
-				// Debug: Log all permission events received
-				fmt.Printf("SSE: Received permission event - Type: %s, EventSessionID: %s, SSESessionID: %s\n", 
-					permissionEvent.Type, permissionEvent.Payload.SessionID, sessionID)
-
-				// Only send permission events for the current session
-				if permissionEvent.Type == pubsub.CreatedEvent && permissionEvent.Payload.SessionID == sessionID {
-					fmt.Printf("SSE: Sending permission event to frontend - ID: %s\n", permissionEvent.Payload.ID)
-					
-					permEvent := PermissionEvent{
-						Type:        "permission",
-						ID:          permissionEvent.Payload.ID,
-						SessionID:   permissionEvent.Payload.SessionID,
-						ToolName:    permissionEvent.Payload.ToolName,
-						Description: permissionEvent.Payload.Description,
-						Action:      permissionEvent.Payload.Action,
-						Path:        permissionEvent.Payload.Path,
-						Params:      permissionEvent.Payload.Params,
-					}
-
-					if err := WriteSSE(w, "permission", permEvent); err != nil {
-						return
-					}
-					flusher.Flush()
-				} else {
-					fmt.Printf("SSE: Filtered out permission event - Type: %s, Session mismatch\n", permissionEvent.Type)
-				}
-			}
+	// Replay what the client missed, then continue from the newest event
+	lastSeq, err := app.StreamEvents.Latest(ctx, sessionID)
+	if err != nil {
+		writeEvent(w, schema, "error", ErrorEvent{Error: "Failed to read event log: " + err.Error()})
+		return
+	}
+	if since >= 0 && since < lastSeq {
+		if lastSeq, err = replayEvents(ctx, w, app.StreamEvents, conn, since); err != nil {
+			return
 		}
-	}()
+		flusher.Flush()
+	}
+
+	finished := make(chan struct{}, 1)
+	go runMessages(ctx, handler, conn, finished)
 
 	// Heartbeat to prevent browser timeout
 	heartbeat := time.NewTicker(45 * time.Second)
 	defer heartbeat.Stop()
 
-	// Main event loop - simple and clean
+	draining := drain
 	for {
 		select {
 		case <-r.Context().Done():
-			// Client disconnected
-			handler.GetApp().CoderAgent.Cancel(sessionID)
+			// Client disconnected; its request keeps running in case it reconnects
+			cancelIfAbandoned(app, sessionID)
 			return
 
 		case <-ctx.Done():
 			// Handler context cancelled (server shutdown, timeout, etc.)
-			handler.GetApp().CoderAgent.Cancel(sessionID)
+			app.CoderAgent.Cancel(sessionID)
 			return
 
+		case <-draining:
+			// Server is handing over; the client reconnects to the new process
+			if conn.pending.Load() == 0 {
+				return
+			}
+			draining = nil
+
+		case <-finished:
+			if draining == nil && conn.pending.Load() == 0 {
+				return
+			}
+
 		case <-heartbeat.C:
-			WriteSSE(w, "heartbeat", HeartbeatEvent{Type: "ping"})
+			writeEvent(w, schema, "heartbeat", HeartbeatEvent{Type: "ping"})
 			flusher.Flush()
 
-		case message, ok := <-conn.Messages:
+		case event, ok := <-events:
 			if !ok {
 				return
 			}
+			logged := event.Payload
+			if logged.SessionID != sessionID || logged.Seq <= lastSeq {
+				continue
+			}
+			if logged.Seq > lastSeq+1 {
+				// Events were dropped from the subscription; fill the gap from the log
+				lastSeq, err = replayEvents(ctx, w, app.StreamEvents, conn, lastSeq)
+			} else {
+				err = writeStreamEvent(w, logged, conn)
+				lastSeq = logged.Seq
+			}
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// parseLastEventID returns the sequence number a reconnecting client last saw,
+// from the Last-Event-ID header EventSource sends or the "since" parameter,
+// or -1 for a new stream.
+func parseLastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("since")
+	}
+	if value == "" {
+		return -1, nil
+	}
+	since, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || since < 0 {
+		return 0, fmt.Errorf("Invalid Last-Event-ID %q", value)
+	}
+	return since, nil
+}
 
-			if err := processMessage(ctx, handler, w, flusher, sessionID, message); err != nil {
+// replayEvents writes the session's logged events after since and returns the
+// last sequence number written.
+func replayEvents(ctx context.Context, w http.ResponseWriter, events eventlog.Service, conn *Connection, since int64) (int64, error) {
+	missed, err := events.Since(ctx, conn.SessionID, since)
+	if err != nil {
+		writeEvent(w, conn.Schema, "error", ErrorEvent{Error: "Failed to replay events: " + err.Error()})
+		return since, err
+	}
+	for _, event := range missed {
+		if err := writeStreamEvent(w, event, conn); err != nil {
+			return since, err
+		}
+		since = event.Seq
+	}
+	return since, nil
+}
+
+// writeStreamEvent writes a logged event in the connection's schema, with its
+// sequence number as the SSE id
+func writeStreamEvent(w http.ResponseWriter, event eventlog.Event, conn *Connection) error {
+	data := []byte(event.Data)
+	if event.Type == "complete" && !conn.IncludeThinking {
+		var complete CompleteEvent
+		if err := json.Unmarshal(data, &complete); err == nil && complete.Reasoning != "" {
+			complete.Reasoning = ""
+			complete.ReasoningDuration = 0
+			data, _ = json.Marshal(complete)
+		}
+	}
+	eventType, data := formatEvent(conn.Schema, event.Type, data)
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, eventType, data); err != nil {
+		return fmt.Errorf("failed to write SSE event: %w", err)
+	}
+	return nil
+}
+
+// runMessages processes the messages delivered to conn one at a time until the
+// connection closes, signalling finished after each.
+func runMessages(ctx context.Context, handler *api.QueryHandler, conn *Connection, finished chan<- struct{}) {
+	defer logging.RecoverPanic("sse-messages", nil)
+	for {
+		select {
+		case <-conn.Done:
+			return
+		case message, ok := <-conn.Messages:
+			if !ok {
 				return
 			}
+			processMessage(runqueue.WithClient(ctx, conn.Client), handler, conn.SessionID, message)
+			conn.pending.Add(-1)
+			select {
+			case finished <- struct{}{}:
+			default:
+			}
 		}
 	}
 }
 
+// cancelIfAbandoned cancels the session's request unless a stream for the
+// session is open again after reconnectGrace.
+func cancelIfAbandoned(app *app.App, sessionID string) {
+	time.AfterFunc(reconnectGrace, func() {
+		if !registry.HasConnections(sessionID) {
+			app.CoderAgent.Cancel(sessionID)
+		}
+	})
+}
+
+// forwarding records the apps whose permission requests are being logged
+var forwarding sync.Map
+
+// forwardPermissions logs the app's permission requests as stream events, once
+// per app, so every stream for the session receives them, including replays.
+func forwardPermissions(ctx context.Context, app *app.App) {
+	if _, started := forwarding.LoadOrStore(app, struct{}{}); started {
+		return
+	}
+	permissionEvents := app.Permissions.Subscribe(ctx)
+	go func() {
+		defer forwarding.Delete(app)
+		for event := range permissionEvents {
+			req := event.Payload
+			stream := requestStream{events: app.StreamEvents, sessionID: req.SessionID}
+			switch event.Type {
+			case pubsub.CreatedEvent:
+				stream.send("permission", PermissionEvent{
+					Type:        "permission",
+					ID:          req.ID,
+					SessionID:   req.SessionID,
+					ToolName:    req.ToolName,
+					Description: req.Description,
+					Action:      req.Action,
+					Path:        req.Path,
+					Params:      req.Params,
+					CreatedAt:   req.CreatedAt.Unix(),
+				})
+			case pubsub.UpdatedEvent:
+				stream.send("permission_resolved", PermissionResolvedEvent{
+					Type:       "permission_resolved",
+					ID:         req.ID,
+					SessionID:  req.SessionID,
+					Resolution: string(req.Resolution),
+				})
+			}
+		}
+	}()
+}
+
+// forwardingQuestions records the apps whose ask_user questions are being
+// logged
+var forwardingQuestions sync.Map
+
+// forwardQuestions logs the app's ask_user questions and their resolutions as
+// stream events, once per app, like forwardPermissions.
+func forwardQuestions(ctx context.Context, app *app.App) {
+	if _, started := forwardingQuestions.LoadOrStore(app, struct{}{}); started {
+		return
+	}
+	questionEvents := app.Questions.Subscribe(ctx)
+	go func() {
+		defer forwardingQuestions.Delete(app)
+		for event := range questionEvents {
+			q := event.Payload
+			stream := requestStream{events: app.StreamEvents, sessionID: q.SessionID}
+			switch event.Type {
+			case pubsub.CreatedEvent:
+				stream.send("question", QuestionEvent{
+					Type:          "question",
+					ID:            q.ID,
+					SessionID:     q.SessionID,
+					ToolCallID:    q.ToolCallID,
+					Question:      q.Question,
+					Options:       q.Options,
+					AllowFreeText: q.AllowFreeText,
+					CreatedAt:     q.CreatedAt.Unix(),
+				})
+			case pubsub.UpdatedEvent:
+				stream.send("question_resolved", QuestionResolvedEvent{
+					Type:       "question_resolved",
+					ID:         q.ID,
+					SessionID:  q.SessionID,
+					Resolution: string(q.Resolution),
+					Answer:     q.Answer,
+				})
+			}
+		}
+	}()
+}
+
+// forwardingConfig records the apps whose config reloads are being logged
+var forwardingConfig sync.Map
+
+// forwardConfigChanges logs each configuration reload of the app, once per
+// app, to the streams of every session that has one open.
+func forwardConfigChanges(ctx context.Context, app *app.App) {
+	if _, started := forwardingConfig.LoadOrStore(app, struct{}{}); started {
+		return
+	}
+	changes := app.ConfigChanges.Subscribe(ctx)
+	go func() {
+		defer forwardingConfig.Delete(app)
+		for event := range changes {
+			for _, sessionID := range registry.Sessions() {
+				stream := requestStream{events: app.StreamEvents, sessionID: sessionID}
+				stream.send("config_changed", ConfigChangedEvent{
+					Type:            "config_changed",
+					Changed:         event.Payload.Changed,
+					RestartRequired: event.Payload.RestartRequired,
+				})
+			}
+		}
+	}()
+}
+
+// forwardingFiles records the apps whose working directory changes are being
+// logged
+var forwardingFiles sync.Map
+
+// forwardFileChanges logs the changes to the watched working directories as
+// stream events of their sessions, once per app.
+func forwardFileChanges(ctx context.Context, app *app.App) {
+	if _, started := forwardingFiles.LoadOrStore(app, struct{}{}); started {
+		return
+	}
+	fileEvents := app.FileWatch.Subscribe(ctx)
+	go func() {
+		defer forwardingFiles.Delete(app)
+		for event := range fileEvents {
+			changes := event.Payload
+			stream := requestStream{events: app.StreamEvents, sessionID: changes.SessionID}
+			stream.send("files_changed", FilesChangedEvent{
+				Type:      "files_changed",
+				Changes:   changes.Changes,
+				Truncated: changes.Truncated,
+			})
+		}
+	}()
+}
+
+// requestStream logs the events of one request, from which every stream for
+// the session sends them.
+type requestStream struct {
+	events    eventlog.Service
+	sessionID string
+	requestID string
+	// output is the session's output profile
+	output config.OutputProfile
+}
+
+func (s requestStream) send(eventType string, data any) {
+	if _, err := s.events.Append(context.Background(), s.sessionID, s.requestID, eventType, data); err != nil {
+		logging.Error("Failed to log stream event", "session", s.sessionID, "event", eventType, "error", err)
+	}
+}
+
+// acquireRun waits for a slot in the run queue, sending a "queued" event
+// each time the request's place in the queue changes.
+func (s requestStream) acquireRun(ctx context.Context, app *app.App) (func(), error) {
+	queue := app.RunQueue
+	return queue.Acquire(ctx, runqueue.Request{Client: runqueue.Client(ctx), SessionID: s.sessionID}, func(position int) {
+		s.send("queued", QueuedEvent{Type: "queued", Position: position, Queued: queue.Status().Queued})
+	})
+}
+
+// complete sends the agent's final response, limited to the output profile.
+func (s requestStream) complete(messageID, content, reasoning string, reasoningDuration int64) {
+	output, err := outputlimit.Apply(s.output, s.sessionID, messageID, content)
+	if err != nil {
+		s.send("error", ErrorEvent{Error: fmt.Sprintf("Failed to limit response: %s", err.Error())})
+		return
+	}
+	last := len(output.Parts) - 1
+	for i, part := range output.Parts[:last] {
+		s.send("continuation", ContinuationEvent{Type: "continuation", MessageID: messageID, Content: part, Part: i + 1, Parts: len(output.Parts)})
+	}
+	event := CompleteEvent{
+		Type:              "complete",
+		Content:           output.Parts[last],
+		MessageID:         messageID,
+		Done:              true,
+		Reasoning:         reasoning,
+		ReasoningDuration: reasoningDuration,
+		Truncated:         output.Truncated,
+		ArtifactID:        output.ArtifactID,
+	}
+	if last > 0 {
+		event.Part = last + 1
+		event.Parts = last + 1
+	}
+	s.send("complete", event)
+}
+
 // MessageContent represents the JSON structure sent from frontend
 type MessageContent struct {
 	Text     string   `json:"text"`
 	Media    []string `json:"media,omitempty"`
 	Apps     []string `json:"apps,omitempty"`
 	PlanMode bool     `json:"plan_mode,omitempty"`
+	// Reminders names the reminders attached to this turn
+	Reminders []string `json:"reminders,omitempty"`
+	// DryRun has the turn's tools describe their effects instead of executing
+	DryRun bool `json:"dry_run,omitempty"`
+	// Thinking replaces the agent's thinking settings for the turn
+	Thinking *config.Thinking `json:"thinking,omitempty"`
+	// Queue waits for the session's running turn, e.g. one started from
+	// another client, instead of failing while it generates
+	Queue bool `json:"queue,omitempty"`
 }
 
 // extractText parses JSON content to extract the actual text value
@@ -251,7 +672,7 @@ func quotePaths(text string, mediaPaths []string) string {
 }
 
 // handleShellCommand executes shell commands for ! prefixed messages
-func handleShellCommand(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, text string) error {
+func handleShellCommand(ctx context.Context, stream requestStream, text string) {
 	command := strings.TrimSpace(strings.TrimPrefix(text, "!"))
 	if command == "" {
 		command = "echo 'No command specified'"
@@ -265,19 +686,16 @@ func handleShellCommand(ctx context.Context, w http.ResponseWriter, flusher http
 		result = fmt.Sprintf("Error: %v\n%s", err, result)
 	}
 
-	WriteSSE(w, "complete", CompleteEvent{Type: "complete", Content: result, Done: true})
-	flusher.Flush()
-	return nil
+	stream.send("complete", CompleteEvent{Type: "complete", Content: result, Done: true})
 }
 
 // handleRegularMessage processes regular messages through the agent
-func handleRegularMessage(ctx context.Context, handler *api.QueryHandler, w http.ResponseWriter, flusher http.Flusher, sessionID, text string, planMode bool) error {
+func handleRegularMessage(ctx context.Context, handler *api.QueryHandler, stream requestStream, text string, msgContent MessageContent) {
 	// Check authentication status before processing the message using the centralized function
 	authenticated, _, authErr := provider.IsAuthenticated()
 	if authErr != nil {
-		WriteSSE(w, "error", ErrorEvent{Error: fmt.Sprintf("Error checking authentication: %s", authErr.Error())})
-		flusher.Flush()
-		return nil
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Error checking authentication: %s", authErr.Error())})
+		return
 	}
 	
 	// If not authenticated, show a clear error message
@@ -288,33 +706,59 @@ func handleRegularMessage(ctx context.Context, handler *api.QueryHandler, w http
 			"2. Create an API key\n" +
 			"3. Use the /login command to authenticate"
 		
-		WriteSSE(w, "error", ErrorEvent{
+		stream.send("error", ErrorEvent{
 			Error: helpfulMsg,
 			Type: "authentication_error",
 		})
-		flusher.Flush()
-		return nil
+		return
 	}
 	
-	// If authenticated, proceed with normal message processing
-	events, err := handler.GetApp().CoderAgent.RunWithPlanMode(ctx, sessionID, text, planMode)
+	if err := reminder.Validate(msgContent.Reminders); err != nil {
+		stream.send("error", ErrorEvent{Error: err.Error()})
+		return
+	}
+	if err := msgContent.Thinking.Validate(); err != nil {
+		stream.send("error", ErrorEvent{Error: err.Error()})
+		return
+	}
+
+	// If authenticated, proceed with normal message processing; the run
+	// queue slot is taken once the turn may start
+	events, err := handler.GetApp().CoderAgent.RunWithState(ctx, tools.RequestState{
+		SessionID: stream.sessionID,
+		PlanMode:  msgContent.PlanMode,
+		Reminders: msgContent.Reminders,
+		DryRun:    msgContent.DryRun,
+		Thinking:  msgContent.Thinking,
+		Queue:     msgContent.Queue,
+		AcquireRun: func(ctx context.Context) (func(), error) {
+			return stream.acquireRun(ctx, handler.GetApp())
+		},
+	}, text)
+	if errors.Is(err, runqueue.ErrFull) {
+		stream.send("error", ErrorEvent{Error: err.Error(), Type: "rate_limited"})
+		return
+	}
 	if err != nil {
-		WriteSSE(w, "error", ErrorEvent{Error: fmt.Sprintf("Failed to start agent: %s", err.Error())})
-		flusher.Flush()
-		return nil
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Failed to start agent: %s", err.Error())})
+		return
 	}
+	streamAgentRun(ctx, handler, stream, events)
+}
 
+// streamAgentRun logs the events of an agent run until it completes.
+func streamAgentRun(ctx context.Context, handler *api.QueryHandler, stream requestStream, events <-chan agent.AgentEvent) {
 	for {
 		select {
 		case <-ctx.Done():
-			handler.GetApp().CoderAgent.Cancel(sessionID)
-			return ctx.Err()
+			handler.GetApp().CoderAgent.Cancel(stream.sessionID)
+			return
 
 		case event, ok := <-events:
 			if !ok {
 				var content, messageID, reasoning string
 				var reasoningDuration int64
-				if messages, err := handler.GetApp().Messages.List(context.Background(), sessionID); err == nil && len(messages) > 0 {
+				if messages, err := handler.GetApp().Messages.ListWithReasoning(context.Background(), stream.sessionID); err == nil && len(messages) > 0 {
 					lastMessage := messages[len(messages)-1]
 					if lastMessage.Role == "assistant" {
 						content = lastMessage.Content().String()
@@ -324,28 +768,37 @@ func handleRegularMessage(ctx context.Context, handler *api.QueryHandler, w http
 						reasoningDuration = reasoningContent.Duration
 					}
 				}
-				WriteSSE(w, "complete", CompleteEvent{Type: "complete", Content: content, MessageID: messageID, Done: true, Reasoning: reasoning, ReasoningDuration: reasoningDuration})
-				flusher.Flush()
-				return nil
+				stream.complete(messageID, content, reasoning, reasoningDuration)
+				return
 			}
 
-			if err := WriteAgentEventAsSSE(w, event); err != nil {
-				return err
-			}
-			flusher.Flush()
+			sendAgentEvent(stream, event)
 
 			if event.Error != nil || event.Done {
-				return nil
+				return
 			}
 		}
 	}
 }
 
-// processMessage processes a single message and streams the response
-func processMessage(ctx context.Context, handler *api.QueryHandler, w http.ResponseWriter, flusher http.Flusher, sessionID, content string) error {
+// processMessage runs a single message, logging its events for the session's streams
+func processMessage(ctx context.Context, handler *api.QueryHandler, sessionID string, content string) {
+	events := handler.GetApp().StreamEvents
+	if err := events.Prune(ctx, sessionID); err != nil {
+		logging.Warn("Failed to prune stream events", "session", sessionID, "error", err)
+	}
+	stream := requestStream{events: events, sessionID: sessionID, requestID: uuid.New().String()}
+	profile, err := handler.OutputProfile(ctx, sessionID)
+	if err != nil {
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Failed to get output profile: %s", err.Error())})
+		return
+	}
+	stream.output = profile
+
 	msgContent, err := parseMessageContent(content)
 	if err != nil {
-		return err
+		stream.send("error", ErrorEvent{Error: err.Error()})
+		return
 	}
 
 	text := msgContent.Text
@@ -354,49 +807,44 @@ func processMessage(ctx context.Context, handler *api.QueryHandler, w http.Respo
 	case strings.HasPrefix(text, "/"):
 		// Quote paths in slash commands if they contain file references
 		quotedText := quotePaths(text, msgContent.Media)
-		return handleSlashCommandStreaming(ctx, handler, w, flusher, sessionID, quotedText)
+		handleSlashCommandStreaming(ctx, handler, stream, quotedText)
 	case strings.HasPrefix(text, "!"):
 		// Quote paths in shell commands
 		quotedText := quotePaths(text, msgContent.Media)
-		return handleShellCommand(ctx, w, flusher, quotedText)
+		handleShellCommand(ctx, stream, quotedText)
 	default:
-		return handleRegularMessage(ctx, handler, w, flusher, sessionID, text, msgContent.PlanMode)
+		handleRegularMessage(ctx, handler, stream, text, msgContent)
 	}
 }
 
 // handleSlashCommandStreaming processes slash commands for persistent connections
-func handleSlashCommandStreaming(ctx context.Context, handler *api.QueryHandler, w http.ResponseWriter, flusher http.Flusher, sessionID, content string) error {
+func handleSlashCommandStreaming(ctx context.Context, handler *api.QueryHandler, stream requestStream, content string) {
 	parsedCmd, err := commands.ParseCommand(content)
 	if err != nil {
-		WriteSSE(w, "error", ErrorEvent{Error: fmt.Sprintf("Invalid slash command: %s", err.Error())})
-		flusher.Flush()
-		return nil
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Invalid slash command: %s", err.Error())})
+		return
 	}
 
 	reg := commands.NewRegistry()
 	if err := reg.LoadCommands(handler.GetApp()); err != nil {
-		WriteSSE(w, "error", ErrorEvent{Error: fmt.Sprintf("Failed to load commands: %s", err.Error())})
-		flusher.Flush()
-		return nil
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Failed to load commands: %s", err.Error())})
+		return
 	}
 
 	result, err := reg.ExecuteCommand(ctx, parsedCmd.Name, parsedCmd.Arguments)
 	if err != nil {
-		WriteSSE(w, "error", ErrorEvent{Error: fmt.Sprintf("Command execution failed: %s", err.Error())})
-		flusher.Flush()
-		return nil
+		stream.send("error", ErrorEvent{Error: fmt.Sprintf("Command execution failed: %s", err.Error())})
+		return
 	}
 
-	WriteSSE(w, "complete", CompleteEvent{Type: "complete", Content: result, Done: true})
-	flusher.Flush()
-	return nil
+	stream.send("complete", CompleteEvent{Type: "complete", Content: result, Done: true})
 }
 
 // HandleMessageQueue handles POST requests to add messages to session queues
-func HandleMessageQueue(w http.ResponseWriter, r *http.Request) {
+func HandleMessageQueue(handler *api.QueryHandler, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -415,6 +863,11 @@ func HandleMessageQueue(w http.ResponseWriter, r *http.Request) {
 	}
 	sessionID := pathParts[1]
 
+	if err := authorizeStream(handler, r, sessionID, streamtoken.ScopeWrite); err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
@@ -434,20 +887,24 @@ func HandleMessageQueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Broadcast message to all active connections for this session
-	registry.Broadcast(sessionID, reqData.Content)
+	// Queue the message on one stream for this session; all of them get the events
+	status := "queued"
+	if !registry.Deliver(sessionID, reqData.Content) {
+		status = "dropped"
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	response := map[string]interface{}{
-		"status":    "broadcasted",
+		"status":    status,
 		"sessionId": sessionID,
 	}
 	json.NewEncoder(w).Encode(response)
 }
 
-// WriteAgentEventAsSSE converts an AgentEvent to SSE format using unified event types
-func WriteAgentEventAsSSE(w http.ResponseWriter, event agent.AgentEvent) error {
+// sendAgentEvent converts an AgentEvent to SSE events using unified event types.
+// Complete events carry reasoning; streams that opted out of thinking drop it.
+func sendAgentEvent(stream requestStream, event agent.AgentEvent) {
 	switch event.Type {
 	case agent.AgentEventTypeResponse:
 		// Stream tool calls - detect new tool calls by checking completion status
@@ -459,26 +916,18 @@ func WriteAgentEventAsSSE(w http.ResponseWriter, event agent.AgentEvent) error {
 				status = "completed"
 			}
 
-			if err := WriteSSE(w, "tool", ToolEvent{Type: "tool", Name: toolCall.Name, Input: toolCall.Input, ID: toolCall.ID, Status: status}); err != nil {
-				return err
-			}
+			stream.send("tool", ToolEvent{Type: "tool", Name: toolCall.Name, Input: toolCall.Input, ID: toolCall.ID, Status: status})
 		}
 
 		// Send completion event only for final events, include final content
 		if event.Done {
 			// Check if this is a permission denied error
 			if event.Message.FinishReason() == "permission_denied" {
-				if err := WriteSSE(w, "error", ErrorEvent{Error: "Permission denied"}); err != nil {
-					return err
-				}
+				stream.send("error", ErrorEvent{Error: "Permission denied"})
 			} else {
 				content := event.Message.Content().String()
 				reasoningContent := event.Message.ReasoningContent()
-				reasoning := reasoningContent.String()
-				reasoningDuration := reasoningContent.Duration
-				if err := WriteSSE(w, "complete", CompleteEvent{Type: "complete", Content: content, MessageID: event.Message.ID, Done: true, Reasoning: reasoning, ReasoningDuration: reasoningDuration}); err != nil {
-					return err
-				}
+				stream.complete(event.Message.ID, content, reasoningContent.String(), reasoningContent.Duration)
 			}
 		}
 
@@ -512,9 +961,7 @@ func WriteAgentEventAsSSE(w http.ResponseWriter, event agent.AgentEvent) error {
 				MaxAttempts: maxAttempts,
 			}
 			
-			if err := WriteSSE(w, "rate_limit_error", errorEvent); err != nil {
-				return err
-			}
+			stream.send("rate_limit_error", errorEvent)
 			
 		// Special handling for authentication errors
 		} else if strings.Contains(errMsg, "authentication_error") ||
@@ -522,21 +969,28 @@ func WriteAgentEventAsSSE(w http.ResponseWriter, event agent.AgentEvent) error {
 			strings.Contains(errMsg, "401 Unauthorized") {
 			// Create a more helpful error message
 			helpfulMsg := "Authentication failed: Not logged in or token expired. Please use /login to authenticate with Claude Code."
-			if err := WriteSSE(w, "error", ErrorEvent{Error: helpfulMsg}); err != nil {
-				return err
-			}
+			stream.send("error", ErrorEvent{Error: helpfulMsg})
+		} else if errors.Is(event.Error, runqueue.ErrFull) {
+			// A queued follow-up found the run queue full once it could start
+			stream.send("error", ErrorEvent{Error: errMsg, Type: "rate_limited"})
 		} else {
 			// Normal error handling
-			if err := WriteSSE(w, "error", ErrorEvent{Error: errMsg}); err != nil {
-				return err
-			}
+			stream.send("error", ErrorEvent{Error: errMsg})
 		}
 
+	case agent.AgentEventTypeToolInput:
+		stream.send("tool_input", ToolInputEvent{Type: "tool_input", ID: event.ToolInput.ToolCallID, Name: event.ToolInput.Name, MessageID: event.ToolInput.MessageID, Delta: event.ToolInput.Delta})
+
+	case agent.AgentEventTypeUsage:
+		stream.send("usage", UsageEvent{Type: "usage", InputTokens: event.Usage.InputTokens, OutputTokens: event.Usage.OutputTokens, Cost: event.Usage.Cost, SessionCost: event.Usage.SessionCost})
+
 	case agent.AgentEventTypeSummarize:
-		if err := WriteSSE(w, "summarize", SummarizeEvent{Type: "summarize", Progress: event.Progress, Done: event.Done}); err != nil {
-			return err
-		}
-	}
+		stream.send("summarize", SummarizeEvent{Type: "summarize", Progress: event.Progress, Done: event.Done})
 
-	return nil
+	case agent.AgentEventTypeWarning:
+		stream.send("warning", WarningEvent{Type: "warning", Code: event.Warning.Code, Message: event.Warning.Message, DroppedMessages: event.Warning.DroppedMessages})
+
+	case agent.AgentEventTypeQueued:
+		stream.send("message_queued", MessageQueuedEvent{Type: "message_queued", ID: event.Queued.ID, Content: event.Queued.Content, Position: event.Queued.Position})
+	}
 }
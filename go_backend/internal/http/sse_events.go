@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"mix/internal/filewatch"
 )
 
 // SSE Event Types - Keep structs for type safety but remove interface overhead
@@ -31,6 +33,31 @@ type CompleteEvent struct {
 	Done              bool   `json:"done"`
 	Reasoning         string `json:"reasoning,omitempty"`
 	ReasoningDuration int64  `json:"reasoningDuration,omitempty"`
+	// Set when the session's output profile limited the response; a split
+	// response ends with its last part here
+	Part       int    `json:"part,omitempty"`
+	Parts      int    `json:"parts,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	ArtifactID string `json:"artifactId,omitempty"`
+}
+
+// ContinuationEvent is a part of a split response other than the last, sent
+// in order before the complete event.
+type ContinuationEvent struct {
+	Type      string `json:"type"`
+	MessageID string `json:"messageId"`
+	Content   string `json:"content"`
+	Part      int    `json:"part"`
+	Parts     int    `json:"parts"`
+}
+
+// InterruptedEvent reports a response the previous server process stopped
+// generating. Resumed is set when the response is being generated again; its
+// events follow as for any other request.
+type InterruptedEvent struct {
+	Type      string `json:"type"`
+	MessageID string `json:"messageId"`
+	Resumed   bool   `json:"resumed"`
 }
 
 type ToolEvent struct {
@@ -41,6 +68,36 @@ type ToolEvent struct {
 	Status string `json:"status"`
 }
 
+// ToolInputEvent carries arguments a tool call streamed since its last
+// tool_input event; appending each Delta to the earlier ones rebuilds the
+// partial JSON input.
+type ToolInputEvent struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	MessageID string `json:"messageId"`
+	Delta     string `json:"delta"`
+}
+
+// UsageEvent is a running estimate sent while a response streams; the session's
+// recorded cost is updated with exact usage when the response completes.
+type UsageEvent struct {
+	Type         string  `json:"type"`
+	InputTokens  int64   `json:"inputTokens"`
+	OutputTokens int64   `json:"outputTokens"`
+	Cost         float64 `json:"cost"`
+	SessionCost  float64 `json:"sessionCost"`
+}
+
+// WarningEvent reports something that changed a turn without failing it, such
+// as older messages left out of a request that exceeded the context window.
+type WarningEvent struct {
+	Type            string `json:"type"`
+	Code            string `json:"code"`
+	Message         string `json:"message"`
+	DroppedMessages int    `json:"droppedMessages,omitempty"`
+}
+
 type SummarizeEvent struct {
 	Type     string `json:"type"`
 	Progress string `json:"progress"`
@@ -56,6 +113,87 @@ type PermissionEvent struct {
 	Action      string      `json:"action"`
 	Path        string      `json:"path"`
 	Params      interface{} `json:"params"`
+	CreatedAt   int64       `json:"createdAt"`
+}
+
+// PermissionResolvedEvent tells clients still showing a permission prompt
+// that it was answered elsewhere or timed out.
+type PermissionResolvedEvent struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	SessionID  string `json:"sessionId"`
+	Resolution string `json:"resolution"`
+}
+
+// QuestionEvent asks the user a question for the agent, to answer with
+// questions.answer.
+type QuestionEvent struct {
+	Type          string   `json:"type"`
+	ID            string   `json:"id"`
+	SessionID     string   `json:"sessionId"`
+	ToolCallID    string   `json:"toolCallId"`
+	Question      string   `json:"question"`
+	Options       []string `json:"options,omitempty"`
+	AllowFreeText bool     `json:"allowFreeText"`
+	CreatedAt     int64    `json:"createdAt"`
+}
+
+// QuestionResolvedEvent tells clients still showing a question that it was
+// answered elsewhere, timed out or its turn was canceled.
+type QuestionResolvedEvent struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	SessionID  string `json:"sessionId"`
+	Resolution string `json:"resolution"`
+	Answer     string `json:"answer,omitempty"`
+}
+
+// QueuedEvent tells a client its request waits for the server's agent run
+// limit, and its 1-based place among the Queued waiting requests.
+type QueuedEvent struct {
+	Type     string `json:"type"`
+	Position int    `json:"position"`
+	Queued   int    `json:"queued"`
+}
+
+// MessageQueuedEvent tells a client its message waits behind the session's
+// running turn, at the 1-based Position among the session's queued messages.
+// Its events follow once it starts; messages.removeQueued drops it.
+type MessageQueuedEvent struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Content  string `json:"content"`
+	Position int    `json:"position"`
+}
+
+// ConfigChangedEvent tells clients the server reloaded its configuration, e.g.
+// to refresh the model or MCP tool lists they show.
+type ConfigChangedEvent struct {
+	Type            string   `json:"type"`
+	Changed         []string `json:"changed"`
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// FilesChangedEvent lists the files created, modified and deleted in the
+// session's working directory, so clients can refresh their file trees.
+type FilesChangedEvent struct {
+	Type      string             `json:"type"`
+	Changes   []filewatch.Change `json:"changes"`
+	Truncated bool               `json:"truncated,omitempty"`
+}
+
+// JobCompletedEvent reports a scheduled job run that finished, logged to the
+// stream of the session the run created.
+type JobCompletedEvent struct {
+	Type       string  `json:"type"`
+	Job        string  `json:"job"`
+	SessionID  string  `json:"sessionId"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	Response   string  `json:"response"`
+	Cost       float64 `json:"cost"`
+	StartedAt  int64   `json:"startedAt"`
+	FinishedAt int64   `json:"finishedAt"`
 }
 
 // WriteSSE serializes and writes an SSE event to the response writer
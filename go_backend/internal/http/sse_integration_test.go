@@ -121,7 +121,7 @@ func setupTestServer(t *testing.T) (*httptest.Server, *app.App, string) {
 		t.Logf("Stream sub-path request received: %s %s", r.Method, r.URL.String())
 		// Handle stream endpoints
 		if strings.HasSuffix(r.URL.Path, "/message") {
-			HandleMessageQueue(w, r)
+			HandleMessageQueue(handler, w, r)
 		} else {
 			http.NotFound(w, r)
 		}
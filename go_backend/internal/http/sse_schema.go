@@ -0,0 +1,114 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Stream event schema versions, chosen with the stream's "schema" parameter.
+// Version 1 is the original format and stays the default so existing clients
+// keep working.
+const (
+	EventSchemaLegacy = 1
+	EventSchemaV2     = 2
+	// LatestEventSchema is the newest version the server can send
+	LatestEventSchema = EventSchemaV2
+)
+
+// EventSchemaHeader names the response header reporting the schema a stream
+// was opened with
+const EventSchemaHeader = "Mix-Event-Schema"
+
+// v2EventNames maps the logged event types renamed in schema 2; the others
+// keep their names. Tool events are named after their status.
+var v2EventNames = map[string]string{
+	"continuation":        "message.delta",
+	"complete":            "done",
+	"tool_input":          "tool.input",
+	"rate_limit_error":    "error",
+	"permission":          "permission.request",
+	"permission_resolved": "permission.resolved",
+	"question":            "question.asked",
+	"question_resolved":   "question.resolved",
+	"interrupted":         "message.interrupted",
+	"config_changed":      "config.changed",
+	"files_changed":       "files.changed",
+	"job_completed":       "job.completed",
+	"queued":              "run.queued",
+	"message_queued":      "message.queued",
+}
+
+// parseEventSchema returns the schema version the client asked for with the
+// "schema" parameter, or the legacy version when it didn't.
+func parseEventSchema(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("schema")
+	if value == "" {
+		return EventSchemaLegacy, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < EventSchemaLegacy || version > LatestEventSchema {
+		return EventSchemaLegacy, fmt.Errorf("Unsupported event schema %q, supported versions are %d to %d", value, EventSchemaLegacy, LatestEventSchema)
+	}
+	return version, nil
+}
+
+// formatEvent returns the name and data of an event in the given schema.
+// Events are logged in the legacy format and converted as they are written, so
+// a replay follows the schema of the stream receiving it.
+//
+// Schema 2 names events by what they report and gives every payload its name
+// as "type" and the schema as "schemaVersion". The kind of an error, which the
+// legacy format sends as "type", moves to "code".
+func formatEvent(schema int, eventType string, data []byte) (string, []byte) {
+	if schema < EventSchemaV2 {
+		return eventType, data
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return eventType, data
+	}
+
+	name, renamed := v2EventNames[eventType]
+	if !renamed {
+		name = eventType
+	}
+	switch eventType {
+	case "tool":
+		var status string
+		json.Unmarshal(fields["status"], &status)
+		name = "tool.start"
+		if status == "completed" {
+			name = "tool.end"
+		}
+	case "error", "rate_limit_error":
+		if kind, ok := fields["type"]; ok {
+			fields["code"] = kind
+		}
+	case "complete":
+		delete(fields, "done")
+	}
+	fields["type"], _ = json.Marshal(name)
+	fields["schemaVersion"], _ = json.Marshal(schema)
+
+	formatted, err := json.Marshal(fields)
+	if err != nil {
+		return eventType, data
+	}
+	return name, formatted
+}
+
+// writeEvent writes an event that isn't logged, such as a heartbeat, in the
+// given schema.
+func writeEvent(w http.ResponseWriter, schema int, eventType string, data any) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SSE event data: %w", err)
+	}
+	name, jsonData := formatEvent(schema, eventType, jsonData)
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, jsonData); err != nil {
+		return fmt.Errorf("failed to write SSE event: %w", err)
+	}
+	return nil
+}
@@ -103,6 +103,9 @@ func HandleVideoExport(ctx context.Context, handler *api.QueryHandler, w http.Re
 		})
 		return
 	}
+	if req.SessionID != "" {
+		appInstance.UploadSessionAssets(req.SessionID)
+	}
 
 	// Return successful response
 	response := VideoExportResponse{
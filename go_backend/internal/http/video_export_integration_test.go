@@ -3,7 +3,6 @@ package http
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -192,7 +191,7 @@ func TestVideoExportHTTPEndpoint(t *testing.T) {
 }
 
 // setupTestDB creates a test database connection and returns cleanup function
-func setupTestDB(t *testing.T) (*sql.DB, func()) {
+func setupTestDB(t *testing.T) (*db.DB, func()) {
 	// Set up test configuration directories
 	testConfigDir := "/tmp/test-mix-video-" + t.Name()
 	testDataDir := "/tmp/test-mix-data-video-" + t.Name()
@@ -0,0 +1,143 @@
+// Package i18n is the message catalog for strings the server shows to users,
+// such as slash command responses. Messages are looked up by a stable key,
+// which responses also carry as their code, so clients can rely on the code
+// and display the text as is.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the fallback for locales and keys without a translation
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+var (
+	mu       sync.RWMutex
+	catalogs = make(map[string]map[string]string)
+)
+
+func init() {
+	entries, err := builtinLocales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := builtinLocales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		if err := add(entry.Name(), data); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// add merges a <locale>.json catalog, overriding existing messages.
+func add(fileName string, data []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("invalid catalog %s: %w", fileName, err)
+	}
+	locale := normalize(strings.TrimSuffix(fileName, ".json"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if catalogs[locale] == nil {
+		catalogs[locale] = make(map[string]string, len(messages))
+	}
+	for key, message := range messages {
+		catalogs[locale][key] = message
+	}
+	return nil
+}
+
+// LoadDir adds the <locale>.json catalogs in dir, for new locales or to
+// override built-in messages.
+func LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := add(filepath.Base(path), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// Supported reports whether messages are available for locale or its
+// language, e.g. "de" for "de-AT".
+func Supported(locale string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	locale = normalize(locale)
+	if _, ok := catalogs[locale]; ok {
+		return true
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	_, ok := catalogs[language]
+	return ok
+}
+
+// Locales lists the available locales.
+func Locales() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Args are the values substituted for {name} placeholders in a message.
+type Args map[string]any
+
+// T returns the message for key in locale with args substituted, falling back
+// to the locale's language, then to English, then to the key itself.
+func T(locale, key string, args Args) string {
+	locale = normalize(locale)
+	language, _, _ := strings.Cut(locale, "-")
+
+	mu.RLock()
+	message, ok := catalogs[locale][key]
+	if !ok {
+		message, ok = catalogs[language][key]
+	}
+	if !ok {
+		message, ok = catalogs[DefaultLocale][key]
+	}
+	mu.RUnlock()
+	if !ok {
+		message = key
+	}
+
+	if len(args) == 0 {
+		return message
+	}
+	replacements := make([]string, 0, 2*len(args))
+	for name, value := range args {
+		replacements = append(replacements, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(replacements...).Replace(message)
+}
@@ -0,0 +1,188 @@
+// Package jobs stores prompts the server runs on a cron schedule, such as a
+// nightly repository summary. Each run starts a new session, optionally from
+// a session template, and its outcome is published when it finishes.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mix/internal/db"
+	"mix/internal/pubsub"
+)
+
+var ErrNotFound = errors.New("job not found")
+
+// Run statuses, as reported by mix batch
+const (
+	StatusSuccess   = "success"
+	StatusError     = "error"
+	StatusCancelled = "cancelled"
+)
+
+type Job struct {
+	Name string
+	// Schedule is a cron expression in the server's local time
+	Schedule string
+	Prompt   string
+	// Template is the session template runs start from; empty for none
+	Template string
+	// WorkingDirectory of the runs' sessions; empty uses the template's, or
+	// the directory the server started in
+	WorkingDirectory string
+	// LastRun is nil until the job has run once
+	LastRun   *Run
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+// Run is the outcome of running a job once.
+type Run struct {
+	Job        string
+	SessionID  string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     string
+	Error      string
+	// Response and Cost are only set on the run published when it finishes
+	Response string
+	Cost     float64
+}
+
+type Service interface {
+	pubsub.Suscriber[Run]
+	Create(ctx context.Context, job Job) (Job, error)
+	Get(ctx context.Context, name string) (Job, error)
+	List(ctx context.Context) ([]Job, error)
+	Delete(ctx context.Context, name string) error
+	// Finish records run as the job's last run and publishes it
+	Finish(ctx context.Context, run Run) error
+}
+
+type service struct {
+	*pubsub.Broker[Run]
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{
+		Broker: pubsub.NewBroker[Run](),
+		q:      q,
+	}
+}
+
+// Create stores a new job. Names are unique.
+func (s *service) Create(ctx context.Context, job Job) (Job, error) {
+	if err := validate(&job); err != nil {
+		return Job{}, err
+	}
+	if _, err := s.Get(ctx, job.Name); err == nil {
+		return Job{}, fmt.Errorf("job %q already exists", job.Name)
+	} else if !errors.Is(err, ErrNotFound) {
+		return Job{}, err
+	}
+
+	row, err := s.q.CreateJob(ctx, db.CreateJobParams{
+		Name:             job.Name,
+		Schedule:         job.Schedule,
+		Prompt:           job.Prompt,
+		Template:         job.Template,
+		WorkingDirectory: job.WorkingDirectory,
+	})
+	if err != nil {
+		return Job{}, err
+	}
+	return fromRow(row), nil
+}
+
+func (s *service) Get(ctx context.Context, name string) (Job, error) {
+	row, err := s.q.GetJob(ctx, name)
+	if err == sql.ErrNoRows {
+		return Job{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	return fromRow(row), nil
+}
+
+func (s *service) List(ctx context.Context) ([]Job, error) {
+	rows, err := s.q.ListJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, len(rows))
+	for i, row := range rows {
+		jobs[i] = fromRow(row)
+	}
+	return jobs, nil
+}
+
+func (s *service) Delete(ctx context.Context, name string) error {
+	if _, err := s.Get(ctx, name); err != nil {
+		return err
+	}
+	return s.q.DeleteJob(ctx, name)
+}
+
+func (s *service) Finish(ctx context.Context, run Run) error {
+	err := s.q.RecordJobRun(ctx, db.RecordJobRunParams{
+		LastRunAt:     run.StartedAt.Unix(),
+		LastSessionID: run.SessionID,
+		LastStatus:    run.Status,
+		LastError:     run.Error,
+		Name:          run.Job,
+	})
+	s.Publish(ctx, pubsub.CreatedEvent, run)
+	return err
+}
+
+// validate checks job and cleans its fields in place.
+func validate(job *Job) error {
+	job.Name = strings.TrimSpace(job.Name)
+	if job.Name == "" {
+		return errors.New("job name is required")
+	}
+	job.Schedule = strings.TrimSpace(job.Schedule)
+	if _, err := ParseSchedule(job.Schedule); err != nil {
+		return err
+	}
+	if strings.TrimSpace(job.Prompt) == "" {
+		return errors.New("job prompt is required")
+	}
+	if job.WorkingDirectory != "" {
+		absDir, err := filepath.Abs(job.WorkingDirectory)
+		if err != nil {
+			return fmt.Errorf("invalid working directory: %w", err)
+		}
+		job.WorkingDirectory = absDir
+	}
+	return nil
+}
+
+func fromRow(row db.Job) Job {
+	job := Job{
+		Name:             row.Name,
+		Schedule:         row.Schedule,
+		Prompt:           row.Prompt,
+		Template:         row.Template,
+		WorkingDirectory: row.WorkingDirectory,
+		CreatedAt:        row.CreatedAt,
+		UpdatedAt:        row.UpdatedAt,
+	}
+	if row.LastRunAt != 0 {
+		job.LastRun = &Run{
+			Job:       row.Name,
+			SessionID: row.LastSessionID,
+			StartedAt: time.Unix(row.LastRunAt, 0),
+			Status:    row.LastStatus,
+			Error:     row.LastError,
+		}
+	}
+	return job
+}
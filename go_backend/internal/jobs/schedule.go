@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression: minute, hour, day of month, month and
+// day of week. Fields take numbers, names for months and days of the week,
+// "*", ranges, lists and steps, e.g. "30 2 * * mon-fri" or "*/15 9-17 * * *".
+// As in cron, a day matches either day field when both are restricted.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool
+}
+
+// macros are the shorthands cron accepts in place of the five fields
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// 7 is Sunday as well as 0
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// ParseSchedule parses a five-field cron expression or one of @yearly,
+// @monthly, @weekly, @daily and @hourly. Expressions that can never match,
+// such as "0 0 31 2 *", are rejected.
+func ParseSchedule(spec string) (Schedule, error) {
+	expr := strings.ToLower(strings.TrimSpace(spec))
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: want 5 fields (minute hour day-of-month month day-of-week), got %d", spec, len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		bits[i] = b
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	s := Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		anyDom: strings.HasPrefix(parts[2], "*"),
+		anyDow: strings.HasPrefix(parts[4], "*"),
+	}
+	if s.Next(time.Now()).IsZero() {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: never matches", spec)
+	}
+	return s, nil
+}
+
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		span, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepText, f.name)
+			}
+		}
+
+		start, end := f.min, f.max
+		if span != "*" {
+			first, last, isRange := strings.Cut(span, "-")
+			var err error
+			if start, err = parseValue(first, f); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if end, err = parseValue(last, f); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				end = start
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q in %s", span, f.name)
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(text string, f field) (int, error) {
+	for i, name := range f.names {
+		if name != "" && text == name {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q: want %d-%d", f.name, text, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Matches reports whether the schedule fires in the minute of t.
+func (s Schedule) Matches(t time.Time) bool {
+	return s.minute&(1<<t.Minute()) != 0 &&
+		s.hour&(1<<t.Hour()) != 0 &&
+		s.month&(1<<int(t.Month())) != 0 &&
+		s.matchesDay(t)
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.anyDom || s.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first minute after t the schedule fires in, or the zero
+// time if it doesn't fire within five years.
+func (s Schedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		y, m, d := next.Date()
+		switch {
+		case s.month&(1<<int(m)) == 0:
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.matchesDay(next):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, next.Location())
+		case s.hour&(1<<next.Hour()) == 0:
+			next = time.Date(y, m, d, next.Hour()+1, 0, 0, 0, next.Location())
+		case s.minute&(1<<next.Minute()) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
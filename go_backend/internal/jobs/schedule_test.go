@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		errText string
+	}{
+		{name: "every minute", spec: "* * * * *"},
+		{name: "steps, ranges and lists", spec: "*/15 9-17 1,15 * *"},
+		{name: "month and day names", spec: "30 2 * jan-jun mon-fri"},
+		{name: "uppercase names and spaces", spec: "  0 0 * DEC SUN  "},
+		{name: "Sunday as 7", spec: "0 0 * * 7"},
+		{name: "macros", spec: "@daily"},
+		{name: "ranged steps", spec: "0-30/10 * * * *"},
+		{name: "too few fields", spec: "* * * *", errText: "want 5 fields"},
+		{name: "unknown macro", spec: "@fortnightly", errText: "want 5 fields"},
+		{name: "minute out of range", spec: "60 * * * *", errText: "invalid minute"},
+		{name: "day of month zero", spec: "0 0 0 * *", errText: "invalid day of month"},
+		{name: "unknown name", spec: "0 0 * * funday", errText: "invalid day of week"},
+		{name: "backwards range", spec: "0 17-9 * * *", errText: "invalid range"},
+		{name: "zero step", spec: "*/0 * * * *", errText: "invalid step"},
+		{name: "never matches", spec: "0 0 31 2 *", errText: "never matches"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSchedule(tt.spec)
+			if tt.errText != "" {
+				assert.ErrorContains(t, err, tt.errText)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, time.January, 14, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected time.Time
+	}{
+		{name: "next minute", spec: "* * * * *", expected: time.Date(2026, 1, 14, 10, 8, 0, 0, time.UTC)},
+		{name: "next quarter hour", spec: "*/15 * * * *", expected: time.Date(2026, 1, 14, 10, 15, 0, 0, time.UTC)},
+		{name: "later today", spec: "30 14 * * *", expected: time.Date(2026, 1, 14, 14, 30, 0, 0, time.UTC)},
+		{name: "tomorrow", spec: "0 9 * * *", expected: time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)},
+		{name: "next weekday by name", spec: "0 0 * * mon", expected: time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{name: "Sunday as 7", spec: "0 0 * * 7", expected: time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{name: "next month", spec: "0 0 1 * *", expected: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "leap day", spec: "0 0 29 2 *", expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "either restricted day field", spec: "0 0 20 * mon", expected: time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{name: "yearly", spec: "@yearly", expected: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			next := schedule.Next(from)
+			assert.Equal(t, tt.expected, next)
+			assert.True(t, schedule.Matches(next))
+		})
+	}
+}
+
+func TestScheduleMatches(t *testing.T) {
+	// 2026-01-19 is a Monday
+	monday := time.Date(2026, time.January, 19, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		at       time.Time
+		expected bool
+	}{
+		{name: "restricted day of week only", spec: "0 0 * * mon", at: monday, expected: true},
+		{name: "restricted day of month only", spec: "0 0 20 * *", at: monday, expected: false},
+		{name: "both restricted, day of week matches", spec: "0 0 20 * mon", at: monday, expected: true},
+		{name: "both restricted, day of month matches", spec: "0 0 19 * fri", at: monday, expected: true},
+		{name: "both restricted, neither matches", spec: "0 0 20 * fri", at: monday, expected: false},
+		{name: "a starred step needs both day fields", spec: "0 0 */2 * tue", at: monday, expected: false},
+		{name: "seconds are ignored", spec: "0 0 * * *", at: monday.Add(59 * time.Second), expected: true},
+		{name: "wrong minute", spec: "1 0 * * *", at: monday, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule.Matches(tt.at))
+		})
+	}
+}
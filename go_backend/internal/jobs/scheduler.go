@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mix/internal/logging"
+)
+
+// finishTimeout bounds recording a run, which happens after the run's context
+// may have been cancelled by Stop
+const finishTimeout = 10 * time.Second
+
+// RunFunc runs a job once and reports how it went.
+type RunFunc func(ctx context.Context, job Job) Run
+
+// Scheduler runs jobs at the minutes their schedules match. Minutes missed
+// while the server was down are not caught up, and a job whose previous run
+// is still going skips the minute.
+type Scheduler struct {
+	jobs    Service
+	run     RunFunc
+	cancel  context.CancelFunc
+	done    chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// Start runs the jobs stored in jobs with run until Stop is called.
+func Start(jobs Service, run RunFunc) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		jobs:    jobs,
+		run:     run,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		running: make(map[string]bool),
+	}
+	go s.loop(ctx)
+	logging.Info("Job scheduler started")
+	return s
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+	defer logging.RecoverPanic("job-scheduler", nil)
+
+	for {
+		minute := time.Now().Truncate(time.Minute).Add(time.Minute)
+		timer := time.NewTimer(time.Until(minute))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.tick(ctx, minute)
+		}
+	}
+}
+
+// tick starts the jobs due in minute.
+func (s *Scheduler) tick(ctx context.Context, minute time.Time) {
+	jobs, err := s.jobs.List(ctx)
+	if err != nil {
+		logging.Error("Failed to list jobs", "error", err)
+		return
+	}
+	for _, job := range jobs {
+		schedule, err := ParseSchedule(job.Schedule)
+		if err != nil {
+			logging.Warn("Skipping job with invalid schedule", "job", job.Name, "error", err)
+			continue
+		}
+		if !schedule.Matches(minute) {
+			continue
+		}
+
+		s.mu.Lock()
+		busy := s.running[job.Name]
+		s.running[job.Name] = true
+		s.mu.Unlock()
+		if busy {
+			logging.Warn("Skipping job run, the previous run is still going", "job", job.Name)
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.start(ctx, job)
+	}
+}
+
+func (s *Scheduler) start(ctx context.Context, job Job) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.Name)
+		s.mu.Unlock()
+	}()
+	defer logging.RecoverPanic("job."+job.Name, nil)
+
+	logging.Info("Running job", "job", job.Name)
+	run := s.run(ctx, job)
+	logging.Info("Job run finished", "job", job.Name, "session", run.SessionID, "status", run.Status, "error", run.Error)
+
+	finishCtx, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+	if err := s.jobs.Finish(finishCtx, run); err != nil {
+		logging.Error("Failed to record job run", "job", job.Name, "error", err)
+	}
+}
+
+// Stop cancels the runs in progress and waits for them and the scheduler to
+// exit.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		<-s.done
+		s.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
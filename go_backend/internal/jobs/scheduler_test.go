@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeJobs lists fixed jobs and records finished runs
+type fakeJobs struct {
+	Service
+	jobs []Job
+
+	mu       sync.Mutex
+	finished []Run
+}
+
+func (f *fakeJobs) List(context.Context) ([]Job, error) {
+	return f.jobs, nil
+}
+
+func (f *fakeJobs) Finish(_ context.Context, run Run) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.finished = append(f.finished, run)
+	return nil
+}
+
+func TestSchedulerTick(t *testing.T) {
+	minute := time.Date(2026, time.January, 19, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		jobs     []Job
+		busy     []string
+		expected []string
+	}{
+		{
+			name: "due jobs run",
+			jobs: []Job{
+				{Name: "hourly", Schedule: "@hourly"},
+				{Name: "nine", Schedule: "0 9 * * *"},
+				{Name: "ten", Schedule: "0 10 * * *"},
+			},
+			expected: []string{"hourly", "nine"},
+		},
+		{
+			name:     "invalid schedules are skipped",
+			jobs:     []Job{{Name: "broken", Schedule: "not a schedule"}, {Name: "nine", Schedule: "0 9 * * *"}},
+			expected: []string{"nine"},
+		},
+		{
+			name:     "a job still running skips the minute",
+			jobs:     []Job{{Name: "slow", Schedule: "* * * * *"}, {Name: "fast", Schedule: "* * * * *"}},
+			busy:     []string{"slow"},
+			expected: []string{"fast"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeJobs{jobs: tt.jobs}
+			s := &Scheduler{
+				jobs: store,
+				run: func(_ context.Context, job Job) Run {
+					return Run{Job: job.Name, Status: StatusSuccess}
+				},
+				running: make(map[string]bool),
+			}
+			for _, name := range tt.busy {
+				s.running[name] = true
+			}
+
+			s.tick(context.Background(), minute)
+			s.wg.Wait()
+
+			var ran []string
+			for _, run := range store.finished {
+				ran = append(ran, run.Job)
+			}
+			assert.ElementsMatch(t, tt.expected, ran)
+			for _, name := range tt.expected {
+				assert.False(t, s.running[name], "%s is still marked running", name)
+			}
+			for _, name := range tt.busy {
+				assert.True(t, s.running[name], "%s lost its running mark", name)
+			}
+		})
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	s := Start(&fakeJobs{}, func(context.Context, Job) Run { return Run{} })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, s.Stop(ctx))
+}
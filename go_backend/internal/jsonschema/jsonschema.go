@@ -0,0 +1,521 @@
+// Package jsonschema validates JSON documents against a JSON Schema (draft 7).
+// It covers the keywords describing config files and structured responses:
+// types, properties, items, enums, numeric and length bounds, patterns,
+// combinators and local $refs. Annotations such as format are ignored.
+//
+// Errors name the offending value by its path, as in
+// "mcpServers.foo.type must be one of stdio|sse".
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema. Parse a schema with Parse, which checks its
+// patterns and references, before validating with it.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        Types  `json:"type,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Additional        `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+
+	Enum             []any           `json:"enum,omitempty"`
+	Const            json.RawMessage `json:"const,omitempty"`
+	Minimum          *float64        `json:"minimum,omitempty"`
+	Maximum          *float64        `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64        `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64        `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int            `json:"minLength,omitempty"`
+	MaxLength        *int            `json:"maxLength,omitempty"`
+	Pattern          string          `json:"pattern,omitempty"`
+	Format           string          `json:"format,omitempty"`
+
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	Not   *Schema   `json:"not,omitempty"`
+
+	Defs        map[string]*Schema `json:"$defs,omitempty"`
+	Definitions map[string]*Schema `json:"definitions,omitempty"`
+
+	Default  any   `json:"default,omitempty"`
+	Examples []any `json:"examples,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Types lists the JSON types a value may have. It is written as a string
+// when there is one.
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Additional is the additionalProperties keyword: false, for objects that
+// only take their listed properties, or the schema of the other properties.
+type Additional struct {
+	Schema *Schema
+}
+
+func (a Additional) MarshalJSON() ([]byte, error) {
+	if a.Schema == nil {
+		return []byte("false"), nil
+	}
+	return json.Marshal(a.Schema)
+}
+
+func (a *Additional) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "false":
+		a.Schema = nil
+		return nil
+	case "true":
+		a.Schema = &Schema{}
+		return nil
+	}
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// Parse reads a schema, failing when it isn't valid JSON, a pattern doesn't
+// compile or a $ref doesn't point into the schema.
+func Parse(data []byte) (*Schema, error) {
+	var root Schema
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := root.prepare(&root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &root, nil
+}
+
+// prepare compiles the patterns of s and its subschemas and checks their
+// references.
+func (s *Schema) prepare(root *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	if s.Ref != "" {
+		if _, err := root.resolve(s.Ref); err != nil {
+			return err
+		}
+	}
+	for _, sub := range s.subschemas() {
+		if err := sub.prepare(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) subschemas() []*Schema {
+	subs := []*Schema{s.PropertyNames, s.Items, s.Not}
+	if s.AdditionalProperties != nil {
+		subs = append(subs, s.AdditionalProperties.Schema)
+	}
+	for _, group := range []map[string]*Schema{s.Properties, s.Defs, s.Definitions} {
+		for _, name := range slices.Sorted(maps.Keys(group)) {
+			subs = append(subs, group[name])
+		}
+	}
+	return slices.Concat(subs, s.AllOf, s.AnyOf, s.OneOf)
+}
+
+// resolve returns the schema a local reference such as "#/$defs/item" points
+// to.
+func (s *Schema) resolve(ref string) (*Schema, error) {
+	if ref == "#" {
+		return s, nil
+	}
+	for prefix, defs := range map[string]map[string]*Schema{"#/$defs/": s.Defs, "#/definitions/": s.Definitions} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			name = strings.ReplaceAll(strings.ReplaceAll(name, "~1", "/"), "~0", "~")
+			if def := defs[name]; def != nil {
+				return def, nil
+			}
+			return nil, fmt.Errorf("$ref %q: no such definition", ref)
+		}
+	}
+	return nil, fmt.Errorf("$ref %q: only references to #, #/$defs and #/definitions are supported", ref)
+}
+
+// Validate checks the JSON document data against s. The error lists every
+// problem found, one per line.
+func (s *Schema) Validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return errors.New("invalid JSON: unexpected data after the value")
+	}
+	return s.ValidateValue(value)
+}
+
+// ValidateValue checks a value decoded from JSON, with numbers as float64 or
+// json.Number, against s.
+func (s *Schema) ValidateValue(value any) error {
+	v := validator{root: s}
+	v.check(s, value, "")
+	return errors.Join(v.problems...)
+}
+
+type validator struct {
+	root     *Schema
+	problems []error
+}
+
+func (v *validator) report(path, format string, args ...any) {
+	if path == "" {
+		path = "value"
+	}
+	v.problems = append(v.problems, fmt.Errorf("%s "+format, append([]any{path}, args...)...))
+}
+
+// matches reports whether value is valid against s, without reporting why not.
+func (v *validator) matches(s *Schema, value any, path string) bool {
+	sub := validator{root: v.root}
+	sub.check(s, value, path)
+	return len(sub.problems) == 0
+}
+
+func (v *validator) check(s *Schema, value any, path string) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		// Draft 7 ignores the keywords beside a $ref
+		target, err := v.root.resolve(s.Ref)
+		if err != nil {
+			v.report(path, "can't be checked: %v", err)
+			return
+		}
+		v.check(target, value, path)
+		return
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(typ string) bool { return hasType(value, typ) }) {
+		v.report(path, "must be %s, not %s", typeList(s.Type), article(jsonType(value)))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(allowed any) bool { return equal(allowed, value) }) {
+		v.report(path, "must be one of %s", formatEnum(s.Enum))
+	}
+	if len(s.Const) > 0 {
+		var want any
+		decoder := json.NewDecoder(bytes.NewReader(s.Const))
+		decoder.UseNumber()
+		if decoder.Decode(&want) == nil && !equal(want, value) {
+			v.report(path, "must be %s", s.Const)
+		}
+	}
+
+	switch value := value.(type) {
+	case json.Number, float64:
+		n := toFloat(value)
+		if s.Minimum != nil && n < *s.Minimum {
+			v.report(path, "must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			v.report(path, "must be at most %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum {
+			v.report(path, "must be greater than %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && n >= *s.ExclusiveMaximum {
+			v.report(path, "must be less than %v", *s.ExclusiveMaximum)
+		}
+
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil && length < *s.MinLength {
+			v.report(path, "must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			v.report(path, "must be at most %d characters long", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			v.report(path, "must match the pattern %s", s.Pattern)
+		}
+
+	case []any:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			v.report(path, "must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			v.report(path, "must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				v.check(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+
+	case map[string]any:
+		v.checkObject(s, value, path)
+	}
+
+	for _, sub := range s.AllOf {
+		v.check(sub, value, path)
+	}
+	if len(s.AnyOf) > 0 && !slices.ContainsFunc(s.AnyOf, func(sub *Schema) bool { return v.matches(sub, value, path) }) {
+		v.report(path, "must match at least one of the schemas in anyOf")
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, sub := range s.OneOf {
+			if v.matches(sub, value, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.report(path, "must match exactly one of the schemas in oneOf, matches %d", matched)
+		}
+	}
+	if s.Not != nil && v.matches(s.Not, value, path) {
+		v.report(path, "must not match the schema in not")
+	}
+}
+
+func (v *validator) checkObject(s *Schema, value map[string]any, path string) {
+	keyPath := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			v.problems = append(v.problems, fmt.Errorf("%s is required", keyPath(name)))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(value)) {
+		if property, ok := s.Properties[key]; ok {
+			v.check(property, value[key], keyPath(key))
+			continue
+		}
+		if names := s.PropertyNames; names != nil && !v.matches(names, key, "") {
+			if len(names.Enum) > 0 {
+				v.problems = append(v.problems, fmt.Errorf("%s is not allowed: keys must be one of %s%s", keyPath(key), formatEnum(names.Enum), suggest(key, enumNames(names.Enum))))
+			} else {
+				v.problems = append(v.problems, fmt.Errorf("%s is not allowed as a key", keyPath(key)))
+			}
+			continue
+		}
+		if s.AdditionalProperties == nil {
+			continue
+		}
+		if s.AdditionalProperties.Schema == nil {
+			v.problems = append(v.problems, fmt.Errorf("%s is not allowed%s", keyPath(key), suggest(key, slices.Sorted(maps.Keys(s.Properties)))))
+			continue
+		}
+		v.check(s.AdditionalProperties.Schema, value[key], keyPath(key))
+	}
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "integer":
+		switch value.(type) {
+		case json.Number, float64:
+			n := toFloat(value)
+			return n == math.Trunc(n) && !math.IsInf(n, 0)
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case json.Number, float64:
+			return true
+		}
+		return false
+	}
+	return jsonType(value) == typ
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func toFloat(value any) float64 {
+	switch value := value.(type) {
+	case json.Number:
+		n, _ := value.Float64()
+		return n
+	case float64:
+		return value
+	}
+	return math.NaN()
+}
+
+// equal compares JSON values, numbers by value.
+func equal(a, b any) bool {
+	if jsonType(a) == "number" && jsonType(b) == "number" {
+		return toFloat(a) == toFloat(b)
+	}
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func article(typ string) string {
+	switch typ {
+	case "array", "object", "integer":
+		return "an " + typ
+	case "null":
+		return typ
+	}
+	return "a " + typ
+}
+
+func typeList(types Types) string {
+	names := make([]string, len(types))
+	for i, typ := range types {
+		names[i] = article(typ)
+	}
+	return strings.Join(names, " or ")
+}
+
+// formatEnum lists the allowed values. "" is left out, as it only selects a
+// default in the enums that allow it.
+func formatEnum(values []any) string {
+	var names []string
+	for _, name := range enumNames(values) {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+func enumNames(values []any) []string {
+	names := make([]string, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			names[i] = s
+			continue
+		}
+		data, _ := json.Marshal(value)
+		names[i] = string(data)
+	}
+	return names
+}
+
+// suggest returns a "did you mean" hint naming the candidate closest to key,
+// or "" when none is close.
+func suggest(key string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, name := range candidates {
+		if strings.EqualFold(name, key) {
+			return fmt.Sprintf("; did you mean %s?", name)
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDistance && d <= len(name)/2 {
+			best, bestDistance = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("; did you mean %s?", best)
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -2,28 +2,48 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"mix/internal/audit"
+	"mix/internal/chaos"
+	"mix/internal/checkpoint"
 	"mix/internal/config"
 	"mix/internal/llm/models"
 	"mix/internal/llm/prompt"
 	"mix/internal/llm/provider"
+	"mix/internal/llm/reminder"
 	"mix/internal/llm/tools"
 	"mix/internal/logging"
+	"mix/internal/memory"
 	"mix/internal/message"
+	"mix/internal/metrics"
+	"mix/internal/netpolicy"
 	"mix/internal/permission"
+	"mix/internal/pin"
 	"mix/internal/pubsub"
+	"mix/internal/redact"
 	"mix/internal/session"
+	"mix/internal/tracing"
 )
 
 // Common errors
 var (
 	ErrRequestCancelled = errors.New("request cancelled by user")
 	ErrSessionBusy      = errors.New("session is currently processing another request")
+	ErrBudgetExceeded   = errors.New("session cost budget exceeded")
+	ErrToolCallNotFound = errors.New("tool call not running")
+	// ErrQueuedMessageNotFound is returned for a queued message that already
+	// started or was removed
+	ErrQueuedMessageNotFound = errors.New("queued message not found")
+	// ErrIdempotencyKeyQueued is returned for a follow-up message whose
+	// idempotency key a message already queued for the session has
+	ErrIdempotencyKeyQueued = fmt.Errorf("%w: a message with this idempotency key is queued", ErrSessionBusy)
 )
 
 type AgentEventType string
@@ -32,6 +52,10 @@ const (
 	AgentEventTypeError     AgentEventType = "error"
 	AgentEventTypeResponse  AgentEventType = "response"
 	AgentEventTypeSummarize AgentEventType = "summarize"
+	AgentEventTypeUsage     AgentEventType = "usage"
+	AgentEventTypeToolInput AgentEventType = "tool_input"
+	AgentEventTypeWarning   AgentEventType = "warning"
+	AgentEventTypeQueued    AgentEventType = "queued"
 )
 
 type AgentEvent struct {
@@ -43,35 +67,93 @@ type AgentEvent struct {
 	SessionID string
 	Progress  string
 	Done      bool
+
+	// Interim estimate while a generation streams
+	Usage *UsageEstimate
+
+	// Tool call arguments as they stream
+	ToolInput *ToolInputDelta
+
+	// When the turn continued after a recoverable problem
+	Warning *Warning
+
+	// The final response's JSON when the turn has a response format
+	Structured json.RawMessage
+
+	// The follow-up message queued behind the session's running turn
+	Queued *QueuedMessage
 }
 
 type Service interface {
 	pubsub.Suscriber[AgentEvent]
 	Model() models.Model
+	Tools() []tools.BaseTool
 	Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error)
-	RunWithPlanMode(ctx context.Context, sessionID string, content string, planMode bool, attachments ...message.Attachment) (<-chan AgentEvent, error)
+	RunWithState(ctx context.Context, state tools.RequestState, content string, attachments ...message.Attachment) (<-chan AgentEvent, error)
+	// Resume continues the session's last turn from its stored history
+	// without a new user message, e.g. after the server was interrupted
+	Resume(ctx context.Context, sessionID string) (<-chan AgentEvent, error)
 	Cancel(sessionID string)
+	// CancelToolCall cancels one running tool call, whose result tells the
+	// model it was canceled, and lets the other tool calls and the turn go on.
+	// sessionID, when not empty, must be the call's session.
+	CancelToolCall(sessionID, toolCallID string) error
 	IsSessionBusy(sessionID string) bool
 	IsBusy() bool
+	// ListQueued returns the session's queued follow-up messages, next first
+	ListQueued(sessionID string) []QueuedMessage
+	// RemoveQueued removes a queued follow-up message before it runs; its run
+	// ends with ErrRequestCancelled. sessionID, when not empty, must be the
+	// message's session.
+	RemoveQueued(sessionID, id string) error
 	Update(agentName config.AgentName, modelID models.ModelID) (models.Model, error)
 	Summarize(ctx context.Context, sessionID string) error
-	Shutdown()
+	// Estimate predicts the size and cost of the next turn without sending it
+	Estimate(ctx context.Context, sessionID string, content string) (TurnEstimate, error)
+	// Preview returns the system prompt, tools and user message a turn with
+	// state and content would send, without sending it
+	Preview(ctx context.Context, state tools.RequestState, content string) (PromptPreview, error)
+	InvalidateSessionProvider(sessionID string)
+	// ReloadProviders rebuilds every provider from the current configuration,
+	// e.g. after an API key changed
+	ReloadProviders() error
+	// SetMCPTools replaces the agent's MCP tools, e.g. after MCP servers were
+	// added or removed from the configuration
+	SetMCPTools(mcpTools []tools.BaseTool)
+	// SetCommandTools replaces the agent's command tools after they changed
+	// in the configuration
+	SetCommandTools(commandTools []tools.BaseTool)
+	Shutdown(ctx context.Context) error
 }
 
 type agent struct {
 	*pubsub.Broker[AgentEvent]
 	sessions session.Service
 	messages message.Service
+	audits   audit.Service
+	// checkpoints is nil for agents whose tools can't write files
+	checkpoints checkpoint.Service
+	// pins is nil for sub-agents, whose sessions can't pin context
+	pins pin.Service
+	// memories is nil for sub-agents, whose prompts carry no memories
+	memories memory.Service
 
 	agentName config.AgentName
+	toolsMu   sync.RWMutex
 	tools     []tools.BaseTool
 	provider  provider.Provider
 
 	titleProvider     provider.Provider
 	summarizeProvider provider.Provider
 
-	sessionProviders sync.Map // Maps session ID to provider.Provider
+	sessionProviders sync.Map // Maps session ID to sessionProviderEntry
+	compactedTools   sync.Map // Maps session ID to the names of the tools it sends abbreviated
+	providers        *providerPool
 	activeRequests   sync.Map
+	followUps        *followUps
+	activeToolCalls  sync.Map       // Maps tool call ID to its activeToolCall
+	turns            sync.Map       // Maps session ID to the *checkpoint.Turn running
+	running          sync.WaitGroup // Generations and summaries in flight
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -81,6 +163,10 @@ func NewAgent(
 	agentName config.AgentName,
 	sessions session.Service,
 	messages message.Service,
+	audits audit.Service,
+	checkpoints checkpoint.Service,
+	pins pin.Service,
+	memories memory.Service,
 	agentTools []tools.BaseTool,
 ) (Service, error) {
 	agentProvider, err := createAgentProvider(agentName)
@@ -103,6 +189,10 @@ func NewAgent(
 		}
 	}
 
+	if config.Get().CompactTools.Enabled {
+		agentTools = append(agentTools, tools.NewToolSchemaTool(agentTools))
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	agent := &agent{
@@ -111,17 +201,30 @@ func NewAgent(
 		provider:          agentProvider,
 		messages:          messages,
 		sessions:          sessions,
+		audits:            audits,
+		checkpoints:       checkpoints,
+		pins:              pins,
+		memories:          memories,
 		tools:             agentTools,
 		titleProvider:     titleProvider,
 		summarizeProvider: summarizeProvider,
 		sessionProviders:  sync.Map{},
+		providers:         newProviderPool(),
 		activeRequests:    sync.Map{},
+		followUps:         newFollowUps(),
 		ctx:               ctx,
 		cancel:            cancel,
 	}
 
 	// Start session deletion cleanup goroutine
 	go agent.handleSessionEvents()
+	go agent.providers.evictIdleLoop(ctx)
+
+	if agentName == config.AgentMain {
+		if err := prompt.Watch(ctx, agent.invalidateSessionProviders); err != nil {
+			logging.Warn("Failed to watch prompts directory", "error", err)
+		}
+	}
 
 	return agent, nil
 }
@@ -130,6 +233,40 @@ func (a *agent) Model() models.Model {
 	return a.provider.Model()
 }
 
+// Tools returns every tool the agent can use, before per-session filtering.
+func (a *agent) Tools() []tools.BaseTool {
+	a.toolsMu.RLock()
+	defer a.toolsMu.RUnlock()
+	return a.tools
+}
+
+func (a *agent) SetMCPTools(mcpTools []tools.BaseTool) {
+	a.replaceTools(func(tool tools.BaseTool) bool {
+		_, isMCP := tool.(*mcpTool)
+		return isMCP
+	}, mcpTools)
+}
+
+func (a *agent) SetCommandTools(commandTools []tools.BaseTool) {
+	a.replaceTools(tools.IsCommandTool, commandTools)
+}
+
+// replaceTools replaces the agent's tools that replaced reports true for with
+// added, and rebuilds tool_schema's list of tools.
+func (a *agent) replaceTools(replaced func(tools.BaseTool) bool, added []tools.BaseTool) {
+	a.toolsMu.Lock()
+	defer a.toolsMu.Unlock()
+
+	agentTools := slices.DeleteFunc(slices.Clone(a.tools), func(tool tools.BaseTool) bool {
+		return replaced(tool) || tool.Info().Name == tools.ToolSchemaToolName
+	})
+	agentTools = addTools(agentTools, added)
+	if config.Get().CompactTools.Enabled {
+		agentTools = append(agentTools, tools.NewToolSchemaTool(agentTools))
+	}
+	a.tools = agentTools
+}
+
 func (a *agent) Cancel(sessionID string) {
 	// Cancel regular requests
 	if cancelFunc, exists := a.activeRequests.LoadAndDelete(sessionID); exists {
@@ -148,6 +285,61 @@ func (a *agent) Cancel(sessionID string) {
 	}
 }
 
+// activeToolCall is a running tool call and the function canceling it.
+type activeToolCall struct {
+	sessionID string
+	cancel    context.CancelFunc
+}
+
+func (a *agent) CancelToolCall(sessionID, toolCallID string) error {
+	value, ok := a.activeToolCalls.Load(toolCallID)
+	if !ok || (sessionID != "" && value.(activeToolCall).sessionID != sessionID) {
+		return fmt.Errorf("%w: %s", ErrToolCallNotFound, toolCallID)
+	}
+	logging.Info("Tool call cancellation initiated", "sessionID", value.(activeToolCall).sessionID, "toolCallID", toolCallID)
+	value.(activeToolCall).cancel()
+	return nil
+}
+
+// runTool runs the tool call under its own context, which CancelToolCall
+// cancels. A canceled call returns at once with a canceled result, even if
+// the tool is still running.
+func (a *agent) runTool(ctx context.Context, tool tools.BaseTool, call tools.ToolCall) (tools.ToolResponse, error) {
+	toolCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	a.activeToolCalls.Store(call.ID, activeToolCall{sessionID: call.State.SessionID, cancel: cancel})
+	defer a.activeToolCalls.Delete(call.ID)
+
+	type runResult struct {
+		response tools.ToolResponse
+		err      error
+	}
+	done := make(chan runResult, 1)
+	go func() {
+		response, err := tool.Run(toolCtx, call)
+		done <- runResult{response, err}
+	}()
+
+	select {
+	case result := <-done:
+		if ctx.Err() == nil && toolCtx.Err() != nil {
+			// The tool noticed the cancellation before it returned
+			return tools.NewTextErrorResponse(toolCallCanceled), nil
+		}
+		return result.response, result.err
+	case <-toolCtx.Done():
+		if ctx.Err() != nil {
+			// The turn was canceled; wait for the tool as before
+			result := <-done
+			return result.response, result.err
+		}
+		return tools.NewTextErrorResponse(toolCallCanceled), nil
+	}
+}
+
+// toolCallCanceled is the result of a tool call canceled with CancelToolCall
+const toolCallCanceled = "The user canceled this tool call. The other tool calls and the turn continue; do not retry it unless the user asks."
+
 func (a *agent) IsBusy() bool {
 	busy := false
 	a.activeRequests.Range(func(key, value interface{}) bool {
@@ -178,14 +370,15 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 	if err != nil {
 		return err
 	}
-	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
-
-	// Add session working directory to context
-	ctx = context.WithValue(ctx, tools.WorkingDirectoryContextKey, session.WorkingDirectory)
+	state := tools.RequestState{
+		SessionID:        sessionID,
+		WorkingDirectory: session.WorkingDirectory,
+	}
 
 	parts := []message.ContentPart{message.TextContent{Text: content}}
 	response, err := a.titleProvider.SendMessages(
 		ctx,
+		state,
 		[]message.Message{
 			{
 				Role:  message.User,
@@ -216,35 +409,153 @@ func (a *agent) err(err error) AgentEvent {
 }
 
 func (a *agent) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
-	return a.RunWithPlanMode(ctx, sessionID, content, false, attachments...)
+	return a.RunWithState(ctx, tools.RequestState{SessionID: sessionID}, content, attachments...)
+}
+
+// WaitForResult reads events until the final response or an error, skipping
+// intermediate tool and usage events.
+func WaitForResult(events <-chan AgentEvent) AgentEvent {
+	var last AgentEvent
+	for event := range events {
+		last = event
+		if event.Type == AgentEventTypeError || (event.Type == AgentEventTypeResponse && event.Done) {
+			return event
+		}
+	}
+	return last
 }
 
-func (a *agent) RunWithPlanMode(ctx context.Context, sessionID string, content string, planMode bool, attachments ...message.Attachment) (<-chan AgentEvent, error) {
+// RunWithState starts a generation for state.SessionID. The state is threaded
+// explicitly to tools and providers; ctx only carries cancellation.
+func (a *agent) RunWithState(ctx context.Context, state tools.RequestState, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
 	if !a.provider.Model().SupportsAttachments && attachments != nil {
-		attachments = nil
+		// Documents reach the model as their text, images not at all
+		attachments = slices.DeleteFunc(slices.Clone(attachments), func(attachment message.Attachment) bool {
+			return !message.IsDocumentType(attachment.MimeType)
+		})
 	}
+	var attachmentParts []message.ContentPart
+	for _, attachment := range attachments {
+		attachmentParts = append(attachmentParts, message.BinaryContent{Path: attachment.FilePath, MIMEType: attachment.MimeType, Data: attachment.Content})
+	}
+	var queue *QueuedMessage
+	if state.Queue {
+		queue = &QueuedMessage{Content: content, IdempotencyKey: state.IdempotencyKey}
+	}
+	return a.start(ctx, state.SessionID, state.PlanMode, queue, state.AcquireRun, func(genCtx context.Context) AgentEvent {
+		return a.processGeneration(genCtx, state, content, attachmentParts)
+	})
+}
+
+// Resume regenerates the response to the session's history as stored. A
+// trailing assistant message without tool calls, such as an interrupted
+// partial response, is left out so the model answers afresh.
+func (a *agent) Resume(ctx context.Context, sessionID string) (<-chan AgentEvent, error) {
+	return a.start(ctx, sessionID, false, nil, nil, func(genCtx context.Context) AgentEvent {
+		msgs, session, err := a.history(genCtx, sessionID)
+		if err != nil {
+			return a.err(err)
+		}
+		if len(msgs) == 0 {
+			return a.err(fmt.Errorf("session %s has no messages to resume", sessionID))
+		}
+		if last := msgs[len(msgs)-1]; last.Role == message.Assistant && len(last.ToolCalls()) == 0 {
+			msgs = msgs[:len(msgs)-1]
+		}
+		state := tools.RequestState{SessionID: sessionID, WorkingDirectory: session.WorkingDirectory}
+		return a.generate(genCtx, state, msgs)
+	})
+}
+
+// start runs generate as the session's active request and returns its events.
+// While another request for the session is running it returns ErrSessionBusy,
+// unless given the queue message's content and idempotency key, which then
+// waits in the session's queue and runs once the requests before it ended,
+// starting its events with a queued event. A message whose idempotency key is
+// already queued returns ErrIdempotencyKeyQueued. acquire, when not nil, takes a run queue slot once the request may
+// start: a failure is returned for a request starting right away, and ends
+// a queued one's events with it.
+func (a *agent) start(ctx context.Context, sessionID string, planMode bool, queue *QueuedMessage, acquire func(context.Context) (func(), error), generate func(ctx context.Context) AgentEvent) (<-chan AgentEvent, error) {
 	events := make(chan AgentEvent, 10) // Buffered channel for better streaming
 
 	genCtx, cancel := context.WithCancel(ctx)
-	if _, loaded := a.activeRequests.LoadOrStore(sessionID, cancel); loaded {
+	if _, loaded := a.activeRequests.LoadOrStore(sessionID, cancel); !loaded {
+		release, err := a.acquireRun(genCtx, sessionID, acquire)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		a.run(genCtx, cancel, sessionID, planMode, events, release, generate)
+		return events, nil
+	}
+	if queue == nil {
 		cancel() // Clean up unused cancel function
 		return nil, ErrSessionBusy
 	}
 
-	// Add plan mode to context
-	if planMode {
-		genCtx = context.WithValue(genCtx, "plan_mode", true)
+	turn := a.followUps.enqueue(sessionID, queue.Content, queue.IdempotencyKey, cancel)
+	if turn == nil {
+		cancel()
+		return nil, ErrIdempotencyKeyQueued
 	}
+	logging.Info("Queued follow-up message", "sessionID", sessionID, "queuedID", turn.message.ID, "position", turn.message.Position)
+	queued := turn.message
+	events <- AgentEvent{Type: AgentEventTypeQueued, SessionID: sessionID, Queued: &queued}
 
+	a.running.Add(1)
+	go func() {
+		defer a.running.Done()
+		if err := a.waitTurn(genCtx, turn); err != nil {
+			logging.Info("Queued follow-up message removed", "sessionID", sessionID, "queuedID", turn.message.ID)
+			cancel()
+			events <- a.err(ErrRequestCancelled)
+			close(events)
+			return
+		}
+		release, err := a.acquireRun(genCtx, sessionID, acquire)
+		if err != nil {
+			cancel()
+			events <- a.err(err)
+			close(events)
+			return
+		}
+		a.run(genCtx, cancel, sessionID, planMode, events, release, generate)
+	}()
+	return events, nil
+}
+
+// acquireRun takes a run queue slot with acquire for the session's active
+// request, returning the function releasing it. If that fails, the session
+// stops being active and the turns queued for it may start.
+func (a *agent) acquireRun(ctx context.Context, sessionID string, acquire func(context.Context) (func(), error)) (func(), error) {
+	if acquire == nil {
+		return func() {}, nil
+	}
+	release, err := acquire(ctx)
+	if err != nil {
+		a.activeRequests.Delete(sessionID)
+		a.followUps.wake(sessionID)
+		return nil, err
+	}
+	return release, nil
+}
+
+// run runs generate as the session's active request, which the caller made it,
+// sending its events to events. release is called when the request ends.
+func (a *agent) run(genCtx context.Context, cancel context.CancelFunc, sessionID string, planMode bool, events chan AgentEvent, release func(), generate func(ctx context.Context) AgentEvent) {
 	// Subscribe to agent events for real-time streaming
 	subscription := a.Subscribe(genCtx)
 
+	a.running.Add(1)
 	go func() {
 		defer func() {
 			logging.Debug("Request completed", "sessionID", sessionID)
+			release()
 			a.activeRequests.Delete(sessionID)
+			a.followUps.wake(sessionID)
 			cancel()
 			close(events)
+			a.running.Done()
 		}()
 
 		logging.Debug("Request started", "sessionID", sessionID, "planMode", planMode)
@@ -252,14 +563,30 @@ func (a *agent) RunWithPlanMode(ctx context.Context, sessionID string, content s
 			events <- a.err(fmt.Errorf("panic while running the agent"))
 		})
 
-		var attachmentParts []message.ContentPart
-		for _, attachment := range attachments {
-			attachmentParts = append(attachmentParts, message.BinaryContent{Path: attachment.FilePath, MIMEType: attachment.MimeType, Data: attachment.Content})
-		}
+		runCtx, span := a.startRunSpan(genCtx, sessionID, planMode)
+		defer span.End()
 
-		result := a.processGeneration(genCtx, sessionID, content, attachmentParts)
-		if result.Error != nil && !errors.Is(result.Error, ErrRequestCancelled) && !errors.Is(result.Error, context.Canceled) {
+		started := time.Now()
+		result := generate(runCtx)
+		outcome := "ok"
+		if errors.Is(result.Error, ErrRequestCancelled) || errors.Is(result.Error, context.Canceled) {
+			outcome = "cancelled"
+		} else if result.Error != nil {
+			outcome = "error"
 			logging.Error(result.Error.Error())
+			tracing.Fail(span, result.Error)
+		}
+		span.SetAttributes(attrOutcome.String(outcome))
+		metrics.AgentRunDuration.Observe(time.Since(started).Seconds(), string(a.agentName), outcome)
+		if result.Type == AgentEventTypeError {
+			// Errors are only returned to the caller, so publish them for
+			// subscribers waiting on the end of the run
+			failed := result
+			failed.SessionID = sessionID
+			failed.Done = true
+			if err := a.Publish(context.Background(), pubsub.CreatedEvent, failed); err != nil {
+				logging.Warn("Failed to publish agent error", "sessionID", sessionID, "error", err)
+			}
 		}
 		// Always send the final result directly to ensure CLI mode receives it
 		events <- result
@@ -270,7 +597,7 @@ func (a *agent) RunWithPlanMode(ctx context.Context, sessionID string, content s
 		defer logging.RecoverPanic("agent.Run-subscription", nil)
 		for {
 			select {
-			case <-ctx.Done():
+			case <-genCtx.Done():
 				return
 			case event, ok := <-subscription:
 				if !ok {
@@ -280,7 +607,7 @@ func (a *agent) RunWithPlanMode(ctx context.Context, sessionID string, content s
 				if (event.Payload.SessionID == sessionID || event.Payload.Message.SessionID == sessionID) && !event.Payload.Done {
 					select {
 					case events <- event.Payload:
-					case <-ctx.Done():
+					case <-genCtx.Done():
 						return
 					}
 				}
@@ -288,16 +615,16 @@ func (a *agent) RunWithPlanMode(ctx context.Context, sessionID string, content s
 		}
 	}()
 
-	return events, nil
 }
 
-func (a *agent) processGeneration(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) AgentEvent {
+func (a *agent) processGeneration(ctx context.Context, state tools.RequestState, content string, attachmentParts []message.ContentPart) AgentEvent {
+	sessionID := state.SessionID
 	logging.Info("[Agent] Starting message processing for session", "sessionID", sessionID, "contentPreview", fmt.Sprintf("%.100s...", content))
 	_ = config.Get()
 	// List existing messages; if none, start title generation asynchronously.
-	msgs, err := a.messages.List(ctx, sessionID)
+	msgs, session, err := a.history(ctx, sessionID)
 	if err != nil {
-		return a.err(fmt.Errorf("failed to list messages: %w", err))
+		return a.err(err)
 	}
 	if len(msgs) == 0 {
 		go func() {
@@ -310,31 +637,69 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			}
 		}()
 	}
-	session, err := a.sessions.Get(ctx, sessionID)
+
+	state.WorkingDirectory = session.WorkingDirectory
+
+	userMsg, err := a.createUserMessage(ctx, state, session, content, attachmentParts)
 	if err != nil {
-		return a.err(fmt.Errorf("failed to get session: %w", err))
-	}
-	if session.SummaryMessageID != "" {
-		summaryMsgInex := -1
-		for i, msg := range msgs {
-			if msg.ID == session.SummaryMessageID {
-				summaryMsgInex = i
-				break
-			}
+		return a.err(fmt.Errorf("failed to create user message: %w", err))
+	}
+	if a.checkpoints != nil {
+		turn := a.checkpoints.Begin(sessionID, userMsg.ID, session.WorkingDirectory)
+		a.turns.Store(sessionID, turn)
+		defer func() {
+			a.turns.Delete(sessionID)
+			turn.Finish(context.Background())
+		}()
+	}
+	// Append the new user message to the conversation history.
+	return a.generate(ctx, state, append(msgs, userMsg))
+}
+
+// history returns the messages sent to the model: the session's messages from
+// its last summary on, the summary standing in as a user message.
+func (a *agent) history(ctx context.Context, sessionID string) ([]message.Message, session.Session, error) {
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, session.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	msgs, err := a.messagesSinceSummary(ctx, sess)
+	if err != nil {
+		return nil, session.Session{}, err
+	}
+	if sess.SummaryMessageID != "" && len(msgs) > 0 && msgs[0].ID == sess.SummaryMessageID {
+		msgs[0].Role = message.User
+	}
+	return msgs, sess, nil
+}
+
+// messagesSinceSummary loads the session's messages from its last summary on,
+// so long sessions don't load the history the summary replaces. Without a
+// summary, or when it was deleted, it loads them all.
+func (a *agent) messagesSinceSummary(ctx context.Context, sess session.Session) ([]message.Message, error) {
+	if sess.SummaryMessageID != "" {
+		msgs, err := a.messages.ListFrom(ctx, sess.ID, sess.SummaryMessageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
 		}
-		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
+		if len(msgs) > 0 {
+			return msgs, nil
 		}
 	}
-
-	userMsg, err := a.createUserMessage(ctx, sessionID, content, attachmentParts)
+	msgs, err := a.messages.List(ctx, sess.ID)
 	if err != nil {
-		return a.err(fmt.Errorf("failed to create user message: %w", err))
+		return nil, fmt.Errorf("failed to list messages: %w", err)
 	}
-	// Append the new user message to the conversation history.
-	msgHistory := append(msgs, userMsg)
+	return msgs, nil
+}
 
+// generate streams responses to msgHistory, running the requested tools and
+// sending their results back, until the model finishes its turn.
+func (a *agent) generate(ctx context.Context, state tools.RequestState, msgHistory []message.Message) AgentEvent {
+	sessionID := state.SessionID
+	state.DryRun = state.DryRun || config.Get().DryRun
+	trimmed := false
+	formatRetried := false
 	for {
 		// Check for cancellation before each iteration
 		select {
@@ -343,7 +708,9 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		default:
 			// Continue processing
 		}
-		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
+		stepCtx, span := startStepSpan(ctx, sessionID)
+		agentMessage, toolResults, err := a.streamAndHandleEvents(stepCtx, state, msgHistory)
+		endStepSpan(span, agentMessage, err)
 		if err != nil {
 			logging.Info("[Agent] Stream processing failed for session", "sessionID", sessionID, "error", err)
 			if errors.Is(err, context.Canceled) {
@@ -351,6 +718,12 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 				a.messages.Update(context.Background(), agentMessage)
 				return a.err(ErrRequestCancelled)
 			}
+			if !trimmed && provider.IsContextTooLong(err) {
+				if retryHistory, ok := a.trimForRetry(ctx, sessionID, msgHistory, agentMessage); ok {
+					msgHistory, trimmed = retryHistory, true
+					continue
+				}
+			}
 			return a.err(fmt.Errorf("failed to process events: %w", err))
 		}
 
@@ -365,13 +738,36 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			msgHistory = append(msgHistory, agentMessage, *toolResults)
 			continue
 		}
+		var structured json.RawMessage
+		if state.ResponseFormat != nil {
+			structured, err = state.ResponseFormat.Check(agentMessage.Content().Text)
+			if err != nil && !formatRetried {
+				// Ask once for a response that matches the format
+				logging.Info("[Agent] Response does not match the response format, retrying", "sessionID", sessionID, "error", err)
+				correction, createErr := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+					Role:  message.User,
+					Parts: []message.ContentPart{message.TextContent{Text: strings.TrimSpace(reminder.Inject("", []reminder.Reminder{{Text: err.Error() + "\n\nSend the corrected final response."}}))}},
+				})
+				if createErr != nil {
+					return a.err(fmt.Errorf("failed to create response format correction: %w", createErr))
+				}
+				msgHistory = append(msgHistory, agentMessage, correction)
+				formatRetried = true
+				continue
+			}
+			if err != nil {
+				return a.err(err)
+			}
+		}
+
 		// Publish final completion event
 
 		finalEvent := AgentEvent{
-			Type:      AgentEventTypeResponse,
-			Message:   agentMessage,
-			SessionID: sessionID,
-			Done:      true,
+			Type:       AgentEventTypeResponse,
+			Message:    agentMessage,
+			SessionID:  sessionID,
+			Done:       true,
+			Structured: structured,
 		}
 		err = a.Publish(ctx, pubsub.CreatedEvent, finalEvent)
 		if err != nil {
@@ -381,67 +777,232 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 }
 
-func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
-	// Check if plan mode is active and append system-reminder
-	messageContent := content
-	if ctx.Value("plan_mode") != nil {
-		planModeContent, err := prompt.LoadPrompt("plan_mode")
-		if err != nil {
-			return message.Message{}, fmt.Errorf("failed to load plan mode prompt: %w", err)
+// trimForRetry prepares retrying a request the provider rejected as too long:
+// the failed assistant message is removed and the oldest messages are left
+// out, which a warning event reports. It returns false when there is nothing
+// to leave out.
+func (a *agent) trimForRetry(ctx context.Context, sessionID string, msgHistory []message.Message, failed message.Message) ([]message.Message, bool) {
+	session, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		logging.Error("[Agent] Failed to load session for trimming", "sessionID", sessionID, "error", err)
+		return nil, false
+	}
+	keepSummary := session.SummaryMessageID != "" && len(msgHistory) > 0 && msgHistory[0].ID == session.SummaryMessageID
+	retryHistory, dropped := trimHistory(msgHistory, keepSummary)
+	if dropped == 0 {
+		return nil, false
+	}
+
+	if failed.ID != "" && len(failed.ToolCalls()) == 0 && failed.Content().Text == "" {
+		if err := a.messages.Delete(ctx, failed.ID); err != nil {
+			logging.Warn("[Agent] Failed to delete rejected assistant message", "sessionID", sessionID, "messageID", failed.ID, "error", err)
 		}
-		messageContent = content + "\n\n<system-reminder>\n" + planModeContent + "\n</system-reminder>"
 	}
 
-	parts := []message.ContentPart{message.TextContent{Text: messageContent}}
+	logging.Warn("[Agent] Context too long, retrying without the oldest messages", "sessionID", sessionID, "dropped", dropped, "kept", len(retryHistory))
+	if err := a.Publish(ctx, pubsub.CreatedEvent, AgentEvent{
+		Type:      AgentEventTypeWarning,
+		SessionID: sessionID,
+		Warning: &Warning{
+			Code:            WarningContextTrimmed,
+			Message:         fmt.Sprintf("The conversation exceeded the model's context window, so the %d oldest messages were left out of this request. Summarize the session to keep their content.", dropped),
+			DroppedMessages: dropped,
+		},
+	}); err != nil {
+		logging.Debug("Failed to publish context trimmed warning", "sessionID", sessionID, "error", err)
+	}
+	return retryHistory, true
+}
+
+func (a *agent) createUserMessage(ctx context.Context, state tools.RequestState, sess session.Session, content string, attachmentParts []message.ContentPart) (message.Message, error) {
+	reminders, err := a.reminders(ctx, state, sess)
+	if err != nil {
+		return message.Message{}, err
+	}
+
+	parts := []message.ContentPart{message.TextContent{Text: reminder.Inject(content, reminders)}}
 	parts = append(parts, attachmentParts...)
-	return a.messages.Create(ctx, sessionID, message.CreateMessageParams{
-		Role:  message.User,
-		Parts: parts,
+	return a.messages.Create(ctx, state.SessionID, message.CreateMessageParams{
+		Role:           message.User,
+		Parts:          parts,
+		IdempotencyKey: state.IdempotencyKey,
+	})
+}
+
+// reminders composes the reminders appended to the turn's user message.
+func (a *agent) reminders(ctx context.Context, state tools.RequestState, sess session.Session) ([]reminder.Reminder, error) {
+	attached, err := a.sessions.Reminders(ctx, sess.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session reminders: %w", err)
+	}
+	model, err := a.sessionModel(ctx, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	return reminder.Compose(reminder.Turn{
+		WorkingDirectory: sess.WorkingDirectory,
+		PlanMode:         state.PlanMode,
+		Session:          attached,
+		Turn:             state.Reminders,
+		// The last request's prompt plus its response
+		ContextTokens:  sess.PromptTokens + sess.CompletionTokens,
+		ContextWindow:  model.ContextWindow,
+		ResponseFormat: state.ResponseFormat,
 	})
 }
 
+// sessionModel returns the model the session's turns use.
+func (a *agent) sessionModel(ctx context.Context, sessionID string) (models.Model, error) {
+	settings, err := a.sessions.AgentSettings(ctx, sessionID)
+	if err != nil {
+		return models.Model{}, fmt.Errorf("failed to load session agent settings: %w", err)
+	}
+	if settings.Model != "" {
+		if model, ok := models.Lookup(models.ModelID(settings.Model)); ok {
+			return model, nil
+		}
+	}
+	return a.Model(), nil
+}
+
+// withPinnedContext returns msgHistory with the session's pinned files and
+// messages put ahead of the first message's content. The stored messages are
+// left as they are.
+func (a *agent) withPinnedContext(ctx context.Context, sess session.Session, msgHistory []message.Message) ([]message.Message, error) {
+	if len(msgHistory) == 0 {
+		return msgHistory, nil
+	}
+	contents, err := a.pinnedContext(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	block := pin.Block(contents)
+	if block == "" {
+		return msgHistory, nil
+	}
+
+	pinned := message.Message{Role: message.User, SessionID: sess.ID, Parts: []message.ContentPart{message.TextContent{Text: block}}}
+	if msgHistory[0].Role != message.User {
+		return append([]message.Message{pinned}, msgHistory...), nil
+	}
+	// Merged into the first user message, as some providers reject two user
+	// messages in a row
+	first := msgHistory[0]
+	first.Parts = append(pinned.Parts, first.Parts...)
+	return append([]message.Message{first}, msgHistory[1:]...), nil
+}
+
+// pinnedContext reads the session's pinned files and messages as they are now.
+func (a *agent) pinnedContext(ctx context.Context, sess session.Session) ([]pin.Content, error) {
+	if a.pins == nil {
+		return nil, nil
+	}
+	contents, err := a.pins.Contents(ctx, sess.ID, sess.WorkingDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pinned context: %w", err)
+	}
+	return contents, nil
+}
+
+// memoryPrompt returns the memories section of the session's system prompt,
+// read when its provider is created, so memories written during a session
+// apply from the next one.
+func (a *agent) memoryPrompt(ctx context.Context, sess session.Session) (string, error) {
+	if a.memories == nil {
+		return "", nil
+	}
+	text, err := a.memories.Prompt(ctx, sess.WorkingDirectory)
+	if err != nil {
+		return "", fmt.Errorf("failed to load memories: %w", err)
+	}
+	return text, nil
+}
+
 type toolExecResult struct {
 	index            int
 	result           message.ToolResult
 	permissionDenied bool
 }
 
-func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msgHistory []message.Message) (message.Message, *message.Message, error) {
-	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+func (a *agent) streamAndHandleEvents(ctx context.Context, state tools.RequestState, msgHistory []message.Message) (message.Message, *message.Message, error) {
+	sessionID := state.SessionID
 
-	// Get session and add working directory to context
 	session, err := a.sessions.Get(ctx, sessionID)
 	if err != nil {
 		return message.Message{}, nil, fmt.Errorf("failed to load session %s: %w", sessionID, err)
 	}
-	// Add session working directory to context
-	ctx = context.WithValue(ctx, tools.WorkingDirectoryContextKey, session.WorkingDirectory)
+	state.WorkingDirectory = session.WorkingDirectory
+	state.Env, err = a.sessions.Env(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, nil, fmt.Errorf("failed to load session environment: %w", err)
+	}
+	state.SandboxProfile, err = a.sessions.SandboxProfile(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, nil, fmt.Errorf("failed to load session sandbox profile: %w", err)
+	}
 
-	// Get cached session-specific provider
-	sessionProvider, err := a.getOrCreateSessionProvider(ctx, sessionID, &session)
+	maxCost := config.Get().MaxSessionCost
+	if maxCost > 0 && session.Cost >= maxCost {
+		return message.Message{}, nil, ErrBudgetExceeded
+	}
+
+	// Get cached session-specific provider. Prompt loading still reads the
+	// working directory from context, so it goes through the compatibility shim.
+	sessionProvider, err := a.getOrCreateSessionProvider(tools.WithRequestState(ctx, state), sessionID, &session)
 	if err != nil {
 		return message.Message{}, nil, fmt.Errorf("failed to get session provider: %w", err)
 	}
 
-	// Filter tools based on plan mode
-	availableTools := a.tools
-	if ctx.Value("plan_mode") != nil {
-		availableTools = filterToolsForPlanMode(a.tools)
+	disabledTools, err := a.sessions.DisabledTools(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, nil, fmt.Errorf("failed to load disabled tools for session %s: %w", sessionID, err)
+	}
+	disabled := make(map[string]bool, len(disabledTools))
+	for _, name := range disabledTools {
+		disabled[name] = true
 	}
 
-	eventChan := sessionProvider.StreamResponse(ctx, msgHistory, availableTools)
+	// Filter tools by the session's disabled list and plan mode
+	availableTools := filterDisabledTools(a.Tools(), disabled)
+	if state.PlanMode {
+		availableTools = filterToolsForPlanMode(availableTools)
+	}
+	availableTools = a.compactSessionTools(sessionID, availableTools, msgHistory)
+
+	msgHistory, pruned := applyHistoryStrategy(config.Get().Agents[a.agentName].History, session, sessionProvider.Model(), msgHistory)
+	if pruned != nil {
+		logging.Debug("[Agent] History shortened for request", "sessionID", sessionID, "strategy", pruned.Strategy, "droppedMessages", len(pruned.DroppedMessageIDs), "prunedToolResults", len(pruned.PrunedToolCallIDs))
+	}
 
+	msgHistory, err = a.withPinnedContext(ctx, session, msgHistory)
+	if err != nil {
+		return message.Message{}, nil, err
+	}
+
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	streamCtx, chatSpan := startChatSpan(streamCtx, sessionProvider.Model())
+	defer chatSpan.End()
+	eventChan := sessionProvider.StreamResponse(streamCtx, state, msgHistory, availableTools)
+	ticker := newCostTicker(sessionProvider.Model(), session.Cost, maxCost, promptTokens(a.sessionSystemTokens(sessionID), msgHistory, availableTools))
+	toolInputs := newToolInputStream()
+
+	// What the history strategy left out is recorded on the response
+	assistantParts := []message.ContentPart{}
+	if pruned != nil {
+		assistantParts = append(assistantParts, *pruned)
+	}
 	assistantMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
 		Role:  message.Assistant,
-		Parts: []message.ContentPart{},
+		Parts: assistantParts,
 		Model: sessionProvider.Model().ID,
 	})
 	if err != nil {
 		return assistantMsg, nil, fmt.Errorf("failed to create assistant message: %w", err)
 	}
 
-	// Add the session and message ID into the context if needed by tools.
-	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
+	// Tools attribute their work to the assistant message that called them
+	state.MessageID = assistantMsg.ID
 
 	// Track reasoning start time and ensure cleanup
 	reasoningStartTime := time.Now()
@@ -455,16 +1016,51 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 
 	// Process each event in the stream.
 	for event := range eventChan {
-		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event); processErr != nil {
-			a.finishMessage(ctx, &assistantMsg, message.FinishReasonCanceled)
+		if event.Type == provider.EventComplete {
+			recordChatResponse(chatSpan, sessionProvider.Model(), event.Response)
+		}
+		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, toolInputs, event); processErr != nil {
+			tracing.Fail(chatSpan, processErr)
+			assistantMsg.AddFinish(message.FinishReasonCanceled)
+			if requestID := provider.RequestIDFromError(processErr); requestID != "" {
+				assistantMsg.SetProviderRequestID(requestID)
+				logging.Error("[Agent] Provider request failed", "sessionID", sessionID, "messageID", assistantMsg.ID, "requestID", requestID, "error", processErr)
+			}
+			_ = a.messages.Update(ctx, assistantMsg)
 			return assistantMsg, nil, processErr
 		}
 		if ctx.Err() != nil {
 			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled)
 			return assistantMsg, nil, ctx.Err()
 		}
+
+		ticker.observe(event)
+		// A completed response's usage was recorded as the provider reported it
+		if event.Type != provider.EventComplete && ticker.overBudget() {
+			cancelStream()
+			estimate := ticker.estimate()
+			logging.Warn("Session cost budget exceeded, stopping generation", "sessionID", sessionID, "sessionCost", estimate.SessionCost, "maxCost", maxCost)
+			if err := a.trackUsage(context.Background(), sessionID, sessionProvider.Model(), ticker.usage(), true); err != nil {
+				logging.Error("Failed to record estimated usage", "sessionID", sessionID, "error", err)
+			}
+			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonBudgetExceeded)
+			return assistantMsg, nil, ErrBudgetExceeded
+		}
+		if ticker.due() {
+			estimate := ticker.estimate()
+			if err := a.Publish(ctx, pubsub.CreatedEvent, AgentEvent{
+				Type:      AgentEventTypeUsage,
+				SessionID: sessionID,
+				Usage:     &estimate,
+			}); err != nil {
+				logging.Debug("Failed to publish usage estimate", "sessionID", sessionID, "error", err)
+			}
+		}
 	}
 
+	// The request's span ends with the stream, before the tools run
+	chatSpan.End()
+
 	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
 	toolCalls := assistantMsg.ToolCalls()
 
@@ -495,7 +1091,7 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 
 			// Find tool
 			var tool tools.BaseTool
-			for _, availableTool := range a.tools {
+			for _, availableTool := range a.Tools() {
 				if availableTool.Info().Name == tc.Name {
 					tool = availableTool
 					break
@@ -515,8 +1111,21 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 				return
 			}
 
+			// The model may still call a tool it saw before it was disabled
+			if disabled[tc.Name] {
+				resultChan <- toolExecResult{
+					index: index,
+					result: message.ToolResult{
+						ToolCallID: tc.ID,
+						Content:    fmt.Sprintf("Tool %s is disabled for this session.", tc.Name),
+						IsError:    true,
+					},
+				}
+				return
+			}
+
 			// Check if tool is available in plan mode
-			if ctx.Value("plan_mode") != nil && !isToolAllowedInPlanMode(tool) {
+			if state.PlanMode && !isToolAllowedInPlanMode(tool) {
 				resultChan <- toolExecResult{
 					index: index,
 					result: message.ToolResult{
@@ -528,14 +1137,45 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 				return
 			}
 
+			// Snapshot the files before the turn's first tool that may change them
+			if !isToolAllowedInPlanMode(tool) && !state.DryRun {
+				if turn, ok := a.turns.Load(sessionID); ok {
+					turn.(*checkpoint.Turn).Capture(ctx)
+				}
+			}
+
 			logging.Info("[Agent] Executing tool", "toolName", tc.Name, "sessionID", sessionID, "toolCallID", tc.ID, "inputSize", len(tc.Input), "inputContent", tc.Input)
 
+			toolCtx, toolSpan := startToolSpan(ctx, tc)
+			defer toolSpan.End()
 			toolStartTime := time.Now()
-			toolResult, toolErr := tool.Run(ctx, tools.ToolCall{
-				ID:    tc.ID,
-				Name:  tc.Name,
-				Input: tc.Input,
-			})
+			var toolResult tools.ToolResponse
+			var toolErr error
+			if invalid := tools.ValidateInput(tool.Info(), tc.Input); invalid != nil {
+				// Returned to the model so it can correct the call; the tool is not run
+				logging.Warn("[Agent] Tool input failed validation", "toolName", tc.Name, "sessionID", sessionID, "toolCallID", tc.ID, "problems", invalid.Problems)
+				toolResult = invalid.Response()
+			} else if toolErr = chaos.Inject(chaos.ToolTimeout); toolErr != nil {
+				toolResult = tools.NewTextErrorResponse(toolErr.Error())
+			} else if _, describes := tool.(tools.DryRunTool); state.DryRun && !describes && !isToolAllowedInPlanMode(tool) {
+				// Only the tools plan mode allows are known not to change anything
+				toolResult = tools.NewDryRunResponse(tools.DryRunEffect{Tool: tc.Name, Input: tc.Input})
+			} else {
+				toolResult, toolErr = a.runTool(toolCtx, tool, tools.ToolCall{
+					ID:    tc.ID,
+					Name:  tc.Name,
+					Input: tc.Input,
+					State: state,
+				})
+				if toolErr == nil && toolResult.Cost == 0 {
+					toolResult.Cost = config.Get().ToolCosts[tc.Name]
+				}
+			}
+			// Redacted before the full output is kept as an artifact
+			toolResult = tools.RedactSecrets(toolResult, state.Env)
+			if tc.Name != tools.ViewArtifactToolName {
+				toolResult = tools.LimitOutput(sessionID, tc.ID, toolResult)
+			}
 			toolDuration := time.Since(toolStartTime)
 
 			logging.Info("[Agent] Tool execution result", "toolName", tc.Name, "sessionID", sessionID, "toolCallID", tc.ID, "duration", toolDuration, "error", toolErr, "resultLength", len(toolResult.Content), "resultContent", toolResult.Content, "resultIsError", toolResult.IsError)
@@ -556,6 +1196,20 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 				logging.Error("[Agent] Tool execution failed", "toolName", tc.Name, "sessionID", sessionID, "toolCallID", tc.ID, "hasError", isError)
 			}
 
+			a.recordToolAudit(state, tc, toolResult, toolErr, permissionDenied, toolDuration)
+			toolSpan.SetAttributes(attrToolError.Bool(toolErr != nil || toolResult.IsError))
+			tracing.Fail(toolSpan, toolErr)
+			metrics.ToolCalls.Inc(tc.Name)
+			metrics.ToolDuration.Observe(toolDuration.Seconds(), tc.Name)
+			if toolErr != nil || toolResult.IsError {
+				metrics.ToolFailures.Inc(tc.Name)
+			}
+			if toolResult.Cost > 0 {
+				if err := a.sessions.AddCost(context.Background(), sessionID, toolResult.Cost); err != nil {
+					logging.Error("Failed to add tool cost to session", "toolName", tc.Name, "sessionID", sessionID, "error", err)
+				}
+			}
+
 			result := message.ToolResult{
 				ToolCallID: tc.ID,
 				Content:    toolResult.Content,
@@ -656,12 +1310,45 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 	return assistantMsg, &msg, err
 }
 
+// recordToolAudit stores an audit entry for an executed tool call. Failures are
+// logged so that auditing never blocks the tool loop.
+func (a *agent) recordToolAudit(state tools.RequestState, tc message.ToolCall, toolResult tools.ToolResponse, toolErr error, permissionDenied bool, duration time.Duration) {
+	// The result was redacted when the tool returned; the input and error are
+	// redacted the same way, with the session's secret environment values
+	secrets := redact.EnvSecrets(state.Env)
+	input, _ := redact.Default().Redact(tc.Input, secrets...)
+	entry := audit.Entry{
+		SessionID:          state.SessionID,
+		MessageID:          state.MessageID,
+		ToolCallID:         tc.ID,
+		ToolName:           tc.Name,
+		Input:              input,
+		Output:             toolResult.Content,
+		IsError:            toolResult.IsError || toolErr != nil,
+		PermissionDecision: audit.PermissionAllowed,
+		Duration:           duration,
+		Cost:               toolResult.Cost,
+	}
+	if toolErr != nil {
+		entry.Error, _ = redact.Default().Redact(toolErr.Error(), secrets...)
+	}
+	if permissionDenied {
+		entry.PermissionDecision = audit.PermissionDenied
+	} else if errors.Is(toolErr, netpolicy.ErrBlocked) {
+		entry.PermissionDecision = audit.PermissionBlocked
+	}
+
+	if _, err := a.audits.Record(context.Background(), entry); err != nil {
+		logging.Error("Failed to record tool audit", "toolName", tc.Name, "toolCallID", tc.ID, "error", err)
+	}
+}
+
 func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishReson message.FinishReason) {
 	msg.AddFinish(finishReson)
 	_ = a.messages.Update(ctx, *msg)
 }
 
-func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, event provider.ProviderEvent) error {
+func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, toolInputs *toolInputStream, event provider.ProviderEvent) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -698,16 +1385,21 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 			return err
 		}
 		return a.messages.Update(ctx, *assistantMsg)
-	// TODO: see how to handle this
-	// case provider.EventToolUseDelta:
-	// 	tm := time.Unix(assistantMsg.UpdatedAt, 0)
-	// 	assistantMsg.AppendToolCallInput(event.ToolCall.ID, event.ToolCall.Input)
-	// 	if time.Since(tm) > 1000*time.Millisecond {
-	// 		err := a.messages.Update(ctx, *assistantMsg)
-	// 		assistantMsg.UpdatedAt = time.Now().Unix()
-	// 		return err
-	// 	}
+	case provider.EventToolUseDelta:
+		assistantMsg.AppendToolCallInput(event.ToolCall.ID, event.ToolCall.Input)
+		toolInputs.add(event.ToolCall.ID, event.ToolCall.Input)
+		if toolInputs.publishDue() {
+			for _, delta := range toolInputs.take() {
+				a.publishToolInput(ctx, sessionID, assistantMsg, delta)
+			}
+		}
+		if toolInputs.saveDue() {
+			return a.messages.Update(ctx, *assistantMsg)
+		}
 	case provider.EventToolUseStop:
+		if delta, ok := toolInputs.takeCall(event.ToolCall.ID); ok {
+			a.publishToolInput(ctx, sessionID, assistantMsg, delta)
+		}
 		assistantMsg.FinishToolCall(event.ToolCall.ID)
 		// Publish tool completion event for real-time streaming
 		err := a.Publish(ctx, pubsub.CreatedEvent, AgentEvent{
@@ -729,27 +1421,73 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 	case provider.EventComplete:
 		assistantMsg.SetToolCalls(event.Response.ToolCalls)
 		assistantMsg.AddFinish(event.Response.FinishReason)
+		assistantMsg.SetProviderRequestID(event.Response.RequestID)
+		// A fallback provider reports the model that actually answered
+		model := a.provider.Model()
+		if event.Response.Model.ID != "" {
+			model = event.Response.Model
+		}
+		assistantMsg.SetAnsweredBy(model)
+		assistantMsg.SetCost(usageCost(model, event.Response.Usage))
+		logging.Info("[Agent] Provider response completed", "sessionID", sessionID, "messageID", assistantMsg.ID, "requestID", event.Response.RequestID, "model", model.ID)
 		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.TrackUsage(ctx, sessionID, a.provider.Model(), event.Response.Usage)
+		return a.TrackUsage(ctx, sessionID, model, event.Response.Usage)
 	}
 
 	return nil
 }
 
-func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.Model, usage provider.TokenUsage) error {
-	sess, err := a.sessions.Get(ctx, sessionID)
-	if err != nil {
-		return fmt.Errorf("failed to get session: %w", err)
+// publishToolInput sends the arguments a tool call streamed since its last
+// delta. Deltas are only a preview, so a failure to publish is not an error.
+func (a *agent) publishToolInput(ctx context.Context, sessionID string, assistantMsg *message.Message, delta ToolInputDelta) {
+	delta.MessageID = assistantMsg.ID
+	for _, toolCall := range assistantMsg.ToolCalls() {
+		if toolCall.ID == delta.ToolCallID {
+			delta.Name = toolCall.Name
+			break
+		}
 	}
+	if err := a.Publish(ctx, pubsub.CreatedEvent, AgentEvent{
+		Type:      AgentEventTypeToolInput,
+		SessionID: sessionID,
+		ToolInput: &delta,
+	}); err != nil {
+		logging.Debug("Failed to publish tool input", "sessionID", sessionID, "toolCallID", delta.ToolCallID, "error", err)
+	}
+}
 
-	cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
+func usageCost(model models.Model, usage provider.TokenUsage) float64 {
+	return model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
 		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
 		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
 		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+}
 
-	sess.Cost += cost
+func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.Model, usage provider.TokenUsage) error {
+	return a.trackUsage(ctx, sessionID, model, usage, false)
+}
+
+// trackUsage adds usage to the session's cost and token counts. Estimated
+// usage, charged for interrupted responses, is left out of the cache stats.
+func (a *agent) trackUsage(ctx context.Context, sessionID string, model models.Model, usage provider.TokenUsage, estimated bool) error {
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	sess.Cost += usageCost(model, usage)
+	for tokenType, tokens := range map[string]int64{
+		"input":       usage.InputTokens,
+		"output":      usage.OutputTokens,
+		"cache_read":  usage.CacheReadTokens,
+		"cache_write": usage.CacheCreationTokens,
+	} {
+		if tokens > 0 {
+			metrics.ProviderTokens.Add(float64(tokens), string(model.Provider), string(model.ID), tokenType)
+		}
+	}
 	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
 	sess.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
 
@@ -757,6 +1495,23 @@ func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.M
 	if err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
+
+	// Estimates for interrupted responses say nothing of the cache
+	if !estimated && usage.InputTokens+usage.CacheCreationTokens+usage.CacheReadTokens > 0 {
+		cacheUsage := session.CacheUsage{
+			InputTokens:          usage.InputTokens,
+			CacheCreationTokens:  usage.CacheCreationTokens,
+			CacheReadTokens:      usage.CacheReadTokens,
+			ThinkingBudgetTokens: usage.ThinkingBudget,
+		}
+		if usage.ThinkingBudget > 0 {
+			cacheUsage.ThinkingRequests = 1
+		}
+		err = a.sessions.AddCacheUsage(ctx, sessionID, cacheUsage)
+		if err != nil {
+			return fmt.Errorf("failed to record cache usage: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -793,7 +1548,9 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 		return ErrSessionBusy
 	}
 
+	a.running.Add(1)
 	go func() {
+		defer a.running.Done()
 		defer a.activeRequests.Delete(sessionID + "-summarize")
 		defer cancel()
 		event := AgentEvent{
@@ -819,12 +1576,10 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			}
 			return
 		}
-		summarizeCtx = context.WithValue(summarizeCtx, tools.SessionIDContextKey, sessionID)
-
-		// Get session working directory and add to context
+		state := tools.RequestState{SessionID: sessionID}
 		session, err := a.sessions.Get(summarizeCtx, sessionID)
 		if err == nil {
-			summarizeCtx = context.WithValue(summarizeCtx, tools.WorkingDirectoryContextKey, session.WorkingDirectory)
+			state.WorkingDirectory = session.WorkingDirectory
 		}
 
 		if len(msgs) == 0 {
@@ -874,6 +1629,7 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 		// Send the messages to the summarize provider
 		response, err := a.summarizeProvider.SendMessages(
 			summarizeCtx,
+			state,
 			msgsWithPrompt,
 			make([]tools.BaseTool, 0),
 		)
@@ -991,6 +1747,20 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 }
 
 // filterToolsForPlanMode returns only read-only and planning tools for plan mode
+// filterDisabledTools drops the tools turned off for a session.
+func filterDisabledTools(allTools []tools.BaseTool, disabled map[string]bool) []tools.BaseTool {
+	if len(disabled) == 0 {
+		return allTools
+	}
+	var enabledTools []tools.BaseTool
+	for _, tool := range allTools {
+		if !disabled[tool.Info().Name] {
+			enabledTools = append(enabledTools, tool)
+		}
+	}
+	return enabledTools
+}
+
 func filterToolsForPlanMode(allTools []tools.BaseTool) []tools.BaseTool {
 	var planModeTools []tools.BaseTool
 	for _, tool := range allTools {
@@ -1013,89 +1783,131 @@ func isToolAllowedInPlanMode(tool tools.BaseTool) bool {
 		"glob":           true,
 		"todo_write":     true,
 		"exit_plan_mode": true,
+		"ask_user":       true,
 		"fetch":          true,
+		"tool_schema":    true,
+		"view_artifact":  true,
+		"grammar_check":  true,
+		"git_status":     true,
+		"git_diff":       true,
+		"git_log":        true,
 	}
 
 	return allowedTools[toolName]
 }
 
-func createAgentProvider(agentName config.AgentName) (provider.Provider, error) {
-	cfg := config.Get()
-	agentConfig, ok := cfg.Agents[agentName]
-	if !ok {
-		return nil, fmt.Errorf("agent %s not found", agentName)
-	}
-	model, ok := models.SupportedModels[agentConfig.Model]
+// compactSessionTools returns availableTools with those the session sends
+// abbreviated wrapped. Which tools those are is chosen once per session, once
+// it has unusedTurns assistant turns, from the tools it didn't call in them:
+// tool definitions lead every request, so changing them from turn to turn
+// would defeat prompt caching. Until then tools are sent in full.
+func (a *agent) compactSessionTools(sessionID string, availableTools []tools.BaseTool, msgHistory []message.Message) []tools.BaseTool {
+	compactCfg := config.Get().CompactTools
+	if !compactCfg.Enabled {
+		return availableTools
+	}
+	chosen, ok := a.compactedTools.Load(sessionID)
 	if !ok {
-		return nil, fmt.Errorf("model %s not supported", agentConfig.Model)
+		compacted, ok := unusedTools(availableTools, msgHistory, compactCfg.UnusedTurns)
+		if !ok {
+			return availableTools
+		}
+		chosen, _ = a.compactedTools.LoadOrStore(sessionID, compacted)
 	}
+	return compactTools(availableTools, chosen.(map[string]bool))
+}
 
-	providerCfg, ok := cfg.Providers[model.Provider]
-	if !ok {
-		return nil, fmt.Errorf("provider %s not supported", model.Provider)
-	}
-	if providerCfg.Disabled {
-		return nil, fmt.Errorf("provider %s is not enabled", model.Provider)
+// unusedTools returns the names of the tools not called in the last
+// unusedTurns assistant messages, and false when there are fewer turns than
+// that. Tools whose schema was fetched through tool_schema count as used.
+func unusedTools(availableTools []tools.BaseTool, msgHistory []message.Message, unusedTurns int) (map[string]bool, bool) {
+	recentlyUsed := make(map[string]bool)
+	turns := 0
+	for i := len(msgHistory) - 1; i >= 0 && turns < unusedTurns; i-- {
+		if msgHistory[i].Role != message.Assistant {
+			continue
+		}
+		turns++
+		for _, tc := range msgHistory[i].ToolCalls() {
+			recentlyUsed[tc.Name] = true
+			if tc.Name == tools.ToolSchemaToolName {
+				var params tools.ToolSchemaParams
+				if err := json.Unmarshal([]byte(tc.Input), &params); err == nil {
+					recentlyUsed[params.Name] = true
+				}
+			}
+		}
 	}
-	// Note: API key validation removed - let provider client handle authentication
-	// This allows providers to support multiple authentication methods (OAuth, API key, etc.)
-	maxTokens := model.DefaultMaxTokens
-	if agentConfig.MaxTokens > 0 {
-		maxTokens = agentConfig.MaxTokens
+	if turns < unusedTurns {
+		return nil, false
 	}
-	opts := []provider.ProviderClientOption{
-		provider.WithAPIKey(providerCfg.APIKey),
-		provider.WithModel(model),
-		provider.WithMaxTokens(maxTokens),
+
+	unused := make(map[string]bool)
+	for _, tool := range availableTools {
+		if name := tool.Info().Name; !recentlyUsed[name] && name != tools.ToolSchemaToolName {
+			unused[name] = true
+		}
 	}
-	if model.Provider == models.ProviderOpenAI || model.Provider == models.ProviderLocal && model.CanReason {
-		opts = append(
-			opts,
-			provider.WithOpenAIOptions(
-				provider.WithReasoningEffort(agentConfig.ReasoningEffort),
-			),
-		)
-	} else if model.Provider == models.ProviderAnthropic && model.CanReason && agentName == config.AgentMain {
-		opts = append(
-			opts,
-			provider.WithAnthropicOptions(
-				provider.WithAnthropicThinkingBudgetFn(provider.DefaultThinkingBudgetFn),
-			),
-		)
+	return unused, true
+}
+
+// compactTools replaces the tools named in compacted with abbreviated
+// versions.
+func compactTools(availableTools []tools.BaseTool, compacted map[string]bool) []tools.BaseTool {
+	result := make([]tools.BaseTool, 0, len(availableTools))
+	for _, tool := range availableTools {
+		if compacted[tool.Info().Name] {
+			tool = tools.NewCompactTool(tool)
+		}
+		result = append(result, tool)
 	}
-	agentProvider, err := provider.NewProvider(
-		model.Provider,
-		opts...,
+	logging.Debug("Compacted unused tool descriptions", "compacted", len(compacted), "total", len(availableTools))
+	return result
+}
+
+func bedrockProviderOption(providerCfg config.Provider) provider.ProviderClientOption {
+	return provider.WithBedrockOptions(
+		provider.WithBedrockRegion(providerCfg.Region),
+		provider.WithBedrockProfile(providerCfg.Profile),
+		provider.WithBedrockInferenceProfile(providerCfg.InferenceProfileARN),
 	)
-	if err != nil {
-		return nil, fmt.Errorf("could not create provider: %v", err)
-	}
+}
 
-	return agentProvider, nil
+func azureProviderOption(providerCfg config.Provider, modelID models.ModelID) provider.ProviderClientOption {
+	return provider.WithAzureOptions(
+		provider.WithAzureEndpoint(providerCfg.Endpoint, providerCfg.APIVersion),
+		provider.WithAzureDeployment(providerCfg.Deployment(modelID)),
+		provider.WithAzureEntraID(providerCfg.EntraID),
+	)
 }
 
-func createSessionProvider(ctx context.Context, agentName config.AgentName, sess *session.Session) (provider.Provider, error) {
+func vertexAIProviderOption(providerCfg config.Provider) provider.ProviderClientOption {
+	return provider.WithVertexAIOptions(
+		provider.WithVertexAIProject(providerCfg.Project, providerCfg.Location),
+	)
+}
+
+func createAgentProvider(agentName config.AgentName) (provider.Provider, error) {
 	cfg := config.Get()
 	agentConfig, ok := cfg.Agents[agentName]
 	if !ok {
 		return nil, fmt.Errorf("agent %s not found", agentName)
 	}
-	model, ok := models.SupportedModels[agentConfig.Model]
-	if !ok {
-		return nil, fmt.Errorf("model %s not supported", agentConfig.Model)
-	}
+	return createProviderChain(agentName, agentConfig, nil)
+}
 
-	providerCfg, ok := cfg.Providers[model.Provider]
+// sessionProviderConfig resolves the agent config of a session's provider,
+// with the system prompt of each model in its chain.
+func sessionProviderConfig(ctx context.Context, agentName config.AgentName, sess *session.Session, settings session.AgentSettings, memories string) (config.Agent, map[models.ModelID]string, error) {
+	cfg := config.Get()
+	agentConfig, ok := cfg.Agents[agentName]
 	if !ok {
-		return nil, fmt.Errorf("provider %s not supported", model.Provider)
+		return config.Agent{}, nil, fmt.Errorf("agent %s not found", agentName)
 	}
-	if providerCfg.Disabled {
-		return nil, fmt.Errorf("provider %s is not enabled", model.Provider)
-	}
-
-	maxTokens := model.DefaultMaxTokens
-	if agentConfig.MaxTokens > 0 {
-		maxTokens = agentConfig.MaxTokens
+	// The configured max tokens are sized for the configured model
+	if settings.Model != "" {
+		agentConfig.Model = models.ModelID(settings.Model)
+		agentConfig.MaxTokens = 0
 	}
 
 	// Create session-specific variables
@@ -1106,17 +1918,86 @@ func createSessionProvider(ctx context.Context, agentName config.AgentName, sess
 	}
 
 	// Get system prompt with session variables
-	systemPrompt, err := prompt.GetAgentPromptWithVars(ctx, agentName, model.Provider, sessionVars)
+	systemPrompts := map[models.ModelID]string{}
+	for _, modelID := range append([]models.ModelID{agentConfig.Model}, agentConfig.Fallback...) {
+		model, ok := models.Lookup(modelID)
+		if !ok {
+			return config.Agent{}, nil, fmt.Errorf("model %s not supported", modelID)
+		}
+		text, err := sessionSystemPrompt(ctx, agentName, model, sessionVars, settings, memories)
+		if err != nil {
+			return config.Agent{}, nil, err
+		}
+		systemPrompts[modelID] = text
+	}
+	return agentConfig, systemPrompts, nil
+}
+
+// sessionSystemPrompt builds the system prompt of a session's requests to
+// model, with the memories section and the session's addendum.
+func sessionSystemPrompt(ctx context.Context, agentName config.AgentName, model models.Model, sessionVars map[string]string, settings session.AgentSettings, memories string) (string, error) {
+	text, err := prompt.GetAgentPromptWithVars(ctx, agentName, model.Provider, sessionVars)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load system prompt: %w", err)
+		return "", fmt.Errorf("failed to load system prompt: %w", err)
 	}
+	if memories != "" {
+		text += "\n\n" + memories
+	}
+	if settings.SystemPrompt != "" {
+		text += "\n\n" + settings.SystemPrompt
+	}
+	return text, nil
+}
 
+// createProviderChain creates the provider for the agent's model, wrapped with
+// its fallback models when any are configured. systemPrompt, when set, builds
+// the system prompt for each model's provider.
+func createProviderChain(agentName config.AgentName, agentConfig config.Agent, systemPrompt func(models.Model) (string, error)) (provider.Provider, error) {
+	modelIDs := append([]models.ModelID{agentConfig.Model}, agentConfig.Fallback...)
+	chain := make([]provider.Provider, 0, len(modelIDs))
+	for i, modelID := range modelIDs {
+		model, ok := models.Lookup(modelID)
+		if !ok {
+			return nil, fmt.Errorf("model %s not supported", modelID)
+		}
+		// The configured max tokens are sized for the primary model
+		maxTokens := model.DefaultMaxTokens
+		if i == 0 && agentConfig.MaxTokens > 0 {
+			maxTokens = agentConfig.MaxTokens
+		}
+		var opts []provider.ProviderClientOption
+		if systemPrompt != nil {
+			text, err := systemPrompt(model)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, provider.WithSystemMessage(text))
+		}
+		modelProvider, err := createModelProvider(agentName, agentConfig, model, maxTokens, opts...)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, modelProvider)
+	}
+	return provider.NewFallbackProvider(chain...), nil
+}
+
+func createModelProvider(agentName config.AgentName, agentConfig config.Agent, model models.Model, maxTokens int64, extraOpts ...provider.ProviderClientOption) (provider.Provider, error) {
+	providerCfg, ok := config.Get().Providers[model.Provider]
+	if !ok {
+		return nil, fmt.Errorf("provider %s not supported", model.Provider)
+	}
+	if providerCfg.Disabled {
+		return nil, fmt.Errorf("provider %s is not enabled", model.Provider)
+	}
+	// Note: API key validation removed - let provider client handle authentication
+	// This allows providers to support multiple authentication methods (OAuth, API key, etc.)
 	opts := []provider.ProviderClientOption{
 		provider.WithAPIKey(providerCfg.APIKey),
 		provider.WithModel(model),
-		provider.WithSystemMessage(systemPrompt),
 		provider.WithMaxTokens(maxTokens),
 	}
+	opts = append(opts, extraOpts...)
 	if model.Provider == models.ProviderOpenAI || model.Provider == models.ProviderLocal && model.CanReason {
 		opts = append(
 			opts,
@@ -1124,45 +2005,162 @@ func createSessionProvider(ctx context.Context, agentName config.AgentName, sess
 				provider.WithReasoningEffort(agentConfig.ReasoningEffort),
 			),
 		)
-	} else if model.Provider == models.ProviderAnthropic && model.CanReason && agentName == config.AgentMain {
-		opts = append(
-			opts,
-			provider.WithAnthropicOptions(
-				provider.WithAnthropicThinkingBudgetFn(provider.DefaultThinkingBudgetFn),
-			),
-		)
-	}
-	sessionProvider, err := provider.NewProvider(
+	} else if model.Provider == models.ProviderAnthropic {
+		system, tools, messages := agentConfig.Cache.Breakpoints()
+		anthropicOpts := []provider.AnthropicOption{
+			provider.WithAnthropicCacheBreakpoints(system, tools, messages),
+		}
+		if model.CanReason && agentName == config.AgentMain {
+			anthropicOpts = append(anthropicOpts, provider.WithAnthropicThinking(agentConfig.Thinking.Resolved()))
+		}
+		opts = append(opts, provider.WithAnthropicOptions(anthropicOpts...))
+	} else if model.Provider == models.ProviderBedrock {
+		opts = append(opts, bedrockProviderOption(providerCfg))
+	} else if model.Provider == models.ProviderAzure {
+		opts = append(opts, azureProviderOption(providerCfg, model.ID))
+	} else if model.Provider == models.ProviderVertexAI {
+		opts = append(opts, vertexAIProviderOption(providerCfg))
+	}
+	modelProvider, err := provider.NewProvider(
 		model.Provider,
 		opts...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("could not create session provider: %v", err)
+		return nil, fmt.Errorf("could not create provider: %v", err)
 	}
 
-	return sessionProvider, nil
+	return modelProvider, nil
 }
 
+// sessionProviderEntry is the pool key of a session's provider, with the
+// fingerprint of the context files its system prompt was built from.
+type sessionProviderEntry struct {
+	key          string
+	contextFiles string
+	// systemTokens approximates the system prompt of the primary model
+	systemTokens int64
+}
+
+// getOrCreateSessionProvider returns the session's provider from the pool,
+// resolving its system prompt on first use, once the session's context files
+// changed and once the pool evicted it.
 func (a *agent) getOrCreateSessionProvider(ctx context.Context, sessionID string, session *session.Session) (provider.Provider, error) {
-	// Create new session provider
-	sessionProvider, err := createSessionProvider(ctx, a.agentName, session)
+	contextFiles := prompt.ContextFingerprint(session.WorkingDirectory)
+	if cached, ok := a.sessionProviders.Load(sessionID); ok {
+		entry := cached.(sessionProviderEntry)
+		if entry.contextFiles != contextFiles {
+			logging.Info("Context files changed, rebuilding the session's system prompt", "sessionID", sessionID)
+		} else if pooled, ok := a.providers.get(entry.key); ok {
+			return pooled, nil
+		}
+	}
+
+	settings, err := a.sessions.AgentSettings(ctx, sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session provider: %w", err)
+		return nil, fmt.Errorf("failed to load session agent settings: %w", err)
+	}
+
+	memories, err := a.memoryPrompt(ctx, *session)
+	if err != nil {
+		return nil, err
 	}
 
-	// Atomically store if not exists, or load existing
-	actual, loaded := a.sessionProviders.LoadOrStore(sessionID, sessionProvider)
-	if loaded {
-		// Another goroutine already created one, use theirs
-		return actual.(provider.Provider), nil
+	agentConfig, systemPrompts, err := sessionProviderConfig(ctx, a.agentName, session, settings, memories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session provider: %w", err)
+	}
+	key := providerKey(a.agentName, agentConfig, systemPrompts)
+	sessionProvider, err := a.providers.getOrCreate(key, func() (provider.Provider, error) {
+		return createProviderChain(a.agentName, agentConfig, func(model models.Model) (string, error) {
+			return systemPrompts[model.ID], nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session provider: %w", err)
 	}
 
-	// We successfully stored our provider
+	a.sessionProviders.Store(sessionID, sessionProviderEntry{
+		key:          key,
+		contextFiles: contextFiles,
+		systemTokens: textTokens(systemPrompts[agentConfig.Model]),
+	})
 	return sessionProvider, nil
 }
 
-func (a *agent) Shutdown() {
+// sessionSystemTokens approximates the system prompt of the session's
+// provider, or returns 0 before the provider is created.
+func (a *agent) sessionSystemTokens(sessionID string) int64 {
+	if cached, ok := a.sessionProviders.Load(sessionID); ok {
+		return cached.(sessionProviderEntry).systemTokens
+	}
+	return 0
+}
+
+// InvalidateSessionProvider drops the cached provider so the next run rebuilds
+// its system prompt from the current session record.
+func (a *agent) InvalidateSessionProvider(sessionID string) {
+	if _, existed := a.sessionProviders.LoadAndDelete(sessionID); existed {
+		logging.Info("Invalidated session provider cache", "sessionID", sessionID)
+	}
+}
+
+func (a *agent) ReloadProviders() error {
+	agentProvider, err := createAgentProvider(a.agentName)
+	if err != nil {
+		return fmt.Errorf("failed to create provider: %w", err)
+	}
+	a.provider = agentProvider
+	if a.titleProvider != nil {
+		if a.titleProvider, err = createAgentProvider(config.AgentMain); err != nil {
+			return fmt.Errorf("failed to create title provider: %w", err)
+		}
+	}
+	if a.summarizeProvider != nil {
+		if a.summarizeProvider, err = createAgentProvider(config.AgentMain); err != nil {
+			return fmt.Errorf("failed to create summarize provider: %w", err)
+		}
+	}
+	a.invalidateSessionProviders()
+	a.providers.clear()
+	// The tool set or compactTools settings may have changed
+	a.compactedTools.Clear()
+	return nil
+}
+
+// invalidateSessionProviders drops every session's provider key so each
+// session picks up edited prompt files on its next run. Providers built from
+// the old prompts stay pooled until they go idle.
+func (a *agent) invalidateSessionProviders() {
+	a.sessionProviders.Range(func(key, _ any) bool {
+		a.sessionProviders.Delete(key)
+		return true
+	})
+}
+
+// Shutdown cancels in-flight generations and summaries and waits for them to
+// save their final state, or until ctx is done.
+func (a *agent) Shutdown(ctx context.Context) error {
+	// Drop the queued turns first, so none starts as a running one ends
+	a.followUps.cancelAll()
+	a.activeRequests.Range(func(key, value interface{}) bool {
+		if cancel, ok := value.(context.CancelFunc); ok {
+			cancel()
+		}
+		return true
+	})
 	a.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		a.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (a *agent) handleSessionEvents() {
@@ -1175,6 +2173,7 @@ func (a *agent) handleSessionEvents() {
 			if _, existed := a.sessionProviders.LoadAndDelete(sessionID); existed {
 				logging.Info("Cleaned up session provider cache", "sessionID", sessionID)
 			}
+			a.compactedTools.Delete(sessionID)
 		}
 	}
 }
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mix/internal/audit"
+	"mix/internal/llm/tools"
+	"mix/internal/message"
+	"mix/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedAudits struct {
+	audit.Service
+	entries []audit.Entry
+}
+
+func (r *recordedAudits) Record(_ context.Context, entry audit.Entry) (audit.Entry, error) {
+	r.entries = append(r.entries, entry)
+	return entry, nil
+}
+
+func TestRecordToolAuditRedacts(t *testing.T) {
+	const (
+		apiKey    = "sk-ant-REDACTED"
+		envSecret = "deploy-secret-value-42"
+	)
+
+	tests := []struct {
+		name     string
+		input    string
+		toolErr  error
+		secrets  []string
+		expected audit.Entry
+	}{
+		{
+			name:     "credential in the input",
+			input:    `{"command":"curl -H 'x-api-key: ` + apiKey + `' https://api.anthropic.com"}`,
+			secrets:  []string{apiKey},
+			expected: audit.Entry{Input: `{"command":"curl -H 'x-api-key: ` + redact.Marker(redact.KindAnthropicKey) + `' https://api.anthropic.com"}`},
+		},
+		{
+			name:     "session secret in the input",
+			input:    `{"command":"deploy --password-file <(echo ` + envSecret + `)"}`,
+			secrets:  []string{envSecret},
+			expected: audit.Entry{Input: `{"command":"deploy --password-file <(echo ` + redact.Marker(redact.KindSecret) + `)"}`},
+		},
+		{
+			name:     "credential in the error",
+			input:    `{"command":"env"}`,
+			toolErr:  errors.New("request failed with key " + apiKey),
+			secrets:  []string{apiKey},
+			expected: audit.Entry{Input: `{"command":"env"}`, Error: "request failed with key " + redact.Marker(redact.KindAnthropicKey), IsError: true},
+		},
+		{
+			name:     "nothing to redact",
+			input:    `{"command":"ls"}`,
+			expected: audit.Entry{Input: `{"command":"ls"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audits := &recordedAudits{}
+			a := &agent{audits: audits}
+			state := tools.RequestState{SessionID: "s1", Env: map[string]string{"DEPLOY_TOKEN": envSecret}}
+			call := message.ToolCall{ID: "call-1", Name: "bash", Input: tt.input}
+
+			a.recordToolAudit(state, call, tools.NewTextResponse("done"), tt.toolErr, false, time.Second)
+
+			require.Len(t, audits.entries, 1)
+			entry := audits.entries[0]
+			assert.Equal(t, tt.expected.Input, entry.Input)
+			assert.Equal(t, tt.expected.Error, entry.Error)
+			assert.Equal(t, tt.expected.IsError, entry.IsError)
+			for _, secret := range tt.secrets {
+				assert.NotContains(t, entry.Input+entry.Error, secret)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"mix/internal/config"
+	"mix/internal/llm/tools"
+	"mix/internal/message"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedTool struct {
+	name string
+}
+
+func (t namedTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{Name: t.name, Description: "Does " + t.name + ". In detail."}
+}
+
+func (t namedTool) Run(context.Context, tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.NewTextResponse(""), nil
+}
+
+func assistantTurn(calls ...message.ToolCall) message.Message {
+	parts := make([]message.ContentPart, len(calls))
+	for i, call := range calls {
+		parts[i] = call
+	}
+	return message.Message{Role: message.Assistant, Parts: parts}
+}
+
+func toolDescriptions(available []tools.BaseTool) map[string]string {
+	descriptions := make(map[string]string, len(available))
+	for _, tool := range available {
+		descriptions[tool.Info().Name] = tool.Info().Description
+	}
+	return descriptions
+}
+
+func TestUnusedTools(t *testing.T) {
+	available := []tools.BaseTool{namedTool{"view"}, namedTool{"edit"}, namedTool{"bash"}, namedTool{tools.ToolSchemaToolName}}
+	user := message.Message{Role: message.User}
+
+	tests := []struct {
+		name     string
+		history  []message.Message
+		turns    int
+		expected map[string]bool
+		ok       bool
+	}{
+		{
+			name:    "too few turns",
+			history: []message.Message{user, assistantTurn()},
+			turns:   2,
+		},
+		{
+			name:     "called tools are used",
+			history:  []message.Message{user, assistantTurn(message.ToolCall{Name: "view"}), user, assistantTurn()},
+			turns:    2,
+			expected: map[string]bool{"edit": true, "bash": true},
+			ok:       true,
+		},
+		{
+			name:     "calls before the window don't count",
+			history:  []message.Message{assistantTurn(message.ToolCall{Name: "view"}), user, assistantTurn(), user, assistantTurn()},
+			turns:    2,
+			expected: map[string]bool{"view": true, "edit": true, "bash": true},
+			ok:       true,
+		},
+		{
+			name: "fetched schemas count as used",
+			history: []message.Message{user, assistantTurn(message.ToolCall{
+				Name:  tools.ToolSchemaToolName,
+				Input: `{"name": "bash"}`,
+			})},
+			turns:    1,
+			expected: map[string]bool{"view": true, "edit": true},
+			ok:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unused, ok := unusedTools(available, tt.history, tt.turns)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, unused)
+		})
+	}
+}
+
+func TestCompactSessionToolsChosenOnce(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false, false)
+	require.NoError(t, err)
+	config.Get().CompactTools = config.CompactToolsConfig{Enabled: true, UnusedTurns: 1}
+
+	a := &agent{}
+	available := []tools.BaseTool{namedTool{"view"}, namedTool{"edit"}}
+	user := message.Message{Role: message.User}
+
+	// Before the session has enough turns, tools are sent in full
+	first := toolDescriptions(a.compactSessionTools("session", available, []message.Message{user}))
+	assert.Equal(t, "Does view. In detail.", first["view"])
+
+	history := []message.Message{user, assistantTurn(message.ToolCall{Name: "view"})}
+	second := toolDescriptions(a.compactSessionTools("session", available, history))
+	assert.Equal(t, "Does view. In detail.", second["view"])
+	assert.Equal(t, "Does edit. (Abbreviated; call tool_schema for full documentation.)", second["edit"])
+
+	// Later turns keep the choice, though view has gone unused since
+	history = append(history, user, assistantTurn(message.ToolCall{Name: "edit"}))
+	third := toolDescriptions(a.compactSessionTools("session", available, history))
+	assert.Equal(t, second, third)
+
+	// Other sessions choose for themselves
+	other := toolDescriptions(a.compactSessionTools("other", available, history))
+	assert.Equal(t, "Does edit. In detail.", other["edit"])
+	assert.Equal(t, "Does view. (Abbreviated; call tool_schema for full documentation.)", other["view"])
+}
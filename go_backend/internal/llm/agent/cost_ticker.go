@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"time"
+
+	"mix/internal/llm/models"
+	"mix/internal/llm/provider"
+	"mix/internal/llm/tools"
+	"mix/internal/message"
+)
+
+const (
+	// costTickInterval is how often usage estimates are published while streaming
+	costTickInterval = time.Second
+	// charsPerToken approximates tokenization for interim estimates. Providers
+	// report exact usage when the generation completes.
+	charsPerToken = 4
+)
+
+// UsageEstimate is a running estimate of the current generation's input,
+// output and cost. SessionCost includes the cost already recorded on the
+// session.
+type UsageEstimate struct {
+	InputTokens  int64
+	OutputTokens int64
+	Cost         float64
+	SessionCost  float64
+}
+
+// costTicker accumulates streamed output to estimate cost before the provider
+// reports usage, so UIs can show a running meter and budgets trip mid-turn.
+// The request's input is billed whether or not the response completes, so the
+// prompt's estimated input is counted from the start.
+type costTicker struct {
+	model       models.Model
+	baseCost    float64
+	maxCost     float64
+	inputTokens int64
+	chars       int
+	lastTick    time.Time
+}
+
+func newCostTicker(model models.Model, baseCost, maxCost float64, inputTokens int64) *costTicker {
+	return &costTicker{
+		model:       model,
+		baseCost:    baseCost,
+		maxCost:     maxCost,
+		inputTokens: inputTokens,
+		lastTick:    time.Now(),
+	}
+}
+
+// promptTokens approximates the input of a request sending msgHistory and
+// availableTools after a system prompt of systemTokens.
+func promptTokens(systemTokens int64, msgHistory []message.Message, availableTools []tools.BaseTool) int64 {
+	tokens := systemTokens
+	for _, tool := range availableTools {
+		tokens += toolTokens(tool.Info())
+	}
+	for _, msg := range msgHistory {
+		tokens += messageTokens(msg)
+	}
+	return tokens
+}
+
+func (t *costTicker) observe(event provider.ProviderEvent) {
+	t.chars += len(event.Content) + len(event.Thinking)
+	if event.Type == provider.EventToolUseDelta && event.ToolCall != nil {
+		t.chars += len(event.ToolCall.Input)
+	}
+}
+
+// due reports whether an estimate should be published, resetting the interval.
+func (t *costTicker) due() bool {
+	if t.chars == 0 || time.Since(t.lastTick) < costTickInterval {
+		return false
+	}
+	t.lastTick = time.Now()
+	return true
+}
+
+// usage returns the estimated usage so far, with the input priced as uncached.
+func (t *costTicker) usage() provider.TokenUsage {
+	return provider.TokenUsage{
+		InputTokens:  t.inputTokens,
+		OutputTokens: int64(t.chars / charsPerToken),
+	}
+}
+
+func (t *costTicker) estimate() UsageEstimate {
+	usage := t.usage()
+	cost := usageCost(t.model, usage)
+	return UsageEstimate{
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		Cost:         cost,
+		SessionCost:  t.baseCost + cost,
+	}
+}
+
+func (t *costTicker) overBudget() bool {
+	return t.maxCost > 0 && t.estimate().SessionCost > t.maxCost
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"mix/internal/llm/models"
+	"mix/internal/llm/provider"
+	"mix/internal/llm/tools"
+	"mix/internal/message"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostTicker(t *testing.T) {
+	model := models.Model{CostPer1MIn: 3, CostPer1MOut: 15}
+
+	tests := []struct {
+		name        string
+		inputTokens int64
+		maxCost     float64
+		events      []provider.ProviderEvent
+		expected    UsageEstimate
+		overBudget  bool
+	}{
+		{
+			name:        "the prompt is charged before any output",
+			inputTokens: 1_000_000,
+			expected:    UsageEstimate{InputTokens: 1_000_000, Cost: 3, SessionCost: 4},
+		},
+		{
+			name:        "streamed text, reasoning and tool input are output",
+			inputTokens: 1000,
+			events: []provider.ProviderEvent{
+				{Type: provider.EventContentDelta, Content: strings.Repeat("a", 400)},
+				{Type: provider.EventThinkingDelta, Thinking: strings.Repeat("b", 400)},
+				{Type: provider.EventToolUseDelta, ToolCall: &message.ToolCall{Input: strings.Repeat("c", 200)}},
+			},
+			expected: UsageEstimate{InputTokens: 1000, OutputTokens: 250, Cost: 0.006750, SessionCost: 1.006750},
+		},
+		{
+			name:        "the prompt alone can exceed the budget",
+			inputTokens: 1_000_000,
+			maxCost:     3.5,
+			expected:    UsageEstimate{InputTokens: 1_000_000, Cost: 3, SessionCost: 4},
+			overBudget:  true,
+		},
+		{
+			name:        "within budget",
+			inputTokens: 1000,
+			maxCost:     3.5,
+			expected:    UsageEstimate{InputTokens: 1000, Cost: 0.003, SessionCost: 1.003},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticker := newCostTicker(model, 1, tt.maxCost, tt.inputTokens)
+			for _, event := range tt.events {
+				ticker.observe(event)
+			}
+
+			estimate := ticker.estimate()
+			assert.Equal(t, tt.expected.InputTokens, estimate.InputTokens)
+			assert.Equal(t, tt.expected.OutputTokens, estimate.OutputTokens)
+			assert.InDelta(t, tt.expected.Cost, estimate.Cost, 1e-9)
+			assert.InDelta(t, tt.expected.SessionCost, estimate.SessionCost, 1e-9)
+			assert.Equal(t, tt.overBudget, ticker.overBudget())
+		})
+	}
+}
+
+func TestPromptTokens(t *testing.T) {
+	history := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: strings.Repeat("a", 40)}}},
+		assistantTurn(message.ToolCall{Name: "view", Input: strings.Repeat("b", 8)}),
+	}
+
+	assert.Equal(t, int64(100), promptTokens(100, nil, nil))
+	assert.Equal(t, int64(100+10+1+2), promptTokens(100, history, nil))
+	assert.Greater(t, promptTokens(0, nil, []tools.BaseTool{namedTool{"view"}}), int64(0))
+}
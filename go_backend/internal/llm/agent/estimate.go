@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"mix/internal/config"
+	"mix/internal/llm/models"
+	"mix/internal/llm/prompt"
+	"mix/internal/llm/tools"
+	"mix/internal/message"
+	"mix/internal/pin"
+)
+
+const (
+	// imageTokens approximates one attached image; providers scale images to
+	// roughly this many tokens
+	imageTokens = 1600
+	// pdfPageTokens approximates one page of an attached PDF, sent as its
+	// text and an image of the page
+	pdfPageTokens = 2000
+	// CompactionThreshold is the share of the context window above which the
+	// history should be summarized before sending more turns
+	CompactionThreshold = 0.8
+)
+
+// TurnEstimate predicts the first model request of a turn. Tool calls add
+// further requests, so a turn that uses tools costs more than MaxCost.
+type TurnEstimate struct {
+	Model models.Model
+	// Fallback models the agent switches to if Model is unavailable
+	Fallback []models.ModelID
+
+	SystemTokens  int64
+	ToolTokens    int64
+	HistoryTokens int64
+	// PinnedTokens is the pinned files and messages sent ahead of the history
+	PinnedTokens int64
+	DraftTokens  int64
+	InputTokens  int64
+	// MaxOutputTokens is the agent's max tokens for the response
+	MaxOutputTokens int64
+
+	// MinCost assumes an empty response and, with prompt caching, a cached
+	// prefix; MaxCost assumes a full-length response and a cache miss
+	MinCost float64
+	MaxCost float64
+
+	ContextWindow int64
+	// ContextUsage is InputTokens plus MaxOutputTokens over ContextWindow
+	ContextUsage          float64
+	ExceedsContext        bool
+	CompactionRecommended bool
+}
+
+// Estimate predicts the size and cost of sending content to the session next,
+// from the history since the last summary, the system prompt and the tools
+// enabled for the session. Token counts are approximations, not provider counts.
+func (a *agent) Estimate(ctx context.Context, sessionID string, content string) (TurnEstimate, error) {
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return TurnEstimate{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	msgs, err := a.messagesSinceSummary(ctx, sess)
+	if err != nil {
+		return TurnEstimate{}, err
+	}
+
+	agentCfg := config.Get().Agents[a.agentName]
+	model := a.Model()
+	estimate := TurnEstimate{
+		Model:           model,
+		Fallback:        agentCfg.Fallback,
+		MaxOutputTokens: agentCfg.MaxTokens,
+		ContextWindow:   model.ContextWindow,
+	}
+	if estimate.MaxOutputTokens <= 0 {
+		estimate.MaxOutputTokens = model.DefaultMaxTokens
+	}
+
+	state := tools.RequestState{SessionID: sessionID, WorkingDirectory: sess.WorkingDirectory}
+	systemPrompt, err := prompt.GetAgentPromptWithVars(tools.WithRequestState(ctx, state), a.agentName, model.Provider, map[string]string{
+		"session_id":      sess.ID,
+		"session_workdir": sess.WorkingDirectory,
+	})
+	if err != nil {
+		return TurnEstimate{}, fmt.Errorf("failed to load system prompt: %w", err)
+	}
+	memories, err := a.memoryPrompt(ctx, sess)
+	if err != nil {
+		return TurnEstimate{}, err
+	}
+	estimate.SystemTokens = textTokens(systemPrompt) + textTokens(memories)
+
+	disabledTools, err := a.sessions.DisabledTools(ctx, sessionID)
+	if err != nil {
+		return TurnEstimate{}, fmt.Errorf("failed to load disabled tools: %w", err)
+	}
+	disabled := make(map[string]bool, len(disabledTools))
+	for _, name := range disabledTools {
+		disabled[name] = true
+	}
+	availableTools := filterDisabledTools(a.Tools(), disabled)
+	availableTools = a.compactSessionTools(sessionID, availableTools, msgs)
+	for _, tool := range availableTools {
+		estimate.ToolTokens += toolTokens(tool.Info())
+	}
+
+	for _, msg := range msgs {
+		estimate.HistoryTokens += messageTokens(msg)
+	}
+	pinned, err := a.pinnedContext(ctx, sess)
+	if err != nil {
+		return TurnEstimate{}, err
+	}
+	estimate.PinnedTokens = textTokens(pin.Block(pinned))
+	estimate.DraftTokens = textTokens(content)
+	estimate.InputTokens = estimate.SystemTokens + estimate.ToolTokens + estimate.HistoryTokens + estimate.PinnedTokens + estimate.DraftTokens
+
+	prefix := float64(estimate.InputTokens - estimate.DraftTokens)
+	draft := float64(estimate.DraftTokens)
+	system, cacheTools, cachedMessages := agentCfg.Cache.Breakpoints()
+	cached := (model.Provider == models.ProviderAnthropic || model.Provider == models.ProviderBedrock) &&
+		(system || cacheTools || cachedMessages > 0)
+	if cached {
+		// See usageCost: CostPer1MInCached prices cache writes and
+		// CostPer1MOutCached cache reads
+		estimate.MinCost = (prefix*model.CostPer1MOutCached + draft*model.CostPer1MIn) / 1e6
+		estimate.MaxCost = float64(estimate.InputTokens) * model.CostPer1MInCached / 1e6
+	} else {
+		estimate.MinCost = float64(estimate.InputTokens) * model.CostPer1MIn / 1e6
+		estimate.MaxCost = estimate.MinCost
+	}
+	estimate.MaxCost += float64(estimate.MaxOutputTokens) * model.CostPer1MOut / 1e6
+
+	if model.ContextWindow > 0 {
+		needed := estimate.InputTokens + estimate.MaxOutputTokens
+		estimate.ContextUsage = float64(needed) / float64(model.ContextWindow)
+		estimate.ExceedsContext = needed > model.ContextWindow
+		estimate.CompactionRecommended = estimate.ContextUsage > CompactionThreshold
+	}
+	return estimate, nil
+}
+
+func textTokens(text string) int64 {
+	return int64((len(text) + charsPerToken - 1) / charsPerToken)
+}
+
+func toolTokens(info tools.ToolInfo) int64 {
+	schema, _ := json.Marshal(info.Parameters)
+	return textTokens(info.Name) + textTokens(info.Description) + textTokens(string(schema))
+}
+
+// messageTokens approximates a message as the provider receives it. Reasoning
+// is not sent back, so it isn't counted.
+func messageTokens(msg message.Message) int64 {
+	var tokens int64
+	for _, part := range msg.Parts {
+		switch p := part.(type) {
+		case message.TextContent:
+			tokens += textTokens(p.Text)
+		case message.ToolCall:
+			tokens += textTokens(p.Name) + textTokens(p.Input)
+		case message.ToolResult:
+			tokens += textTokens(p.Content)
+		case message.BinaryContent:
+			tokens += binaryTokens(p)
+		case message.ImageURLContent:
+			tokens += imageTokens
+		}
+	}
+	return tokens
+}
+
+// pdfPageObject matches the page objects of a PDF, not its page tree nodes
+var pdfPageObject = regexp.MustCompile(`/Type\s*/Page\b`)
+
+func binaryTokens(content message.BinaryContent) int64 {
+	switch {
+	case message.IsPDFType(content.MIMEType):
+		pages := len(pdfPageObject.FindAllIndex(content.Data, -1))
+		return int64(max(pages, 1)) * pdfPageTokens
+	case message.IsTextDocumentType(content.MIMEType):
+		return textTokens(string(content.Data))
+	}
+	return imageTokens
+}
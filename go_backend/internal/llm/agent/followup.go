@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueuedMessage is a follow-up message sent while its session was generating,
+// waiting to run as one of the session's next turns.
+type QueuedMessage struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionId"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Position is 1 for the message running next
+	Position int `json:"position"`
+	// IdempotencyKey is the key the message was sent with, if any
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// queuedTurn is a queued message and the function canceling its wait.
+type queuedTurn struct {
+	message QueuedMessage
+	cancel  context.CancelFunc
+}
+
+// followUps holds the queued messages of each session, oldest first. Only the
+// oldest of a session may start, so they run in the order they were sent.
+// They are kept in memory only; the requests waiting on them end with the
+// process anyway.
+type followUps struct {
+	mu     sync.Mutex
+	queues map[string][]*queuedTurn
+	// idle holds a channel per session with waiting turns, closed when the
+	// session's running turn ends or its queue changes
+	idle map[string]chan struct{}
+}
+
+func newFollowUps() *followUps {
+	return &followUps{queues: map[string][]*queuedTurn{}, idle: map[string]chan struct{}{}}
+}
+
+// enqueue queues content as the session's next turn after the ones already
+// queued. It returns nil if a turn with the same idempotency key is queued,
+// so a retried request doesn't run twice.
+func (f *followUps) enqueue(sessionID, content, idempotencyKey string, cancel context.CancelFunc) *queuedTurn {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if idempotencyKey != "" && slices.ContainsFunc(f.queues[sessionID], func(turn *queuedTurn) bool {
+		return turn.message.IdempotencyKey == idempotencyKey
+	}) {
+		return nil
+	}
+	turn := &queuedTurn{
+		message: QueuedMessage{
+			ID:             uuid.New().String(),
+			SessionID:      sessionID,
+			Content:        content,
+			IdempotencyKey: idempotencyKey,
+			CreatedAt:      time.Now(),
+			Position:       len(f.queues[sessionID]) + 1,
+		},
+		cancel: cancel,
+	}
+	f.queues[sessionID] = append(f.queues[sessionID], turn)
+	return turn
+}
+
+// remove drops turn from its session's queue and wakes the turns behind it,
+// reporting whether it was still queued.
+func (f *followUps) remove(turn *queuedTurn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.removeLocked(turn)
+}
+
+func (f *followUps) removeLocked(turn *queuedTurn) bool {
+	sessionID := turn.message.SessionID
+	queue := f.queues[sessionID]
+	i := slices.Index(queue, turn)
+	if i < 0 {
+		return false
+	}
+	queue = slices.Delete(queue, i, i+1)
+	if len(queue) == 0 {
+		delete(f.queues, sessionID)
+	} else {
+		f.queues[sessionID] = queue
+	}
+	f.wakeLocked(sessionID)
+	return true
+}
+
+// wake signals the turns waiting in the session's queue to check whether
+// they may start.
+func (f *followUps) wake(sessionID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.wakeLocked(sessionID)
+}
+
+func (f *followUps) wakeLocked(sessionID string) {
+	if idle, ok := f.idle[sessionID]; ok {
+		close(idle)
+		delete(f.idle, sessionID)
+	}
+}
+
+func (f *followUps) list(sessionID string) []QueuedMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages := make([]QueuedMessage, 0, len(f.queues[sessionID]))
+	for i, turn := range f.queues[sessionID] {
+		message := turn.message
+		message.Position = i + 1
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// cancelAll cancels every queued turn, which then leaves its queue.
+func (f *followUps) cancelAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, queue := range f.queues {
+		for _, turn := range queue {
+			turn.cancel()
+		}
+	}
+}
+
+func (f *followUps) find(id string) (*queuedTurn, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, queue := range f.queues {
+		for _, turn := range queue {
+			if turn.message.ID == id {
+				return turn, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// waitTurn waits until turn is the oldest queued for its session and the
+// session's running turn has ended, then makes it the session's active
+// request. It returns ctx's error, after leaving the queue, if ctx is done
+// first.
+func (a *agent) waitTurn(ctx context.Context, turn *queuedTurn) error {
+	sessionID := turn.message.SessionID
+	for {
+		a.followUps.mu.Lock()
+		if queue := a.followUps.queues[sessionID]; len(queue) > 0 && queue[0] == turn {
+			if _, loaded := a.activeRequests.LoadOrStore(sessionID, turn.cancel); !loaded {
+				a.followUps.removeLocked(turn)
+				a.followUps.mu.Unlock()
+				return nil
+			}
+		}
+		idle, ok := a.followUps.idle[sessionID]
+		if !ok {
+			idle = make(chan struct{})
+			a.followUps.idle[sessionID] = idle
+		}
+		a.followUps.mu.Unlock()
+
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			a.followUps.remove(turn)
+			return ctx.Err()
+		}
+	}
+}
+
+func (a *agent) ListQueued(sessionID string) []QueuedMessage {
+	return a.followUps.list(sessionID)
+}
+
+func (a *agent) RemoveQueued(sessionID, id string) error {
+	turn, ok := a.followUps.find(id)
+	if !ok || (sessionID != "" && turn.message.SessionID != sessionID) {
+		return fmt.Errorf("%w: %s", ErrQueuedMessageNotFound, id)
+	}
+	turn.cancel()
+	a.followUps.remove(turn)
+	return nil
+}
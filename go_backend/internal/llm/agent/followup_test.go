@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queuedContents(messages []QueuedMessage) []string {
+	contents := make([]string, 0, len(messages))
+	for _, message := range messages {
+		contents = append(contents, message.Content)
+	}
+	return contents
+}
+
+func TestFollowUps(t *testing.T) {
+	f := newFollowUps()
+	first := f.enqueue("s1", "first", "", func() {})
+	second := f.enqueue("s1", "second", "", func() {})
+	third := f.enqueue("s1", "third", "", func() {})
+	f.enqueue("s2", "other", "", func() {})
+
+	assert.Equal(t, 2, second.message.Position)
+	assert.Equal(t, []string{"first", "second", "third"}, queuedContents(f.list("s1")))
+
+	require.True(t, f.remove(second))
+	assert.False(t, f.remove(second))
+	queued := f.list("s1")
+	assert.Equal(t, []string{"first", "third"}, queuedContents(queued))
+	assert.Equal(t, 2, queued[1].Position)
+
+	found, ok := f.find(third.message.ID)
+	require.True(t, ok)
+	assert.Same(t, third, found)
+	_, ok = f.find(second.message.ID)
+	assert.False(t, ok)
+
+	require.True(t, f.remove(first))
+	require.True(t, f.remove(third))
+	assert.Empty(t, f.list("s1"))
+	assert.Len(t, f.list("s2"), 1)
+}
+
+func TestFollowUpsIdempotencyKey(t *testing.T) {
+	f := newFollowUps()
+	first := f.enqueue("s1", "first", "key-1", func() {})
+	require.NotNil(t, first)
+	assert.Equal(t, "key-1", first.message.IdempotencyKey)
+
+	assert.Nil(t, f.enqueue("s1", "first retried", "key-1", func() {}), "a retried message was queued twice")
+	assert.NotNil(t, f.enqueue("s2", "other session", "key-1", func() {}))
+	assert.NotNil(t, f.enqueue("s1", "no key", "", func() {}))
+	assert.NotNil(t, f.enqueue("s1", "no key again", "", func() {}))
+	assert.Equal(t, []string{"first", "no key", "no key again"}, queuedContents(f.list("s1")))
+
+	// Once the message leaves the queue, e.g. to run, its key may be queued again
+	require.True(t, f.remove(first))
+	assert.NotNil(t, f.enqueue("s1", "first retried", "key-1", func() {}))
+}
+
+func TestStartQueuedIdempotencyKey(t *testing.T) {
+	a := &agent{followUps: newFollowUps()}
+	a.activeRequests.Store("s1", context.CancelFunc(func() {}))
+	generate := func(context.Context) AgentEvent { return AgentEvent{} }
+
+	_, err := a.start(context.Background(), "s1", false, &QueuedMessage{Content: "hi", IdempotencyKey: "key-1"}, nil, generate)
+	require.NoError(t, err)
+	_, err = a.start(context.Background(), "s1", false, &QueuedMessage{Content: "hi", IdempotencyKey: "key-1"}, nil, generate)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyQueued)
+	assert.ErrorIs(t, err, ErrSessionBusy)
+	assert.Len(t, a.ListQueued("s1"), 1)
+	a.followUps.cancelAll()
+}
+
+// waitResult reports whether a waitTurn started, with its error.
+func waitResult(t *testing.T, done <-chan error, timeout time.Duration) (error, bool) {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err, true
+	case <-time.After(timeout):
+		return nil, false
+	}
+}
+
+func TestWaitTurnOrder(t *testing.T) {
+	a := &agent{followUps: newFollowUps()}
+	a.activeRequests.Store("s1", context.CancelFunc(func() {}))
+
+	first := a.followUps.enqueue("s1", "first", "", func() {})
+	second := a.followUps.enqueue("s1", "second", "", func() {})
+	firstDone, secondDone := make(chan error, 1), make(chan error, 1)
+	go func() { secondDone <- a.waitTurn(context.Background(), second) }()
+	go func() { firstDone <- a.waitTurn(context.Background(), first) }()
+
+	_, started := waitResult(t, firstDone, 50*time.Millisecond)
+	assert.False(t, started, "a queued turn started while the session was generating")
+
+	// The running turn ends: only the oldest queued one starts
+	a.activeRequests.Delete("s1")
+	a.followUps.wake("s1")
+	err, started := waitResult(t, firstDone, time.Second)
+	require.True(t, started)
+	require.NoError(t, err)
+	_, started = waitResult(t, secondDone, 50*time.Millisecond)
+	assert.False(t, started, "the second turn started before the first ended")
+	assert.Equal(t, []string{"second"}, queuedContents(a.ListQueued("s1")))
+
+	a.activeRequests.Delete("s1")
+	a.followUps.wake("s1")
+	err, started = waitResult(t, secondDone, time.Second)
+	require.True(t, started)
+	require.NoError(t, err)
+	assert.Empty(t, a.ListQueued("s1"))
+}
+
+func TestWaitTurnCancelled(t *testing.T) {
+	a := &agent{followUps: newFollowUps()}
+	a.activeRequests.Store("s1", context.CancelFunc(func() {}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	turn := a.followUps.enqueue("s1", "first", "", cancel)
+	done := make(chan error, 1)
+	go func() { done <- a.waitTurn(ctx, turn) }()
+
+	require.NoError(t, a.RemoveQueued("s1", turn.message.ID))
+	err, finished := waitResult(t, done, time.Second)
+	require.True(t, finished)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, a.ListQueued("s1"))
+	assert.ErrorIs(t, a.RemoveQueued("s1", turn.message.ID), ErrQueuedMessageNotFound)
+}
+
+func TestStartQueuedAcquiresRunAfterWaiting(t *testing.T) {
+	a := &agent{followUps: newFollowUps()}
+	a.activeRequests.Store("s1", context.CancelFunc(func() {}))
+
+	errFull := errors.New("run queue full")
+	var acquired atomic.Int32
+	acquire := func(context.Context) (func(), error) {
+		acquired.Add(1)
+		return nil, errFull
+	}
+	events, err := a.start(context.Background(), "s1", false, &QueuedMessage{Content: "follow-up"}, acquire, func(context.Context) AgentEvent {
+		t.Error("the turn ran without a run queue slot")
+		return AgentEvent{}
+	})
+	require.NoError(t, err)
+
+	event := <-events
+	require.Equal(t, AgentEventTypeQueued, event.Type)
+	assert.Equal(t, 1, event.Queued.Position)
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, acquired.Load(), "the run queue slot was taken while the turn waited for the session")
+
+	// The running turn ends, so the queued one asks for a slot and fails
+	a.activeRequests.Delete("s1")
+	a.followUps.wake("s1")
+	event = <-events
+	assert.Equal(t, AgentEventTypeError, event.Type)
+	assert.ErrorIs(t, event.Error, errFull)
+	_, open := <-events
+	assert.False(t, open)
+	assert.Equal(t, int32(1), acquired.Load())
+
+	// The session is free again for the next request
+	_, busy := a.activeRequests.Load("s1")
+	assert.False(t, busy)
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"fmt"
+
+	"mix/internal/config"
+	"mix/internal/llm/models"
+	"mix/internal/message"
+	"mix/internal/session"
+)
+
+// applyHistoryStrategy shortens the history sent with a request as the
+// agent's history settings select, returning it and a record of what was
+// left out, nil when nothing was. The summary message, when first, is always
+// kept, as is the current turn. The stored messages are left as they are.
+func applyHistoryStrategy(settings *config.History, sess session.Session, model models.Model, msgs []message.Message) ([]message.Message, *message.HistoryPruned) {
+	if settings == nil {
+		return msgs, nil
+	}
+	h := settings.Resolved()
+	if h.ContextPercent > 0 && model.ContextWindow > 0 &&
+		(sess.PromptTokens+sess.CompletionTokens)*100 < model.ContextWindow*int64(h.ContextPercent) {
+		return msgs, nil
+	}
+
+	start := 0
+	if sess.SummaryMessageID != "" && len(msgs) > 0 && msgs[0].ID == sess.SummaryMessageID {
+		start = 1
+	}
+	turns := turnStarts(msgs, start)
+	keepTurns := max(h.KeepTurns, 1)
+	if len(turns) <= keepTurns {
+		return msgs, nil
+	}
+	recent := turns[len(turns)-keepTurns]
+
+	var pruned message.HistoryPruned
+	kept := make([]message.Message, 0, len(msgs))
+	kept = append(kept, msgs[:start]...)
+	switch h.Strategy {
+	case config.HistoryWindow, config.HistoryMiddleDrop:
+		dropFrom := start
+		if h.Strategy == config.HistoryMiddleDrop {
+			if len(turns) <= keepTurns+h.KeepFirstTurns {
+				return msgs, nil
+			}
+			dropFrom = turns[h.KeepFirstTurns]
+		}
+		kept = append(kept, msgs[start:dropFrom]...)
+		for _, msg := range msgs[dropFrom:recent] {
+			pruned.DroppedMessageIDs = append(pruned.DroppedMessageIDs, msg.ID)
+		}
+	case config.HistoryPruneToolResults:
+		for _, msg := range msgs[start:recent] {
+			msg, ids := pruneToolResults(msg)
+			kept = append(kept, msg)
+			pruned.PrunedToolCallIDs = append(pruned.PrunedToolCallIDs, ids...)
+		}
+	default:
+		return msgs, nil
+	}
+	if len(pruned.DroppedMessageIDs) == 0 && len(pruned.PrunedToolCallIDs) == 0 {
+		return msgs, nil
+	}
+	pruned.Strategy = h.Strategy
+	return append(kept, msgs[recent:]...), &pruned
+}
+
+// turnStarts returns the indexes of msgs[start:] that turns start at: each
+// user message, and start itself, so messages before the first user message
+// make a turn of their own.
+func turnStarts(msgs []message.Message, start int) []int {
+	var starts []int
+	for i := start; i < len(msgs); i++ {
+		if i == start || msgs[i].Role == message.User {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// pruneToolResults returns a copy of msg with the content of its tool results
+// replaced by placeholders, and the IDs of the calls whose results were.
+// Results shorter than their placeholder are kept.
+func pruneToolResults(msg message.Message) (message.Message, []string) {
+	var ids []string
+	var parts []message.ContentPart
+	for i, part := range msg.Parts {
+		result, ok := part.(message.ToolResult)
+		if !ok {
+			continue
+		}
+		placeholder := fmt.Sprintf("[Result of %s left out of the history: %d bytes]", result.Name, len(result.Content))
+		if len(result.Content) <= len(placeholder) {
+			continue
+		}
+		if parts == nil {
+			parts = append([]message.ContentPart(nil), msg.Parts...)
+		}
+		result.Content, result.Metadata = placeholder, ""
+		parts[i] = result
+		ids = append(ids, result.ToolCallID)
+	}
+	if parts != nil {
+		msg.Parts = parts
+	}
+	return msg, ids
+}
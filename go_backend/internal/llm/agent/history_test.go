@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"mix/internal/config"
+	"mix/internal/llm/models"
+	"mix/internal/message"
+	"mix/internal/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyHistoryStrategy(t *testing.T) {
+	model := models.Model{ContextWindow: 1000}
+
+	tests := []struct {
+		name     string
+		settings *config.History
+		session  session.Session
+		roles    string
+		expected []string
+		dropped  []string
+	}{
+		{
+			name:     "no strategy",
+			roles:    "uauauaua",
+			expected: []string{"0", "1", "2", "3", "4", "5", "6", "7"},
+		},
+		{
+			name:     "window keeps the last turns",
+			settings: &config.History{Strategy: config.HistoryWindow, KeepTurns: 2},
+			roles:    "uauauaua",
+			expected: []string{"4", "5", "6", "7"},
+			dropped:  []string{"0", "1", "2", "3"},
+		},
+		{
+			name:     "window with no more turns than kept",
+			settings: &config.History{Strategy: config.HistoryWindow, KeepTurns: 4},
+			roles:    "uauauaua",
+			expected: []string{"0", "1", "2", "3", "4", "5", "6", "7"},
+		},
+		{
+			name:     "window keeps the summary",
+			settings: &config.History{Strategy: config.HistoryWindow, KeepTurns: 1},
+			session:  session.Session{SummaryMessageID: "0"},
+			roles:    "auauaua",
+			expected: []string{"0", "5", "6"},
+			dropped:  []string{"1", "2", "3", "4"},
+		},
+		{
+			name:     "messages before the first user message are a turn",
+			settings: &config.History{Strategy: config.HistoryWindow, KeepTurns: 2},
+			roles:    "auaua",
+			expected: []string{"1", "2", "3", "4"},
+			dropped:  []string{"0"},
+		},
+		{
+			name:     "middle-drop keeps the first and last turns",
+			settings: &config.History{Strategy: config.HistoryMiddleDrop, KeepTurns: 1},
+			roles:    "uauauaua",
+			expected: []string{"0", "1", "6", "7"},
+			dropped:  []string{"2", "3", "4", "5"},
+		},
+		{
+			name:     "middle-drop with nothing in between",
+			settings: &config.History{Strategy: config.HistoryMiddleDrop, KeepTurns: 2, KeepFirstTurns: 2},
+			roles:    "uauauaua",
+			expected: []string{"0", "1", "2", "3", "4", "5", "6", "7"},
+		},
+		{
+			name:     "below the context threshold",
+			settings: &config.History{Strategy: config.HistoryWindow, KeepTurns: 1, ContextPercent: 50},
+			session:  session.Session{PromptTokens: 300, CompletionTokens: 100},
+			roles:    "uauaua",
+			expected: []string{"0", "1", "2", "3", "4", "5"},
+		},
+		{
+			name:     "past the context threshold",
+			settings: &config.History{Strategy: config.HistoryWindow, KeepTurns: 1, ContextPercent: 50},
+			session:  session.Session{PromptTokens: 500, CompletionTokens: 100},
+			roles:    "uauaua",
+			expected: []string{"4", "5"},
+			dropped:  []string{"0", "1", "2", "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, pruned := applyHistoryStrategy(tt.settings, tt.session, model, rolesHistory(tt.roles))
+			assert.Equal(t, tt.expected, messageIDs(msgs))
+			if tt.dropped == nil {
+				assert.Nil(t, pruned)
+				return
+			}
+			require.NotNil(t, pruned)
+			assert.Equal(t, tt.settings.Strategy, pruned.Strategy)
+			assert.Equal(t, tt.dropped, pruned.DroppedMessageIDs)
+			assert.Empty(t, pruned.PrunedToolCallIDs)
+		})
+	}
+}
+
+func TestApplyHistoryStrategyPrunesToolResults(t *testing.T) {
+	long := strings.Repeat("x", 500)
+	msgs := rolesHistory("uatuatua")
+	msgs[2].Parts = []message.ContentPart{message.ToolResult{ToolCallID: "call-1", Name: "view", Content: long, Metadata: "{}"}}
+	msgs[5].Parts = []message.ContentPart{message.ToolResult{ToolCallID: "call-2", Name: "ls", Content: "short"}}
+	settings := &config.History{Strategy: config.HistoryPruneToolResults, KeepTurns: 1}
+
+	kept, pruned := applyHistoryStrategy(settings, session.Session{}, models.Model{}, msgs)
+
+	require.NotNil(t, pruned)
+	assert.Equal(t, config.HistoryPruneToolResults, pruned.Strategy)
+	assert.Equal(t, []string{"call-1"}, pruned.PrunedToolCallIDs)
+	assert.Empty(t, pruned.DroppedMessageIDs)
+	assert.Equal(t, messageIDs(msgs), messageIDs(kept))
+
+	result := kept[2].Parts[0].(message.ToolResult)
+	assert.Equal(t, "[Result of view left out of the history: 500 bytes]", result.Content)
+	assert.Empty(t, result.Metadata)
+	assert.Equal(t, "short", kept[5].Parts[0].(message.ToolResult).Content)
+	// The stored messages are left as they are
+	assert.Equal(t, long, msgs[2].Parts[0].(message.ToolResult).Content)
+}
@@ -6,12 +6,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"mix/internal/config"
 	"mix/internal/llm/tools"
 	"mix/internal/logging"
+	"mix/internal/netpolicy"
 	"mix/internal/permission"
 	"mix/internal/version"
 
@@ -43,17 +47,40 @@ type MCPClient interface {
 type MCPClientManager struct {
 	mu      sync.RWMutex
 	clients map[string]*client.Client
+	// env is the session environment a client's server was started with,
+	// keyed like clients
+	env map[string][]string
 }
 
 func NewMCPClientManager() *MCPClientManager {
 	return &MCPClientManager{
 		clients: make(map[string]*client.Client),
+		env:     make(map[string][]string),
 	}
 }
 
 func (m *MCPClientManager) GetClient(ctx context.Context, serverName string, mcpConfig config.MCPServer) (*client.Client, error) {
+	return m.getClient(ctx, serverName, mcpConfig, nil)
+}
+
+// GetSessionClient returns the client for a session. A stdio server used by a
+// session with environment variables runs as a separate process started with
+// them, restarted when they change; otherwise the shared client is used.
+func (m *MCPClientManager) GetSessionClient(ctx context.Context, serverName string, mcpConfig config.MCPServer, sessionID string, env []string) (*client.Client, error) {
+	if mcpConfig.Type != config.MCPStdio || len(env) == 0 {
+		return m.GetClient(ctx, serverName, mcpConfig)
+	}
+	return m.getClient(ctx, sessionClientKey(serverName, sessionID), mcpConfig, env)
+}
+
+// sessionClientKey keys a session's own client of a server
+func sessionClientKey(serverName, sessionID string) string {
+	return serverName + "\x00" + sessionID
+}
+
+func (m *MCPClientManager) getClient(ctx context.Context, key string, mcpConfig config.MCPServer, env []string) (*client.Client, error) {
 	m.mu.RLock()
-	if c, exists := m.clients[serverName]; exists {
+	if c, exists := m.clients[key]; exists && slices.Equal(m.env[key], env) {
 		// Check if client is healthy
 		if c.IsInitialized() {
 			pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
@@ -63,9 +90,6 @@ func (m *MCPClientManager) GetClient(ctx context.Context, serverName string, mcp
 				return c, nil
 			}
 		}
-		// Client is unhealthy, close it
-		c.Close()
-		delete(m.clients, serverName)
 	}
 	m.mu.RUnlock()
 
@@ -73,9 +97,10 @@ func (m *MCPClientManager) GetClient(ctx context.Context, serverName string, mcp
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Double-check after acquiring write lock
-	if c, exists := m.clients[serverName]; exists {
-		if c.IsInitialized() {
+	// Double-check after acquiring write lock; replace unhealthy clients and
+	// servers started with a different environment
+	if c, exists := m.clients[key]; exists {
+		if c.IsInitialized() && slices.Equal(m.env[key], env) {
 			pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 			defer cancel()
 			if err := c.Ping(pingCtx); err == nil {
@@ -83,7 +108,8 @@ func (m *MCPClientManager) GetClient(ctx context.Context, serverName string, mcp
 			}
 		}
 		c.Close()
-		delete(m.clients, serverName)
+		delete(m.clients, key)
+		delete(m.env, key)
 	}
 
 	// Create new client
@@ -92,15 +118,23 @@ func (m *MCPClientManager) GetClient(ctx context.Context, serverName string, mcp
 
 	switch mcpConfig.Type {
 	case config.MCPStdio:
+		// Route the server's HTTP(S) traffic through the egress policy proxy
+		proxyEnv, envErr := netpolicy.Default().SubprocessEnv()
+		if envErr != nil {
+			return nil, envErr
+		}
 		newClient, err = client.NewStdioMCPClient(
 			mcpConfig.Command,
-			mcpConfig.Env,
+			append(append(slices.Clone(mcpConfig.Env), proxyEnv...), env...),
 			mcpConfig.Args...,
 		)
 	case config.MCPSse:
+		// Dial through the egress policy; the event stream is long-lived, so
+		// the client has no timeout
 		newClient, err = client.NewSSEMCPClient(
 			mcpConfig.URL,
 			client.WithHeaders(mcpConfig.Headers),
+			client.WithHTTPClient(netpolicy.Default().Client(0)),
 		)
 	default:
 		return nil, fmt.Errorf("invalid mcp type: %s", mcpConfig.Type)
@@ -127,7 +161,8 @@ func (m *MCPClientManager) GetClient(ctx context.Context, serverName string, mcp
 	}
 
 	// Store the client
-	m.clients[serverName] = newClient
+	m.clients[key] = newClient
+	m.env[key] = env
 	return newClient, nil
 }
 
@@ -141,17 +176,23 @@ func (m *MCPClientManager) Close() {
 		}
 	}
 	m.clients = make(map[string]*client.Client)
+	m.env = make(map[string][]string)
 }
 
+// CloseClient closes the server's shared client and its session clients.
 func (m *MCPClientManager) CloseClient(serverName string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if client, exists := m.clients[serverName]; exists {
+	for key, client := range m.clients {
+		if key != serverName && !strings.HasPrefix(key, serverName+"\x00") {
+			continue
+		}
 		if err := client.Close(); err != nil {
 			logging.Debug("error closing mcp client", "server", serverName, "error", err)
 		}
-		delete(m.clients, serverName)
+		delete(m.clients, key)
+		delete(m.env, key)
 	}
 }
 
@@ -212,27 +253,30 @@ func runTool(ctx context.Context, c *client.Client, toolName string, input strin
 }
 
 func (b *mcpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolResponse, error) {
-	sessionID, messageID := tools.GetContextValues(ctx)
+	sessionID, messageID := params.State.SessionID, params.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return tools.ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
-	permissionDescription := fmt.Sprintf("execute %s with the following parameters: %s", b.Info().Name, params.Input)
-	p := b.permissions.Request(
-		permission.CreatePermissionRequest{
-			SessionID:   sessionID,
-			Path:        ctx.Value(tools.WorkingDirectoryContextKey).(string),
-			ToolName:    b.Info().Name,
-			Action:      "execute",
-			Description: permissionDescription,
-			Params:      params.Input,
-		},
-	)
-	if !p {
-		return tools.NewTextErrorResponse("permission denied"), nil
+	// Tools the server's config auto-approves run without a prompt
+	if !matchesTool(b.tool.Name, b.mcpConfig.AutoApprove) {
+		permissionDescription := fmt.Sprintf("execute %s with the following parameters: %s", b.Info().Name, params.Input)
+		p := b.permissions.Request(
+			permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        params.State.WorkingDirectory,
+				ToolName:    b.Info().Name,
+				Action:      "execute",
+				Description: permissionDescription,
+				Params:      params.Input,
+			},
+		)
+		if !p {
+			return tools.NewTextErrorResponse("permission denied"), nil
+		}
 	}
 
 	// Get client from manager (handles creation, caching, and health checking)
-	c, err := b.manager.GetClient(ctx, b.mcpName, b.mcpConfig)
+	c, err := b.manager.GetSessionClient(ctx, b.mcpName, b.mcpConfig, sessionID, params.State.Environ())
 	if err != nil {
 		return tools.NewTextErrorResponse(err.Error()), nil
 	}
@@ -250,29 +294,24 @@ func NewMcpTool(name string, tool mcp.Tool, permissions permission.Service, mcpC
 	}
 }
 
-// shouldIncludeTool determines if a tool should be included based on allow/deny lists
+// shouldIncludeTool determines if a tool should be included based on allow/deny
+// lists: it must match an allowed pattern, if there are any, and no denied one
 func shouldIncludeTool(toolName string, allowedTools []string, deniedTools []string) bool {
-	// If allowedTools is specified and not empty, only include tools in the allowlist
-	if len(allowedTools) > 0 {
-		for _, allowed := range allowedTools {
-			if allowed == toolName {
-				return true
-			}
-		}
-		return false // Tool not in allowlist
+	if len(allowedTools) > 0 && !matchesTool(toolName, allowedTools) {
+		return false
 	}
+	return !matchesTool(toolName, deniedTools)
+}
 
-	// If deniedTools is specified and not empty, exclude tools in the denylist
-	if len(deniedTools) > 0 {
-		for _, denied := range deniedTools {
-			if denied == toolName {
-				return false // Tool is in denylist
-			}
+// matchesTool reports whether toolName matches one of the path.Match patterns.
+// Patterns are checked when the config is validated.
+func matchesTool(toolName string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, toolName); ok {
+			return true
 		}
 	}
-
-	// Default: include the tool (no filtering or tool not in denylist)
-	return true
+	return false
 }
 
 func getTools(ctx context.Context, name string, m config.MCPServer, permissions permission.Service, manager *MCPClientManager) []tools.BaseTool {
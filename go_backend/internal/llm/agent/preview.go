@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"mix/internal/llm/models"
+	"mix/internal/llm/reminder"
+	"mix/internal/llm/tools"
+	"mix/internal/pin"
+)
+
+// PromptPreview is what the next turn of a session would send the model.
+type PromptPreview struct {
+	Model        models.Model
+	SystemPrompt string
+	// Tools names the tools offered to the model
+	Tools []string
+	// Reminders are appended to the user message in this order
+	Reminders []reminder.Reminder
+	// UserMessage is the content with its reminders, as it would be stored
+	UserMessage string
+	// PinnedContext is sent ahead of the conversation, empty without pins
+	PinnedContext string
+}
+
+// Preview composes the turn the way processGeneration and
+// streamAndHandleEvents do, from the session's current settings, without
+// storing or sending anything.
+func (a *agent) Preview(ctx context.Context, state tools.RequestState, content string) (PromptPreview, error) {
+	sess, err := a.sessions.Get(ctx, state.SessionID)
+	if err != nil {
+		return PromptPreview{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	state.WorkingDirectory = sess.WorkingDirectory
+
+	model, err := a.sessionModel(ctx, sess.ID)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+	settings, err := a.sessions.AgentSettings(ctx, sess.ID)
+	if err != nil {
+		return PromptPreview{}, fmt.Errorf("failed to load session agent settings: %w", err)
+	}
+	memories, err := a.memoryPrompt(ctx, sess)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+	systemPrompt, err := sessionSystemPrompt(tools.WithRequestState(ctx, state), a.agentName, model, map[string]string{
+		"session_id":      sess.ID,
+		"session_workdir": sess.WorkingDirectory,
+	}, settings, memories)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+
+	msgs, _, err := a.history(ctx, sess.ID)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+	disabledTools, err := a.sessions.DisabledTools(ctx, sess.ID)
+	if err != nil {
+		return PromptPreview{}, fmt.Errorf("failed to load disabled tools: %w", err)
+	}
+	disabled := make(map[string]bool, len(disabledTools))
+	for _, name := range disabledTools {
+		disabled[name] = true
+	}
+	availableTools := filterDisabledTools(a.Tools(), disabled)
+	if state.PlanMode {
+		availableTools = filterToolsForPlanMode(availableTools)
+	}
+	availableTools = a.compactSessionTools(sess.ID, availableTools, msgs)
+	toolNames := make([]string, len(availableTools))
+	for i, tool := range availableTools {
+		toolNames[i] = tool.Info().Name
+	}
+
+	reminders, err := a.reminders(ctx, state, sess)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+	pinned, err := a.pinnedContext(ctx, sess)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+	return PromptPreview{
+		Model:         model,
+		SystemPrompt:  systemPrompt,
+		Tools:         toolNames,
+		Reminders:     reminders,
+		UserMessage:   reminder.Inject(content, reminders),
+		PinnedContext: pin.Block(pinned),
+	}, nil
+}
@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/models"
+	"mix/internal/llm/provider"
+	"mix/internal/llm/tools"
+	"mix/internal/message"
+)
+
+// Problems reported by ProbeProviders
+const (
+	ProbeInvalidCredentials = "invalid_credentials"
+	ProbeAccessDenied       = "access_denied"
+	ProbeModelUnavailable   = "model_unavailable"
+	ProbeRateLimited        = "rate_limited"
+	ProbeUnreachable        = "unreachable"
+	ProbeMisconfigured      = "misconfigured"
+	ProbeFailed             = "failed"
+)
+
+const (
+	probeTimeout = 30 * time.Second
+	// probeMaxTokens keeps the probe response, and its cost, negligible
+	probeMaxTokens = 16
+)
+
+// probeAgent builds probe providers without agent options such as extended
+// thinking, which needs a larger max tokens than a probe asks for
+const probeAgent config.AgentName = "probe"
+
+// ProbeResult is the outcome of a minimal request to one configured model.
+type ProbeResult struct {
+	Provider models.ModelProvider
+	Model    models.ModelID
+	// Agents are the agents using the model, as primary or fallback
+	Agents   []config.AgentName
+	OK       bool
+	Problem  string
+	Error    string
+	Hint     string
+	Duration time.Duration
+}
+
+// ProbeProviders sends a one-word streaming request to every model the
+// configured agents use, so wrong keys, disabled organizations and missing
+// model access show up before the first user message. Models are probed
+// concurrently; results are sorted by provider and model.
+func ProbeProviders(ctx context.Context) []ProbeResult {
+	cfg := config.Get()
+	agentsByModel := make(map[models.ModelID][]config.AgentName)
+	for name, agentCfg := range cfg.Agents {
+		for _, modelID := range append([]models.ModelID{agentCfg.Model}, agentCfg.Fallback...) {
+			agentsByModel[modelID] = append(agentsByModel[modelID], name)
+		}
+	}
+
+	results := make([]ProbeResult, 0, len(agentsByModel))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for modelID, agents := range agentsByModel {
+		sort.Slice(agents, func(i, j int) bool { return agents[i] < agents[j] })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := probeModel(ctx, modelID)
+			result.Agents = agents
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Provider != results[j].Provider {
+			return results[i].Provider < results[j].Provider
+		}
+		return results[i].Model < results[j].Model
+	})
+	return results
+}
+
+func probeModel(ctx context.Context, modelID models.ModelID) ProbeResult {
+	result := ProbeResult{Model: modelID}
+	model, ok := models.Lookup(modelID)
+	if !ok {
+		result.Problem = ProbeMisconfigured
+		result.Error = fmt.Sprintf("model %s not supported", modelID)
+		result.Hint = "Pick a model from the supported model list"
+		return result
+	}
+	result.Provider = model.Provider
+
+	modelProvider, err := createModelProvider(probeAgent, config.Agent{}, model, probeMaxTokens)
+	if err != nil {
+		result.Problem = ProbeMisconfigured
+		result.Error = err.Error()
+		result.Hint = fmt.Sprintf("Enable and configure providers.%s", model.Provider)
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+	err = streamProbe(ctx, modelProvider)
+	result.Duration = time.Since(start)
+	if err == nil {
+		result.OK = true
+		return result
+	}
+	result.Error = err.Error()
+	result.Problem, result.Hint = classifyProbeError(model, err)
+	return result
+}
+
+// streamProbe uses the streaming path the agent uses, which reports
+// authentication failures as errors rather than as response text.
+func streamProbe(ctx context.Context, modelProvider provider.Provider) error {
+	messages := []message.Message{
+		{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: "Reply with OK."}},
+		},
+	}
+	for event := range modelProvider.StreamResponse(ctx, tools.RequestState{}, messages, nil) {
+		switch event.Type {
+		case provider.EventError:
+			return event.Error
+		case provider.EventComplete:
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.New("stream ended without a response")
+}
+
+// classifyProbeError maps a provider error to a problem and a hint. Provider
+// SDKs format errors differently, so this matches on status codes and the
+// usual wording rather than on error types.
+func classifyProbeError(model models.Model, err error) (string, string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ProbeUnreachable, fmt.Sprintf("No response within %s; check the network and any proxy or egress policy", probeTimeout)
+	}
+	text := strings.ToLower(err.Error())
+	containsAny := func(needles ...string) bool {
+		for _, needle := range needles {
+			if strings.Contains(text, needle) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case containsAny("401", "unauthorized", "authentication", "invalid api key", "invalid x-api-key", "api key not valid", "incorrect api key"):
+		return ProbeInvalidCredentials, fmt.Sprintf("Check the %s API key or login; it was rejected", model.Provider)
+	case containsAny("403", "forbidden", "permission", "disabled", "billing"):
+		return ProbeAccessDenied, fmt.Sprintf("The %s credentials are valid but the account, organization or project may not use the API; check that it is enabled and billing is set up", model.Provider)
+	case containsAny("404", "not_found", "not found", "does not exist", "model_not_available"):
+		return ProbeModelUnavailable, fmt.Sprintf("The account has no access to %s (%s); request access or configure another model", model.Name, model.APIModel)
+	case containsAny("429", "rate limit", "rate_limit", "quota", "retries"):
+		return ProbeRateLimited, fmt.Sprintf("The %s credentials work, but the account is rate limited or out of quota", model.Provider)
+	case containsAny("no such host", "connection refused", "dial tcp", "timeout"):
+		return ProbeUnreachable, fmt.Sprintf("Could not reach %s; check the network and any proxy or egress policy", model.Provider)
+	}
+	return ProbeFailed, ""
+}
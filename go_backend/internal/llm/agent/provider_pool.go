@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/models"
+	"mix/internal/llm/provider"
+	"mix/internal/logging"
+)
+
+// providerIdleTimeout is how long a pooled provider may go unused before it
+// is evicted.
+const providerIdleTimeout = 15 * time.Minute
+
+// providerPool holds the session providers by what they are built from: the
+// agent, its models and each model's system prompt. Building a provider reads
+// the credential storage, so sessions reuse pooled ones, including each
+// other's when their prompts match, and only rebuild when that changes.
+type providerPool struct {
+	mu      sync.Mutex
+	entries map[string]*pooledProvider
+	now     func() time.Time
+}
+
+type pooledProvider struct {
+	provider provider.Provider
+	lastUsed time.Time
+}
+
+func newProviderPool() *providerPool {
+	return &providerPool{entries: map[string]*pooledProvider{}, now: time.Now}
+}
+
+// get returns the provider pooled under key.
+func (p *providerPool) get(key string) (provider.Provider, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry.lastUsed = p.now()
+	return entry.provider, true
+}
+
+// getOrCreate returns the provider pooled under key, creating and pooling it
+// when there is none. Creation runs outside the lock, so two sessions missing
+// the same key at once may both create one; the first pooled is kept.
+func (p *providerPool) getOrCreate(key string, create func() (provider.Provider, error)) (provider.Provider, error) {
+	if cached, ok := p.get(key); ok {
+		return cached, nil
+	}
+	created, err := create()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[key]; ok {
+		entry.lastUsed = p.now()
+		return entry.provider, nil
+	}
+	p.entries[key] = &pooledProvider{provider: created, lastUsed: p.now()}
+	return created, nil
+}
+
+// evictIdle drops the providers unused for longer than idle and returns how
+// many it dropped.
+func (p *providerPool) evictIdle(idle time.Duration) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	evicted := 0
+	for key, entry := range p.entries {
+		if p.now().Sub(entry.lastUsed) > idle {
+			delete(p.entries, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// clear drops every provider, e.g. after the credentials changed.
+func (p *providerPool) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.entries)
+}
+
+// evictIdleLoop evicts idle providers until ctx is done.
+func (p *providerPool) evictIdleLoop(ctx context.Context) {
+	ticker := time.NewTicker(providerIdleTimeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if evicted := p.evictIdle(providerIdleTimeout); evicted > 0 {
+				logging.Debug("Evicted idle session providers", "count", evicted)
+			}
+		}
+	}
+}
+
+// providerKey identifies a session provider by the agent, the models of its
+// chain and the primary model's max tokens, and the hash of each model's
+// system prompt. The rest of the agent's config only changes with a reload,
+// which clears the pool.
+func providerKey(agentName config.AgentName, agentConfig config.Agent, systemPrompts map[models.ModelID]string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\n", agentName, agentConfig.MaxTokens)
+	for _, modelID := range append([]models.ModelID{agentConfig.Model}, agentConfig.Fallback...) {
+		fmt.Fprintf(h, "%s\x00%s\n", modelID, systemPrompts[modelID])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
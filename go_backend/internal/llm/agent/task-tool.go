@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"mix/internal/audit"
 	"mix/internal/llm/tools"
 	"mix/internal/message"
 	"mix/internal/permission"
@@ -15,6 +16,7 @@ type taskTool struct {
 	sessions    session.Service
 	messages    message.Service
 	permissions permission.Service
+	audits      audit.Service
 }
 
 const (
@@ -64,18 +66,18 @@ func (b *taskTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResp
 		return tools.NewTextErrorResponse("subagent_type is required"), nil
 	}
 
-	sessionID, messageID := tools.GetContextValues(ctx)
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return tools.ToolResponse{}, fmt.Errorf("session_id and message_id are required")
 	}
 
-	agent, err := NewAgent("sub", b.sessions, b.messages, TaskAgentTools(b.permissions))
+	agent, err := NewAgent("sub", b.sessions, b.messages, b.audits, nil, nil, nil, TaskAgentTools(b.permissions))
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error creating agent: %s", err)
 	}
-	defer agent.Shutdown()
+	defer agent.Shutdown(ctx)
 
-	session, err := b.sessions.Create(ctx, "New Agent Session", ctx.Value(tools.WorkingDirectoryContextKey).(string))
+	session, err := b.sessions.Create(ctx, "New Agent Session", call.State.WorkingDirectory)
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error creating session: %s", err)
 	}
@@ -147,10 +149,12 @@ func NewTaskTool(
 	Sessions session.Service,
 	Messages message.Service,
 	Permissions permission.Service,
+	Audits audit.Service,
 ) tools.BaseTool {
 	return &taskTool{
 		sessions:    Sessions,
 		messages:    Messages,
 		permissions: Permissions,
+		audits:      Audits,
 	}
 }
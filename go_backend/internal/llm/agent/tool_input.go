@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// toolInputPublishInterval is how often streamed tool arguments are
+	// published; deltas arriving in between are sent together
+	toolInputPublishInterval = 250 * time.Millisecond
+	// toolInputSaveInterval is how often the assistant message is saved while
+	// tool arguments stream. The tool call's stop event saves it in full.
+	toolInputSaveInterval = time.Second
+)
+
+// ToolInputDelta is arguments a tool call received since the last delta, so
+// UIs can show the call being typed out, e.g. the diff of an edit. Delta
+// continues the call's partial JSON input.
+type ToolInputDelta struct {
+	MessageID  string
+	ToolCallID string
+	Name       string
+	Delta      string
+}
+
+// toolInputStream coalesces the tool argument deltas of one generation.
+type toolInputStream struct {
+	pending     map[string]string
+	order       []string
+	lastPublish time.Time
+	lastSave    time.Time
+}
+
+func newToolInputStream() *toolInputStream {
+	now := time.Now()
+	return &toolInputStream{
+		pending:     make(map[string]string),
+		lastPublish: now,
+		lastSave:    now,
+	}
+}
+
+func (s *toolInputStream) add(toolCallID, delta string) {
+	if _, ok := s.pending[toolCallID]; !ok {
+		s.order = append(s.order, toolCallID)
+	}
+	s.pending[toolCallID] += delta
+}
+
+// publishDue reports whether pending deltas should be published, resetting
+// the interval.
+func (s *toolInputStream) publishDue() bool {
+	if len(s.order) == 0 || time.Since(s.lastPublish) < toolInputPublishInterval {
+		return false
+	}
+	s.lastPublish = time.Now()
+	return true
+}
+
+// saveDue reports whether the message should be saved, resetting the interval.
+func (s *toolInputStream) saveDue() bool {
+	if time.Since(s.lastSave) < toolInputSaveInterval {
+		return false
+	}
+	s.lastSave = time.Now()
+	return true
+}
+
+// take returns and forgets the pending deltas of every tool call, in the
+// order the calls started streaming.
+func (s *toolInputStream) take() []ToolInputDelta {
+	deltas := make([]ToolInputDelta, 0, len(s.order))
+	for _, id := range s.order {
+		deltas = append(deltas, ToolInputDelta{ToolCallID: id, Delta: s.pending[id]})
+	}
+	clear(s.pending)
+	s.order = s.order[:0]
+	return deltas
+}
+
+// takeCall returns and forgets the pending delta of one tool call.
+func (s *toolInputStream) takeCall(toolCallID string) (ToolInputDelta, bool) {
+	delta, ok := s.pending[toolCallID]
+	if !ok {
+		return ToolInputDelta{}, false
+	}
+	delete(s.pending, toolCallID)
+	s.order = slices.DeleteFunc(s.order, func(id string) bool { return id == toolCallID })
+	return ToolInputDelta{ToolCallID: toolCallID, Delta: delta}, true
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"time"
 
+	"mix/internal/audit"
+	"mix/internal/config"
 	"mix/internal/history"
 	"mix/internal/llm/tools"
+	"mix/internal/logging"
+	"mix/internal/memory"
 	"mix/internal/message"
 	"mix/internal/permission"
+	"mix/internal/question"
 	"mix/internal/session"
 )
 
@@ -16,16 +21,20 @@ func CoderAgentTools(
 	sessions session.Service,
 	messages message.Service,
 	history history.Service,
+	audits audit.Service,
+	memories memory.Service,
+	questions question.Service,
 	manager *MCPClientManager,
 ) []tools.BaseTool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	otherTools := GetMcpTools(ctx, permissions, manager)
 	bashTool := tools.NewBashTool(permissions)
-	return append(
+	agentTools := append(
 		[]tools.BaseTool{
 			bashTool,
 			tools.NewEditTool(permissions, history),
+			tools.NewApplyPatchTool(permissions, history),
 			tools.NewFetchTool(permissions),
 			tools.NewGlobTool(),
 			tools.NewGrepTool(permissions),
@@ -34,12 +43,54 @@ func CoderAgentTools(
 			tools.NewWriteTool(permissions, history),
 			tools.NewPythonExecutionTool(permissions),
 			tools.NewTodoWriteTool(),
+			tools.NewMemoryWriteTool(memories),
 			tools.NewExitPlanModeTool(),
+			tools.NewAskUserTool(questions),
 			tools.NewMediaShowcaseTool(),
+			tools.NewViewArtifactTool(),
+			tools.NewGrammarCheckTool(),
+			tools.NewGitStatusTool(),
+			tools.NewGitDiffTool(),
+			tools.NewGitLogTool(),
+			tools.NewGitCommitTool(permissions),
+			tools.NewGitBranchTool(permissions),
+			tools.NewGenerateCaptionsTool(permissions),
+			tools.NewEditCaptionsTool(permissions),
+			tools.NewBurnSubtitlesTool(permissions),
 			// tools.NewNotesTool(permissions, bashTool),
-			NewTaskTool(sessions, messages, permissions),
+			NewTaskTool(sessions, messages, permissions, audits),
 		}, otherTools...,
 	)
+	return addTools(agentTools, CommandTools(permissions))
+}
+
+// CommandTools returns the tools running the configured executables.
+func CommandTools(permissions permission.Service) []tools.BaseTool {
+	var commandTools []tools.BaseTool
+	for _, cfg := range config.Get().CommandTools {
+		commandTools = append(commandTools, tools.NewCommandTool(cfg, permissions))
+	}
+	return commandTools
+}
+
+// addTools appends added to agentTools, leaving out with a warning the tools
+// named like one already there, so a command tool can't replace a built-in
+// or MCP tool.
+func addTools(agentTools, added []tools.BaseTool) []tools.BaseTool {
+	names := make(map[string]bool, len(agentTools))
+	for _, tool := range agentTools {
+		names[tool.Info().Name] = true
+	}
+	for _, tool := range added {
+		name := tool.Info().Name
+		if names[name] {
+			logging.Warn("Leaving out tool named like another tool", "tool", name)
+			continue
+		}
+		names[name] = true
+		agentTools = append(agentTools, tool)
+	}
+	return agentTools
 }
 
 func TaskAgentTools(permissions permission.Service) []tools.BaseTool {
@@ -48,5 +99,9 @@ func TaskAgentTools(permissions permission.Service) []tools.BaseTool {
 		tools.NewGrepTool(permissions),
 		tools.NewLsTool(),
 		tools.NewViewTool(permissions),
+		tools.NewViewArtifactTool(),
+		tools.NewGitStatusTool(),
+		tools.NewGitDiffTool(),
+		tools.NewGitLogTool(),
 	}
 }
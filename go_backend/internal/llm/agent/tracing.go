@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+
+	"mix/internal/llm/models"
+	"mix/internal/llm/provider"
+	"mix/internal/message"
+	"mix/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes follow the OpenTelemetry GenAI conventions where they have
+// one; the rest are prefixed with mix.
+const (
+	attrSessionID     = attribute.Key("mix.session.id")
+	attrAgentName     = attribute.Key("mix.agent.name")
+	attrPlanMode      = attribute.Key("mix.plan_mode")
+	attrOutcome       = attribute.Key("mix.outcome")
+	attrProvider      = attribute.Key("gen_ai.system")
+	attrRequestModel  = attribute.Key("gen_ai.request.model")
+	attrResponseModel = attribute.Key("gen_ai.response.model")
+	attrResponseID    = attribute.Key("gen_ai.response.id")
+	attrFinishReasons = attribute.Key("gen_ai.response.finish_reasons")
+	attrInputTokens   = attribute.Key("gen_ai.usage.input_tokens")
+	attrOutputTokens  = attribute.Key("gen_ai.usage.output_tokens")
+	attrCacheRead     = attribute.Key("mix.usage.cache_read_tokens")
+	attrCacheCreation = attribute.Key("mix.usage.cache_creation_tokens")
+	attrCost          = attribute.Key("mix.cost")
+	attrToolCalls     = attribute.Key("mix.tool_calls")
+	attrToolName      = attribute.Key("gen_ai.tool.name")
+	attrToolCallID    = attribute.Key("gen_ai.tool.call.id")
+	attrToolError     = attribute.Key("mix.tool.is_error")
+)
+
+// startRunSpan starts the span of an agent run, the root of its turn's spans.
+func (a *agent) startRunSpan(ctx context.Context, sessionID string, planMode bool) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "agent.run", trace.WithAttributes(
+		attrSessionID.String(sessionID),
+		attrAgentName.String(string(a.agentName)),
+		attrPlanMode.Bool(planMode),
+	))
+}
+
+// startStepSpan starts the span of one model request of a turn and the tool
+// calls it makes.
+func startStepSpan(ctx context.Context, sessionID string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "agent.step", trace.WithAttributes(attrSessionID.String(sessionID)))
+}
+
+// endStepSpan ends a step's span with what the model answered.
+func endStepSpan(span trace.Span, msg message.Message, err error) {
+	span.SetAttributes(attrToolCalls.Int(len(msg.ToolCalls())))
+	if reason := msg.FinishReason(); reason != "" {
+		span.SetAttributes(attrFinishReasons.StringSlice([]string{string(reason)}))
+	}
+	tracing.Fail(span, err)
+	span.End()
+}
+
+// startChatSpan starts the span of a streamed model request.
+func startChatSpan(ctx context.Context, model models.Model) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "chat "+model.APIModel,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrProvider.String(string(model.Provider)),
+			attrRequestModel.String(string(model.ID)),
+		),
+	)
+}
+
+// recordChatResponse adds the usage and answering model of a completed
+// response to its request's span.
+func recordChatResponse(span trace.Span, model models.Model, response *provider.ProviderResponse) {
+	if response == nil {
+		return
+	}
+	if response.Model.ID != "" {
+		model = response.Model
+	}
+	span.SetAttributes(
+		attrResponseModel.String(string(model.ID)),
+		attrFinishReasons.StringSlice([]string{string(response.FinishReason)}),
+		attrInputTokens.Int64(response.Usage.InputTokens),
+		attrOutputTokens.Int64(response.Usage.OutputTokens),
+		attrCacheRead.Int64(response.Usage.CacheReadTokens),
+		attrCacheCreation.Int64(response.Usage.CacheCreationTokens),
+		attrCost.Float64(usageCost(model, response.Usage)),
+	)
+	if response.RequestID != "" {
+		span.SetAttributes(attrResponseID.String(response.RequestID))
+	}
+}
+
+// startToolSpan starts the span of a tool call.
+func startToolSpan(ctx context.Context, tc message.ToolCall) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "execute_tool "+tc.Name, trace.WithAttributes(
+		attrToolName.String(tc.Name),
+		attrToolCallID.String(tc.ID),
+	))
+}
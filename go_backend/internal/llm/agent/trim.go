@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"mix/internal/message"
+)
+
+// WarningContextTrimmed is the code of the warning sent when older messages
+// were left out of a request the provider rejected as too long
+const WarningContextTrimmed = "context_trimmed"
+
+// Warning tells clients about something that changed a turn without failing
+// it.
+type Warning struct {
+	Code    string
+	Message string
+	// DroppedMessages counts the messages left out of the request
+	DroppedMessages int
+}
+
+// trimHistory drops about the oldest half of the messages before the current
+// turn so a request rejected as too long may fit. The summary message, when
+// keepFirst, and the current turn, from the last user message on, are kept.
+// Trimming stops at a user message so no tool result loses its call. It
+// returns the trimmed history and the number of messages dropped, zero when
+// there is nothing left to drop.
+func trimHistory(msgs []message.Message, keepFirst bool) ([]message.Message, int) {
+	start := 0
+	if keepFirst {
+		start = 1
+	}
+	turnStart := -1
+	for i := len(msgs) - 1; i >= start; i-- {
+		if msgs[i].Role == message.User {
+			turnStart = i
+			break
+		}
+	}
+	if turnStart <= start {
+		return msgs, 0
+	}
+
+	cut := turnStart
+	for i := start + (turnStart-start+1)/2; i < turnStart; i++ {
+		if msgs[i].Role == message.User {
+			cut = i
+			break
+		}
+	}
+
+	trimmed := make([]message.Message, 0, len(msgs)-(cut-start))
+	trimmed = append(trimmed, msgs[:start]...)
+	trimmed = append(trimmed, msgs[cut:]...)
+	return trimmed, cut - start
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"strconv"
+	"testing"
+
+	"mix/internal/message"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rolesHistory returns messages with IDs of their index and the roles of roles:
+// u for user, a for assistant and t for tool results.
+func rolesHistory(roles string) []message.Message {
+	byLetter := map[rune]message.MessageRole{'u': message.User, 'a': message.Assistant, 't': message.Tool}
+	msgs := make([]message.Message, 0, len(roles))
+	for i, letter := range roles {
+		msgs = append(msgs, message.Message{ID: strconv.Itoa(i), Role: byLetter[letter]})
+	}
+	return msgs
+}
+
+func messageIDs(msgs []message.Message) []string {
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestTrimHistory(t *testing.T) {
+	tests := []struct {
+		name      string
+		roles     string
+		keepFirst bool
+		expected  []string
+		dropped   int
+	}{
+		{
+			name:     "oldest half up to a user message",
+			roles:    "uatuatuatu",
+			expected: []string{"6", "7", "8", "9"},
+			dropped:  6,
+		},
+		{
+			name:      "summary is kept",
+			roles:     "uatuatuatu",
+			keepFirst: true,
+			expected:  []string{"0", "6", "7", "8", "9"},
+			dropped:   5,
+		},
+		{
+			name:     "no user message in the newer half drops up to the current turn",
+			roles:    "uatatau",
+			expected: []string{"6"},
+			dropped:  6,
+		},
+		{
+			name:     "current turn with tool calls is kept whole",
+			roles:    "uauatat",
+			expected: []string{"2", "3", "4", "5", "6"},
+			dropped:  2,
+		},
+		{
+			name:     "only the current turn",
+			roles:    "uat",
+			expected: []string{"0", "1", "2"},
+		},
+		{
+			name:      "only the summary and the current turn",
+			roles:     "uuat",
+			keepFirst: true,
+			expected:  []string{"0", "1", "2", "3"},
+		},
+		{
+			name:      "no user message after the summary",
+			roles:     "uat",
+			keepFirst: true,
+			expected:  []string{"0", "1", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trimmed, dropped := trimHistory(rolesHistory(tt.roles), tt.keepFirst)
+			assert.Equal(t, tt.expected, messageIDs(trimmed))
+			assert.Equal(t, tt.dropped, dropped)
+		})
+	}
+}
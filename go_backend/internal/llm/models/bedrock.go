@@ -0,0 +1,42 @@
+package models
+
+const (
+	ProviderBedrock ModelProvider = "bedrock"
+
+	// Models
+	BedrockClaude35Haiku  ModelID = "bedrock.claude-3.5-haiku"
+	BedrockClaude37Sonnet ModelID = "bedrock.claude-3.7-sonnet"
+	BedrockClaude4Sonnet  ModelID = "bedrock.claude-4-sonnet"
+	BedrockClaude4Opus    ModelID = "bedrock.claude-4-opus"
+)
+
+// bedrockClaudeModels maps Bedrock model IDs to the Anthropic model they serve
+// and its Bedrock model ID. The cross-region inference profile prefix (us.,
+// eu., apac.) is added by the provider based on the configured region.
+//
+// https://docs.aws.amazon.com/bedrock/latest/userguide/models-supported.html
+var bedrockClaudeModels = map[ModelID]struct {
+	anthropic ModelID
+	apiModel  string
+}{
+	BedrockClaude35Haiku:  {Claude35Haiku, "anthropic.claude-3-5-haiku-20241022-v1:0"},
+	BedrockClaude37Sonnet: {Claude37Sonnet, "anthropic.claude-3-7-sonnet-20250219-v1:0"},
+	BedrockClaude4Sonnet:  {Claude4Sonnet, "anthropic.claude-sonnet-4-20250514-v1:0"},
+	BedrockClaude4Opus:    {Claude4Opus, "anthropic.claude-opus-4-20250514-v1:0"},
+}
+
+// BedrockModels mirror their Anthropic counterparts with Bedrock model IDs.
+var BedrockModels = bedrockModels()
+
+func bedrockModels() map[ModelID]Model {
+	result := make(map[ModelID]Model, len(bedrockClaudeModels))
+	for id, mapping := range bedrockClaudeModels {
+		model := AnthropicModels[mapping.anthropic]
+		model.ID = id
+		model.Name = "Bedrock: " + model.Name
+		model.Provider = ProviderBedrock
+		model.APIModel = mapping.apiModel
+		result[id] = model
+	}
+	return result
+}
@@ -0,0 +1,387 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"mix/internal/logging"
+)
+
+// Models discovered from the providers' model list endpoints are persisted to
+// CatalogFile in the data directory, so models released after this binary are
+// usable without an update. The list endpoints don't report prices, so
+// discovered models are priced from catalogPricing; models it has no entry
+// for are left out rather than counted as free.
+const CatalogFile = "models.json"
+
+const (
+	anthropicModelsURL = "https://api.anthropic.com/v1/models"
+	openAIModelsURL    = "https://api.openai.com/v1/models"
+	geminiModelsURL    = "https://generativelanguage.googleapis.com/v1beta/models"
+)
+
+// CatalogProviders are the providers whose model lists can be fetched.
+var CatalogProviders = []ModelProvider{ProviderAnthropic, ProviderOpenAI, ProviderGemini}
+
+var catalogFetchers = map[ModelProvider]func(ctx context.Context, apiKey string) ([]Model, error){
+	ProviderAnthropic: fetchAnthropicModels,
+	ProviderOpenAI:    fetchOpenAIModels,
+	ProviderGemini:    fetchGeminiModels,
+}
+
+// Catalog is the set of discovered models persisted in CatalogFile.
+type Catalog struct {
+	RefreshedAt time.Time `json:"refreshedAt"`
+	Models      []Model   `json:"models"`
+}
+
+// catalogRefreshedAt is when the registered catalog was fetched, guarded by
+// supportedMu
+var catalogRefreshedAt time.Time
+
+// CatalogRefreshedAt returns when the registered catalog was fetched, or the
+// zero time if there is none.
+func CatalogRefreshedAt() time.Time {
+	supportedMu.RLock()
+	defer supportedMu.RUnlock()
+	return catalogRefreshedAt
+}
+
+// LoadCatalog registers the models of the catalog persisted at path. A
+// missing file is an empty catalog.
+func LoadCatalog(path string) (Catalog, error) {
+	catalog, err := readCatalog(path)
+	if err != nil {
+		return catalog, fmt.Errorf("failed to read model catalog %s: %w", path, err)
+	}
+	registerCatalog(catalog)
+	return catalog, nil
+}
+
+// RefreshCatalog fetches the model list of every provider in apiKeys,
+// registers the discovered models and persists the catalog to path. Providers
+// whose list can't be fetched keep their models from the previous catalog and
+// are returned in failed; if none could be fetched, the previous catalog is
+// returned unchanged. An error is returned only if the catalog can't be saved.
+func RefreshCatalog(ctx context.Context, path string, apiKeys map[ModelProvider]string) (catalog Catalog, failed map[ModelProvider]error, err error) {
+	previous, err := readCatalog(path)
+	if err != nil {
+		logging.Warn("Ignoring unreadable model catalog", "path", path, "error", err)
+	}
+
+	type result struct {
+		provider ModelProvider
+		models   []Model
+		err      error
+	}
+	results := make(chan result, len(apiKeys))
+	for provider, apiKey := range apiKeys {
+		fetch, ok := catalogFetchers[provider]
+		if !ok {
+			results <- result{provider: provider, err: fmt.Errorf("%s doesn't list its models", provider)}
+			continue
+		}
+		go func() {
+			models, err := fetch(ctx, apiKey)
+			results <- result{provider, models, err}
+		}()
+	}
+
+	fetched := make(map[ModelProvider][]Model)
+	failed = make(map[ModelProvider]error)
+	for range apiKeys {
+		r := <-results
+		if r.err != nil {
+			failed[r.provider] = r.err
+			continue
+		}
+		fetched[r.provider] = r.models
+	}
+	// Keep the refresh time so startup retries while no provider answers
+	if len(fetched) == 0 {
+		return previous, failed, nil
+	}
+
+	catalog = Catalog{RefreshedAt: time.Now().UTC()}
+	for _, model := range previous.Models {
+		if _, ok := fetched[model.Provider]; !ok {
+			catalog.Models = append(catalog.Models, model)
+		}
+	}
+	for _, models := range fetched {
+		catalog.Models = append(catalog.Models, models...)
+	}
+	slices.SortFunc(catalog.Models, func(a, b Model) int { return cmp.Compare(a.ID, b.ID) })
+
+	if err := saveCatalog(path, catalog); err != nil {
+		return catalog, failed, err
+	}
+	registerCatalog(catalog)
+	logging.Info("Refreshed model catalog", "models", len(catalog.Models), "failed", len(failed))
+	return catalog, failed, nil
+}
+
+func readCatalog(path string) (Catalog, error) {
+	var catalog Catalog
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return catalog, nil
+	}
+	if err != nil {
+		return catalog, err
+	}
+	return catalog, json.Unmarshal(data, &catalog)
+}
+
+// saveCatalog writes the catalog through a temporary file so an interrupted
+// write doesn't leave a truncated catalog behind.
+func saveCatalog(path string, catalog Catalog) error {
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save model catalog: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save model catalog: %w", err)
+	}
+	return nil
+}
+
+// registerCatalog adds the catalog's models to the supported models. Built-in
+// models are never replaced, so their hand-checked prices and limits win.
+func registerCatalog(catalog Catalog) {
+	supportedMu.Lock()
+	defer supportedMu.Unlock()
+	for _, model := range catalog.Models {
+		if isBuiltin(model) {
+			continue
+		}
+		supportedModels[model.ID] = model
+	}
+	catalogRefreshedAt = catalog.RefreshedAt
+}
+
+// isBuiltin reports whether model has the ID of a built-in model, or is one
+// under another ID.
+func isBuiltin(model Model) bool {
+	if _, ok := builtinModels[model.ID]; ok {
+		return true
+	}
+	for _, builtin := range builtinModels {
+		if builtin.Provider == model.Provider && builtin.APIModel == model.APIModel {
+			return true
+		}
+	}
+	return false
+}
+
+// catalogPricing prices discovered models by the longest matching API model
+// prefix. Costs are USD per million tokens; for Anthropic, CostPer1MInCached
+// is the cache write price and CostPer1MOutCached the cache read price, as in
+// AnthropicModels.
+var catalogPricing = map[string]Model{
+	// Anthropic
+	"claude-opus-4":     {CostPer1MIn: 15, CostPer1MInCached: 18.75, CostPer1MOutCached: 1.50, CostPer1MOut: 75, ContextWindow: 200_000, DefaultMaxTokens: 32000, CanReason: true, SupportsAttachments: true},
+	"claude-opus-4-5":   {CostPer1MIn: 5, CostPer1MInCached: 6.25, CostPer1MOutCached: 0.50, CostPer1MOut: 25, ContextWindow: 200_000, DefaultMaxTokens: 64000, CanReason: true, SupportsAttachments: true},
+	"claude-sonnet-4":   {CostPer1MIn: 3, CostPer1MInCached: 3.75, CostPer1MOutCached: 0.30, CostPer1MOut: 15, ContextWindow: 200_000, DefaultMaxTokens: 64000, CanReason: true, SupportsAttachments: true},
+	"claude-haiku-4":    {CostPer1MIn: 1, CostPer1MInCached: 1.25, CostPer1MOutCached: 0.10, CostPer1MOut: 5, ContextWindow: 200_000, DefaultMaxTokens: 64000, CanReason: true, SupportsAttachments: true},
+	"claude-3-7-sonnet": {CostPer1MIn: 3, CostPer1MInCached: 3.75, CostPer1MOutCached: 0.30, CostPer1MOut: 15, ContextWindow: 200_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"claude-3-5-sonnet": {CostPer1MIn: 3, CostPer1MInCached: 3.75, CostPer1MOutCached: 0.30, CostPer1MOut: 15, ContextWindow: 200_000, DefaultMaxTokens: 5000, SupportsAttachments: true},
+	"claude-3-5-haiku":  {CostPer1MIn: 0.80, CostPer1MInCached: 1, CostPer1MOutCached: 0.08, CostPer1MOut: 4, ContextWindow: 200_000, DefaultMaxTokens: 4096, SupportsAttachments: true},
+	"claude-3-haiku":    {CostPer1MIn: 0.25, CostPer1MInCached: 0.30, CostPer1MOutCached: 0.03, CostPer1MOut: 1.25, ContextWindow: 200_000, DefaultMaxTokens: 4096, SupportsAttachments: true},
+	"claude-3-opus":     {CostPer1MIn: 15, CostPer1MInCached: 18.75, CostPer1MOutCached: 1.50, CostPer1MOut: 75, ContextWindow: 200_000, DefaultMaxTokens: 4096, SupportsAttachments: true},
+
+	// OpenAI
+	"gpt-5":        {CostPer1MIn: 1.25, CostPer1MInCached: 0.125, CostPer1MOut: 10, ContextWindow: 400_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"gpt-5-mini":   {CostPer1MIn: 0.25, CostPer1MInCached: 0.025, CostPer1MOut: 2, ContextWindow: 400_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"gpt-5-pro":    {CostPer1MIn: 15, CostPer1MOut: 120, ContextWindow: 400_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"gpt-5-nano":   {CostPer1MIn: 0.05, CostPer1MInCached: 0.005, CostPer1MOut: 0.40, ContextWindow: 400_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"gpt-4.1":      {CostPer1MIn: 2, CostPer1MInCached: 0.50, CostPer1MOut: 8, ContextWindow: 1_047_576, DefaultMaxTokens: 20000, SupportsAttachments: true},
+	"gpt-4.1-mini": {CostPer1MIn: 0.40, CostPer1MInCached: 0.10, CostPer1MOut: 1.60, ContextWindow: 1_047_576, DefaultMaxTokens: 20000, SupportsAttachments: true},
+	"gpt-4.1-nano": {CostPer1MIn: 0.10, CostPer1MInCached: 0.025, CostPer1MOut: 0.40, ContextWindow: 1_047_576, DefaultMaxTokens: 20000, SupportsAttachments: true},
+	"gpt-4o":       {CostPer1MIn: 2.50, CostPer1MInCached: 1.25, CostPer1MOut: 10, ContextWindow: 128_000, DefaultMaxTokens: 4096, SupportsAttachments: true},
+	"gpt-4o-mini":  {CostPer1MIn: 0.15, CostPer1MInCached: 0.075, CostPer1MOut: 0.60, ContextWindow: 128_000, DefaultMaxTokens: 4096, SupportsAttachments: true},
+	"o1":           {CostPer1MIn: 15, CostPer1MInCached: 7.50, CostPer1MOut: 60, ContextWindow: 200_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"o3":           {CostPer1MIn: 2, CostPer1MInCached: 0.50, CostPer1MOut: 8, ContextWindow: 200_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"o3-pro":       {CostPer1MIn: 20, CostPer1MOut: 80, ContextWindow: 200_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"o3-mini":      {CostPer1MIn: 1.10, CostPer1MInCached: 0.55, CostPer1MOut: 4.40, ContextWindow: 200_000, DefaultMaxTokens: 50000, CanReason: true},
+	"o4-mini":      {CostPer1MIn: 1.10, CostPer1MInCached: 0.275, CostPer1MOut: 4.40, ContextWindow: 200_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+
+	// Gemini
+	"gemini-2.5-pro":        {CostPer1MIn: 1.25, CostPer1MOut: 10, ContextWindow: 1_000_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"gemini-2.5-flash":      {CostPer1MIn: 0.30, CostPer1MOut: 2.50, ContextWindow: 1_000_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"gemini-2.5-flash-lite": {CostPer1MIn: 0.10, CostPer1MOut: 0.40, ContextWindow: 1_000_000, DefaultMaxTokens: 50000, CanReason: true, SupportsAttachments: true},
+	"gemini-2.0-flash":      {CostPer1MIn: 0.10, CostPer1MOut: 0.40, ContextWindow: 1_000_000, DefaultMaxTokens: 8192, SupportsAttachments: true},
+	"gemini-2.0-flash-lite": {CostPer1MIn: 0.075, CostPer1MOut: 0.30, ContextWindow: 1_000_000, DefaultMaxTokens: 8192, SupportsAttachments: true},
+}
+
+// catalogExcluded marks model variants that can't serve chat with tools:
+// audio, realtime, image and search models, and the like.
+var catalogExcluded = []string{"audio", "realtime", "tts", "transcribe", "image", "search", "embedding", "live", "codex", "deep-research"}
+
+// pricedModel returns a model for a discovered API model priced from
+// catalogPricing, or false if the table has no price for it or it's a variant
+// that can't be used for chat.
+func pricedModel(provider ModelProvider, apiModel, name string) (Model, bool) {
+	for _, excluded := range catalogExcluded {
+		if strings.Contains(apiModel, excluded) {
+			return Model{}, false
+		}
+	}
+	prefix := ""
+	for candidate := range catalogPricing {
+		if len(candidate) > len(prefix) && matchesModelPrefix(apiModel, candidate) {
+			prefix = candidate
+		}
+	}
+	if prefix == "" {
+		return Model{}, false
+	}
+	model := catalogPricing[prefix]
+	model.ID = ModelID(apiModel)
+	model.Name = cmp.Or(name, apiModel)
+	model.Provider = provider
+	model.APIModel = apiModel
+	return model, true
+}
+
+// matchesModelPrefix reports whether apiModel is the family prefix or a
+// version of it, so "o3" matches "o3-2025-04-16" but not "o3x".
+func matchesModelPrefix(apiModel, prefix string) bool {
+	rest, ok := strings.CutPrefix(apiModel, prefix)
+	return ok && (rest == "" || rest[0] == '-')
+}
+
+func fetchAnthropicModels(ctx context.Context, apiKey string) ([]Model, error) {
+	var models []Model
+	afterID := ""
+	for {
+		query := url.Values{"limit": {"1000"}}
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+		var page struct {
+			Data []struct {
+				ID          string `json:"id"`
+				DisplayName string `json:"display_name"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		err := getCatalogJSON(ctx, anthropicModelsURL+"?"+query.Encode(), map[string]string{
+			"x-api-key":         apiKey,
+			"anthropic-version": "2023-06-01",
+		}, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range page.Data {
+			if model, ok := pricedModel(ProviderAnthropic, m.ID, m.DisplayName); ok {
+				models = append(models, model)
+			}
+		}
+		if !page.HasMore || page.LastID == "" {
+			return models, nil
+		}
+		afterID = page.LastID
+	}
+}
+
+func fetchOpenAIModels(ctx context.Context, apiKey string) ([]Model, error) {
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	err := getCatalogJSON(ctx, openAIModelsURL, map[string]string{"Authorization": "Bearer " + apiKey}, &list)
+	if err != nil {
+		return nil, err
+	}
+	var models []Model
+	for _, m := range list.Data {
+		if model, ok := pricedModel(ProviderOpenAI, m.ID, ""); ok {
+			models = append(models, model)
+		}
+	}
+	return models, nil
+}
+
+func fetchGeminiModels(ctx context.Context, apiKey string) ([]Model, error) {
+	var models []Model
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		var page struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				DisplayName                string   `json:"displayName"`
+				InputTokenLimit            int64    `json:"inputTokenLimit"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err := getCatalogJSON(ctx, geminiModelsURL+"?"+query.Encode(), map[string]string{"x-goog-api-key": apiKey}, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range page.Models {
+			if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+				continue
+			}
+			model, ok := pricedModel(ProviderGemini, strings.TrimPrefix(m.Name, "models/"), m.DisplayName)
+			if !ok {
+				continue
+			}
+			model.ContextWindow = cmp.Or(m.InputTokenLimit, model.ContextWindow)
+			models = append(models, model)
+		}
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func getCatalogJSON(ctx context.Context, url string, headers map[string]string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to list models: status %d", res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode model list: %w", err)
+	}
+	return nil
+}
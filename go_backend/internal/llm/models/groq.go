@@ -10,6 +10,8 @@ const (
 	Llama4Scout               ModelID = "meta-llama/llama-4-scout-17b-16e-instruct"
 	Llama4Maverick            ModelID = "meta-llama/llama-4-maverick-17b-128e-instruct"
 	Llama3_3_70BVersatile     ModelID = "llama-3.3-70b-versatile"
+	Llama3_1_8BInstant        ModelID = "llama-3.1-8b-instant"
+	Mixtral8x7B               ModelID = "mixtral-8x7b-32768"
 	DeepseekR1DistillLlama70b ModelID = "deepseek-r1-distill-llama-70b"
 )
 
@@ -71,6 +73,32 @@ var GroqModels = map[ModelID]Model{
 		SupportsAttachments: false,
 	},
 
+	Llama3_1_8BInstant: {
+		ID:                  Llama3_1_8BInstant,
+		Name:                "Llama3_1_8BInstant",
+		Provider:            ProviderGROQ,
+		APIModel:            "llama-3.1-8b-instant",
+		CostPer1MIn:         0.05,
+		CostPer1MInCached:   0,
+		CostPer1MOutCached:  0,
+		CostPer1MOut:        0.08,
+		ContextWindow:       128_000,
+		SupportsAttachments: false,
+	},
+
+	Mixtral8x7B: {
+		ID:                  Mixtral8x7B,
+		Name:                "Mixtral8x7B",
+		Provider:            ProviderGROQ,
+		APIModel:            "mixtral-8x7b-32768",
+		CostPer1MIn:         0.24,
+		CostPer1MInCached:   0,
+		CostPer1MOutCached:  0,
+		CostPer1MOut:        0.24,
+		ContextWindow:       32_768,
+		SupportsAttachments: false,
+	},
+
 	DeepseekR1DistillLlama70b: {
 		ID:                  DeepseekR1DistillLlama70b,
 		Name:                "DeepseekR1DistillLlama70b",
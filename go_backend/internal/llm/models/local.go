@@ -127,7 +127,7 @@ func listLocalModels(modelsEndpoint string) []localModel {
 func loadLocalModels(models []localModel) {
 	for i, m := range models {
 		model := convertLocalModel(m)
-		SupportedModels[model.ID] = model
+		Register(model)
 
 		if i == 0 || m.State == "loaded" {
 			viper.SetDefault("agents.coder.model", model.ID)
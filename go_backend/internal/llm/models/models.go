@@ -1,6 +1,9 @@
 package models
 
-import "maps"
+import (
+	"maps"
+	"sync"
+)
 
 type (
 	ModelID       string
@@ -22,14 +25,7 @@ type Model struct {
 	SupportsAttachments bool          `json:"supports_attachments"`
 }
 
-// Model IDs
-const ( // GEMINI
-	// Bedrock
-	BedrockClaude37Sonnet ModelID = "bedrock.claude-3.7-sonnet"
-)
-
 const (
-	ProviderBedrock ModelProvider = "bedrock"
 	// ForTests
 	ProviderMock ModelProvider = "__mock"
 )
@@ -46,51 +42,71 @@ var ProviderPopularity = map[ModelProvider]int{
 	ProviderVertexAI:   9,
 }
 
-var SupportedModels = map[ModelID]Model{
-	//
-	// // GEMINI
-	// GEMINI25: {
-	// 	ID:                 GEMINI25,
-	// 	Name:               "Gemini 2.5 Pro",
-	// 	Provider:           ProviderGemini,
-	// 	APIModel:           "gemini-2.5-pro-exp-03-25",
-	// 	CostPer1MIn:        0,
-	// 	CostPer1MInCached:  0,
-	// 	CostPer1MOutCached: 0,
-	// 	CostPer1MOut:       0,
-	// },
-	//
-	// GRMINI20Flash: {
-	// 	ID:                 GRMINI20Flash,
-	// 	Name:               "Gemini 2.0 Flash",
-	// 	Provider:           ProviderGemini,
-	// 	APIModel:           "gemini-2.0-flash",
-	// 	CostPer1MIn:        0.1,
-	// 	CostPer1MInCached:  0,
-	// 	CostPer1MOutCached: 0.025,
-	// 	CostPer1MOut:       0.4,
-	// },
-	//
-	// // Bedrock
-	BedrockClaude37Sonnet: {
-		ID:                 BedrockClaude37Sonnet,
-		Name:               "Bedrock: Claude 3.7 Sonnet",
-		Provider:           ProviderBedrock,
-		APIModel:           "anthropic.claude-3-7-sonnet-20250219-v1:0",
-		CostPer1MIn:        3.0,
-		CostPer1MInCached:  3.75,
-		CostPer1MOutCached: 0.30,
-		CostPer1MOut:       15.0,
-	},
-}
+// supportedModels holds the built-in models plus the ones registered at
+// runtime from provider catalogs. It's guarded by supportedMu since catalogs
+// are refreshed while the server runs.
+var (
+	supportedMu     sync.RWMutex
+	builtinModels   map[ModelID]Model
+	supportedModels = map[ModelID]Model{
+		//
+		// // GEMINI
+		// GEMINI25: {
+		// 	ID:                 GEMINI25,
+		// 	Name:               "Gemini 2.5 Pro",
+		// 	Provider:           ProviderGemini,
+		// 	APIModel:           "gemini-2.5-pro-exp-03-25",
+		// 	CostPer1MIn:        0,
+		// 	CostPer1MInCached:  0,
+		// 	CostPer1MOutCached: 0,
+		// 	CostPer1MOut:       0,
+		// },
+		//
+		// GRMINI20Flash: {
+		// 	ID:                 GRMINI20Flash,
+		// 	Name:               "Gemini 2.0 Flash",
+		// 	Provider:           ProviderGemini,
+		// 	APIModel:           "gemini-2.0-flash",
+		// 	CostPer1MIn:        0.1,
+		// 	CostPer1MInCached:  0,
+		// 	CostPer1MOutCached: 0.025,
+		// 	CostPer1MOut:       0.4,
+		// },
+		//
+	}
+)
 
 func init() {
-	maps.Copy(SupportedModels, AnthropicModels)
-	maps.Copy(SupportedModels, OpenAIModels)
-	maps.Copy(SupportedModels, GeminiModels)
-	maps.Copy(SupportedModels, GroqModels)
-	maps.Copy(SupportedModels, AzureModels)
-	maps.Copy(SupportedModels, OpenRouterModels)
-	maps.Copy(SupportedModels, XAIModels)
-	maps.Copy(SupportedModels, VertexAIGeminiModels)
+	maps.Copy(supportedModels, AnthropicModels)
+	maps.Copy(supportedModels, BedrockModels)
+	maps.Copy(supportedModels, OpenAIModels)
+	maps.Copy(supportedModels, GeminiModels)
+	maps.Copy(supportedModels, GroqModels)
+	maps.Copy(supportedModels, AzureModels)
+	maps.Copy(supportedModels, OpenRouterModels)
+	maps.Copy(supportedModels, XAIModels)
+	maps.Copy(supportedModels, VertexAIGeminiModels)
+	builtinModels = maps.Clone(supportedModels)
+}
+
+// Lookup returns the supported model with the given ID.
+func Lookup(id ModelID) (Model, bool) {
+	supportedMu.RLock()
+	defer supportedMu.RUnlock()
+	model, ok := supportedModels[id]
+	return model, ok
+}
+
+// Supported returns a copy of all supported models by ID.
+func Supported() map[ModelID]Model {
+	supportedMu.RLock()
+	defer supportedMu.RUnlock()
+	return maps.Clone(supportedModels)
+}
+
+// Register adds model to the supported models, replacing any with its ID.
+func Register(model Model) {
+	supportedMu.Lock()
+	defer supportedMu.Unlock()
+	supportedModels[model.ID] = model
 }
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"mix/internal/logging"
+)
+
+var openRouterModelsURL = "https://openrouter.ai/api/v1/models"
+
+type openRouterModelList struct {
+	Data []openRouterModel `json:"data"`
+}
+
+type openRouterModel struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ContextLength int64  `json:"context_length"`
+	Architecture  struct {
+		InputModalities []string `json:"input_modalities"`
+	} `json:"architecture"`
+	Pricing struct {
+		Prompt          string `json:"prompt"`
+		Completion      string `json:"completion"`
+		InputCacheRead  string `json:"input_cache_read"`
+		InputCacheWrite string `json:"input_cache_write"`
+	} `json:"pricing"`
+	TopProvider struct {
+		MaxCompletionTokens int64 `json:"max_completion_tokens"`
+	} `json:"top_provider"`
+	SupportedParameters []string `json:"supported_parameters"`
+}
+
+// OpenRouterCatalogFile is where the last fetched OpenRouter catalog is
+// persisted in the data directory, so startup registers its models without
+// waiting on the network.
+const OpenRouterCatalogFile = "openrouter-models.json"
+
+// openRouterCatalog is the persisted OpenRouter catalog
+type openRouterCatalog struct {
+	RefreshedAt time.Time         `json:"refreshedAt"`
+	Models      []openRouterModel `json:"models"`
+}
+
+// openRouterRefreshedAt is when the registered OpenRouter catalog was
+// fetched, guarded by supportedMu
+var openRouterRefreshedAt time.Time
+
+// OpenRouterCatalogRefreshedAt returns when the registered OpenRouter catalog
+// was fetched, or the zero time if there is none.
+func OpenRouterCatalogRefreshedAt() time.Time {
+	supportedMu.RLock()
+	defer supportedMu.RUnlock()
+	return openRouterRefreshedAt
+}
+
+// LoadOpenRouterCatalog registers the models of the OpenRouter catalog
+// persisted at path. A missing file leaves the built-in OpenRouter models.
+func LoadOpenRouterCatalog(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read OpenRouter catalog %s: %w", path, err)
+	}
+	var catalog openRouterCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("failed to read OpenRouter catalog %s: %w", path, err)
+	}
+	registerOpenRouterModels(catalog)
+	return nil
+}
+
+// RefreshOpenRouterCatalog fetches the OpenRouter catalog, registers its
+// models and persists it to path. The models registered before stay when the
+// fetch fails.
+func RefreshOpenRouterCatalog(ctx context.Context, path, apiKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, openRouterModelsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch OpenRouter models: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch OpenRouter models: status %d", res.StatusCode)
+	}
+
+	var modelList openRouterModelList
+	if err := json.NewDecoder(res.Body).Decode(&modelList); err != nil {
+		return fmt.Errorf("failed to decode OpenRouter models: %w", err)
+	}
+
+	catalog := openRouterCatalog{RefreshedAt: time.Now().UTC(), Models: modelList.Data}
+	registerOpenRouterModels(catalog)
+
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save OpenRouter catalog: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save OpenRouter catalog: %w", err)
+	}
+	return nil
+}
+
+// registerOpenRouterModels registers every catalog model that supports tool
+// calling. Built-in OpenRouter models get their pricing and limits refreshed
+// from the catalog so usage costs match what OpenRouter bills.
+func registerOpenRouterModels(catalog openRouterCatalog) {
+	builtin := make(map[string]ModelID, len(OpenRouterModels))
+	for id, model := range OpenRouterModels {
+		builtin[model.APIModel] = id
+	}
+
+	loaded := 0
+	for _, m := range catalog.Models {
+		if !slices.Contains(m.SupportedParameters, "tools") {
+			continue
+		}
+
+		model := convertOpenRouterModel(m)
+		if id, ok := builtin[m.ID]; ok {
+			existing, _ := Lookup(id)
+			existing.CostPer1MIn = model.CostPer1MIn
+			existing.CostPer1MOut = model.CostPer1MOut
+			existing.CostPer1MInCached = model.CostPer1MInCached
+			existing.CostPer1MOutCached = model.CostPer1MOutCached
+			existing.ContextWindow = cmp.Or(model.ContextWindow, existing.ContextWindow)
+			Register(existing)
+		} else {
+			Register(model)
+		}
+		loaded++
+	}
+
+	supportedMu.Lock()
+	openRouterRefreshedAt = catalog.RefreshedAt
+	supportedMu.Unlock()
+	logging.Debug("Loaded OpenRouter models", "count", loaded)
+}
+
+func convertOpenRouterModel(m openRouterModel) Model {
+	return Model{
+		ID:                  ModelID("openrouter." + m.ID),
+		Name:                "OpenRouter – " + cmp.Or(m.Name, m.ID),
+		Provider:            ProviderOpenRouter,
+		APIModel:            m.ID,
+		CostPer1MIn:         perTokenToPer1M(m.Pricing.Prompt),
+		CostPer1MOut:        perTokenToPer1M(m.Pricing.Completion),
+		CostPer1MInCached:   perTokenToPer1M(m.Pricing.InputCacheWrite),
+		CostPer1MOutCached:  perTokenToPer1M(m.Pricing.InputCacheRead),
+		ContextWindow:       m.ContextLength,
+		DefaultMaxTokens:    cmp.Or(m.TopProvider.MaxCompletionTokens, 4096),
+		CanReason:           slices.Contains(m.SupportedParameters, "reasoning"),
+		SupportsAttachments: slices.Contains(m.Architecture.InputModalities, "image"),
+	}
+}
+
+// perTokenToPer1M converts OpenRouter's per-token USD price string. Missing or
+// negative ("-1" marks variable pricing) prices count as free.
+func perTokenToPer1M(price string) float64 {
+	perToken, err := strconv.ParseFloat(price, 64)
+	if err != nil || perToken < 0 {
+		return 0
+	}
+	return perToken * 1e6
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOpenRouterCatalog = `{"data": [
+	{"id": "acme/tool-model", "name": "Tool Model", "context_length": 64000,
+	 "architecture": {"input_modalities": ["text", "image"]},
+	 "pricing": {"prompt": "0.000002", "completion": "0.000008"},
+	 "top_provider": {"max_completion_tokens": 8192},
+	 "supported_parameters": ["tools", "reasoning"]},
+	{"id": "acme/chat-model", "name": "Chat Model", "supported_parameters": ["temperature"]},
+	{"id": "openai/gpt-4.1", "name": "GPT 4.1", "context_length": 2000000,
+	 "pricing": {"prompt": "0.000003", "completion": "-1"},
+	 "supported_parameters": ["tools"]}
+]}`
+
+func TestPerTokenToPer1M(t *testing.T) {
+	tests := []struct {
+		price    string
+		expected float64
+	}{
+		{"0.000002", 2},
+		{"0", 0},
+		{"-1", 0},
+		{"", 0},
+		{"free", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.price, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, perTokenToPer1M(tt.price), 1e-9)
+		})
+	}
+}
+
+func TestRefreshOpenRouterCatalog(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(testOpenRouterCatalog))
+	}))
+	defer server.Close()
+	defer func(url string) { openRouterModelsURL = url }(openRouterModelsURL)
+	openRouterModelsURL = server.URL
+
+	path := filepath.Join(t.TempDir(), OpenRouterCatalogFile)
+	require.NoError(t, RefreshOpenRouterCatalog(context.Background(), path, "key"))
+	assert.Equal(t, "Bearer key", auth)
+	assert.WithinDuration(t, time.Now(), OpenRouterCatalogRefreshedAt(), time.Minute)
+
+	tests := []struct {
+		name      string
+		id        ModelID
+		supported bool
+		check     func(t *testing.T, model Model)
+	}{
+		{
+			name:      "tool calling model",
+			id:        "openrouter.acme/tool-model",
+			supported: true,
+			check: func(t *testing.T, model Model) {
+				assert.Equal(t, ProviderOpenRouter, model.Provider)
+				assert.Equal(t, "acme/tool-model", model.APIModel)
+				assert.InDelta(t, 2, model.CostPer1MIn, 1e-9)
+				assert.InDelta(t, 8, model.CostPer1MOut, 1e-9)
+				assert.Equal(t, int64(64000), model.ContextWindow)
+				assert.Equal(t, int64(8192), model.DefaultMaxTokens)
+				assert.True(t, model.CanReason)
+				assert.True(t, model.SupportsAttachments)
+			},
+		},
+		{
+			name: "model without tool calling",
+			id:   "openrouter.acme/chat-model",
+		},
+		{
+			name:      "built-in model repriced",
+			id:        OpenRouterGPT41,
+			supported: true,
+			check: func(t *testing.T, model Model) {
+				assert.InDelta(t, 3, model.CostPer1MIn, 1e-9)
+				assert.Zero(t, model.CostPer1MOut)
+				assert.Equal(t, int64(2000000), model.ContextWindow)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, ok := Lookup(tt.id)
+			assert.Equal(t, tt.supported, ok)
+			if tt.check != nil {
+				tt.check(t, model)
+			}
+		})
+	}
+
+	// The persisted catalog is what the next start loads, without fetching
+	_, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, LoadOpenRouterCatalog(path))
+}
+
+func TestRefreshOpenRouterCatalogFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	defer func(url string) { openRouterModelsURL = url }(openRouterModelsURL)
+	openRouterModelsURL = server.URL
+
+	path := filepath.Join(t.TempDir(), OpenRouterCatalogFile)
+	assert.ErrorContains(t, RefreshOpenRouterCatalog(context.Background(), path, "bad"), "status 401")
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// A missing catalog leaves the built-in models
+	require.NoError(t, LoadOpenRouterCatalog(path))
+	_, ok := Lookup(OpenRouterGPT41)
+	assert.True(t, ok)
+}
@@ -0,0 +1,184 @@
+package prompt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"mix/internal/config"
+	"mix/internal/llm/tools"
+	"mix/internal/logging"
+)
+
+const (
+	// maxContextFileBytes bounds the content loaded from one context file
+	maxContextFileBytes = 32 * 1024
+	// maxContextBytes bounds the content of all context files together;
+	// files past it are left out
+	maxContextBytes = 96 * 1024
+)
+
+func getContextFromPaths(ctx context.Context) (string, error) {
+	workingDir, ok := ctx.Value(tools.WorkingDirectoryContextKey).(string)
+	if !ok {
+		return "", fmt.Errorf("no working directory found in context")
+	}
+
+	return ContextFiles(workingDir)
+}
+
+// ContextFiles returns the content of the configured context files, such as
+// MIX.md, for workDir as they are now. Files are looked up in every directory
+// from the repository root down to workDir, so the instructions of the whole
+// repository come first and those of workDir last.
+func ContextFiles(workDir string) (string, error) {
+	return processContextPaths(workDir, config.Get().ContextPaths)
+}
+
+// ContextFingerprint identifies the state of workDir's context files, so a
+// change to any of them, or a new or deleted one, changes it.
+func ContextFingerprint(workDir string) string {
+	if workDir == "" {
+		return ""
+	}
+	h := sha256.New()
+	for _, path := range contextFileCandidates(workDir, config.Get().ContextPaths) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// repositoryRoot returns the nearest directory above or at dir containing
+// .git, or dir itself outside a repository.
+func repositoryRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// contextFileCandidates returns the paths context files may be at, in the
+// order they are loaded. Files are looked up in each directory from the
+// repository root down to workDir; directory entries, ending in "/", only in
+// workDir, where all their files are candidates.
+func contextFileCandidates(workDir string, paths []string) []string {
+	workDir = filepath.Clean(workDir)
+	root := repositoryRoot(workDir)
+	dirs := []string{workDir}
+	for dir := workDir; dir != root; {
+		dir = filepath.Dir(dir)
+		dirs = append([]string{dir}, dirs...)
+	}
+
+	var candidates []string
+	for _, path := range paths {
+		if !strings.HasSuffix(path, "/") {
+			for _, dir := range dirs {
+				candidates = append(candidates, filepath.Join(dir, path))
+			}
+			continue
+		}
+		filepath.WalkDir(filepath.Join(workDir, path), func(filePath string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				candidates = append(candidates, filePath)
+			}
+			return nil
+		})
+	}
+	return candidates
+}
+
+func processContextPaths(workDir string, paths []string) (string, error) {
+	// Files are the same when their real paths are, on case-insensitive file
+	// systems too, or when their content is
+	processedFiles := make(map[string]bool)
+	processedContent := make(map[[sha256.Size]byte]bool)
+	results := make([]string, 0)
+	var foundCount, loadedCount, size int
+
+	for _, path := range contextFileCandidates(workDir, paths) {
+		key := path
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			key = real
+		}
+		key = strings.ToLower(key)
+		if processedFiles[key] {
+			continue
+		}
+		processedFiles[key] = true
+
+		content, found, err := processFile(path)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			continue
+		}
+		foundCount++
+		if content == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(content))
+		if processedContent[sum] {
+			logging.Debug("Skipping context file with duplicate content", "path", path)
+			continue
+		}
+		processedContent[sum] = true
+
+		if size+len(content) > maxContextBytes {
+			logging.Warn("Context files exceed the size limit, leaving out the rest", "path", path, "limit", maxContextBytes)
+			break
+		}
+		size += len(content)
+		loadedCount++
+		results = append(results, "# From:"+path+"\n"+content)
+	}
+
+	content := strings.Join(results, "\n")
+	logging.Debug("Context file loading completed",
+		"files_found", foundCount,
+		"files_loaded", loadedCount,
+		"content_length", len(content))
+
+	return content, nil
+}
+
+// processFile reads a context file, cut at maxContextFileBytes. found is
+// false when the file doesn't exist.
+func processFile(filePath string) (content string, found bool, err error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil // Not found, not an error
+		}
+		logging.Error("Failed to read context file", "path", filePath, "error", err)
+		return "", false, fmt.Errorf("failed to read context file %s: %w", filePath, err)
+	}
+
+	if len(data) > maxContextFileBytes {
+		logging.Warn("Context file exceeds the size limit, truncating", "path", filePath, "size", len(data), "limit", maxContextFileBytes)
+		end := maxContextFileBytes
+		for end > 0 && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		data = append(data[:end:end], fmt.Sprintf("\n[Truncated: the file is larger than %d KB]", maxContextFileBytes/1024)...)
+	}
+
+	logging.Debug("Loaded context file", "path", filePath, "size", len(data))
+	return string(data), true, nil
+}
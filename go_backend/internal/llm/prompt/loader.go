@@ -3,6 +3,7 @@ package prompt
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
@@ -12,42 +13,82 @@ import (
 	"mix/internal/llm/tools"
 )
 
-// LoadPrompt loads a prompt from embedded filesystem markdown files
+var templateVarRegex = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// LoadPrompt loads a prompt from the project prompts directory or the embedded markdown files
 func LoadPrompt(name string) (string, error) {
 	return LoadPromptWithVars(name, nil)
 }
 
-// loadEmbeddedPrompt loads a prompt from the embedded filesystem
-func loadEmbeddedPrompt(name string) (string, error) {
+// loadPromptFile reads relativePath from the configured prompts directory,
+// falling back to the embedded prompts when there is no override.
+func loadPromptFile(relativePath string) (string, error) {
+	if promptsDir, err := config.PromptsDirectory(); err == nil && promptsDir != "" {
+		content, err := os.ReadFile(filepath.Join(promptsDir, relativePath))
+		if err == nil {
+			return string(content), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read prompt override '%s': %w", relativePath, err)
+		}
+	}
+
 	embeddedFS := config.GetEmbeddedPrompts()
-	promptPath := filepath.Join("prompts", name+".md")
-	
-	content, err := embeddedFS.ReadFile(promptPath)
+	content, err := embeddedFS.ReadFile(filepath.Join("prompts", relativePath))
 	if err != nil {
-		return "", fmt.Errorf("failed to read embedded prompt file '%s': %w", promptPath, err)
+		return "", fmt.Errorf("failed to read embedded prompt file '%s': %w", relativePath, err)
 	}
-	
 	return string(content), nil
 }
 
-// LoadPromptWithVars loads a prompt from embedded filesystem only and replaces $<name> placeholders
+// substituteVars replaces $<name> and {{name}} placeholders. Unknown {{name}}
+// placeholders are an error so typos in prompt overrides surface immediately.
+func substituteVars(content string, vars map[string]string) (string, error) {
+	for key, value := range vars {
+		content = strings.ReplaceAll(content, "$<"+key+">", value)
+	}
+
+	var missing []string
+	content = templateVarRegex.ReplaceAllStringFunc(content, func(match string) string {
+		key := templateVarRegex.FindStringSubmatch(match)[1]
+		value, ok := vars[key]
+		if !ok {
+			missing = append(missing, match)
+			return match
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined template variables: %s", strings.Join(missing, ", "))
+	}
+	return content, nil
+}
+
+// LoadPromptWithVars loads a prompt and replaces $<name> and {{name}} placeholders.
+// promptVars from the config are available to every prompt; vars take precedence.
 func LoadPromptWithVars(name string, vars map[string]string) (string, error) {
-	// Load from embedded filesystem only
-	result, err := loadEmbeddedPrompt(name)
+	result, err := loadPromptFile(name + ".md")
 	if err != nil {
-		return "", fmt.Errorf("failed to load embedded prompt '%s': %w", name, err)
+		return "", fmt.Errorf("failed to load prompt '%s': %w", name, err)
 	}
 
-	// Replace $<name> placeholders with values
-	if vars != nil {
-		for key, value := range vars {
-			placeholder := "$<" + key + ">"
-			result = strings.ReplaceAll(result, placeholder, value)
+	allVars := make(map[string]string)
+	if cfg := config.Get(); cfg != nil {
+		for k, v := range cfg.PromptVars {
+			allVars[k] = v
 		}
 	}
+	for k, v := range vars {
+		allVars[k] = v
+	}
+
+	result, err = substituteVars(result, allVars)
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt '%s': %w", name, err)
+	}
 
 	// Resolve markdown file templates
-	result, err = resolveMarkdownTemplates(result, vars)
+	result, err = resolveMarkdownTemplates(result, allVars)
 	if err != nil {
 		return "", err
 	}
@@ -104,24 +145,17 @@ func resolveMarkdownTemplates(content string, vars map[string]string) (string, e
 			return match
 		}
 
-		// Load from embedded filesystem only
-		embeddedFS := config.GetEmbeddedPrompts()
-		embeddedPath := filepath.Join("prompts", relativePath)
-		fileContent, err := embeddedFS.ReadFile(embeddedPath)
-		
+		fileResult, err := loadPromptFile(relativePath)
 		if err != nil {
-			resolveErr = fmt.Errorf("failed to load embedded markdown template '%s': %w", relativePath, err)
+			resolveErr = fmt.Errorf("failed to load markdown template '%s': %w", relativePath, err)
 			return match
 		}
-		
-		fileResult := string(fileContent)
 
 		// Apply variable substitution to included markdown file
-		if vars != nil {
-			for key, value := range vars {
-				placeholder := "$<" + key + ">"
-				fileResult = strings.ReplaceAll(fileResult, placeholder, value)
-			}
+		fileResult, err = substituteVars(fileResult, vars)
+		if err != nil {
+			resolveErr = fmt.Errorf("failed to render markdown template '%s': %w", relativePath, err)
+			return match
 		}
 
 		// Check for unmatched template variables
@@ -1,9 +1,9 @@
 package prompt
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"mix/internal/config"
@@ -12,47 +12,95 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestGetContextFromPaths(t *testing.T) {
-	t.Parallel()
-
+func TestContextFiles(t *testing.T) {
 	tmpDir := t.TempDir()
-	_, err := config.Load(tmpDir, false)
+	_, err := config.Load(tmpDir, false, false)
 	if err != nil {
 		t.Fatalf("Failed to load config: %v", err)
 	}
-	cfg := config.Get()
-	cfg.WorkingDir = tmpDir
-	cfg.ContextPaths = []string{
-		"file.txt",
-		"directory/",
-	}
-	testFiles := []string{
-		"file.txt",
-		"directory/file_a.txt",
-		"directory/file_b.txt",
-		"directory/file_c.txt",
+
+	tests := []struct {
+		name       string
+		files      []string
+		content    map[string]string
+		paths      []string
+		workDir    string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:  "files and directories",
+			files: []string{"file.txt", "directory/file_a.txt", "directory/file_b.txt"},
+			paths: []string{"file.txt", "directory/"},
+			expected: []string{
+				"# From:{dir}/file.txt\nfile.txt: test content",
+				"# From:{dir}/directory/file_a.txt\ndirectory/file_a.txt: test content",
+				"# From:{dir}/directory/file_b.txt\ndirectory/file_b.txt: test content",
+			},
+		},
+		{
+			name:    "repository root down to the working directory",
+			files:   []string{".git/HEAD", "MIX.md", "pkg/MIX.md"},
+			paths:   []string{"MIX.md"},
+			workDir: "pkg",
+			expected: []string{
+				"# From:{dir}/MIX.md\nMIX.md: test content\n# From:{dir}/pkg/MIX.md\npkg/MIX.md: test content",
+			},
+		},
+		{
+			name:       "duplicate content is loaded once",
+			files:      []string{"MIX.md", "CLAUDE.md"},
+			content:    map[string]string{"MIX.md": "same", "CLAUDE.md": "same"},
+			paths:      []string{"MIX.md", "CLAUDE.md"},
+			expected:   []string{"# From:{dir}/MIX.md\nsame"},
+			unexpected: []string{"CLAUDE.md"},
+		},
+		{
+			name:       "large files are truncated",
+			files:      []string{"MIX.md"},
+			content:    map[string]string{"MIX.md": strings.Repeat("é", maxContextFileBytes)},
+			paths:      []string{"MIX.md"},
+			expected:   []string{"[Truncated: the file is larger than 32 KB]"},
+			unexpected: []string{"�"},
+		},
+		{
+			name:  "missing files are skipped",
+			paths: []string{"MIX.md", "missing/"},
+		},
 	}
 
-	createTestFiles(t, tmpDir, testFiles)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createTestFiles(t, dir, tt.files, tt.content)
+			config.Get().ContextPaths = tt.paths
 
-	context := getContextFromPaths()
-	expectedContext := fmt.Sprintf("# From:%s/file.txt\nfile.txt: test content\n# From:%s/directory/file_a.txt\ndirectory/file_a.txt: test content\n# From:%s/directory/file_b.txt\ndirectory/file_b.txt: test content\n# From:%s/directory/file_c.txt\ndirectory/file_c.txt: test content", tmpDir, tmpDir, tmpDir, tmpDir)
-	assert.Equal(t, expectedContext, context)
+			context, err := ContextFiles(filepath.Join(dir, tt.workDir))
+			require.NoError(t, err)
+			if len(tt.expected) == 0 {
+				assert.Empty(t, context)
+			}
+			for _, expected := range tt.expected {
+				assert.Contains(t, context, strings.ReplaceAll(expected, "{dir}", dir))
+			}
+			for _, unexpected := range tt.unexpected {
+				assert.NotContains(t, context, unexpected)
+			}
+		})
+	}
 }
 
-func createTestFiles(t *testing.T, tmpDir string, testFiles []string) {
+func createTestFiles(t *testing.T, tmpDir string, testFiles []string, content map[string]string) {
 	t.Helper()
 	for _, path := range testFiles {
 		fullPath := filepath.Join(tmpDir, path)
-		if path[len(path)-1] == '/' {
-			err := os.MkdirAll(fullPath, 0755)
-			require.NoError(t, err)
-		} else {
-			dir := filepath.Dir(fullPath)
-			err := os.MkdirAll(dir, 0755)
-			require.NoError(t, err)
-			err = os.WriteFile(fullPath, []byte(path+": test content"), 0644)
-			require.NoError(t, err)
+		err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+		require.NoError(t, err)
+		data, ok := content[path]
+		if !ok {
+			data = path + ": test content"
 		}
+		err = os.WriteFile(fullPath, []byte(data), 0644)
+		require.NoError(t, err)
 	}
 }
@@ -0,0 +1,90 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/logging"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce groups the bursts of events editors produce on save
+const reloadDebounce = 250 * time.Millisecond
+
+// Watch calls onChange whenever a file in the prompts directory changes, until
+// ctx is cancelled. It does nothing if the prompts directory does not exist.
+func Watch(ctx context.Context, onChange func()) error {
+	promptsDir, err := config.PromptsDirectory()
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(promptsDir); err != nil || !info.IsDir() {
+		logging.Debug("Prompts directory not found, prompt hot-reload disabled", "dir", promptsDir)
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// fsnotify is not recursive, so watch every subdirectory (e.g. tools/)
+	err = filepath.WalkDir(promptsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	logging.Info("Watching prompts directory for changes", "dir", promptsDir)
+
+	go func() {
+		defer logging.RecoverPanic("prompt-watcher", nil)
+		defer watcher.Close()
+
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						watcher.Add(event.Name)
+					}
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDebounce, func() {
+					logging.Info("Prompt files changed, reloading", "dir", promptsDir)
+					onChange()
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.Warn("Prompt watcher error", "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -9,24 +9,35 @@ import (
 	"strings"
 	"time"
 
+	"mix/internal/chaos"
 	"mix/internal/config"
 	"mix/internal/llm/models"
+	"mix/internal/llm/responseformat"
 	toolsPkg "mix/internal/llm/tools"
 	"mix/internal/logging"
 	"mix/internal/message"
+	"mix/internal/metrics"
 
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/bedrock"
 	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
 type anthropicOptions struct {
-	useBedrock             bool
-	disableCache           bool
-	thinkingBudget         func(userMessage string) int
+	bedrockConfig          *aws.Config
+	cache                  anthropicCache
 	useOAuth               bool
 	oauthCreds             *OAuthCredentials
 	useInterleavedThinking bool
+	// thinking is nil for models that don't think
+	thinking *config.Thinking
+}
+
+// anthropicCache says where to put prompt cache breakpoints
+type anthropicCache struct {
+	system   bool
+	tools    bool
+	messages int
 }
 
 type AnthropicOption func(*anthropicOptions)
@@ -43,6 +54,7 @@ type AnthropicClient ProviderClient
 func newAnthropicClient(opts providerClientOptions) AnthropicClient {
 	anthropicOpts := anthropicOptions{
 		useInterleavedThinking: true, // Enable by default
+		cache:                  anthropicCache{system: true, tools: true, messages: 2},
 	}
 	for _, o := range opts.anthropicOptions {
 		o(&anthropicOpts)
@@ -56,7 +68,7 @@ func newAnthropicClient(opts providerClientOptions) AnthropicClient {
 
 	// Check for OAuth credentials first
 	var oauthCreds *OAuthCredentials
-	if credStorage != nil {
+	if credStorage != nil && anthropicOpts.bedrockConfig == nil {
 		if creds, err := credStorage.GetOAuthCredentials("anthropic"); err == nil && creds != nil {
 			// Check if token needs refresh
 			if creds.IsTokenExpired() && creds.RefreshToken != "" {
@@ -83,8 +95,10 @@ func newAnthropicClient(opts providerClientOptions) AnthropicClient {
 
 	anthropicClientOptions := []option.RequestOption{}
 
-	// Set up authentication
-	if oauthCreds != nil {
+	// Set up authentication. Bedrock requests are signed with AWS credentials instead
+	if anthropicOpts.bedrockConfig != nil {
+		anthropicClientOptions = append(anthropicClientOptions, bedrockRequestOptions(*anthropicOpts.bedrockConfig)...)
+	} else if oauthCreds != nil {
 		anthropicOpts.useOAuth = true
 		anthropicOpts.oauthCreds = oauthCreds
 		anthropicClientOptions = append(anthropicClientOptions, option.WithAuthToken(oauthCreds.AccessToken))
@@ -99,12 +113,8 @@ func newAnthropicClient(opts providerClientOptions) AnthropicClient {
 		anthropicClientOptions = append(anthropicClientOptions, option.WithAPIKey("placeholder-for-initialization-only"))
 	}
 
-	if anthropicOpts.useBedrock {
-		anthropicClientOptions = append(anthropicClientOptions, bedrock.WithLoadDefaultConfig(context.Background()))
-	}
-
 	// Add request timeout to prevent indefinite hangs
-	anthropicClientOptions = append(anthropicClientOptions, option.WithRequestTimeout(90*time.Second))
+	anthropicClientOptions = append(anthropicClientOptions, option.WithRequestTimeout(90*time.Second), option.WithHTTPClient(sharedHTTPClient))
 
 	anthropicClient := &anthropicClient{
 		providerOptions:   opts,
@@ -121,16 +131,26 @@ func newAnthropicClient(opts providerClientOptions) AnthropicClient {
 	return anthropicClient
 }
 
+// anthropicDocumentBlock returns the document block of a PDF or text
+// attachment, titled with its file name.
+func anthropicDocumentBlock(content message.BinaryContent) anthropic.ContentBlockParamUnion {
+	var block anthropic.ContentBlockParamUnion
+	if message.IsPDFType(content.MIMEType) {
+		block = anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: content.String(models.ProviderAnthropic)})
+	} else {
+		block = anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: strings.ToValidUTF8(string(content.Data), "\uFFFD")})
+	}
+	block.OfDocument.Title = anthropic.String(content.Name())
+	return block
+}
+
 func (a *anthropicClient) convertMessages(messages []message.Message) (anthropicMessages []anthropic.MessageParam) {
 	for i, msg := range messages {
-		cache := false
-		if i > len(messages)-3 {
-			cache = true
-		}
+		cache := i >= len(messages)-a.options.cache.messages
 		switch msg.Role {
 		case message.User:
 			content := anthropic.NewTextBlock(msg.Content().String())
-			if cache && !a.options.disableCache {
+			if cache {
 				content.OfText.CacheControl = anthropic.CacheControlEphemeralParam{
 					Type: "ephemeral",
 				}
@@ -138,6 +158,10 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 			var contentBlocks []anthropic.ContentBlockParamUnion
 			contentBlocks = append(contentBlocks, content)
 			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsDocument() {
+					contentBlocks = append(contentBlocks, anthropicDocumentBlock(binaryContent))
+					continue
+				}
 				base64Image := binaryContent.String(models.ProviderAnthropic)
 				imageBlock := anthropic.NewImageBlockBase64(binaryContent.MIMEType, base64Image)
 				contentBlocks = append(contentBlocks, imageBlock)
@@ -148,7 +172,7 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 			blocks := []anthropic.ContentBlockParamUnion{}
 			if msg.Content().String() != "" {
 				content := anthropic.NewTextBlock(msg.Content().String())
-				if cache && !a.options.disableCache {
+				if cache {
 					content.OfText.CacheControl = anthropic.CacheControlEphemeralParam{
 						Type: "ephemeral",
 					}
@@ -195,7 +219,7 @@ func (a *anthropicClient) convertTools(tools []toolsPkg.BaseTool) []anthropic.To
 			},
 		}
 
-		if i == len(tools)-1 && !a.options.disableCache {
+		if i == len(tools)-1 && a.options.cache.tools {
 			toolParam.CacheControl = anthropic.CacheControlEphemeralParam{
 				Type: "ephemeral",
 			}
@@ -207,6 +231,44 @@ func (a *anthropicClient) convertTools(tools []toolsPkg.BaseTool) []anthropic.To
 	return anthropicTools
 }
 
+// responseFormatTool returns the tool the model gives a response in format
+// by calling, as the API has no response format parameter of its own.
+func responseFormatTool(format *responseformat.Format) anthropic.ToolUnionParam {
+	schema, _ := format.ToolSchema()
+	inputSchema := anthropic.ToolInputSchemaParam{Properties: schema["properties"], ExtraFields: map[string]any{}}
+	for key, value := range schema {
+		switch key {
+		case "type", "properties":
+		case "required":
+			names, _ := value.([]any)
+			for _, name := range names {
+				if name, ok := name.(string); ok {
+					inputSchema.Required = append(inputSchema.Required, name)
+				}
+			}
+		default:
+			inputSchema.ExtraFields[key] = value
+		}
+	}
+	return anthropic.ToolUnionParam{OfTool: &anthropic.ToolParam{
+		Name:        responseformat.ToolName,
+		Description: anthropic.String("Give your final response by calling this tool once you are done, with the response as its input."),
+		InputSchema: inputSchema,
+	}}
+}
+
+// structuredResponse returns the response the model gave by calling
+// responseformat.ToolName.
+func structuredResponse(format *responseformat.Format, input json.RawMessage) string {
+	if _, wrapped := format.ToolSchema(); wrapped {
+		var wrapper map[string]json.RawMessage
+		if json.Unmarshal(input, &wrapper) == nil {
+			input = wrapper[responseformat.WrapProperty]
+		}
+	}
+	return string(input)
+}
+
 func (a *anthropicClient) finishReason(reason string) message.FinishReason {
 	switch reason {
 	case "end_turn":
@@ -222,7 +284,30 @@ func (a *anthropicClient) finishReason(reason string) message.FinishReason {
 	}
 }
 
-func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, tools []anthropic.ToolUnionParam) anthropic.MessageNewParams {
+// thinkingBudget returns the function budgeting a request's thinking by the
+// user's message: the request's own thinking settings, or else the agent's.
+// It is nil when the model doesn't think.
+func (a *anthropicClient) thinkingBudget(state toolsPkg.RequestState, messages []message.Message) func(userMessage string) int {
+	if a.options.thinking == nil {
+		return nil
+	}
+	th := *a.options.thinking
+	if state.Thinking != nil {
+		th = state.Thinking.Resolved()
+	}
+	failureRate := ToolFailureRate(messages)
+	return func(userMessage string) int {
+		budget := ThinkingBudget(th, userMessage, failureRate)
+		// The budget must stay below max_tokens
+		budget = min(budget, int(a.providerOptions.maxTokens)-1)
+		if budget < config.MinThinkingBudget {
+			return 0
+		}
+		return budget
+	}
+}
+
+func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, tools []anthropic.ToolUnionParam, thinkingBudget func(userMessage string) int) anthropic.MessageNewParams {
 	var thinkingParam anthropic.ThinkingConfigParamUnion
 	lastMessage := messages[len(messages)-1]
 	isUser := lastMessage.Role == anthropic.MessageParamRoleUser
@@ -234,8 +319,8 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 				messageContent = m.OfText.Text
 			}
 		}
-		if messageContent != "" && a.options.thinkingBudget != nil {
-			if tokenBudget := a.options.thinkingBudget(messageContent); tokenBudget > 0 {
+		if messageContent != "" && thinkingBudget != nil {
+			if tokenBudget := thinkingBudget(messageContent); tokenBudget > 0 {
 				thinkingParam = anthropic.ThinkingConfigParamOfEnabled(int64(tokenBudget))
 				temperature = anthropic.Float(1)
 			}
@@ -268,6 +353,13 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 		}
 	}
 
+	system := anthropic.TextBlockParam{Text: systemMessage}
+	if a.options.cache.system {
+		system.CacheControl = anthropic.CacheControlEphemeralParam{
+			Type: "ephemeral",
+		}
+	}
+
 	return anthropic.MessageNewParams{
 		Model:       anthropic.Model(a.providerOptions.model.APIModel),
 		MaxTokens:   a.providerOptions.maxTokens,
@@ -275,18 +367,11 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 		Messages:    messages,
 		Tools:       tools,
 		Thinking:    thinkingParam,
-		System: []anthropic.TextBlockParam{
-			{
-				Text: systemMessage,
-				CacheControl: anthropic.CacheControlEphemeralParam{
-					Type: "ephemeral",
-				},
-			},
-		},
+		System:      []anthropic.TextBlockParam{system},
 	}
 }
 
-func (a *anthropicClient) send(ctx context.Context, messages []message.Message, tools []toolsPkg.BaseTool) (resposne *ProviderResponse, err error) {
+func (a *anthropicClient) send(ctx context.Context, state toolsPkg.RequestState, messages []message.Message, tools []toolsPkg.BaseTool) (resposne *ProviderResponse, err error) {
 	// Handle proactive token refresh for OAuth
 	if a.options.useOAuth && a.options.oauthCreds != nil {
 		if a.options.oauthCreds.IsTokenExpired() && a.options.oauthCreds.RefreshToken != "" {
@@ -311,23 +396,29 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 	}
 
 	// Use SDK for both OAuth and API key authentication
-	preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
+	anthropicTools := a.convertTools(tools)
+	if state.ResponseFormat != nil {
+		anthropicTools = append(anthropicTools, responseFormatTool(state.ResponseFormat))
+	}
+	preparedMessages := a.preparedMessages(a.convertMessages(messages), anthropicTools, a.thinkingBudget(state, messages))
 	cfg := config.Get()
 	if cfg.Debug {
 		jsonData, _ := json.Marshal(preparedMessages)
 		logging.Debug("Prepared messages", "messages", string(jsonData))
 	}
 
+	requestID := &requestIDRecorder{}
 	attempts := 0
 	for {
 		attempts++
 		anthropicResponse, err := a.client.Messages.New(
 			ctx,
 			preparedMessages,
+			option.WithMiddleware(requestID.middleware, chaos.ProviderMiddleware),
 		)
 		// If there is an error we are going to see if we can retry the call
 		if err != nil {
-			logging.Error("Error in Anthropic API call", "error", err)
+			logging.Error("Error in Anthropic API call", "error", err, "request_id", requestID.ID())
 
 			// Check for authentication errors (401)
 			if strings.Contains(err.Error(), "401") {
@@ -373,10 +464,11 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 						Usage: TokenUsage{},
 					}, nil
 				}
-				return nil, retryErr
+				return nil, requestID.wrapError(retryErr)
 			}
 			if retry {
 				logging.Warn(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries))
+				metrics.ProviderRetries.Inc(string(a.providerOptions.model.Provider))
 				select {
 				case <-ctx.Done():
 					return nil, ctx.Err()
@@ -384,25 +476,31 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 					continue
 				}
 			}
-			return nil, retryErr
+			return nil, requestID.wrapError(retryErr)
 		}
 
 		content := ""
 		for _, block := range anthropicResponse.Content {
-			if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-				content += text.Text
+			switch block := block.AsAny().(type) {
+			case anthropic.TextBlock:
+				content += block.Text
+			case anthropic.ToolUseBlock:
+				if block.Name == responseformat.ToolName && state.ResponseFormat != nil {
+					content += structuredResponse(state.ResponseFormat, block.Input)
+				}
 			}
 		}
 
 		return &ProviderResponse{
 			Content:   content,
 			ToolCalls: a.toolCalls(*anthropicResponse),
-			Usage:     a.usage(*anthropicResponse),
+			Usage:     a.usage(*anthropicResponse, preparedMessages),
+			RequestID: requestID.ID(),
 		}, nil
 	}
 }
 
-func (a *anthropicClient) stream(ctx context.Context, messages []message.Message, tools []toolsPkg.BaseTool) <-chan ProviderEvent {
+func (a *anthropicClient) stream(ctx context.Context, state toolsPkg.RequestState, messages []message.Message, tools []toolsPkg.BaseTool) <-chan ProviderEvent {
 	eventChan := make(chan ProviderEvent)
 
 	// Handle proactive token refresh for OAuth
@@ -429,7 +527,11 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 	}
 
 	// Use SDK for both OAuth and API key authentication
-	preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
+	anthropicTools := a.convertTools(tools)
+	if state.ResponseFormat != nil {
+		anthropicTools = append(anthropicTools, responseFormatTool(state.ResponseFormat))
+	}
+	preparedMessages := a.preparedMessages(a.convertMessages(messages), anthropicTools, a.thinkingBudget(state, messages))
 	cfg := config.Get()
 
 	if cfg.Debug {
@@ -456,12 +558,14 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 		return eventChan
 	}
 
+	requestID := &requestIDRecorder{}
 	go func() {
 		for {
 			attempts++
 			anthropicStream := a.client.Messages.NewStreaming(
 				ctx,
 				preparedMessages,
+				option.WithMiddleware(requestID.middleware, chaos.ProviderMiddleware),
 			)
 			accumulatedMessage := anthropic.Message{}
 
@@ -475,6 +579,9 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 			}
 
 			activeToolCalls := make(map[int]*message.ToolCall)
+			// The block the model gives a structured response in, streamed
+			// as content once complete
+			structuredBlock := -1
 			for anthropicStream.Next() {
 				event := anthropicStream.Current()
 				err := accumulatedMessage.Accumulate(event)
@@ -487,6 +594,9 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 				case anthropic.ContentBlockStartEvent:
 					if event.ContentBlock.Type == "text" {
 						eventChan <- ProviderEvent{Type: EventContentStart}
+					} else if event.ContentBlock.Type == "tool_use" && event.ContentBlock.Name == responseformat.ToolName && state.ResponseFormat != nil {
+						structuredBlock = int(event.Index)
+						eventChan <- ProviderEvent{Type: EventContentStart}
 					} else if event.ContentBlock.Type == "tool_use" {
 						toolCall := &message.ToolCall{
 							ID:       event.ContentBlock.ID,
@@ -518,13 +628,22 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 								ToolCall: &message.ToolCall{
 									ID:       toolCall.ID,
 									Finished: false,
-									Input:    event.Delta.JSON.PartialJSON.Raw(),
+									Input:    event.Delta.PartialJSON,
 								},
 							}
 						}
 					}
 				case anthropic.ContentBlockStopEvent:
-					if toolCall, exists := activeToolCalls[int(event.Index)]; exists {
+					if int(event.Index) == structuredBlock {
+						structuredBlock = -1
+						if block, ok := accumulatedMessage.Content[event.Index].AsAny().(anthropic.ToolUseBlock); ok {
+							eventChan <- ProviderEvent{
+								Type:    EventContentDelta,
+								Content: structuredResponse(state.ResponseFormat, block.Input),
+							}
+						}
+						eventChan <- ProviderEvent{Type: EventContentStop}
+					} else if toolCall, exists := activeToolCalls[int(event.Index)]; exists {
 						eventChan <- ProviderEvent{
 							Type: EventToolUseStop,
 							ToolCall: &message.ToolCall{
@@ -539,18 +658,30 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 				case anthropic.MessageStopEvent:
 					content := ""
 					for _, block := range accumulatedMessage.Content {
-						if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-							content += text.Text
+						switch block := block.AsAny().(type) {
+						case anthropic.TextBlock:
+							content += block.Text
+						case anthropic.ToolUseBlock:
+							if block.Name == responseformat.ToolName && state.ResponseFormat != nil {
+								content += structuredResponse(state.ResponseFormat, block.Input)
+							}
 						}
 					}
 
+					toolCalls := a.toolCalls(accumulatedMessage)
+					finishReason := a.finishReason(string(accumulatedMessage.StopReason))
+					if finishReason == message.FinishReasonToolUse && len(toolCalls) == 0 {
+						// The only tool called gave the structured response
+						finishReason = message.FinishReasonEndTurn
+					}
 					eventChan <- ProviderEvent{
 						Type: EventComplete,
 						Response: &ProviderResponse{
 							Content:      content,
-							ToolCalls:    a.toolCalls(accumulatedMessage),
-							Usage:        a.usage(accumulatedMessage),
-							FinishReason: a.finishReason(string(accumulatedMessage.StopReason)),
+							ToolCalls:    toolCalls,
+							Usage:        a.usage(accumulatedMessage, preparedMessages),
+							FinishReason: finishReason,
+							RequestID:    requestID.ID(),
 						},
 					}
 				}
@@ -594,14 +725,16 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 			}
 
 			// If there is an error we are going to see if we can retry the call
+			logging.Error("Error in Anthropic streaming call", "error", err, "request_id", requestID.ID())
 			retry, after, retryErr := a.shouldRetry(attempts, err)
 			if retryErr != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+				eventChan <- ProviderEvent{Type: EventError, Error: requestID.wrapError(retryErr)}
 				close(eventChan)
 				return
 			}
 			if retry {
 				logging.Warn(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries))
+				metrics.ProviderRetries.Inc(string(a.providerOptions.model.Provider))
 				select {
 				case <-ctx.Done():
 					// context cancelled
@@ -626,6 +759,10 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 }
 
 func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, error) {
+	if a.options.bedrockConfig != nil {
+		return shouldRetryBedrock(attempts, err)
+	}
+
 	var apierr *anthropic.Error
 	if !errors.As(err, &apierr) {
 		return false, 0, err
@@ -636,7 +773,7 @@ func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, err
 	}
 
 	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+		return false, 0, fmt.Errorf("%w for rate limit: %d retries: %w", ErrRetriesExhausted, maxRetries, err)
 	}
 
 	retryMs := 0
@@ -659,6 +796,10 @@ func (a *anthropicClient) toolCalls(msg anthropic.Message) []message.ToolCall {
 	for _, block := range msg.Content {
 		switch variant := block.AsAny().(type) {
 		case anthropic.ToolUseBlock:
+			if variant.Name == responseformat.ToolName {
+				// Given as content instead
+				continue
+			}
 			toolCall := message.ToolCall{
 				ID:       variant.ID,
 				Name:     variant.Name,
@@ -673,24 +814,35 @@ func (a *anthropicClient) toolCalls(msg anthropic.Message) []message.ToolCall {
 	return toolCalls
 }
 
-func (a *anthropicClient) usage(msg anthropic.Message) TokenUsage {
-	return TokenUsage{
+func (a *anthropicClient) usage(msg anthropic.Message, params anthropic.MessageNewParams) TokenUsage {
+	usage := TokenUsage{
 		InputTokens:         msg.Usage.InputTokens,
 		OutputTokens:        msg.Usage.OutputTokens,
 		CacheCreationTokens: msg.Usage.CacheCreationInputTokens,
 		CacheReadTokens:     msg.Usage.CacheReadInputTokens,
 	}
+	if budget := params.Thinking.GetBudgetTokens(); budget != nil {
+		usage.ThinkingBudget = *budget
+	}
+	return usage
 }
 
-func WithAnthropicBedrock(useBedrock bool) AnthropicOption {
+// WithAnthropicBedrock sends requests to Amazon Bedrock using cfg's region and credentials
+func WithAnthropicBedrock(cfg aws.Config) AnthropicOption {
 	return func(options *anthropicOptions) {
-		options.useBedrock = useBedrock
+		options.bedrockConfig = &cfg
 	}
 }
 
 func WithAnthropicDisableCache() AnthropicOption {
+	return WithAnthropicCacheBreakpoints(false, false, 0)
+}
+
+// WithAnthropicCacheBreakpoints sets which parts of the prompt are cached: the
+// system prompt, the tool definitions and the given number of latest messages.
+func WithAnthropicCacheBreakpoints(system, tools bool, messages int) AnthropicOption {
 	return func(options *anthropicOptions) {
-		options.disableCache = true
+		options.cache = anthropicCache{system: system, tools: tools, messages: messages}
 	}
 }
 
@@ -727,9 +879,11 @@ func DefaultThinkingBudgetFn(s string) int {
 	return 0
 }
 
-func WithAnthropicThinkingBudgetFn(fn func(string) int) AnthropicOption {
+// WithAnthropicThinking lets the model think with the budget th gives each
+// request.
+func WithAnthropicThinking(th config.Thinking) AnthropicOption {
 	return func(options *anthropicOptions) {
-		options.thinkingBudget = fn
+		options.thinking = &th
 	}
 }
 
@@ -763,10 +917,10 @@ func (a *anthropicClient) recreateClient() {
 		clientOptions = append(clientOptions, option.WithHeader("anthropic-beta", betaHeader))
 	}
 
-	if a.options.useBedrock {
-		clientOptions = append(clientOptions, bedrock.WithLoadDefaultConfig(context.Background()))
+	if a.options.bedrockConfig != nil {
+		clientOptions = append(clientOptions, bedrockRequestOptions(*a.options.bedrockConfig)...)
 	}
 
-	clientOptions = append(clientOptions, option.WithRequestTimeout(90*time.Second))
+	clientOptions = append(clientOptions, option.WithRequestTimeout(90*time.Second), option.WithHTTPClient(sharedHTTPClient))
 	a.client = anthropic.NewClient(clientOptions...)
 }
@@ -0,0 +1,246 @@
+package provider
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/models"
+	"mix/internal/logging"
+)
+
+// StoredAPIKey is an API key kept in the encrypted credential store rather
+// than the config file or the process environment.
+type StoredAPIKey struct {
+	Key       string `json:"key"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// AuthMethod is how a provider authenticates. Anthropic and OpenAI prefer
+// OAuth, then any provider uses a stored key, a config file key and finally
+// its environment variable.
+type AuthMethod string
+
+const (
+	AuthOAuth     AuthMethod = "oauth"
+	AuthStoredKey AuthMethod = "stored"
+	AuthConfigKey AuthMethod = "config"
+	AuthEnvKey    AuthMethod = "env"
+	// AuthCloud is AWS credentials for Bedrock, Google Cloud credentials for
+	// Vertex AI and Entra ID for Azure
+	AuthCloud AuthMethod = "cloud"
+	AuthNone  AuthMethod = "none"
+)
+
+// AuthStatus reports how a provider authenticates and which credentials it
+// could fall back to.
+type AuthStatus struct {
+	Provider   models.ModelProvider
+	Configured bool
+	Disabled   bool
+	Method     AuthMethod
+	OAuth      bool
+	StoredKey  bool
+	ConfigKey  bool
+	EnvKey     bool
+	// EnvVar is the variable the provider reads its key from, if any
+	EnvVar string
+	// KeyHint is the end of the key in use, to tell keys apart
+	KeyHint string
+	// UpdatedAt is when the stored key was set
+	UpdatedAt int64
+}
+
+func init() {
+	config.SetAPIKeyStore(loadStoredAPIKeys)
+}
+
+func loadStoredAPIKeys() (map[models.ModelProvider]string, error) {
+	storage, err := NewCredentialStorage()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := storage.APIKeys()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[models.ModelProvider]string, len(stored))
+	for provider, apiKey := range stored {
+		keys[models.ModelProvider(provider)] = apiKey.Key
+	}
+	return keys, nil
+}
+
+// StoreAPIKey saves a provider's API key, replacing any stored before.
+func (cs *CredentialStorage) StoreAPIKey(provider string, apiKey string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	store, err := cs.loadCredentialStore()
+	if err != nil {
+		return fmt.Errorf("failed to load credential store: %w", err)
+	}
+	store.APIKeys[provider] = StoredAPIKey{Key: apiKey, UpdatedAt: time.Now().Unix()}
+	if err := cs.saveCredentialStore(store); err != nil {
+		return fmt.Errorf("failed to save credential store: %w", err)
+	}
+
+	logging.Info("API key stored for provider", "provider", provider)
+	return nil
+}
+
+// ClearAPIKey removes a provider's stored API key.
+func (cs *CredentialStorage) ClearAPIKey(provider string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	store, err := cs.loadCredentialStore()
+	if err != nil {
+		return fmt.Errorf("failed to load credential store: %w", err)
+	}
+	if _, ok := store.APIKeys[provider]; !ok {
+		return nil
+	}
+	delete(store.APIKeys, provider)
+	if err := cs.saveCredentialStore(store); err != nil {
+		return fmt.Errorf("failed to save credential store: %w", err)
+	}
+
+	logging.Info("API key cleared for provider", "provider", provider)
+	return nil
+}
+
+// APIKeys returns the stored API keys by provider.
+func (cs *CredentialStorage) APIKeys() (map[string]StoredAPIKey, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	store, err := cs.loadCredentialStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credential store: %w", err)
+	}
+	return store.APIKeys, nil
+}
+
+// SetAPIKey stores a key-based provider's API key and applies it to the
+// configuration, or clears the stored key when apiKey is empty so the config
+// file or environment key applies again.
+func SetAPIKey(provider models.ModelProvider, apiKey string) error {
+	if config.APIKeyEnv(provider) == "" {
+		return fmt.Errorf("provider %s does not authenticate with an API key", provider)
+	}
+	storage, err := NewCredentialStorage()
+	if err != nil {
+		return err
+	}
+	if apiKey == "" {
+		err = storage.ClearAPIKey(string(provider))
+	} else {
+		err = storage.StoreAPIKey(string(provider), apiKey)
+	}
+	if err != nil {
+		return err
+	}
+	config.SetProviderAPIKey(provider, apiKey)
+	return nil
+}
+
+// AuthStatuses reports the authentication of every provider that is
+// configured or could be with a key or cloud credentials, sorted by name.
+// Without a loaded configuration, only the credential store and environment
+// are reported.
+func AuthStatuses() ([]AuthStatus, error) {
+	storage, err := NewCredentialStorage()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := storage.APIKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var configured map[models.ModelProvider]config.Provider
+	if cfg := config.Get(); cfg != nil {
+		configured = cfg.Providers
+	}
+	providers := make(map[models.ModelProvider]bool)
+	for provider := range configured {
+		providers[provider] = true
+	}
+	for _, provider := range []models.ModelProvider{
+		models.ProviderAnthropic, models.ProviderOpenAI, models.ProviderGemini, models.ProviderGROQ,
+		models.ProviderAzure, models.ProviderOpenRouter, models.ProviderXAI,
+		models.ProviderBedrock, models.ProviderVertexAI,
+	} {
+		providers[provider] = true
+	}
+
+	statuses := make([]AuthStatus, 0, len(providers))
+	for _, provider := range slices.Sorted(maps.Keys(providers)) {
+		providerCfg, isConfigured := configured[provider]
+		status := AuthStatus{
+			Provider:   provider,
+			Configured: isConfigured,
+			Disabled:   providerCfg.Disabled,
+			OAuth:      hasOAuth(storage, provider),
+			EnvVar:     config.APIKeyEnv(provider),
+		}
+		storedKey, hasStored := stored[string(provider)]
+		status.StoredKey = hasStored
+		status.UpdatedAt = storedKey.UpdatedAt
+
+		var envKey string
+		if status.EnvVar != "" {
+			envKey = os.Getenv(status.EnvVar)
+		}
+		configKey := config.ConfiguredAPIKey(provider)
+		// Keys picked up from the environment are copied into the config
+		status.ConfigKey = configKey != "" && configKey != envKey
+		status.EnvKey = envKey != ""
+
+		var inUse string
+		switch {
+		case status.OAuth:
+			status.Method = AuthOAuth
+		case hasStored:
+			status.Method, inUse = AuthStoredKey, storedKey.Key
+		case status.ConfigKey:
+			status.Method, inUse = AuthConfigKey, configKey
+		case status.EnvKey:
+			status.Method, inUse = AuthEnvKey, envKey
+		case config.HasCloudCredentials(provider) || provider == models.ProviderAzure && providerCfg.EntraID:
+			status.Method = AuthCloud
+		default:
+			status.Method = AuthNone
+		}
+		status.KeyHint = KeyHint(inUse)
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// hasOAuth reports whether a provider has OAuth credentials that are valid or
+// can be refreshed.
+func hasOAuth(storage *CredentialStorage, provider models.ModelProvider) bool {
+	switch provider {
+	case models.ProviderAnthropic:
+		creds, err := storage.GetOAuthCredentials("anthropic")
+		return err == nil && creds != nil && (!creds.IsTokenExpired() || creds.RefreshToken != "")
+	case models.ProviderOpenAI:
+		creds, err := storage.GetOpenAICredentials("openai")
+		return err == nil && creds != nil && creds.APIKey != "" && (!creds.IsTokenExpired() || creds.RefreshToken != "")
+	}
+	return false
+}
+
+// KeyHint returns the last four characters of a key, enough to recognize it
+// without revealing it.
+func KeyHint(apiKey string) string {
+	if len(apiKey) < 12 {
+		return ""
+	}
+	return "..." + apiKey[len(apiKey)-4:]
+}
@@ -10,6 +10,15 @@ import (
 	"github.com/openai/openai-go/option"
 )
 
+type azureOptions struct {
+	endpoint   string
+	apiVersion string
+	deployment string
+	entraID    bool
+}
+
+type AzureOption func(*azureOptions)
+
 type azureClient struct {
 	*openaiClient
 }
@@ -17,35 +26,87 @@ type azureClient struct {
 type AzureClient ProviderClient
 
 func newAzureClient(opts providerClientOptions) (AzureClient, error) {
+	azureOpts := azureOptions{
+		endpoint:   os.Getenv("AZURE_OPENAI_ENDPOINT"),    // ex: https://foo.openai.azure.com
+		apiVersion: os.Getenv("AZURE_OPENAI_API_VERSION"), // ex: 2025-04-01-preview
+	}
+	for _, o := range opts.azureOptions {
+		o(&azureOpts)
+	}
 
-	endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")      // ex: https://foo.openai.azure.com
-	apiVersion := os.Getenv("AZURE_OPENAI_API_VERSION") // ex: 2025-04-01-preview
-
-	if endpoint == "" {
-		return nil, fmt.Errorf("Azure provider requires AZURE_OPENAI_ENDPOINT environment variable to be set")
+	if azureOpts.endpoint == "" {
+		return nil, fmt.Errorf("Azure provider requires providers.azure.endpoint or the AZURE_OPENAI_ENDPOINT environment variable")
 	}
-	if apiVersion == "" {
-		return nil, fmt.Errorf("Azure provider requires AZURE_OPENAI_API_VERSION environment variable to be set")
+	if azureOpts.apiVersion == "" {
+		return nil, fmt.Errorf("Azure provider requires providers.azure.apiVersion or the AZURE_OPENAI_API_VERSION environment variable")
 	}
 
 	reqOpts := []option.RequestOption{
-		azure.WithEndpoint(endpoint, apiVersion),
+		azure.WithEndpoint(azureOpts.endpoint, azureOpts.apiVersion),
+		option.WithHTTPClient(sharedHTTPClient),
 	}
 
-	if opts.apiKey != "" || os.Getenv("AZURE_OPENAI_API_KEY") != "" {
-		key := opts.apiKey
-		if key == "" {
-			key = os.Getenv("AZURE_OPENAI_API_KEY")
-		}
+	key := opts.apiKey
+	if key == "" {
+		key = os.Getenv("AZURE_OPENAI_API_KEY")
+	}
+	if key != "" && !azureOpts.entraID {
 		reqOpts = append(reqOpts, azure.WithAPIKey(key))
-	} else if cred, err := azidentity.NewDefaultAzureCredential(nil); err == nil {
+	} else {
+		// Entra ID: environment, workload identity, managed identity or az login
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("Azure provider has no API key and Entra ID credentials are unavailable: %w", err)
+		}
 		reqOpts = append(reqOpts, azure.WithTokenCredential(cred))
 	}
 
+	// Azure routes requests by deployment name, sent in place of the model name
+	if azureOpts.deployment != "" {
+		opts.model.APIModel = azureOpts.deployment
+	}
+
+	openaiOpts := openaiOptions{
+		reasoningEffort: "medium",
+	}
+	for _, o := range opts.openaiOptions {
+		o(&openaiOpts)
+	}
+
 	base := &openaiClient{
 		providerOptions: opts,
+		options:         openaiOpts,
 		client:          openai.NewClient(reqOpts...),
 	}
 
 	return &azureClient{openaiClient: base}, nil
 }
+
+// WithAzureEndpoint overrides AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_VERSION.
+// Empty values keep the environment's.
+func WithAzureEndpoint(endpoint, apiVersion string) AzureOption {
+	return func(options *azureOptions) {
+		if endpoint != "" {
+			options.endpoint = endpoint
+		}
+		if apiVersion != "" {
+			options.apiVersion = apiVersion
+		}
+	}
+}
+
+// WithAzureDeployment sends requests to the named deployment instead of one
+// named after the model.
+func WithAzureDeployment(deployment string) AzureOption {
+	return func(options *azureOptions) {
+		options.deployment = deployment
+	}
+}
+
+// WithAzureEntraID authenticates with Entra ID tokens even when an API key is
+// available.
+func WithAzureEntraID(entraID bool) AzureOption {
+	return func(options *azureOptions) {
+		options.entraID = entraID
+	}
+}
@@ -1,18 +1,40 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"mix/internal/llm/tools"
+	"mix/internal/logging"
 	"mix/internal/message"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/bedrock"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultBedrockRegion = "us-east-1"
+	// bedrockMaxRetries is higher than maxRetries since Bedrock throttles per
+	// account and region, and quotas recover within seconds
+	bedrockMaxRetries = 8
+	// bedrockMaxBackoffMs caps the exponential backoff between throttled attempts
+	bedrockMaxBackoffMs = 30000
 )
 
 type bedrockOptions struct {
-	// Bedrock specific options can be added here
+	region              string
+	profile             string
+	inferenceProfileARN string
 }
 
 type BedrockOption func(*bedrockOptions)
@@ -21,80 +43,194 @@ type bedrockClient struct {
 	providerOptions providerClientOptions
 	options         bedrockOptions
 	childProvider   ProviderClient
+	err             error
 }
 
 type BedrockClient ProviderClient
 
 func newBedrockClient(opts providerClientOptions) BedrockClient {
 	bedrockOpts := bedrockOptions{}
-	// Apply bedrock specific options if they are added in the future
-
-	// Get AWS region from environment
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = os.Getenv("AWS_DEFAULT_REGION")
+	for _, o := range opts.bedrockOptions {
+		o(&bedrockOpts)
+	}
+	client := &bedrockClient{
+		providerOptions: opts,
+		options:         bedrockOpts,
 	}
 
-	if region == "" {
-		region = "us-east-1" // default region
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if bedrockOpts.region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(bedrockOpts.region))
 	}
-	if len(region) < 2 {
-		return &bedrockClient{
-			providerOptions: opts,
-			options:         bedrockOpts,
-			childProvider:   nil, // Will cause an error when used
-		}
+	if bedrockOpts.profile != "" {
+		loadOpts = append(loadOpts, awsconfig.WithSharedConfigProfile(bedrockOpts.profile))
 	}
-
-	// Prefix the model name with region
-	regionPrefix := region[:2]
-	modelName := opts.model.APIModel
-	opts.model.APIModel = fmt.Sprintf("%s.%s", regionPrefix, modelName)
-
-	// Determine which provider to use based on the model
-	if strings.Contains(string(opts.model.APIModel), "anthropic") {
-		// Create Anthropic client with Bedrock configuration
-		anthropicOpts := opts
-		anthropicOpts.anthropicOptions = append(anthropicOpts.anthropicOptions,
-			WithAnthropicBedrock(true),
-			WithAnthropicDisableCache(),
-		)
-		return &bedrockClient{
-			providerOptions: opts,
-			options:         bedrockOpts,
-			childProvider:   newAnthropicClient(anthropicOpts),
-		}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOpts...)
+	if err != nil {
+		client.err = fmt.Errorf("failed to load AWS config for bedrock: %w", err)
+		return client
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = defaultBedrockRegion
 	}
 
-	// Return client with nil childProvider if model is not supported
-	// This will cause an error when used
-	return &bedrockClient{
-		providerOptions: opts,
-		options:         bedrockOpts,
-		childProvider:   nil,
+	if !strings.HasPrefix(opts.model.APIModel, "anthropic.") {
+		client.err = fmt.Errorf("unsupported model for bedrock provider: %s", opts.model.APIModel)
+		return client
 	}
+
+	opts.model.APIModel = bedrockModelID(opts.model.APIModel, awsCfg.Region, bedrockOpts.inferenceProfileARN)
+	logging.Info("Initialized Bedrock client", "region", awsCfg.Region, "model", opts.model.APIModel)
+
+	anthropicOpts := opts
+	anthropicOpts.anthropicOptions = append(anthropicOpts.anthropicOptions,
+		WithAnthropicBedrock(awsCfg),
+		// Bedrock requests have only ever cached the system prompt
+		WithAnthropicCacheBreakpoints(true, false, 0),
+	)
+	client.childProvider = newAnthropicClient(anthropicOpts)
+	return client
 }
 
-func (b *bedrockClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
-	if b.childProvider == nil {
-		return nil, errors.New("unsupported model for bedrock provider")
+func (b *bedrockClient) send(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	if b.err != nil {
+		return nil, b.err
 	}
-	return b.childProvider.send(ctx, messages, tools)
+	return b.childProvider.send(ctx, state, messages, tools)
 }
 
-func (b *bedrockClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+func (b *bedrockClient) stream(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
 	eventChan := make(chan ProviderEvent)
 
-	if b.childProvider == nil {
+	if b.err != nil {
 		go func() {
 			eventChan <- ProviderEvent{
 				Type:  EventError,
-				Error: errors.New("unsupported model for bedrock provider"),
+				Error: b.err,
 			}
 			close(eventChan)
 		}()
 		return eventChan
 	}
 
-	return b.childProvider.stream(ctx, messages, tools)
+	return b.childProvider.stream(ctx, state, messages, tools)
+}
+
+// bedrockModelID resolves the model ID to invoke. A configured inference
+// profile ARN wins; otherwise the model is called through the cross-region
+// inference profile for the region's geography, which newer Claude models
+// require.
+func bedrockModelID(apiModel, region, inferenceProfileARN string) string {
+	if inferenceProfileARN != "" {
+		return inferenceProfileARN
+	}
+	prefix := crossRegionPrefix(region)
+	if prefix == "" {
+		return apiModel
+	}
+	return prefix + "." + apiModel
+}
+
+func crossRegionPrefix(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "us-gov"
+	case strings.HasPrefix(region, "us-"):
+		return "us"
+	case strings.HasPrefix(region, "eu-"):
+		return "eu"
+	case strings.HasPrefix(region, "ap-"):
+		return "apac"
+	}
+	return ""
+}
+
+// bedrockRequestOptions routes Anthropic SDK requests to Bedrock with cfg's
+// region and credentials.
+func bedrockRequestOptions(cfg aws.Config) []option.RequestOption {
+	return []option.RequestOption{
+		// Must run before the SDK's Bedrock middleware so the escaped path is signed
+		option.WithMiddleware(escapeBedrockModelPath),
+		bedrock.WithConfig(cfg),
+	}
+}
+
+// escapeBedrockModelPath escapes ARN model IDs in the invoke path. The SDK
+// puts the model into the path verbatim, which breaks on the slash in
+// inference profile ARNs. Setting Opaque takes precedence over the path the
+// SDK writes, both on the wire and when signing.
+func escapeBedrockModelPath(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if r.Method != http.MethodPost || !bedrock.DefaultEndpoints[r.URL.Path] || r.Body == nil {
+		return next(r)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	model := gjson.GetBytes(body, "model").String()
+	if strings.HasPrefix(model, "arn:") {
+		action := "invoke"
+		if gjson.GetBytes(body, "stream").Bool() {
+			action = "invoke-with-response-stream"
+		}
+		r.URL.Opaque = fmt.Sprintf("/model/%s/%s", url.PathEscape(model), action)
+	}
+	return next(r)
+}
+
+// shouldRetryBedrock retries throttling and transient Bedrock errors with
+// capped exponential backoff, honoring Retry-After when present.
+func shouldRetryBedrock(attempts int, err error) (bool, int64, error) {
+	var apierr *anthropic.Error
+	if !errors.As(err, &apierr) {
+		return false, 0, err
+	}
+
+	switch apierr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable, 529:
+	default:
+		return false, 0, err
+	}
+
+	if attempts > bedrockMaxRetries {
+		return false, 0, fmt.Errorf("%w for bedrock throttling: %d retries: %w", ErrRetriesExhausted, bedrockMaxRetries, err)
+	}
+
+	backoffMs := 1000 * (1 << (attempts - 1))
+	if backoffMs > bedrockMaxBackoffMs {
+		backoffMs = bedrockMaxBackoffMs
+	}
+	retryMs := backoffMs + int(float64(backoffMs)*0.2)
+	if apierr.Response != nil {
+		if values := apierr.Response.Header.Values("Retry-After"); len(values) > 0 {
+			if _, err := fmt.Sscanf(values[0], "%d", &retryMs); err == nil {
+				retryMs = retryMs * 1000
+			}
+		}
+		logging.Warn("Bedrock request throttled, retrying", "status", apierr.StatusCode, "errorType", apierr.Response.Header.Get("X-Amzn-Errortype"), "attempt", attempts, "retryMs", retryMs)
+	}
+	return true, int64(retryMs), nil
+}
+
+func WithBedrockRegion(region string) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.region = region
+	}
+}
+
+func WithBedrockProfile(profile string) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.profile = profile
+	}
+}
+
+// WithBedrockInferenceProfile invokes the given inference profile ARN instead
+// of the model's own ID, e.g. an application inference profile for cost tracking.
+func WithBedrockInferenceProfile(arn string) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.inferenceProfileARN = arn
+	}
 }
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRetryBedrock(t *testing.T) {
+	throttled := &anthropic.Error{StatusCode: http.StatusTooManyRequests}
+	invalid := &anthropic.Error{StatusCode: http.StatusBadRequest}
+	other := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		attempts  int
+		err       error
+		retry     bool
+		exhausted bool
+	}{
+		{name: "throttling is retried", attempts: 1, err: throttled, retry: true},
+		{name: "server errors are retried", attempts: 1, err: &anthropic.Error{StatusCode: http.StatusServiceUnavailable}, retry: true},
+		{name: "invalid requests aren't retried", attempts: 1, err: invalid},
+		{name: "other errors aren't retried", attempts: 1, err: other},
+		{name: "retries run out", attempts: bedrockMaxRetries + 1, err: throttled, exhausted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, after, err := shouldRetryBedrock(tt.attempts, tt.err)
+			assert.Equal(t, tt.retry, retry)
+			if tt.retry {
+				assert.NoError(t, err)
+				assert.Positive(t, after)
+				return
+			}
+			// The last error is kept, whether or not the retries ran out
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.exhausted, errors.Is(err, ErrRetriesExhausted))
+		})
+	}
+}
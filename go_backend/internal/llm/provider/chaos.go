@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+
+	"mix/internal/chaos"
+)
+
+// truncateStream cuts some streams short at the stream_truncate rate, as a
+// dropped connection would, ending them with an error before completion.
+func truncateStream(ctx context.Context, start func(context.Context) <-chan ProviderEvent) <-chan ProviderEvent {
+	if !chaos.Should(chaos.StreamTruncate) {
+		return start(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	inner := start(ctx)
+	cutAfter := chaos.Intn(20)
+	out := make(chan ProviderEvent)
+	go func() {
+		defer close(out)
+		defer func() {
+			cancel()
+			for range inner {
+			}
+		}()
+
+		for i := 0; ; i++ {
+			event, ok := <-inner
+			if !ok {
+				return
+			}
+			if i >= cutAfter || event.Type == EventComplete || event.Type == EventError {
+				break
+			}
+			out <- event
+		}
+		out <- ProviderEvent{Type: EventError, Error: chaos.Error(chaos.StreamTruncate)}
+	}()
+	return out
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+	"github.com/tidwall/gjson"
+	"google.golang.org/genai"
+)
+
+// openaiContextLimitCode is the error code OpenAI rejects over-long requests
+// with
+const openaiContextLimitCode = "context_length_exceeded"
+
+// Anthropic, Bedrock and Gemini have no dedicated code for over-long requests,
+// and not every OpenAI-compatible API sets the OpenAI one, so their invalid
+// request errors are matched by the messages each provider sends
+var (
+	anthropicContextLimitPhrases = []string{"prompt is too long", "input is too long", "too many input tokens"}
+	openaiContextLimitPhrases    = []string{"maximum context length", "context length", "context window", "too many tokens"}
+	geminiContextLimitPhrases    = []string{"input token count", "exceeds the maximum number of tokens"}
+)
+
+// IsContextTooLong reports whether err means the request's messages don't fit
+// the model's context window, so a shorter history may succeed. Only provider
+// API errors are considered.
+func IsContextTooLong(err error) bool {
+	if err == nil {
+		return false
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicContextTooLong(anthropicErr)
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		if openaiErr.Code == openaiContextLimitCode {
+			return true
+		}
+		return openaiErr.StatusCode == http.StatusBadRequest && containsPhrase(openaiErr.Message, openaiContextLimitPhrases)
+	}
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code == http.StatusBadRequest && containsPhrase(geminiErr.Message, geminiContextLimitPhrases)
+	}
+	return false
+}
+
+// anthropicContextTooLong matches the invalid request errors of Anthropic, and
+// the validation errors of Bedrock, which answers through the same client.
+func anthropicContextTooLong(err *anthropic.Error) bool {
+	if err.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	raw := err.RawJSON()
+	invalidRequest := gjson.Get(raw, "error.type").String() == "invalid_request_error"
+	if err.Response != nil && strings.HasPrefix(err.Response.Header.Get("X-Amzn-ErrorType"), "ValidationException") {
+		invalidRequest = true
+	}
+	if !invalidRequest {
+		return false
+	}
+	message := gjson.Get(raw, "error.message").String()
+	if message == "" {
+		message = gjson.Get(raw, "message").String()
+	}
+	return containsPhrase(message, anthropicContextLimitPhrases)
+}
+
+func containsPhrase(message string, phrases []string) bool {
+	message = strings.ToLower(message)
+	for _, phrase := range phrases {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func anthropicError(t *testing.T, status int, header http.Header, body string) *anthropic.Error {
+	t.Helper()
+	err := &anthropic.Error{}
+	require.NoError(t, err.UnmarshalJSON([]byte(body)))
+	err.StatusCode = status
+	err.Response = &http.Response{StatusCode: status, Header: header}
+	return err
+}
+
+func TestIsContextTooLong(t *testing.T) {
+	bedrockValidation := http.Header{"X-Amzn-Errortype": {"ValidationException:http://internal.amazon.com/coral/com.amazon.bedrock/"}}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "anthropic prompt too long",
+			err:      anthropicError(t, http.StatusBadRequest, nil, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`),
+			expected: true,
+		},
+		{
+			name: "wrapped anthropic error",
+			err: fmt.Errorf("%w for rate limit: %w", ErrRetriesExhausted,
+				anthropicError(t, http.StatusBadRequest, nil, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`)),
+			expected: true,
+		},
+		{
+			name:     "anthropic invalid request about something else",
+			err:      anthropicError(t, http.StatusBadRequest, nil, `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: must be at most 8192"}}`),
+			expected: false,
+		},
+		{
+			name:     "anthropic phrase in another error type",
+			err:      anthropicError(t, http.StatusBadRequest, nil, `{"type":"error","error":{"type":"api_error","message":"prompt is too long"}}`),
+			expected: false,
+		},
+		{
+			name:     "bedrock validation error",
+			err:      anthropicError(t, http.StatusBadRequest, bedrockValidation, `{"message":"Input is too long for requested model."}`),
+			expected: true,
+		},
+		{
+			name:     "bedrock message without validation error type",
+			err:      anthropicError(t, http.StatusBadRequest, nil, `{"message":"Input is too long for requested model."}`),
+			expected: false,
+		},
+		{
+			name:     "openai context length code",
+			err:      &openai.Error{StatusCode: http.StatusBadRequest, Code: "context_length_exceeded", Message: "This model's maximum context length is 128000 tokens."},
+			expected: true,
+		},
+		{
+			name:     "openai-compatible error without the code",
+			err:      &openai.Error{StatusCode: http.StatusBadRequest, Message: "This endpoint's maximum context length is 65536 tokens."},
+			expected: true,
+		},
+		{
+			name:     "openai rate limit mentioning tokens",
+			err:      &openai.Error{StatusCode: http.StatusTooManyRequests, Code: "rate_limit_exceeded", Message: "Rate limit reached: too many tokens per min"},
+			expected: false,
+		},
+		{
+			name:     "gemini token count",
+			err:      genai.APIError{Code: http.StatusBadRequest, Status: "INVALID_ARGUMENT", Message: "The input token count (1200000) exceeds the maximum number of tokens allowed (1048576)."},
+			expected: true,
+		},
+		{
+			name:     "gemini other invalid argument",
+			err:      genai.APIError{Code: http.StatusBadRequest, Status: "INVALID_ARGUMENT", Message: "Unsupported MIME type"},
+			expected: false,
+		},
+		{
+			name:     "plain error mentioning the context window",
+			err:      errors.New("tool output exceeds the context window"),
+			expected: false,
+		},
+		{
+			name:     "nil",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsContextTooLong(tt.err))
+		})
+	}
+}
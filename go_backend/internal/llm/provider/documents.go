@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/llm/models"
+	"mix/internal/logging"
+	"mix/internal/message"
+)
+
+const (
+	// maxDocumentText bounds the text a document is replaced by
+	maxDocumentText = 512 << 10
+	// pdfExtractTimeout bounds a pdftotext run
+	pdfExtractTimeout = 30 * time.Second
+	// maxExtractedDocuments bounds the extracted texts kept, so a history
+	// resent every turn is extracted once
+	maxExtractedDocuments = 32
+)
+
+// documentSupport is which documents a provider's API takes as they are
+type documentSupport struct {
+	pdf  bool
+	text bool
+}
+
+// providerDocuments are the providers taking documents: Anthropic's document
+// blocks take PDFs and plain text, OpenAI's file inputs PDFs, and Gemini's
+// inline data both
+var providerDocuments = map[models.ModelProvider]documentSupport{
+	models.ProviderAnthropic: {pdf: true, text: true},
+	models.ProviderBedrock:   {pdf: true, text: true},
+	models.ProviderGemini:    {pdf: true, text: true},
+	models.ProviderVertexAI:  {pdf: true, text: true},
+	models.ProviderOpenAI:    {pdf: true},
+	models.ProviderAzure:     {pdf: true},
+}
+
+var extractedDocuments = struct {
+	sync.Mutex
+	texts map[[sha256.Size]byte]string
+	order [][sha256.Size]byte
+}{texts: make(map[[sha256.Size]byte]string)}
+
+// supportsDocument reports whether model takes a document of mimeType as it
+// is.
+func supportsDocument(model models.Model, mimeType string) bool {
+	if !model.SupportsAttachments {
+		return false
+	}
+	support := providerDocuments[model.Provider]
+	if message.IsPDFType(mimeType) {
+		return support.pdf
+	}
+	return support.text
+}
+
+// inlineDocuments returns messages with the documents model doesn't take
+// replaced by their text, appended to the message's text as clients send
+// only its first text part. Messages without such documents are kept as they
+// are.
+func inlineDocuments(model models.Model, messages []message.Message) []message.Message {
+	var inlined []message.Message
+	for i, msg := range messages {
+		var texts []string
+		parts := make([]message.ContentPart, 0, len(msg.Parts))
+		for _, part := range msg.Parts {
+			if content, ok := part.(message.BinaryContent); ok && content.IsDocument() && !supportsDocument(model, content.MIMEType) {
+				texts = append(texts, documentText(content))
+				continue
+			}
+			parts = append(parts, part)
+		}
+		if len(texts) == 0 {
+			continue
+		}
+		text := msg.Content().Text
+		if text != "" {
+			texts = append([]string{text}, texts...)
+		}
+		merged := false
+		for j, part := range parts {
+			if _, ok := part.(message.TextContent); ok {
+				parts[j] = message.TextContent{Text: strings.Join(texts, "\n\n")}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			parts = append(parts, message.TextContent{Text: strings.Join(texts, "\n\n")})
+		}
+		if inlined == nil {
+			inlined = append([]message.Message(nil), messages...)
+		}
+		msg.Parts = parts
+		inlined[i] = msg
+	}
+	if inlined == nil {
+		return messages
+	}
+	return inlined
+}
+
+// documentText returns the text standing in for a document: its content in a
+// document tag, or a note of why it couldn't be read.
+func documentText(content message.BinaryContent) string {
+	name := content.Name()
+	text, err := extractDocumentText(content)
+	if err != nil {
+		logging.Warn("Failed to extract document text", "document", name, "error", err)
+		return fmt.Sprintf("[Attached document %s could not be read: %v]", name, err)
+	}
+	if len(text) > maxDocumentText {
+		text = strings.ToValidUTF8(text[:maxDocumentText], "") + fmt.Sprintf("\n[document truncated at %d bytes]", maxDocumentText)
+	}
+	return fmt.Sprintf("<document name=%q>\n%s\n</document>", name, text)
+}
+
+func extractDocumentText(content message.BinaryContent) (string, error) {
+	if message.IsTextDocumentType(content.MIMEType) {
+		return strings.ToValidUTF8(string(content.Data), "\uFFFD"), nil
+	}
+
+	key := sha256.Sum256(content.Data)
+	extractedDocuments.Lock()
+	text, ok := extractedDocuments.texts[key]
+	extractedDocuments.Unlock()
+	if ok {
+		return text, nil
+	}
+
+	text, err := extractPDFText(content.Data)
+	if err != nil {
+		return "", err
+	}
+	extractedDocuments.Lock()
+	defer extractedDocuments.Unlock()
+	if _, ok := extractedDocuments.texts[key]; !ok {
+		if len(extractedDocuments.order) == maxExtractedDocuments {
+			delete(extractedDocuments.texts, extractedDocuments.order[0])
+			extractedDocuments.order = extractedDocuments.order[1:]
+		}
+		extractedDocuments.texts[key] = text
+		extractedDocuments.order = append(extractedDocuments.order, key)
+	}
+	return text, nil
+}
+
+// extractPDFText returns the text of a PDF, read by pdftotext from poppler.
+func extractPDFText(data []byte) (string, error) {
+	path, err := exec.LookPath("pdftotext")
+	if err != nil {
+		return "", errors.New("extracting PDF text needs pdftotext on the PATH")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pdfExtractTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "-layout", "-enc", "UTF-8", "-q", "-", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if details := strings.TrimSpace(stderr.String()); details != "" {
+			return "", fmt.Errorf("pdftotext failed: %s", details)
+		}
+		return "", fmt.Errorf("pdftotext failed: %w", err)
+	}
+	text := strings.TrimSpace(strings.ToValidUTF8(stdout.String(), "\uFFFD"))
+	if text == "" {
+		return "", errors.New("the PDF has no text layer")
+	}
+	return text, nil
+}
@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"mix/internal/llm/models"
+	"mix/internal/llm/tools"
+	"mix/internal/logging"
+	"mix/internal/message"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+)
+
+// ErrRetriesExhausted is returned once a provider keeps rate limiting or
+// failing after all retries.
+var ErrRetriesExhausted = errors.New("maximum retry attempts reached")
+
+// fallbackProvider tries each provider of a chain in turn, moving on when one
+// is rate limited or failing after its own retries. A stream only falls back
+// before any output has been forwarded, so callers never see a mixed answer.
+type fallbackProvider struct {
+	chain []Provider
+}
+
+// NewFallbackProvider returns a provider that answers with the first provider
+// in chain and falls back to the next ones on rate limits and server errors.
+func NewFallbackProvider(chain ...Provider) Provider {
+	if len(chain) == 1 {
+		return chain[0]
+	}
+	return &fallbackProvider{chain: chain}
+}
+
+// ShouldFallback reports whether err means the provider is unavailable rather
+// than the request being invalid, so another provider may succeed.
+func ShouldFallback(err error) bool {
+	if errors.Is(err, ErrRetriesExhausted) {
+		return true
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return isUnavailableStatus(anthropicErr.StatusCode)
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return isUnavailableStatus(openaiErr.StatusCode)
+	}
+	return false
+}
+
+// IsRateLimited reports whether err is a provider rejecting requests for
+// their rate, after the retries if there were any.
+func IsRateLimited(err error) bool {
+	if errors.Is(err, ErrRetriesExhausted) {
+		return true
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode == http.StatusTooManyRequests
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+func isUnavailableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func (p *fallbackProvider) Model() models.Model {
+	return p.chain[0].Model()
+}
+
+func (p *fallbackProvider) SendMessages(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	var err error
+	for i, current := range p.chain {
+		var response *ProviderResponse
+		response, err = current.SendMessages(ctx, state, messagesForModel(current.Model(), messages), tools)
+		if err == nil {
+			response.Model = current.Model()
+			return response, nil
+		}
+		if !p.canFallback(ctx, i, err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (p *fallbackProvider) StreamResponse(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	out := make(chan ProviderEvent)
+	go func() {
+		defer close(out)
+		for i, current := range p.chain {
+			events := current.StreamResponse(ctx, state, messagesForModel(current.Model(), messages), tools)
+			forwarded := false
+			var failed error
+			for event := range events {
+				if event.Type == EventError && failed == nil && !forwarded && p.canFallback(ctx, i, event.Error) {
+					// Keep draining so the provider's goroutine can exit
+					failed = event.Error
+					continue
+				}
+				if failed != nil {
+					continue
+				}
+				if event.Type == EventComplete && event.Response != nil {
+					event.Response.Model = current.Model()
+				}
+				forwarded = forwarded || producesOutput(event.Type)
+				out <- event
+			}
+			if failed == nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// canFallback reports whether the chain should move past provider i after err.
+func (p *fallbackProvider) canFallback(ctx context.Context, i int, err error) bool {
+	if i == len(p.chain)-1 || ctx.Err() != nil || !ShouldFallback(err) {
+		return false
+	}
+	from, to := p.chain[i].Model(), p.chain[i+1].Model()
+	logging.Warn("Provider unavailable, falling back", "from", from.ID, "to", to.ID, "error", err)
+	return true
+}
+
+func producesOutput(t EventType) bool {
+	switch t {
+	case EventContentDelta, EventThinkingDelta, EventToolUseStart, EventToolUseDelta, EventToolUseStop, EventComplete:
+		return true
+	}
+	return false
+}
+
+// messagesForModel adapts the history to what model accepts. Images are
+// dropped for models without attachment support, with a note in the message
+// text so the conversation still reads coherently; documents reach them as
+// their text.
+func messagesForModel(model models.Model, messages []message.Message) []message.Message {
+	if model.SupportsAttachments {
+		return messages
+	}
+	const note = "[Attachments omitted: the fallback model does not support them]"
+	adapted := make([]message.Message, len(messages))
+	for i, msg := range messages {
+		adapted[i] = msg
+		parts := make([]message.ContentPart, 0, len(msg.Parts))
+		for _, part := range msg.Parts {
+			// Documents are kept: the fallback's provider sends their text
+			if content, ok := part.(message.BinaryContent); !ok || content.IsDocument() {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == len(msg.Parts) {
+			continue
+		}
+		noted := false
+		for j, part := range parts {
+			if text, ok := part.(message.TextContent); ok {
+				parts[j] = message.TextContent{Text: text.Text + "\n\n" + note}
+				noted = true
+				break
+			}
+		}
+		if !noted {
+			parts = append(parts, message.TextContent{Text: note})
+		}
+		adapted[i].Parts = parts
+	}
+	return adapted
+}
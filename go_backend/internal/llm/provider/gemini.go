@@ -15,6 +15,7 @@ import (
 	toolspkg "mix/internal/llm/tools"
 	"mix/internal/logging"
 	"mix/internal/message"
+	"mix/internal/metrics"
 
 	"github.com/google/uuid"
 	"google.golang.org/genai"
@@ -40,7 +41,7 @@ func newGeminiClient(opts providerClientOptions) GeminiClient {
 		o(&geminiOpts)
 	}
 
-	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{APIKey: opts.apiKey, Backend: genai.BackendGeminiAPI})
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{APIKey: opts.apiKey, Backend: genai.BackendGeminiAPI, HTTPClient: sharedHTTPClient})
 	if err != nil {
 		logging.Error("Failed to create Gemini client", "error", err)
 		return nil
@@ -61,9 +62,13 @@ func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Cont
 			var parts []*genai.Part
 			parts = append(parts, &genai.Part{Text: msg.Content().String()})
 			for _, binaryContent := range msg.BinaryContent() {
-				imageFormat := strings.Split(binaryContent.MIMEType, "/")
+				mimeType := binaryContent.MIMEType
+				if message.IsTextDocumentType(mimeType) {
+					// Gemini reads every text format, but accepts few of their types
+					mimeType = "text/plain"
+				}
 				parts = append(parts, &genai.Part{InlineData: &genai.Blob{
-					MIMEType: imageFormat[1],
+					MIMEType: mimeType,
 					Data:     binaryContent.Data,
 				}})
 			}
@@ -168,7 +173,7 @@ func (g *geminiClient) finishReason(reason genai.FinishReason) message.FinishRea
 	}
 }
 
-func (g *geminiClient) send(ctx context.Context, messages []message.Message, tools []toolspkg.BaseTool) (*ProviderResponse, error) {
+func (g *geminiClient) send(ctx context.Context, state toolspkg.RequestState, messages []message.Message, tools []toolspkg.BaseTool) (*ProviderResponse, error) {
 	// Convert messages
 	geminiMessages := g.convertMessages(messages)
 
@@ -209,6 +214,7 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 			}
 			if retry {
 				logging.Warn(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries))
+				metrics.ProviderRetries.Inc(string(g.providerOptions.model.Provider))
 				select {
 				case <-ctx.Done():
 					return nil, ctx.Err()
@@ -243,10 +249,7 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 		// Check for completely empty response (no content and no tool calls)
 		if content == "" && len(toolCalls) == 0 {
 			logging.Warn("Gemini returned empty response with no content or tool calls")
-			// Extract sessionID from context and log detailed debug information
-			if sessionID, ok := ctx.Value(toolspkg.SessionIDContextKey).(string); ok {
-				g.logEmptyResponseDetails(sessionID, messages, tools, resp)
-			}
+			g.logEmptyResponseDetails(state.SessionID, messages, tools, resp)
 		}
 
 		finishReason := message.FinishReasonEndTurn
@@ -266,7 +269,7 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 	}
 }
 
-func (g *geminiClient) stream(ctx context.Context, messages []message.Message, tools []toolspkg.BaseTool) <-chan ProviderEvent {
+func (g *geminiClient) stream(ctx context.Context, state toolspkg.RequestState, messages []message.Message, tools []toolspkg.BaseTool) <-chan ProviderEvent {
 	// Convert messages
 	geminiMessages := g.convertMessages(messages)
 
@@ -295,6 +298,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	go func() {
 		defer close(eventChan)
 
+	attempt:
 		for {
 			attempts++
 
@@ -318,6 +322,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 					}
 					if retry {
 						logging.Warn(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries))
+						metrics.ProviderRetries.Inc(string(g.providerOptions.model.Provider))
 						select {
 						case <-ctx.Done():
 							if ctx.Err() != nil {
@@ -326,8 +331,8 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 
 							return
 						case <-time.After(time.Duration(after) * time.Millisecond):
-							break
 						}
+						continue attempt
 					} else {
 						eventChan <- ProviderEvent{Type: EventError, Error: err}
 						return
@@ -381,10 +386,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 				// Check for completely empty response (no content and no tool calls)
 				if currentContent == "" && len(toolCalls) == 0 {
 					logging.Warn("Gemini returned empty response with no content or tool calls")
-					// Extract sessionID from context and log detailed debug information
-					if sessionID, ok := ctx.Value(toolspkg.SessionIDContextKey).(string); ok {
-						g.logEmptyResponseDetails(sessionID, messages, tools, finalResp)
-					}
+					g.logEmptyResponseDetails(state.SessionID, messages, tools, finalResp)
 				}
 
 				finishReason := message.FinishReasonEndTurn
@@ -415,7 +417,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
 	// Check if error is a rate limit error
 	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+		return false, 0, fmt.Errorf("%w for rate limit: %d retries: %w", ErrRetriesExhausted, maxRetries, err)
 	}
 
 	// Gemini doesn't have a standard error type we can check against
@@ -470,11 +472,14 @@ func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
 		return TokenUsage{}
 	}
 
+	// The prompt count includes cached tokens, and thinking is billed as
+	// output
+	usage := resp.UsageMetadata
 	return TokenUsage{
-		InputTokens:         int64(resp.UsageMetadata.PromptTokenCount),
-		OutputTokens:        int64(resp.UsageMetadata.CandidatesTokenCount),
+		InputTokens:         int64(usage.PromptTokenCount - usage.CachedContentTokenCount),
+		OutputTokens:        int64(usage.CandidatesTokenCount + usage.ThoughtsTokenCount),
 		CacheCreationTokens: 0, // Not directly provided by Gemini
-		CacheReadTokens:     int64(resp.UsageMetadata.CachedContentTokenCount),
+		CacheReadTokens:     int64(usage.CachedContentTokenCount),
 	}
 }
 
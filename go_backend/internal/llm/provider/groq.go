@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const groqBaseURL = "https://api.groq.com/openai/v1"
+
+type GroqClient ProviderClient
+
+// newGroqClient reuses the OpenAI-compatible chat client for streaming and
+// tool calls, but authenticates only with the Groq key so OpenAI OAuth
+// credentials are never sent to Groq. Groq rejects reasoning_effort for the
+// models it serves, and sends each tool call whole in a single chunk, so tool
+// calls are finished as soon as their arguments are complete instead of when
+// the stream ends.
+func newGroqClient(opts providerClientOptions) GroqClient {
+	openaiOpts := openaiOptions{}
+	for _, o := range opts.openaiOptions {
+		o(&openaiOpts)
+	}
+	openaiOpts.reasoningEffort = ""
+	openaiOpts.finishToolCallsEarly = true
+
+	client := openai.NewClient(
+		option.WithAPIKey(opts.apiKey),
+		option.WithBaseURL(groqBaseURL),
+		option.WithRequestTimeout(90*time.Second),
+		option.WithHTTPClient(sharedHTTPClient),
+	)
+
+	return &openaiClient{
+		providerOptions: opts,
+		options:         openaiOpts,
+		client:          client,
+	}
+}
+
+// groqUsage returns the usage Groq reports in the x_groq field of a stream's
+// last chunk, where OpenAI reports it in usage.
+func groqUsage(chunk openai.ChatCompletionChunk) (openai.CompletionUsage, bool) {
+	field, ok := chunk.JSON.ExtraFields["x_groq"]
+	if !ok {
+		return openai.CompletionUsage{}, false
+	}
+	var xGroq struct {
+		Usage *openai.CompletionUsage `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(field.Raw()), &xGroq); err != nil || xGroq.Usage == nil {
+		return openai.CompletionUsage{}, false
+	}
+	return *xGroq.Usage, true
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"mix/internal/logging"
+)
+
+// The encryption key of the credential store is kept in the OS keychain
+// (macOS Keychain, Windows Credential Manager or the Linux secret service)
+// when one is available, so the encrypted credentials on disk are useless
+// without the user's login. Otherwise it stays in key.enc next to them.
+const (
+	keychainService = "mix"
+	keychainAccount = "credentials-key"
+
+	// credentialStoreEnv set to "file" keeps the key on disk even when a
+	// keychain is available
+	credentialStoreEnv = "MIX_CREDENTIAL_STORE"
+)
+
+var errKeychainItemNotFound = errors.New("keychain item not found")
+
+// keychain stores secrets by service and account in the OS keychain.
+type keychain interface {
+	// Name describes the keychain for users
+	Name() string
+	// Get returns errKeychainItemNotFound for a missing item
+	Get(service, account string) (string, error)
+	Set(service, account, secret string) error
+	Delete(service, account string) error
+}
+
+// selectKeychain returns the platform's keychain, or nil when there is none or
+// the user opted out.
+func selectKeychain() keychain {
+	if os.Getenv(credentialStoreEnv) == "file" {
+		return nil
+	}
+	return platformKeychain()
+}
+
+// loadKey returns the encryption key, creating it if there is none. With a
+// keychain, a key.enc left from file storage is moved into the keychain. If
+// the keychain can't store the key, the key file is used instead; a key that
+// is in the keychain but can't be read is an error, since a new key couldn't
+// decrypt the stored credentials.
+func (cs *CredentialStorage) loadKey() ([]byte, error) {
+	cs.keyMu.Lock()
+	defer cs.keyMu.Unlock()
+	if cs.key != nil {
+		return cs.key, nil
+	}
+
+	if cs.keychain != nil {
+		key, err := cs.loadKeychainKey()
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			cs.key = key
+			return key, nil
+		}
+		cs.keychain = nil
+	}
+
+	key, err := cs.loadFileKey()
+	if err != nil {
+		return nil, err
+	}
+	cs.key = key
+	return key, nil
+}
+
+// loadKeychainKey returns the key in the keychain, moving the key file there
+// or creating a key if it has none. It returns nil if the keychain can't
+// store the key.
+func (cs *CredentialStorage) loadKeychainKey() ([]byte, error) {
+	encoded, err := cs.keychain.Get(keychainService, keychainAccount)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials key in the %s: %w", cs.keychain.Name(), err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, errKeychainItemNotFound) {
+		return nil, fmt.Errorf("failed to read the credentials key from the %s (set %s=file to keep it on disk): %w", cs.keychain.Name(), credentialStoreEnv, err)
+	}
+
+	migrating := true
+	key, err := os.ReadFile(cs.keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		migrating = false
+		if key, err = newEncryptionKey(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	if err := cs.keychain.Set(keychainService, keychainAccount, base64.StdEncoding.EncodeToString(key)); err != nil {
+		logging.Warn("OS keychain unavailable, keeping the credentials key on disk", "keychain", cs.keychain.Name(), "error", err)
+		return nil, nil
+	}
+	if migrating {
+		if err := os.Remove(cs.keyFile); err != nil {
+			logging.Warn("Failed to remove the credentials key file after moving it to the keychain", "file", cs.keyFile, "error", err)
+		}
+		logging.Info("Moved the credentials key into the OS keychain", "keychain", cs.keychain.Name())
+	}
+	return key, nil
+}
+
+func (cs *CredentialStorage) loadFileKey() ([]byte, error) {
+	if keyData, err := os.ReadFile(cs.keyFile); err == nil {
+		return keyData, nil
+	}
+
+	key, err := newEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	// Save key with restricted permissions
+	if err := os.WriteFile(cs.keyFile, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save key: %w", err)
+	}
+	return key, nil
+}
+
+// KeyStorage describes where the encryption key of the credentials is kept.
+func (cs *CredentialStorage) KeyStorage() string {
+	if _, err := cs.loadKey(); err != nil {
+		return "unavailable (" + err.Error() + ")"
+	}
+	cs.keyMu.Lock()
+	defer cs.keyMu.Unlock()
+	if cs.keychain != nil {
+		return cs.keychain.Name()
+	}
+	return cs.keyFile
+}
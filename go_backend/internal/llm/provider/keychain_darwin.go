@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// macKeychain uses the login keychain through the security tool. Secrets are
+// passed on stdin in interactive mode so they don't show up in the process
+// list.
+type macKeychain struct {
+	path string
+}
+
+// errSecItemNotFound is the exit status of security for a missing item
+const errSecItemNotFound = 44
+
+func platformKeychain() keychain {
+	path, err := exec.LookPath("security")
+	if err != nil {
+		return nil
+	}
+	return &macKeychain{path: path}
+}
+
+func (k *macKeychain) Name() string {
+	return "macOS Keychain"
+}
+
+func (k *macKeychain) Get(service, account string) (string, error) {
+	out, err := exec.Command(k.path, "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+			return "", errKeychainItemNotFound
+		}
+		return "", fmt.Errorf("failed to read from the keychain: %w", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (k *macKeychain) Set(service, account, secret string) error {
+	command := fmt.Sprintf("add-generic-password -U -s %q -a %q -X %s\n", service, account, hex.EncodeToString([]byte(secret)))
+	cmd := exec.Command(k.path, "-i")
+	cmd.Stdin = strings.NewReader(command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to write to the keychain: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// Interactive mode exits successfully even when the command fails
+	if stderr.Len() > 0 {
+		return fmt.Errorf("failed to write to the keychain: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (k *macKeychain) Delete(service, account string) error {
+	err := exec.Command(k.path, "delete-generic-password", "-s", service, "-a", account).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete from the keychain: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// secretService uses the freedesktop secret service (GNOME Keyring, KWallet)
+// through secret-tool. It needs a D-Bus session, so headless machines fall
+// back to the key file.
+type secretService struct {
+	path string
+}
+
+// secretToolTimeout bounds a call, since an unlock prompt nobody answers
+// would otherwise block
+const secretToolTimeout = 30 * time.Second
+
+func platformKeychain() keychain {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil
+	}
+	return &secretService{path: path}
+}
+
+func (k *secretService) Name() string {
+	return "secret service"
+}
+
+// run returns the output of secret-tool. A failure that printed nothing is
+// errKeychainItemNotFound, which is how lookup reports a missing item.
+func (k *secretService) run(stdin string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretToolTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, k.path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" && errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", errKeychainItemNotFound
+		}
+		return "", fmt.Errorf("secret-tool %s: %w: %s", args[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+func (k *secretService) Get(service, account string) (string, error) {
+	return k.run("", "lookup", "service", service, "account", account)
+}
+
+func (k *secretService) Set(service, account, secret string) error {
+	_, err := k.run(secret, "store", "--label", "Mix credentials key", "service", service, "account", account)
+	return err
+}
+
+func (k *secretService) Delete(service, account string) error {
+	_, err := k.run("", "clear", "service", service, "account", account)
+	if errors.Is(err, errKeychainItemNotFound) {
+		return nil
+	}
+	return err
+}
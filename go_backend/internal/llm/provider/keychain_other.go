@@ -0,0 +1,9 @@
+//go:build !darwin && !linux && !windows
+
+package provider
+
+// platformKeychain returns nil on platforms without a supported keychain, so
+// the key stays in the key file.
+func platformKeychain() keychain {
+	return nil
+}
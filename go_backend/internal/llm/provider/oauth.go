@@ -60,6 +60,11 @@ type CredentialStorage struct {
 	keyFile   string
 	credFile  string
 	mu        sync.RWMutex
+
+	// keychain holds the encryption key when set; see loadKey
+	keychain keychain
+	keyMu    sync.Mutex
+	key      []byte
 }
 
 // OAuthFlow handles the OAuth authentication flow
@@ -106,14 +111,23 @@ func CleanupOAuthFlow(state string) {
 	delete(oauthFlowStore, state)
 }
 
+// CredentialsDir is where the encrypted credentials are stored, with their
+// key unless it is in the OS keychain
+func CredentialsDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mix", "credentials"), nil
+}
+
 // NewCredentialStorage creates a new credential storage instance
 func NewCredentialStorage() (*CredentialStorage, error) {
-	homeDir, err := os.UserHomeDir()
+	configDir, err := CredentialsDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
 	}
 
-	configDir := filepath.Join(homeDir, ".mix", "credentials")
 	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
@@ -122,33 +136,22 @@ func NewCredentialStorage() (*CredentialStorage, error) {
 		configDir: configDir,
 		keyFile:   filepath.Join(configDir, "key.enc"),
 		credFile:  filepath.Join(configDir, "credentials.enc"),
+		keychain:  selectKeychain(),
 	}, nil
 }
 
-// generateEncryptionKey creates or loads an encryption key
-func (cs *CredentialStorage) generateEncryptionKey() ([]byte, error) {
-	// Try to load existing key
-	if keyData, err := os.ReadFile(cs.keyFile); err == nil {
-		return keyData, nil
-	}
-
-	// Generate new key
-	key := make([]byte, 32) // AES-256
+// newEncryptionKey generates a random AES-256 key
+func newEncryptionKey() ([]byte, error) {
+	key := make([]byte, 32)
 	if _, err := rand.Read(key); err != nil {
 		return nil, fmt.Errorf("failed to generate key: %w", err)
 	}
-
-	// Save key with restricted permissions
-	if err := os.WriteFile(cs.keyFile, key, 0600); err != nil {
-		return nil, fmt.Errorf("failed to save key: %w", err)
-	}
-
 	return key, nil
 }
 
 // encrypt encrypts data using AES-GCM
 func (cs *CredentialStorage) encrypt(data []byte) ([]byte, error) {
-	key, err := cs.generateEncryptionKey()
+	key, err := cs.loadKey()
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +177,7 @@ func (cs *CredentialStorage) encrypt(data []byte) ([]byte, error) {
 
 // decrypt decrypts data using AES-GCM
 func (cs *CredentialStorage) decrypt(data []byte) ([]byte, error) {
-	key, err := cs.generateEncryptionKey()
+	key, err := cs.loadKey()
 	if err != nil {
 		return nil, err
 	}
@@ -577,6 +580,7 @@ func (flow *OAuthFlow) fallbackToBrowserInstructions(authCode string) (*OAuthCre
 type CredentialStore struct {
 	AnthropicCredentials map[string]OAuthCredentials  `json:"anthropic,omitempty"`
 	OpenAICredentials    map[string]OpenAICredentials `json:"openai,omitempty"`
+	APIKeys              map[string]StoredAPIKey      `json:"apiKeys,omitempty"`
 }
 
 // loadCredentialStore loads the credential store from encrypted storage
@@ -587,6 +591,7 @@ func (cs *CredentialStorage) loadCredentialStore() (*CredentialStore, error) {
 		return &CredentialStore{
 			AnthropicCredentials: make(map[string]OAuthCredentials),
 			OpenAICredentials:    make(map[string]OpenAICredentials),
+			APIKeys:              make(map[string]StoredAPIKey),
 		}, nil
 	}
 
@@ -617,6 +622,9 @@ func (cs *CredentialStorage) loadCredentialStore() (*CredentialStore, error) {
 	if store.OpenAICredentials == nil {
 		store.OpenAICredentials = make(map[string]OpenAICredentials)
 	}
+	if store.APIKeys == nil {
+		store.APIKeys = make(map[string]StoredAPIKey)
+	}
 
 	return &store, nil
 }
@@ -660,6 +668,29 @@ func (cs *CredentialStorage) StoreOpenAICredentials(provider string, credentials
 	return nil
 }
 
+// ClearOpenAICredentials removes OpenAI OAuth credentials for a provider,
+// including the API key they were exchanged for.
+func (cs *CredentialStorage) ClearOpenAICredentials(provider string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	store, err := cs.loadCredentialStore()
+	if err != nil {
+		return fmt.Errorf("failed to load credential store: %w", err)
+	}
+	if _, ok := store.OpenAICredentials[provider]; !ok {
+		return nil
+	}
+	delete(store.OpenAICredentials, provider)
+
+	if err := cs.saveCredentialStore(store); err != nil {
+		return fmt.Errorf("failed to save credential store: %w", err)
+	}
+
+	logging.Info("OpenAI OAuth credentials cleared for provider", "provider", provider)
+	return nil
+}
+
 // GetOpenAICredentials retrieves OpenAI OAuth credentials for a provider
 func (cs *CredentialStorage) GetOpenAICredentials(provider string) (*OpenAICredentials, error) {
 	cs.mu.RLock()
@@ -690,6 +721,10 @@ func IsAuthenticated() (bool, string, error) {
 	if err != nil {
 		return false, "", fmt.Errorf("failed to initialize credential storage: %w", err)
 	}
+
+	if keys, err := storage.APIKeys(); err == nil && keys["anthropic"].Key != "" {
+		return true, "API Key", nil
+	}
 	
 	creds, err := storage.GetOAuthCredentials("anthropic")
 	if err != nil {
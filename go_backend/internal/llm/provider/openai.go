@@ -9,11 +9,14 @@ import (
 	"strings"
 	"time"
 
+	"mix/internal/chaos"
 	"mix/internal/config"
 	"mix/internal/llm/models"
+	"mix/internal/llm/responseformat"
 	"mix/internal/llm/tools"
 	"mix/internal/logging"
 	"mix/internal/message"
+	"mix/internal/metrics"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -27,6 +30,9 @@ type openaiOptions struct {
 	extraHeaders    map[string]string
 	useOAuth        bool
 	oauthCreds      *OpenAICredentials
+	// finishToolCallsEarly finishes a streamed tool call once its arguments
+	// are complete JSON, for providers sending each call in one chunk
+	finishToolCallsEarly bool
 }
 
 type OpenAIOption func(*openaiOptions)
@@ -102,7 +108,7 @@ func newOpenAIClient(opts providerClientOptions) OpenAIClient {
 	}
 
 	// Add request timeout to prevent indefinite hangs
-	openaiClientOptions = append(openaiClientOptions, option.WithRequestTimeout(90*time.Second))
+	openaiClientOptions = append(openaiClientOptions, option.WithRequestTimeout(90*time.Second), option.WithHTTPClient(sharedHTTPClient))
 
 	client := openai.NewClient(openaiClientOptions...)
 	return &openaiClient{
@@ -124,6 +130,15 @@ func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessag
 			textBlock := openai.ChatCompletionContentPartTextParam{Text: msg.Content().String()}
 			content = append(content, openai.ChatCompletionContentPartUnionParam{OfText: &textBlock})
 			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsDocument() {
+					// Text documents were inlined already, so this is a PDF
+					file := openai.ChatCompletionContentPartFileParam{File: openai.ChatCompletionContentPartFileFileParam{
+						FileData: openai.String(binaryContent.String(models.ProviderOpenAI)),
+						Filename: openai.String(binaryContent.Name()),
+					}}
+					content = append(content, openai.ChatCompletionContentPartUnionParam{OfFile: &file})
+					continue
+				}
 				imageURL := openai.ChatCompletionContentPartImageImageURLParam{URL: binaryContent.String(models.ProviderOpenAI)}
 				imageBlock := openai.ChatCompletionContentPartImageParam{ImageURL: imageURL}
 
@@ -207,16 +222,21 @@ func (o *openaiClient) finishReason(reason string) message.FinishReason {
 	}
 }
 
-func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessageParamUnion, tools []openai.ChatCompletionToolParam) openai.ChatCompletionNewParams {
+func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessageParamUnion, tools []openai.ChatCompletionToolParam, format *responseformat.Format) openai.ChatCompletionNewParams {
 	params := openai.ChatCompletionNewParams{
 		Model:    openai.ChatModel(o.providerOptions.model.APIModel),
 		Messages: messages,
 		Tools:    tools,
 	}
+	if responseFormat, ok := openaiResponseFormat(format); ok {
+		params.ResponseFormat = responseFormat
+	}
 
 	if o.providerOptions.model.CanReason == true {
 		params.MaxCompletionTokens = openai.Int(o.providerOptions.maxTokens)
 		switch o.options.reasoningEffort {
+		case "":
+			// Left to the provider, for those rejecting the parameter
 		case "low":
 			params.ReasoningEffort = shared.ReasoningEffortLow
 		case "medium":
@@ -233,7 +253,30 @@ func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessagePar
 	return params
 }
 
-func (o *openaiClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (response *ProviderResponse, err error) {
+// openaiResponseFormat returns the response_format enforcing format. The API
+// only takes object schemas, so other formats are left to the agent's
+// validation.
+func openaiResponseFormat(format *responseformat.Format) (openai.ChatCompletionNewParamsResponseFormatUnion, bool) {
+	if format == nil {
+		return openai.ChatCompletionNewParamsResponseFormatUnion{}, false
+	}
+	if format.Type == responseformat.TypeJSONObject {
+		return openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}}, true
+	}
+	schema := format.SchemaMap()
+	if schema["type"] != "object" {
+		return openai.ChatCompletionNewParamsResponseFormatUnion{}, false
+	}
+	return openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+		JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:   format.SchemaName(),
+			Schema: schema,
+			Strict: openai.Bool(format.Strict),
+		},
+	}}, true
+}
+
+func (o *openaiClient) send(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) (response *ProviderResponse, err error) {
 	// Handle proactive token refresh for OAuth
 	if o.options.useOAuth && o.options.oauthCreds != nil {
 		if o.options.oauthCreds.IsTokenExpired() && o.options.oauthCreds.RefreshToken != "" {
@@ -251,18 +294,20 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 		}
 	}
 
-	params := o.preparedParams(o.convertMessages(messages), o.convertTools(tools))
+	params := o.preparedParams(o.convertMessages(messages), o.convertTools(tools), state.ResponseFormat)
 	cfg := config.Get()
 	if cfg.Debug {
 		jsonData, _ := json.Marshal(params)
 		logging.Debug("Prepared messages", "messages", string(jsonData))
 	}
+	requestID := &requestIDRecorder{}
 	attempts := 0
 	for {
 		attempts++
 		openaiResponse, err := o.client.Chat.Completions.New(
 			ctx,
 			params,
+			option.WithMiddleware(requestID.middleware, chaos.ProviderMiddleware),
 		)
 		// If there is an error we are going to see if we can retry the call
 		if err != nil {
@@ -282,12 +327,14 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 				}
 			}
 
+			logging.Error("Error in OpenAI API call", "error", err, "request_id", requestID.ID())
 			retry, after, retryErr := o.shouldRetry(attempts, err)
 			if retryErr != nil {
-				return nil, retryErr
+				return nil, requestID.wrapError(retryErr)
 			}
 			if retry {
 				logging.Warn(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries))
+				metrics.ProviderRetries.Inc(string(o.providerOptions.model.Provider))
 				select {
 				case <-ctx.Done():
 					return nil, ctx.Err()
@@ -295,7 +342,7 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 					continue
 				}
 			}
-			return nil, retryErr
+			return nil, requestID.wrapError(retryErr)
 		}
 
 		content := ""
@@ -315,11 +362,12 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 			ToolCalls:    toolCalls,
 			Usage:        o.usage(*openaiResponse),
 			FinishReason: finishReason,
+			RequestID:    requestID.ID(),
 		}, nil
 	}
 }
 
-func (o *openaiClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+func (o *openaiClient) stream(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
 	eventChan := make(chan ProviderEvent)
 
 	// Handle proactive token refresh for OAuth
@@ -339,7 +387,7 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 		}
 	}
 
-	params := o.preparedParams(o.convertMessages(messages), o.convertTools(tools))
+	params := o.preparedParams(o.convertMessages(messages), o.convertTools(tools), state.ResponseFormat)
 	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{
 		IncludeUsage: openai.Bool(true),
 	}
@@ -352,21 +400,36 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 
 	attempts := 0
 
+	requestID := &requestIDRecorder{}
 	go func() {
 		for {
 			attempts++
 			openaiStream := o.client.Chat.Completions.NewStreaming(
 				ctx,
 				params,
+				option.WithMiddleware(requestID.middleware, chaos.ProviderMiddleware),
 			)
 
 			acc := openai.ChatCompletionAccumulator{}
 			currentContent := ""
 			toolCalls := make([]message.ToolCall, 0)
+			// streamingToolCalls maps the index of each tool call in the
+			// stream to its ID, which only its first chunk carries
+			streamingToolCalls := make(map[int64]string)
+			var streamingOrder []string
+			// With finishToolCallsEarly, the arguments of the calls not
+			// finished yet
+			streamingArgs := make(map[string]string)
+			finishedToolCalls := make(map[string]bool)
 
 			for openaiStream.Next() {
 				chunk := openaiStream.Current()
 				acc.AddChunk(chunk)
+				if usage, ok := groqUsage(chunk); ok && chunk.Usage.TotalTokens == 0 {
+					acc.Usage.PromptTokens += usage.PromptTokens
+					acc.Usage.CompletionTokens += usage.CompletionTokens
+					acc.Usage.TotalTokens += usage.TotalTokens
+				}
 
 				for _, choice := range chunk.Choices {
 					if choice.Delta.Content != "" {
@@ -376,6 +439,34 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 						}
 						currentContent += choice.Delta.Content
 					}
+					for _, toolCall := range choice.Delta.ToolCalls {
+						if _, started := streamingToolCalls[toolCall.Index]; !started && toolCall.ID != "" {
+							streamingToolCalls[toolCall.Index] = toolCall.ID
+							streamingOrder = append(streamingOrder, toolCall.ID)
+							eventChan <- ProviderEvent{
+								Type:     EventToolUseStart,
+								ToolCall: &message.ToolCall{ID: toolCall.ID, Name: toolCall.Function.Name},
+							}
+						}
+						id, ok := streamingToolCalls[toolCall.Index]
+						if !ok || finishedToolCalls[id] {
+							continue
+						}
+						if toolCall.Function.Arguments != "" {
+							eventChan <- ProviderEvent{
+								Type:     EventToolUseDelta,
+								ToolCall: &message.ToolCall{ID: id, Input: toolCall.Function.Arguments},
+							}
+						}
+						if o.options.finishToolCallsEarly {
+							streamingArgs[id] += toolCall.Function.Arguments
+							if json.Valid([]byte(streamingArgs[id])) {
+								delete(streamingArgs, id)
+								finishedToolCalls[id] = true
+								eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &message.ToolCall{ID: id}}
+							}
+						}
+					}
 				}
 			}
 
@@ -389,6 +480,11 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 				if len(toolCalls) > 0 {
 					finishReason = message.FinishReasonToolUse
 				}
+				for _, id := range streamingOrder {
+					if !finishedToolCalls[id] {
+						eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &message.ToolCall{ID: id}}
+					}
+				}
 
 				eventChan <- ProviderEvent{
 					Type: EventComplete,
@@ -397,6 +493,7 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 						ToolCalls:    toolCalls,
 						Usage:        o.usage(acc.ChatCompletion),
 						FinishReason: finishReason,
+						RequestID:    requestID.ID(),
 					},
 				}
 				close(eventChan)
@@ -420,14 +517,16 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 			}
 
 			// If there is an error we are going to see if we can retry the call
+			logging.Error("Error in OpenAI streaming call", "error", err, "request_id", requestID.ID())
 			retry, after, retryErr := o.shouldRetry(attempts, err)
 			if retryErr != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+				eventChan <- ProviderEvent{Type: EventError, Error: requestID.wrapError(retryErr)}
 				close(eventChan)
 				return
 			}
 			if retry {
 				logging.Warn(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries))
+				metrics.ProviderRetries.Inc(string(o.providerOptions.model.Provider))
 				select {
 				case <-ctx.Done():
 					// context cancelled
@@ -440,7 +539,7 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 					continue
 				}
 			}
-			eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+			eventChan <- ProviderEvent{Type: EventError, Error: requestID.wrapError(retryErr)}
 			close(eventChan)
 			return
 		}
@@ -460,7 +559,7 @@ func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 	}
 
 	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+		return false, 0, fmt.Errorf("%w for rate limit: %d retries: %w", ErrRetriesExhausted, maxRetries, err)
 	}
 
 	retryMs := 0
@@ -558,7 +657,7 @@ func (o *openaiClient) recreateClient() {
 		}
 	}
 
-	clientOptions = append(clientOptions, option.WithRequestTimeout(90*time.Second))
+	clientOptions = append(clientOptions, option.WithRequestTimeout(90*time.Second), option.WithHTTPClient(sharedHTTPClient))
 	o.client = openai.NewClient(clientOptions...)
 }
 
@@ -0,0 +1,162 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mix/internal/logging"
+)
+
+// The device code flow signs in without a browser or callback port on this
+// machine: the user opens the verification page anywhere and enters a code
+// while we poll for the authorization code.
+const (
+	openaiDeviceAuthURL     = openaiIssuer + "/api/accounts/deviceauth"
+	openaiDeviceVerifyURL   = openaiIssuer + "/codex/device"
+	openaiDeviceRedirectURI = openaiIssuer + "/deviceauth/callback"
+
+	// openaiDeviceCodeTimeout is how long the user has to enter the code
+	openaiDeviceCodeTimeout = 15 * time.Minute
+	defaultDevicePollPeriod = 5 * time.Second
+)
+
+// OpenAIDeviceCode is a pending device code sign-in. Show VerificationURL
+// and UserCode to the user, then call Wait.
+type OpenAIDeviceCode struct {
+	VerificationURL string
+	UserCode        string
+	ExpiresAt       time.Time
+
+	deviceAuthID string
+	interval     time.Duration
+}
+
+// RequestOpenAIDeviceCode starts a device code sign-in with OpenAI.
+func RequestOpenAIDeviceCode(ctx context.Context) (*OpenAIDeviceCode, error) {
+	resp, err := postDeviceAuth(ctx, "/usercode", map[string]string{"client_id": openaiClientID})
+	if err != nil {
+		return nil, fmt.Errorf("device code request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("device code request failed (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var codeResp struct {
+		DeviceAuthID string `json:"device_auth_id"`
+		UserCode     string `json:"user_code"`
+		Usercode     string `json:"usercode"`
+		// Interval is in seconds, sent as a string or a number
+		Interval json.RawMessage `json:"interval"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&codeResp); err != nil {
+		return nil, fmt.Errorf("failed to decode device code response: %w", err)
+	}
+	userCode := codeResp.UserCode
+	if userCode == "" {
+		userCode = codeResp.Usercode
+	}
+	if codeResp.DeviceAuthID == "" || userCode == "" {
+		return nil, fmt.Errorf("device code response is missing the device or user code")
+	}
+
+	interval := defaultDevicePollPeriod
+	if seconds, err := strconv.Atoi(string(bytes.Trim(codeResp.Interval, `"`))); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+	return &OpenAIDeviceCode{
+		VerificationURL: openaiDeviceVerifyURL,
+		UserCode:        userCode,
+		ExpiresAt:       time.Now().Add(openaiDeviceCodeTimeout),
+		deviceAuthID:    codeResp.DeviceAuthID,
+		interval:        interval,
+	}, nil
+}
+
+// Wait polls until the user has entered the code, exchanges the resulting
+// authorization code for credentials and stores them.
+func (dc *OpenAIDeviceCode) Wait(ctx context.Context) (*OpenAICredentials, error) {
+	ctx, cancel := context.WithDeadline(ctx, dc.ExpiresAt)
+	defer cancel()
+
+	var authResp struct {
+		AuthorizationCode string `json:"authorization_code"`
+		CodeChallenge     string `json:"code_challenge"`
+		CodeVerifier      string `json:"code_verifier"`
+	}
+	for {
+		resp, err := postDeviceAuth(ctx, "/token", map[string]string{
+			"device_auth_id": dc.deviceAuthID,
+			"user_code":      dc.UserCode,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("the code was not entered within %s", openaiDeviceCodeTimeout)
+			}
+			return nil, fmt.Errorf("device code poll failed: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			err := json.NewDecoder(resp.Body).Decode(&authResp)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode device code authorization: %w", err)
+			}
+			break
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		// Pending until the user enters the code
+		if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusNotFound {
+			return nil, fmt.Errorf("device code poll failed (status %d): %s", resp.StatusCode, string(body))
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("the code was not entered within %s", openaiDeviceCodeTimeout)
+			}
+			return nil, ctx.Err()
+		case <-time.After(dc.interval):
+		}
+	}
+
+	flow := &OpenAIOAuthFlow{
+		ClientID:    openaiClientID,
+		PKCE:        &OpenAIPKCECodes{CodeVerifier: authResp.CodeVerifier, CodeChallenge: authResp.CodeChallenge},
+		RedirectURI: openaiDeviceRedirectURI,
+	}
+	credentials, _, err := flow.exchangeCodeForCredentials(authResp.AuthorizationCode)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := NewCredentialStorage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize credential storage: %w", err)
+	}
+	if err := storage.StoreOpenAICredentials("openai", credentials); err != nil {
+		return nil, fmt.Errorf("failed to store credentials: %w", err)
+	}
+	logging.Info("OpenAI device code sign-in completed")
+	return credentials, nil
+}
+
+func postDeviceAuth(ctx context.Context, path string, body map[string]string) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, openaiDeviceAuthURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const openRouterBaseURL = "https://openrouter.ai/api/v1"
+
+type OpenRouterClient ProviderClient
+
+// newOpenRouterClient reuses the OpenAI-compatible chat client for streaming
+// and tool calls, but authenticates only with the OpenRouter key so OpenAI
+// OAuth credentials are never sent to OpenRouter.
+func newOpenRouterClient(opts providerClientOptions) OpenRouterClient {
+	openaiOpts := openaiOptions{
+		reasoningEffort: "medium",
+	}
+	for _, o := range opts.openaiOptions {
+		o(&openaiOpts)
+	}
+
+	client := openai.NewClient(
+		option.WithAPIKey(opts.apiKey),
+		option.WithBaseURL(openRouterBaseURL),
+		// Attribution headers shown on openrouter.ai
+		option.WithHeader("HTTP-Referer", "mix.ai"),
+		option.WithHeader("X-Title", "Mix"),
+		option.WithRequestTimeout(90*time.Second),
+		option.WithHTTPClient(sharedHTTPClient),
+	)
+
+	return &openaiClient{
+		providerOptions: opts,
+		options:         openaiOpts,
+		client:          client,
+	}
+}
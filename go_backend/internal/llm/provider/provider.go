@@ -32,6 +32,8 @@ type TokenUsage struct {
 	OutputTokens        int64
 	CacheCreationTokens int64
 	CacheReadTokens     int64
+	// ThinkingBudget is the thinking token budget the request was given
+	ThinkingBudget int64
 }
 
 type ProviderResponse struct {
@@ -39,6 +41,10 @@ type ProviderResponse struct {
 	ToolCalls    []message.ToolCall
 	Usage        TokenUsage
 	FinishReason message.FinishReason
+	// RequestID is the provider's ID for the request, for support escalation
+	RequestID string
+	// Model is the model that answered when a fallback chain is configured
+	Model models.Model
 }
 
 type ProviderEvent struct {
@@ -51,9 +57,9 @@ type ProviderEvent struct {
 	Error    error
 }
 type Provider interface {
-	SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
+	SendMessages(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
 
-	StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
+	StreamResponse(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
 
 	Model() models.Model
 }
@@ -68,13 +74,15 @@ type providerClientOptions struct {
 	openaiOptions    []OpenAIOption
 	geminiOptions    []GeminiOption
 	bedrockOptions   []BedrockOption
+	azureOptions     []AzureOption
+	vertexAIOptions  []VertexAIOption
 }
 
 type ProviderClientOption func(*providerClientOptions)
 
 type ProviderClient interface {
-	send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
-	stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
+	send(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
+	stream(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
 }
 
 type baseProvider[C ProviderClient] struct {
@@ -109,12 +117,9 @@ func NewProvider(providerName models.ModelProvider, opts ...ProviderClientOption
 			client:  newBedrockClient(clientOptions),
 		}, nil
 	case models.ProviderGROQ:
-		clientOptions.openaiOptions = append(clientOptions.openaiOptions,
-			WithOpenAIBaseURL("https://api.groq.com/openai/v1"),
-		)
-		return &baseProvider[OpenAIClient]{
+		return &baseProvider[GroqClient]{
 			options: clientOptions,
-			client:  newOpenAIClient(clientOptions),
+			client:  newGroqClient(clientOptions),
 		}, nil
 	case models.ProviderAzure:
 		client, err := newAzureClient(clientOptions)
@@ -126,21 +131,18 @@ func NewProvider(providerName models.ModelProvider, opts ...ProviderClientOption
 			client:  client,
 		}, nil
 	case models.ProviderVertexAI:
+		client, err := newVertexAIClient(clientOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Vertex AI client: %w", err)
+		}
 		return &baseProvider[VertexAIClient]{
 			options: clientOptions,
-			client:  newVertexAIClient(clientOptions),
+			client:  client,
 		}, nil
 	case models.ProviderOpenRouter:
-		clientOptions.openaiOptions = append(clientOptions.openaiOptions,
-			WithOpenAIBaseURL("https://openrouter.ai/api/v1"),
-			WithOpenAIExtraHeaders(map[string]string{
-				"HTTP-Referer": "mix.ai",
-				"X-Title":      "Mix",
-			}),
-		)
-		return &baseProvider[OpenAIClient]{
+		return &baseProvider[OpenRouterClient]{
 			options: clientOptions,
-			client:  newOpenAIClient(clientOptions),
+			client:  newOpenRouterClient(clientOptions),
 		}, nil
 	case models.ProviderXAI:
 		clientOptions.openaiOptions = append(clientOptions.openaiOptions,
@@ -172,21 +174,35 @@ func (p *baseProvider[C]) cleanMessages(messages []message.Message) (cleaned []m
 		}
 		cleaned = append(cleaned, msg)
 	}
-	return
+	return inlineDocuments(p.options.model, cleaned)
 }
 
-func (p *baseProvider[C]) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+func (p *baseProvider[C]) SendMessages(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
 	messages = p.cleanMessages(messages)
-	return p.client.send(ctx, messages, tools)
+	cache := cacheRequestFor(p.options, messages, tools)
+	if cached := cache.load(); cached != nil {
+		return &cached.Response, nil
+	}
+	response, err := p.client.send(ctx, state, messages, tools)
+	if err == nil && response != nil {
+		cache.store(*response, response.Content, "")
+	}
+	return response, err
 }
 
 func (p *baseProvider[C]) Model() models.Model {
 	return p.options.model
 }
 
-func (p *baseProvider[C]) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+func (p *baseProvider[C]) StreamResponse(ctx context.Context, state tools.RequestState, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
 	messages = p.cleanMessages(messages)
-	return p.client.stream(ctx, messages, tools)
+	cache := cacheRequestFor(p.options, messages, tools)
+	if cached := cache.load(); cached != nil {
+		return cached.replay()
+	}
+	return cache.record(ctx, truncateStream(ctx, func(ctx context.Context) <-chan ProviderEvent {
+		return p.client.stream(ctx, state, messages, tools)
+	}))
 }
 
 func WithAPIKey(apiKey string) ProviderClientOption {
@@ -236,3 +252,15 @@ func WithBedrockOptions(bedrockOptions ...BedrockOption) ProviderClientOption {
 		options.bedrockOptions = bedrockOptions
 	}
 }
+
+func WithAzureOptions(azureOptions ...AzureOption) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.azureOptions = azureOptions
+	}
+}
+
+func WithVertexAIOptions(vertexAIOptions ...VertexAIOption) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.vertexAIOptions = vertexAIOptions
+	}
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// requestIDHeaders are the response headers providers return request IDs in:
+// "request-id" for Anthropic and "x-request-id" for OpenAI-compatible APIs.
+var requestIDHeaders = []string{"request-id", "x-request-id"}
+
+// RequestError annotates a provider failure with the request ID to quote when
+// escalating to the provider's support.
+type RequestError struct {
+	Err       error
+	RequestID string
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s (request_id: %s)", e.Err.Error(), e.RequestID)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// RequestIDFromError returns the provider request ID attached to err, if any.
+func RequestIDFromError(err error) string {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.RequestID
+	}
+	return ""
+}
+
+// requestIDRecorder captures the request ID of the latest attempt through SDK
+// middleware, so retried calls report the attempt that produced the result.
+type requestIDRecorder struct {
+	mu sync.Mutex
+	id string
+}
+
+func (r *requestIDRecorder) middleware(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	resp, err := next(req)
+	if resp != nil {
+		for _, header := range requestIDHeaders {
+			if id := resp.Header.Get(header); id != "" {
+				r.mu.Lock()
+				r.id = id
+				r.mu.Unlock()
+				break
+			}
+		}
+	}
+	return resp, err
+}
+
+func (r *requestIDRecorder) ID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+// wrapError attaches the recorded request ID to err. Cancellations are left
+// alone since there is nothing to escalate.
+func (r *requestIDRecorder) wrapError(err error) error {
+	id := r.ID()
+	if err == nil || id == "" || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return &RequestError{Err: err, RequestID: id}
+}
@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"mix/internal/llm/models"
+	"mix/internal/llm/tools"
+	"mix/internal/logging"
+	"mix/internal/message"
+)
+
+// responseCache stores provider responses on disk so that a request identical
+// to an earlier one is answered without calling the provider. It is meant for
+// repeated non-interactive runs, such as evaluations and CI, and is off unless
+// EnableResponseCache is called.
+type responseCache struct {
+	dir string
+	// ttl is how long a response is reused; zero reuses it forever
+	ttl time.Duration
+}
+
+var activeResponseCache atomic.Pointer[responseCache]
+
+// EnableResponseCache answers requests from the responses stored in dir,
+// storing the responses to new requests there. Requests match on the model,
+// a hash of the full prompt and a hash of the tools. Cached responses report
+// no token usage, so they cost nothing.
+func EnableResponseCache(dir string, ttl time.Duration) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create response cache directory: %w", err)
+	}
+	activeResponseCache.Store(&responseCache{dir: dir, ttl: ttl})
+	return nil
+}
+
+// cachedResponse is a stored response. The hashes are kept for inspection.
+type cachedResponse struct {
+	Model      models.ModelID   `json:"model"`
+	PromptHash string           `json:"promptHash"`
+	ToolsHash  string           `json:"toolsHash"`
+	CreatedAt  int64            `json:"createdAt"`
+	Thinking   string           `json:"thinking,omitempty"`
+	Content    string           `json:"content"`
+	Response   ProviderResponse `json:"response"`
+}
+
+// cacheRequest is one request's place in the cache. Its methods may be called
+// on a nil cacheRequest, which caches nothing.
+type cacheRequest struct {
+	cache      *responseCache
+	model      models.ModelID
+	promptHash string
+	toolsHash  string
+}
+
+// cacheRequestFor returns the cache entry of a request, or nil when the cache
+// is off.
+func cacheRequestFor(options providerClientOptions, messages []message.Message, tools []tools.BaseTool) *cacheRequest {
+	cache := activeResponseCache.Load()
+	if cache == nil {
+		return nil
+	}
+	return &cacheRequest{
+		cache:      cache,
+		model:      options.model.ID,
+		promptHash: promptHash(options, messages),
+		toolsHash:  toolsHash(tools),
+	}
+}
+
+func (r *cacheRequest) path() string {
+	sum := sha256.Sum256([]byte(string(r.model) + "\x00" + r.promptHash + "\x00" + r.toolsHash))
+	return filepath.Join(r.cache.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the stored response, or nil when there is none or it expired.
+func (r *cacheRequest) load() *cachedResponse {
+	if r == nil {
+		return nil
+	}
+	data, err := os.ReadFile(r.path())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logging.Warn("Failed to read cached response", "error", err)
+		}
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		logging.Warn("Ignoring unreadable cached response", "path", r.path(), "error", err)
+		return nil
+	}
+	if r.cache.ttl > 0 && time.Since(time.Unix(cached.CreatedAt, 0)) > r.cache.ttl {
+		return nil
+	}
+	logging.Info("Answering from the response cache", "model", r.model, "promptHash", r.promptHash)
+	cached.Response.Usage = TokenUsage{}
+	cached.Response.RequestID = ""
+	return &cached
+}
+
+// store saves a completed response. Failures only cost a later cache miss.
+func (r *cacheRequest) store(response ProviderResponse, content, thinking string) {
+	if r == nil {
+		return
+	}
+	data, err := json.Marshal(cachedResponse{
+		Model:      r.model,
+		PromptHash: r.promptHash,
+		ToolsHash:  r.toolsHash,
+		CreatedAt:  time.Now().Unix(),
+		Thinking:   thinking,
+		Content:    content,
+		Response:   response,
+	})
+	if err == nil {
+		// Write a temporary file first so concurrent runs never read half a response
+		tmp := r.path() + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, r.path())
+		}
+	}
+	if err != nil {
+		logging.Warn("Failed to cache response", "error", err)
+	}
+}
+
+// replay streams a cached response as the provider would have.
+func (r *cachedResponse) replay() <-chan ProviderEvent {
+	events := make(chan ProviderEvent, 3)
+	if r.Thinking != "" {
+		events <- ProviderEvent{Type: EventThinkingDelta, Thinking: r.Thinking}
+	}
+	if r.Content != "" {
+		events <- ProviderEvent{Type: EventContentDelta, Content: r.Content}
+	}
+	response := r.Response
+	events <- ProviderEvent{Type: EventComplete, Response: &response}
+	close(events)
+	return events
+}
+
+// record passes a stream through, storing its response once it completes.
+func (r *cacheRequest) record(ctx context.Context, stream <-chan ProviderEvent) <-chan ProviderEvent {
+	if r == nil {
+		return stream
+	}
+	out := make(chan ProviderEvent)
+	go func() {
+		defer close(out)
+		var content, thinking string
+		for event := range stream {
+			switch event.Type {
+			case EventContentDelta:
+				content += event.Content
+			case EventThinkingDelta:
+				thinking += event.Thinking
+			case EventComplete:
+				if event.Response != nil && ctx.Err() == nil {
+					// SendMessages replays the same response, so it needs the content
+					response := *event.Response
+					if response.Content == "" {
+						response.Content = content
+					}
+					if content == "" {
+						content = response.Content
+					}
+					r.store(response, content, thinking)
+				}
+			}
+			out <- event
+		}
+	}()
+	return out
+}
+
+// promptHash hashes what the model is sent besides the tools: the system
+// message, the output limit and the messages' content. Message IDs and
+// timestamps differ between runs and are left out.
+func promptHash(options providerClientOptions, messages []message.Message) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	enc.Encode([]any{options.systemMessage, options.maxTokens})
+	for _, msg := range messages {
+		enc.Encode(msg.Role)
+		for _, part := range msg.Parts {
+			switch p := part.(type) {
+			case message.TextContent:
+				enc.Encode([]any{"text", p.Text})
+			case message.ImageURLContent:
+				enc.Encode([]any{"image_url", p.URL, p.Detail})
+			case message.BinaryContent:
+				enc.Encode([]any{"binary", p.MIMEType, fmt.Sprintf("%x", sha256.Sum256(p.Data))})
+			case message.ToolCall:
+				enc.Encode([]any{"tool_call", p.ID, p.Name, p.Input})
+			case message.ToolResult:
+				enc.Encode([]any{"tool_result", p.ToolCallID, p.Name, p.Content, p.IsError})
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// toolsHash hashes the tools' names, descriptions and schemas, in order.
+func toolsHash(baseTools []tools.BaseTool) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, tool := range baseTools {
+		info := tool.Info()
+		enc.Encode([]any{info.Name, info.Description, info.Parameters, info.Required})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"mix/internal/config"
+	"mix/internal/message"
+)
+
+// recentToolResults is how many of the latest tool results the adaptive
+// thinking budget considers
+const recentToolResults = 20
+
+// ThinkingBudget returns the thinking token budget th gives a request for the
+// user's message, where failureRate is the share of recent tool calls that
+// failed. 0 disables thinking.
+func ThinkingBudget(th config.Thinking, userMessage string, failureRate float64) int {
+	switch th.Mode {
+	case config.ThinkingOff:
+		return 0
+	case config.ThinkingFixed:
+		return th.Budget
+	case config.ThinkingAdaptive:
+		return th.Budget + int(failureRate*float64(th.MaxBudget-th.Budget))
+	default:
+		return DefaultThinkingBudgetFn(userMessage)
+	}
+}
+
+// ToolFailureRate returns the share of the latest tool results in messages
+// that are errors, or 0 without any.
+func ToolFailureRate(messages []message.Message) float64 {
+	var results, failures int
+	for i := len(messages) - 1; i >= 0 && results < recentToolResults; i-- {
+		toolResults := messages[i].ToolResults()
+		for j := len(toolResults) - 1; j >= 0 && results < recentToolResults; j-- {
+			results++
+			if toolResults[j].IsError {
+				failures++
+			}
+		}
+	}
+	if results == 0 {
+		return 0
+	}
+	return float64(failures) / float64(results)
+}
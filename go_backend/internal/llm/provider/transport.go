@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connection pool limits of the shared transport. Sessions talk to few hosts,
+// so each keeps enough idle connections for concurrent runs to reuse.
+const (
+	maxIdleConnsPerHost = 32
+	idleConnTimeout     = 90 * time.Second
+)
+
+// sharedHTTPClient is the HTTP client of every provider client, so building a
+// provider reuses the open keep-alive and HTTP/2 connections instead of
+// dialing and negotiating TLS again. Vertex AI, whose client carries its own
+// credentials, is the exception.
+var sharedHTTPClient = &http.Client{Transport: newSharedTransport()}
+
+func newSharedTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          4 * maxIdleConnsPerHost,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
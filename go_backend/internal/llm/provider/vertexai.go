@@ -2,34 +2,82 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"os"
 
-	"mix/internal/logging"
-
 	"google.golang.org/genai"
 )
 
+// DefaultVertexAILocation is used when neither the config nor the environment
+// names a location
+const DefaultVertexAILocation = "us-central1"
+
+type vertexAIOptions struct {
+	project  string
+	location string
+}
+
+type VertexAIOption func(*vertexAIOptions)
+
 type VertexAIClient ProviderClient
 
-func newVertexAIClient(opts providerClientOptions) VertexAIClient {
+// newVertexAIClient serves Gemini models through Vertex AI, authenticating
+// with Application Default Credentials: GOOGLE_APPLICATION_CREDENTIALS, the
+// gcloud application-default login, or the attached service account on GCP.
+func newVertexAIClient(opts providerClientOptions) (VertexAIClient, error) {
+	vertexOpts := vertexAIOptions{
+		project:  firstEnv("VERTEXAI_PROJECT", "GOOGLE_CLOUD_PROJECT"),
+		location: firstEnv("VERTEXAI_LOCATION", "GOOGLE_CLOUD_LOCATION", "GOOGLE_CLOUD_REGION"),
+	}
+	for _, o := range opts.vertexAIOptions {
+		o(&vertexOpts)
+	}
+	if vertexOpts.project == "" {
+		return nil, fmt.Errorf("Vertex AI provider requires providers.vertexai.project or the VERTEXAI_PROJECT or GOOGLE_CLOUD_PROJECT environment variable")
+	}
+	if vertexOpts.location == "" {
+		vertexOpts.location = DefaultVertexAILocation
+	}
+
 	geminiOpts := geminiOptions{}
 	for _, o := range opts.geminiOptions {
 		o(&geminiOpts)
 	}
 
 	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
-		Project:  os.Getenv("VERTEXAI_PROJECT"),
-		Location: os.Getenv("VERTEXAI_LOCATION"),
+		Project:  vertexOpts.project,
+		Location: vertexOpts.location,
 		Backend:  genai.BackendVertexAI,
 	})
 	if err != nil {
-		logging.Error("Failed to create VertexAI client", "error", err)
-		return nil
+		return nil, fmt.Errorf("Vertex AI provider has no Application Default Credentials (run gcloud auth application-default login or set GOOGLE_APPLICATION_CREDENTIALS): %w", err)
 	}
 
 	return &geminiClient{
 		providerOptions: opts,
 		options:         geminiOpts,
 		client:          client,
+	}, nil
+}
+
+// WithVertexAIProject overrides the project and location from the
+// environment. Empty values keep the environment's.
+func WithVertexAIProject(project, location string) VertexAIOption {
+	return func(options *vertexAIOptions) {
+		if project != "" {
+			options.project = project
+		}
+		if location != "" {
+			options.location = location
+		}
+	}
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
 	}
+	return ""
 }
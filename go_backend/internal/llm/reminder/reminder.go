@@ -0,0 +1,246 @@
+// Package reminder composes the <system-reminder> blocks appended to a user
+// message from the reminders attached to its turn: plan mode's, the response
+// format's, the configured defaults, the session's and the turn's own. Every turn composes
+// them in the same order, so the same attachments always produce the same
+// message.
+package reminder
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mix/internal/config"
+	"mix/internal/llm/prompt"
+	"mix/internal/llm/responseformat"
+	"mix/internal/logging"
+)
+
+// How a reminder was attached to a turn
+const (
+	SourcePlanMode       = "planMode"
+	SourceResponseFormat = "responseFormat"
+	SourceTurn           = "turn"
+	SourceSession        = "session"
+	SourceDefault        = "default"
+)
+
+// Orders of the built-in reminders; custom reminders default to 0
+const (
+	orderPlanMode       = -200
+	orderContextRefresh = -100
+	orderTokenBudget    = 100
+	orderResponseFormat = 200
+)
+
+// defaultTokenBudgetPercent is the context window share token_budget warns
+// above when the config doesn't set one
+const defaultTokenBudgetPercent = 80
+
+// Reminder is a reminder composed for a turn.
+type Reminder struct {
+	Name string
+	// Source is how the reminder was attached. One attached several ways is
+	// sent once and reports the first of plan mode, turn, session and default.
+	Source string
+	Order  int
+	Text   string
+}
+
+// Turn is what the reminders of a turn depend on.
+type Turn struct {
+	WorkingDirectory string
+	PlanMode         bool
+	// Session and Turn name the reminders attached to the session and to
+	// this turn alone
+	Session []string
+	Turn    []string
+	// ContextTokens is the size of the conversation as of the last response,
+	// ContextWindow the model's
+	ContextTokens int64
+	ContextWindow int64
+	// ResponseFormat is the format the turn's final response must have
+	ResponseFormat *responseformat.Format
+}
+
+// Exists reports whether name is a built-in or configured reminder.
+func Exists(name string) bool {
+	switch name {
+	case config.ReminderPlanMode, config.ReminderContextRefresh, config.ReminderTokenBudget, config.ReminderResponseFormat:
+		return true
+	}
+	_, ok := custom(name)
+	return ok
+}
+
+// Validate fails for the first name that isn't a reminder sessions and turns
+// can attach. plan_mode comes with plan mode only, response_format with a
+// response format.
+func Validate(names []string) error {
+	for _, name := range names {
+		if name == config.ReminderPlanMode {
+			return fmt.Errorf("reminder %s is attached by plan mode", name)
+		}
+		if name == config.ReminderResponseFormat {
+			return fmt.Errorf("reminder %s is attached by responseFormat", name)
+		}
+		if !Exists(name) {
+			return fmt.Errorf("unknown reminder %q", name)
+		}
+	}
+	return nil
+}
+
+// Compose returns the reminders of turn in the order they are sent: by order,
+// then by name. Reminders with nothing to say, such as token_budget while the
+// conversation is small, are left out. A turn reminder that doesn't exist is
+// an error, while session and default ones removed from the config since are
+// skipped.
+func Compose(turn Turn) ([]Reminder, error) {
+	if err := Validate(turn.Turn); err != nil {
+		return nil, err
+	}
+	attached := []struct{ name, source string }{}
+	seen := map[string]bool{}
+	attach := func(source string, names ...string) {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				attached = append(attached, struct{ name, source string }{name, source})
+			}
+		}
+	}
+	if turn.PlanMode {
+		attach(SourcePlanMode, config.ReminderPlanMode)
+	}
+	if turn.ResponseFormat != nil {
+		attach(SourceResponseFormat, config.ReminderResponseFormat)
+	}
+	attach(SourceTurn, turn.Turn...)
+	attach(SourceSession, turn.Session...)
+	attach(SourceDefault, config.Get().Reminders.Default...)
+
+	reminders := []Reminder{}
+	for _, a := range attached {
+		if !Exists(a.name) {
+			logging.Warn("Skipping reminder that is no longer configured", "reminder", a.name, "source", a.source)
+			continue
+		}
+		reminder, err := render(a.name, turn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose reminder %s: %w", a.name, err)
+		}
+		if reminder.Text == "" {
+			continue
+		}
+		reminder.Source = a.source
+		reminders = append(reminders, reminder)
+	}
+	slices.SortFunc(reminders, func(a, b Reminder) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), strings.Compare(a.Name, b.Name))
+	})
+	return reminders, nil
+}
+
+// Inject appends reminders to the content of a user message.
+func Inject(content string, reminders []Reminder) string {
+	var b strings.Builder
+	b.WriteString(content)
+	for _, reminder := range reminders {
+		b.WriteString("\n\n<system-reminder>\n")
+		b.WriteString(reminder.Text)
+		b.WriteString("\n</system-reminder>")
+	}
+	return b.String()
+}
+
+// Only reports whether the content of a user message is made of reminders
+// alone, as the corrections the agent sends itself are.
+func Only(content string) bool {
+	return strings.HasPrefix(content, "<system-reminder>")
+}
+
+func render(name string, turn Turn) (Reminder, error) {
+	switch name {
+	case config.ReminderPlanMode:
+		text, err := prompt.LoadPrompt("plan_mode")
+		return Reminder{Name: name, Order: orderPlanMode, Text: text}, err
+
+	case config.ReminderResponseFormat:
+		reminder := Reminder{Name: name, Order: orderResponseFormat}
+		if turn.ResponseFormat == nil {
+			return reminder, nil
+		}
+		text, err := prompt.LoadPromptWithVars("response_format", map[string]string{
+			"format": turn.ResponseFormat.Instruction(),
+		})
+		reminder.Text = strings.TrimSpace(text)
+		return reminder, err
+
+	case config.ReminderContextRefresh:
+		reminder := Reminder{Name: name, Order: orderContextRefresh}
+		if turn.WorkingDirectory == "" {
+			return reminder, nil
+		}
+		content, err := prompt.ContextFiles(turn.WorkingDirectory)
+		if err != nil || content == "" {
+			return reminder, err
+		}
+		reminder.Text = "The project instructions as they are now, which replace any earlier version in this conversation:\n" + content
+		return reminder, nil
+
+	case config.ReminderTokenBudget:
+		reminder := Reminder{Name: name, Order: orderTokenBudget}
+		threshold := config.Get().Reminders.TokenBudgetPercent
+		if threshold == 0 {
+			threshold = defaultTokenBudgetPercent
+		}
+		if turn.ContextWindow <= 0 {
+			return reminder, nil
+		}
+		percent := turn.ContextTokens * 100 / turn.ContextWindow
+		if percent < int64(threshold) {
+			return reminder, nil
+		}
+		text, err := prompt.LoadPromptWithVars("token_budget", map[string]string{
+			"used_percent":   strconv.FormatInt(percent, 10),
+			"used_tokens":    strconv.FormatInt(turn.ContextTokens, 10),
+			"context_window": strconv.FormatInt(turn.ContextWindow, 10),
+		})
+		reminder.Text = strings.TrimSpace(text)
+		return reminder, err
+	}
+
+	c, _ := custom(name)
+	reminder := Reminder{Name: name, Order: c.Order, Text: c.Text}
+	if c.File == "" {
+		return reminder, nil
+	}
+	path := c.File
+	if !filepath.IsAbs(path) {
+		if turn.WorkingDirectory == "" {
+			return reminder, nil
+		}
+		path = filepath.Join(turn.WorkingDirectory, path)
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		logging.Debug("Reminder file not found", "reminder", name, "path", path)
+		return reminder, nil
+	}
+	reminder.Text = strings.TrimSpace(string(content))
+	return reminder, err
+}
+
+func custom(name string) (config.Reminder, bool) {
+	for _, reminder := range config.Get().Reminders.Custom {
+		if reminder.Name == name {
+			return reminder, true
+		}
+	}
+	return config.Reminder{}, false
+}
@@ -0,0 +1,183 @@
+// Package responseformat constrains a turn's final response to JSON, either
+// any JSON object or a value matching a JSON Schema. Providers that support
+// it enforce the format while generating; the agent validates every response
+// either way and asks once for a corrected one.
+package responseformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mix/internal/jsonschema"
+)
+
+// Format types, named as in OpenAI's response_format
+const (
+	TypeJSONObject = "json_object"
+	TypeJSONSchema = "json_schema"
+)
+
+// ToolName is the tool providers without a response format parameter, such as
+// Anthropic, are given to answer with: its input schema is the response's.
+const ToolName = "structured_response"
+
+// WrapProperty holds the response in the tool input when the response isn't
+// an object, as tool inputs must be.
+const WrapProperty = "response"
+
+// DefaultName names a schema sent without a name.
+const DefaultName = "response"
+
+// ErrInvalid wraps the problems of a response that doesn't match its format.
+var ErrInvalid = errors.New("response does not match the response format")
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Format is the responseFormat parameter of messages.send.
+type Format struct {
+	// Type is TypeJSONObject or TypeJSONSchema
+	Type string `json:"type"`
+	// Name identifies the schema to the provider (default: response)
+	Name string `json:"name,omitempty"`
+	// Schema is the JSON Schema responses of TypeJSONSchema must match
+	Schema json.RawMessage `json:"schema,omitempty"`
+	// Strict has OpenAI enforce the schema while generating. It requires
+	// every object to list all its properties as required and disallow
+	// additional ones.
+	Strict bool `json:"strict,omitempty"`
+
+	parsed *jsonschema.Schema
+}
+
+// Validate checks the type, name and schema. A nil Format is valid.
+func (f *Format) Validate() error {
+	if f == nil {
+		return nil
+	}
+	switch f.Type {
+	case TypeJSONObject:
+		if len(f.Schema) > 0 {
+			return fmt.Errorf("responseFormat.schema is only used with type %s", TypeJSONSchema)
+		}
+	case TypeJSONSchema:
+		if len(f.Schema) == 0 {
+			return fmt.Errorf("responseFormat.schema is required with type %s", TypeJSONSchema)
+		}
+		schema, err := jsonschema.Parse(f.Schema)
+		if err != nil {
+			return fmt.Errorf("responseFormat.schema: %w", err)
+		}
+		f.parsed = schema
+	default:
+		return fmt.Errorf("unknown responseFormat.type %q: must be %s or %s", f.Type, TypeJSONObject, TypeJSONSchema)
+	}
+	if f.Name != "" && !namePattern.MatchString(f.Name) {
+		return fmt.Errorf("invalid responseFormat.name %q: use up to 64 letters, digits, _ and -", f.Name)
+	}
+	return nil
+}
+
+// SchemaName returns Name, or DefaultName when unset.
+func (f *Format) SchemaName() string {
+	if f.Name == "" {
+		return DefaultName
+	}
+	return f.Name
+}
+
+// SchemaMap returns the schema responses must match as a map, for provider
+// SDKs taking one. A JSON object format has {"type": "object"}.
+func (f *Format) SchemaMap() map[string]any {
+	schema := map[string]any{"type": "object"}
+	if f.Type == TypeJSONSchema {
+		json.Unmarshal(f.Schema, &schema)
+	}
+	return schema
+}
+
+// ToolSchema returns the input schema of ToolName: the response's schema when
+// it describes an object, else an object holding the response under
+// WrapProperty, in which case wrapped is true.
+func (f *Format) ToolSchema() (schema map[string]any, wrapped bool) {
+	schema = f.SchemaMap()
+	if schema["type"] == "object" {
+		return schema, false
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{WrapProperty: schema},
+		"required":   []any{WrapProperty},
+	}, true
+}
+
+// Instruction describes the format to the model, for providers that can't
+// enforce it.
+func (f *Format) Instruction() string {
+	if f.Type == TypeJSONObject {
+		return "a JSON object"
+	}
+	var schema bytes.Buffer
+	if err := json.Indent(&schema, f.Schema, "", "  "); err != nil {
+		schema.Write(f.Schema)
+	}
+	return "a JSON value matching this JSON Schema:\n" + schema.String()
+}
+
+// Check extracts the JSON from a response, which may be wrapped in a code
+// fence or surrounded by prose, and validates it against the format. It
+// returns the JSON, compacted; its error wraps ErrInvalid.
+func (f *Format) Check(response string) (json.RawMessage, error) {
+	data, ok := extract(response)
+	if !ok {
+		return nil, fmt.Errorf("%w: no JSON value found", ErrInvalid)
+	}
+	if f.Type == TypeJSONObject {
+		if !bytes.HasPrefix(data, []byte("{")) {
+			return nil, fmt.Errorf("%w: must be a JSON object", ErrInvalid)
+		}
+		return data, nil
+	}
+	if f.parsed == nil {
+		schema, err := jsonschema.Parse(f.Schema)
+		if err != nil {
+			return nil, err
+		}
+		f.parsed = schema
+	}
+	if err := f.parsed.Validate(data); err != nil {
+		return nil, fmt.Errorf("%w:\n%w", ErrInvalid, err)
+	}
+	return data, nil
+}
+
+var fence = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\n(.*?)\n?```")
+
+// extract returns the compacted JSON value of a response: all of it, the
+// content of its first code fence, or the span from its first { or [ to the
+// last matching bracket.
+func extract(response string) (json.RawMessage, bool) {
+	candidates := []string{strings.TrimSpace(response)}
+	if match := fence.FindStringSubmatch(response); match != nil {
+		candidates = append(candidates, match[1])
+	}
+	if start := strings.IndexAny(response, "{["); start >= 0 {
+		closing := "}"
+		if response[start] == '[' {
+			closing = "]"
+		}
+		if end := strings.LastIndex(response, closing); end > start {
+			candidates = append(candidates, response[start:end+1])
+		}
+	}
+	for _, candidate := range candidates {
+		var compacted bytes.Buffer
+		if json.Valid([]byte(candidate)) && json.Compact(&compacted, []byte(candidate)) == nil {
+			return compacted.Bytes(), true
+		}
+	}
+	return nil, false
+}
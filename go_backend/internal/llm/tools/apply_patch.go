@@ -0,0 +1,574 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mix/internal/history"
+	"mix/internal/logging"
+	"mix/internal/permission"
+)
+
+type ApplyPatchParams struct {
+	Patch string `json:"patch"`
+}
+
+type ApplyPatchPermissionsParams struct {
+	Files []string `json:"files"`
+	Diff  string   `json:"diff"`
+}
+
+type ApplyPatchResponseMetadata struct {
+	Files     []string `json:"files"`
+	Diff      string   `json:"diff"`
+	Additions int      `json:"additions"`
+	Removals  int      `json:"removals"`
+}
+
+type applyPatchTool struct {
+	permissions permission.Service
+	files       history.Service
+}
+
+const (
+	ApplyPatchToolName = "apply_patch"
+
+	devNull = "/dev/null"
+)
+
+func NewApplyPatchTool(permissions permission.Service, files history.Service) BaseTool {
+	return &applyPatchTool{
+		permissions: permissions,
+		files:       files,
+	}
+}
+
+func (a *applyPatchTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ApplyPatchToolName,
+		Description: LoadToolDescription("apply_patch"),
+		Parameters: map[string]any{
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "A unified diff with ---/+++ file headers and @@ hunks, covering one or more files",
+			},
+		},
+		Required: []string{"patch"},
+	}
+}
+
+// filePatch is the part of a patch that changes one file.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []hunk
+	// diff is the file's section of the patch, for the permission preview
+	diff string
+}
+
+type hunk struct {
+	oldStart int
+	oldCount int
+	lines    []hunkLine
+}
+
+// hunkLine is a context (' '), removed ('-') or added ('+') line. text keeps
+// its line ending unless the patch marks it "\ No newline at end of file".
+type hunkLine struct {
+	op   byte
+	text string
+}
+
+// fileChange is a validated change, ready to be written.
+type fileChange struct {
+	path       string
+	oldContent string
+	newContent string
+	create     bool
+	delete     bool
+	additions  int
+	removals   int
+}
+
+func (a *applyPatchTool) DescribesDryRun() {}
+
+func (a *applyPatchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ApplyPatchParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if strings.TrimSpace(params.Patch) == "" {
+		return NewTextErrorResponse("patch is required"), nil
+	}
+
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session_id and message_id are required")
+	}
+
+	patches, err := parsePatch(params.Patch)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("invalid patch: %s", err)), nil
+	}
+
+	// Validate every file before asking for permission, so the user only
+	// reviews patches that apply cleanly
+	var changes []fileChange
+	var files []string
+	var diff strings.Builder
+	seen := make(map[string]bool)
+	for _, patch := range patches {
+		change, err := preparePatch(workingDir, patch)
+		if err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+		if seen[change.path] {
+			return NewTextErrorResponse(fmt.Sprintf("patch changes %s more than once; combine its hunks under one file header", change.path)), nil
+		}
+		seen[change.path] = true
+		changes = append(changes, change)
+		files = append(files, change.path)
+		diff.WriteString(patch.diff)
+	}
+
+	if call.State.DryRun {
+		effect := DryRunEffect{Tool: ApplyPatchToolName}
+		for _, change := range changes {
+			action := "update"
+			if change.create {
+				action = "create"
+			} else if change.delete {
+				action = "delete"
+			}
+			effect.Files = append(effect.Files, dryRunFileChange(action, change.path, change.oldContent, change.newContent))
+		}
+		return NewDryRunResponse(effect), nil
+	}
+
+	permissionPath := workingDir
+	for _, path := range files {
+		if !strings.HasPrefix(path, workingDir) {
+			permissionPath = filepath.Dir(path)
+			break
+		}
+	}
+	p := a.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        permissionPath,
+			ToolName:    ApplyPatchToolName,
+			Action:      "write",
+			Description: fmt.Sprintf("Apply patch to %s", strings.Join(files, ", ")),
+			Params: ApplyPatchPermissionsParams{
+				Files: files,
+				Diff:  diff.String(),
+			},
+		},
+	)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	if err := commitChanges(changes); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to apply patch, no files were changed: %s", err)), nil
+	}
+
+	additions, removals := 0, 0
+	for _, change := range changes {
+		additions += change.additions
+		removals += change.removals
+		a.recordHistory(ctx, sessionID, change)
+		recordFileWrite(change.path)
+		recordFileRead(change.path)
+	}
+
+	result := fmt.Sprintf("<result>\nPatch applied to %d file(s): %s\n</result>", len(files), strings.Join(files, ", "))
+	return WithResponseMetadata(NewTextResponse(result),
+		ApplyPatchResponseMetadata{
+			Files:     files,
+			Diff:      diff.String(),
+			Additions: additions,
+			Removals:  removals,
+		},
+	), nil
+}
+
+func (a *applyPatchTool) recordHistory(ctx context.Context, sessionID string, change fileChange) {
+	file, err := a.files.GetByPathAndSession(ctx, change.path, sessionID)
+	if err != nil {
+		if _, err := a.files.Create(ctx, sessionID, change.path, change.oldContent); err != nil {
+			logging.Debug("Error creating file history", "error", err)
+			return
+		}
+	} else if file.Content != change.oldContent {
+		// The file was changed outside the session; store an intermediate version
+		if _, err := a.files.CreateVersion(ctx, sessionID, change.path, change.oldContent); err != nil {
+			logging.Debug("Error creating file history version", "error", err)
+		}
+	}
+	if _, err := a.files.CreateVersion(ctx, sessionID, change.path, change.newContent); err != nil {
+		logging.Debug("Error creating file history version", "error", err)
+	}
+}
+
+// preparePatch checks a file patch against the file on disk and computes the
+// new contents.
+func preparePatch(workingDir string, patch filePatch) (fileChange, error) {
+	if patch.oldPath != devNull && patch.newPath != devNull && patch.oldPath != patch.newPath {
+		return fileChange{}, fmt.Errorf("renaming %s to %s is not supported; use bash mv, then patch the new path", patch.oldPath, patch.newPath)
+	}
+	change := fileChange{
+		path:   patch.newPath,
+		create: patch.oldPath == devNull,
+		delete: patch.newPath == devNull,
+	}
+	if change.delete {
+		change.path = patch.oldPath
+	}
+	if !filepath.IsAbs(change.path) {
+		change.path = filepath.Join(workingDir, change.path)
+	}
+
+	info, err := os.Stat(change.path)
+	switch {
+	case change.create && err == nil:
+		return fileChange{}, fmt.Errorf("cannot create %s: file already exists", change.path)
+	case !change.create && os.IsNotExist(err):
+		return fileChange{}, fmt.Errorf("file not found: %s", change.path)
+	case err != nil && !os.IsNotExist(err):
+		return fileChange{}, fmt.Errorf("failed to access %s: %w", change.path, err)
+	case err == nil && info.IsDir():
+		return fileChange{}, fmt.Errorf("path is a directory, not a file: %s", change.path)
+	}
+	if !change.create {
+		content, err := os.ReadFile(change.path)
+		if err != nil {
+			return fileChange{}, fmt.Errorf("failed to read %s: %w", change.path, err)
+		}
+		change.oldContent = string(content)
+	}
+
+	change.newContent, err = applyHunks(change.oldContent, patch.hunks)
+	if err != nil {
+		return fileChange{}, fmt.Errorf("%s: %w", change.path, err)
+	}
+	if change.delete && change.newContent != "" {
+		return fileChange{}, fmt.Errorf("%s: deleting patch does not remove the whole file", change.path)
+	}
+	if !change.delete && change.newContent == change.oldContent {
+		return fileChange{}, fmt.Errorf("%s: patch makes no changes", change.path)
+	}
+	for _, h := range patch.hunks {
+		for _, line := range h.lines {
+			switch line.op {
+			case '+':
+				change.additions++
+			case '-':
+				change.removals++
+			}
+		}
+	}
+	return change, nil
+}
+
+// commitChanges writes all changes or none: new contents are staged in temp
+// files next to their targets, then renamed into place, and files already
+// replaced are restored if a later rename fails.
+func commitChanges(changes []fileChange) error {
+	staged := make([]string, len(changes))
+	cleanup := func() {
+		for _, tmp := range staged {
+			if tmp != "" {
+				os.Remove(tmp)
+			}
+		}
+	}
+	for i, change := range changes {
+		if change.delete {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(change.path), 0o755); err != nil {
+			cleanup()
+			return err
+		}
+		tmp, err := stageFile(change)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		staged[i] = tmp
+	}
+
+	for i, change := range changes {
+		var err error
+		if change.delete {
+			err = os.Remove(change.path)
+		} else if err = os.Rename(staged[i], change.path); err == nil {
+			staged[i] = ""
+		}
+		if err != nil {
+			cleanup()
+			rollback(changes[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+func stageFile(change fileChange) (string, error) {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(change.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(change.path), "."+filepath.Base(change.path)+".patch-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(change.newContent); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// rollback restores files already changed by commitChanges.
+func rollback(changes []fileChange) {
+	for _, change := range changes {
+		var err error
+		if change.create {
+			err = os.Remove(change.path)
+		} else {
+			err = os.WriteFile(change.path, []byte(change.oldContent), 0o644)
+		}
+		if err != nil {
+			logging.Error("Failed to roll back patched file", "path", change.path, "error", err)
+		}
+	}
+}
+
+// applyHunks applies hunks in order. A hunk whose context isn't at its stated
+// line is searched for nearby, like patch(1) does, but never before the
+// previous hunk.
+func applyHunks(content string, hunks []hunk) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var out []string
+	pos := 0
+	for i, h := range hunks {
+		var oldLines, newLines []string
+		for _, line := range h.lines {
+			if line.op != '+' {
+				oldLines = append(oldLines, line.text)
+			}
+			if line.op != '-' {
+				newLines = append(newLines, line.text)
+			}
+		}
+		// For pure insertions the old start is the line to insert after
+		want := h.oldStart - 1
+		if h.oldCount == 0 {
+			want = h.oldStart
+		}
+		at := findLines(lines, oldLines, want, pos)
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d (@@ -%d,%d @@) does not match the current file contents; view the file and regenerate the patch", i+1, h.oldStart, h.oldCount)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, newLines...)
+		pos = at + len(oldLines)
+	}
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, ""), nil
+}
+
+// findLines returns the index of old in lines closest to want and not before
+// min, or -1.
+func findLines(lines, old []string, want, min int) int {
+	last := len(lines) - len(old)
+	matches := func(at int) bool {
+		if at < min || at > last {
+			return false
+		}
+		for j, line := range old {
+			// Tolerate a missing newline at the end of the file
+			if strings.TrimSuffix(lines[at+j], "\n") != strings.TrimSuffix(line, "\n") {
+				return false
+			}
+		}
+		return true
+	}
+	for offset := 0; want-offset >= min || want+offset <= last; offset++ {
+		if matches(want - offset) {
+			return want - offset
+		}
+		if matches(want + offset) {
+			return want + offset
+		}
+	}
+	return -1
+}
+
+// parsePatch parses a unified diff. Git extended headers and other lines
+// outside file sections are ignored.
+func parsePatch(text string) ([]filePatch, error) {
+	lines := strings.SplitAfter(text, "\n")
+	var patches []filePatch
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		start := i
+		patch := filePatch{
+			oldPath: patchPath(lines[i][4:]),
+			newPath: patchPath(lines[i+1][4:]),
+		}
+		// Git prefixes paths with a/ and b/
+		if strings.HasPrefix(patch.oldPath, "a/") && strings.HasPrefix(patch.newPath, "b/") {
+			patch.oldPath, patch.newPath = patch.oldPath[2:], patch.newPath[2:]
+		} else if patch.oldPath == devNull && strings.HasPrefix(patch.newPath, "b/") {
+			patch.newPath = patch.newPath[2:]
+		} else if patch.newPath == devNull && strings.HasPrefix(patch.oldPath, "a/") {
+			patch.oldPath = patch.oldPath[2:]
+		}
+		if patch.oldPath == devNull && patch.newPath == devNull {
+			return nil, fmt.Errorf("line %d: both paths are %s", i+1, devNull)
+		}
+		i += 2
+
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@") {
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			patch.hunks = append(patch.hunks, h)
+			i = next
+		}
+		if len(patch.hunks) == 0 {
+			return nil, fmt.Errorf("line %d: no hunks for %s", start+1, patch.newPath)
+		}
+		patch.diff = strings.Join(lines[start:i], "")
+		if !strings.HasSuffix(patch.diff, "\n") {
+			patch.diff += "\n"
+		}
+		patches = append(patches, patch)
+		i--
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file sections found; each file needs --- and +++ header lines followed by @@ hunks")
+	}
+	return patches, nil
+}
+
+// parseHunk parses the hunk starting at lines[i], returning the index of the
+// line after it.
+func parseHunk(lines []string, i int) (hunk, int, error) {
+	header := strings.TrimSpace(lines[i])
+	var oldRange, newRange string
+	if fields := strings.Fields(header); len(fields) >= 4 && fields[0] == "@@" && strings.HasPrefix(fields[1], "-") && strings.HasPrefix(fields[2], "+") {
+		oldRange, newRange = fields[1][1:], fields[2][1:]
+	} else {
+		return hunk{}, 0, fmt.Errorf("line %d: malformed hunk header %q", i+1, header)
+	}
+	oldStart, oldCount, err := parseRange(oldRange)
+	if err != nil {
+		return hunk{}, 0, fmt.Errorf("line %d: %w", i+1, err)
+	}
+	_, newCount, err := parseRange(newRange)
+	if err != nil {
+		return hunk{}, 0, fmt.Errorf("line %d: %w", i+1, err)
+	}
+
+	h := hunk{oldStart: oldStart, oldCount: oldCount}
+	oldSeen, newSeen := 0, 0
+	i++
+	for ; i < len(lines) && (oldSeen < oldCount || newSeen < newCount); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, `\`) {
+			h.trimNewline()
+			continue
+		}
+		op := byte(' ')
+		text := "\n"
+		// Editors and models often strip the space from empty context lines
+		if line != "\n" && line != "" {
+			op, text = line[0], line[1:]
+		}
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		switch op {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		default:
+			return hunk{}, 0, fmt.Errorf("line %d: unexpected %q in hunk", i+1, strings.TrimSuffix(line, "\n"))
+		}
+		h.lines = append(h.lines, hunkLine{op: op, text: text})
+	}
+	if oldSeen != oldCount || newSeen != newCount {
+		return hunk{}, 0, fmt.Errorf("hunk %q has %d old and %d new lines, expected %d and %d", header, oldSeen, newSeen, oldCount, newCount)
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], `\`) {
+		h.trimNewline()
+		i++
+	}
+	return h, i, nil
+}
+
+// trimNewline applies "\ No newline at end of file" to the last line.
+func (h *hunk) trimNewline() {
+	if len(h.lines) > 0 {
+		last := &h.lines[len(h.lines)-1]
+		last.text = strings.TrimSuffix(last.text, "\n")
+	}
+}
+
+// parseRange parses "start,count" or "start", where count defaults to 1.
+func parseRange(value string) (int, int, error) {
+	startText, countText, hasCount := strings.Cut(value, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk range %q", value)
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return 0, 0, fmt.Errorf("invalid hunk range %q", value)
+		}
+	}
+	return start, count, nil
+}
+
+// patchPath strips the timestamp diff(1) appends after a tab.
+func patchPath(value string) string {
+	value = strings.TrimRight(value, "\r\n")
+	if before, _, found := strings.Cut(value, "\t"); found {
+		value = before
+	}
+	return strings.TrimSpace(value)
+}
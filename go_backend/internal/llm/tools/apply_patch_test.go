@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		patch    string
+		expected map[string]string
+		deleted  []string
+		errText  string
+	}{
+		{
+			name:  "multiple hunks in one file",
+			files: map[string]string{"a.txt": "one\ntwo\nthree\nfour\nfive\nsix\n"},
+			patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n-one\n+ONE\n two\n@@ -5,2 +5,3 @@\n five\n six\n+seven\n",
+			expected: map[string]string{
+				"a.txt": "ONE\ntwo\nthree\nfour\nfive\nsix\nseven\n",
+			},
+		},
+		{
+			name:  "several files at once",
+			files: map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			patch: "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-a\n+A\n--- b.txt\n+++ b.txt\n@@ -1 +1 @@\n-b\n+B\n",
+			expected: map[string]string{
+				"a.txt": "A\n",
+				"b.txt": "B\n",
+			},
+		},
+		{
+			name:     "hunks are found when their line numbers are off",
+			files:    map[string]string{"a.txt": "x\nx\none\ntwo\n"},
+			patch:    "--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n",
+			expected: map[string]string{"a.txt": "x\nx\none\nTWO\n"},
+		},
+		{
+			name:     "empty context lines without their space",
+			files:    map[string]string{"a.txt": "a\n\nb\n"},
+			patch:    "--- a.txt\n+++ a.txt\n@@ -1,3 +1,3 @@\n a\n\n-b\n+B\n",
+			expected: map[string]string{"a.txt": "a\n\nB\n"},
+		},
+		{
+			name:     "no newline at end of file",
+			files:    map[string]string{"a.txt": "a\nb"},
+			patch:    "--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+			expected: map[string]string{"a.txt": "a\nc"},
+		},
+		{
+			name:     "creating a file in a new directory",
+			patch:    "--- /dev/null\n+++ b/dir/new.txt\n@@ -0,0 +1,2 @@\n+hello\n+world\n",
+			expected: map[string]string{"dir/new.txt": "hello\nworld\n"},
+		},
+		{
+			name:    "deleting a file",
+			files:   map[string]string{"a.txt": "a\nb\n"},
+			patch:   "--- a/a.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+			deleted: []string{"a.txt"},
+		},
+		{
+			name:     "a hunk that doesn't match changes no file",
+			files:    map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			patch:    "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-a\n+A\n--- b.txt\n+++ b.txt\n@@ -1 +1 @@\n-x\n+X\n",
+			expected: map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			errText:  "does not match the current file contents",
+		},
+		{
+			name:     "creating a file that exists",
+			files:    map[string]string{"a.txt": "a\n"},
+			patch:    "--- /dev/null\n+++ a.txt\n@@ -0,0 +1 @@\n+b\n",
+			expected: map[string]string{"a.txt": "a\n"},
+			errText:  "file already exists",
+		},
+		{
+			name:    "patching a missing file",
+			patch:   "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-a\n+A\n",
+			errText: "file not found",
+		},
+		{
+			name:     "deleting part of a file",
+			files:    map[string]string{"a.txt": "a\nb\n"},
+			patch:    "--- a.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n",
+			expected: map[string]string{"a.txt": "a\nb\n"},
+			errText:  "does not remove the whole file",
+		},
+		{
+			name:    "renames are refused",
+			files:   map[string]string{"a.txt": "a\n"},
+			patch:   "--- a/a.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			errText: "renaming",
+		},
+		{
+			name:    "hunk line counts must add up",
+			files:   map[string]string{"a.txt": "a\n"},
+			patch:   "--- a.txt\n+++ a.txt\n@@ -1,3 +1 @@\n-a\n+A\n",
+			errText: "expected 3 and 1",
+		},
+		{
+			name:    "patches need file headers",
+			patch:   "@@ -1 +1 @@\n-a\n+A\n",
+			errText: "no file sections found",
+		},
+		{
+			name:    "malformed hunk headers",
+			files:   map[string]string{"a.txt": "a\n"},
+			patch:   "--- a.txt\n+++ a.txt\n@@ one @@\n-a\n+A\n",
+			errText: "malformed hunk header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for path, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0o644))
+			}
+
+			err := applyPatch(dir, tt.patch)
+			if tt.errText != "" {
+				assert.ErrorContains(t, err, tt.errText)
+			} else {
+				require.NoError(t, err)
+			}
+			for path, content := range tt.expected {
+				data, err := os.ReadFile(filepath.Join(dir, path))
+				require.NoError(t, err)
+				assert.Equal(t, content, string(data), path)
+			}
+			for _, path := range tt.deleted {
+				assert.NoFileExists(t, filepath.Join(dir, path))
+			}
+			// Staged temp files never stay behind
+			entries, err := filepath.Glob(filepath.Join(dir, ".*.patch-*"))
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+// applyPatch validates and writes a patch the way the tool does after
+// permission is granted.
+func applyPatch(workingDir, patch string) error {
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return err
+	}
+	var changes []fileChange
+	for _, p := range patches {
+		change, err := preparePatch(workingDir, p)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+	return commitChanges(changes)
+}
+
+func TestCommitChangesKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.sh")
+	require.NoError(t, os.WriteFile(path, []byte("echo a\n"), 0o755))
+
+	require.NoError(t, applyPatch(dir, "--- run.sh\n+++ run.sh\n@@ -1 +1 @@\n-echo a\n+echo b\n"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+}
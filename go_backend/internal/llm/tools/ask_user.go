@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mix/internal/question"
+)
+
+const AskUserToolName = "ask_user"
+
+type AskUserParams struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	// AllowFreeText accepts answers other than the options
+	AllowFreeText bool `json:"allow_free_text"`
+}
+
+type AskUserResponseMetadata struct {
+	QuestionID string `json:"question_id"`
+	Resolution string `json:"resolution"`
+	Answer     string `json:"answer,omitempty"`
+}
+
+type askUserTool struct {
+	questions question.Service
+}
+
+func NewAskUserTool(questions question.Service) BaseTool {
+	return &askUserTool{questions: questions}
+}
+
+func (t *askUserTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        AskUserToolName,
+		Description: LoadToolDescription(AskUserToolName),
+		Parameters: map[string]any{
+			"question": map[string]any{
+				"type":        "string",
+				"description": "The question to ask the user",
+			},
+			"options": map[string]any{
+				"type":        "array",
+				"description": "Answers for the user to choose from; leave out for a free-text answer",
+				"items":       map[string]any{"type": "string"},
+			},
+			"allow_free_text": map[string]any{
+				"type":        "boolean",
+				"description": "Accept answers other than the options (default: false when there are options)",
+			},
+		},
+		Required: []string{"question"},
+	}
+}
+
+func (t *askUserTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params AskUserParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.Question == "" {
+		return NewTextErrorResponse("question is required"), nil
+	}
+
+	asked, err := t.questions.Ask(ctx, question.CreateQuestion{
+		SessionID:     call.State.SessionID,
+		ToolCallID:    call.ID,
+		Question:      params.Question,
+		Options:       params.Options,
+		AllowFreeText: params.AllowFreeText,
+	})
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ToolResponse{}, err
+	}
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	text := "The user answered: " + asked.Answer
+	if asked.Resolution != question.ResolutionAnswered {
+		text = "The user did not answer in time. Go on with the most reasonable choice and say which one you made."
+	}
+	return WithResponseMetadata(NewTextResponse(text), AskUserResponseMetadata{
+		QuestionID: asked.ID,
+		Resolution: string(asked.Resolution),
+		Answer:     asked.Answer,
+	}), nil
+}
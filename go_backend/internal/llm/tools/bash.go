@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"mix/internal/config"
 	"mix/internal/llm/tools/shell"
 	"mix/internal/permission"
 )
@@ -81,6 +82,8 @@ func (b *bashTool) Info() ToolInfo {
 	}
 }
 
+func (b *bashTool) DescribesDryRun() {}
+
 func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 	var params BashParams
 	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
@@ -116,15 +119,24 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		}
 	}
 
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 	startTime := time.Now()
-	workingDir, err := GetWorkingDirectory(ctx)
+	workingDir, err := call.State.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
+
+	// Even read-only commands aren't run, as they can still be chained with others
+	if call.State.DryRun {
+		return NewDryRunResponse(DryRunEffect{
+			Tool:             BashToolName,
+			Command:          params.Command,
+			WorkingDirectory: workingDir,
+		}), nil
+	}
 	
 	if !isSafeReadOnly {
 		p := b.permissions.Request(
@@ -144,7 +156,18 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		}
 	}
 	
-	shell := shell.GetPersistentShell(workingDir)
+	sandbox, err := sandboxProfile(call.State)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	if sandbox != nil && sandbox.TimeoutSeconds > 0 {
+		params.Timeout = min(params.Timeout, sandbox.TimeoutSeconds*1000)
+	}
+
+	shell, err := shell.GetPersistentShell(sessionID, workingDir, call.State.Environ(), sandbox)
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to start shell: %w", err)
+	}
 	stdout, stderr, exitCode, interrupted, err := shell.Exec(ctx, params.Command, params.Timeout)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error executing command: %w", err)
@@ -186,6 +209,28 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	return WithResponseMetadata(NewTextResponse(stdout), metadata), nil
 }
 
+// sandboxProfile returns the sandbox profile the session's commands run in,
+// or nil when they run unconfined. A selected profile that is no longer
+// configured is an error rather than no sandbox.
+func sandboxProfile(state RequestState) (*config.SandboxProfile, error) {
+	cfg := config.Get()
+	if cfg == nil {
+		return nil, nil
+	}
+	name := state.SandboxProfile
+	if name == "" {
+		name = cfg.DefaultSandboxProfile
+	}
+	if name == "" {
+		return nil, nil
+	}
+	profile, ok := cfg.SandboxProfile(name)
+	if !ok {
+		return nil, fmt.Errorf("sandbox profile %s is not configured", name)
+	}
+	return &profile, nil
+}
+
 func truncateOutput(content string) string {
 	if len(content) <= MaxOutputLength {
 		return content
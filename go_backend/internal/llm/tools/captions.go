@@ -0,0 +1,535 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mix/internal/captions"
+	"mix/internal/permission"
+)
+
+const (
+	GenerateCaptionsToolName = "generate_captions"
+	EditCaptionsToolName     = "edit_captions"
+	BurnSubtitlesToolName    = "burn_subtitles"
+)
+
+// durationTolerance is how far a render may drift from its source before the
+// result flags it, in seconds
+const durationTolerance = 0.5
+
+type generateCaptionsTool struct {
+	permissions permission.Service
+}
+
+type editCaptionsTool struct {
+	permissions permission.Service
+}
+
+type burnSubtitlesTool struct {
+	permissions permission.Service
+}
+
+type GenerateCaptionsParams struct {
+	TranscriptPath string  `json:"transcript_path"`
+	Transcript     string  `json:"transcript"`
+	MediaPath      string  `json:"media_path"`
+	OutputPath     string  `json:"output_path"`
+	MaxLineChars   int     `json:"max_line_chars"`
+	MaxCueSeconds  float64 `json:"max_cue_seconds"`
+}
+
+type EditCaptionsParams struct {
+	Path       string        `json:"path"`
+	OutputPath string        `json:"output_path"`
+	Cues       []CaptionEdit `json:"cues"`
+	ScaleBy    float64       `json:"scale"`
+	ShiftMs    int64         `json:"shift_ms"`
+	MediaPath  string        `json:"media_path"`
+}
+
+// CaptionEdit changes one cue, addressed by its 1-based index in the input file.
+type CaptionEdit struct {
+	Index  int     `json:"index"`
+	Start  *string `json:"start,omitempty"`
+	End    *string `json:"end,omitempty"`
+	Text   *string `json:"text,omitempty"`
+	Delete bool    `json:"delete,omitempty"`
+}
+
+type BurnSubtitlesParams struct {
+	VideoPath    string `json:"video_path"`
+	CaptionsPath string `json:"captions_path"`
+	OutputPath   string `json:"output_path"`
+	Style        string `json:"style"`
+}
+
+type CaptionsPermissionsParams struct {
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+}
+
+// CaptionsResult describes a written caption file and, when a media file was
+// given, how its timing lines up with the media.
+type CaptionsResult struct {
+	Path          string              `json:"path"`
+	Format        captions.Format     `json:"format"`
+	CueCount      int                 `json:"cueCount"`
+	FirstCueStart float64             `json:"firstCueStart"`
+	LastCueEnd    float64             `json:"lastCueEnd"`
+	Media         *captions.MediaInfo `json:"media,omitempty"`
+	Warnings      []string            `json:"warnings,omitempty"`
+	Preview       string              `json:"preview"`
+}
+
+type BurnSubtitlesResult struct {
+	Output   captions.MediaInfo `json:"output"`
+	Source   captions.MediaInfo `json:"source"`
+	Warnings []string           `json:"warnings,omitempty"`
+}
+
+func NewGenerateCaptionsTool(permissions permission.Service) BaseTool {
+	return &generateCaptionsTool{permissions: permissions}
+}
+
+func NewEditCaptionsTool(permissions permission.Service) BaseTool {
+	return &editCaptionsTool{permissions: permissions}
+}
+
+func NewBurnSubtitlesTool(permissions permission.Service) BaseTool {
+	return &burnSubtitlesTool{permissions: permissions}
+}
+
+func (t *generateCaptionsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GenerateCaptionsToolName,
+		Description: LoadToolDescription(GenerateCaptionsToolName),
+		Parameters: map[string]any{
+			"transcript_path": map[string]any{
+				"type":        "string",
+				"description": "Path to the transcription output: JSON with timed segments or words, or plain text",
+			},
+			"transcript": map[string]any{
+				"type":        "string",
+				"description": "The transcription output inline, instead of transcript_path",
+			},
+			"media_path": map[string]any{
+				"type":        "string",
+				"description": "The audio or video the captions belong to. Required for plain text transcripts, which are timed across its duration",
+			},
+			"output_path": map[string]any{
+				"type":        "string",
+				"description": "Caption file to write, ending in .srt or .vtt (default: output/text/<media or transcript name>.srt)",
+			},
+			"max_line_chars": map[string]any{
+				"type":        "integer",
+				"description": "Maximum characters per caption line, two lines per cue (default: 42)",
+			},
+			"max_cue_seconds": map[string]any{
+				"type":        "number",
+				"description": "Maximum time a cue stays on screen (default: 7)",
+			},
+		},
+		Required: []string{},
+	}
+}
+
+func (t *generateCaptionsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GenerateCaptionsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+	if (params.TranscriptPath == "") == (params.Transcript == "") {
+		return NewTextErrorResponse("provide exactly one of transcript_path or transcript"), nil
+	}
+
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	transcript := []byte(params.Transcript)
+	if params.TranscriptPath != "" {
+		transcript, err = os.ReadFile(resolvePath(workingDir, params.TranscriptPath))
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("Failed to read transcript: %v", err)), nil
+		}
+	}
+
+	layout := captions.DefaultLayout
+	if params.MaxLineChars > 0 {
+		layout.MaxLineChars = params.MaxLineChars
+	}
+	if params.MaxCueSeconds > 0 {
+		layout.MaxDuration = time.Duration(params.MaxCueSeconds * float64(time.Second))
+	}
+
+	var media *captions.MediaInfo
+	if params.MediaPath != "" {
+		info, err := captions.Probe(ctx, resolvePath(workingDir, params.MediaPath))
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("Failed to probe media: %v", err)), nil
+		}
+		media = &info
+	}
+
+	var cues []captions.Cue
+	if json.Valid(transcript) {
+		cues, err = captions.FromTranscript(transcript, layout)
+	} else if media != nil {
+		cues, err = captions.FromText(string(transcript), seconds(media.Duration), layout)
+	} else {
+		err = fmt.Errorf("plain text transcripts have no timing; pass media_path so the text can be timed across its duration")
+	}
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(cues) == 0 {
+		return NewTextErrorResponse("transcript contains no caption text"), nil
+	}
+
+	outputPath := params.OutputPath
+	if outputPath == "" {
+		source := params.MediaPath
+		if source == "" {
+			source = params.TranscriptPath
+		}
+		if source == "" {
+			source = "captions"
+		}
+		name := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+		outputPath = filepath.Join("output", "text", name+".srt")
+	}
+	outputPath = resolvePath(workingDir, outputPath)
+
+	return writeCaptions(t.permissions, call, GenerateCaptionsToolName, workingDir, outputPath, cues, media)
+}
+
+func (t *editCaptionsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        EditCaptionsToolName,
+		Description: LoadToolDescription(EditCaptionsToolName),
+		Parameters: map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "The .srt or .vtt file to edit",
+			},
+			"output_path": map[string]any{
+				"type":        "string",
+				"description": "Where to write the result (default: overwrite path). A different extension converts between SRT and VTT",
+			},
+			"cues": map[string]any{
+				"type":        "array",
+				"description": "Changes to individual cues, addressed by their 1-based index in the input file",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"index": map[string]any{
+							"type":        "integer",
+							"description": "1-based cue number",
+						},
+						"start": map[string]any{
+							"type":        "string",
+							"description": "New start time as HH:MM:SS.mmm",
+						},
+						"end": map[string]any{
+							"type":        "string",
+							"description": "New end time as HH:MM:SS.mmm",
+						},
+						"text": map[string]any{
+							"type":        "string",
+							"description": "New cue text; use \\n for a line break",
+						},
+						"delete": map[string]any{
+							"type":        "boolean",
+							"description": "Remove the cue",
+						},
+					},
+					"required": []string{"index"},
+				},
+			},
+			"scale": map[string]any{
+				"type":        "number",
+				"description": "Multiply every timestamp by this factor, applied after cue edits",
+			},
+			"shift_ms": map[string]any{
+				"type":        "integer",
+				"description": "Move every cue by this many milliseconds, negative for earlier, applied last",
+			},
+			"media_path": map[string]any{
+				"type":        "string",
+				"description": "The audio or video to check the edited timing against",
+			},
+		},
+		Required: []string{"path"},
+	}
+}
+
+func (t *editCaptionsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params EditCaptionsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+	if params.Path == "" {
+		return NewTextErrorResponse("path is required"), nil
+	}
+	if len(params.Cues) == 0 && params.ScaleBy == 0 && params.ShiftMs == 0 && params.OutputPath == "" {
+		return NewTextErrorResponse("nothing to change: pass cues, scale, shift_ms or output_path"), nil
+	}
+	if params.ScaleBy < 0 {
+		return NewTextErrorResponse("scale must be positive"), nil
+	}
+
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	path := resolvePath(workingDir, params.Path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Failed to read captions: %v", err)), nil
+	}
+	cues, err := captions.Parse(string(data))
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Failed to parse %s: %v", path, err)), nil
+	}
+
+	cues, err = applyCaptionEdits(cues, params.Cues)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if params.ScaleBy > 0 {
+		cues = captions.Scale(cues, params.ScaleBy)
+	}
+	if params.ShiftMs != 0 {
+		cues = captions.Shift(cues, time.Duration(params.ShiftMs)*time.Millisecond)
+	}
+	if len(cues) == 0 {
+		return NewTextErrorResponse("the edit leaves no cues"), nil
+	}
+
+	var media *captions.MediaInfo
+	if params.MediaPath != "" {
+		info, err := captions.Probe(ctx, resolvePath(workingDir, params.MediaPath))
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("Failed to probe media: %v", err)), nil
+		}
+		media = &info
+	}
+
+	outputPath := path
+	if params.OutputPath != "" {
+		outputPath = resolvePath(workingDir, params.OutputPath)
+	}
+	return writeCaptions(t.permissions, call, EditCaptionsToolName, workingDir, outputPath, cues, media)
+}
+
+func applyCaptionEdits(cues []captions.Cue, edits []CaptionEdit) ([]captions.Cue, error) {
+	deleted := make(map[int]bool)
+	for _, edit := range edits {
+		if edit.Index < 1 || edit.Index > len(cues) {
+			return nil, fmt.Errorf("cue index %d is out of range 1-%d", edit.Index, len(cues))
+		}
+		cue := &cues[edit.Index-1]
+		if edit.Delete {
+			deleted[edit.Index-1] = true
+			continue
+		}
+		if edit.Start != nil {
+			start, err := captions.ParseTimestamp(*edit.Start)
+			if err != nil {
+				return nil, fmt.Errorf("cue %d start: %w", edit.Index, err)
+			}
+			cue.Start = start
+		}
+		if edit.End != nil {
+			end, err := captions.ParseTimestamp(*edit.End)
+			if err != nil {
+				return nil, fmt.Errorf("cue %d end: %w", edit.Index, err)
+			}
+			cue.End = end
+		}
+		if edit.Text != nil {
+			cue.Text = strings.ReplaceAll(*edit.Text, `\n`, "\n")
+		}
+	}
+
+	result := make([]captions.Cue, 0, len(cues))
+	for i, cue := range cues {
+		if !deleted[i] {
+			result = append(result, cue)
+		}
+	}
+	return result, nil
+}
+
+// writeCaptions validates cues, asks permission and writes them in the format
+// the output extension names.
+func writeCaptions(permissions permission.Service, call ToolCall, toolName, workingDir, outputPath string, cues []captions.Cue, media *captions.MediaInfo) (ToolResponse, error) {
+	format, err := captions.FormatFromPath(outputPath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if err := captions.Validate(cues); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Captions are not valid: %v", err)), nil
+	}
+
+	if !requestAssetWrite(permissions, call, toolName, workingDir, outputPath, "", fmt.Sprintf("Write captions to %s", outputPath)) {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	content := captions.Render(cues, format)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return ToolResponse{}, fmt.Errorf("error creating directory: %w", err)
+	}
+	if err := os.WriteFile(outputPath, []byte(content), 0o644); err != nil {
+		return ToolResponse{}, fmt.Errorf("error writing captions: %w", err)
+	}
+	recordFileWrite(outputPath)
+	recordFileRead(outputPath)
+
+	result := CaptionsResult{
+		Path:          outputPath,
+		Format:        format,
+		CueCount:      len(cues),
+		FirstCueStart: cues[0].Start.Seconds(),
+		LastCueEnd:    captions.Duration(cues).Seconds(),
+		Media:         media,
+		Preview:       captions.Render(cues[:min(len(cues), 3)], format),
+	}
+	if media != nil && result.LastCueEnd > media.Duration+durationTolerance {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("captions end at %.3fs, after the media ends at %.3fs", result.LastCueEnd, media.Duration))
+	}
+	return jsonResponse(result)
+}
+
+func (t *burnSubtitlesTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        BurnSubtitlesToolName,
+		Description: LoadToolDescription(BurnSubtitlesToolName),
+		Parameters: map[string]any{
+			"video_path": map[string]any{
+				"type":        "string",
+				"description": "The video to caption",
+			},
+			"captions_path": map[string]any{
+				"type":        "string",
+				"description": "The .srt or .vtt file to burn in",
+			},
+			"output_path": map[string]any{
+				"type":        "string",
+				"description": "The new video to write (default: output/videos/<video name>_subtitled<ext>)",
+			},
+			"style": map[string]any{
+				"type":        "string",
+				"description": "ASS style overrides, e.g. FontName=Inter,FontSize=24,Outline=2,MarginV=40",
+			},
+		},
+		Required: []string{"video_path", "captions_path"},
+	}
+}
+
+func (t *burnSubtitlesTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params BurnSubtitlesParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+	if params.VideoPath == "" || params.CaptionsPath == "" {
+		return NewTextErrorResponse("video_path and captions_path are required"), nil
+	}
+
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	videoPath := resolvePath(workingDir, params.VideoPath)
+	captionsPath := resolvePath(workingDir, params.CaptionsPath)
+	if _, err := captions.FormatFromPath(captionsPath); err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if _, err := os.Stat(captionsPath); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Captions not found: %v", err)), nil
+	}
+
+	outputPath := params.OutputPath
+	if outputPath == "" {
+		ext := filepath.Ext(videoPath)
+		outputPath = filepath.Join("output", "videos", strings.TrimSuffix(filepath.Base(videoPath), ext)+"_subtitled"+ext)
+	}
+	outputPath = resolvePath(workingDir, outputPath)
+	if outputPath == videoPath {
+		return NewTextErrorResponse("output_path must differ from video_path"), nil
+	}
+
+	source, err := captions.Probe(ctx, videoPath)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Failed to probe video: %v", err)), nil
+	}
+
+	if !requestAssetWrite(t.permissions, call, BurnSubtitlesToolName, workingDir, outputPath, videoPath,
+		fmt.Sprintf("Burn %s into %s and write %s", filepath.Base(captionsPath), filepath.Base(videoPath), outputPath)) {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return ToolResponse{}, fmt.Errorf("error creating directory: %w", err)
+	}
+	if err := captions.Burn(ctx, videoPath, captionsPath, outputPath, params.Style); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Failed to burn subtitles: %v", err)), nil
+	}
+
+	output, err := captions.Probe(ctx, outputPath)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Subtitled video was written to %s but could not be probed: %v", outputPath, err)), nil
+	}
+	result := BurnSubtitlesResult{Output: output, Source: source}
+	if math.Abs(output.Duration-source.Duration) > durationTolerance {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("output lasts %.3fs but the source lasts %.3fs", output.Duration, source.Duration))
+	}
+	return jsonResponse(result)
+}
+
+// requestAssetWrite asks to create a file derived from the session's media,
+// scoped to the working directory like the write tool.
+func requestAssetWrite(permissions permission.Service, call ToolCall, toolName, workingDir, outputPath, source, description string) bool {
+	permissionPath := filepath.Dir(outputPath)
+	if strings.HasPrefix(outputPath, workingDir) {
+		permissionPath = workingDir
+	}
+	return permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   call.State.SessionID,
+		Path:        permissionPath,
+		ToolName:    toolName,
+		Action:      "write",
+		Description: description,
+		Params: CaptionsPermissionsParams{
+			Path:   outputPath,
+			Source: source,
+		},
+	})
+}
+
+func resolvePath(workingDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workingDir, path)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
+
+func jsonResponse(v any) (ToolResponse, error) {
+	output, err := json.Marshal(v)
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return NewTextResponse(string(output)), nil
+}
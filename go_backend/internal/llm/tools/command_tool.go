@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/netpolicy"
+	"mix/internal/permission"
+)
+
+const (
+	// maxCommandToolOutput bounds the stdout kept of a command tool call; the
+	// agent limits what the model sees further
+	maxCommandToolOutput = 1 << 20
+	// maxCommandToolStderr bounds the stderr reported when a call fails
+	maxCommandToolStderr = 16 << 10
+	// commandToolWaitDelay is how long a killed command's output pipes may
+	// stay open, e.g. held by its children, before they are closed
+	commandToolWaitDelay = 2 * time.Second
+)
+
+type CommandToolPermissionsParams struct {
+	Command string          `json:"command"`
+	Args    []string        `json:"args,omitempty"`
+	Input   json.RawMessage `json:"input"`
+}
+
+// commandToolOutput is the structured result a command may print instead of
+// plain text.
+type commandToolOutput struct {
+	Content  *string         `json:"content"`
+	IsError  bool            `json:"isError"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+type commandTool struct {
+	config      config.CommandTool
+	permissions permission.Service
+}
+
+// NewCommandTool returns the tool running the executable cfg configures.
+func NewCommandTool(cfg config.CommandTool, permissions permission.Service) BaseTool {
+	return &commandTool{config: cfg, permissions: permissions}
+}
+
+// IsCommandTool reports whether tool runs a configured executable.
+func IsCommandTool(tool BaseTool) bool {
+	_, ok := tool.(*commandTool)
+	return ok
+}
+
+func (t *commandTool) Info() ToolInfo {
+	parameters, _ := t.config.InputSchema["properties"].(map[string]any)
+	if parameters == nil {
+		parameters = make(map[string]any)
+	}
+	required := make([]string, 0)
+	if names, ok := t.config.InputSchema["required"].([]any); ok {
+		for _, name := range names {
+			if name, ok := name.(string); ok {
+				required = append(required, name)
+			}
+		}
+	}
+	return ToolInfo{
+		Name:        t.config.Name,
+		Description: t.config.Description,
+		Parameters:  parameters,
+		Required:    required,
+	}
+}
+
+func (t *commandTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for running %s", t.config.Name)
+	}
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	input := strings.TrimSpace(call.Input)
+	if input == "" {
+		input = "{}"
+	}
+	if !json.Valid([]byte(input)) {
+		return NewTextErrorResponse("invalid parameters: input is not JSON"), nil
+	}
+
+	if !t.config.AutoApprove {
+		p := t.permissions.Request(
+			permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        workingDir,
+				ToolName:    t.config.Name,
+				Action:      "execute",
+				Description: fmt.Sprintf("Run %s with the following input: %s", t.config.Command, input),
+				Params: CommandToolPermissionsParams{
+					Command: t.config.Command,
+					Args:    t.config.Args,
+					Input:   json.RawMessage(input),
+				},
+			},
+		)
+		if !p {
+			return ToolResponse{}, permission.ErrorPermissionDenied
+		}
+	}
+
+	timeout := time.Duration(t.config.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = config.DefaultCommandToolTimeoutSeconds * time.Second
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	env, err := t.environ(call)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	cmd := exec.CommandContext(runCtx, t.config.Command, t.config.Args...)
+	cmd.Dir = workingDir
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(input)
+	stdout := &cappedBuffer{limit: maxCommandToolOutput}
+	stderr := &cappedBuffer{limit: maxCommandToolStderr}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = commandToolWaitDelay
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return ToolResponse{}, ctx.Err()
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return NewTextErrorResponse(fmt.Sprintf("%s timed out after %s", t.config.Name, timeout)), nil
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return NewTextErrorResponse(fmt.Sprintf("failed to run %s: %v", t.config.Command, err)), nil
+		}
+		message := fmt.Sprintf("%s exited with status %d", t.config.Name, exitErr.ExitCode())
+		if details := strings.TrimSpace(stderr.String()); details != "" {
+			message += ":\n" + details
+		} else if details := strings.TrimSpace(stdout.String()); details != "" {
+			message += ":\n" + details
+		}
+		return NewTextErrorResponse(message), nil
+	}
+	return commandToolResponse(stdout), nil
+}
+
+// environ returns the command's environment: the server's, the session's,
+// the tool's configured variables, the egress proxy's, and MIX_* variables
+// describing the call. Later entries win.
+func (t *commandTool) environ(call ToolCall) ([]string, error) {
+	proxyEnv, err := netpolicy.Default().SubprocessEnv()
+	if err != nil {
+		return nil, err
+	}
+	env := append(os.Environ(), call.State.Environ()...)
+	env = append(env, t.config.Env...)
+	env = append(env, proxyEnv...)
+	return append(env,
+		"MIX_TOOL_NAME="+t.config.Name,
+		"MIX_TOOL_CALL_ID="+call.ID,
+		"MIX_SESSION_ID="+call.State.SessionID,
+		"MIX_WORKING_DIRECTORY="+call.State.WorkingDirectory,
+	), nil
+}
+
+// commandToolResponse reads a command's stdout: a JSON object with content
+// is a structured result, anything else plain text.
+func commandToolResponse(stdout *cappedBuffer) ToolResponse {
+	text := stdout.String()
+	if stdout.truncated {
+		text += fmt.Sprintf("\n\n[output truncated at %d bytes]", maxCommandToolOutput)
+	}
+	var output commandToolOutput
+	if stdout.truncated || json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &output) != nil || output.Content == nil {
+		return NewTextResponse(text)
+	}
+	response := NewTextResponse(*output.Content)
+	response.IsError = output.IsError
+	if len(output.Metadata) > 0 && string(output.Metadata) != "null" {
+		response.Metadata = string(output.Metadata)
+	}
+	return response
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a chatty command can't exhaust memory.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DryRunTool is implemented by tools whose Run, in a dry run, describes what
+// the call would do instead of doing it. The agent answers dry-run calls of
+// other tools that could change anything with the call's input alone.
+type DryRunTool interface {
+	BaseTool
+	DescribesDryRun()
+}
+
+// DryRunEffect is the metadata of a dry-run response: what the call would
+// have done had it run.
+type DryRunEffect struct {
+	DryRun bool   `json:"dry_run"`
+	Tool   string `json:"tool"`
+	// Command and WorkingDirectory are set for shell commands
+	Command          string `json:"command,omitempty"`
+	WorkingDirectory string `json:"working_directory,omitempty"`
+	// Files are the file changes, with diffs against the files as they are
+	Files []DryRunFileChange `json:"files,omitempty"`
+	// Input is the call's input, for tools that can't describe their effects
+	Input string `json:"input,omitempty"`
+}
+
+type DryRunFileChange struct {
+	Path string `json:"path"`
+	// Action is "create", "update" or "delete"
+	Action    string `json:"action"`
+	Diff      string `json:"diff"`
+	Additions int    `json:"additions"`
+	Removals  int    `json:"removals"`
+}
+
+// NewDryRunResponse returns the response of a dry-run call, telling the model
+// that nothing happened and what would have.
+func NewDryRunResponse(effect DryRunEffect) ToolResponse {
+	effect.DryRun = true
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dry run: %s was not executed. ", effect.Tool)
+	switch {
+	case effect.Command != "":
+		fmt.Fprintf(&b, "It would run this command in %s:\n%s\n", effect.WorkingDirectory, effect.Command)
+	case len(effect.Files) > 0:
+		b.WriteString("It would make these changes:\n")
+		for _, file := range effect.Files {
+			fmt.Fprintf(&b, "%s %s (+%d -%d)\n", file.Action, file.Path, file.Additions, file.Removals)
+		}
+	default:
+		fmt.Fprintf(&b, "It would be called with:\n%s\n", effect.Input)
+	}
+	b.WriteString("Continue as if the call succeeded, without relying on its output.")
+	return WithResponseMetadata(NewTextResponse(b.String()), effect)
+}
+
+// dryRunFileChange describes action, the change of path from oldContent to
+// newContent, with a unified diff.
+func dryRunFileChange(action, path, oldContent, newContent string) DryRunFileChange {
+	change := DryRunFileChange{Path: path, Action: action}
+	fromFile, toFile := path, path
+	switch action {
+	case "create":
+		fromFile = devNull
+	case "delete":
+		toFile = devNull
+	}
+	oldLines, newLines := diffLines(oldContent), diffLines(newContent)
+	change.Diff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        oldLines,
+		B:        newLines,
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+	for _, op := range difflib.NewMatcher(oldLines, newLines).GetOpCodes() {
+		if op.Tag == 'r' || op.Tag == 'd' {
+			change.Removals += op.I2 - op.I1
+		}
+		if op.Tag == 'r' || op.Tag == 'i' {
+			change.Additions += op.J2 - op.J1
+		}
+	}
+	return change
+}
+
+// diffLines splits content into lines that all end in a newline, as the diff
+// expects.
+func diffLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
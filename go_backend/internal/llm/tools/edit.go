@@ -69,6 +69,8 @@ func (e *editTool) Info() ToolInfo {
 	}
 }
 
+func (e *editTool) DescribesDryRun() {}
+
 func (e *editTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 	var params EditParams
 	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
@@ -80,7 +82,7 @@ func (e *editTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	}
 
 	if !filepath.IsAbs(params.FilePath) {
-		workingDir, err := GetWorkingDirectory(ctx)
+		workingDir, err := call.State.RequireWorkingDirectory()
 		if err != nil {
 			return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 		}
@@ -90,21 +92,14 @@ func (e *editTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	var response ToolResponse
 	var err error
 
-	if params.OldString == "" {
-		response, err = e.createNewFile(ctx, params.FilePath, params.NewString)
-		if err != nil {
-			return response, err
-		}
-	}
-
-	if params.NewString == "" {
-		response, err = e.deleteContent(ctx, params.FilePath, params.OldString)
-		if err != nil {
-			return response, err
-		}
+	switch {
+	case params.OldString == "":
+		response, err = e.createNewFile(ctx, call.State, params.FilePath, params.NewString)
+	case params.NewString == "":
+		response, err = e.deleteContent(ctx, call.State, params.FilePath, params.OldString)
+	default:
+		response, err = e.replaceContent(ctx, call.State, params.FilePath, params.OldString, params.NewString)
 	}
-
-	response, err = e.replaceContent(ctx, params.FilePath, params.OldString, params.NewString)
 	if err != nil {
 		return response, err
 	}
@@ -120,7 +115,7 @@ func (e *editTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	return response, nil
 }
 
-func (e *editTool) createNewFile(ctx context.Context, filePath, content string) (ToolResponse, error) {
+func (e *editTool) createNewFile(ctx context.Context, state RequestState, filePath, content string) (ToolResponse, error) {
 	fileInfo, err := os.Stat(filePath)
 	if err == nil {
 		if fileInfo.IsDir() {
@@ -131,12 +126,7 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 		return ToolResponse{}, fmt.Errorf("failed to access file: %w", err)
 	}
 
-	dir := filepath.Dir(filePath)
-	if err = os.MkdirAll(dir, 0o755); err != nil {
-		return ToolResponse{}, fmt.Errorf("failed to create parent directories: %w", err)
-	}
-
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := state.SessionID, state.MessageID
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
@@ -149,7 +139,13 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 	}
 	additions := len(lines)
 	removals := 0
-	rootDir, err := GetWorkingDirectory(ctx)
+	if state.DryRun {
+		return NewDryRunResponse(DryRunEffect{
+			Tool:  EditToolName,
+			Files: []DryRunFileChange{dryRunFileChange("create", filePath, "", content)},
+		}), nil
+	}
+	rootDir, err := state.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
@@ -174,6 +170,10 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 		return ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
+	if err = os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to create parent directories: %w", err)
+	}
+
 	err = os.WriteFile(filePath, []byte(content), 0o644)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
@@ -206,7 +206,7 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 	), nil
 }
 
-func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string) (ToolResponse, error) {
+func (e *editTool) deleteContent(ctx context.Context, state RequestState, filePath, oldString string) (ToolResponse, error) {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -251,7 +251,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 
 	newContent := oldContent[:index] + oldContent[index+len(oldString):]
 
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := state.SessionID, state.MessageID
 
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
@@ -263,8 +263,14 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	newLines := strings.Split(newContent, "\n")
 	additions := len(newLines)
 	removals := len(oldLines)
+	if state.DryRun {
+		return NewDryRunResponse(DryRunEffect{
+			Tool:  EditToolName,
+			Files: []DryRunFileChange{dryRunFileChange("update", filePath, oldContent, newContent)},
+		}), nil
+	}
 
-	rootDir, err := GetWorkingDirectory(ctx)
+	rootDir, err := state.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
@@ -329,7 +335,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	), nil
 }
 
-func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newString string) (ToolResponse, error) {
+func (e *editTool) replaceContent(ctx context.Context, state RequestState, filePath, oldString, newString string) (ToolResponse, error) {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -377,7 +383,7 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	if oldContent == newContent {
 		return NewTextErrorResponse("new content is the same as old content. No changes made."), nil
 	}
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := state.SessionID, state.MessageID
 
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
@@ -388,7 +394,13 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	newLines := strings.Split(newContent, "\n")
 	additions := len(newLines)
 	removals := len(oldLines)
-	rootDir, err := GetWorkingDirectory(ctx)
+	if state.DryRun {
+		return NewDryRunResponse(DryRunEffect{
+			Tool:  EditToolName,
+			Files: []DryRunFileChange{dryRunFileChange("update", filePath, oldContent, newContent)},
+		}), nil
+	}
+	rootDir, err := state.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"mix/internal/netpolicy"
 	"mix/internal/permission"
 
 	md "github.com/JohannesKaufmann/html-to-markdown"
@@ -38,9 +39,7 @@ const (
 
 func NewFetchTool(permissions permission.Service) BaseTool {
 	return &fetchTool{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:      netpolicy.Default().Client(30 * time.Second),
 		permissions: permissions,
 	}
 }
@@ -87,12 +86,12 @@ func (t *fetchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return NewTextErrorResponse("URL must start with http:// or https://"), nil
 	}
 
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 
-	workingDir, err := GetWorkingDirectory(ctx)
+	workingDir, err := call.State.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
@@ -118,9 +117,7 @@ func (t *fetchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		if params.Timeout > maxTimeout {
 			params.Timeout = maxTimeout
 		}
-		client = &http.Client{
-			Timeout: time.Duration(params.Timeout) * time.Second,
-		}
+		client = netpolicy.Default().Client(time.Duration(params.Timeout) * time.Second)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", params.URL, nil)
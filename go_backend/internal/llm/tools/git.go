@@ -0,0 +1,840 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"mix/internal/permission"
+)
+
+const (
+	GitStatusToolName = "git_status"
+	GitDiffToolName   = "git_diff"
+	GitLogToolName    = "git_log"
+	GitCommitToolName = "git_commit"
+	GitBranchToolName = "git_branch"
+
+	DefaultGitLogEntries = 20
+	MaxGitLogEntries     = 200
+)
+
+// Branch actions of git_branch
+const (
+	GitBranchList   = "list"
+	GitBranchCreate = "create"
+	GitBranchSwitch = "switch"
+	GitBranchDelete = "delete"
+)
+
+type GitStatusParams struct {
+	Paths []string `json:"paths"`
+}
+
+type GitFileStatus struct {
+	Path string `json:"path"`
+	// OrigPath is the path a renamed or copied file had
+	OrigPath string `json:"orig_path,omitempty"`
+	// Status is "added", "modified", "deleted", "renamed", "copied" or
+	// "type changed"
+	Status string `json:"status"`
+}
+
+type GitStatusResponseMetadata struct {
+	// Branch is empty when HEAD is detached
+	Branch     string          `json:"branch"`
+	Commit     string          `json:"commit,omitempty"`
+	Upstream   string          `json:"upstream,omitempty"`
+	Ahead      int             `json:"ahead"`
+	Behind     int             `json:"behind"`
+	Staged     []GitFileStatus `json:"staged"`
+	Unstaged   []GitFileStatus `json:"unstaged"`
+	Untracked  []string        `json:"untracked"`
+	Conflicted []string        `json:"conflicted"`
+	Clean      bool            `json:"clean"`
+}
+
+type GitDiffParams struct {
+	// Staged diffs the index against HEAD instead of the working tree
+	// against the index
+	Staged bool `json:"staged"`
+	// Ref diffs against a commit, or between two with a range such as
+	// main...feature
+	Ref   string   `json:"ref"`
+	Paths []string `json:"paths"`
+}
+
+type GitDiffFile struct {
+	Path string `json:"path"`
+	// OrigPath is the path a renamed file had
+	OrigPath  string `json:"orig_path,omitempty"`
+	Additions int    `json:"additions"`
+	Removals  int    `json:"removals"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+type GitDiffResponseMetadata struct {
+	Files     []GitDiffFile `json:"files"`
+	Additions int           `json:"additions"`
+	Removals  int           `json:"removals"`
+	Truncated bool          `json:"truncated"`
+}
+
+type GitLogParams struct {
+	Ref      string `json:"ref"`
+	Path     string `json:"path"`
+	MaxCount int    `json:"max_count"`
+}
+
+type GitCommitInfo struct {
+	Commit      string    `json:"commit"`
+	ShortCommit string    `json:"short_commit"`
+	Author      string    `json:"author"`
+	Email       string    `json:"email"`
+	Date        time.Time `json:"date"`
+	Subject     string    `json:"subject"`
+}
+
+type GitLogResponseMetadata struct {
+	Commits []GitCommitInfo `json:"commits"`
+}
+
+type GitCommitParams struct {
+	Message string `json:"message"`
+	// Paths are staged before committing
+	Paths []string `json:"paths"`
+	// All stages every change to tracked files, as git commit -a
+	All bool `json:"all"`
+}
+
+type GitCommitPermissionsParams struct {
+	Message string   `json:"message"`
+	Paths   []string `json:"paths,omitempty"`
+	All     bool     `json:"all,omitempty"`
+}
+
+type GitCommitResponseMetadata struct {
+	Commit string   `json:"commit"`
+	Branch string   `json:"branch"`
+	Files  []string `json:"files"`
+}
+
+type GitBranchParams struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	// StartPoint is the commit a created branch starts at (default: HEAD)
+	StartPoint string `json:"start_point"`
+	// Remote lists remote-tracking branches too
+	Remote bool `json:"remote"`
+}
+
+type GitBranchPermissionsParams struct {
+	Action     string `json:"action"`
+	Name       string `json:"name"`
+	StartPoint string `json:"start_point,omitempty"`
+}
+
+type GitBranchInfo struct {
+	Name     string `json:"name"`
+	Commit   string `json:"commit"`
+	Upstream string `json:"upstream,omitempty"`
+	Current  bool   `json:"current"`
+	Remote   bool   `json:"remote,omitempty"`
+}
+
+type GitBranchResponseMetadata struct {
+	Action   string          `json:"action"`
+	Current  string          `json:"current"`
+	Branches []GitBranchInfo `json:"branches,omitempty"`
+}
+
+type gitStatusTool struct{}
+
+type gitDiffTool struct{}
+
+type gitLogTool struct{}
+
+type gitCommitTool struct {
+	permissions permission.Service
+}
+
+type gitBranchTool struct {
+	permissions permission.Service
+}
+
+func NewGitStatusTool() BaseTool {
+	return &gitStatusTool{}
+}
+
+func NewGitDiffTool() BaseTool {
+	return &gitDiffTool{}
+}
+
+func NewGitLogTool() BaseTool {
+	return &gitLogTool{}
+}
+
+func NewGitCommitTool(permissions permission.Service) BaseTool {
+	return &gitCommitTool{permissions: permissions}
+}
+
+func NewGitBranchTool(permissions permission.Service) BaseTool {
+	return &gitBranchTool{permissions: permissions}
+}
+
+var gitPathsParameter = map[string]any{
+	"type":        "array",
+	"description": "Limit to these files or directories, relative to the working directory",
+	"items": map[string]any{
+		"type": "string",
+	},
+}
+
+func (t *gitStatusTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GitStatusToolName,
+		Description: LoadToolDescription(GitStatusToolName),
+		Parameters: map[string]any{
+			"paths": gitPathsParameter,
+		},
+		Required: []string{},
+	}
+}
+
+func (t *gitStatusTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GitStatusParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	args := append([]string{"status", "--porcelain=v2", "--branch", "--untracked-files=all", "--"}, params.Paths...)
+	out, err := runGit(ctx, call.State, workingDir, args...)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	status := parseGitStatus(out)
+	return WithResponseMetadata(NewTextResponse(formatGitStatus(status)), status), nil
+}
+
+// parseGitStatus parses the output of git status --porcelain=v2 --branch.
+func parseGitStatus(out string) GitStatusResponseMetadata {
+	status := GitStatusResponseMetadata{
+		Staged:     []GitFileStatus{},
+		Unstaged:   []GitFileStatus{},
+		Untracked:  []string{},
+		Conflicted: []string{},
+	}
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.oid "):
+			if oid := strings.TrimPrefix(line, "# branch.oid "); oid != "(initial)" {
+				status.Commit = oid
+			}
+		case strings.HasPrefix(line, "# branch.head "):
+			if head := strings.TrimPrefix(line, "# branch.head "); head != "(detached)" {
+				status.Branch = head
+			}
+		case strings.HasPrefix(line, "# branch.upstream "):
+			status.Upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &status.Ahead, &status.Behind)
+		case strings.HasPrefix(line, "1 "):
+			// 1 XY sub mH mI mW hH hI path
+			fields := strings.SplitN(line, " ", 9)
+			if len(fields) == 9 {
+				status.addChange(fields[1], fields[8], "")
+			}
+		case strings.HasPrefix(line, "2 "):
+			// 2 XY sub mH mI mW hH hI Xscore path<tab>origPath
+			fields := strings.SplitN(line, " ", 10)
+			if len(fields) == 10 {
+				path, origPath, _ := strings.Cut(fields[9], "\t")
+				status.addChange(fields[1], path, origPath)
+			}
+		case strings.HasPrefix(line, "u "):
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			fields := strings.SplitN(line, " ", 11)
+			if len(fields) == 11 {
+				status.Conflicted = append(status.Conflicted, fields[10])
+			}
+		case strings.HasPrefix(line, "? "):
+			status.Untracked = append(status.Untracked, strings.TrimPrefix(line, "? "))
+		}
+	}
+	status.Clean = len(status.Staged)+len(status.Unstaged)+len(status.Untracked)+len(status.Conflicted) == 0
+	return status
+}
+
+func (s *GitStatusResponseMetadata) addChange(xy, path, origPath string) {
+	if len(xy) != 2 {
+		return
+	}
+	if xy[0] != '.' {
+		s.Staged = append(s.Staged, GitFileStatus{Path: path, OrigPath: origPath, Status: gitChangeStatus(xy[0])})
+	}
+	if xy[1] != '.' {
+		s.Unstaged = append(s.Unstaged, GitFileStatus{Path: path, OrigPath: origPath, Status: gitChangeStatus(xy[1])})
+	}
+}
+
+func gitChangeStatus(code byte) string {
+	switch code {
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	case 'T':
+		return "type changed"
+	default:
+		return "modified"
+	}
+}
+
+func formatGitStatus(status GitStatusResponseMetadata) string {
+	var b strings.Builder
+	if status.Branch != "" {
+		fmt.Fprintf(&b, "On branch %s", status.Branch)
+	} else {
+		fmt.Fprintf(&b, "HEAD detached at %s", shortCommit(status.Commit))
+	}
+	if status.Upstream != "" {
+		fmt.Fprintf(&b, ", tracking %s (ahead %d, behind %d)", status.Upstream, status.Ahead, status.Behind)
+	}
+	b.WriteString("\n")
+	if status.Clean {
+		b.WriteString("Nothing to commit, working tree clean\n")
+		return b.String()
+	}
+	writeChanges := func(title string, changes []GitFileStatus) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, change := range changes {
+			if change.OrigPath != "" {
+				fmt.Fprintf(&b, "  %s: %s -> %s\n", change.Status, change.OrigPath, change.Path)
+			} else {
+				fmt.Fprintf(&b, "  %s: %s\n", change.Status, change.Path)
+			}
+		}
+	}
+	writePaths := func(title string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, path := range paths {
+			fmt.Fprintf(&b, "  %s\n", path)
+		}
+	}
+	writePaths("Conflicted", status.Conflicted)
+	writeChanges("Staged", status.Staged)
+	writeChanges("Not staged", status.Unstaged)
+	writePaths("Untracked", status.Untracked)
+	return b.String()
+}
+
+func (t *gitDiffTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GitDiffToolName,
+		Description: LoadToolDescription(GitDiffToolName),
+		Parameters: map[string]any{
+			"staged": map[string]any{
+				"type":        "boolean",
+				"description": "Show the changes staged for the next commit instead of the unstaged ones",
+			},
+			"ref": map[string]any{
+				"type":        "string",
+				"description": "Compare the working tree with this commit or branch, or two commits with a range such as main...feature",
+			},
+			"paths": gitPathsParameter,
+		},
+		Required: []string{},
+	}
+}
+
+func (t *gitDiffTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GitDiffParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if err := validateGitRef(params.Ref); err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	args := []string{"diff"}
+	if params.Staged {
+		args = append(args, "--cached")
+	}
+	if params.Ref != "" {
+		args = append(args, params.Ref)
+	}
+	paths := append([]string{"--"}, params.Paths...)
+
+	numstat, err := runGit(ctx, call.State, workingDir, append(append(args, "--numstat", "-z"), paths...)...)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	diff, err := runGit(ctx, call.State, workingDir, append(args, paths...)...)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	metadata := GitDiffResponseMetadata{Files: []GitDiffFile{}}
+	// Entries are "added<tab>removed<tab>path" and NUL-terminated; a rename
+	// leaves the path empty and is followed by the old and new paths
+	entries := strings.Split(numstat, "\x00")
+	for i := 0; i < len(entries); i++ {
+		fields := strings.SplitN(entries[i], "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		file := GitDiffFile{Path: fields[2], Binary: fields[0] == "-"}
+		if file.Path == "" && i+2 < len(entries) {
+			file.OrigPath, file.Path = entries[i+1], entries[i+2]
+			i += 2
+		}
+		file.Additions, _ = strconv.Atoi(fields[0])
+		file.Removals, _ = strconv.Atoi(fields[1])
+		metadata.Files = append(metadata.Files, file)
+		metadata.Additions += file.Additions
+		metadata.Removals += file.Removals
+	}
+	if diff == "" {
+		return WithResponseMetadata(NewTextResponse("No changes"), metadata), nil
+	}
+	output := truncateOutput(diff)
+	metadata.Truncated = len(output) != len(diff)
+	return WithResponseMetadata(NewTextResponse(output), metadata), nil
+}
+
+func (t *gitLogTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GitLogToolName,
+		Description: LoadToolDescription(GitLogToolName),
+		Parameters: map[string]any{
+			"ref": map[string]any{
+				"type":        "string",
+				"description": "Branch, commit or range to list, such as main..feature (default: HEAD)",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Only list commits changing this file or directory",
+			},
+			"max_count": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("Maximum commits to list (default %d, max %d)", DefaultGitLogEntries, MaxGitLogEntries),
+			},
+		},
+		Required: []string{},
+	}
+}
+
+func (t *gitLogTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GitLogParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if err := validateGitRef(params.Ref); err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if params.MaxCount <= 0 {
+		params.MaxCount = DefaultGitLogEntries
+	}
+	params.MaxCount = min(params.MaxCount, MaxGitLogEntries)
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	// Fields are separated by unit separators and commits by record separators
+	args := []string{"log", "--max-count=" + strconv.Itoa(params.MaxCount), "--format=%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%s%x1e"}
+	if params.Ref != "" {
+		args = append(args, params.Ref)
+	}
+	args = append(args, "--")
+	if params.Path != "" {
+		args = append(args, params.Path)
+	}
+	out, err := runGit(ctx, call.State, workingDir, args...)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	metadata := GitLogResponseMetadata{Commits: []GitCommitInfo{}}
+	var b strings.Builder
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		commit := GitCommitInfo{
+			Commit:      fields[0],
+			ShortCommit: fields[1],
+			Author:      fields[2],
+			Email:       fields[3],
+			Subject:     fields[5],
+		}
+		commit.Date, _ = time.Parse(time.RFC3339, fields[4])
+		metadata.Commits = append(metadata.Commits, commit)
+		fmt.Fprintf(&b, "%s %s %s: %s\n", commit.ShortCommit, commit.Date.Format("2006-01-02"), commit.Author, commit.Subject)
+	}
+	if len(metadata.Commits) == 0 {
+		return WithResponseMetadata(NewTextResponse("No commits"), metadata), nil
+	}
+	return WithResponseMetadata(NewTextResponse(b.String()), metadata), nil
+}
+
+func (t *gitCommitTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GitCommitToolName,
+		Description: LoadToolDescription(GitCommitToolName),
+		Parameters: map[string]any{
+			"message": map[string]any{
+				"type":        "string",
+				"description": "The commit message",
+			},
+			"paths": map[string]any{
+				"type":        "array",
+				"description": "Files or directories to stage before committing, relative to the working directory",
+				"items": map[string]any{
+					"type": "string",
+				},
+			},
+			"all": map[string]any{
+				"type":        "boolean",
+				"description": "Stage every change to tracked files before committing",
+			},
+		},
+		Required: []string{"message"},
+	}
+}
+
+func (t *gitCommitTool) DescribesDryRun() {}
+
+func (t *gitCommitTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GitCommitParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if strings.TrimSpace(params.Message) == "" {
+		return NewTextErrorResponse("message is required"), nil
+	}
+
+	sessionID := call.State.SessionID
+	if sessionID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID is required for committing")
+	}
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	commitArgs := []string{"commit", "--message", params.Message}
+	if params.All {
+		commitArgs = append(commitArgs, "--all")
+	}
+
+	if call.State.DryRun {
+		command := gitCommandLine(commitArgs)
+		if len(params.Paths) > 0 {
+			command = gitCommandLine(append([]string{"add", "--"}, params.Paths...)) + " && " + command
+		}
+		return NewDryRunResponse(DryRunEffect{
+			Tool:             GitCommitToolName,
+			Command:          command,
+			WorkingDirectory: workingDir,
+		}), nil
+	}
+
+	granted := t.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        workingDir,
+			ToolName:    GitCommitToolName,
+			Action:      "commit",
+			Description: fmt.Sprintf("Commit: %s", firstLine(params.Message)),
+			Params: GitCommitPermissionsParams{
+				Message: params.Message,
+				Paths:   params.Paths,
+				All:     params.All,
+			},
+		},
+	)
+	if !granted {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	if len(params.Paths) > 0 {
+		if _, err := runGit(ctx, call.State, workingDir, append([]string{"add", "--"}, params.Paths...)...); err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+	}
+	if _, err := runGit(ctx, call.State, workingDir, commitArgs...); err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	out, err := runGit(ctx, call.State, workingDir, "show", "--name-only", "--format=%H%n%D", "HEAD")
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	metadata := GitCommitResponseMetadata{Commit: lines[0], Files: []string{}}
+	if len(lines) > 1 {
+		// The ref names read "HEAD -> branch, ..." unless HEAD is detached
+		if _, branch, ok := strings.Cut(lines[1], "HEAD -> "); ok {
+			metadata.Branch, _, _ = strings.Cut(branch, ",")
+		}
+	}
+	for _, line := range lines[min(2, len(lines)):] {
+		if line != "" {
+			metadata.Files = append(metadata.Files, line)
+		}
+	}
+	return WithResponseMetadata(
+		NewTextResponse(fmt.Sprintf("Committed %s (%d files): %s", shortCommit(metadata.Commit), len(metadata.Files), firstLine(params.Message))),
+		metadata,
+	), nil
+}
+
+func (t *gitBranchTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GitBranchToolName,
+		Description: LoadToolDescription(GitBranchToolName),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "list (default), create, switch or delete",
+				"enum":        []string{GitBranchList, GitBranchCreate, GitBranchSwitch, GitBranchDelete},
+			},
+			"name": map[string]any{
+				"type":        "string",
+				"description": "The branch to create, switch to or delete",
+			},
+			"start_point": map[string]any{
+				"type":        "string",
+				"description": "The commit or branch a new branch starts at (default: HEAD)",
+			},
+			"remote": map[string]any{
+				"type":        "boolean",
+				"description": "Also list remote-tracking branches",
+			},
+		},
+		Required: []string{},
+	}
+}
+
+func (t *gitBranchTool) DescribesDryRun() {}
+
+func (t *gitBranchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GitBranchParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.Action == "" {
+		params.Action = GitBranchList
+	}
+	workingDir, err := call.State.RequireWorkingDirectory()
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if params.Action == GitBranchList {
+		return t.list(ctx, call.State, workingDir, params.Remote)
+	}
+
+	var args []string
+	switch params.Action {
+	case GitBranchCreate:
+		args = []string{"branch", params.Name}
+		if params.StartPoint != "" {
+			if err := validateGitRef(params.StartPoint); err != nil {
+				return NewTextErrorResponse(err.Error()), nil
+			}
+			args = append(args, params.StartPoint)
+		}
+	case GitBranchSwitch:
+		args = []string{"switch", params.Name}
+	case GitBranchDelete:
+		// -d refuses to delete branches that aren't merged
+		args = []string{"branch", "-d", params.Name}
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("unknown action: %s", params.Action)), nil
+	}
+	if params.Name == "" {
+		return NewTextErrorResponse("name is required"), nil
+	}
+	if err := validateGitRef(params.Name); err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	sessionID := call.State.SessionID
+	if sessionID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID is required for changing branches")
+	}
+
+	if call.State.DryRun {
+		return NewDryRunResponse(DryRunEffect{
+			Tool:             GitBranchToolName,
+			Command:          gitCommandLine(args),
+			WorkingDirectory: workingDir,
+		}), nil
+	}
+
+	granted := t.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        workingDir,
+			ToolName:    GitBranchToolName,
+			Action:      params.Action,
+			Description: fmt.Sprintf("%s branch %s", strings.ToUpper(params.Action[:1])+params.Action[1:], params.Name),
+			Params: GitBranchPermissionsParams{
+				Action:     params.Action,
+				Name:       params.Name,
+				StartPoint: params.StartPoint,
+			},
+		},
+	)
+	if !granted {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	if _, err := runGit(ctx, call.State, workingDir, args...); err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	current, _ := runGit(ctx, call.State, workingDir, "branch", "--show-current")
+	metadata := GitBranchResponseMetadata{Action: params.Action, Current: strings.TrimSpace(current)}
+
+	var output string
+	switch params.Action {
+	case GitBranchCreate:
+		output = fmt.Sprintf("Created branch %s", params.Name)
+	case GitBranchSwitch:
+		output = fmt.Sprintf("Switched to branch %s", params.Name)
+	case GitBranchDelete:
+		output = fmt.Sprintf("Deleted branch %s", params.Name)
+	}
+	return WithResponseMetadata(NewTextResponse(output), metadata), nil
+}
+
+func (t *gitBranchTool) list(ctx context.Context, state RequestState, workingDir string, remote bool) (ToolResponse, error) {
+	args := []string{"branch", "--format=%(refname)%09%(objectname:short)%09%(HEAD)%09%(upstream:short)"}
+	if remote {
+		args = append(args, "--all")
+	}
+	out, err := runGit(ctx, state, workingDir, args...)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	metadata := GitBranchResponseMetadata{Action: GitBranchList, Branches: []GitBranchInfo{}}
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\t")
+		// Detached HEAD is listed as "(HEAD detached at ...)", not a ref
+		if len(fields) != 4 || !strings.HasPrefix(fields[0], "refs/") {
+			continue
+		}
+		branch := GitBranchInfo{
+			Commit:   fields[1],
+			Current:  fields[2] == "*",
+			Upstream: fields[3],
+		}
+		if name, ok := strings.CutPrefix(fields[0], "refs/remotes/"); ok {
+			branch.Name, branch.Remote = name, true
+		} else {
+			branch.Name = strings.TrimPrefix(fields[0], "refs/heads/")
+		}
+		metadata.Branches = append(metadata.Branches, branch)
+
+		marker := " "
+		if branch.Current {
+			marker = "*"
+			metadata.Current = branch.Name
+		}
+		fmt.Fprintf(&b, "%s %s %s", marker, branch.Name, branch.Commit)
+		if branch.Upstream != "" {
+			fmt.Fprintf(&b, " [%s]", branch.Upstream)
+		}
+		b.WriteString("\n")
+	}
+	if len(metadata.Branches) == 0 {
+		return WithResponseMetadata(NewTextResponse("No branches"), metadata), nil
+	}
+	return WithResponseMetadata(NewTextResponse(b.String()), metadata), nil
+}
+
+// runGit runs git in dir with the session's environment and returns its
+// output. A failing command's error carries what git printed.
+func runGit(ctx context.Context, state RequestState, dir string, args ...string) (string, error) {
+	// Keep output plain and never wait for credentials or an editor
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-c", "color.ui=false", "-c", "core.quotepath=false"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), state.Environ()...), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "GIT_PAGER=cat")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		// git commit reports having nothing to commit on stdout
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+// gitCommandLine returns the command line running git with args, quoting
+// arguments the shell would split.
+func gitCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$`&|;<>()*?") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return "git " + strings.Join(quoted, " ")
+}
+
+// validateGitRef rejects refs git would read as options.
+func validateGitRef(ref string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid ref %q", ref)
+	}
+	return nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
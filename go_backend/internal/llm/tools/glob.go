@@ -65,7 +65,7 @@ func (g *globTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	searchPath := params.Path
 	if searchPath == "" {
 		var err error
-		searchPath, err = GetWorkingDirectory(ctx)
+		searchPath, err = call.State.RequireWorkingDirectory()
 		if err != nil {
 			return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 		}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"mix/internal/grammar"
+)
+
+const GrammarCheckToolName = "grammar_check"
+
+// maxGrammarCheckChars matches LanguageTool's limit for a single request
+const maxGrammarCheckChars = 20000
+
+type grammarCheckTool struct{}
+
+type GrammarCheckParams struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+}
+
+func NewGrammarCheckTool() BaseTool {
+	return &grammarCheckTool{}
+}
+
+func (t *grammarCheckTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GrammarCheckToolName,
+		Description: LoadToolDescription(GrammarCheckToolName),
+		Parameters: map[string]any{
+			"text": map[string]any{
+				"type":        "string",
+				"description": "The script, caption or narration text to check",
+			},
+			"language": map[string]any{
+				"type":        "string",
+				"description": "Language code such as en-US or de-DE (default: auto-detect)",
+			},
+		},
+		Required: []string{"text"},
+	}
+}
+
+func (t *grammarCheckTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GrammarCheckParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+	if params.Text == "" {
+		return NewTextErrorResponse("text is required"), nil
+	}
+	if n := len([]rune(params.Text)); n > maxGrammarCheckChars {
+		return NewTextErrorResponse(fmt.Sprintf("text is %d characters; check at most %d at a time", n, maxGrammarCheckChars)), nil
+	}
+
+	result, err := grammar.Check(ctx, params.Text, params.Language)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Grammar check failed: %v", err)), nil
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to marshal grammar check result: %w", err)
+	}
+	return NewTextResponse(string(output)), nil
+}
@@ -108,14 +108,14 @@ func (g *grepTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	searchPath := params.Path
 	if searchPath == "" {
 		var err error
-		searchPath, err = GetWorkingDirectory(ctx)
+		searchPath, err = call.State.RequireWorkingDirectory()
 		if err != nil {
 			return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 		}
 	}
 
 	// Check permissions before searching files
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for searching files")
 	}
@@ -65,7 +65,7 @@ func (l *lsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
 	}
 
-	workingDir, err := GetWorkingDirectory(ctx)
+	workingDir, err := call.State.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
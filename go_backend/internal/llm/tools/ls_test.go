@@ -79,6 +79,7 @@ func TestLsTool_Run(t *testing.T) {
 		call := ToolCall{
 			Name:  LSToolName,
 			Input: string(paramsJSON),
+			State: RequestState{WorkingDirectory: tempDir},
 		}
 
 		response, err := tool.Run(context.Background(), call)
@@ -112,6 +113,7 @@ func TestLsTool_Run(t *testing.T) {
 		call := ToolCall{
 			Name:  LSToolName,
 			Input: string(paramsJSON),
+			State: RequestState{WorkingDirectory: tempDir},
 		}
 
 		response, err := tool.Run(context.Background(), call)
@@ -150,6 +152,7 @@ func TestLsTool_Run(t *testing.T) {
 		call := ToolCall{
 			Name:  LSToolName,
 			Input: string(paramsJSON),
+			State: RequestState{WorkingDirectory: tempDir},
 		}
 
 		response, err := tool.Run(context.Background(), call)
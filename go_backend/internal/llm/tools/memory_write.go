@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"mix/internal/config"
+	"mix/internal/memory"
+)
+
+const MemoryWriteToolName = "memory_write"
+
+type MemoryWriteParams struct {
+	Content string `json:"content"`
+	// Scope is "user" (default) or "workspace"
+	Scope string `json:"scope"`
+}
+
+type MemoryWriteResponseMetadata struct {
+	ID        string `json:"id"`
+	Scope     string `json:"scope"`
+	Workspace string `json:"workspace,omitempty"`
+}
+
+type memoryWriteTool struct {
+	memories memory.Service
+}
+
+func NewMemoryWriteTool(memories memory.Service) BaseTool {
+	return &memoryWriteTool{memories: memories}
+}
+
+func (t *memoryWriteTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        MemoryWriteToolName,
+		Description: LoadToolDescription(MemoryWriteToolName),
+		Parameters: map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": fmt.Sprintf("One short fact or preference to remember (at most %d characters)", memory.MaxContentChars),
+			},
+			"scope": map[string]any{
+				"type":        "string",
+				"description": "user for facts about the user that apply everywhere (default), workspace for facts about the current project",
+				"enum":        []string{string(memory.ScopeUser), string(memory.ScopeWorkspace)},
+			},
+		},
+		Required: []string{"content"},
+	}
+}
+
+func (t *memoryWriteTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params MemoryWriteParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if cfg := config.Get(); cfg != nil && cfg.Memory.Disabled {
+		return NewTextErrorResponse("memory is disabled"), nil
+	}
+	scope := memory.Scope(params.Scope)
+	if scope == "" {
+		scope = memory.ScopeUser
+	}
+
+	saved, err := t.memories.Write(ctx, scope, call.State.WorkingDirectory, params.Content, call.State.SessionID)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return WithResponseMetadata(
+		NewTextResponse(fmt.Sprintf("Saved to %s memory. It applies from the next session.", saved.Scope)),
+		MemoryWriteResponseMetadata{
+			ID:        saved.ID,
+			Scope:     string(saved.Scope),
+			Workspace: saved.Workspace,
+		},
+	), nil
+}
@@ -75,12 +75,12 @@ func (n *notesTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return NewTextErrorResponse("missing operation"), nil
 	}
 
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for notes operations")
 	}
 
-	workingDir, err := GetWorkingDirectory(ctx)
+	workingDir, err := call.State.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
@@ -76,7 +76,7 @@ func (p *pythonExecutionTool) Run(ctx context.Context, call ToolCall) (ToolRespo
 		return NewTextErrorResponse("potentially unsafe code detected"), nil
 	}
 
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for Python execution")
 	}
@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"mix/internal/redact"
+)
+
+// RedactSecrets replaces the credentials in a text result, and the values of
+// the session's secret environment variables, with [REDACTED:<kind>] markers
+// before the result is stored or returned to the model. In strict mode a
+// result with credentials is replaced by an error instead.
+func RedactSecrets(response ToolResponse, env map[string]string) ToolResponse {
+	if response.Type != ToolResponseTypeText {
+		return response
+	}
+	secrets := redact.EnvSecrets(env)
+	content, findings := redact.Default().Redact(response.Content, secrets...)
+	metadata, metadataFindings := redact.Default().Redact(response.Metadata, secrets...)
+	findings = append(findings, metadataFindings...)
+	if len(findings) == 0 {
+		return response
+	}
+	if redact.Strict() {
+		withheld := NewTextErrorResponse(fmt.Sprintf("The tool result was withheld because it contains credentials (%s). Read or print only the parts of the output that don't include secrets.",
+			strings.Join(redact.Kinds(findings), ", ")))
+		withheld.Cost = response.Cost
+		return withheld
+	}
+	response.Content, response.Metadata = content, metadata
+	return response
+}
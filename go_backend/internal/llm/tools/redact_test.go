@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"testing"
+
+	"mix/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	const apiKey = "sk-ant-REDACTED"
+	env := map[string]string{"DEPLOY_TOKEN": "deploy-secret-value"}
+
+	tests := []struct {
+		name     string
+		strict   bool
+		response ToolResponse
+		expected ToolResponse
+	}{
+		{
+			name:     "credential in the content",
+			response: NewTextResponse("ANTHROPIC_API_KEY=" + apiKey),
+			expected: NewTextResponse("ANTHROPIC_API_KEY=" + redact.Marker(redact.KindAnthropicKey)),
+		},
+		{
+			name:     "session secret in the metadata",
+			response: WithResponseMetadata(NewTextResponse("deployed"), map[string]string{"token": "deploy-secret-value"}),
+			expected: ToolResponse{Type: ToolResponseTypeText, Content: "deployed", Metadata: `{"token":"` + redact.Marker(redact.KindSecret) + `"}`},
+		},
+		{
+			name:     "nothing to redact",
+			strict:   true,
+			response: NewTextResponse("total 0"),
+			expected: NewTextResponse("total 0"),
+		},
+		{
+			name:     "strict mode withholds the result",
+			strict:   true,
+			response: WithResponseCost(NewTextResponse("key: "+apiKey), 0.5),
+			expected: WithResponseCost(NewTextErrorResponse("The tool result was withheld because it contains credentials (anthropic_key). Read or print only the parts of the output that don't include secrets."), 0.5),
+		},
+		{
+			name:     "images are left as they are",
+			strict:   true,
+			response: ToolResponse{Type: ToolResponseTypeImage, Content: apiKey},
+			expected: ToolResponse{Type: ToolResponseTypeImage, Content: apiKey},
+		},
+	}
+
+	t.Cleanup(func() { _ = redact.Configure(redact.Options{}) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, redact.Configure(redact.Options{Strict: tt.strict}))
+			assert.Equal(t, tt.expected, RedactSecrets(tt.response, env))
+		})
+	}
+}
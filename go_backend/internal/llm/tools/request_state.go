@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"mix/internal/config"
+	"mix/internal/llm/responseformat"
+)
+
+// RequestState carries the per-request values the agent, tools and providers
+// need. It is passed explicitly; the context only carries cancellation.
+type RequestState struct {
+	SessionID        string
+	MessageID        string
+	WorkingDirectory string
+	PlanMode         bool
+	IdempotencyKey   string
+	// Reminders names the reminders attached to this turn alone
+	Reminders []string
+	// Env holds the session's environment variables for bash commands and
+	// stdio MCP servers
+	Env map[string]string
+	// SandboxProfile names the sandbox profile of the session's bash
+	// commands; empty uses the configured default
+	SandboxProfile string
+	// DryRun has tools that could change anything describe what they would
+	// do instead of doing it
+	DryRun bool
+	// Thinking replaces the agent's thinking settings for this turn
+	Thinking *config.Thinking
+	// ResponseFormat constrains this turn's final response to JSON
+	ResponseFormat *responseformat.Format
+	// Queue has a turn sent while the session is generating wait for its
+	// turn instead of failing with the session busy
+	Queue bool
+	// AcquireRun, when set, waits for a slot in the server's run queue once
+	// the turn may start, so a queued turn doesn't hold one while it waits
+	// for the session. The turn calls the returned function when it ends.
+	AcquireRun func(ctx context.Context) (func(), error)
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Environ returns Env as sorted NAME=value entries. ${NAME} in a value is
+// replaced with the server's NAME, so PATH=/opt/tools/bin:${PATH} extends
+// the inherited PATH.
+func (s RequestState) Environ() []string {
+	env := make([]string, 0, len(s.Env))
+	for name, value := range s.Env {
+		value = envReference.ReplaceAllStringFunc(value, func(ref string) string {
+			return os.Getenv(envReference.FindStringSubmatch(ref)[1])
+		})
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// RequireWorkingDirectory returns the working directory, failing if it is unset.
+func (s RequestState) RequireWorkingDirectory() (string, error) {
+	if s.WorkingDirectory == "" {
+		return "", fmt.Errorf("working directory not set in request state")
+	}
+	return s.WorkingDirectory, nil
+}
+
+// WithRequestState is a compatibility shim for code that still reads the
+// legacy context keys. It will be removed once all readers take RequestState.
+func WithRequestState(ctx context.Context, state RequestState) context.Context {
+	if state.SessionID != "" {
+		ctx = context.WithValue(ctx, SessionIDContextKey, state.SessionID)
+	}
+	if state.MessageID != "" {
+		ctx = context.WithValue(ctx, MessageIDContextKey, state.MessageID)
+	}
+	if state.WorkingDirectory != "" {
+		ctx = context.WithValue(ctx, WorkingDirectoryContextKey, state.WorkingDirectory)
+	}
+	return ctx
+}
@@ -0,0 +1,47 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mix/internal/config"
+)
+
+// sandboxEnvNames are the only variables of the server's environment a
+// sandboxed shell inherits, so API keys and tokens in it can't leak
+var sandboxEnvNames = []string{"HOME", "USER", "LANG", "TERM", "TMPDIR"}
+
+// sandboxEnv returns the environment of a shell confined by profile, before
+// the session's variables.
+func sandboxEnv(profile config.SandboxProfile) []string {
+	var env []string
+	for _, name := range sandboxEnvNames {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	path := profile.Path
+	if path == "" {
+		path = os.Getenv("PATH")
+	}
+	return append(env, "PATH="+path)
+}
+
+// limitsResources reports whether profile caps CPU, memory or processes.
+func limitsResources(profile config.SandboxProfile) bool {
+	return profile.CPUPercent > 0 || profile.MemoryMB > 0 || profile.MaxProcesses > 0
+}
+
+// sandboxPaths expands ~/ in paths to the home directory.
+func sandboxPaths(paths []string) []string {
+	home, _ := os.UserHomeDir()
+	expanded := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok && home != "" {
+			path = filepath.Join(home, rest)
+		}
+		expanded = append(expanded, filepath.Clean(path))
+	}
+	return expanded
+}
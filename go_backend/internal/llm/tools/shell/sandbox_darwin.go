@@ -0,0 +1,57 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"mix/internal/config"
+)
+
+// sandboxCommand returns the command that starts the shell confined by
+// profile, and the number of sandbox processes between it and the shell.
+// sandbox-exec applies the profile and execs the shell, so there are none.
+func sandboxCommand(profile config.SandboxProfile, workingDir, shellPath string, shellArgs []string) (*exec.Cmd, int, error) {
+	if limitsResources(profile) {
+		return nil, 0, errors.New("CPU, memory and process limits are only supported on Linux")
+	}
+	if len(profile.ReadOnlyPaths) == 0 && len(profile.HiddenPaths) == 0 && !profile.DisableNetwork {
+		return exec.Command(shellPath, shellArgs...), 0, nil
+	}
+
+	// The last matching rule wins, so the working and temporary directories
+	// stay writable under a read-only parent
+	var sb strings.Builder
+	sb.WriteString("(version 1)\n(allow default)\n")
+	if profile.DisableNetwork {
+		sb.WriteString("(deny network*)\n")
+	}
+	for _, path := range sandboxPaths(profile.ReadOnlyPaths) {
+		fmt.Fprintf(&sb, "(deny file-write* (subpath %s))\n", sbplString(realPath(path)))
+	}
+	for _, path := range []string{workingDir, os.TempDir()} {
+		fmt.Fprintf(&sb, "(allow file-write* (subpath %s))\n", sbplString(realPath(path)))
+	}
+	for _, path := range sandboxPaths(profile.HiddenPaths) {
+		fmt.Fprintf(&sb, "(deny file-read* file-write* (subpath %s))\n", sbplString(realPath(path)))
+	}
+
+	args := append([]string{"-p", sb.String(), shellPath}, shellArgs...)
+	return exec.Command("/usr/bin/sandbox-exec", args...), 0, nil
+}
+
+// realPath resolves symlinks such as /var -> /private/var, since the sandbox
+// matches resolved paths.
+func realPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+func sbplString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"mix/internal/config"
+)
+
+// sandboxCommand returns the command that starts the shell confined by
+// profile, and the number of sandbox processes between it and the shell.
+// Paths and network are isolated by bubblewrap and resources limited by a
+// transient systemd user scope; both exec the next command, except bwrap
+// which stays as its parent.
+func sandboxCommand(profile config.SandboxProfile, workingDir, shellPath string, shellArgs []string) (*exec.Cmd, int, error) {
+	args := append([]string{shellPath}, shellArgs...)
+	wrappers := 0
+
+	if len(profile.ReadOnlyPaths) > 0 || len(profile.HiddenPaths) > 0 || profile.DisableNetwork {
+		bwrap, err := exec.LookPath("bwrap")
+		if err != nil {
+			return nil, 0, errors.New("bubblewrap (bwrap) is required to isolate paths and network")
+		}
+		// Later mounts take precedence, so the working and temporary
+		// directories stay writable under a read-only parent
+		bwrapArgs := []string{bwrap, "--die-with-parent", "--bind", "/", "/"}
+		for _, path := range sandboxPaths(profile.ReadOnlyPaths) {
+			if _, err := os.Stat(path); err == nil {
+				bwrapArgs = append(bwrapArgs, "--ro-bind", path, path)
+			}
+		}
+		for _, path := range []string{workingDir, os.TempDir()} {
+			bwrapArgs = append(bwrapArgs, "--bind", path, path)
+		}
+		for _, path := range sandboxPaths(profile.HiddenPaths) {
+			info, err := os.Stat(path)
+			switch {
+			case err != nil:
+			case info.IsDir():
+				bwrapArgs = append(bwrapArgs, "--tmpfs", path)
+			default:
+				bwrapArgs = append(bwrapArgs, "--ro-bind", "/dev/null", path)
+			}
+		}
+		if profile.DisableNetwork {
+			bwrapArgs = append(bwrapArgs, "--unshare-net")
+		}
+		args = append(append(bwrapArgs, "--"), args...)
+		wrappers++
+	}
+
+	if limitsResources(profile) {
+		systemdRun, err := exec.LookPath("systemd-run")
+		if err != nil {
+			return nil, 0, errors.New("systemd-run is required to limit CPU, memory and processes")
+		}
+		runArgs := []string{systemdRun, "--user", "--scope", "--quiet", "--collect"}
+		if profile.CPUPercent > 0 {
+			runArgs = append(runArgs, "-p", fmt.Sprintf("CPUQuota=%d%%", profile.CPUPercent))
+		}
+		if profile.MemoryMB > 0 {
+			runArgs = append(runArgs, "-p", fmt.Sprintf("MemoryMax=%dM", profile.MemoryMB), "-p", "MemorySwapMax=0")
+		}
+		if profile.MaxProcesses > 0 {
+			runArgs = append(runArgs, "-p", fmt.Sprintf("TasksMax=%d", profile.MaxProcesses))
+		}
+		args = append(append(runArgs, "--"), args...)
+	}
+
+	return exec.Command(args[0], args[1:]...), wrappers, nil
+}
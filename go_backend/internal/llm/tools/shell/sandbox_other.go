@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package shell
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"mix/internal/config"
+)
+
+// sandboxCommand returns the command that starts the shell confined by
+// profile. Only PATH and timeout restrictions are supported here.
+func sandboxCommand(profile config.SandboxProfile, workingDir, shellPath string, shellArgs []string) (*exec.Cmd, int, error) {
+	if limitsResources(profile) || len(profile.ReadOnlyPaths) > 0 || len(profile.HiddenPaths) > 0 || profile.DisableNetwork {
+		return nil, 0, fmt.Errorf("path, network and resource restrictions are not supported on %s", runtime.GOOS)
+	}
+	return exec.Command(shellPath, shellArgs...), 0, nil
+}
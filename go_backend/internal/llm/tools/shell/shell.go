@@ -1,12 +1,15 @@
 package shell
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -22,6 +25,14 @@ type PersistentShell struct {
 	stdin        *os.File
 	isAlive      bool
 	cwd          string
+	env          []string
+	sandbox      *config.SandboxProfile
+	// wrappers is the number of sandbox processes between cmd and the shell
+	wrappers     int
+	// exited is closed when the shell exits, after exitErr is set to why,
+	// e.g. a sandbox that failed to start
+	exited       chan struct{}
+	exitErr      error
 	mu           sync.Mutex
 	commandQueue chan *commandExecution
 }
@@ -43,26 +54,55 @@ type commandResult struct {
 
 var (
 	shellInstance *PersistentShell
+	// sessionShells run commands for sessions with their own environment or
+	// sandbox
+	sessionShells = make(map[string]*PersistentShell)
 	shellMutex    sync.Mutex
 )
 
-func GetPersistentShell(workingDir string) *PersistentShell {
+// GetPersistentShell returns the shared shell, or for a session with
+// environment variables or a sandbox profile a shell of its own started with
+// them. A session's shell is restarted when either changes.
+func GetPersistentShell(sessionID, workingDir string, env []string, sandbox *config.SandboxProfile) (*PersistentShell, error) {
 	shellMutex.Lock()
 	defer shellMutex.Unlock()
 
+	if len(env) > 0 || sandbox != nil {
+		shell := sessionShells[sessionID]
+		if shell == nil || !shell.IsAlive() || !slices.Equal(shell.env, env) || !reflect.DeepEqual(shell.sandbox, sandbox) {
+			if shell != nil {
+				shell.Close()
+				delete(sessionShells, sessionID)
+			}
+			var err error
+			if shell, err = newPersistentShell(workingDir, env, sandbox); err != nil {
+				return nil, err
+			}
+			sessionShells[sessionID] = shell
+		}
+		return shell, nil
+	}
+	if shell, ok := sessionShells[sessionID]; ok {
+		shell.Close()
+		delete(sessionShells, sessionID)
+	}
+
 	// Check if we need a new shell
 	if shellInstance == nil || !shellInstance.IsAlive() {
 		// Clean up old shell if it exists
 		if shellInstance != nil {
 			shellInstance.Close()
 		}
-		shellInstance = newPersistentShell(workingDir)
+		var err error
+		if shellInstance, err = newPersistentShell(workingDir, nil, nil); err != nil {
+			return nil, err
+		}
 	}
 
-	return shellInstance
+	return shellInstance, nil
 }
 
-func newPersistentShell(cwd string) *PersistentShell {
+func newPersistentShell(cwd string, env []string, sandbox *config.SandboxProfile) (*PersistentShell, error) {
 	// Get shell configuration from config
 	cfg := config.Get()
 
@@ -82,24 +122,36 @@ func newPersistentShell(cwd string) *PersistentShell {
 		}
 	}
 
-	// Default shell args
-	if len(shellArgs) == 0 {
+	// Default shell args. Sandboxed shells don't run login scripts, which
+	// could reset the restricted PATH.
+	if len(shellArgs) == 0 && sandbox == nil {
 		shellArgs = []string{"-l"}
 	}
 
 	cmd := exec.Command(shellPath, shellArgs...)
+	baseEnv := os.Environ()
+	var wrappers int
+	if sandbox != nil {
+		var err error
+		if cmd, wrappers, err = sandboxCommand(*sandbox, cwd, shellPath, shellArgs); err != nil {
+			return nil, fmt.Errorf("sandbox profile %s: %w", sandbox.Name, err)
+		}
+		baseEnv = sandboxEnv(*sandbox)
+	}
 	cmd.Dir = cwd
 
 	stdinPipe, err := cmd.StdinPipe()
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to open shell input: %w", err)
 	}
 
-	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	cmd.Env = append(append(baseEnv, "GIT_EDITOR=true"), env...)
+	stderr := &limitedBuffer{max: 4096}
+	cmd.Stderr = stderr
 
 	err = cmd.Start()
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to start shell: %w", err)
 	}
 
 	shell := &PersistentShell{
@@ -107,6 +159,10 @@ func newPersistentShell(cwd string) *PersistentShell {
 		stdin:        stdinPipe.(*os.File),
 		isAlive:      true,
 		cwd:          cwd,
+		env:          env,
+		sandbox:      sandbox,
+		wrappers:     wrappers,
+		exited:       make(chan struct{}),
 		commandQueue: make(chan *commandExecution, 10),
 	}
 
@@ -115,7 +171,6 @@ func newPersistentShell(cwd string) *PersistentShell {
 			if r := recover(); r != nil {
 				fmt.Fprintf(os.Stderr, "Panic in shell command processor: %v\n", r)
 				shell.isAlive = false
-				close(shell.commandQueue)
 			}
 		}()
 		shell.processCommands()
@@ -123,20 +178,24 @@ func newPersistentShell(cwd string) *PersistentShell {
 
 	go func() {
 		err := cmd.Wait()
-		if err != nil {
-			// Log the error if needed
-		}
+		shell.exitErr = exitError(err, stderr.String())
+		close(shell.exited)
 		shell.isAlive = false
-		close(shell.commandQueue)
 	}()
 
-	return shell
+	return shell, nil
 }
 
+// processCommands runs queued commands until the shell exits. Commands
+// queued after that get no result; Exec stops waiting when the shell exits.
 func (s *PersistentShell) processCommands() {
-	for cmd := range s.commandQueue {
-		result := s.execCommand(cmd.command, cmd.timeout, cmd.ctx)
-		cmd.resultChan <- result
+	for {
+		select {
+		case cmd := <-s.commandQueue:
+			cmd.resultChan <- s.execCommand(cmd.command, cmd.timeout, cmd.ctx)
+		case <-s.exited:
+			return
+		}
 	}
 }
 
@@ -204,6 +263,11 @@ echo $EXEC_EXIT_CODE > %s
 				done <- true
 				return
 
+			case <-s.exited:
+				interrupted = true
+				done <- true
+				return
+
 			case <-ticker.C:
 				if fileExists(statusFile) && fileSize(statusFile) > 0 {
 					done <- true
@@ -233,6 +297,13 @@ echo $EXEC_EXIT_CODE > %s
 	exitCode := 0
 	if exitCodeStr != "" {
 		fmt.Sscanf(exitCodeStr, "%d", &exitCode)
+	} else if s.hasExited() {
+		return commandResult{
+			stdout:   stdout,
+			stderr:   stderr,
+			exitCode: 1,
+			err:      s.exitErr,
+		}
 	} else if interrupted {
 		exitCode = 143
 		stderr += "\nCommand execution timed out or was interrupted"
@@ -250,47 +321,86 @@ echo $EXEC_EXIT_CODE > %s
 	}
 }
 
+// killChildren terminates the processes the shell started. A sandboxed
+// shell runs under its sandbox's processes, so they are skipped.
 func (s *PersistentShell) killChildren() {
 	if s.cmd == nil || s.cmd.Process == nil {
 		return
 	}
 
-	pgrepCmd := exec.Command("pgrep", "-P", fmt.Sprintf("%d", s.cmd.Process.Pid))
+	pids := []int{s.cmd.Process.Pid}
+	for range s.wrappers {
+		var shells []int
+		for _, pid := range pids {
+			shells = append(shells, childPIDs(pid)...)
+		}
+		pids = shells
+	}
+
+	for _, parent := range pids {
+		for _, pid := range childPIDs(parent) {
+			proc, err := os.FindProcess(pid)
+			if err == nil {
+				proc.Signal(syscall.SIGTERM)
+			}
+		}
+	}
+}
+
+func childPIDs(parent int) []int {
+	pgrepCmd := exec.Command("pgrep", "-P", fmt.Sprintf("%d", parent))
 	output, err := pgrepCmd.Output()
 	if err != nil {
-		return
+		return nil
 	}
 
+	var pids []int
 	for pidStr := range strings.SplitSeq(string(output), "\n") {
 		if pidStr = strings.TrimSpace(pidStr); pidStr != "" {
 			var pid int
 			fmt.Sscanf(pidStr, "%d", &pid)
 			if pid > 0 {
-				proc, err := os.FindProcess(pid)
-				if err == nil {
-					proc.Signal(syscall.SIGTERM)
-				}
+				pids = append(pids, pid)
 			}
 		}
 	}
+	return pids
 }
 
 func (s *PersistentShell) Exec(ctx context.Context, command string, timeoutMs int) (string, string, int, bool, error) {
+	if s.hasExited() {
+		return "", "Shell is not alive", 1, false, s.exitErr
+	}
 	if !s.IsAlive() {
 		return "", "Shell is not alive", 1, false, errors.New("shell is not alive")
 	}
 
 	timeout := time.Duration(timeoutMs) * time.Millisecond
 
-	resultChan := make(chan commandResult)
-	s.commandQueue <- &commandExecution{
+	// Buffered so a command finishing as the shell exits doesn't block
+	resultChan := make(chan commandResult, 1)
+	select {
+	case s.commandQueue <- &commandExecution{
 		command:    command,
 		timeout:    timeout,
 		resultChan: resultChan,
 		ctx:        ctx,
+	}:
+	case <-s.exited:
+		return "", "Shell is not alive", 1, false, s.exitErr
 	}
 
-	result := <-resultChan
+	var result commandResult
+	select {
+	case result = <-resultChan:
+	case <-s.exited:
+		// The command may have finished just before the shell exited
+		select {
+		case result = <-resultChan:
+		case <-time.After(time.Second):
+			return "", "Shell is not alive", 1, false, s.exitErr
+		}
+	}
 	return result.stdout, result.stderr, result.exitCode, result.interrupted, result.err
 }
 
@@ -314,6 +424,50 @@ func (s *PersistentShell) IsAlive() bool {
 	return s.isAlive
 }
 
+func (s *PersistentShell) hasExited() bool {
+	select {
+	case <-s.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// exitError describes why the shell exited, with the start of what it wrote
+// to stderr.
+func exitError(err error, stderr string) error {
+	msg := "shell exited"
+	if err != nil {
+		msg += ": " + err.Error()
+	}
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return errors.New(msg)
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
 func shellQuote(s string) string {
 	quoted, _ := syntax.Quote(s, syntax.LangBash)
 	return quoted
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ToolSchemaToolName = "tool_schema"
+
+	compactDescriptionLimit = 200
+)
+
+// ToolSchemaTool returns the full schema for tools that were sent to the
+// provider in compact form.
+type ToolSchemaTool struct {
+	tools []BaseTool
+}
+
+type ToolSchemaParams struct {
+	Name string `json:"name"`
+}
+
+type toolSchemaResponse struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+	Required    []string       `json:"required"`
+}
+
+func NewToolSchemaTool(tools []BaseTool) *ToolSchemaTool {
+	return &ToolSchemaTool{tools: tools}
+}
+
+func (t *ToolSchemaTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ToolSchemaToolName,
+		Description: LoadToolDescription(ToolSchemaToolName),
+		Parameters: map[string]any{
+			"name": map[string]any{
+				"type":        "string",
+				"description": "The name of the tool to describe",
+			},
+		},
+		Required: []string{"name"},
+	}
+}
+
+func (t *ToolSchemaTool) Run(ctx context.Context, params ToolCall) (ToolResponse, error) {
+	var p ToolSchemaParams
+	if err := json.Unmarshal([]byte(params.Input), &p); err != nil {
+		return NewTextErrorResponse("Failed to parse parameters"), err
+	}
+
+	if p.Name == "" {
+		return NewTextErrorResponse("name is required"), nil
+	}
+
+	for _, tool := range t.tools {
+		info := tool.Info()
+		if info.Name != p.Name {
+			continue
+		}
+		schema, err := json.MarshalIndent(toolSchemaResponse{
+			Name:        info.Name,
+			Description: info.Description,
+			Parameters:  info.Parameters,
+			Required:    info.Required,
+		}, "", "  ")
+		if err != nil {
+			return NewTextErrorResponse("Failed to encode tool schema"), err
+		}
+		return NewTextResponse(string(schema)), nil
+	}
+
+	return NewTextErrorResponse(fmt.Sprintf("Tool not found: %s", p.Name)), nil
+}
+
+// compactTool wraps a tool so that it is advertised with an abbreviated
+// description and parameter schema while still running the full tool.
+type compactTool struct {
+	BaseTool
+}
+
+// NewCompactTool wraps tool with an abbreviated Info.
+func NewCompactTool(tool BaseTool) BaseTool {
+	return &compactTool{BaseTool: tool}
+}
+
+func (t *compactTool) Info() ToolInfo {
+	return CompactToolInfo(t.BaseTool.Info())
+}
+
+// CompactToolInfo keeps the first sentence of the description and strips
+// parameter descriptions, leaving only what is needed for a valid schema.
+func CompactToolInfo(info ToolInfo) ToolInfo {
+	description := strings.TrimSpace(info.Description)
+	if idx := strings.Index(description, "\n"); idx != -1 {
+		description = description[:idx]
+	}
+	if idx := strings.Index(description, ". "); idx != -1 {
+		description = description[:idx+1]
+	}
+	if len(description) > compactDescriptionLimit {
+		end := compactDescriptionLimit
+		for end > 0 && !utf8.RuneStart(description[end]) {
+			end--
+		}
+		description = description[:end] + "..."
+	}
+	description += fmt.Sprintf(" (Abbreviated; call %s for full documentation.)", ToolSchemaToolName)
+
+	parameters := make(map[string]any, len(info.Parameters))
+	for name, param := range info.Parameters {
+		parameters[name] = compactParameter(param)
+	}
+
+	return ToolInfo{
+		Name:        info.Name,
+		Description: description,
+		Parameters:  parameters,
+		Required:    info.Required,
+	}
+}
+
+// compactParameter drops descriptions and examples from a parameter schema
+// while keeping its structure.
+func compactParameter(param any) any {
+	schema, ok := param.(map[string]any)
+	if !ok {
+		return param
+	}
+	compact := make(map[string]any, len(schema))
+	for key, value := range schema {
+		switch key {
+		case "description", "examples", "default":
+			continue
+		case "properties":
+			if props, ok := value.(map[string]any); ok {
+				compactProps := make(map[string]any, len(props))
+				for name, prop := range props {
+					compactProps[name] = compactParameter(prop)
+				}
+				value = compactProps
+			}
+		case "items":
+			value = compactParameter(value)
+		}
+		compact[key] = value
+	}
+	return compact
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactToolInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		expected    string
+	}{
+		{
+			name:        "first sentence",
+			description: "Reads a file. Supports offsets and limits.",
+			expected:    "Reads a file.",
+		},
+		{
+			name:        "first line",
+			description: "Lists a directory\nSkips hidden files",
+			expected:    "Lists a directory",
+		},
+		{
+			name:        "long description",
+			description: strings.Repeat("a", 300),
+			expected:    strings.Repeat("a", compactDescriptionLimit) + "...",
+		},
+		{
+			name:        "multi-byte character at the limit",
+			description: strings.Repeat("a", compactDescriptionLimit-1) + strings.Repeat("é", 10),
+			expected:    strings.Repeat("a", compactDescriptionLimit-1) + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := CompactToolInfo(ToolInfo{Name: "tool", Description: tt.description})
+			assert.True(t, utf8.ValidString(info.Description))
+			assert.Equal(t, tt.expected+" (Abbreviated; call tool_schema for full documentation.)", info.Description)
+		})
+	}
+}
+
+func TestCompactToolInfoParameters(t *testing.T) {
+	info := CompactToolInfo(ToolInfo{
+		Name: "tool",
+		Parameters: map[string]any{
+			"path": map[string]any{"type": "string", "description": "The path", "examples": []string{"a"}},
+			"options": map[string]any{
+				"type":        "object",
+				"description": "Options",
+				"properties": map[string]any{
+					"depth": map[string]any{"type": "integer", "description": "Depth", "default": 1},
+				},
+			},
+			"items": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string", "description": "An item"},
+			},
+		},
+		Required: []string{"path"},
+	})
+
+	assert.Equal(t, map[string]any{
+		"path": map[string]any{"type": "string"},
+		"options": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"depth": map[string]any{"type": "integer"}},
+		},
+		"items": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	}, info.Parameters)
+	assert.Equal(t, []string{"path"}, info.Required)
+}
@@ -35,6 +35,9 @@ type ToolResponse struct {
 	Content  string           `json:"content"`
 	Metadata string           `json:"metadata,omitempty"`
 	IsError  bool             `json:"is_error"`
+	// Cost is what the call spent on paid external APIs, in USD. It is added
+	// to the session's cost.
+	Cost float64 `json:"cost,omitempty"`
 }
 
 func NewTextResponse(content string) ToolResponse {
@@ -55,6 +58,13 @@ func WithResponseMetadata(response ToolResponse, metadata any) ToolResponse {
 	return response
 }
 
+// WithResponseCost reports the USD cost of a call to a paid API, e.g. a
+// search, text-to-speech or image generation request.
+func WithResponseCost(response ToolResponse, cost float64) ToolResponse {
+	response.Cost = cost
+	return response
+}
+
 func NewTextErrorResponse(content string) ToolResponse {
 	return ToolResponse{
 		Type:    ToolResponseTypeText,
@@ -64,9 +74,10 @@ func NewTextErrorResponse(content string) ToolResponse {
 }
 
 type ToolCall struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Input string `json:"input"`
+	ID    string       `json:"id"`
+	Name  string       `json:"name"`
+	Input string       `json:"input"`
+	State RequestState `json:"-"`
 }
 
 type BaseTool interface {
@@ -74,6 +85,9 @@ type BaseTool interface {
 	Run(ctx context.Context, params ToolCall) (ToolResponse, error)
 }
 
+// GetContextValues reads the session and message IDs from the legacy context keys.
+//
+// Deprecated: use ToolCall.State.
 func GetContextValues(ctx context.Context) (string, string) {
 	sessionID := ctx.Value(SessionIDContextKey)
 	messageID := ctx.Value(MessageIDContextKey)
@@ -87,6 +101,8 @@ func GetContextValues(ctx context.Context) (string, string) {
 }
 
 // GetWorkingDirectory safely extracts the working directory from context
+//
+// Deprecated: use ToolCall.State.RequireWorkingDirectory.
 func GetWorkingDirectory(ctx context.Context) (string, error) {
 	value := ctx.Value(WorkingDirectoryContextKey)
 	if value == nil {
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// InputProblem is one way a tool call's input does not match the tool's schema.
+type InputProblem struct {
+	// Path locates the offending value, e.g. "edits[2].old_string"
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// InputValidationError reports tool input that does not match the tool's
+// parameter schema.
+type InputValidationError struct {
+	Tool     string
+	Problems []InputProblem
+}
+
+func (e *InputValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p.Path + ": " + p.Message
+	}
+	return fmt.Sprintf("invalid input for tool %s: %s", e.Tool, strings.Join(problems, "; "))
+}
+
+// Response is the error result returned to the model, structured so it can
+// correct the listed parameters and call the tool again.
+func (e *InputValidationError) Response() ToolResponse {
+	content, err := json.MarshalIndent(struct {
+		Error    string         `json:"error"`
+		Tool     string         `json:"tool"`
+		Problems []InputProblem `json:"problems"`
+		Hint     string         `json:"hint"`
+	}{
+		Error:    "invalid_tool_input",
+		Tool:     e.Tool,
+		Problems: e.Problems,
+		Hint:     fmt.Sprintf("The tool was not run. Fix the listed parameters to match the %s input schema and call it again.", e.Tool),
+	}, "", "  ")
+	if err != nil {
+		return NewTextErrorResponse(e.Error())
+	}
+	return NewTextErrorResponse(string(content))
+}
+
+// ValidateInput checks a tool call's JSON input against the tool's parameters
+// and required list. It covers the JSON Schema keywords tools use: type, enum,
+// properties, required and items. Unknown properties are allowed.
+func ValidateInput(info ToolInfo, input string) *InputValidationError {
+	if strings.TrimSpace(input) == "" {
+		input = "{}"
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(input)))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return &InputValidationError{Tool: info.Name, Problems: []InputProblem{{
+			Path:    "$",
+			Message: fmt.Sprintf("input is not valid JSON: %v", err),
+		}}}
+	}
+
+	v := &validator{}
+	v.validate("", map[string]any{
+		"type":       "object",
+		"properties": info.Parameters,
+		"required":   info.Required,
+	}, value)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &InputValidationError{Tool: info.Name, Problems: v.problems}
+}
+
+type validator struct {
+	problems []InputProblem
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	if path == "" {
+		path = "$"
+	}
+	v.problems = append(v.problems, InputProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(path string, schema map[string]any, value any) {
+	if types := schemaStrings(schema["type"]); len(types) > 0 && !matchesAnyType(types, value) {
+		v.fail(path, "expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+
+	if enum, ok := schema["enum"]; ok && !inEnum(enum, value) {
+		v.fail(path, "must be one of %s", compactJSON(enum))
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range schemaStrings(schema["required"]) {
+			if field, ok := value[name]; !ok || field == nil {
+				v.fail(joinPath(path, name), "required parameter is missing")
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propSchema, ok := properties[name].(map[string]any)
+			if !ok || value[name] == nil {
+				continue
+			}
+			v.validate(joinPath(path, name), propSchema, value[name])
+		}
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return
+		}
+		for i, item := range value {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), items, item)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func matchesAnyType(types []string, value any) bool {
+	for _, t := range types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(t string, value any) bool {
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "null":
+		return value == nil
+	}
+	// Unknown types are not ours to reject
+	return true
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// inEnum compares by JSON encoding, since Go tool schemas declare enums as
+// []string and MCP schemas as []any.
+func inEnum(enum any, value any) bool {
+	encoded := compactJSON(value)
+	for _, candidate := range schemaValues(enum) {
+		if compactJSON(candidate) == encoded {
+			return true
+		}
+	}
+	return false
+}
+
+func compactJSON(value any) string {
+	if n, ok := value.(json.Number); ok {
+		// Normalize 1.0 and 1 so numeric enums compare by value
+		if f, err := n.Float64(); err == nil {
+			value = f
+		}
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// schemaValues returns the elements of a schema list of any slice type.
+func schemaValues(list any) []any {
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	values := make([]any, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+// schemaStrings reads a keyword that is a string or a list of strings, such as
+// "type" and "required".
+func schemaStrings(keyword any) []string {
+	if s, ok := keyword.(string); ok {
+		return []string{s}
+	}
+	var result []string
+	for _, value := range schemaValues(keyword) {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mix/internal/artifact"
+	"mix/internal/logging"
+)
+
+const ViewArtifactToolName = "view_artifact"
+
+type viewArtifactTool struct{}
+
+type ViewArtifactParams struct {
+	ArtifactID string `json:"artifact_id"`
+	Offset     int64  `json:"offset"`
+	Limit      int64  `json:"limit"`
+}
+
+func NewViewArtifactTool() BaseTool {
+	return &viewArtifactTool{}
+}
+
+func (t *viewArtifactTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ViewArtifactToolName,
+		Description: LoadToolDescription(ViewArtifactToolName),
+		Parameters: map[string]any{
+			"artifact_id": map[string]any{
+				"type":        "string",
+				"description": "The artifact ID given in the truncated tool output",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Byte offset to start reading from (default 0)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of bytes to read",
+			},
+		},
+		Required: []string{"artifact_id"},
+	}
+}
+
+func (t *viewArtifactTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ViewArtifactParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+	if params.ArtifactID == "" {
+		return NewTextErrorResponse("artifact_id is required"), nil
+	}
+
+	chunk, err := artifact.Read(call.State.SessionID, params.ArtifactID, params.Offset, params.Limit)
+	if errors.Is(err, artifact.ErrNotFound) {
+		return NewTextErrorResponse(fmt.Sprintf("Artifact %s not found", params.ArtifactID)), nil
+	}
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Failed to read artifact: %v", err)), nil
+	}
+
+	end := chunk.Offset + int64(len(chunk.Content))
+	content := fmt.Sprintf("[Artifact %s: bytes %d-%d of %d]\n%s", chunk.ID, chunk.Offset, end, chunk.Size, chunk.Content)
+	if chunk.More() {
+		content += fmt.Sprintf("\n\n[%d bytes remaining; continue with offset %d]", chunk.Size-end, end)
+	}
+	return NewTextResponse(content), nil
+}
+
+// LimitOutput truncates text results larger than the configured limit,
+// storing the full content as an artifact of the session keyed by the tool
+// call ID.
+func LimitOutput(sessionID, toolCallID string, response ToolResponse) ToolResponse {
+	max := artifact.MaxOutputBytes()
+	if response.Type != ToolResponseTypeText || len(response.Content) <= max {
+		return response
+	}
+
+	full := response.Content
+	head := string(artifact.TrimPartialRune([]byte(full[:max])))
+	note := fmt.Sprintf("[Output truncated: showing the first %d of %d bytes. The full output is stored as artifact %s; read the rest with %s using artifact_id %q and offset %d.]",
+		len(head), len(full), toolCallID, ViewArtifactToolName, toolCallID, len(head))
+	if err := artifact.Store(sessionID, toolCallID, full); err != nil {
+		logging.Warn("Failed to store tool output artifact", "toolCallID", toolCallID, "error", err)
+		note = fmt.Sprintf("[Output truncated: showing the first %d of %d bytes. The full output could not be stored.]", len(head), len(full))
+	}
+
+	response.Content = head + "\n\n" + note
+	return response
+}
@@ -64,6 +64,8 @@ func (w *writeTool) Info() ToolInfo {
 	}
 }
 
+func (w *writeTool) DescribesDryRun() {}
+
 func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 	var params WriteParams
 	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
@@ -78,7 +80,7 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return NewTextErrorResponse("content is required"), nil
 	}
 
-	workingDir, err := GetWorkingDirectory(ctx)
+	workingDir, err := call.State.RequireWorkingDirectory()
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
@@ -108,11 +110,6 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return ToolResponse{}, fmt.Errorf("error checking file: %w", err)
 	}
 
-	dir := filepath.Dir(filePath)
-	if err = os.MkdirAll(dir, 0o755); err != nil {
-		return ToolResponse{}, fmt.Errorf("error creating directory: %w", err)
-	}
-
 	oldContent := ""
 	if fileInfo != nil && !fileInfo.IsDir() {
 		oldBytes, readErr := os.ReadFile(filePath)
@@ -121,7 +118,7 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		}
 	}
 
-	sessionID, messageID := GetContextValues(ctx)
+	sessionID, messageID := call.State.SessionID, call.State.MessageID
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session_id and message_id are required")
 	}
@@ -133,6 +130,17 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	additions := len(newLines)
 	removals := len(oldLines)
 
+	if call.State.DryRun {
+		action := "create"
+		if fileInfo != nil {
+			action = "update"
+		}
+		return NewDryRunResponse(DryRunEffect{
+			Tool:  WriteToolName,
+			Files: []DryRunFileChange{dryRunFileChange(action, filePath, oldContent, params.Content)},
+		}), nil
+	}
+
 	permissionPath := filepath.Dir(filePath)
 	if strings.HasPrefix(filePath, workingDir) {
 		permissionPath = workingDir
@@ -154,6 +162,10 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
+	if err = os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return ToolResponse{}, fmt.Errorf("error creating directory: %w", err)
+	}
+
 	err = os.WriteFile(filePath, []byte(params.Content), 0o644)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error writing file: %w", err)
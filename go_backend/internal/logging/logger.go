@@ -2,12 +2,15 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"runtime"
 	"runtime/debug"
 	"time"
+
+	"mix/internal/redact"
 )
 
 func init() {
@@ -36,19 +39,50 @@ func getCaller() string {
 	return caller
 }
 func Info(msg string, args ...any) {
-	slog.Info(msg, args...)
+	log(slog.LevelInfo, msg, args)
 }
 
 func Debug(msg string, args ...any) {
-	slog.Debug(msg, args...)
+	log(slog.LevelDebug, msg, args)
 }
 
 func Warn(msg string, args ...any) {
-	slog.Warn(msg, args...)
+	log(slog.LevelWarn, msg, args)
 }
 
 func Error(msg string, args ...any) {
-	slog.Error(msg, args...)
+	log(slog.LevelError, msg, args)
+}
+
+// log logs msg with the secrets in it and in its string and error arguments
+// redacted. Messages below the logger's level are dropped before redaction.
+func log(level slog.Level, msg string, args []any) {
+	ctx := context.Background()
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	redacted := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			redacted[i] = redact.String(v)
+		case error:
+			// Kept as an error unless it holds a secret
+			redacted[i] = v
+			if text := redact.String(v.Error()); text != v.Error() {
+				redacted[i] = text
+			}
+		case slog.Attr:
+			if v.Value.Kind() == slog.KindString {
+				v.Value = slog.StringValue(redact.String(v.Value.String()))
+			}
+			redacted[i] = v
+		default:
+			redacted[i] = arg
+		}
+	}
+	logger.Log(ctx, level, redact.String(msg), redacted...)
 }
 
 // Simplified logging - removed persist functions for embedded binary
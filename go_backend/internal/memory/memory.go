@@ -0,0 +1,212 @@
+// Package memory keeps long-term facts the agent learns across sessions, such
+// as the user's coding style or preferred render settings. User memories apply
+// to every session and workspace memories to sessions in one working
+// directory. New sessions carry the newest of them in their system prompt.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mix/internal/config"
+	"mix/internal/db"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("memory not found")
+
+type Scope string
+
+const (
+	ScopeUser      Scope = "user"
+	ScopeWorkspace Scope = "workspace"
+)
+
+const (
+	// MaxContentChars bounds a single memory, which should be one fact
+	MaxContentChars = 1000
+
+	DefaultMaxEntries     = 100
+	DefaultMaxPromptChars = 6000
+)
+
+// Memory is a fact saved for later sessions. Workspace is the working
+// directory of workspace memories and empty for user memories.
+type Memory struct {
+	ID        string
+	Scope     Scope
+	Workspace string
+	Content   string
+	// SessionID is the session the memory was written in
+	SessionID string
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+type Service interface {
+	// Write saves content in scope. Writing a memory that already exists
+	// refreshes it instead of adding a copy. The oldest memories of the scope
+	// beyond the configured maximum are dropped.
+	Write(ctx context.Context, scope Scope, workspace, content, sessionID string) (Memory, error)
+	// List returns all memories, newest first
+	List(ctx context.Context) ([]Memory, error)
+	Delete(ctx context.Context, id string) error
+	// Prompt renders the user's memories and workspace's for a system prompt,
+	// or "" when there are none or memory is disabled
+	Prompt(ctx context.Context, workspace string) (string, error)
+}
+
+type service struct {
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q}
+}
+
+func (s *service) Write(ctx context.Context, scope Scope, workspace, content, sessionID string) (Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Memory{}, errors.New("memory content is required")
+	}
+	if len([]rune(content)) > MaxContentChars {
+		return Memory{}, fmt.Errorf("memory is longer than %d characters; save one short fact per memory", MaxContentChars)
+	}
+	switch scope {
+	case ScopeUser:
+		workspace = ""
+	case ScopeWorkspace:
+		if workspace == "" {
+			return Memory{}, errors.New("workspace memories need a working directory")
+		}
+		workspace = filepath.Clean(workspace)
+	default:
+		return Memory{}, fmt.Errorf("invalid memory scope %q: must be %q or %q", scope, ScopeUser, ScopeWorkspace)
+	}
+
+	row, err := s.q.CreateMemory(ctx, db.CreateMemoryParams{
+		ID:        uuid.New().String(),
+		Scope:     string(scope),
+		Workspace: workspace,
+		Content:   content,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		return Memory{}, err
+	}
+	maxEntries, _ := limits()
+	err = s.q.PruneMemories(ctx, db.PruneMemoriesParams{
+		Scope:     string(scope),
+		Workspace: workspace,
+		Limit:     int64(maxEntries),
+	})
+	if err != nil {
+		return Memory{}, fmt.Errorf("failed to prune memories: %w", err)
+	}
+	return fromRow(row), nil
+}
+
+func (s *service) List(ctx context.Context) ([]Memory, error) {
+	rows, err := s.q.ListMemories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memories := make([]Memory, len(rows))
+	for i, row := range rows {
+		memories[i] = fromRow(row)
+	}
+	return memories, nil
+}
+
+func (s *service) Delete(ctx context.Context, id string) error {
+	deleted, err := s.q.DeleteMemory(ctx, id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (s *service) Prompt(ctx context.Context, workspace string) (string, error) {
+	if cfg := config.Get(); cfg != nil && cfg.Memory.Disabled {
+		return "", nil
+	}
+	if workspace != "" {
+		workspace = filepath.Clean(workspace)
+	}
+	rows, err := s.q.ListMemoriesForWorkspace(ctx, workspace)
+	if err != nil {
+		return "", err
+	}
+
+	// The newest memories win when they don't all fit
+	_, maxChars := limits()
+	var user, local []string
+	chars := 0
+	for _, row := range rows {
+		if chars += len(row.Content); chars > maxChars {
+			break
+		}
+		if Scope(row.Scope) == ScopeUser {
+			user = append(user, row.Content)
+		} else {
+			local = append(local, row.Content)
+		}
+	}
+	return Block(user, local), nil
+}
+
+// Block renders user and workspace memories as a system prompt section, or ""
+// when there are none.
+func Block(user, workspace []string) string {
+	if len(user)+len(workspace) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("# Memory\nThese facts were saved with memory_write in earlier sessions. Follow them unless the user says otherwise.\n")
+	section := func(title string, memories []string) {
+		if len(memories) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, memory := range memories {
+			fmt.Fprintf(&b, "- %s\n", strings.ReplaceAll(memory, "\n", "\n  "))
+		}
+	}
+	section("About the user", user)
+	section("About this workspace", workspace)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// limits returns the configured maximum memories per scope and characters
+// per prompt.
+func limits() (maxEntries, maxPromptChars int) {
+	maxEntries, maxPromptChars = DefaultMaxEntries, DefaultMaxPromptChars
+	if cfg := config.Get(); cfg != nil {
+		if cfg.Memory.MaxEntries > 0 {
+			maxEntries = cfg.Memory.MaxEntries
+		}
+		if cfg.Memory.MaxPromptChars > 0 {
+			maxPromptChars = cfg.Memory.MaxPromptChars
+		}
+	}
+	return maxEntries, maxPromptChars
+}
+
+func fromRow(row db.Memory) Memory {
+	return Memory{
+		ID:        row.ID,
+		Scope:     Scope(row.Scope),
+		Workspace: row.Workspace,
+		Content:   row.Content,
+		SessionID: row.SessionID,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
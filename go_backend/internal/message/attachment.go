@@ -1,8 +1,144 @@
 package message
 
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Attachment size limits, the strictest of the providers': images go up to
+// 5 MB, and PDF and text documents up to 32 MB
+const (
+	MaxImageBytes    = 5 << 20
+	MaxDocumentBytes = 32 << 20
+)
+
+var (
+	ErrUnsupportedAttachment = errors.New("unsupported attachment type")
+	ErrAttachmentTooLarge    = errors.New("attachment too large")
+)
+
+// imageTypes are the image formats every provider accepts
+var imageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// textDocumentTypes are the types outside text/* of documents read as plain
+// text
+var textDocumentTypes = []string{
+	"application/json",
+	"application/xml",
+	"application/yaml",
+	"application/x-yaml",
+	"application/toml",
+	"application/javascript",
+	"application/x-sh",
+}
+
 type Attachment struct {
 	FilePath string
 	FileName string
 	MimeType string
 	Content  []byte
 }
+
+// NewAttachment returns the attachment of data named name, detecting its type
+// when mimeType is empty or generic. It fails for types no provider takes and
+// for content over the size limit of its kind.
+func NewAttachment(name, mimeType string, data []byte) (Attachment, error) {
+	mimeType = mediaType(mimeType)
+	if mimeType == "" || mimeType == "application/octet-stream" || mimeType == "text/plain" {
+		mimeType = DetectMIMEType(name, data)
+	}
+	if err := ValidateAttachment(name, mimeType, len(data)); err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{FileName: name, MimeType: mimeType, Content: data}, nil
+}
+
+// ValidateAttachment checks that an attachment of size bytes is of a type
+// providers take and within the size limit of its kind.
+func ValidateAttachment(name, mimeType string, size int) error {
+	limit, kind := MaxImageBytes, "images"
+	switch {
+	case IsImageType(mimeType):
+	case IsDocumentType(mimeType):
+		limit, kind = MaxDocumentBytes, "documents"
+	default:
+		return fmt.Errorf("%w: %s is %s", ErrUnsupportedAttachment, name, mimeType)
+	}
+	if size > limit {
+		return fmt.Errorf("%w: %s is %d bytes, %s are limited to %d MB", ErrAttachmentTooLarge, name, size, kind, limit>>20)
+	}
+	return nil
+}
+
+// DetectMIMEType returns the media type of an attachment named name: the one
+// its content sniffs as, or, for content sniffing as generic text or binary,
+// the one its extension maps to.
+func DetectMIMEType(name string, data []byte) string {
+	detected := mediaType(http.DetectContentType(data))
+	if detected != "text/plain" && detected != "application/octet-stream" {
+		return detected
+	}
+	if byExtension := mediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))); byExtension != "" {
+		return byExtension
+	}
+	return detected
+}
+
+// mediaType returns mimeType without its parameters, lowercased.
+func mediaType(mimeType string) string {
+	if mimeType == "" {
+		return ""
+	}
+	if parsed, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return parsed
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+func IsImageType(mimeType string) bool {
+	return slices.Contains(imageTypes, mimeType)
+}
+
+func IsPDFType(mimeType string) bool {
+	return mimeType == "application/pdf"
+}
+
+// IsTextDocumentType reports whether attachments of mimeType are read as
+// plain text.
+func IsTextDocumentType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || slices.Contains(textDocumentTypes, mimeType)
+}
+
+// IsDocumentType reports whether attachments of mimeType are documents, PDFs
+// or text, rather than images.
+func IsDocumentType(mimeType string) bool {
+	return IsPDFType(mimeType) || IsTextDocumentType(mimeType)
+}
+
+// IsDocument reports whether the content is a PDF or text document.
+func (bc BinaryContent) IsDocument() bool {
+	return IsDocumentType(bc.MIMEType)
+}
+
+// Name returns the file name of the content, or a generic one with the
+// extension of its type when it has no path.
+func (bc BinaryContent) Name() string {
+	if bc.Path != "" {
+		return filepath.Base(bc.Path)
+	}
+	switch bc.MIMEType {
+	case "application/pdf":
+		return "attachment.pdf"
+	case "text/plain":
+		return "attachment.txt"
+	}
+	if extensions, err := mime.ExtensionsByType(bc.MIMEType); err == nil && len(extensions) > 0 {
+		return "attachment" + extensions[0]
+	}
+	return "attachment"
+}
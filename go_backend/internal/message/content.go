@@ -28,6 +28,9 @@ const (
 	FinishReasonCanceled         FinishReason = "canceled"
 	FinishReasonError            FinishReason = "error"
 	FinishReasonPermissionDenied FinishReason = "permission_denied"
+	FinishReasonBudgetExceeded   FinishReason = "budget_exceeded"
+	// The server stopped while the message was being generated
+	FinishReasonInterrupted FinishReason = "interrupted"
 
 	// Should never happen
 	FinishReasonUnknown FinishReason = "unknown"
@@ -107,18 +110,42 @@ func (ToolResult) isPart() {}
 type Finish struct {
 	Reason FinishReason `json:"reason"`
 	Time   int64        `json:"time"`
+
+	// ProviderRequestID identifies the provider request that produced the message
+	ProviderRequestID string `json:"provider_request_id,omitempty"`
+	// Cost is the USD cost of the generation that produced the message, nil
+	// for messages finished before costs were recorded
+	Cost *float64 `json:"cost,omitempty"`
+	// Provider and Model identify who answered, which differs from the
+	// message's model when a fallback provider took over
+	Provider models.ModelProvider `json:"provider,omitempty"`
+	Model    models.ModelID       `json:"model,omitempty"`
 }
 
 func (Finish) isPart() {}
 
+// HistoryPruned records how the agent's history strategy shortened the
+// history sent with the request that produced an assistant message.
+type HistoryPruned struct {
+	Strategy string `json:"strategy"`
+	// DroppedMessageIDs are the messages left out of the request
+	DroppedMessageIDs []string `json:"dropped_message_ids,omitempty"`
+	// PrunedToolCallIDs are the tool calls whose results were sent as
+	// placeholders
+	PrunedToolCallIDs []string `json:"pruned_tool_call_ids,omitempty"`
+}
+
+func (HistoryPruned) isPart() {}
+
 type Message struct {
-	ID        string
-	Role      MessageRole
-	SessionID string
-	Parts     []ContentPart
-	Model     models.ModelID
-	CreatedAt int64
-	UpdatedAt int64
+	ID             string
+	Role           MessageRole
+	SessionID      string
+	Parts          []ContentPart
+	Model          models.ModelID
+	CreatedAt      int64
+	UpdatedAt      int64
+	IdempotencyKey string
 }
 
 func (m *Message) Content() TextContent {
@@ -197,6 +224,17 @@ func (m *Message) FinishPart() *Finish {
 	return nil
 }
 
+// HistoryPruned returns what was left out of the history sent for the
+// message, or nil when the full history was sent.
+func (m *Message) HistoryPruned() *HistoryPruned {
+	for _, part := range m.Parts {
+		if c, ok := part.(HistoryPruned); ok {
+			return &c
+		}
+	}
+	return nil
+}
+
 func (m *Message) FinishReason() FinishReason {
 	for _, part := range m.Parts {
 		if c, ok := part.(Finish); ok {
@@ -264,6 +302,17 @@ func (m *Message) AppendContent(delta string) {
 	}
 }
 
+// SetContent replaces the text returned by Content.
+func (m *Message) SetContent(text string) {
+	for i, part := range m.Parts {
+		if _, ok := part.(TextContent); ok {
+			m.Parts[i] = TextContent{Text: text}
+			return
+		}
+	}
+	m.Parts = append([]ContentPart{TextContent{Text: text}}, m.Parts...)
+}
+
 func (m *Message) AppendReasoningContent(delta string) {
 	found := false
 	for i, part := range m.Parts {
@@ -358,14 +407,97 @@ func (m *Message) SetToolResults(tr []ToolResult) {
 }
 
 func (m *Message) AddFinish(reason FinishReason) {
-	// remove any existing finish part
+	// remove any existing finish part, keeping its request ID, cost and model
+	var previous Finish
 	for i, part := range m.Parts {
-		if _, ok := part.(Finish); ok {
+		if f, ok := part.(Finish); ok {
+			previous = f
 			m.Parts = slices.Delete(m.Parts, i, i+1)
 			break
 		}
 	}
-	m.Parts = append(m.Parts, Finish{Reason: reason, Time: time.Now().Unix()})
+	m.Parts = append(m.Parts, Finish{
+		Reason:            reason,
+		Time:              time.Now().Unix(),
+		ProviderRequestID: previous.ProviderRequestID,
+		Cost:              previous.Cost,
+		Provider:          previous.Provider,
+		Model:             previous.Model,
+	})
+}
+
+// SetProviderRequestID records the provider request ID on the finish part.
+// It must be called after AddFinish.
+func (m *Message) SetProviderRequestID(requestID string) {
+	for i, part := range m.Parts {
+		if f, ok := part.(Finish); ok {
+			f.ProviderRequestID = requestID
+			m.Parts[i] = f
+			return
+		}
+	}
+}
+
+// SetCost records the generation's cost on the finish part. It must be
+// called after AddFinish.
+func (m *Message) SetCost(cost float64) {
+	for i, part := range m.Parts {
+		if f, ok := part.(Finish); ok {
+			f.Cost = &cost
+			m.Parts[i] = f
+			return
+		}
+	}
+}
+
+// SetAnsweredBy records the model that produced the message. It must be
+// called after AddFinish.
+func (m *Message) SetAnsweredBy(model models.Model) {
+	for i, part := range m.Parts {
+		if f, ok := part.(Finish); ok {
+			f.Provider = model.Provider
+			f.Model = model.ID
+			m.Parts[i] = f
+			return
+		}
+	}
+}
+
+// AnsweredBy returns the provider and model that produced the message, or the
+// message's own model when none was recorded.
+func (m *Message) AnsweredBy() (models.ModelProvider, models.ModelID) {
+	if f := m.FinishPart(); f != nil && f.Model != "" {
+		return f.Provider, f.Model
+	}
+	model, _ := models.Lookup(m.Model)
+	return model.Provider, m.Model
+}
+
+// Cost returns the cost of the generation that produced the message, or 0
+// when none was recorded.
+func (m *Message) Cost() float64 {
+	if f := m.FinishPart(); f != nil && f.Cost != nil {
+		return *f.Cost
+	}
+	return 0
+}
+
+// CostUnknown reports whether the message is a finished assistant message
+// whose cost wasn't recorded, such as one finished before costs were.
+func (m *Message) CostUnknown() bool {
+	if m.Role != Assistant {
+		return false
+	}
+	f := m.FinishPart()
+	return f != nil && f.Cost == nil
+}
+
+// ProviderRequestID returns the provider request ID, if one was recorded.
+func (m *Message) ProviderRequestID() string {
+	if f := m.FinishPart(); f != nil {
+		return f.ProviderRequestID
+	}
+	return ""
 }
 
 func (m *Message) AddImageURL(url, detail string) {
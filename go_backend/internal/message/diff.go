@@ -0,0 +1,91 @@
+package message
+
+import "bytes"
+
+// ForkDiff compares the messages of two sessions that share a fork point.
+type ForkDiff struct {
+	// ForkPoint is the number of leading messages both sessions share
+	ForkPoint int
+	Base      Branch
+	Compare   Branch
+}
+
+// Branch is one side of a fork after the fork point.
+type Branch struct {
+	Messages []Message
+	// UniqueToolCalls are calls with no matching name and input on the other branch
+	UniqueToolCalls []ToolCall
+	// Cost leaves out the messages whose cost is unknown, counted in
+	// UnknownCostMessages
+	Cost                float64
+	UnknownCostMessages int
+	// DurationSeconds spans the first to the last update of the branch's messages
+	DurationSeconds int64
+}
+
+// Diff finds where base and compare diverge and summarizes each branch.
+// Forked messages are copies with new IDs, so messages are matched by role
+// and content.
+func Diff(base, compare []Message) ForkDiff {
+	forkPoint := 0
+	for forkPoint < len(base) && forkPoint < len(compare) && sameMessage(base[forkPoint], compare[forkPoint]) {
+		forkPoint++
+	}
+
+	diff := ForkDiff{
+		ForkPoint: forkPoint,
+		Base:      newBranch(base[forkPoint:]),
+		Compare:   newBranch(compare[forkPoint:]),
+	}
+	diff.Base.UniqueToolCalls = uniqueToolCalls(diff.Base.Messages, diff.Compare.Messages)
+	diff.Compare.UniqueToolCalls = uniqueToolCalls(diff.Compare.Messages, diff.Base.Messages)
+	return diff
+}
+
+func newBranch(messages []Message) Branch {
+	branch := Branch{Messages: messages}
+	for _, msg := range messages {
+		branch.Cost += msg.Cost()
+		if msg.CostUnknown() {
+			branch.UnknownCostMessages++
+		}
+	}
+	if len(messages) > 0 {
+		branch.DurationSeconds = messages[len(messages)-1].UpdatedAt - messages[0].CreatedAt
+	}
+	return branch
+}
+
+func sameMessage(a, b Message) bool {
+	if a.Role != b.Role {
+		return false
+	}
+	aParts, errA := marshallParts(a.Parts)
+	bParts, errB := marshallParts(b.Parts)
+	return errA == nil && errB == nil && bytes.Equal(aParts, bParts)
+}
+
+// uniqueToolCalls returns the calls in messages that other has no equivalent
+// of, counting duplicates so a call made twice on one side and once on the
+// other is reported once.
+func uniqueToolCalls(messages, other []Message) []ToolCall {
+	remaining := make(map[[2]string]int)
+	for _, msg := range other {
+		for _, tc := range msg.ToolCalls() {
+			remaining[[2]string{tc.Name, tc.Input}]++
+		}
+	}
+
+	var unique []ToolCall
+	for _, msg := range messages {
+		for _, tc := range msg.ToolCalls() {
+			key := [2]string{tc.Name, tc.Input}
+			if remaining[key] > 0 {
+				remaining[key]--
+				continue
+			}
+			unique = append(unique, tc)
+		}
+	}
+	return unique
+}
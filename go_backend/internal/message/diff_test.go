@@ -0,0 +1,118 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func userMessage(text string) Message {
+	return Message{Role: User, Parts: []ContentPart{TextContent{Text: text}}}
+}
+
+func assistantMessage(text string, cost *float64, calls ...ToolCall) Message {
+	parts := []ContentPart{TextContent{Text: text}}
+	for _, call := range calls {
+		parts = append(parts, call)
+	}
+	return Message{Role: Assistant, Parts: append(parts, Finish{Reason: FinishReasonEndTurn, Cost: cost})}
+}
+
+func cost(usd float64) *float64 {
+	return &usd
+}
+
+func TestDiff(t *testing.T) {
+	view := ToolCall{ID: "1", Name: "view", Input: `{"path":"a.go"}`}
+	viewAgain := ToolCall{ID: "2", Name: "view", Input: `{"path":"a.go"}`}
+	edit := ToolCall{ID: "3", Name: "edit", Input: `{"path":"a.go"}`}
+	shared := []Message{userMessage("fix it"), assistantMessage("looking", cost(0.5), view)}
+
+	tests := []struct {
+		name          string
+		base          []Message
+		compare       []Message
+		forkPoint     int
+		baseBranch    Branch
+		compareBranch Branch
+	}{
+		{
+			name:      "identical sessions",
+			base:      shared,
+			compare:   shared,
+			forkPoint: 2,
+		},
+		{
+			name:       "branches after the shared messages",
+			base:       append(append([]Message{}, shared...), userMessage("use edit"), assistantMessage("done", cost(1), edit)),
+			compare:    append(append([]Message{}, shared...), userMessage("just look")),
+			forkPoint:  2,
+			baseBranch: Branch{UniqueToolCalls: []ToolCall{edit}, Cost: 1},
+		},
+		{
+			name:      "messages are matched by content, not ID",
+			base:      []Message{{ID: "a", Role: User, Parts: []ContentPart{TextContent{Text: "hi"}}}},
+			compare:   []Message{{ID: "b", Role: User, Parts: []ContentPart{TextContent{Text: "hi"}}}},
+			forkPoint: 1,
+		},
+		{
+			name:          "duplicate calls are reported once per extra call",
+			base:          []Message{userMessage("go"), assistantMessage("a", cost(0.25), view, viewAgain)},
+			compare:       []Message{userMessage("go"), assistantMessage("b", cost(0.25), view)},
+			forkPoint:     1,
+			baseBranch:    Branch{UniqueToolCalls: []ToolCall{viewAgain}, Cost: 0.25},
+			compareBranch: Branch{Cost: 0.25},
+		},
+		{
+			name:       "messages without a recorded cost are counted as unknown",
+			base:       []Message{userMessage("go"), assistantMessage("old", nil), assistantMessage("new", cost(0))},
+			compare:    []Message{userMessage("stop")},
+			forkPoint:  0,
+			baseBranch: Branch{UnknownCostMessages: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Diff(tt.base, tt.compare)
+			assert.Equal(t, tt.forkPoint, diff.ForkPoint)
+			assert.Len(t, diff.Base.Messages, len(tt.base)-tt.forkPoint)
+			assert.Len(t, diff.Compare.Messages, len(tt.compare)-tt.forkPoint)
+			assert.Equal(t, tt.baseBranch.UniqueToolCalls, diff.Base.UniqueToolCalls)
+			assert.Equal(t, tt.compareBranch.UniqueToolCalls, diff.Compare.UniqueToolCalls)
+			assert.InDelta(t, tt.baseBranch.Cost, diff.Base.Cost, 1e-9)
+			assert.InDelta(t, tt.compareBranch.Cost, diff.Compare.Cost, 1e-9)
+			assert.Equal(t, tt.baseBranch.UnknownCostMessages, diff.Base.UnknownCostMessages)
+			assert.Equal(t, tt.compareBranch.UnknownCostMessages, diff.Compare.UnknownCostMessages)
+		})
+	}
+}
+
+func TestCostUnknown(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      Message
+		expected bool
+	}{
+		{name: "recorded cost", msg: assistantMessage("a", cost(0.1)), expected: false},
+		{name: "recorded zero cost", msg: assistantMessage("a", cost(0)), expected: false},
+		{name: "finished before costs were recorded", msg: assistantMessage("a", nil), expected: true},
+		{name: "still generating", msg: Message{Role: Assistant}, expected: false},
+		{name: "user messages have no cost", msg: userMessage("hi"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.msg.CostUnknown())
+		})
+	}
+}
+
+func TestCostKeptAcrossFinish(t *testing.T) {
+	msg := Message{Role: Assistant}
+	msg.AddFinish(FinishReasonEndTurn)
+	msg.SetCost(0.2)
+	msg.AddFinish(FinishReasonToolUse)
+	assert.InDelta(t, 0.2, msg.Cost(), 1e-9)
+	assert.False(t, msg.CostUnknown())
+}
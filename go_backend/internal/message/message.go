@@ -4,12 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"mix/internal/db"
 	"mix/internal/llm/models"
 	"mix/internal/pubsub"
+	"mix/internal/redact"
 
 	"github.com/google/uuid"
 )
@@ -18,6 +21,18 @@ type CreateMessageParams struct {
 	Role  MessageRole
 	Parts []ContentPart
 	Model models.ModelID
+	// IdempotencyKey is an optional client-supplied key, unique per session
+	IdempotencyKey string
+}
+
+// Page selects a window of a session's messages: the Limit messages right
+// before the message Before, the Limit newest ones with Latest, else the Limit
+// messages after the Offset oldest ones. A Limit of 0 takes all of them.
+type Page struct {
+	Limit  int64
+	Offset int64
+	Before string
+	Latest bool
 }
 
 type Service interface {
@@ -25,9 +40,28 @@ type Service interface {
 	Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error)
 	Update(ctx context.Context, message Message) error
 	Get(ctx context.Context, id string) (Message, error)
+	GetByIdempotencyKey(ctx context.Context, sessionID, key string) (Message, error)
+	// ReleaseIdempotencyKey clears the idempotency key of the message id, so
+	// a new message may take it.
+	ReleaseIdempotencyKey(ctx context.Context, id string) error
+	// List returns messages without reasoning content; use ListWithReasoning
+	// when the caller is allowed to see it.
 	List(ctx context.Context, sessionID string) ([]Message, error)
+	ListWithReasoning(ctx context.Context, sessionID string) ([]Message, error)
+	// ListPage returns a window of the session's messages, oldest first,
+	// with reasoning content when withReasoning is set.
+	ListPage(ctx context.Context, sessionID string, page Page, withReasoning bool) ([]Message, error)
+	// ListFrom returns the session's messages from the message id on, or
+	// none when id isn't one of them.
+	ListFrom(ctx context.Context, sessionID, id string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
+	// DeleteAfter deletes the messages that follow the message id in its
+	// session and returns them.
+	DeleteAfter(ctx context.Context, id string) ([]Message, error)
 	ListUserMessageHistory(ctx context.Context, limit, offset int64) ([]Message, error)
+	// ListUnfinished returns the assistant messages of all sessions that were
+	// created before the given Unix time and never got a finish reason.
+	ListUnfinished(ctx context.Context, createdBefore int64) ([]Message, error)
 	CopyMessagesToSession(ctx context.Context, sourceSessionID, targetSessionID string, messageIndex int64) error
 }
 
@@ -59,13 +93,41 @@ func (s *service) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *service) DeleteAfter(ctx context.Context, id string) ([]Message, error) {
+	message, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.List(ctx, message.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	for i, msg := range messages {
+		if msg.ID != id {
+			continue
+		}
+		deleted := messages[i+1:]
+		for _, msg := range deleted {
+			if err := s.q.DeleteMessage(ctx, msg.ID); err != nil {
+				return nil, err
+			}
+			if err := s.Publish(ctx, pubsub.DeletedEvent, msg); err != nil {
+				return nil, err
+			}
+		}
+		return deleted, nil
+	}
+	return nil, fmt.Errorf("message %s not found in session %s", id, message.SessionID)
+}
+
 func (s *service) Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error) {
 	if params.Role != Assistant {
 		params.Parts = append(params.Parts, Finish{
 			Reason: "stop",
 		})
 	}
-	partsJSON, err := marshallParts(params.Parts)
+	parts, reasoning := splitReasoning(params.Parts)
+	partsJSON, err := marshallParts(redactToolCalls(parts))
 	if err != nil {
 		return Message{}, err
 	}
@@ -75,6 +137,10 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 		Role:      string(params.Role),
 		Parts:     string(partsJSON),
 		Model:     sql.NullString{String: string(params.Model), Valid: true},
+		IdempotencyKey: sql.NullString{
+			String: params.IdempotencyKey,
+			Valid:  params.IdempotencyKey != "",
+		},
 	})
 	if err != nil {
 		return Message{}, err
@@ -83,6 +149,12 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 	if err != nil {
 		return Message{}, err
 	}
+	if reasoning.Thinking != "" {
+		if err := s.saveReasoning(ctx, message, reasoning); err != nil {
+			return Message{}, err
+		}
+		message.Parts = append([]ContentPart{reasoning}, message.Parts...)
+	}
 	err = s.Publish(ctx, pubsub.CreatedEvent, message)
 	if err != nil {
 		return Message{}, err
@@ -90,9 +162,9 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 	return message, nil
 }
 
-
 func (s *service) Update(ctx context.Context, message Message) error {
-	parts, err := marshallParts(message.Parts)
+	stored, reasoning := splitReasoning(message.Parts)
+	parts, err := marshallParts(redactToolCalls(stored))
 	if err != nil {
 		return err
 	}
@@ -109,6 +181,11 @@ func (s *service) Update(ctx context.Context, message Message) error {
 	if err != nil {
 		return err
 	}
+	if reasoning.Thinking != "" {
+		if err := s.saveReasoning(ctx, message, reasoning); err != nil {
+			return err
+		}
+	}
 	message.UpdatedAt = time.Now().Unix()
 	err = s.Publish(ctx, pubsub.UpdatedEvent, message)
 	if err != nil {
@@ -125,6 +202,21 @@ func (s *service) Get(ctx context.Context, id string) (Message, error) {
 	return s.fromDBItem(dbMessage)
 }
 
+func (s *service) GetByIdempotencyKey(ctx context.Context, sessionID, key string) (Message, error) {
+	dbMessage, err := s.q.GetMessageByIdempotencyKey(ctx, db.GetMessageByIdempotencyKeyParams{
+		SessionID:      sessionID,
+		IdempotencyKey: sql.NullString{String: key, Valid: true},
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	return s.fromDBItem(dbMessage)
+}
+
+func (s *service) ReleaseIdempotencyKey(ctx context.Context, id string) error {
+	return s.q.ClearMessageIdempotencyKey(ctx, id)
+}
+
 func (s *service) List(ctx context.Context, sessionID string) ([]Message, error) {
 	dbMessages, err := s.q.ListMessagesBySession(ctx, sessionID)
 	if err != nil {
@@ -140,6 +232,92 @@ func (s *service) List(ctx context.Context, sessionID string) ([]Message, error)
 	return messages, nil
 }
 
+func (s *service) ListWithReasoning(ctx context.Context, sessionID string) ([]Message, error) {
+	messages, err := s.List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.q.ListMessageReasoningBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	reasoning := make(map[string]ReasoningContent, len(rows))
+	for _, row := range rows {
+		reasoning[row.MessageID] = ReasoningContent{Thinking: row.Thinking, Duration: row.Duration}
+	}
+	for i := range messages {
+		if r, ok := reasoning[messages[i].ID]; ok {
+			messages[i].Parts = append([]ContentPart{r}, messages[i].Parts...)
+		}
+	}
+	return messages, nil
+}
+
+func (s *service) ListPage(ctx context.Context, sessionID string, page Page, withReasoning bool) ([]Message, error) {
+	// SQLite takes a negative limit as no limit
+	limit := page.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	var dbMessages []db.Message
+	var err error
+	if page.Before != "" || page.Latest {
+		dbMessages, err = s.q.ListMessagesBefore(ctx, db.ListMessagesBeforeParams{
+			SessionID: sessionID,
+			Before:    page.Before,
+			Limit:     limit,
+		})
+		slices.Reverse(dbMessages)
+	} else {
+		dbMessages, err = s.q.ListMessagesPage(ctx, db.ListMessagesPageParams{
+			SessionID: sessionID,
+			Limit:     limit,
+			Offset:    page.Offset,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.fromDBItems(dbMessages)
+	if err != nil || !withReasoning {
+		return messages, err
+	}
+	for i := range messages {
+		row, err := s.q.GetMessageReasoning(ctx, messages[i].ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages[i].Parts = append([]ContentPart{ReasoningContent{Thinking: row.Thinking, Duration: row.Duration}}, messages[i].Parts...)
+	}
+	return messages, nil
+}
+
+func (s *service) ListFrom(ctx context.Context, sessionID, id string) ([]Message, error) {
+	dbMessages, err := s.q.ListMessagesFrom(ctx, db.ListMessagesFromParams{
+		SessionID: sessionID,
+		ID:        id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.fromDBItems(dbMessages)
+}
+
+func (s *service) fromDBItems(dbMessages []db.Message) ([]Message, error) {
+	messages := make([]Message, len(dbMessages))
+	for i, dbMessage := range dbMessages {
+		var err error
+		messages[i], err = s.fromDBItem(dbMessage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
 func (s *service) ListUserMessageHistory(ctx context.Context, limit, offset int64) ([]Message, error) {
 	dbMessages, err := s.q.ListUserMessageHistory(ctx, db.ListUserMessageHistoryParams{
 		Limit:  limit,
@@ -158,6 +336,21 @@ func (s *service) ListUserMessageHistory(ctx context.Context, limit, offset int6
 	return messages, nil
 }
 
+func (s *service) ListUnfinished(ctx context.Context, createdBefore int64) ([]Message, error) {
+	dbMessages, err := s.q.ListUnfinishedAssistantMessages(ctx, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, len(dbMessages))
+	for i, dbMessage := range dbMessages {
+		messages[i], err = s.fromDBItem(dbMessage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
 func (s *service) CopyMessagesToSession(ctx context.Context, sourceSessionID, targetSessionID string, messageIndex int64) error {
 	// Get messages to copy using the new ListMessagesForFork query
 	dbMessages, err := s.q.ListMessagesForFork(ctx, db.ListMessagesForForkParams{
@@ -227,19 +420,43 @@ func (s *service) CopyMessagesToSession(ctx context.Context, sourceSessionID, ta
 	return nil
 }
 
+func (s *service) saveReasoning(ctx context.Context, message Message, reasoning ReasoningContent) error {
+	return s.q.UpsertMessageReasoning(ctx, db.UpsertMessageReasoningParams{
+		MessageID: message.ID,
+		SessionID: message.SessionID,
+		Thinking:  reasoning.Thinking,
+		Duration:  reasoning.Duration,
+	})
+}
+
+// splitReasoning separates reasoning from the parts stored on the message row.
+func splitReasoning(parts []ContentPart) ([]ContentPart, ReasoningContent) {
+	var reasoning ReasoningContent
+	stored := make([]ContentPart, 0, len(parts))
+	for _, part := range parts {
+		if r, ok := part.(ReasoningContent); ok {
+			reasoning = r
+			continue
+		}
+		stored = append(stored, part)
+	}
+	return stored, reasoning
+}
+
 func (s *service) fromDBItem(item db.Message) (Message, error) {
 	parts, err := unmarshallParts([]byte(item.Parts))
 	if err != nil {
 		return Message{}, err
 	}
 	return Message{
-		ID:        item.ID,
-		SessionID: item.SessionID,
-		Role:      MessageRole(item.Role),
-		Parts:     parts,
-		Model:     models.ModelID(item.Model.String),
-		CreatedAt: item.CreatedAt,
-		UpdatedAt: item.UpdatedAt,
+		ID:             item.ID,
+		SessionID:      item.SessionID,
+		Role:           MessageRole(item.Role),
+		Parts:          parts,
+		Model:          models.ModelID(item.Model.String),
+		CreatedAt:      item.CreatedAt,
+		UpdatedAt:      item.UpdatedAt,
+		IdempotencyKey: item.IdempotencyKey.String,
 	}, nil
 }
 
@@ -253,6 +470,7 @@ const (
 	toolCallType   partType = "tool_call"
 	toolResultType partType = "tool_result"
 	finishType     partType = "finish"
+	historyType    partType = "history_pruned"
 )
 
 type partWrapper struct {
@@ -260,6 +478,31 @@ type partWrapper struct {
 	Data ContentPart `json:"data"`
 }
 
+// redactToolCalls returns parts with the credentials in tool call inputs
+// redacted, as they are stored. Tools run with the input the model sent.
+func redactToolCalls(parts []ContentPart) []ContentPart {
+	var redacted []ContentPart
+	for i, part := range parts {
+		call, ok := part.(ToolCall)
+		if !ok {
+			continue
+		}
+		input := redact.String(call.Input)
+		if input == call.Input {
+			continue
+		}
+		if redacted == nil {
+			redacted = slices.Clone(parts)
+		}
+		call.Input = input
+		redacted[i] = call
+	}
+	if redacted == nil {
+		return parts
+	}
+	return redacted
+}
+
 func marshallParts(parts []ContentPart) ([]byte, error) {
 	wrappedParts := make([]partWrapper, len(parts))
 
@@ -281,6 +524,8 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 			typ = toolResultType
 		case Finish:
 			typ = finishType
+		case HistoryPruned:
+			typ = historyType
 		default:
 			return nil, fmt.Errorf("unknown part type: %T", part)
 		}
@@ -355,6 +600,12 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 				return nil, err
 			}
 			parts = append(parts, part)
+		case historyType:
+			part := HistoryPruned{}
+			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unknown part type: %s", wrapper.Type)
 		}
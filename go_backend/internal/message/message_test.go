@@ -0,0 +1,44 @@
+package message
+
+import (
+	"testing"
+
+	"mix/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactToolCalls(t *testing.T) {
+	const apiKey = "sk-ant-REDACTED"
+
+	tests := []struct {
+		name     string
+		parts    []ContentPart
+		expected []ContentPart
+	}{
+		{
+			name:     "credential in a tool call input",
+			parts:    []ContentPart{TextContent{Text: "Checking"}, ToolCall{ID: "1", Name: "bash", Input: `{"command":"echo ` + apiKey + `"}`}},
+			expected: []ContentPart{TextContent{Text: "Checking"}, ToolCall{ID: "1", Name: "bash", Input: `{"command":"echo ` + redact.Marker(redact.KindAnthropicKey) + `"}`}},
+		},
+		{
+			name:     "inputs without credentials",
+			parts:    []ContentPart{ToolCall{ID: "1", Name: "bash", Input: `{"command":"ls"}`}},
+			expected: []ContentPart{ToolCall{ID: "1", Name: "bash", Input: `{"command":"ls"}`}},
+		},
+		{
+			name:     "only tool call inputs are redacted",
+			parts:    []ContentPart{ToolResult{ToolCallID: "1", Content: "already redacted when the tool returned"}},
+			expected: []ContentPart{ToolResult{ToolCallID: "1", Content: "already redacted when the tool returned"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]ContentPart(nil), tt.parts...)
+			assert.Equal(t, tt.expected, redactToolCalls(tt.parts))
+			// The message the tools run from keeps the input the model sent
+			assert.Equal(t, original, tt.parts)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DBTX matches db.DBTX so WrapDB can sit between the queries and the connection.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// WrapDB times statements in DBQueryDuration, labelled with the query's sqlc
+// name. Multi-row queries are timed until the first rows are available.
+func WrapDB(db DBTX) DBTX {
+	return &timedDB{DBTX: db}
+}
+
+type timedDB struct {
+	DBTX
+}
+
+func (d *timedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery(query, time.Now())
+	return d.DBTX.ExecContext(ctx, query, args...)
+}
+
+func (d *timedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery(query, time.Now())
+	return d.DBTX.QueryContext(ctx, query, args...)
+}
+
+func (d *timedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery(query, time.Now())
+	return d.DBTX.QueryRowContext(ctx, query, args...)
+}
+
+func observeQuery(query string, start time.Time) {
+	DBQueryDuration.Observe(time.Since(start).Seconds(), queryName(query))
+}
+
+// queryName returns the name in a query's "-- name: GetMessage :one" header,
+// or "other" for queries without one.
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+// PoolStats exports the statistics of connection pools, read when metrics are
+// scraped, labelled with the pool's name.
+type PoolStats struct {
+	mu    sync.Mutex
+	pools map[string]*sql.DB
+}
+
+// NewPoolStats registers pool statistics without pools.
+func NewPoolStats() *PoolStats {
+	p := &PoolStats{pools: make(map[string]*sql.DB)}
+	register(p)
+	return p
+}
+
+// Track exports the statistics of db, replacing the pool tracked under name.
+func (p *PoolStats) Track(name string, db *sql.DB) {
+	p.mu.Lock()
+	p.pools[name] = db
+	p.mu.Unlock()
+}
+
+var poolFamilies = []struct {
+	desc
+	kind  string
+	value func(sql.DBStats) float64
+}{
+	{desc{"mix_db_open_connections", "Open connections by pool.", []string{"pool"}}, "gauge",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+	{desc{"mix_db_in_use_connections", "Connections running a statement by pool.", []string{"pool"}}, "gauge",
+		func(s sql.DBStats) float64 { return float64(s.InUse) }},
+	{desc{"mix_db_idle_connections", "Idle connections by pool.", []string{"pool"}}, "gauge",
+		func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	{desc{"mix_db_wait_count_total", "Statements that waited for a free connection by pool.", []string{"pool"}}, "counter",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+	{desc{"mix_db_wait_duration_seconds_total", "Time statements waited for a free connection by pool.", []string{"pool"}}, "counter",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+}
+
+func (p *PoolStats) write(w *bufio.Writer) {
+	p.mu.Lock()
+	stats := make(map[string]sql.DBStats, len(p.pools))
+	for name, db := range p.pools {
+		stats[name] = db.Stats()
+	}
+	p.mu.Unlock()
+
+	for _, family := range poolFamilies {
+		family.header(w, family.kind)
+		for _, name := range sortedKeys(stats) {
+			fmt.Fprintf(w, "%s%s %s\n", family.name, family.labelPairs(name), formatFloat(family.value(stats[name])))
+		}
+	}
+}
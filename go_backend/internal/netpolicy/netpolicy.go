@@ -0,0 +1,242 @@
+// Package netpolicy enforces the outbound network policy for tools. Go HTTP
+// clients get a transport that checks every dial; subprocesses such as MCP
+// stdio servers are pointed at a local filtering proxy through their env.
+package netpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"mix/internal/audit"
+	"mix/internal/config"
+)
+
+// ErrBlocked is wrapped by every error caused by the policy.
+var ErrBlocked = errors.New("blocked by network policy")
+
+type rule struct {
+	domain string
+	prefix *net.IPNet
+}
+
+type Policy struct {
+	allowed     []rule
+	denied      []rule
+	defaultDeny bool
+	audits      audit.Service
+
+	transportOnce sync.Once
+	transport     *http.Transport
+
+	proxyOnce sync.Once
+	proxyEnv  []string
+	proxyErr  error
+	// proxyAuth is the Proxy-Authorization header the proxy requires
+	proxyAuth string
+}
+
+// blockedByDefault are the link-local ranges and cloud metadata endpoints,
+// which hand out instance credentials. They can't be reached unless
+// allowedHosts lists them, whatever the rest of the policy: an address only
+// when an allowed IP or CIDR contains it, so an allowed host name resolving
+// or rebinding to one is still blocked.
+var blockedByDefault, _ = parseRules([]string{
+	"169.254.0.0/16",
+	"fe80::/10",
+	"fd00:ec2::254",
+	"100.100.100.200",
+	"metadata.google.internal",
+	"metadata.goog",
+})
+
+var (
+	defaultMu     sync.RWMutex
+	defaultPolicy = &Policy{}
+)
+
+// Init builds the process-wide policy from cfg. Blocked subprocess attempts
+// are recorded in audits.
+func Init(cfg config.NetworkConfig, audits audit.Service) error {
+	p, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	p.audits = audits
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultPolicy = p
+	return nil
+}
+
+// Default returns the process-wide policy. Before Init it allows everything.
+func Default() *Policy {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultPolicy
+}
+
+func New(cfg config.NetworkConfig) (*Policy, error) {
+	allowed, err := parseRules(cfg.AllowedHosts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network.allowedHosts: %w", err)
+	}
+	denied, err := parseRules(cfg.DeniedHosts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network.deniedHosts: %w", err)
+	}
+	return &Policy{
+		allowed:     allowed,
+		denied:      denied,
+		defaultDeny: cfg.DefaultDeny,
+	}, nil
+}
+
+func parseRules(entries []string) ([]rule, error) {
+	rules := make([]rule, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case strings.Contains(entry, "/"):
+			_, prefix, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule{prefix: prefix})
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			rules = append(rules, rule{prefix: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}})
+		default:
+			rules = append(rules, rule{domain: strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")})
+		}
+	}
+	return rules, nil
+}
+
+func (r rule) matches(host string, ip net.IP) bool {
+	if r.prefix != nil {
+		return ip != nil && r.prefix.Contains(ip)
+	}
+	return host == r.domain || strings.HasSuffix(host, "."+r.domain)
+}
+
+func matchAny(rules []rule, host string, ip net.IP) bool {
+	for _, r := range rules {
+		if r.matches(host, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether the configured rules restrict anything, beyond the
+// link-local and metadata addresses always blocked.
+func (p *Policy) Enabled() bool {
+	return p.defaultDeny || len(p.denied) > 0
+}
+
+// Check decides whether host, resolved to ip (nil if unknown), may be reached.
+func (p *Policy) Check(host string, ip net.IP) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip == nil {
+		ip = net.ParseIP(host)
+	}
+	if matchAny(p.denied, host, ip) {
+		return fmt.Errorf("%w: %s is denied", ErrBlocked, host)
+	}
+	if p.blockedByDefault(host, ip) {
+		return fmt.Errorf("%w: %s is a link-local or cloud metadata address", ErrBlocked, host)
+	}
+	if matchAny(p.allowed, host, ip) {
+		return nil
+	}
+	if !p.defaultDeny {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is not in the allowlist", ErrBlocked, host)
+}
+
+// blockedByDefault reports whether host or ip is one of blockedByDefault that
+// the allowlist doesn't list itself. A blocked address is only allowed by an
+// IP or CIDR, a blocked host name by a host name.
+func (p *Policy) blockedByDefault(host string, ip net.IP) bool {
+	for _, blocked := range blockedByDefault {
+		if !blocked.matches(host, ip) {
+			continue
+		}
+		if blocked.prefix != nil && !matchAny(p.allowed, "", ip) {
+			return true
+		}
+		if blocked.prefix == nil && !matchAny(p.allowed, host, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// DialContext dials addr, checking the host name and every resolved IP
+// against the policy before connecting.
+func (p *Policy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			ipStr, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return p.Check(host, net.ParseIP(ipStr))
+		},
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// Transport returns the shared HTTP transport that enforces the policy. It
+// ignores proxy environment variables since the policy applies to direct dials.
+func (p *Policy) Transport() http.RoundTripper {
+	p.transportOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = p.DialContext
+		p.transport = transport
+	})
+	return p.transport
+}
+
+// Client returns an HTTP client with the given timeout using the shared transport.
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: p.Transport(),
+	}
+}
+
+func (p *Policy) recordBlocked(source, target string, err error) {
+	if p.audits == nil {
+		return
+	}
+	p.audits.Record(context.Background(), audit.Entry{
+		ToolName:           source,
+		Input:              target,
+		IsError:            true,
+		Error:              err.Error(),
+		PermissionDecision: audit.PermissionBlocked,
+	})
+}
@@ -0,0 +1,251 @@
+package netpolicy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"mix/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.NetworkConfig
+		host    string
+		ip      string
+		blocked bool
+	}{
+		{
+			name: "everything is allowed by default",
+			host: "example.com",
+		},
+		{
+			name:    "cloud metadata is blocked by default",
+			host:    "169.254.169.254",
+			blocked: true,
+		},
+		{
+			name:    "metadata host names are blocked by default",
+			host:    "metadata.google.internal",
+			blocked: true,
+		},
+		{
+			name: "allowedHosts reaches metadata addresses",
+			cfg:  config.NetworkConfig{AllowedHosts: []string{"169.254.0.0/16"}},
+			host: "169.254.169.254",
+		},
+		{
+			name: "allowedHosts reaches metadata host names",
+			cfg:  config.NetworkConfig{AllowedHosts: []string{"metadata.google.internal"}},
+			host: "metadata.google.internal",
+		},
+		{
+			name:    "allowed host names resolving to metadata addresses are blocked",
+			cfg:     config.NetworkConfig{AllowedHosts: []string{"example.com"}},
+			host:    "example.com",
+			ip:      "169.254.169.254",
+			blocked: true,
+		},
+		{
+			name:    "allowed host names rebinding to link-local IPv6 are blocked",
+			cfg:     config.NetworkConfig{DefaultDeny: true, AllowedHosts: []string{"*.example.com"}},
+			host:    "api.example.com",
+			ip:      "fe80::1",
+			blocked: true,
+		},
+		{
+			name:    "allowed metadata host names resolving to metadata addresses are blocked",
+			cfg:     config.NetworkConfig{AllowedHosts: []string{"metadata.google.internal"}},
+			host:    "metadata.google.internal",
+			ip:      "169.254.169.254",
+			blocked: true,
+		},
+		{
+			name: "allowed CIDRs reach metadata addresses behind host names",
+			cfg:  config.NetworkConfig{AllowedHosts: []string{"metadata.google.internal", "169.254.169.254"}},
+			host: "metadata.google.internal",
+			ip:   "169.254.169.254",
+		},
+		{
+			name: "allowed host names resolving elsewhere are allowed",
+			cfg:  config.NetworkConfig{DefaultDeny: true, AllowedHosts: []string{"example.com"}},
+			host: "example.com",
+			ip:   "93.184.216.34",
+		},
+		{
+			name:    "denied domains cover subdomains",
+			cfg:     config.NetworkConfig{DeniedHosts: []string{"*.example.com"}},
+			host:    "api.example.com",
+			blocked: true,
+		},
+		{
+			name: "denied domains don't cover lookalikes",
+			cfg:  config.NetworkConfig{DeniedHosts: []string{"example.com"}},
+			host: "notexample.com",
+		},
+		{
+			name:    "denied CIDRs match the resolved IP",
+			cfg:     config.NetworkConfig{DeniedHosts: []string{"10.0.0.0/8"}},
+			host:    "internal.test",
+			ip:      "10.1.2.3",
+			blocked: true,
+		},
+		{
+			name:    "deny wins over allow",
+			cfg:     config.NetworkConfig{AllowedHosts: []string{"example.com"}, DeniedHosts: []string{"example.com"}},
+			host:    "example.com",
+			blocked: true,
+		},
+		{
+			name: "default deny allows the allowlist",
+			cfg:  config.NetworkConfig{DefaultDeny: true, AllowedHosts: []string{"Example.com"}},
+			host: "example.com.",
+		},
+		{
+			name:    "default deny blocks the rest",
+			cfg:     config.NetworkConfig{DefaultDeny: true, AllowedHosts: []string{"example.com"}},
+			host:    "example.org",
+			blocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.cfg)
+			require.NoError(t, err)
+
+			err = p.Check(tt.host, net.ParseIP(tt.ip))
+			if tt.blocked {
+				assert.ErrorIs(t, err, ErrBlocked)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		rules   []string
+		wantErr bool
+	}{
+		{
+			name:    "domains are lowercased and wildcards dropped",
+			entries: []string{" Example.COM ", "*.internal.test", ""},
+			rules:   []string{"example.com", "internal.test"},
+		},
+		{
+			name:    "IPs become single-address prefixes",
+			entries: []string{"10.0.0.1", "::1"},
+			rules:   []string{"10.0.0.1/32", "::1/128"},
+		},
+		{
+			name:    "CIDRs are kept",
+			entries: []string{"192.168.0.0/16"},
+			rules:   []string{"192.168.0.0/16"},
+		},
+		{
+			name:    "invalid CIDRs fail",
+			entries: []string{"10.0.0.0/99"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRules(tt.entries)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var got []string
+			for _, r := range rules {
+				if r.prefix != nil {
+					got = append(got, r.prefix.String())
+				} else {
+					got = append(got, r.domain)
+				}
+			}
+			assert.Equal(t, tt.rules, got)
+		})
+	}
+}
+
+func TestProxyRequiresCredential(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	p, err := New(config.NetworkConfig{DefaultDeny: true, AllowedHosts: []string{"127.0.0.1"}})
+	require.NoError(t, err)
+	env, err := p.SubprocessEnv()
+	require.NoError(t, err)
+
+	var proxyURL *url.URL
+	for _, variable := range env {
+		if value, ok := strings.CutPrefix(variable, "HTTP_PROXY="); ok {
+			proxyURL, err = url.Parse(value)
+			require.NoError(t, err)
+		}
+	}
+	require.NotNil(t, proxyURL)
+	require.NotNil(t, proxyURL.User)
+
+	withoutCredential := *proxyURL
+	withoutCredential.User = nil
+	wrongCredential := *proxyURL
+	wrongCredential.User = url.UserPassword(proxyUser, "wrong")
+
+	tests := []struct {
+		name   string
+		proxy  *url.URL
+		target string
+		status int
+	}{
+		{
+			name:   "requests without the credential are rejected",
+			proxy:  &withoutCredential,
+			target: upstream.URL,
+			status: http.StatusProxyAuthRequired,
+		},
+		{
+			name:   "requests with a wrong credential are rejected",
+			proxy:  &wrongCredential,
+			target: upstream.URL,
+			status: http.StatusProxyAuthRequired,
+		},
+		{
+			name:   "requests with the credential are forwarded",
+			proxy:  proxyURL,
+			target: upstream.URL,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "requests with the credential are still checked against the policy",
+			proxy:  proxyURL,
+			target: "http://127.0.0.2:1/",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(tt.proxy)}}
+			resp, err := client.Get(tt.target)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
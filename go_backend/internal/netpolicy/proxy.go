@@ -0,0 +1,166 @@
+package netpolicy
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"mix/internal/logging"
+)
+
+// proxySource is the audit tool name for attempts blocked at the local proxy
+const proxySource = "egress-proxy"
+
+// proxyUser is the user name of the egress proxy's credential
+const proxyUser = "mix"
+
+// SubprocessEnv returns proxy variables that route a subprocess's HTTP(S)
+// traffic through a local proxy enforcing the policy. It returns nil when the
+// policy restricts nothing. Programs that ignore proxy variables bypass it.
+// The proxy URL carries a credential generated for this proxy, so other local
+// processes can't use the proxy to reach what the policy allows tools.
+func (p *Policy) SubprocessEnv() ([]string, error) {
+	if !p.Enabled() {
+		return nil, nil
+	}
+	p.proxyOnce.Do(func() {
+		secret := make([]byte, 16)
+		if _, err := rand.Read(secret); err != nil {
+			p.proxyErr = fmt.Errorf("failed to generate egress proxy credential: %w", err)
+			return
+		}
+		credential := url.UserPassword(proxyUser, hex.EncodeToString(secret))
+		password, _ := credential.Password()
+		p.proxyAuth = "Basic " + base64.StdEncoding.EncodeToString([]byte(proxyUser+":"+password))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			p.proxyErr = fmt.Errorf("failed to start egress proxy: %w", err)
+			return
+		}
+		go func() {
+			defer logging.RecoverPanic("egress-proxy", nil)
+			if err := http.Serve(listener, p); err != nil {
+				logging.Error("Egress proxy stopped", "error", err)
+			}
+		}()
+
+		logging.Info("Started egress proxy for subprocesses", "address", listener.Addr().String())
+		proxyURL := (&url.URL{Scheme: "http", User: credential, Host: listener.Addr().String()}).String()
+		p.proxyEnv = []string{
+			"HTTP_PROXY=" + proxyURL,
+			"HTTPS_PROXY=" + proxyURL,
+			"ALL_PROXY=" + proxyURL,
+			"http_proxy=" + proxyURL,
+			"https_proxy=" + proxyURL,
+			"all_proxy=" + proxyURL,
+			"NO_PROXY=",
+			"no_proxy=",
+		}
+	})
+	return p.proxyEnv, p.proxyErr
+}
+
+// ServeHTTP implements a forward proxy: CONNECT tunnels for HTTPS and absolute
+// URL requests for plain HTTP, both dialed through the policy. Requests
+// without the proxy's credential are rejected.
+func (p *Policy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.authorized(r) {
+		logging.Warn("Rejected egress proxy request without credentials", "remote", r.RemoteAddr, "target", r.Host)
+		w.Header().Set("Proxy-Authenticate", `Basic realm="mix"`)
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+
+	if r.URL.Host == "" {
+		http.Error(w, "proxy requests must use an absolute URL", http.StatusBadRequest)
+		return
+	}
+
+	outReq := r.Clone(r.Context())
+	outReq.RequestURI = ""
+	outReq.Header.Del("Proxy-Connection")
+	outReq.Header.Del("Proxy-Authorization")
+
+	resp, err := p.Transport().RoundTrip(outReq)
+	if err != nil {
+		p.proxyError(w, r.URL.Host, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// authorized reports whether r carries the proxy's credential.
+func (p *Policy) authorized(r *http.Request) bool {
+	if p.proxyAuth == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Proxy-Authorization")), []byte(p.proxyAuth)) == 1
+}
+
+func (p *Policy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.proxyError(w, r.Host, err)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	go func() {
+		defer client.Close()
+		defer upstream.Close()
+		// The client may have sent its TLS hello before reading our reply
+		io.Copy(upstream, buffered.Reader)
+	}()
+	go func() {
+		defer client.Close()
+		defer upstream.Close()
+		io.Copy(client, upstream)
+	}()
+}
+
+func (p *Policy) proxyError(w http.ResponseWriter, target string, err error) {
+	if errors.Is(err, ErrBlocked) {
+		logging.Warn("Blocked outbound connection", "target", target, "error", err)
+		p.recordBlocked(proxySource, target, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
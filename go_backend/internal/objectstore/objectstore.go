@@ -0,0 +1,320 @@
+// Package objectstore is a small client for S3 and S3-compatible object
+// storage, including Google Cloud Storage through its XML API and HMAC keys.
+// It talks to the REST API directly with path-style URLs, which AWS, GCS and
+// the common S3-compatible services all accept.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+const (
+	ProviderS3  = "s3"
+	ProviderGCS = "gcs"
+
+	gcsEndpoint = "https://storage.googleapis.com"
+	// unsignedPayload lets uploads stream without hashing the body first
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Config locates a bucket. Keys passed to the Client are relative to Prefix.
+type Config struct {
+	Provider string
+	Endpoint string
+	Bucket   string
+	Prefix   string
+	Region   string
+	Profile  string
+}
+
+// Object is one stored object, with its key relative to the prefix.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type Client struct {
+	provider string
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	region   string
+	creds    aws.CredentialsProvider
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+// New loads credentials from the standard AWS chain and returns a client for
+// cfg's bucket. An empty Provider means S3.
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(cfg.Region))
+	}
+	if cfg.Profile != "" {
+		loadOpts = append(loadOpts, awsconfig.WithSharedConfigProfile(cfg.Profile))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	provider := cfg.Provider
+	if provider == "" {
+		provider = ProviderS3
+	}
+	region := awsCfg.Region
+	endpoint := cfg.Endpoint
+	switch provider {
+	case ProviderS3:
+		if region == "" {
+			region = "us-east-1"
+		}
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+	case ProviderGCS:
+		// GCS accepts any region in V4 signatures; "auto" is conventional
+		if region == "" {
+			region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = gcsEndpoint
+		}
+	default:
+		return nil, fmt.Errorf("unknown object storage provider %q", provider)
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return nil, fmt.Errorf("invalid object storage endpoint %q", endpoint)
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &Client{
+		provider: provider,
+		endpoint: endpointURL,
+		bucket:   cfg.Bucket,
+		prefix:   prefix,
+		region:   region,
+		creds:    awsCfg.Credentials,
+		signer:   v4.NewSigner(),
+		client:   &http.Client{Timeout: 10 * time.Minute},
+	}, nil
+}
+
+// Location is the URI of key, such as s3://bucket/prefix/key.
+func (c *Client) Location(key string) string {
+	scheme := "s3"
+	if c.provider == ProviderGCS {
+		scheme = "gs"
+	}
+	return scheme + "://" + c.bucket + "/" + c.prefix + key
+}
+
+func (c *Client) url(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket + "/"
+	if key != "" {
+		u.Path += c.prefix + key
+	}
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do signs req with payloadHash and sends it, returning the response when it
+// succeeded.
+func (c *Client) do(ctx context.Context, req *http.Request, payloadHash string) (*http.Response, error) {
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	creds, err := c.creds.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage credentials: %w", err)
+	}
+	if err := c.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", c.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound && req.Method != http.MethodPut {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return resp, nil
+}
+
+// send signs a request with a fully hashed body, for small payloads.
+func (c *Client) send(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	return c.do(ctx, req, hex.EncodeToString(sum[:]))
+}
+
+// Put uploads size bytes from body as key. The body streams unhashed, so
+// large files aren't read twice.
+func (c *Client) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url(key, nil).String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(ctx, req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get returns the content of key, or ErrNotFound.
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes key. Deleting a missing key succeeds.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.send(ctx, http.MethodDelete, key, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// List returns the objects whose keys start with prefix.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	query := url.Values{"list-type": {"2"}, "prefix": {c.prefix + prefix}}
+	for {
+		resp, err := c.send(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bucket listing: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			objects = append(objects, Object{
+				Key:          strings.TrimPrefix(object.Key, c.prefix),
+				Size:         object.Size,
+				LastModified: object.LastModified,
+			})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	return objects, nil
+}
+
+// SignedURL returns a URL that downloads key without credentials until it
+// expires.
+func (c *Client) SignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	query := url.Values{"X-Amz-Expires": {strconv.Itoa(int(expires.Seconds()))}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(key, query).String(), nil)
+	if err != nil {
+		return "", err
+	}
+	creds, err := c.creds.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get storage credentials: %w", err)
+	}
+	signed, _, err := c.signer.PresignHTTP(ctx, creds, req, unsignedPayload, "s3", c.region, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to sign URL: %w", err)
+	}
+	return signed, nil
+}
+
+// ExpireAfter replaces the bucket's lifecycle configuration with one rule
+// deleting objects under the client's prefix days after they are written.
+func (c *Client) ExpireAfter(ctx context.Context, days int) error {
+	var body string
+	if c.provider == ProviderGCS {
+		body = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>`+
+			`<LifecycleConfiguration><Rule><Action><Delete/></Action>`+
+			`<Condition><Age>%d</Age><MatchesPrefix>%s</MatchesPrefix></Condition></Rule></LifecycleConfiguration>`,
+			days, xmlEscape(c.prefix))
+	} else {
+		body = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>`+
+			`<LifecycleConfiguration><Rule><ID>mix-expire</ID><Filter><Prefix>%s</Prefix></Filter>`+
+			`<Status>Enabled</Status><Expiration><Days>%d</Days></Expiration></Rule></LifecycleConfiguration>`,
+			xmlEscape(c.prefix), days)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url("", url.Values{"lifecycle": {""}}).String(), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	// S3 requires Content-MD5 on lifecycle updates
+	md5Sum := md5.Sum([]byte(body))
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+	req.Header.Set("Content-Type", "application/xml")
+	sum := sha256.Sum256([]byte(body))
+	resp, err := c.do(ctx, req, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return fmt.Errorf("failed to set bucket lifecycle: %w", err)
+	}
+	return resp.Body.Close()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
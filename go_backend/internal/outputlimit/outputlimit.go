@@ -0,0 +1,76 @@
+// Package outputlimit shapes assistant responses to a session's output
+// profile, for clients that can't handle arbitrarily large messages. Oversized
+// responses are either truncated, with the full text kept as an artifact, or
+// split into continuation parts.
+package outputlimit
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"mix/internal/artifact"
+	"mix/internal/config"
+)
+
+// Output is a response as returned to the client. Parts holds at least one
+// part; only split responses have more than one.
+type Output struct {
+	Parts     []string
+	Truncated bool
+	// ArtifactID names the stored full response of a truncated one
+	ArtifactID string
+}
+
+// Apply limits content, the response of message messageID in session
+// sessionID, to profile. The zero profile leaves content unchanged.
+func Apply(profile config.OutputProfile, sessionID, messageID, content string) (Output, error) {
+	if profile.MaxBytes <= 0 || len(content) <= profile.MaxBytes {
+		return Output{Parts: []string{content}}, nil
+	}
+
+	switch profile.Mode {
+	case config.OutputModeSplit:
+		return Output{Parts: split(content, profile.MaxBytes)}, nil
+	case config.OutputModeTruncate:
+		if err := artifact.Store(sessionID, messageID, content); err != nil {
+			return Output{}, fmt.Errorf("failed to store full response: %w", err)
+		}
+		note := fmt.Sprintf("\n\n[Response truncated to fit the %s output profile. The full %d-byte response is artifact %s; read it with artifacts.get.]", profile.Name, len(content), messageID)
+		head := ""
+		if budget := profile.MaxBytes - len(note); budget > 0 {
+			head = content[:cutPoint(content, budget)]
+		}
+		return Output{Parts: []string{head + note}, Truncated: true, ArtifactID: messageID}, nil
+	}
+	return Output{}, fmt.Errorf("unknown output mode %q", profile.Mode)
+}
+
+// split cuts content into parts of at most maxBytes.
+func split(content string, maxBytes int) []string {
+	var parts []string
+	for len(content) > maxBytes {
+		cut := cutPoint(content, maxBytes)
+		parts = append(parts, content[:cut])
+		content = content[cut:]
+	}
+	return append(parts, content)
+}
+
+// cutPoint picks where to end a part of at most maxBytes: after the last
+// paragraph or line break in the second half of the window, so parts read
+// naturally, otherwise at the last character boundary.
+func cutPoint(content string, maxBytes int) int {
+	window := content[:maxBytes]
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(window, sep); i >= maxBytes/2 {
+			return i + len(sep)
+		}
+	}
+	if cut := len(artifact.TrimPartialRune([]byte(window))); cut > 0 {
+		return cut
+	}
+	// maxBytes is smaller than the first character; keep it whole
+	_, size := utf8.DecodeRuneInString(content)
+	return size
+}
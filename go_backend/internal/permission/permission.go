@@ -3,9 +3,9 @@ package permission
 import (
 	"context"
 	"errors"
-	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -19,6 +19,19 @@ import (
 
 var ErrorPermissionDenied = errors.New("permission denied")
 
+// DefaultTimeout is how long a request waits for an answer before it is
+// denied, unless configured with permissionTimeoutSeconds.
+const DefaultTimeout = 30 * time.Second
+
+// Resolution is how a permission request was answered.
+type Resolution string
+
+const (
+	ResolutionGranted  Resolution = "granted"
+	ResolutionDenied   Resolution = "denied"
+	ResolutionTimedOut Resolution = "timed_out"
+)
+
 type CreatePermissionRequest struct {
 	SessionID   string `json:"session_id"`
 	ToolName    string `json:"tool_name"`
@@ -29,13 +42,17 @@ type CreatePermissionRequest struct {
 }
 
 type PermissionRequest struct {
-	ID          string `json:"id"`
-	SessionID   string `json:"session_id"`
-	ToolName    string `json:"tool_name"`
-	Description string `json:"description"`
-	Action      string `json:"action"`
-	Params      any    `json:"params"`
-	Path        string `json:"path"`
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	ToolName    string    `json:"tool_name"`
+	Description string    `json:"description"`
+	Action      string    `json:"action"`
+	Params      any       `json:"params"`
+	Path        string    `json:"path"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Resolution is set on the UpdatedEvent published once the request is
+	// answered or times out
+	Resolution Resolution `json:"resolution,omitempty"`
 }
 
 type Service interface {
@@ -44,6 +61,14 @@ type Service interface {
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
 	Request(opts CreatePermissionRequest) bool
+	// ListPending returns the requests waiting for an answer, oldest first
+	ListPending() []PermissionRequest
+}
+
+// pendingRequest is a request waiting in Request for its answer on respCh.
+type pendingRequest struct {
+	request PermissionRequest
+	respCh  chan bool
 }
 
 type permissionService struct {
@@ -51,29 +76,52 @@ type permissionService struct {
 
 	sessionPermissions []PermissionRequest
 	pendingRequests    sync.Map
-	sessions          session.Service
+	sessions           session.Service
 }
 
 func (s *permissionService) GrantPersistant(permission PermissionRequest) {
-	respCh, ok := s.pendingRequests.Load(permission.ID)
-	if ok {
-		respCh.(chan bool) <- true
+	if request, ok := s.resolve(permission.ID, ResolutionGranted); ok {
+		permission = request
 	}
 	s.sessionPermissions = append(s.sessionPermissions, permission)
 }
 
 func (s *permissionService) Grant(permission PermissionRequest) {
-	respCh, ok := s.pendingRequests.Load(permission.ID)
-	if ok {
-		respCh.(chan bool) <- true
-	}
+	s.resolve(permission.ID, ResolutionGranted)
 }
 
 func (s *permissionService) Deny(permission PermissionRequest) {
-	respCh, ok := s.pendingRequests.Load(permission.ID)
-	if ok {
-		respCh.(chan bool) <- false
+	s.resolve(permission.ID, ResolutionDenied)
+}
+
+// resolve answers the pending request with the given ID and publishes the
+// resolution. Requests that were already answered are ignored.
+func (s *permissionService) resolve(id string, resolution Resolution) (PermissionRequest, bool) {
+	value, ok := s.pendingRequests.LoadAndDelete(id)
+	if !ok {
+		return PermissionRequest{}, false
+	}
+	pending := value.(*pendingRequest)
+	pending.respCh <- resolution == ResolutionGranted
+
+	resolved := pending.request
+	resolved.Resolution = resolution
+	if err := s.Publish(context.Background(), pubsub.UpdatedEvent, resolved); err != nil {
+		logging.Error("Failed to publish permission resolution", "permissionID", id, "error", err)
 	}
+	return pending.request, true
+}
+
+func (s *permissionService) ListPending() []PermissionRequest {
+	var pending []PermissionRequest
+	s.pendingRequests.Range(func(_, value any) bool {
+		pending = append(pending, value.(*pendingRequest).request)
+		return true
+	})
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	return pending
 }
 
 // isPathWithinSessionRoot checks if the given path is accessible within the session working directory using os.Root
@@ -135,7 +183,6 @@ func (s *permissionService) isPathWithinSessionRoot(sessionID, requestedPath str
 	return true // Path is accessible within session working directory
 }
 
-
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	logging.Info("Permission request", "sessionID", opts.SessionID, "toolName", opts.ToolName, "action", opts.Action, "path", opts.Path)
 
@@ -182,6 +229,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 		Description: opts.Description,
 		Action:      opts.Action,
 		Params:      opts.Params,
+		CreatedAt:   time.Now(),
 	}
 
 	for _, p := range s.sessionPermissions {
@@ -193,24 +241,32 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 
 	respCh := make(chan bool, 1)
 
-	s.pendingRequests.Store(permission.ID, respCh)
-	defer s.pendingRequests.Delete(permission.ID)
+	s.pendingRequests.Store(permission.ID, &pendingRequest{request: permission, respCh: respCh})
 
 	logging.Info("Publishing permission request for approval", "permissionID", permission.ID)
-	fmt.Printf("PERMISSION: Publishing event to %d subscribers\n", s.GetSubscriberCount())
+	logging.Debug("Publishing permission request", "subscribers", s.GetSubscriberCount())
 	if err := s.Publish(context.Background(), pubsub.CreatedEvent, permission); err != nil {
 		logging.Error("Failed to publish permission request", "permissionID", permission.ID, "error", err)
+		s.pendingRequests.Delete(permission.ID)
 		return false
 	}
-	fmt.Printf("PERMISSION: Event published successfully\n")
 
-	// Wait for the response with a timeout (30 seconds)
+	// Deny if nobody answers, so clients that can't show the prompt don't
+	// leave the agent waiting forever
+	timeout := DefaultTimeout
+	if seconds := config.Get().PermissionTimeoutSeconds; seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
 	select {
 	case resp := <-respCh:
 		logging.Info("Permission responded", "permissionID", permission.ID, "approved", resp)
 		return resp
-	case <-time.After(30 * time.Second):
-		logging.Info("Permission request timed out after 30 seconds, denying", "permissionID", permission.ID)
+	case <-time.After(timeout):
+		if _, ok := s.resolve(permission.ID, ResolutionTimedOut); !ok {
+			// Answered just as the timeout fired
+			return <-respCh
+		}
+		logging.Info("Permission request timed out, denying", "permissionID", permission.ID, "timeout", timeout)
 		return false
 	}
 }
@@ -219,6 +275,6 @@ func NewPermissionService(sessions session.Service) Service {
 	return &permissionService{
 		Broker:             pubsub.NewBroker[PermissionRequest](),
 		sessionPermissions: make([]PermissionRequest, 0),
-		sessions:          sessions,
+		sessions:           sessions,
 	}
 }
@@ -0,0 +1,255 @@
+// Package pin keeps files and messages in a session's context. Pinned content
+// is sent ahead of the conversation in every request, so summarizing the
+// session or trimming its history never drops it. Files are read anew for
+// each request.
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mix/internal/db"
+	"mix/internal/message"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("pin not found")
+
+type Kind string
+
+const (
+	KindFile    Kind = "file"
+	KindMessage Kind = "message"
+)
+
+const (
+	// MaxFileBytes bounds the content sent for one pinned file; the rest is
+	// left out
+	MaxFileBytes = 100 * 1024
+	// charsPerToken approximates token counts as the agent's estimates do
+	charsPerToken = 4
+)
+
+// Pin is a file or message kept in a session's context. Target is the file
+// path, relative to the session's working directory when inside it, or the
+// message ID.
+type Pin struct {
+	ID        string
+	SessionID string
+	Kind      Kind
+	Target    string
+	CreatedAt int64
+}
+
+// Content is a pin as the model receives it.
+type Content struct {
+	Pin
+	Text string
+	// Tokens approximates the size of Text
+	Tokens int64
+	// Truncated reports that the file was cut at MaxFileBytes
+	Truncated bool
+	// Error says why the content is unavailable, e.g. a deleted file; Text
+	// is empty then
+	Error string
+}
+
+type Service interface {
+	// Pin keeps a file, by path absolute or relative to workingDirectory, or
+	// a message of the session in its context. Pinning the same file or
+	// message again returns the existing pin.
+	Pin(ctx context.Context, sessionID, workingDirectory string, kind Kind, target string) (Pin, error)
+	// List returns the session's pins, oldest first
+	List(ctx context.Context, sessionID string) ([]Pin, error)
+	Unpin(ctx context.Context, id string) error
+	// Contents reads the session's pins as they are now
+	Contents(ctx context.Context, sessionID, workingDirectory string) ([]Content, error)
+}
+
+type service struct {
+	q        db.Querier
+	messages message.Service
+}
+
+func NewService(q db.Querier, messages message.Service) Service {
+	return &service{q: q, messages: messages}
+}
+
+func (s *service) Pin(ctx context.Context, sessionID, workingDirectory string, kind Kind, target string) (Pin, error) {
+	switch kind {
+	case KindFile:
+		path, err := filePath(workingDirectory, target)
+		if err != nil {
+			return Pin{}, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return Pin{}, fmt.Errorf("cannot pin %s: %w", target, err)
+		}
+		if !info.Mode().IsRegular() {
+			return Pin{}, fmt.Errorf("cannot pin %s: not a regular file", target)
+		}
+		target = path
+		if workingDirectory != "" {
+			if rel, err := filepath.Rel(workingDirectory, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				target = rel
+			}
+		}
+	case KindMessage:
+		msg, err := s.messages.Get(ctx, target)
+		if err == sql.ErrNoRows || (err == nil && msg.SessionID != sessionID) {
+			return Pin{}, fmt.Errorf("message %s not found in session %s", target, sessionID)
+		}
+		if err != nil {
+			return Pin{}, err
+		}
+	default:
+		return Pin{}, fmt.Errorf("invalid pin kind %q: must be %q or %q", kind, KindFile, KindMessage)
+	}
+
+	row, err := s.q.CreateContextPin(ctx, db.CreateContextPinParams{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Kind:      string(kind),
+		Target:    target,
+	})
+	if err != nil {
+		return Pin{}, err
+	}
+	return fromRow(row), nil
+}
+
+func (s *service) List(ctx context.Context, sessionID string) ([]Pin, error) {
+	rows, err := s.q.ListContextPinsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	pins := make([]Pin, len(rows))
+	for i, row := range rows {
+		pins[i] = fromRow(row)
+	}
+	return pins, nil
+}
+
+func (s *service) Unpin(ctx context.Context, id string) error {
+	deleted, err := s.q.DeleteContextPin(ctx, id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (s *service) Contents(ctx context.Context, sessionID, workingDirectory string) ([]Content, error) {
+	pins, err := s.List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]Content, len(pins))
+	for i, p := range pins {
+		contents[i] = Content{Pin: p}
+		switch p.Kind {
+		case KindFile:
+			s.readFile(&contents[i], workingDirectory)
+		case KindMessage:
+			s.readMessage(ctx, &contents[i])
+		}
+		contents[i].Tokens = int64((len(contents[i].Text) + charsPerToken - 1) / charsPerToken)
+	}
+	return contents, nil
+}
+
+func (s *service) readFile(content *Content, workingDirectory string) {
+	path, err := filePath(workingDirectory, content.Target)
+	if err != nil {
+		content.Error = err.Error()
+		return
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		content.Error = "file no longer exists"
+		return
+	}
+	if err != nil {
+		content.Error = err.Error()
+		return
+	}
+	if len(data) > MaxFileBytes {
+		data, content.Truncated = data[:MaxFileBytes], true
+	}
+	content.Text = string(data)
+}
+
+func (s *service) readMessage(ctx context.Context, content *Content) {
+	msg, err := s.messages.Get(ctx, content.Target)
+	if err != nil {
+		content.Error = "message not found"
+		return
+	}
+	parts := []string{}
+	if text := msg.Content().Text; text != "" {
+		parts = append(parts, text)
+	}
+	for _, result := range msg.ToolResults() {
+		parts = append(parts, result.Content)
+	}
+	content.Text = strings.Join(parts, "\n\n")
+}
+
+// Block renders contents as the text sent ahead of the conversation, or ""
+// when there are none.
+func Block(contents []Content) string {
+	if len(contents) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<pinned-context>\nThe user pinned these files and messages to keep them in context. Files are shown as they are now.\n")
+	for _, c := range contents {
+		tag, attr := "file", fmt.Sprintf("path=%q", c.Target)
+		if c.Kind == KindMessage {
+			tag, attr = "message", fmt.Sprintf("id=%q", c.Target)
+		}
+		if c.Error != "" {
+			fmt.Fprintf(&b, "\n<%s %s unavailable=%q/>\n", tag, attr, c.Error)
+			continue
+		}
+		if c.Truncated {
+			attr += fmt.Sprintf(" truncated=\"first %d bytes\"", MaxFileBytes)
+		}
+		fmt.Fprintf(&b, "\n<%s %s>\n%s\n</%s>\n", tag, attr, strings.TrimRight(c.Text, "\n"), tag)
+	}
+	b.WriteString("</pinned-context>")
+	return b.String()
+}
+
+// filePath resolves a pinned file's path against the working directory.
+func filePath(workingDirectory, target string) (string, error) {
+	if target == "" {
+		return "", errors.New("file path is required")
+	}
+	if filepath.IsAbs(target) {
+		return filepath.Clean(target), nil
+	}
+	if workingDirectory == "" {
+		return "", fmt.Errorf("cannot resolve %s: the session has no working directory", target)
+	}
+	return filepath.Join(workingDirectory, target), nil
+}
+
+func fromRow(row db.ContextPin) Pin {
+	return Pin{
+		ID:        row.ID,
+		SessionID: row.SessionID,
+		Kind:      Kind(row.Kind),
+		Target:    row.Target,
+		CreatedAt: row.CreatedAt,
+	}
+}
@@ -0,0 +1,195 @@
+// Package question lets the agent ask the user a question mid-turn. The turn
+// waits while the question is published to clients, and goes on with the
+// answer one of them sends, or without one once the question times out.
+package question
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/logging"
+	"mix/internal/pubsub"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound      = errors.New("question not pending")
+	ErrInvalidAnswer = errors.New("invalid answer")
+)
+
+// DefaultTimeout is how long a question waits for an answer, unless
+// configured with questionTimeoutSeconds.
+const DefaultTimeout = 5 * time.Minute
+
+// Resolution is how a question was resolved.
+type Resolution string
+
+const (
+	ResolutionAnswered Resolution = "answered"
+	ResolutionTimedOut Resolution = "timed_out"
+	// ResolutionCanceled is set when the turn asking was canceled
+	ResolutionCanceled Resolution = "canceled"
+)
+
+type CreateQuestion struct {
+	SessionID  string
+	ToolCallID string
+	Question   string
+	// Options are the answers to choose from; without any, the answer is
+	// free text
+	Options []string
+	// AllowFreeText accepts answers other than the options
+	AllowFreeText bool
+}
+
+type Question struct {
+	ID            string    `json:"id"`
+	SessionID     string    `json:"session_id"`
+	ToolCallID    string    `json:"tool_call_id"`
+	Question      string    `json:"question"`
+	Options       []string  `json:"options,omitempty"`
+	AllowFreeText bool      `json:"allow_free_text"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Resolution and Answer are set on the UpdatedEvent published once the
+	// question is resolved
+	Resolution Resolution `json:"resolution,omitempty"`
+	Answer     string     `json:"answer,omitempty"`
+}
+
+type Service interface {
+	pubsub.Suscriber[Question]
+	// Ask publishes the question and waits for its answer. A question that
+	// times out or whose ctx is done resolves without one.
+	Ask(ctx context.Context, opts CreateQuestion) (Question, error)
+	// Answer resolves the pending question with the given ID. An answer must
+	// be one of the options unless the question allows free text.
+	Answer(id, answer string) (Question, error)
+	// ListPending returns the questions waiting for an answer, oldest first
+	ListPending() []Question
+}
+
+// pendingQuestion is a question waiting in Ask for its resolution on respCh.
+type pendingQuestion struct {
+	question Question
+	respCh   chan Question
+}
+
+type questionService struct {
+	*pubsub.Broker[Question]
+
+	pending sync.Map
+}
+
+func NewService() Service {
+	return &questionService{Broker: pubsub.NewBroker[Question]()}
+}
+
+func (s *questionService) Ask(ctx context.Context, opts CreateQuestion) (Question, error) {
+	if strings.TrimSpace(opts.Question) == "" {
+		return Question{}, fmt.Errorf("question is required")
+	}
+	q := Question{
+		ID:            uuid.New().String(),
+		SessionID:     opts.SessionID,
+		ToolCallID:    opts.ToolCallID,
+		Question:      opts.Question,
+		Options:       opts.Options,
+		AllowFreeText: opts.AllowFreeText || len(opts.Options) == 0,
+		CreatedAt:     time.Now(),
+	}
+
+	respCh := make(chan Question, 1)
+	s.pending.Store(q.ID, &pendingQuestion{question: q, respCh: respCh})
+
+	logging.Info("Publishing question for the user", "questionID", q.ID, "sessionID", q.SessionID)
+	if err := s.Publish(context.Background(), pubsub.CreatedEvent, q); err != nil {
+		s.pending.Delete(q.ID)
+		return Question{}, fmt.Errorf("failed to publish question: %w", err)
+	}
+
+	// Go on without an answer if nobody gives one, so clients that can't show
+	// the question don't leave the turn waiting forever
+	timeout := DefaultTimeout
+	if seconds := config.Get().QuestionTimeoutSeconds; seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case answered := <-respCh:
+		return answered, nil
+	case <-timer.C:
+		return s.resolveUnanswered(q.ID, ResolutionTimedOut, respCh), nil
+	case <-ctx.Done():
+		s.resolveUnanswered(q.ID, ResolutionCanceled, respCh)
+		return Question{}, ctx.Err()
+	}
+}
+
+// resolveUnanswered resolves a question nobody answered, or returns the
+// answer given just as it was resolving.
+func (s *questionService) resolveUnanswered(id string, resolution Resolution, respCh chan Question) Question {
+	if resolved, ok := s.resolve(id, resolution, ""); ok {
+		logging.Info("Question resolved without an answer", "questionID", id, "resolution", resolution)
+		return resolved
+	}
+	return <-respCh
+}
+
+func (s *questionService) Answer(id, answer string) (Question, error) {
+	value, ok := s.pending.Load(id)
+	if !ok {
+		return Question{}, ErrNotFound
+	}
+	q := value.(*pendingQuestion).question
+	if strings.TrimSpace(answer) == "" {
+		return Question{}, fmt.Errorf("%w: answer is required", ErrInvalidAnswer)
+	}
+	if !q.AllowFreeText && !slices.Contains(q.Options, answer) {
+		return Question{}, fmt.Errorf("%w: must be one of %s", ErrInvalidAnswer, strings.Join(q.Options, ", "))
+	}
+	resolved, ok := s.resolve(id, ResolutionAnswered, answer)
+	if !ok {
+		return Question{}, ErrNotFound
+	}
+	return resolved, nil
+}
+
+// resolve resolves the pending question with the given ID and publishes the
+// resolution. Questions that were already resolved are ignored.
+func (s *questionService) resolve(id string, resolution Resolution, answer string) (Question, bool) {
+	value, ok := s.pending.LoadAndDelete(id)
+	if !ok {
+		return Question{}, false
+	}
+	pending := value.(*pendingQuestion)
+	resolved := pending.question
+	resolved.Resolution = resolution
+	resolved.Answer = answer
+	pending.respCh <- resolved
+
+	if err := s.Publish(context.Background(), pubsub.UpdatedEvent, resolved); err != nil {
+		logging.Error("Failed to publish question resolution", "questionID", id, "error", err)
+	}
+	return resolved, true
+}
+
+func (s *questionService) ListPending() []Question {
+	var pending []Question
+	s.pending.Range(func(_, value any) bool {
+		pending = append(pending, value.(*pendingQuestion).question)
+		return true
+	})
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	return pending
+}
@@ -0,0 +1,332 @@
+// Package redact finds credentials in text, such as API keys, OAuth tokens
+// and the values of secret environment variables, and replaces them with
+// [REDACTED:<kind>] markers before the text is logged, stored or exported.
+package redact
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Kinds of the secrets built-in detection reports. Patterns configured with
+// Options.Patterns are reported as KindCustom.
+const (
+	KindAnthropicKey = "anthropic_key"
+	KindOpenAIKey    = "openai_key"
+	KindAWSKey       = "aws_access_key"
+	KindGitHubToken  = "github_token"
+	KindGoogleKey    = "google_api_key"
+	KindSlackToken   = "slack_token"
+	KindStripeKey    = "stripe_key"
+	KindJWT          = "jwt"
+	KindPrivateKey   = "private_key"
+	KindBearer       = "bearer_token"
+	KindCredential   = "credential"
+	KindSecret       = "secret"
+	KindHighEntropy  = "high_entropy"
+	KindCustom       = "custom"
+)
+
+// minSecretLength is the length below which known secret values aren't
+// redacted, since short values would match ordinary text
+const minSecretLength = 8
+
+// rule is a pattern whose match, or Group of it when above zero, is a secret.
+type rule struct {
+	kind  string
+	re    *regexp.Regexp
+	group int
+}
+
+// builtinRules are the well-known credential formats. Earlier rules win when
+// matches overlap, so the specific formats come before the generic ones.
+var builtinRules = []rule{
+	{kind: KindPrivateKey, re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{kind: KindAnthropicKey, re: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{kind: KindOpenAIKey, re: regexp.MustCompile(`\bsk-(?:proj-|svcacct-)?[A-Za-z0-9_\-]{20,}`)},
+	{kind: KindAWSKey, re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: KindGitHubToken, re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{kind: KindGoogleKey, re: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+	{kind: KindSlackToken, re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}`)},
+	{kind: KindStripeKey, re: regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}`)},
+	{kind: KindJWT, re: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`)},
+	{kind: KindBearer, re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/\-]{16,}=*)`), group: 1},
+	// Only the value of an assignment such as api_key=... or "password": "..."
+	{kind: KindCredential, re: regexp.MustCompile(`(?i)(?:api[_\-]?key|secret|token|passw(?:or)?d|credentials?)["']?\s*[:=]\s*["']?([^\s"'<>,;(){}\[\]]{8,})`), group: 1},
+}
+
+// secretEnvName matches the names of environment variables holding secrets
+var secretEnvName = regexp.MustCompile(`(?i)(^|_)(API_?KEY|KEY|SECRET|TOKEN|PASSW(OR)?D|CREDENTIALS?)(_|$)`)
+
+// entropyToken matches the words high entropy detection considers; slashes
+// and dots are left out so paths and host names aren't mistaken for tokens
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+=_\-]{20,}`)
+
+// Options configure detection.
+type Options struct {
+	// Disabled turns redaction off
+	Disabled bool
+	// Strict withholds tool results containing credentials instead of
+	// redacting them
+	Strict bool
+	// Patterns are extra regular expressions of secrets. When a pattern has a
+	// capture group, only the first group's text is redacted.
+	Patterns []string
+	// MinEntropy, when above zero, also redacts words of at least 20 letters
+	// and digits whose Shannon entropy in bits per character is at least this
+	MinEntropy float64
+	// Secrets are values always redacted, such as the configured API keys.
+	// The values of environment variables named like secrets are added.
+	Secrets []string
+}
+
+// Redactor finds and redacts secrets. The zero value redacts nothing.
+type Redactor struct {
+	disabled   bool
+	strict     bool
+	rules      []rule
+	minEntropy float64
+	secrets    []string
+}
+
+// Finding is a secret at s[Start:End] of the text searched.
+type Finding struct {
+	Kind  string
+	Start int
+	End   int
+}
+
+// New returns a Redactor configured with opts, or an error for a pattern that
+// doesn't compile.
+func New(opts Options) (*Redactor, error) {
+	r := &Redactor{
+		disabled:   opts.Disabled,
+		strict:     opts.Strict,
+		rules:      slices.Clone(builtinRules),
+		minEntropy: opts.MinEntropy,
+	}
+	for _, pattern := range opts.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %w", pattern, err)
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		r.rules = append(r.rules, rule{kind: KindCustom, re: re, group: group})
+	}
+	r.secrets = secretValues(append(slices.Clone(opts.Secrets), EnvSecrets(environ())...))
+	return r, nil
+}
+
+// Strict reports whether tool results with credentials are withheld.
+func (r *Redactor) Strict() bool {
+	return r != nil && !r.disabled && r.strict
+}
+
+// Find returns the secrets in s, sorted by position and not overlapping.
+// Secrets are values redacted on top of the configured ones, such as a
+// session's environment.
+func (r *Redactor) Find(s string, secrets ...string) []Finding {
+	if r == nil || r.disabled || s == "" {
+		return nil
+	}
+	var findings []Finding
+	for _, value := range append(secretValues(secrets), r.secrets...) {
+		for offset := 0; ; {
+			i := strings.Index(s[offset:], value)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			findings = append(findings, Finding{Kind: KindSecret, Start: start, End: start + len(value)})
+			offset = start + len(value)
+		}
+	}
+	for _, rule := range r.rules {
+		for _, m := range rule.re.FindAllStringSubmatchIndex(s, -1) {
+			start, end := m[2*rule.group], m[2*rule.group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			if rule.kind == KindCredential && end < len(s) && s[end] == '(' {
+				// Code assigning a call's result, as in secret = os.Getenv(...)
+				continue
+			}
+			findings = append(findings, Finding{Kind: rule.kind, Start: start, End: end})
+		}
+	}
+	if r.minEntropy > 0 {
+		for _, m := range entropyToken.FindAllStringIndex(s, -1) {
+			if word := s[m[0]:m[1]]; lettersAndDigits(word) && entropy(word) >= r.minEntropy {
+				findings = append(findings, Finding{Kind: KindHighEntropy, Start: m[0], End: m[1]})
+			}
+		}
+	}
+	return merge(findings)
+}
+
+// Redact returns s with the secrets Find reports replaced by
+// [REDACTED:<kind>] markers, and the secrets found.
+func (r *Redactor) Redact(s string, secrets ...string) (string, []Finding) {
+	findings := r.Find(s, secrets...)
+	if len(findings) == 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	last := 0
+	for _, f := range findings {
+		b.WriteString(s[last:f.Start])
+		b.WriteString(Marker(f.Kind))
+		last = f.End
+	}
+	b.WriteString(s[last:])
+	return b.String(), findings
+}
+
+// Marker is the text a secret of kind is replaced with.
+func Marker(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// Kinds returns the distinct kinds of findings, sorted.
+func Kinds(findings []Finding) []string {
+	var kinds []string
+	for _, f := range findings {
+		if !slices.Contains(kinds, f.Kind) {
+			kinds = append(kinds, f.Kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// EnvSecrets returns the values of the variables of env whose names mark them
+// as secrets, such as OPENAI_API_KEY or DB_PASSWORD.
+func EnvSecrets(env map[string]string) []string {
+	var values []string
+	for name, value := range env {
+		if secretEnvName.MatchString(name) && len(value) >= minSecretLength {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// environ returns the process environment as a map.
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// secretValues returns the distinct values long enough to redact, longest
+// first so a value containing another is redacted whole.
+func secretValues(values []string) []string {
+	var kept []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) >= minSecretLength && !slices.Contains(kept, value) {
+			kept = append(kept, value)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return len(kept[i]) > len(kept[j]) })
+	return kept
+}
+
+// merge sorts findings by position and drops those overlapping an earlier
+// one. Findings are appended in order of precedence, which the stable sort
+// keeps among those starting at the same position.
+func merge(findings []Finding) []Finding {
+	if len(findings) < 2 {
+		return findings
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+	merged := findings[:1]
+	for _, f := range findings[1:] {
+		last := &merged[len(merged)-1]
+		if f.Start < last.End {
+			last.End = max(last.End, f.End)
+			continue
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+// lettersAndDigits reports whether word has letters and digits, which identifiers
+// and words of prose rarely do at length.
+func lettersAndDigits(word string) bool {
+	return strings.ContainsAny(word, "0123456789") &&
+		strings.IndexFunc(word, func(r rune) bool { return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' }) >= 0
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var bits float64
+	n := float64(len(s))
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			bits -= p * math.Log2(p)
+		}
+	}
+	return bits
+}
+
+var current atomic.Pointer[Redactor]
+
+func init() {
+	// Built-in detection applies from the start, before the configuration is
+	// loaded
+	r, _ := New(Options{})
+	current.Store(r)
+}
+
+// Configure replaces the redaction String, Find and Strict apply.
+func Configure(opts Options) error {
+	r, err := New(opts)
+	if err != nil {
+		return err
+	}
+	current.Store(r)
+	return nil
+}
+
+// Default returns the configured Redactor.
+func Default() *Redactor {
+	return current.Load()
+}
+
+// String returns s with the secrets the configured Redactor finds redacted.
+func String(s string) string {
+	redacted, _ := Default().Redact(s)
+	return redacted
+}
+
+// Find returns the secrets the configured Redactor finds in s.
+func Find(s string, secrets ...string) []Finding {
+	return Default().Find(s, secrets...)
+}
+
+// Strict reports whether the configuration withholds tool results with
+// credentials.
+func Strict() bool {
+	return Default().Strict()
+}
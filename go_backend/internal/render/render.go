@@ -0,0 +1,205 @@
+// Package render converts mermaid, graphviz and LaTeX blocks in assistant
+// messages into SVG or PNG images with the usual command-line tools. Images
+// are cached by content, so a block is only rendered once.
+package render
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	FormatSVG = "svg"
+	FormatPNG = "png"
+
+	// URLPrefix is where the HTTP server serves rendered images
+	URLPrefix = "/render/"
+
+	renderTimeout = 30 * time.Second
+)
+
+var (
+	// fencedBlock matches ```lang ... ``` blocks
+	fencedBlock = regexp.MustCompile("(?ms)^[ \t]*```[ \t]*([A-Za-z]+)[ \t]*\n(.*?)\n[ \t]*```[ \t]*$")
+	validName   = regexp.MustCompile(`^[0-9a-f]{64}\.(svg|png)$`)
+)
+
+// languages maps fence languages to the renderer that handles them
+var languages = map[string]string{
+	"mermaid":  "mermaid",
+	"dot":      "graphviz",
+	"graphviz": "graphviz",
+	"latex":    "latex",
+	"tex":      "latex",
+	"math":     "math",
+}
+
+// Block is a renderable fenced block. Index counts blocks in the message.
+type Block struct {
+	Index    int
+	Language string
+	Source   string
+}
+
+// Blocks returns the renderable blocks in text, in order.
+func Blocks(text string) []Block {
+	var blocks []Block
+	for _, match := range fencedBlock.FindAllStringSubmatch(text, -1) {
+		language := strings.ToLower(match[1])
+		if _, ok := languages[language]; !ok {
+			continue
+		}
+		blocks = append(blocks, Block{Index: len(blocks), Language: language, Source: match[2]})
+	}
+	return blocks
+}
+
+// Renderer renders blocks into a cache directory.
+type Renderer struct {
+	dir string
+}
+
+func NewRenderer(dir string) *Renderer {
+	return &Renderer{dir: dir}
+}
+
+// Render returns the cached image name for block in format, rendering it
+// first if needed.
+func (r *Renderer) Render(ctx context.Context, block Block, format string) (string, error) {
+	if format != FormatSVG && format != FormatPNG {
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+	kind, ok := languages[block.Language]
+	if !ok {
+		return "", fmt.Errorf("unsupported block language %q", block.Language)
+	}
+
+	sum := sha256.Sum256([]byte(kind + "\x00" + format + "\x00" + block.Source))
+	name := hex.EncodeToString(sum[:]) + "." + format
+	path := filepath.Join(r.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return name, nil
+	}
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create render cache: %w", err)
+	}
+	work, err := os.MkdirTemp(r.dir, ".render-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(work)
+
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	out := filepath.Join(work, "out."+format)
+	switch kind {
+	case "mermaid":
+		err = renderMermaid(ctx, work, block.Source, out)
+	case "graphviz":
+		err = renderGraphviz(ctx, block.Source, format, out)
+	case "latex":
+		err = renderLatex(ctx, work, block.Source, format, out)
+	case "math":
+		err = renderLatex(ctx, work, `\[`+block.Source+`\]`, format, out)
+	}
+	if err != nil {
+		return "", err
+	}
+	// Rename so concurrent renders of the same block never serve a partial file
+	if err := os.Rename(out, path); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// URL is the server path of a rendered image.
+func URL(name string) string {
+	return URLPrefix + name
+}
+
+// ServeHTTP serves cached images by name.
+func (r *Renderer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	name := strings.TrimPrefix(req.URL.Path, URLPrefix)
+	if !validName.MatchString(name) {
+		http.NotFound(w, req)
+		return
+	}
+	if strings.HasSuffix(name, ".svg") {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	}
+	// Names are content hashes, so images never change
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, req, filepath.Join(r.dir, name))
+}
+
+// run runs cmd, including the tail of its output in the error when it fails.
+// latex reports errors on stdout.
+func run(cmd *exec.Cmd) error {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(output.String())
+		if len(detail) > 500 {
+			detail = detail[len(detail)-500:]
+		}
+		if detail == "" {
+			return fmt.Errorf("%s failed: %w", filepath.Base(cmd.Path), err)
+		}
+		return fmt.Errorf("%s failed: %w: %s", filepath.Base(cmd.Path), err, detail)
+	}
+	return nil
+}
+
+func renderMermaid(ctx context.Context, work, source, out string) error {
+	in := filepath.Join(work, "diagram.mmd")
+	if err := os.WriteFile(in, []byte(source), 0o644); err != nil {
+		return err
+	}
+	return run(exec.CommandContext(ctx, "mmdc", "--quiet", "-i", in, "-o", out, "-b", "transparent"))
+}
+
+func renderGraphviz(ctx context.Context, source, format, out string) error {
+	cmd := exec.CommandContext(ctx, "dot", "-T"+format, "-o", out)
+	cmd.Stdin = strings.NewReader(source)
+	return run(cmd)
+}
+
+// latexDocument wraps a fragment in a standalone document cropped to its content
+const latexDocument = `\documentclass[preview,border=2pt]{standalone}
+\usepackage{amsmath,amssymb}
+\begin{document}
+%s
+\end{document}
+`
+
+func renderLatex(ctx context.Context, work, source, format, out string) error {
+	tex := filepath.Join(work, "diagram.tex")
+	if err := os.WriteFile(tex, []byte(fmt.Sprintf(latexDocument, source)), 0o644); err != nil {
+		return err
+	}
+	// -no-shell-escape keeps model-written LaTeX from running commands
+	latex := exec.CommandContext(ctx, "latex", "-interaction=nonstopmode", "-halt-on-error", "-no-shell-escape", "-output-directory", work, tex)
+	latex.Dir = work
+	if err := run(latex); err != nil {
+		return err
+	}
+	dvi := filepath.Join(work, "diagram.dvi")
+	if format == FormatSVG {
+		return run(exec.CommandContext(ctx, "dvisvgm", "--no-fonts", "--exact", "-o", out, dvi))
+	}
+	return run(exec.CommandContext(ctx, "dvipng", "-T", "tight", "-D", "200", "-bg", "Transparent", "-o", out, dvi))
+}
@@ -0,0 +1,295 @@
+// Package runqueue limits the agent runs in progress across all clients.
+// Runs past the limit wait in a queue per client, and the queues take turns,
+// so a client starting dozens of runs can't keep everyone else waiting behind
+// all of them.
+package runqueue
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrFull is returned when a run can't wait because the queue is full.
+var ErrFull = errors.New("too many agent runs queued, try again later")
+
+// Request identifies a run waiting for a slot.
+type Request struct {
+	// Client is who started the run, such as its IP address; runs without
+	// one share a queue
+	Client    string
+	SessionID string
+}
+
+// Status is a snapshot of the queue.
+type Status struct {
+	// Limit is the maximum runs in progress, 0 when unlimited
+	Limit int
+	// MaxQueued is the maximum runs waiting, 0 when unlimited
+	MaxQueued int
+	Running   int
+	Queued    int
+	// Clients are the clients with runs in progress or waiting
+	Clients []ClientStatus
+}
+
+type ClientStatus struct {
+	Client  string
+	Running int
+	Queued  int
+}
+
+type waiter struct {
+	Request
+	// granted is closed when the run may start
+	granted chan struct{}
+	// moved is signalled when position changes
+	moved    chan struct{}
+	position int
+}
+
+// Queue hands out run slots up to its limit. The zero value isn't usable;
+// create one with New.
+type Queue struct {
+	mu        sync.Mutex
+	limit     int
+	maxQueued int
+	running   map[string]int
+	total     int
+	queues    map[string][]*waiter
+	// turns are the clients with waiting runs, the next to start a run first
+	turns []string
+}
+
+// New returns a queue allowing limit runs at once with up to maxQueued
+// waiting. Zero means no limit for either.
+func New(limit, maxQueued int) *Queue {
+	return &Queue{
+		limit:     limit,
+		maxQueued: maxQueued,
+		running:   make(map[string]int),
+		queues:    make(map[string][]*waiter),
+	}
+}
+
+// Configure changes the limits. Runs already in progress keep their slots;
+// waiting runs start if the new limit allows.
+func (q *Queue) Configure(limit, maxQueued int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limit, q.maxQueued = limit, maxQueued
+	q.dispatch()
+}
+
+// Acquire waits for a slot for req and returns the function releasing it,
+// which must be called when the run finishes. While the run waits,
+// onQueued, if not nil, is called with its 1-based position each time the
+// position changes. It returns ErrFull when the queue is full, and ctx's
+// error when ctx is done before the run may start.
+func (q *Queue) Acquire(ctx context.Context, req Request, onQueued func(position int)) (func(), error) {
+	q.mu.Lock()
+	if q.available() && len(q.turns) == 0 {
+		q.start(req.Client)
+		q.mu.Unlock()
+		return q.releaser(req.Client), nil
+	}
+	if q.maxQueued > 0 && q.queued() >= q.maxQueued {
+		q.mu.Unlock()
+		return nil, ErrFull
+	}
+	w := &waiter{Request: req, granted: make(chan struct{}), moved: make(chan struct{}, 1)}
+	if len(q.queues[req.Client]) == 0 {
+		q.turns = append(q.turns, req.Client)
+	}
+	q.queues[req.Client] = append(q.queues[req.Client], w)
+	q.reposition()
+	q.mu.Unlock()
+
+	reported := 0
+	for {
+		select {
+		case <-w.granted:
+			return q.releaser(req.Client), nil
+
+		case <-w.moved:
+			q.mu.Lock()
+			position := w.position
+			q.mu.Unlock()
+			if onQueued != nil && position > 0 && position != reported {
+				onQueued(position)
+				reported = position
+			}
+
+		case <-ctx.Done():
+			q.mu.Lock()
+			select {
+			case <-w.granted:
+				// Started just as ctx ended; give the slot to the next run
+				q.mu.Unlock()
+				q.releaser(req.Client)()
+			default:
+				q.remove(w)
+				q.reposition()
+				q.mu.Unlock()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Status returns the queue's limits and what is running and waiting.
+func (q *Queue) Status() Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	status := Status{Limit: q.limit, MaxQueued: q.maxQueued, Running: q.total, Queued: q.queued()}
+	clients := make(map[string]*ClientStatus)
+	client := func(name string) *ClientStatus {
+		if clients[name] == nil {
+			clients[name] = &ClientStatus{Client: name}
+		}
+		return clients[name]
+	}
+	for name, running := range q.running {
+		client(name).Running = running
+	}
+	for name, waiting := range q.queues {
+		client(name).Queued = len(waiting)
+	}
+	for _, c := range clients {
+		status.Clients = append(status.Clients, *c)
+	}
+	sort.Slice(status.Clients, func(i, j int) bool { return status.Clients[i].Client < status.Clients[j].Client })
+	return status
+}
+
+// Position returns the 1-based position of the first run waiting for
+// sessionID, or 0 when none is.
+func (q *Queue) Position(sessionID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	position := 0
+	for _, waiting := range q.queues {
+		for _, w := range waiting {
+			if w.SessionID == sessionID && (position == 0 || w.position < position) {
+				position = w.position
+			}
+		}
+	}
+	return position
+}
+
+func (q *Queue) available() bool {
+	return q.limit <= 0 || q.total < q.limit
+}
+
+func (q *Queue) queued() int {
+	n := 0
+	for _, waiting := range q.queues {
+		n += len(waiting)
+	}
+	return n
+}
+
+func (q *Queue) start(client string) {
+	q.running[client]++
+	q.total++
+}
+
+func (q *Queue) releaser(client string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			if q.running[client]--; q.running[client] <= 0 {
+				delete(q.running, client)
+			}
+			q.total--
+			q.dispatch()
+		})
+	}
+}
+
+// dispatch starts waiting runs while slots are free, one client's oldest run
+// at a time, in turn.
+func (q *Queue) dispatch() {
+	started := false
+	for q.available() && len(q.turns) > 0 {
+		client := q.turns[0]
+		q.turns = q.turns[1:]
+		w := q.queues[client][0]
+		if q.queues[client] = q.queues[client][1:]; len(q.queues[client]) > 0 {
+			q.turns = append(q.turns, client)
+		} else {
+			delete(q.queues, client)
+		}
+		q.start(client)
+		close(w.granted)
+		started = true
+	}
+	if started {
+		q.reposition()
+	}
+}
+
+func (q *Queue) remove(w *waiter) {
+	waiting := q.queues[w.Client]
+	for i := range waiting {
+		if waiting[i] == w {
+			waiting = append(waiting[:i:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(waiting) > 0 {
+		q.queues[w.Client] = waiting
+		return
+	}
+	delete(q.queues, w.Client)
+	for i, client := range q.turns {
+		if client == w.Client {
+			q.turns = append(q.turns[:i:i], q.turns[i+1:]...)
+			break
+		}
+	}
+}
+
+// reposition numbers the waiting runs in the order they will start: the
+// oldest run of each client in turn, then the second oldest, and so on.
+func (q *Queue) reposition() {
+	position := 1
+	for round := 0; ; round++ {
+		found := false
+		for _, client := range q.turns {
+			waiting := q.queues[client]
+			if round >= len(waiting) {
+				continue
+			}
+			found = true
+			if w := waiting[round]; w.position != position {
+				w.position = position
+				select {
+				case w.moved <- struct{}{}:
+				default:
+				}
+			}
+			position++
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+type clientKey struct{}
+
+// WithClient returns ctx carrying the client starting runs with it.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// Client returns the client ctx carries, or "" when none.
+func Client(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
@@ -0,0 +1,194 @@
+package runqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acquired is a run that went into Acquire
+type acquired struct {
+	release func()
+	err     error
+}
+
+// enqueue starts Acquire for req in the background and waits until the run
+// is running or waiting.
+func enqueue(t *testing.T, q *Queue, ctx context.Context, req Request) <-chan acquired {
+	t.Helper()
+	before := q.Status()
+	done := make(chan acquired, 1)
+	go func() {
+		release, err := q.Acquire(ctx, req, nil)
+		done <- acquired{release, err}
+	}()
+	require.Eventually(t, func() bool {
+		status := q.Status()
+		return status.Running+status.Queued > before.Running+before.Queued
+	}, time.Second, time.Millisecond)
+	return done
+}
+
+func TestAcquireOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		// waiting are the clients of the runs queued behind a running one,
+		// in the order they are queued
+		waiting []string
+		// expected are the indexes of waiting in the order they start
+		expected []int
+	}{
+		{
+			name:     "one client runs in order",
+			waiting:  []string{"a", "a", "a"},
+			expected: []int{0, 1, 2},
+		},
+		{
+			name:     "clients take turns",
+			waiting:  []string{"a", "a", "a", "b", "c"},
+			expected: []int{0, 3, 4, 1, 2},
+		},
+		{
+			name:     "runs without a client share a queue",
+			waiting:  []string{"", "", "b"},
+			expected: []int{0, 2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New(1, 0)
+			release, err := q.Acquire(context.Background(), Request{Client: "first"}, nil)
+			require.NoError(t, err)
+
+			runs := make([]<-chan acquired, len(tt.waiting))
+			for i, client := range tt.waiting {
+				runs[i] = enqueue(t, q, context.Background(), Request{Client: client})
+			}
+			assert.Equal(t, len(tt.waiting), q.Status().Queued)
+
+			for _, index := range tt.expected {
+				release()
+				select {
+				case run := <-runs[index]:
+					require.NoError(t, run.err)
+					release = run.release
+				case <-time.After(time.Second):
+					t.Fatalf("run %d didn't start", index)
+				}
+			}
+			release()
+			assert.Equal(t, Status{Limit: 1}, q.Status())
+		})
+	}
+}
+
+func TestAcquireLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		maxQueued int
+		runs      int
+		expected  Status
+		full      bool
+	}{
+		{
+			name:     "unlimited",
+			runs:     3,
+			expected: Status{Running: 3, Clients: []ClientStatus{{Client: "a", Running: 3}}},
+		},
+		{
+			name:     "runs past the limit wait",
+			limit:    2,
+			runs:     3,
+			expected: Status{Limit: 2, Running: 2, Queued: 1, Clients: []ClientStatus{{Client: "a", Running: 2, Queued: 1}}},
+		},
+		{
+			name:      "a full queue rejects runs",
+			limit:     1,
+			maxQueued: 1,
+			runs:      2,
+			expected:  Status{Limit: 1, MaxQueued: 1, Running: 1, Queued: 1, Clients: []ClientStatus{{Client: "a", Running: 1, Queued: 1}}},
+			full:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New(tt.limit, tt.maxQueued)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			for range tt.runs {
+				enqueue(t, q, ctx, Request{Client: "a"})
+			}
+			assert.Equal(t, tt.expected, q.Status())
+
+			if tt.full {
+				_, err := q.Acquire(ctx, Request{Client: "a"}, nil)
+				assert.ErrorIs(t, err, ErrFull)
+			}
+		})
+	}
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	q := New(1, 0)
+	release, err := q.Acquire(context.Background(), Request{Client: "a"}, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := enqueue(t, q, ctx, Request{Client: "b", SessionID: "s1"})
+	waiting := enqueue(t, q, context.Background(), Request{Client: "c", SessionID: "s2"})
+	assert.Equal(t, 1, q.Position("s1"))
+	assert.Equal(t, 2, q.Position("s2"))
+
+	cancel()
+	run := <-canceled
+	assert.ErrorIs(t, run.err, context.Canceled)
+	assert.Equal(t, 0, q.Position("s1"))
+	assert.Equal(t, 1, q.Position("s2"))
+
+	release()
+	run = <-waiting
+	require.NoError(t, run.err)
+	run.release()
+	run.release()
+	assert.Equal(t, Status{Limit: 1}, q.Status())
+}
+
+func TestAcquireReportsPosition(t *testing.T) {
+	q := New(1, 0)
+	release, err := q.Acquire(context.Background(), Request{Client: "a"}, nil)
+	require.NoError(t, err)
+	enqueue(t, q, context.Background(), Request{Client: "a"})
+
+	positions := make(chan int, 4)
+	done := make(chan acquired, 1)
+	go func() {
+		release, err := q.Acquire(context.Background(), Request{Client: "b"}, func(position int) { positions <- position })
+		done <- acquired{release, err}
+	}()
+	assert.Equal(t, 2, <-positions)
+
+	release()
+	assert.Equal(t, 1, <-positions)
+}
+
+func TestConfigureStartsWaitingRuns(t *testing.T) {
+	q := New(1, 0)
+	_, err := q.Acquire(context.Background(), Request{Client: "a"}, nil)
+	require.NoError(t, err)
+	waiting := enqueue(t, q, context.Background(), Request{Client: "b"})
+
+	q.Configure(2, 0)
+	select {
+	case run := <-waiting:
+		require.NoError(t, run.err)
+	case <-time.After(time.Second):
+		t.Fatal("the waiting run didn't start")
+	}
+	assert.Equal(t, 2, q.Status().Running)
+}
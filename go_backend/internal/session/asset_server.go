@@ -1,13 +1,16 @@
 package session
 
 import (
-	"crypto/md5"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
 	_ "image/png"
 	_ "image/gif"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,6 +18,9 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"mix/internal/captions"
 
 	"github.com/nfnt/resize"
 	_ "golang.org/x/image/webp"
@@ -109,18 +115,41 @@ func getAllowedMimeTypes() map[string]int64 {
 	return result
 }
 
-// AssetServer serves files from a current working directory
+// RemoteAssets locates stored copies of files that are gone from local disk
+type RemoteAssets interface {
+	SignedURL(ctx context.Context, localPath string) (string, error)
+}
+
+// AssetURLPrefix is the path ServeSession serves each session's files under
+const AssetURLPrefix = "/assets/"
+
+// AssetServer serves the files of sessions' working directories: each
+// session's under AssetURLPrefix, and the current session's at the root.
 type AssetServer struct {
 	mu             sync.RWMutex
 	currentWorkDir string
+	remote         RemoteAssets
+	sessions       Service
+
+	// jobsCtx is cancelled on shutdown to stop ffmpeg jobs in flight
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	jobs       sync.WaitGroup
+
+	// thumbnailDirs are the .thumbnails caches written since startup, which
+	// the cleanup job prunes
+	thumbnailDirs map[string]struct{}
 }
 
 // Thumbnail specification types
 type ThumbnailSpec struct {
-	Type   string // "box", "width", "height"
+	Type   string // "box", "width", "height", "sprite", "preview"
 	Size   int    // the dimension value
 	Width  int    // calculated width (0 means auto)
 	Height int    // calculated height (0 means auto)
+	// Columns and Rows are the grid of frames in a sprite sheet
+	Columns int
+	Rows    int
 }
 
 // Thumbnail parameter validation
@@ -128,16 +157,55 @@ var (
 	boxSizeRegex    = regexp.MustCompile(`^(\d+)$`)         // "100"
 	widthSizeRegex  = regexp.MustCompile(`^w(\d+)$`)        // "w100"
 	heightSizeRegex = regexp.MustCompile(`^h(\d+)$`)        // "h100"
+	spriteRegex     = regexp.MustCompile(`^sprite:(\d+)x(\d+)$`) // "sprite:5x5"
+	previewRegex    = regexp.MustCompile(`^preview$`)             // "preview"
 )
 
 const (
 	MaxThumbnailSize = 1024 // Max width or height for thumbnails
 	MinThumbnailSize = 16   // Min width or height for thumbnails
+
+	MaxSpriteGrid    = 10  // Max columns or rows of a sprite sheet
+	SpriteFrameWidth = 160 // Width of each frame of a sprite sheet
+
+	PreviewWidth   = 320 // Width of animated video previews
+	PreviewSeconds = 3   // Length in seconds of animated video previews
+	PreviewFPS     = 10  // Frame rate of animated video previews
 )
 
-// NewAssetServer creates a new asset server
-func NewAssetServer() *AssetServer {
-	return &AssetServer{}
+// NewAssetServer creates an asset server resolving session IDs with sessions
+func NewAssetServer(sessions Service) *AssetServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	as := &AssetServer{
+		sessions:      sessions,
+		jobsCtx:       ctx,
+		cancelJobs:    cancel,
+		thumbnailDirs: map[string]struct{}{},
+	}
+	as.jobs.Add(1)
+	go func() {
+		defer as.jobs.Done()
+		as.pruneThumbnailsLoop(ctx)
+	}()
+	return as
+}
+
+// Shutdown kills running thumbnail jobs, stops the cache cleanup, and waits for them to exit, or until
+// ctx is done.
+func (as *AssetServer) Shutdown(ctx context.Context) error {
+	as.cancelJobs()
+
+	done := make(chan struct{})
+	go func() {
+		as.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // SetWorkingDirectory sets the current working directory to serve assets from
@@ -154,6 +222,13 @@ func (as *AssetServer) SetWorkingDirectory(workingDir string) error {
 	return nil
 }
 
+// SetRemote makes requests for missing files redirect to their stored copies
+func (as *AssetServer) SetRemote(remote RemoteAssets) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.remote = remote
+}
+
 // detectContentType reads file header to determine content type
 func (as *AssetServer) detectContentType(filePath string) (string, error) {
 	file, err := os.Open(filePath)
@@ -219,6 +294,13 @@ func (as *AssetServer) validateMediaFileWithContentType(filePath string, fileInf
 	return nil
 }
 
+// AssetURL returns the URL path ServeSession serves relPath, a path relative
+// to the session's working directory, at.
+func AssetURL(sessionID, relPath string) string {
+	u := url.URL{Path: AssetURLPrefix + sessionID + "/" + filepath.ToSlash(relPath)}
+	return u.EscapedPath()
+}
+
 // ServeHTTP handles asset serving requests from the current working directory
 func (as *AssetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	as.mu.RLock()
@@ -231,13 +313,49 @@ func (as *AssetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// URL format: /input/videos/file.mp4
-	filePath := strings.TrimPrefix(r.URL.Path, "/")
-	
-	// Construct full file path
-	fullPath := filepath.Join(workingDir, filePath)
-	
-	// Security check: ensure path is within working directory
-	if !strings.HasPrefix(fullPath, workingDir) {
+	as.serveFile(w, r, workingDir, strings.TrimPrefix(r.URL.Path, "/"))
+}
+
+// ServeSession handles /assets/{sessionID}/{path} requests from the working
+// directory of the session, so clients of different sessions each see their
+// own files whichever session is current.
+func (as *AssetServer) ServeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, filePath, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, AssetURLPrefix), "/")
+	if !ok || sessionID == "" || filePath == "" || as.sessions == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	sess, err := as.sessions.Get(r.Context(), sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Session lookup failed", http.StatusInternalServerError)
+		return
+	}
+	if sess.WorkingDirectory == "" {
+		http.NotFound(w, r)
+		return
+	}
+	workingDir, err := filepath.Abs(sess.WorkingDirectory)
+	if err != nil {
+		http.Error(w, "File access error", http.StatusInternalServerError)
+		return
+	}
+
+	as.serveFile(w, r, workingDir, filePath)
+}
+
+// serveFile serves filePath, relative to workingDir, or a thumbnail of it
+func (as *AssetServer) serveFile(w http.ResponseWriter, r *http.Request, workingDir, filePath string) {
+	as.mu.RLock()
+	remote := as.remote
+	as.mu.RUnlock()
+
+	fullPath, ok := resolveAssetPath(workingDir, filePath)
+	if !ok {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -246,7 +364,7 @@ func (as *AssetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fileInfo, err := os.Stat(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			http.NotFound(w, r)
+			as.serveRemote(w, r, remote, fullPath)
 			return
 		}
 		http.Error(w, "File access error", http.StatusInternalServerError)
@@ -293,7 +411,7 @@ func (as *AssetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Parse optional time parameter for video segments
 		timeParam := r.URL.Query().Get("time")
 		
-		if err := as.serveThumbnail(w, r, fullPath, thumbParam, timeParam); err != nil {
+		if err := as.serveThumbnail(w, r, workingDir, fullPath, fileInfo, thumbParam, timeParam); err != nil {
 			http.Error(w, fmt.Sprintf("Thumbnail generation failed: %v", err), http.StatusInternalServerError)
 			return
 		}
@@ -304,6 +422,46 @@ func (as *AssetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, fullPath)
 }
 
+// resolveAssetPath returns the path of filePath in workingDir, failing when
+// it, or a symlink on the way, leads outside the directory. Missing files
+// resolve, as their stored copies may still be served.
+func resolveAssetPath(workingDir, filePath string) (string, bool) {
+	fullPath := filepath.Join(workingDir, filepath.FromSlash(filePath))
+	if !withinDir(workingDir, fullPath) {
+		return "", false
+	}
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return fullPath, os.IsNotExist(err)
+	}
+	root, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		return "", false
+	}
+	return fullPath, withinDir(root, resolved)
+}
+
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// serveRemote redirects to a signed URL for the stored copy of a file missing
+// from local disk, so the client streams it straight from storage.
+func (as *AssetServer) serveRemote(w http.ResponseWriter, r *http.Request, remote RemoteAssets, fullPath string) {
+	if remote == nil || r.URL.Query().Get("thumb") != "" {
+		http.NotFound(w, r)
+		return
+	}
+	url, err := remote.SignedURL(r.Context(), fullPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+}
+
 // parseThumbnailSpec parses and validates thumbnail specification
 func (as *AssetServer) parseThumbnailSpec(thumbParam string) (*ThumbnailSpec, error) {
 	// Try box format: "100" (fit within 100x100)
@@ -341,16 +499,40 @@ func (as *AssetServer) parseThumbnailSpec(thumbParam string) (*ThumbnailSpec, er
 		}
 		return &ThumbnailSpec{Type: "height", Size: size, Width: 0, Height: size}, nil
 	}
+
+	// Try sprite format: "sprite:5x5" (5 columns by 5 rows of video frames)
+	if matches := spriteRegex.FindStringSubmatch(thumbParam); len(matches) == 3 {
+		columns, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid sprite columns: %v", err)
+		}
+		rows, err := strconv.Atoi(matches[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid sprite rows: %v", err)
+		}
+		if columns < 1 || columns > MaxSpriteGrid || rows < 1 || rows > MaxSpriteGrid {
+			return nil, fmt.Errorf("sprite columns and rows must be between 1 and %d", MaxSpriteGrid)
+		}
+		return &ThumbnailSpec{Type: "sprite", Size: SpriteFrameWidth, Width: SpriteFrameWidth, Columns: columns, Rows: rows}, nil
+	}
+
+	// Try preview format: "preview" (short animated WebP of a video)
+	if previewRegex.MatchString(thumbParam) {
+		return &ThumbnailSpec{Type: "preview", Size: PreviewWidth, Width: PreviewWidth}, nil
+	}
 	
-	return nil, fmt.Errorf("invalid thumbnail format, use: 100 (box), w100 (width), or h100 (height)")
+	return nil, fmt.Errorf("invalid thumbnail format, use: 100 (box), w100 (width), h100 (height), sprite:5x5 (video sprite sheet), or preview (animated video preview)")
 }
 
-// generateThumbnailPath creates a consistent cache path for thumbnails
-func (as *AssetServer) generateThumbnailPath(workingDir, originalPath string, spec *ThumbnailSpec, timeOffset float64) string {
+// generateThumbnailPath creates a consistent cache path for thumbnails. Each
+// working directory keeps its own cache, so sessions never share thumbnails.
+// Names start with the hash of the original path followed by its version, its
+// modification time, so a changed file gets new thumbnails.
+func (as *AssetServer) generateThumbnailPath(workingDir, originalPath string, modTime time.Time, spec *ThumbnailSpec, timeOffset float64) string {
 	thumbnailDir := filepath.Join(workingDir, ".thumbnails")
 	
-	// Create hash of original path for consistent naming
-	hash := fmt.Sprintf("%x", md5.Sum([]byte(originalPath)))
+	// Create hash of original path and version for consistent naming
+	hash := thumbnailVersion(originalPath, modTime)
 	
 	// Generate filename based on thumbnail type and time offset
 	var filename string
@@ -367,6 +549,10 @@ func (as *AssetServer) generateThumbnailPath(workingDir, originalPath string, sp
 		filename = fmt.Sprintf("%s_w%d%s.jpg", hash, spec.Size, timeSuffix)
 	case "height":
 		filename = fmt.Sprintf("%s_h%d%s.jpg", hash, spec.Size, timeSuffix)
+	case "sprite":
+		filename = fmt.Sprintf("%s_sprite%dx%d.jpg", hash, spec.Columns, spec.Rows)
+	case "preview":
+		filename = fmt.Sprintf("%s_preview%s.webp", hash, timeSuffix)
 	default:
 		filename = fmt.Sprintf("%s_unknown%s.jpg", hash, timeSuffix)
 	}
@@ -375,36 +561,53 @@ func (as *AssetServer) generateThumbnailPath(workingDir, originalPath string, sp
 }
 
 // serveThumbnail handles thumbnail generation and serving for both videos and images
-func (as *AssetServer) serveThumbnail(w http.ResponseWriter, r *http.Request, mediaPath, thumbParam, timeParam string) error {
+func (as *AssetServer) serveThumbnail(w http.ResponseWriter, r *http.Request, workingDir, mediaPath string, mediaInfo os.FileInfo, thumbParam, timeParam string) error {
 	// Parse thumbnail specification
 	spec, err := as.parseThumbnailSpec(thumbParam)
 	if err != nil {
 		return err
 	}
+	animated := spec.Type == "sprite" || spec.Type == "preview"
+	if animated && !as.isVideoFile(mediaPath) {
+		return fmt.Errorf("%s thumbnails are only supported for video files", spec.Type)
+	}
 	
-	// Parse and validate time offset for video segments (default to 1 second)
+	// Parse and validate time offset for video segments (default to 1 second,
+	// or the start of the video for previews)
 	timeOffset := 1.0
+	if spec.Type == "preview" {
+		timeOffset = 0
+	}
 	if timeParam != "" {
 		if parsedTime, err := strconv.ParseFloat(timeParam, 64); err == nil {
 			// Clamp time to reasonable bounds: 0 to 24 hours
 			if parsedTime >= 0 && parsedTime <= 86400 {
 				timeOffset = parsedTime
 			}
-			// Invalid time values fall back to default 1 second
+			// Invalid time values fall back to the default
 		}
 	}
+	if spec.Type == "sprite" {
+		// Sprite frames are spread over the whole video
+		timeOffset = 0
+	}
 	
-	as.mu.RLock()
-	workingDir := as.currentWorkDir
-	as.mu.RUnlock()
+	contentType := "image/jpeg"
+	if spec.Type == "preview" {
+		contentType = "image/webp"
+	}
 	
 	// Generate thumbnail path with time offset
-	thumbnailPath := as.generateThumbnailPath(workingDir, mediaPath, spec, timeOffset)
+	thumbnailPath := as.generateThumbnailPath(workingDir, mediaPath, mediaInfo.ModTime(), spec, timeOffset)
 	
 	// Check if thumbnail already exists
 	if _, err := os.Stat(thumbnailPath); err == nil {
+		// Mark it as used, so the cleanup job prunes it last
+		now := time.Now()
+		_ = os.Chtimes(thumbnailPath, now, now)
+		
 		// Serve existing thumbnail
-		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Type", contentType)
 		http.ServeFile(w, r, thumbnailPath)
 		return nil
 	}
@@ -414,22 +617,39 @@ func (as *AssetServer) serveThumbnail(w http.ResponseWriter, r *http.Request, me
 	if err := os.MkdirAll(thumbnailDir, 0755); err != nil {
 		return fmt.Errorf("failed to create thumbnail directory: %v", err)
 	}
+	as.trackThumbnailDir(thumbnailDir)
+	
+	// Thumbnails of earlier versions of the file are never served again
+	removeStaleThumbnails(thumbnailDir, mediaPath, mediaInfo.ModTime())
+	
+	// Generate into a temporary file renamed into place once complete, so
+	// concurrent requests never serve a partly written thumbnail. The
+	// extension is kept for FFmpeg to pick the output format.
+	tempPath := filepath.Join(thumbnailDir, fmt.Sprintf(".%d-%s", time.Now().UnixNano(), filepath.Base(thumbnailPath)))
+	defer os.Remove(tempPath)
 	
 	// Generate thumbnail using FFmpeg based on file type
-	if as.isVideoFile(mediaPath) {
-		if err := as.generateVideoThumbnail(mediaPath, thumbnailPath, spec, timeOffset); err != nil {
-			return err
-		}
-	} else if as.isImageFile(mediaPath) {
-		if err := as.generateImageThumbnail(mediaPath, thumbnailPath, spec); err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("unsupported file type for thumbnail generation")
+	switch {
+	case spec.Type == "sprite":
+		err = as.generateVideoSprite(mediaPath, tempPath, spec)
+	case spec.Type == "preview":
+		err = as.generateVideoPreview(mediaPath, tempPath, spec, timeOffset)
+	case as.isVideoFile(mediaPath):
+		err = as.generateVideoThumbnail(mediaPath, tempPath, spec, timeOffset)
+	case as.isImageFile(mediaPath):
+		err = as.generateImageThumbnail(mediaPath, tempPath, spec)
+	default:
+		err = fmt.Errorf("unsupported file type for thumbnail generation")
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, thumbnailPath); err != nil {
+		return fmt.Errorf("failed to store thumbnail: %v", err)
 	}
 	
 	// Serve the generated thumbnail
-	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Type", contentType)
 	http.ServeFile(w, r, thumbnailPath)
 	return nil
 }
@@ -457,7 +677,9 @@ func (as *AssetServer) generateVideoThumbnail(videoPath, thumbnailPath string, s
 	timeStr := fmt.Sprintf("%.2f", timeOffset)
 	
 	// FFmpeg command to extract frame at specified time, scale maintaining aspect ratio, and save as JPEG
-	cmd := exec.Command("ffmpeg", 
+	as.jobs.Add(1)
+	defer as.jobs.Done()
+	cmd := exec.CommandContext(as.jobsCtx, "ffmpeg", 
 		"-i", videoPath,
 		"-ss", timeStr,
 		"-frames:v", "1",
@@ -481,6 +703,68 @@ func (as *AssetServer) generateVideoThumbnail(videoPath, thumbnailPath string, s
 	return nil
 }
 
+// generateVideoSprite uses FFmpeg to tile frames spread evenly over the video
+// into a sprite sheet of spec.Columns by spec.Rows frames
+func (as *AssetServer) generateVideoSprite(videoPath, spritePath string, spec *ThumbnailSpec) error {
+	as.jobs.Add(1)
+	defer as.jobs.Done()
+	
+	info, err := captions.Probe(as.jobsCtx, videoPath)
+	if err != nil {
+		return err
+	}
+	if info.Duration <= 0 {
+		return fmt.Errorf("video has no duration")
+	}
+	
+	// Sample one frame per cell, each scaled to the sprite frame width
+	frames := spec.Columns * spec.Rows
+	filter := fmt.Sprintf("fps=%f,scale=%d:-2,tile=%dx%d", float64(frames)/info.Duration, spec.Size, spec.Columns, spec.Rows)
+	cmd := exec.CommandContext(as.jobsCtx, "ffmpeg",
+		"-i", videoPath,
+		"-vf", filter,
+		"-frames:v", "1",
+		"-q:v", "3",
+		"-y",
+		spritePath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v, output: %s", err, string(output))
+	}
+	if _, err := os.Stat(spritePath); err != nil {
+		return fmt.Errorf("sprite file not created: %v", err)
+	}
+	return nil
+}
+
+// generateVideoPreview uses FFmpeg to encode a short looping WebP animation of
+// the video starting at timeOffset
+func (as *AssetServer) generateVideoPreview(videoPath, previewPath string, spec *ThumbnailSpec, timeOffset float64) error {
+	as.jobs.Add(1)
+	defer as.jobs.Done()
+	cmd := exec.CommandContext(as.jobsCtx, "ffmpeg",
+		"-ss", fmt.Sprintf("%.2f", timeOffset), // Seek before decoding, previews only need a few seconds
+		"-t", strconv.Itoa(PreviewSeconds),
+		"-i", videoPath,
+		"-vf", fmt.Sprintf("fps=%d,scale=%d:-2", PreviewFPS, spec.Size),
+		"-an",
+		"-c:v", "libwebp",
+		"-loop", "0",
+		"-quality", "60",
+		"-y",
+		previewPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v, output: %s", err, string(output))
+	}
+	if info, err := os.Stat(previewPath); err != nil || info.Size() == 0 {
+		return fmt.Errorf("preview file not created, the time may be past the end of the video")
+	}
+	return nil
+}
+
 // generateImageThumbnail uses Go's native image processing to resize an image
 func (as *AssetServer) generateImageThumbnail(imagePath, thumbnailPath string, spec *ThumbnailSpec) error {
 	// Open source image file
@@ -555,4 +839,21 @@ func (as *AssetServer) GetCurrentWorkingDirectory() string {
 // GetSupportedFileTypes returns the supported file types configuration
 func (as *AssetServer) GetSupportedFileTypes() SupportedFileTypes {
 	return supportedFileTypes
-}
\ No newline at end of file
+}
+// MediaCategory returns the category of a supported media file by its
+// extension.
+func MediaCategory(filePath string) (FileTypeCategory, bool) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for category, info := range map[FileTypeCategory]FileTypeInfo{
+		CategoryImage: supportedFileTypes.Image,
+		CategoryVideo: supportedFileTypes.Video,
+		CategoryAudio: supportedFileTypes.Audio,
+	} {
+		for _, supported := range info.Extensions {
+			if ext == supported {
+				return category, true
+			}
+		}
+	}
+	return "", false
+}
@@ -3,11 +3,16 @@ package session
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
+	"mix/internal/artifact"
 	"mix/internal/db"
+	"mix/internal/logging"
 	"mix/internal/pubsub"
 
 	"github.com/google/uuid"
@@ -27,6 +32,52 @@ type Session struct {
 	CreatedAt             int64
 	UpdatedAt             int64
 	WorkingDirectory      string
+	Tags                  []string
+	Pinned                bool
+	Archived              bool
+}
+
+// ListFilter narrows ListWithContent. Nil fields match every session.
+type ListFilter struct {
+	Tag      *string
+	Pinned   *bool
+	Archived *bool
+}
+
+// Organization holds the user-managed session fields changed by Organize.
+// Nil fields are left unchanged.
+type Organization struct {
+	Title    *string
+	Tags     *[]string
+	Pinned   *bool
+	Archived *bool
+}
+
+// CacheUsage is a session's running prompt cache token totals, with the
+// requests that had extended thinking and their total thinking budget.
+type CacheUsage struct {
+	Requests             int64
+	InputTokens          int64
+	CacheCreationTokens  int64
+	CacheReadTokens      int64
+	ThinkingRequests     int64
+	ThinkingBudgetTokens int64
+}
+
+// HitRate is the share of input tokens read from the cache.
+func (u CacheUsage) HitRate() float64 {
+	total := u.InputTokens + u.CacheCreationTokens + u.CacheReadTokens
+	if total == 0 {
+		return 0
+	}
+	return float64(u.CacheReadTokens) / float64(total)
+}
+
+// AgentSettings overrides the agent's model and adds to its system prompt
+// for one session. Empty fields keep the agent's configuration.
+type AgentSettings struct {
+	Model        string
+	SystemPrompt string
 }
 
 // Simplified Service interface for embedded binary
@@ -36,11 +87,32 @@ type Service interface {
 	Fork(ctx context.Context, sourceSessionID string, title string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
-	ListWithContent(ctx context.Context) ([]db.ListSessionsWithContentRow, error)
+	ListWithContent(ctx context.Context, filter ListFilter) ([]db.ListSessionsWithContentRow, error)
 	Save(ctx context.Context, session Session) (Session, error)
+	Organize(ctx context.Context, id string, org Organization) (Session, error)
+	SetWorkingDirectory(ctx context.Context, id string, workingDirectory string) (Session, error)
+	DisabledTools(ctx context.Context, id string) ([]string, error)
+	SetToolsEnabled(ctx context.Context, id string, enable, disable []string) ([]string, error)
+	Reminders(ctx context.Context, id string) ([]string, error)
+	SetReminders(ctx context.Context, id string, add, remove []string) ([]string, error)
+	OutputProfile(ctx context.Context, id string) (string, error)
+	SetOutputProfile(ctx context.Context, id string, profile string) error
+	Locale(ctx context.Context, id string) (string, error)
+	SetLocale(ctx context.Context, id string, locale string) error
+	SandboxProfile(ctx context.Context, id string) (string, error)
+	SetSandboxProfile(ctx context.Context, id string, profile string) error
+	AgentSettings(ctx context.Context, id string) (AgentSettings, error)
+	SetAgentSettings(ctx context.Context, id string, settings AgentSettings) error
+	Env(ctx context.Context, id string) (map[string]string, error)
+	SetEnv(ctx context.Context, id string, set map[string]string, unset []string) (map[string]string, error)
+	AddCost(ctx context.Context, id string, cost float64) error
+	AddCacheUsage(ctx context.Context, id string, usage CacheUsage) error
+	CacheUsage(ctx context.Context, id string) (CacheUsage, error)
 	Delete(ctx context.Context, id string) error
 }
 
+var validEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type service struct {
 	*pubsub.Broker[Session]
 	q db.Querier
@@ -60,36 +132,47 @@ func (s *service) Create(ctx context.Context, title string, workingDirectory str
 		return Session{}, err
 	}
 
-	// Create input directory structure in session's working directory
-	inputDir := filepath.Join(workingDirectory, "input")
-	if err := os.MkdirAll(inputDir, 0o755); err != nil {
-		return Session{}, fmt.Errorf("failed to create input directory: %w", err)
+	if err := prepareWorkingDirectory(workingDirectory); err != nil {
+		return Session{}, err
 	}
 
-	inputSubdirs := []string{"images", "videos", "audios", "text"}
-	for _, subdir := range inputSubdirs {
-		subdirPath := filepath.Join(inputDir, subdir)
-		if err := os.MkdirAll(subdirPath, 0o755); err != nil {
-			return Session{}, fmt.Errorf("failed to create input subdirectory %s: %w", subdir, err)
-		}
+	err = s.Publish(ctx, pubsub.CreatedEvent, session)
+	if err != nil {
+		return Session{}, err
 	}
+	return session, nil
+}
 
-	// Create output directory for generated videos
-	outputDir := filepath.Join(workingDirectory, "output")
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return Session{}, fmt.Errorf("failed to create output directory: %w", err)
+// SetWorkingDirectory repoints a session at workingDirectory, which must be an
+// existing directory.
+func (s *service) SetWorkingDirectory(ctx context.Context, id string, workingDirectory string) (Session, error) {
+	absDir, err := filepath.Abs(workingDirectory)
+	if err != nil {
+		return Session{}, fmt.Errorf("invalid working directory: %w", err)
+	}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return Session{}, fmt.Errorf("invalid working directory: %w", err)
+	}
+	if !info.IsDir() {
+		return Session{}, fmt.Errorf("working directory %s is not a directory", absDir)
 	}
 
-	// Create MIX.md file if it doesn't exist
-	mixFilePath := filepath.Join(workingDirectory, "MIX.md")
-	if _, err := os.Stat(mixFilePath); os.IsNotExist(err) {
-		mixContent := "Sample MIX.md"
-		if err := os.WriteFile(mixFilePath, []byte(mixContent), 0o644); err != nil {
-			return Session{}, fmt.Errorf("failed to create MIX.md file: %w", err)
-		}
+	if err := s.q.UpdateSessionWorkingDirectory(ctx, db.UpdateSessionWorkingDirectoryParams{
+		WorkingDirectory: sql.NullString{String: absDir, Valid: true},
+		ID:               id,
+	}); err != nil {
+		return Session{}, err
+	}
+	if err := prepareWorkingDirectory(absDir); err != nil {
+		return Session{}, err
 	}
 
-	err = s.Publish(ctx, pubsub.CreatedEvent, session)
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	err = s.Publish(ctx, pubsub.UpdatedEvent, session)
 	if err != nil {
 		return Session{}, err
 	}
@@ -115,6 +198,49 @@ func (s *service) Fork(ctx context.Context, sourceSessionID string, title string
 	if err != nil {
 		return Session{}, err
 	}
+	// A fork of a restricted session stays restricted
+	if err := s.q.CopySessionDisabledTools(ctx, db.CopySessionDisabledToolsParams{
+		TargetSessionID: session.ID,
+		SourceSessionID: sourceSessionID,
+	}); err != nil {
+		return Session{}, err
+	}
+	if err := s.q.CopySessionReminders(ctx, db.CopySessionRemindersParams{
+		TargetSessionID: session.ID,
+		SourceSessionID: sourceSessionID,
+	}); err != nil {
+		return Session{}, err
+	}
+	if err := s.q.CopySessionOutputProfile(ctx, db.CopySessionOutputProfileParams{
+		TargetSessionID: session.ID,
+		SourceSessionID: sourceSessionID,
+	}); err != nil {
+		return Session{}, err
+	}
+	if err := s.q.CopySessionEnv(ctx, db.CopySessionEnvParams{
+		TargetSessionID: session.ID,
+		SourceSessionID: sourceSessionID,
+	}); err != nil {
+		return Session{}, err
+	}
+	if err := s.q.CopySessionLocale(ctx, db.CopySessionLocaleParams{
+		TargetSessionID: session.ID,
+		SourceSessionID: sourceSessionID,
+	}); err != nil {
+		return Session{}, err
+	}
+	if err := s.q.CopySessionSandboxProfile(ctx, db.CopySessionSandboxProfileParams{
+		TargetSessionID: session.ID,
+		SourceSessionID: sourceSessionID,
+	}); err != nil {
+		return Session{}, err
+	}
+	if err := s.q.CopySessionAgentSettings(ctx, db.CopySessionAgentSettingsParams{
+		TargetSessionID: session.ID,
+		SourceSessionID: sourceSessionID,
+	}); err != nil {
+		return Session{}, err
+	}
 
 	err = s.Publish(ctx, pubsub.CreatedEvent, session)
 	if err != nil {
@@ -135,6 +261,9 @@ func (s *service) Delete(ctx context.Context, id string) error {
 	if err != nil {
 		return err
 	}
+	if err := artifact.DeleteSession(session.ID); err != nil {
+		logging.Warn("Failed to delete session artifacts", "sessionID", session.ID, "error", err)
+	}
 	err = s.Publish(ctx, pubsub.DeletedEvent, session)
 	if err != nil {
 		return err
@@ -166,8 +295,302 @@ func (s *service) List(ctx context.Context) ([]Session, error) {
 	return sessions, nil
 }
 
-func (s *service) ListWithContent(ctx context.Context) ([]db.ListSessionsWithContentRow, error) {
-	return s.q.ListSessionsWithContent(ctx)
+func (s *service) ListWithContent(ctx context.Context, filter ListFilter) ([]db.ListSessionsWithContentRow, error) {
+	params := db.ListSessionsWithContentParams{}
+	if filter.Tag != nil {
+		params.Tag = sql.NullString{String: *filter.Tag, Valid: true}
+	}
+	if filter.Pinned != nil {
+		params.Pinned = sql.NullBool{Bool: *filter.Pinned, Valid: true}
+	}
+	if filter.Archived != nil {
+		params.Archived = sql.NullBool{Bool: *filter.Archived, Valid: true}
+	}
+	return s.q.ListSessionsWithContent(ctx, params)
+}
+
+// Organize updates a session's title, tags, pinned flag and archived state.
+func (s *service) Organize(ctx context.Context, id string, org Organization) (Session, error) {
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if org.Title != nil {
+		session.Title = *org.Title
+	}
+	if org.Tags != nil {
+		session.Tags = normalizeTags(*org.Tags)
+	}
+	if org.Pinned != nil {
+		session.Pinned = *org.Pinned
+	}
+	if org.Archived != nil {
+		session.Archived = *org.Archived
+	}
+
+	tags, err := json.Marshal(session.Tags)
+	if err != nil {
+		return Session{}, err
+	}
+	if err := s.q.UpdateSessionOrganization(ctx, db.UpdateSessionOrganizationParams{
+		Title:    session.Title,
+		Tags:     string(tags),
+		Pinned:   session.Pinned,
+		Archived: session.Archived,
+		ID:       id,
+	}); err != nil {
+		return Session{}, err
+	}
+
+	session, err = s.Get(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	err = s.Publish(ctx, pubsub.UpdatedEvent, session)
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones, keeping order.
+func normalizeTags(tags []string) []string {
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// DecodeTags parses the JSON tags column, treating malformed values as no tags.
+func DecodeTags(raw string) []string {
+	var tags []string
+	if err := json.Unmarshal([]byte(raw), &tags); err != nil || tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// DisabledTools lists the tools turned off for the session by name.
+func (s *service) DisabledTools(ctx context.Context, id string) ([]string, error) {
+	return s.q.ListSessionDisabledTools(ctx, id)
+}
+
+// SetToolsEnabled turns tools on and off for the session and returns the
+// tools now disabled. A tool in both lists ends up disabled.
+func (s *service) SetToolsEnabled(ctx context.Context, id string, enable, disable []string) ([]string, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	for _, name := range enable {
+		if err := s.q.EnableSessionTool(ctx, db.EnableSessionToolParams{SessionID: id, ToolName: name}); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range disable {
+		if err := s.q.DisableSessionTool(ctx, db.DisableSessionToolParams{SessionID: id, ToolName: name}); err != nil {
+			return nil, err
+		}
+	}
+	return s.DisabledTools(ctx, id)
+}
+
+// Reminders lists the names of the reminders attached to the session's turns.
+func (s *service) Reminders(ctx context.Context, id string) ([]string, error) {
+	return s.q.ListSessionReminders(ctx, id)
+}
+
+// SetReminders attaches reminders to the session and detaches others, and
+// returns the reminders now attached. A reminder in both lists ends up
+// detached.
+func (s *service) SetReminders(ctx context.Context, id string, add, remove []string) ([]string, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	for _, name := range add {
+		if err := s.q.AddSessionReminder(ctx, db.AddSessionReminderParams{SessionID: id, Name: name}); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range remove {
+		if err := s.q.RemoveSessionReminder(ctx, db.RemoveSessionReminderParams{SessionID: id, Name: name}); err != nil {
+			return nil, err
+		}
+	}
+	return s.Reminders(ctx, id)
+}
+
+// OutputProfile returns the name of the session's output profile, or "" when
+// responses are returned unlimited.
+func (s *service) OutputProfile(ctx context.Context, id string) (string, error) {
+	profile, err := s.q.GetSessionOutputProfile(ctx, id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return profile, err
+}
+
+// SetOutputProfile selects the session's output profile; "" removes it.
+func (s *service) SetOutputProfile(ctx context.Context, id string, profile string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if profile == "" {
+		return s.q.DeleteSessionOutputProfile(ctx, id)
+	}
+	return s.q.SetSessionOutputProfile(ctx, db.SetSessionOutputProfileParams{SessionID: id, Profile: profile})
+}
+
+// Locale returns the session's locale, or "" when it uses the configured one.
+func (s *service) Locale(ctx context.Context, id string) (string, error) {
+	locale, err := s.q.GetSessionLocale(ctx, id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return locale, err
+}
+
+// SetLocale selects the session's locale; "" returns it to the configured one.
+func (s *service) SetLocale(ctx context.Context, id string, locale string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if locale == "" {
+		return s.q.DeleteSessionLocale(ctx, id)
+	}
+	return s.q.SetSessionLocale(ctx, db.SetSessionLocaleParams{SessionID: id, Locale: locale})
+}
+
+// SandboxProfile returns the name of the sandbox profile the session's bash
+// commands run in, or "" when it uses the configured default.
+func (s *service) SandboxProfile(ctx context.Context, id string) (string, error) {
+	profile, err := s.q.GetSessionSandboxProfile(ctx, id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return profile, err
+}
+
+// SetSandboxProfile selects the session's sandbox profile; "" returns it to
+// the configured default.
+func (s *service) SetSandboxProfile(ctx context.Context, id string, profile string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if profile == "" {
+		return s.q.DeleteSessionSandboxProfile(ctx, id)
+	}
+	return s.q.SetSessionSandboxProfile(ctx, db.SetSessionSandboxProfileParams{SessionID: id, Profile: profile})
+}
+
+// AgentSettings returns the session's model and system prompt addendum.
+func (s *service) AgentSettings(ctx context.Context, id string) (AgentSettings, error) {
+	row, err := s.q.GetSessionAgentSettings(ctx, id)
+	if err == sql.ErrNoRows {
+		return AgentSettings{}, nil
+	}
+	if err != nil {
+		return AgentSettings{}, err
+	}
+	return AgentSettings{Model: row.Model, SystemPrompt: row.SystemPrompt}, nil
+}
+
+// SetAgentSettings replaces the session's model and system prompt addendum.
+func (s *service) SetAgentSettings(ctx context.Context, id string, settings AgentSettings) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.q.SetSessionAgentSettings(ctx, db.SetSessionAgentSettingsParams{
+		SessionID:    id,
+		Model:        settings.Model,
+		SystemPrompt: settings.SystemPrompt,
+	})
+}
+
+// Env returns the environment variables set for the session.
+func (s *service) Env(ctx context.Context, id string) (map[string]string, error) {
+	rows, err := s.q.ListSessionEnv(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(rows))
+	for _, row := range rows {
+		env[row.Name] = row.Value
+	}
+	return env, nil
+}
+
+// SetEnv sets and removes the session's environment variables and returns
+// them all. A variable both set and unset ends up removed.
+func (s *service) SetEnv(ctx context.Context, id string, set map[string]string, unset []string) (map[string]string, error) {
+	for name := range set {
+		if !validEnvName.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	for name, value := range set {
+		if err := s.q.SetSessionEnvVar(ctx, db.SetSessionEnvVarParams{SessionID: id, Name: name, Value: value}); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range unset {
+		if err := s.q.DeleteSessionEnvVar(ctx, db.DeleteSessionEnvVarParams{SessionID: id, Name: name}); err != nil {
+			return nil, err
+		}
+	}
+	return s.Env(ctx, id)
+}
+
+// AddCost adds to the session's cost in place, so concurrent tool calls don't
+// overwrite each other's charges.
+func (s *service) AddCost(ctx context.Context, id string, cost float64) error {
+	if err := s.q.AddSessionCost(ctx, db.AddSessionCostParams{Cost: cost, ID: id}); err != nil {
+		return err
+	}
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.Publish(ctx, pubsub.UpdatedEvent, session)
+}
+
+// AddCacheUsage adds one request's token counts to the session's totals.
+func (s *service) AddCacheUsage(ctx context.Context, id string, usage CacheUsage) error {
+	return s.q.AddSessionCacheUsage(ctx, db.AddSessionCacheUsageParams{
+		SessionID:            id,
+		InputTokens:          usage.InputTokens,
+		CacheCreationTokens:  usage.CacheCreationTokens,
+		CacheReadTokens:      usage.CacheReadTokens,
+		ThinkingRequests:     usage.ThinkingRequests,
+		ThinkingBudgetTokens: usage.ThinkingBudgetTokens,
+	})
+}
+
+func (s *service) CacheUsage(ctx context.Context, id string) (CacheUsage, error) {
+	usage, err := s.q.GetSessionCacheUsage(ctx, id)
+	if err == sql.ErrNoRows {
+		return CacheUsage{}, nil
+	}
+	if err != nil {
+		return CacheUsage{}, err
+	}
+	return CacheUsage{
+		Requests:             usage.Requests,
+		InputTokens:          usage.InputTokens,
+		CacheCreationTokens:  usage.CacheCreationTokens,
+		CacheReadTokens:      usage.CacheReadTokens,
+		ThinkingRequests:     usage.ThinkingRequests,
+		ThinkingBudgetTokens: usage.ThinkingBudgetTokens,
+	}, nil
 }
 
 func (s *service) Save(ctx context.Context, session Session) (Session, error) {
@@ -198,6 +621,40 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 
 // Removed List method for embedded binary
 
+// prepareWorkingDirectory creates the input/output layout and MIX.md that the
+// asset server and prompts expect in a session working directory.
+func prepareWorkingDirectory(workingDirectory string) error {
+	// Create input directory structure in session's working directory
+	inputDir := filepath.Join(workingDirectory, "input")
+	if err := os.MkdirAll(inputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create input directory: %w", err)
+	}
+
+	inputSubdirs := []string{"images", "videos", "audios", "text"}
+	for _, subdir := range inputSubdirs {
+		subdirPath := filepath.Join(inputDir, subdir)
+		if err := os.MkdirAll(subdirPath, 0o755); err != nil {
+			return fmt.Errorf("failed to create input subdirectory %s: %w", subdir, err)
+		}
+	}
+
+	// Create output directory for generated videos
+	outputDir := filepath.Join(workingDirectory, "output")
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Create MIX.md file if it doesn't exist
+	mixFilePath := filepath.Join(workingDirectory, "MIX.md")
+	if _, err := os.Stat(mixFilePath); os.IsNotExist(err) {
+		mixContent := "Sample MIX.md"
+		if err := os.WriteFile(mixFilePath, []byte(mixContent), 0o644); err != nil {
+			return fmt.Errorf("failed to create MIX.md file: %w", err)
+		}
+	}
+	return nil
+}
+
 // Conversion methods for different query return types
 
 // validateWorkingDirectory ensures working directory is valid
@@ -227,6 +684,9 @@ func (s *service) fromGetSessionByIDRow(item db.GetSessionByIDRow) (Session, err
 		CreatedAt:             item.CreatedAt,
 		UpdatedAt:             item.UpdatedAt,
 		WorkingDirectory:      item.WorkingDirectory.String,
+		Tags:                  DecodeTags(item.Tags),
+		Pinned:                item.Pinned,
+		Archived:              item.Archived,
 	}, nil
 }
 
@@ -249,6 +709,9 @@ func (s *service) fromListSessionsMetadataRow(item db.ListSessionsMetadataRow) (
 		CreatedAt:             item.CreatedAt,
 		UpdatedAt:             item.UpdatedAt,
 		WorkingDirectory:      item.WorkingDirectory.String,
+		Tags:                  DecodeTags(item.Tags),
+		Pinned:                item.Pinned,
+		Archived:              item.Archived,
 	}, nil
 }
 
@@ -271,6 +734,9 @@ func (s *service) fromCreatedSessionRow(item db.CreateSessionRow) (Session, erro
 		CreatedAt:             item.CreatedAt,
 		UpdatedAt:             item.UpdatedAt,
 		WorkingDirectory:      item.WorkingDirectory.String,
+		Tags:                  DecodeTags(item.Tags),
+		Pinned:                item.Pinned,
+		Archived:              item.Archived,
 	}, nil
 }
 
@@ -299,6 +765,9 @@ func (s *service) fromUpdateSessionRowWithCounts(ctx context.Context, item db.Up
 		CreatedAt:             item.CreatedAt,
 		UpdatedAt:             item.UpdatedAt,
 		WorkingDirectory:      item.WorkingDirectory.String,
+		Tags:                  DecodeTags(item.Tags),
+		Pinned:                item.Pinned,
+		Archived:              item.Archived,
 	}, nil
 }
 
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/logging"
+)
+
+const (
+	// DefaultThumbnailCacheMB and DefaultThumbnailMaxAge bound each
+	// .thumbnails cache unless configured otherwise
+	DefaultThumbnailCacheMB = 500
+	DefaultThumbnailMaxAge  = 7 * 24 * time.Hour
+
+	// thumbnailPruneInterval is how often the cleanup job prunes the caches
+	thumbnailPruneInterval = time.Hour
+)
+
+// thumbnailPrefix starts the names of every thumbnail of originalPath.
+func thumbnailPrefix(originalPath string) string {
+	return fmt.Sprintf("%x_", md5.Sum([]byte(originalPath)))
+}
+
+// thumbnailVersion starts the names of the thumbnails of originalPath as
+// last modified at modTime.
+func thumbnailVersion(originalPath string, modTime time.Time) string {
+	return thumbnailPrefix(originalPath) + strconv.FormatInt(modTime.UnixNano(), 36)
+}
+
+// removeStaleThumbnails removes the thumbnails of originalPath generated
+// before it was last modified at modTime.
+func removeStaleThumbnails(thumbnailDir, originalPath string, modTime time.Time) {
+	current := thumbnailVersion(originalPath, modTime) + "_"
+	stale, _ := filepath.Glob(filepath.Join(thumbnailDir, thumbnailPrefix(originalPath)+"*"))
+	for _, path := range stale {
+		if !strings.HasPrefix(filepath.Base(path), current) {
+			os.Remove(path)
+		}
+	}
+}
+
+// trackThumbnailDir adds a .thumbnails cache to the ones the cleanup job
+// prunes.
+func (as *AssetServer) trackThumbnailDir(dir string) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.thumbnailDirs[dir] = struct{}{}
+}
+
+// pruneThumbnailsLoop prunes the .thumbnails caches of the working
+// directories served until ctx is done.
+func (as *AssetServer) pruneThumbnailsLoop(ctx context.Context) {
+	ticker := time.NewTicker(thumbnailPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			as.PruneThumbnails()
+		}
+	}
+}
+
+// PruneThumbnails removes, from the .thumbnails cache of the current working
+// directory and each one written since startup, the thumbnails unused for
+// longer than the configured age, then the least recently used ones until
+// the cache fits in the configured size.
+func (as *AssetServer) PruneThumbnails() {
+	maxBytes := int64(DefaultThumbnailCacheMB) << 20
+	maxAge := DefaultThumbnailMaxAge
+	if cfg := config.Get(); cfg != nil {
+		if cfg.Thumbnails.MaxCacheMB > 0 {
+			maxBytes = int64(cfg.Thumbnails.MaxCacheMB) << 20
+		}
+		if cfg.Thumbnails.MaxAgeHours > 0 {
+			maxAge = time.Duration(cfg.Thumbnails.MaxAgeHours) * time.Hour
+		}
+	}
+
+	as.mu.RLock()
+	dirs := make([]string, 0, len(as.thumbnailDirs)+1)
+	for dir := range as.thumbnailDirs {
+		dirs = append(dirs, dir)
+	}
+	if as.currentWorkDir != "" {
+		current := filepath.Join(as.currentWorkDir, ".thumbnails")
+		if _, ok := as.thumbnailDirs[current]; !ok {
+			dirs = append(dirs, current)
+		}
+	}
+	as.mu.RUnlock()
+
+	for _, dir := range dirs {
+		removed, freed, err := pruneThumbnailDir(dir, maxBytes, maxAge, time.Now())
+		if err != nil && !os.IsNotExist(err) {
+			logging.Warn("Failed to prune thumbnail cache", "dir", dir, "error", err)
+			continue
+		}
+		if removed > 0 {
+			logging.Debug("Pruned thumbnail cache", "dir", dir, "removed", removed, "freedBytes", freed)
+		}
+	}
+}
+
+// pruneThumbnailDir removes the files of dir last used before now minus
+// maxAge, then the least recently used ones until the rest fit in maxBytes.
+// Thumbnails being generated, whose names start with a dot, only go once
+// they are too old. Serving a thumbnail updates its modification time, so
+// that is when it was last used.
+func pruneThumbnailDir(dir string, maxBytes int64, maxAge time.Duration, now time.Time) (int, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	type cached struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var kept []cached
+	var total, freed int64
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if now.Sub(info.ModTime()) > maxAge {
+			if os.Remove(path) == nil {
+				removed++
+				freed += info.Size()
+			}
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		kept = append(kept, cached{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	// Oldest first
+	sort.Slice(kept, func(i, j int) bool { return kept[i].modTime.Before(kept[j].modTime) })
+	for _, file := range kept {
+		if total <= maxBytes {
+			break
+		}
+		if os.Remove(file.path) == nil {
+			removed++
+			freed += file.size
+		}
+		total -= file.size
+	}
+	return removed, freed, nil
+}
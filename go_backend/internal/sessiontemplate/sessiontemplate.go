@@ -0,0 +1,165 @@
+// Package sessiontemplate stores presets for starting sessions, such as a
+// "bug triage" or "video edit workflow" setup: a system prompt addendum, the
+// tools to enable, a model and a working directory layout.
+package sessiontemplate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mix/internal/db"
+	"mix/internal/llm/models"
+)
+
+var ErrNotFound = errors.New("template not found")
+
+type Template struct {
+	Name        string
+	Description string
+	// SystemPrompt is added to the agent's system prompt
+	SystemPrompt string
+	// EnabledTools are the only tools enabled in the session; empty enables
+	// every tool
+	EnabledTools []string
+	// Model replaces the agent's model when set
+	Model string
+	// WorkingDirectory is the default working directory of the session
+	WorkingDirectory string
+	// Directories are created inside the working directory, relative to it
+	Directories []string
+	CreatedAt   int64
+	UpdatedAt   int64
+}
+
+type Service interface {
+	Create(ctx context.Context, template Template) (Template, error)
+	Get(ctx context.Context, name string) (Template, error)
+	List(ctx context.Context) ([]Template, error)
+	Delete(ctx context.Context, name string) error
+}
+
+type service struct {
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q}
+}
+
+// Create stores a new template. Names are unique.
+func (s *service) Create(ctx context.Context, template Template) (Template, error) {
+	if err := validate(&template); err != nil {
+		return Template{}, err
+	}
+	if _, err := s.Get(ctx, template.Name); err == nil {
+		return Template{}, fmt.Errorf("template %q already exists", template.Name)
+	} else if !errors.Is(err, ErrNotFound) {
+		return Template{}, err
+	}
+
+	enabledTools, err := json.Marshal(append([]string{}, template.EnabledTools...))
+	if err != nil {
+		return Template{}, err
+	}
+	directories, err := json.Marshal(append([]string{}, template.Directories...))
+	if err != nil {
+		return Template{}, err
+	}
+	row, err := s.q.CreateSessionTemplate(ctx, db.CreateSessionTemplateParams{
+		Name:             template.Name,
+		Description:      template.Description,
+		SystemPrompt:     template.SystemPrompt,
+		EnabledTools:     string(enabledTools),
+		Model:            template.Model,
+		WorkingDirectory: template.WorkingDirectory,
+		Directories:      string(directories),
+	})
+	if err != nil {
+		return Template{}, err
+	}
+	return fromRow(row)
+}
+
+func (s *service) Get(ctx context.Context, name string) (Template, error) {
+	row, err := s.q.GetSessionTemplate(ctx, name)
+	if err == sql.ErrNoRows {
+		return Template{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return Template{}, err
+	}
+	return fromRow(row)
+}
+
+func (s *service) List(ctx context.Context) ([]Template, error) {
+	rows, err := s.q.ListSessionTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]Template, len(rows))
+	for i, row := range rows {
+		if templates[i], err = fromRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+func (s *service) Delete(ctx context.Context, name string) error {
+	if _, err := s.Get(ctx, name); err != nil {
+		return err
+	}
+	return s.q.DeleteSessionTemplate(ctx, name)
+}
+
+// validate checks template and cleans its paths in place.
+func validate(template *Template) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return errors.New("template name is required")
+	}
+	if template.Model != "" {
+		if _, ok := models.Lookup(models.ModelID(template.Model)); !ok {
+			return fmt.Errorf("model %s not supported", template.Model)
+		}
+	}
+	if template.WorkingDirectory != "" {
+		absDir, err := filepath.Abs(template.WorkingDirectory)
+		if err != nil {
+			return fmt.Errorf("invalid working directory: %w", err)
+		}
+		template.WorkingDirectory = absDir
+	}
+	for i, dir := range template.Directories {
+		dir = filepath.Clean(dir)
+		if filepath.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("directory %q must be a path inside the working directory", template.Directories[i])
+		}
+		template.Directories[i] = dir
+	}
+	return nil
+}
+
+func fromRow(row db.SessionTemplate) (Template, error) {
+	template := Template{
+		Name:             row.Name,
+		Description:      row.Description,
+		SystemPrompt:     row.SystemPrompt,
+		Model:            row.Model,
+		WorkingDirectory: row.WorkingDirectory,
+		CreatedAt:        row.CreatedAt,
+		UpdatedAt:        row.UpdatedAt,
+	}
+	if err := json.Unmarshal([]byte(row.EnabledTools), &template.EnabledTools); err != nil {
+		return Template{}, fmt.Errorf("invalid enabled tools of template %s: %w", row.Name, err)
+	}
+	if err := json.Unmarshal([]byte(row.Directories), &template.Directories); err != nil {
+		return Template{}, fmt.Errorf("invalid directories of template %s: %w", row.Name, err)
+	}
+	return template, nil
+}
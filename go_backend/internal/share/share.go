@@ -0,0 +1,235 @@
+// Package share creates links granting read-only access to a session's
+// transcript without an API token, so a run can be shown to teammates
+// without exposing the rest of the server. A link's token carries the share's
+// ID and expiry signed with HMAC-SHA256; deleting the share revokes it.
+package share
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/db"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultTTL = 7 * 24 * time.Hour
+	MaxTTL     = 30 * 24 * time.Hour
+)
+
+// URLPrefix is the path shared transcripts are served under, followed by the
+// token
+const URLPrefix = "/shared/"
+
+// keyFile is the name of the generated signing key in the data directory
+const keyFile = "share.key"
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, forged or
+	// of revoked shares
+	ErrInvalidToken = errors.New("invalid share token")
+	ErrExpired      = errors.New("share link has expired")
+	ErrNotFound     = errors.New("share not found")
+)
+
+// Share is a link to a session's transcript.
+type Share struct {
+	ID        string
+	SessionID string
+	Token     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Path returns the share's URL path.
+func (s Share) Path() string {
+	return URLPrefix + s.Token
+}
+
+type Service interface {
+	// Create shares sessionID for ttl; zero uses DefaultTTL
+	Create(ctx context.Context, sessionID string, ttl time.Duration) (Share, error)
+	// List returns the unexpired shares of a session, newest first
+	List(ctx context.Context, sessionID string) ([]Share, error)
+	Revoke(ctx context.Context, sessionID, id string) error
+	// RevokeSession revokes every share of a session, returning how many
+	// there were
+	RevokeSession(ctx context.Context, sessionID string) (int64, error)
+	// Resolve returns the share a token grants access through
+	Resolve(ctx context.Context, token string) (Share, error)
+}
+
+type service struct {
+	q   db.Querier
+	key []byte
+}
+
+// NewService returns a Service signing tokens with key.
+func NewService(q db.Querier, key []byte) Service {
+	return &service{q: q, key: key}
+}
+
+// LoadKey returns the configured signing key, or the one generated into
+// dataDir, creating it the first time.
+func LoadKey(cfg config.SharingConfig, dataDir string) ([]byte, error) {
+	if cfg.Secret != "" {
+		return []byte(cfg.Secret), nil
+	}
+	path := filepath.Join(dataDir, keyFile)
+	if key, err := os.ReadFile(path); err == nil && len(key) > 0 {
+		return key, nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read share key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate share key: %w", err)
+	}
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to save share key: %w", err)
+	}
+	return key, nil
+}
+
+func (s *service) Create(ctx context.Context, sessionID string, ttl time.Duration) (Share, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > MaxTTL {
+		return Share{}, fmt.Errorf("expiry %s exceeds maximum of %s", ttl, MaxTTL)
+	}
+	now := time.Now()
+	// Expired shares are of no use, and pruning them here keeps the table small
+	if err := s.q.DeleteExpiredSessionShares(ctx, now.Unix()); err != nil {
+		return Share{}, err
+	}
+	row, err := s.q.CreateSessionShare(ctx, db.CreateSessionShareParams{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return Share{}, err
+	}
+	return s.fromRow(row), nil
+}
+
+func (s *service) List(ctx context.Context, sessionID string) ([]Share, error) {
+	rows, err := s.q.ListSessionShares(ctx, db.ListSessionSharesParams{
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]Share, len(rows))
+	for i, row := range rows {
+		shares[i] = s.fromRow(row)
+	}
+	return shares, nil
+}
+
+func (s *service) Revoke(ctx context.Context, sessionID, id string) error {
+	n, err := s.q.DeleteSessionShare(ctx, db.DeleteSessionShareParams{ID: id, SessionID: sessionID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (s *service) RevokeSession(ctx context.Context, sessionID string) (int64, error) {
+	return s.q.DeleteSessionShares(ctx, sessionID)
+}
+
+// Resolve checks the token's signature and expiry before looking the share
+// up, so forged tokens cost no query.
+func (s *service) Resolve(ctx context.Context, token string) (Share, error) {
+	id, expiresAt, ok := s.verify(token)
+	if !ok {
+		return Share{}, ErrInvalidToken
+	}
+	if !time.Now().Before(time.Unix(expiresAt, 0)) {
+		return Share{}, ErrExpired
+	}
+	row, err := s.q.GetSessionShare(ctx, id)
+	if err == sql.ErrNoRows {
+		return Share{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Share{}, err
+	}
+	if row.ExpiresAt != expiresAt {
+		return Share{}, ErrInvalidToken
+	}
+	return s.fromRow(row), nil
+}
+
+func (s *service) fromRow(row db.SessionShare) Share {
+	return Share{
+		ID:        row.ID,
+		SessionID: row.SessionID,
+		Token:     s.sign(row.ID, row.ExpiresAt),
+		CreatedAt: time.Unix(row.CreatedAt, 0),
+		ExpiresAt: time.Unix(row.ExpiresAt, 0),
+	}
+}
+
+// sign returns the token of a share: its ID and expiry, then their
+// signature, each base64url encoded and joined by a dot. Tokens are derived,
+// not stored, so listing a session's shares returns their links.
+func (s *service) sign(id string, expiresAt int64) string {
+	payload := []byte(id + ":" + strconv.FormatInt(expiresAt, 10))
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// verify returns the share ID and expiry a token carries, and whether its
+// signature is valid.
+func (s *service) verify(token string) (string, int64, bool) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", 0, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", 0, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, s.mac(payload)) {
+		return "", 0, false
+	}
+	id, expiry, ok := strings.Cut(string(payload), ":")
+	if !ok {
+		return "", 0, false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return id, expiresAt, true
+}
+
+func (s *service) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,177 @@
+package share
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shareRows keeps session shares in memory, counting lookups.
+type shareRows struct {
+	db.Querier
+	rows    map[string]db.SessionShare
+	lookups int
+}
+
+func newShareRows() *shareRows {
+	return &shareRows{rows: map[string]db.SessionShare{}}
+}
+
+func (s *shareRows) DeleteExpiredSessionShares(_ context.Context, expiresAt int64) error {
+	for id, row := range s.rows {
+		if row.ExpiresAt <= expiresAt {
+			delete(s.rows, id)
+		}
+	}
+	return nil
+}
+
+func (s *shareRows) CreateSessionShare(_ context.Context, arg db.CreateSessionShareParams) (db.SessionShare, error) {
+	row := db.SessionShare{ID: arg.ID, SessionID: arg.SessionID, ExpiresAt: arg.ExpiresAt, CreatedAt: time.Now().Unix()}
+	s.rows[row.ID] = row
+	return row, nil
+}
+
+func (s *shareRows) GetSessionShare(_ context.Context, id string) (db.SessionShare, error) {
+	s.lookups++
+	row, ok := s.rows[id]
+	if !ok {
+		return db.SessionShare{}, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+func TestSignAndVerify(t *testing.T) {
+	s := &service{key: []byte("signing-key")}
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	token := s.sign("share-1", expiresAt)
+	payload, signature, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "signed token", token: token, valid: true},
+		{name: "other key", token: (&service{key: []byte("other-key")}).sign("share-1", expiresAt)},
+		{name: "changed payload", token: base64.RawURLEncoding.EncodeToString([]byte("share-2:"+"9999999999")) + "." + signature},
+		{name: "changed signature", token: payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged"))},
+		{name: "no signature", token: payload},
+		{name: "payload not base64", token: "!!!." + signature},
+		{name: "signature not base64", token: payload + ".!!!"},
+		{name: "empty", token: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, expiry, ok := s.verify(tt.token)
+			assert.Equal(t, tt.valid, ok)
+			if tt.valid {
+				assert.Equal(t, "share-1", id)
+				assert.Equal(t, expiresAt, expiry)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformedPayload(t *testing.T) {
+	s := &service{key: []byte("signing-key")}
+	for _, payload := range []string{"share-1", "share-1:soon"} {
+		t.Run(payload, func(t *testing.T) {
+			token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(s.mac([]byte(payload)))
+			_, _, ok := s.verify(token)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	rows := newShareRows()
+	s := NewService(rows, []byte("signing-key")).(*service)
+	created, err := s.Create(ctx, "session-1", time.Hour)
+	require.NoError(t, err)
+	future := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		token   string
+		err     error
+		lookups int
+	}{
+		{name: "created share", token: created.Token, lookups: 1},
+		{name: "forged token", token: (&service{key: []byte("other-key")}).sign(created.ID, created.ExpiresAt.Unix()), err: ErrInvalidToken},
+		{name: "expired token", token: s.sign(created.ID, time.Now().Add(-time.Minute).Unix()), err: ErrExpired},
+		{name: "revoked share", token: s.sign("revoked", future), err: ErrInvalidToken, lookups: 1},
+		{name: "expiry differs from the share's", token: s.sign(created.ID, created.ExpiresAt.Unix()+60), err: ErrInvalidToken, lookups: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows.lookups = 0
+			shared, err := s.Resolve(ctx, tt.token)
+			// Tokens failing the signature or expiry check cost no query
+			assert.Equal(t, tt.lookups, rows.lookups)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, created.ID, shared.ID)
+			assert.Equal(t, "session-1", shared.SessionID)
+			assert.Equal(t, created.Token, shared.Token)
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	rows := newShareRows()
+	s := NewService(rows, []byte("signing-key"))
+	rows.rows["stale"] = db.SessionShare{ID: "stale", SessionID: "session-1", ExpiresAt: time.Now().Add(-time.Hour).Unix()}
+
+	shared, err := s.Create(ctx, "session-1", 0)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(DefaultTTL), shared.ExpiresAt, 2*time.Second)
+	assert.Equal(t, URLPrefix+shared.Token, shared.Path())
+	assert.NotContains(t, rows.rows, "stale")
+
+	_, err = s.Create(ctx, "session-1", MaxTTL+time.Hour)
+	assert.Error(t, err)
+}
+
+func TestLoadKey(t *testing.T) {
+	t.Run("configured secret", func(t *testing.T) {
+		dir := t.TempDir()
+		key, err := LoadKey(config.SharingConfig{Secret: "configured-secret"}, dir)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("configured-secret"), key)
+		assert.NoFileExists(t, filepath.Join(dir, keyFile))
+	})
+
+	t.Run("generated once", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "data")
+		key, err := LoadKey(config.SharingConfig{}, dir)
+		require.NoError(t, err)
+		assert.Len(t, key, 32)
+
+		info, err := os.Stat(filepath.Join(dir, keyFile))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		again, err := LoadKey(config.SharingConfig{}, dir)
+		require.NoError(t, err)
+		assert.Equal(t, key, again)
+	})
+}
@@ -0,0 +1,125 @@
+// Package streamtoken issues short-lived tokens that bind an SSE client to a
+// single session, so one client cannot observe another session's events.
+package streamtoken
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Scope string
+
+const (
+	// ScopeRead allows receiving events for the session
+	ScopeRead Scope = "read"
+	// ScopeWrite also allows sending messages to the session
+	ScopeWrite Scope = "write"
+)
+
+const (
+	DefaultTTL = 5 * time.Minute
+	MaxTTL     = time.Hour
+)
+
+var ErrInvalidToken = errors.New("invalid or expired stream token")
+
+type Token struct {
+	Value     string
+	SessionID string
+	Scope     Scope
+	ExpiresAt time.Time
+}
+
+// Allows reports whether the token grants scope. Write implies read.
+func (t Token) Allows(scope Scope) bool {
+	return t.Scope == ScopeWrite || t.Scope == scope
+}
+
+type Service interface {
+	Issue(sessionID string, scope Scope, ttl time.Duration) (Token, error)
+	Validate(value, sessionID string, scope Scope) (Token, error)
+	RevokeSession(sessionID string)
+}
+
+type service struct {
+	mu     sync.Mutex
+	tokens map[string]Token
+}
+
+func NewService() Service {
+	return &service{tokens: make(map[string]Token)}
+}
+
+// Issue creates a token for sessionID. A zero ttl uses DefaultTTL.
+func (s *service) Issue(sessionID string, scope Scope, ttl time.Duration) (Token, error) {
+	if scope != ScopeRead && scope != ScopeWrite {
+		return Token{}, fmt.Errorf("invalid scope %q", scope)
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > MaxTTL {
+		return Token{}, fmt.Errorf("ttl %s exceeds maximum of %s", ttl, MaxTTL)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return Token{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := Token{
+		Value:     hex.EncodeToString(buf),
+		SessionID: sessionID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	s.tokens[token.Value] = token
+	return token, nil
+}
+
+// Validate checks that value is a live token for sessionID granting scope.
+// Tokens stay valid until expiry so EventSource reconnects can reuse them.
+func (s *service) Validate(value, sessionID string, scope Scope) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[value]
+	if !ok || time.Now().After(token.ExpiresAt) {
+		return Token{}, ErrInvalidToken
+	}
+	if token.SessionID != sessionID {
+		return Token{}, fmt.Errorf("stream token is not valid for session %s", sessionID)
+	}
+	if !token.Allows(scope) {
+		return Token{}, fmt.Errorf("stream token does not grant %s access", scope)
+	}
+	return token, nil
+}
+
+// RevokeSession invalidates every token bound to sessionID.
+func (s *service) RevokeSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for value, token := range s.tokens {
+		if token.SessionID == sessionID {
+			delete(s.tokens, value)
+		}
+	}
+}
+
+func (s *service) pruneLocked() {
+	now := time.Now()
+	for value, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, value)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package toolstats aggregates the tool audit log into execution counts, error
+// rates and latency percentiles per tool and per MCP server, to show which
+// tools are slow or flaky.
+package toolstats
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"mix/internal/audit"
+	"mix/internal/config"
+	"mix/internal/db"
+)
+
+// BucketBoundsMs are the upper bounds of the latency histogram buckets, in
+// milliseconds. A last bucket without a bound counts the slower calls.
+var BucketBoundsMs = []int64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// Filter narrows the calls aggregated. Zero values are ignored.
+type Filter struct {
+	SessionID string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Bucket counts the calls that took at most LeMs milliseconds and more than
+// the previous bucket's bound. LeMs is 0 for the last, unbounded bucket.
+type Bucket struct {
+	LeMs  int64 `json:"leMs,omitempty"`
+	Count int64 `json:"count"`
+}
+
+// Stats aggregates executed calls. Calls denied permission or blocked by the
+// network policy never ran, so they are only counted in Denied.
+type Stats struct {
+	Calls     int64    `json:"calls"`
+	Errors    int64    `json:"errors"`
+	Denied    int64    `json:"denied"`
+	ErrorRate float64  `json:"errorRate"`
+	MeanMs    int64    `json:"meanMs"`
+	P50Ms     int64    `json:"p50Ms"`
+	P90Ms     int64    `json:"p90Ms"`
+	P99Ms     int64    `json:"p99Ms"`
+	MaxMs     int64    `json:"maxMs"`
+	Histogram []Bucket `json:"histogram"`
+}
+
+type ToolStats struct {
+	Tool string `json:"tool"`
+	// MCPServer is the configured MCP server providing the tool, if any
+	MCPServer string `json:"mcpServer,omitempty"`
+	Stats
+}
+
+type ServerStats struct {
+	Server string `json:"server"`
+	Stats
+}
+
+// Report holds the statistics of each tool and MCP server that was called,
+// most called first.
+type Report struct {
+	Tools   []ToolStats   `json:"tools"`
+	Servers []ServerStats `json:"servers"`
+}
+
+type Service interface {
+	Report(ctx context.Context, filter Filter) (Report, error)
+}
+
+type service struct {
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q}
+}
+
+func (s *service) Report(ctx context.Context, filter Filter) (Report, error) {
+	params := db.ListToolTimingsParams{
+		SessionID: sql.NullString{String: filter.SessionID, Valid: filter.SessionID != ""},
+	}
+	if !filter.Since.IsZero() {
+		params.Since = sql.NullInt64{Int64: filter.Since.Unix(), Valid: true}
+	}
+	if !filter.Until.IsZero() {
+		params.Until = sql.NullInt64{Int64: filter.Until.Unix(), Valid: true}
+	}
+	rows, err := s.q.ListToolTimings(ctx, params)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var servers []string
+	for name := range config.Get().MCPServers {
+		servers = append(servers, name)
+	}
+
+	tools := map[string]*collector{}
+	serverCollectors := map[string]*collector{}
+	var toolOrder, serverOrder []string
+	for _, row := range rows {
+		tool := tools[row.ToolName]
+		if tool == nil {
+			tool = &collector{}
+			tools[row.ToolName] = tool
+			toolOrder = append(toolOrder, row.ToolName)
+		}
+		tool.add(row)
+
+		if server := mcpServer(servers, row.ToolName); server != "" {
+			c := serverCollectors[server]
+			if c == nil {
+				c = &collector{}
+				serverCollectors[server] = c
+				serverOrder = append(serverOrder, server)
+			}
+			c.add(row)
+		}
+	}
+
+	report := Report{Tools: []ToolStats{}, Servers: []ServerStats{}}
+	for _, name := range toolOrder {
+		report.Tools = append(report.Tools, ToolStats{
+			Tool:      name,
+			MCPServer: mcpServer(servers, name),
+			Stats:     tools[name].stats(),
+		})
+	}
+	for _, name := range serverOrder {
+		report.Servers = append(report.Servers, ServerStats{Server: name, Stats: serverCollectors[name].stats()})
+	}
+	slices.SortStableFunc(report.Tools, func(a, b ToolStats) int { return byCalls(a.Stats, b.Stats) })
+	slices.SortStableFunc(report.Servers, func(a, b ServerStats) int { return byCalls(a.Stats, b.Stats) })
+	return report, nil
+}
+
+func byCalls(a, b Stats) int {
+	return cmp.Compare(b.Calls+b.Denied, a.Calls+a.Denied)
+}
+
+// mcpServer returns the configured MCP server whose tools are named
+// <server>_<tool> like name, preferring the longest server name.
+func mcpServer(servers []string, name string) string {
+	match := ""
+	for _, server := range servers {
+		if strings.HasPrefix(name, server+"_") && len(server) > len(match) {
+			match = server
+		}
+	}
+	return match
+}
+
+// collector gathers the calls of one tool or server.
+type collector struct {
+	durations []int64
+	errors    int64
+	denied    int64
+}
+
+func (c *collector) add(row db.ListToolTimingsRow) {
+	if row.PermissionDecision == audit.PermissionDenied || row.PermissionDecision == audit.PermissionBlocked {
+		c.denied++
+		return
+	}
+	c.durations = append(c.durations, row.DurationMs)
+	if row.IsError {
+		c.errors++
+	}
+}
+
+func (c *collector) stats() Stats {
+	stats := Stats{
+		Calls:     int64(len(c.durations)),
+		Errors:    c.errors,
+		Denied:    c.denied,
+		Histogram: make([]Bucket, len(BucketBoundsMs)+1),
+	}
+	for i, bound := range BucketBoundsMs {
+		stats.Histogram[i].LeMs = bound
+	}
+	if stats.Calls == 0 {
+		return stats
+	}
+
+	slices.Sort(c.durations)
+	var total int64
+	for _, duration := range c.durations {
+		total += duration
+		bucket, _ := slices.BinarySearch(BucketBoundsMs, duration)
+		stats.Histogram[bucket].Count++
+	}
+	stats.ErrorRate = float64(c.errors) / float64(stats.Calls)
+	stats.MeanMs = total / stats.Calls
+	stats.P50Ms = percentile(c.durations, 50)
+	stats.P90Ms = percentile(c.durations, 90)
+	stats.P99Ms = percentile(c.durations, 99)
+	stats.MaxMs = c.durations[len(c.durations)-1]
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations.
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,95 @@
+// Package tracing exports OpenTelemetry spans, so deployments can follow a
+// slow agent turn through its model requests and tool calls. Until Start is
+// called, spans go to OpenTelemetry's no-op tracer.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"mix/internal/config"
+	"mix/internal/version"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultServiceName is the service.name of the spans unless configured
+const DefaultServiceName = "mix"
+
+// Tracer returns the tracer the server's spans are started with.
+func Tracer() trace.Tracer {
+	return otel.Tracer("mix")
+}
+
+// Fail records err on span and marks it failed. A nil err leaves span as is.
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Exporter batches the spans the server records and sends them to an OTLP
+// collector.
+type Exporter struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Start installs the tracer provider exporting spans as cfg configures, until
+// Shutdown.
+func Start(ctx context.Context, cfg config.TracingConfig) (*Exporter, error) {
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		endpoint, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tracing endpoint: %w", err)
+		}
+		// Like OTEL_EXPORTER_OTLP_ENDPOINT, a collector's base URL receives
+		// traces at /v1/traces
+		if strings.Trim(endpoint.Path, "/") == "" {
+			endpoint.Path = "/v1/traces"
+		}
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint.String()))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	ratio := cfg.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", version.Version),
+		)),
+		// Spans of a sampled run are all kept, so traces are never partial
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return &Exporter{provider: provider}, nil
+}
+
+// Shutdown sends the spans not exported yet and stops exporting.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return e.provider.Shutdown(ctx)
+}
@@ -0,0 +1,205 @@
+package transcript
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"mix/internal/session"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/nfnt/resize"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// thumbnailWidth is the width of inlined thumbnails, in pixels
+	thumbnailWidth = 480
+	// maxThumbnailSourceBytes leaves larger images as links
+	maxThumbnailSourceBytes = 20 * 1024 * 1024
+	thumbnailTimeout        = 30 * time.Second
+)
+
+// markdown converts entries to HTML. Raw HTML in messages is escaped, so a
+// transcript can't run scripts.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle("github"),
+			highlighting.WithFormatOptions(chromahtml.WithClasses(false)),
+		),
+	),
+)
+
+type htmlEntry struct {
+	Role      string
+	Model     string
+	CreatedAt string
+	Body      template.HTML
+	Media     []htmlMedia
+}
+
+type htmlMedia struct {
+	Path string
+	// Thumbnail is a data URL, empty when none could be made
+	Thumbnail template.URL
+}
+
+func renderHTML(ctx context.Context, sess session.Session, entries []entry) (string, error) {
+	var header strings.Builder
+	writeHeader(&header, sess)
+	headerHTML, err := toHTML(header.String())
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		Title   string
+		Header  template.HTML
+		Entries []htmlEntry
+	}{Title: sess.Title, Header: headerHTML}
+	if data.Title == "" {
+		data.Title = "Session " + sess.ID
+	}
+
+	for _, e := range entries {
+		body, err := toHTML(entryMarkdown(e))
+		if err != nil {
+			return "", err
+		}
+		he := htmlEntry{
+			Role:      roleTitle(e.Role),
+			Model:     e.Model,
+			CreatedAt: e.CreatedAt.Format(time.RFC1123),
+			Body:      body,
+		}
+		for _, m := range e.Media {
+			he.Media = append(he.Media, htmlMedia{Path: m.Path, Thumbnail: thumbnailURL(ctx, m)})
+		}
+		data.Entries = append(data.Entries, he)
+	}
+
+	var out bytes.Buffer
+	if err := page.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render transcript: %w", err)
+	}
+	return out.String(), nil
+}
+
+func toHTML(source string) (template.HTML, error) {
+	var out bytes.Buffer
+	if err := markdown.Convert([]byte(source), &out); err != nil {
+		return "", fmt.Errorf("failed to convert markdown: %w", err)
+	}
+	return template.HTML(out.String()), nil
+}
+
+// thumbnailURL returns a JPEG thumbnail of an image or video as a data URL,
+// or "" for other media and when it can't be made.
+func thumbnailURL(ctx context.Context, m media) template.URL {
+	var thumbnail []byte
+	var err error
+	switch {
+	case m.Category == session.CategoryImage && m.Data != nil:
+		thumbnail, err = imageThumbnail(m.Data)
+	case m.Category == session.CategoryImage && m.FullPath != "":
+		var data []byte
+		if info, statErr := os.Stat(m.FullPath); statErr != nil || info.Size() > maxThumbnailSourceBytes {
+			return ""
+		}
+		if data, err = os.ReadFile(m.FullPath); err == nil {
+			thumbnail, err = imageThumbnail(data)
+		}
+	case m.Category == session.CategoryVideo && m.FullPath != "":
+		thumbnail, err = videoThumbnail(ctx, m.FullPath)
+	default:
+		return ""
+	}
+	if err != nil || len(thumbnail) == 0 {
+		return ""
+	}
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumbnail))
+}
+
+func imageThumbnail(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if img.Bounds().Dx() > thumbnailWidth {
+		img = resize.Resize(thumbnailWidth, 0, img, resize.Lanczos3)
+	}
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// videoThumbnail extracts a representative frame of the video's start with
+// ffmpeg.
+func videoThumbnail(ctx context.Context, path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, thumbnailTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", path,
+		"-vf", fmt.Sprintf("thumbnail,scale=%d:-2", thumbnailWidth),
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-vcodec", "mjpeg",
+		"-",
+	)
+	return cmd.Output()
+}
+
+var page = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font: 15px/1.55 -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; background: #f6f8fa; margin: 0; }
+main { max-width: 860px; margin: 0 auto; padding: 32px 20px; }
+header, section { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 16px 20px; margin-bottom: 16px; }
+section.user { border-left: 4px solid #0969da; }
+section.assistant { border-left: 4px solid #8250df; }
+.meta { color: #656d76; font-size: 13px; margin-bottom: 8px; }
+.meta strong { color: #1f2328; font-size: 15px; margin-right: 8px; }
+pre { overflow-x: auto; padding: 12px; border-radius: 6px; background: #f6f8fa; font-size: 13px; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+blockquote { margin: 0; padding: 0 12px; color: #656d76; border-left: 3px solid #d0d7de; }
+figure { display: inline-block; margin: 8px 12px 0 0; }
+figure img { max-width: 100%; border-radius: 6px; border: 1px solid #d0d7de; }
+figcaption { font-size: 12px; color: #656d76; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #d0d7de; padding: 4px 8px; }
+</style>
+</head>
+<body>
+<main>
+<header>{{.Header}}</header>
+{{range .Entries}}<section class="{{if eq .Role "User"}}user{{else}}assistant{{end}}">
+<div class="meta"><strong>{{.Role}}</strong>{{if .Model}}{{.Model}} · {{end}}{{.CreatedAt}}</div>
+{{.Body}}
+{{range .Media}}<figure>{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="{{.Path}}">{{end}}<figcaption>{{.Path}}</figcaption></figure>
+{{end}}</section>
+{{end}}</main>
+</body>
+</html>
+`))
@@ -0,0 +1,78 @@
+package transcript
+
+import (
+	"encoding/json"
+	"time"
+
+	"mix/internal/session"
+)
+
+// jsonTranscript is the document FormatJSON renders.
+type jsonTranscript struct {
+	SessionID string        `json:"sessionId"`
+	Title     string        `json:"title"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Messages  []jsonMessage `json:"messages"`
+}
+
+type jsonMessage struct {
+	Role      string         `json:"role"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Model     string         `json:"model,omitempty"`
+	Thinking  string         `json:"thinking,omitempty"`
+	Text      string         `json:"text"`
+	ToolCalls []jsonToolCall `json:"toolCalls,omitempty"`
+	Media     []jsonMedia    `json:"media,omitempty"`
+}
+
+type jsonToolCall struct {
+	Name    string `json:"name"`
+	Input   string `json:"input"`
+	Output  string `json:"output,omitempty"`
+	IsError bool   `json:"isError,omitempty"`
+}
+
+// jsonMedia leaves out attachment data; Path is the file's name for
+// attachments and its path relative to the working directory otherwise.
+type jsonMedia struct {
+	Path     string `json:"path"`
+	MIMEType string `json:"mimeType,omitempty"`
+	Category string `json:"category"`
+}
+
+// renderJSON renders entries as an indented JSON document, tool output
+// truncated as in the other formats.
+func renderJSON(sess session.Session, entries []entry) (string, error) {
+	doc := jsonTranscript{
+		SessionID: sess.ID,
+		Title:     sess.Title,
+		CreatedAt: time.Unix(sess.CreatedAt, 0).UTC(),
+		Messages:  make([]jsonMessage, 0, len(entries)),
+	}
+	for _, e := range entries {
+		msg := jsonMessage{
+			Role:      string(e.Role),
+			CreatedAt: e.CreatedAt.UTC(),
+			Model:     e.Model,
+			Thinking:  e.Thinking,
+			Text:      e.Text,
+		}
+		for _, call := range e.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, jsonToolCall{
+				Name:    call.Name,
+				Input:   call.Input,
+				Output:  truncateOutput(call.Output),
+				IsError: call.IsError,
+			})
+		}
+		for _, m := range e.Media {
+			msg.Media = append(msg.Media, jsonMedia{Path: m.Path, MIMEType: m.MIMEType, Category: string(m.Category)})
+		}
+		doc.Messages = append(doc.Messages, msg)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,97 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mix/internal/session"
+)
+
+// renderMarkdown renders entries as one Markdown document. The HTML
+// transcript converts the same Markdown of each entry.
+func renderMarkdown(sess session.Session, entries []entry) string {
+	var b strings.Builder
+	writeHeader(&b, sess)
+	for _, e := range entries {
+		b.WriteString("---\n\n")
+		writeEntryHeading(&b, e)
+		b.WriteString(entryMarkdown(e))
+		for _, m := range e.Media {
+			writeMediaLink(&b, m)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func writeHeader(b *strings.Builder, sess session.Session) {
+	title := sess.Title
+	if title == "" {
+		title = "Session " + sess.ID
+	}
+	fmt.Fprintf(b, "# %s\n\n", title)
+	fmt.Fprintf(b, "- Session: `%s`\n", sess.ID)
+	fmt.Fprintf(b, "- Created: %s\n", time.Unix(sess.CreatedAt, 0).Format(time.RFC1123))
+	if sess.WorkingDirectory != "" {
+		fmt.Fprintf(b, "- Working directory: `%s`\n", sess.WorkingDirectory)
+	}
+	fmt.Fprintf(b, "- Tokens: %d in, %d out\n", sess.PromptTokens, sess.CompletionTokens)
+	fmt.Fprintf(b, "- Cost: $%.4f\n\n", sess.Cost)
+}
+
+func writeEntryHeading(b *strings.Builder, e entry) {
+	fmt.Fprintf(b, "## %s", roleTitle(e.Role))
+	if e.Model != "" {
+		fmt.Fprintf(b, " (%s)", e.Model)
+	}
+	fmt.Fprintf(b, "\n\n_%s_\n\n", e.CreatedAt.Format(time.RFC1123))
+}
+
+// entryMarkdown is the thinking, text and tool calls of e, without media.
+func entryMarkdown(e entry) string {
+	var b strings.Builder
+	if e.Thinking != "" {
+		b.WriteString("> **Thinking**\n>\n")
+		for _, line := range strings.Split(strings.TrimRight(e.Thinking, "\n"), "\n") {
+			fmt.Fprintf(&b, "> %s\n", line)
+		}
+		b.WriteString("\n")
+	}
+	if text := strings.TrimSpace(e.Text); text != "" {
+		b.WriteString(text)
+		b.WriteString("\n\n")
+	}
+	for _, call := range e.ToolCalls {
+		writeToolCall(&b, call)
+	}
+	return b.String()
+}
+
+func writeToolCall(b *strings.Builder, call toolCall) {
+	fmt.Fprintf(b, "**Tool call: `%s`**\n\n", call.Name)
+	input, language := prettyInput(call.Input)
+	if strings.TrimSpace(input) != "" {
+		codeBlock(b, input, language)
+	}
+	if call.Output == "" {
+		return
+	}
+	if call.IsError {
+		b.WriteString("Error:\n\n")
+	} else {
+		b.WriteString("Output:\n\n")
+	}
+	codeBlock(b, truncateOutput(call.Output), "text")
+}
+
+func writeMediaLink(b *strings.Builder, m media) {
+	if m.Data != nil {
+		fmt.Fprintf(b, "_Attachment: %s (%s)_\n\n", m.Path, m.MIMEType)
+		return
+	}
+	if m.Category == session.CategoryImage {
+		fmt.Fprintf(b, "![%s](<%s>)\n\n", m.Path, m.Path)
+		return
+	}
+	fmt.Fprintf(b, "[%s](<%s>)\n\n", m.Path, m.Path)
+}
@@ -0,0 +1,272 @@
+// Package transcript renders a session's conversation as a Markdown or
+// standalone HTML document to share: messages, thinking, tool calls with
+// their output, and the media they reference.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mix/internal/message"
+	"mix/internal/redact"
+	"mix/internal/session"
+)
+
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+
+	// maxToolOutputBytes bounds the output shown for one tool call
+	maxToolOutputBytes = 8 * 1024
+)
+
+// Formats are the supported formats, for messages listing them
+var Formats = []string{FormatMarkdown, FormatHTML, FormatJSON}
+
+type Options struct {
+	// Format is FormatMarkdown, FormatHTML or FormatJSON
+	Format string
+	// IncludeThinking adds the assistant's thinking, which the messages
+	// must have been loaded with
+	IncludeThinking bool
+}
+
+// Render renders messages of sess in opts.Format. Markdown links media by
+// their path relative to the working directory, so it reads well next to
+// the session's files; HTML inlines thumbnails of images and videos and
+// needs nothing else. JSON lists the messages for programs to read.
+func Render(ctx context.Context, sess session.Session, messages []message.Message, opts Options) (string, error) {
+	entries := collect(sess, messages, opts.IncludeThinking)
+	switch opts.Format {
+	case FormatMarkdown, "":
+		return renderMarkdown(sess, entries), nil
+	case FormatHTML:
+		return renderHTML(ctx, sess, entries)
+	case FormatJSON:
+		return renderJSON(sess, entries)
+	default:
+		return "", fmt.Errorf("unsupported format %q: must be %s", opts.Format, strings.Join(Formats, ", "))
+	}
+}
+
+// RenderSession loads the session sessionID and its messages and renders
+// them in opts.Format, returning the session with the transcript.
+func RenderSession(ctx context.Context, sessions session.Service, messages message.Service, sessionID string, opts Options) (session.Session, string, error) {
+	sess, err := sessions.Get(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, "", fmt.Errorf("failed to get session: %w", err)
+	}
+
+	var msgs []message.Message
+	if opts.IncludeThinking {
+		msgs, err = messages.ListWithReasoning(ctx, sessionID)
+	} else {
+		msgs, err = messages.List(ctx, sessionID)
+	}
+	if err != nil {
+		return session.Session{}, "", fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	content, err := Render(ctx, sess, msgs, opts)
+	if err != nil {
+		return session.Session{}, "", err
+	}
+	return sess, content, nil
+}
+
+// Filename suggests a file name for the transcript of sess in format.
+func Filename(sess session.Session, format string) string {
+	name := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(sess.Title), "-"), "-")
+	if name == "" {
+		name = "session-" + sess.ID
+	}
+	if format == "" {
+		format = FormatMarkdown
+	}
+	return name + "." + format
+}
+
+var (
+	nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+	// mediaPath matches words that may be paths of media files; MediaCategory
+	// decides which are
+	mediaPath = regexp.MustCompile("[^\\s\"'`()<>\\[\\]{},;]+\\.[A-Za-z0-9]{2,4}\\b")
+)
+
+// entry is a user or assistant message as the transcript shows it.
+type entry struct {
+	Role      message.MessageRole
+	CreatedAt time.Time
+	Model     string
+	Thinking  string
+	Text      string
+	ToolCalls []toolCall
+	Media     []media
+}
+
+type toolCall struct {
+	Name  string
+	Input string
+	// Output is empty when the call has no result
+	Output  string
+	IsError bool
+}
+
+// media is a file or image a message references. Path is relative to the
+// working directory when inside it; Data is set for attachments.
+type media struct {
+	Path     string
+	FullPath string
+	MIMEType string
+	Data     []byte
+	Category session.FileTypeCategory
+}
+
+// collect turns messages into entries, attaching tool results to their calls.
+// Secrets in the text are redacted, since transcripts are shared.
+func collect(sess session.Session, messages []message.Message, includeThinking bool) []entry {
+	results := make(map[string]message.ToolResult)
+	for _, msg := range messages {
+		for _, result := range msg.ToolResults() {
+			results[result.ToolCallID] = result
+		}
+	}
+
+	var entries []entry
+	for _, msg := range messages {
+		if msg.Role != message.User && msg.Role != message.Assistant {
+			continue
+		}
+		e := entry{
+			Role:      msg.Role,
+			CreatedAt: time.Unix(msg.CreatedAt, 0),
+			Text:      redact.String(msg.Content().Text),
+		}
+		if msg.Role == message.Assistant {
+			_, model := msg.AnsweredBy()
+			e.Model = string(model)
+		}
+		if includeThinking {
+			e.Thinking = redact.String(msg.ReasoningContent().Thinking)
+		}
+
+		seen := make(map[string]bool)
+		for _, attachment := range msg.BinaryContent() {
+			category, _ := session.MediaCategory(attachment.Path)
+			if strings.HasPrefix(attachment.MIMEType, "image/") {
+				category = session.CategoryImage
+			}
+			e.Media = append(e.Media, media{
+				Path:     filepath.Base(attachment.Path),
+				MIMEType: attachment.MIMEType,
+				Data:     attachment.Data,
+				Category: category,
+			})
+			seen[attachment.Path] = true
+		}
+		for _, image := range msg.ImageURLContent() {
+			e.Media = append(e.Media, media{Path: image.URL, Category: session.CategoryImage})
+		}
+		e.Media = append(e.Media, referencedMedia(sess.WorkingDirectory, e.Text, seen)...)
+
+		for _, call := range msg.ToolCalls() {
+			tc := toolCall{Name: call.Name, Input: redact.String(call.Input)}
+			if result, ok := results[call.ID]; ok {
+				tc.Output, tc.IsError = redact.String(result.Content), result.IsError
+				e.Media = append(e.Media, referencedMedia(sess.WorkingDirectory, result.Content, seen)...)
+			}
+			e.ToolCalls = append(e.ToolCalls, tc)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// referencedMedia returns the media files in workingDir that text mentions,
+// leaving out those in seen. Files outside the working directory are left
+// out so a shared transcript only shows the session's own files.
+func referencedMedia(workingDir, text string, seen map[string]bool) []media {
+	if workingDir == "" {
+		return nil
+	}
+	var found []media
+	for _, candidate := range mediaPath.FindAllString(text, -1) {
+		category, ok := session.MediaCategory(candidate)
+		if !ok {
+			continue
+		}
+		fullPath := candidate
+		if !filepath.IsAbs(fullPath) {
+			fullPath = filepath.Join(workingDir, fullPath)
+		}
+		fullPath = filepath.Clean(fullPath)
+		rel, err := filepath.Rel(workingDir, fullPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || seen[fullPath] {
+			continue
+		}
+		if info, err := os.Stat(fullPath); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		seen[fullPath] = true
+		found = append(found, media{Path: filepath.ToSlash(rel), FullPath: fullPath, Category: category})
+	}
+	return found
+}
+
+func roleTitle(role message.MessageRole) string {
+	if role == message.User {
+		return "User"
+	}
+	return "Assistant"
+}
+
+// prettyInput indents a tool call's JSON input, leaving other input as is.
+func prettyInput(input string) (string, string) {
+	var value any
+	if err := json.Unmarshal([]byte(input), &value); err != nil {
+		return input, ""
+	}
+	pretty, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return input, ""
+	}
+	return string(pretty), "json"
+}
+
+func truncateOutput(output string) string {
+	if len(output) <= maxToolOutputBytes {
+		return output
+	}
+	end := maxToolOutputBytes
+	for end > 0 && !utf8.RuneStart(output[end]) {
+		end--
+	}
+	return output[:end] + fmt.Sprintf("\n… (%d more bytes)", len(output)-end)
+}
+
+// fence returns a code fence longer than any run of backticks in code.
+func fence(code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+func codeBlock(b *strings.Builder, code, language string) {
+	f := fence(code)
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n\n", f, language, strings.TrimRight(code, "\n"), f)
+}
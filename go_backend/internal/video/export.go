@@ -1,6 +1,7 @@
 package video
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,11 +9,17 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // ExportService handles video export operations
 type ExportService struct {
 	projectRoot string
+
+	// jobsCtx is cancelled on shutdown to stop export scripts in flight
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	jobs       sync.WaitGroup
 }
 
 // NewExportService creates a new video export service
@@ -22,11 +29,32 @@ func NewExportService() (*ExportService, error) {
 		return nil, fmt.Errorf("failed to initialize video export service: %w", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &ExportService{
 		projectRoot: projectRoot,
+		jobsCtx:     ctx,
+		cancelJobs:  cancel,
 	}, nil
 }
 
+// Shutdown kills running exports and waits for them to exit, or until ctx
+// is done.
+func (s *ExportService) Shutdown(ctx context.Context) error {
+	s.cancelJobs()
+
+	done := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // ExportRequest contains the parameters for video export
 type ExportRequest struct {
 	ConfigJSON string `json:"config"`
@@ -78,7 +106,9 @@ func (s *ExportService) executeVideoExport(configJSON, outputPath string) error
 	}
 
 	// Run export script with config via stdin (no temp files)
-	cmd := exec.Command("./scripts/export_video.sh", "--output", outputName)
+	s.jobs.Add(1)
+	defer s.jobs.Done()
+	cmd := exec.CommandContext(s.jobsCtx, "./scripts/export_video.sh", "--output", outputName)
 	cmd.Dir = remotionDir
 	cmd.Stdin = strings.NewReader(configJSON)
 
@@ -0,0 +1,351 @@
+// Package webhook posts a JSON payload to the URLs configured in webhooks when
+// an agent response completes or fails, so external systems such as Slack, CI
+// or render farms can react to finished runs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"mix/internal/config"
+	"mix/internal/llm/agent"
+	"mix/internal/logging"
+	"mix/internal/message"
+	"mix/internal/pubsub"
+	"mix/internal/session"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body,
+	// keyed with the webhook's secret
+	SignatureHeader = "X-Mix-Signature"
+	// EventHeader and DeliveryHeader repeat the payload's event and id
+	EventHeader    = "X-Mix-Event"
+	DeliveryHeader = "X-Mix-Delivery"
+)
+
+const (
+	// queueSize bounds the finished runs waiting to be delivered; more are
+	// dropped
+	queueSize          = 256
+	defaultMaxAttempts = 4
+	// firstRetryDelay doubles after each failed attempt
+	firstRetryDelay = time.Second
+	requestTimeout  = 30 * time.Second
+)
+
+// Payload is the body posted to webhooks. ID is the same for every attempt
+// of a delivery, so receivers can ignore retries they already handled.
+type Payload struct {
+	ID    string    `json:"id"`
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Agent string    `json:"agent"`
+	// Message is the final response; failed runs may not have one
+	Message   *MessagePayload   `json:"message,omitempty"`
+	Session   SessionPayload    `json:"session"`
+	ToolCalls []ToolCallPayload `json:"toolCalls"`
+	Usage     UsagePayload      `json:"usage"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type SessionPayload struct {
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	WorkingDirectory string `json:"workingDirectory"`
+}
+
+type MessagePayload struct {
+	ID           string `json:"id"`
+	Content      string `json:"content"`
+	Provider     string `json:"provider,omitempty"`
+	Model        string `json:"model,omitempty"`
+	FinishReason string `json:"finishReason,omitempty"`
+}
+
+// ToolCallPayload is a tool call made during the run. IsError is set when
+// the tool reported an error.
+type ToolCallPayload struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Input   string `json:"input"`
+	IsError bool   `json:"isError"`
+}
+
+// UsagePayload is the cost of the run, and the token and cost totals of the
+// session after it.
+type UsagePayload struct {
+	Cost                    float64 `json:"cost"`
+	SessionCost             float64 `json:"sessionCost"`
+	SessionPromptTokens     int64   `json:"sessionPromptTokens"`
+	SessionCompletionTokens int64   `json:"sessionCompletionTokens"`
+}
+
+// Dispatcher delivers the finished runs of an agent to webhooks in the
+// background.
+type Dispatcher struct {
+	webhooks  []config.WebhookConfig
+	agentName string
+	sessions  session.Service
+	messages  message.Service
+	client    *http.Client
+	queue     chan agent.AgentEvent
+
+	// cancel stops collecting events; abort gives up on pending deliveries
+	cancel     context.CancelFunc
+	abortCtx   context.Context
+	abort      context.CancelFunc
+	done       sync.WaitGroup
+	deliveries sync.WaitGroup
+	dropped    int
+}
+
+// Start delivers the finished runs of the named agent to webhooks until
+// Shutdown.
+func Start(webhooks []config.WebhookConfig, agentName config.AgentName, events pubsub.Suscriber[agent.AgentEvent], sessions session.Service, messages message.Service) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	abortCtx, abort := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		webhooks:  webhooks,
+		agentName: string(agentName),
+		sessions:  sessions,
+		messages:  messages,
+		client:    &http.Client{Timeout: requestTimeout},
+		queue:     make(chan agent.AgentEvent, queueSize),
+		cancel:    cancel,
+		abortCtx:  abortCtx,
+		abort:     abort,
+	}
+
+	subscription := events.Subscribe(ctx)
+	d.done.Add(2)
+	go d.collect(subscription)
+	go d.dispatch()
+	logging.Info("Delivering agent runs to webhooks", "webhooks", len(webhooks), "agent", agentName)
+	return d
+}
+
+// collect queues the events that end a run. Cancelled runs are neither
+// completed nor failed and aren't delivered.
+func (d *Dispatcher) collect(events <-chan pubsub.Event[agent.AgentEvent]) {
+	defer d.done.Done()
+	defer close(d.queue)
+	defer logging.RecoverPanic("webhooks", nil)
+	for event := range events {
+		e := event.Payload
+		if !e.Done || e.SessionID == "" {
+			continue
+		}
+		switch {
+		case e.Type == agent.AgentEventTypeResponse:
+		case e.Type == agent.AgentEventTypeError && !errors.Is(e.Error, agent.ErrRequestCancelled) && !errors.Is(e.Error, context.Canceled):
+		default:
+			continue
+		}
+		select {
+		case d.queue <- e:
+		default:
+			d.dropped++
+			if d.dropped == 1 || d.dropped%100 == 0 {
+				logging.Warn("Webhook queue is full, dropping runs", "dropped", d.dropped)
+			}
+		}
+	}
+}
+
+// dispatch builds the payload of each queued run and delivers it to the
+// webhooks subscribed to its event, each in its own goroutine so a slow
+// receiver doesn't hold up the others.
+func (d *Dispatcher) dispatch() {
+	defer d.done.Done()
+	defer logging.RecoverPanic("webhooks", nil)
+	for event := range d.queue {
+		payload := d.payload(event)
+		body, err := json.Marshal(payload)
+		if err != nil {
+			logging.Error("Failed to encode webhook payload", "session", event.SessionID, "error", err)
+			continue
+		}
+		for _, webhook := range d.webhooks {
+			if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, payload.Event) {
+				continue
+			}
+			d.deliveries.Add(1)
+			go func(webhook config.WebhookConfig) {
+				defer d.deliveries.Done()
+				defer logging.RecoverPanic("webhook-delivery", nil)
+				if err := d.deliver(webhook, payload, body); err != nil {
+					logging.Error("Webhook delivery failed", "url", webhook.URL, "event", payload.Event, "delivery", payload.ID, "error", err)
+				}
+			}(webhook)
+		}
+	}
+}
+
+func (d *Dispatcher) payload(event agent.AgentEvent) Payload {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	payload := Payload{
+		ID:        uuid.New().String(),
+		Event:     config.WebhookResponseCompleted,
+		Time:      time.Now().UTC(),
+		Agent:     d.agentName,
+		Session:   SessionPayload{ID: event.SessionID},
+		ToolCalls: []ToolCallPayload{},
+	}
+	if event.Type == agent.AgentEventTypeError {
+		payload.Event = config.WebhookResponseFailed
+		payload.Error = event.Error.Error()
+	}
+	if msg := event.Message; msg.ID != "" {
+		provider, model := msg.AnsweredBy()
+		payload.Message = &MessagePayload{
+			ID:           msg.ID,
+			Content:      msg.Content().String(),
+			Provider:     string(provider),
+			Model:        string(model),
+			FinishReason: string(msg.FinishReason()),
+		}
+	}
+
+	if sess, err := d.sessions.Get(ctx, event.SessionID); err != nil {
+		logging.Warn("Failed to load session for webhook", "session", event.SessionID, "error", err)
+	} else {
+		payload.Session.Title = sess.Title
+		payload.Session.WorkingDirectory = sess.WorkingDirectory
+		payload.Usage.SessionCost = sess.Cost
+		payload.Usage.SessionPromptTokens = sess.PromptTokens
+		payload.Usage.SessionCompletionTokens = sess.CompletionTokens
+	}
+
+	msgs, err := d.messages.List(ctx, event.SessionID)
+	if err != nil {
+		logging.Warn("Failed to load messages for webhook", "session", event.SessionID, "error", err)
+		return payload
+	}
+	// The run is everything after the last user message
+	start := 0
+	for i, msg := range msgs {
+		if msg.Role == message.User {
+			start = i + 1
+		}
+	}
+	failed := make(map[string]bool)
+	for _, msg := range msgs[start:] {
+		for _, result := range msg.ToolResults() {
+			failed[result.ToolCallID] = result.IsError
+		}
+	}
+	for _, msg := range msgs[start:] {
+		if msg.Role != message.Assistant {
+			continue
+		}
+		payload.Usage.Cost += msg.Cost()
+		for _, call := range msg.ToolCalls() {
+			payload.ToolCalls = append(payload.ToolCalls, ToolCallPayload{
+				ID:      call.ID,
+				Name:    call.Name,
+				Input:   call.Input,
+				IsError: failed[call.ID],
+			})
+		}
+	}
+	return payload
+}
+
+// deliver posts body to the webhook, retrying network errors, 429 and 5xx
+// responses with exponential backoff.
+func (d *Dispatcher) deliver(webhook config.WebhookConfig, payload Payload, body []byte) error {
+	attempts := webhook.MaxAttempts
+	if attempts == 0 {
+		attempts = defaultMaxAttempts
+	}
+	delay := firstRetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = d.post(webhook, payload, body)
+		if err == nil || !retry || attempt == attempts {
+			return err
+		}
+		logging.Warn("Webhook delivery attempt failed, retrying", "url", webhook.URL, "attempt", attempt, "retryIn", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-d.abortCtx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (d *Dispatcher) post(webhook config.WebhookConfig, payload Payload, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(d.abortCtx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mix-webhook")
+	req.Header.Set(EventHeader, payload.Event)
+	req.Header.Set(DeliveryHeader, payload.ID)
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+}
+
+// Sign returns the SignatureHeader value of body, for receivers to compare
+// against with a constant-time comparison.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Shutdown stops collecting runs and waits for the queued ones to be
+// delivered. Deliveries still retrying when ctx expires are abandoned.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.cancel()
+	delivered := make(chan struct{})
+	go func() {
+		d.done.Wait()
+		d.deliveries.Wait()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-ctx.Done():
+		d.abort()
+		return ctx.Err()
+	}
+	d.abort()
+	d.client.CloseIdleConnections()
+	return nil
+}